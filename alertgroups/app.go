@@ -0,0 +1,175 @@
+package alertgroups
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+// alertGroupSetting represents an alert group setting.
+// See https://mackerel.io/api-docs/entry/alert-group-settings .
+type alertGroupSetting struct {
+	ID                   string   `json:"id,omitempty"`
+	Name                 string   `json:"name"`
+	Memo                 string   `json:"memo,omitempty"`
+	ServiceScopes        []string `json:"serviceScopes,omitempty"`
+	RoleScopes           []string `json:"roleScopes,omitempty"`
+	MonitorScopes        []string `json:"monitorScopes,omitempty"`
+	NotificationInterval int64    `json:"notificationInterval,omitempty"`
+}
+
+// alertGroupSettingsClient is the client of the alert group settings API, which mackerel-client-go does not support yet.
+type alertGroupSettingsClient interface {
+	FindAlertGroupSettings() ([]*alertGroupSetting, error)
+	CreateAlertGroupSetting(param *alertGroupSetting) (*alertGroupSetting, error)
+	UpdateAlertGroupSetting(id string, param *alertGroupSetting) (*alertGroupSetting, error)
+	DeleteAlertGroupSetting(id string) (*alertGroupSetting, error)
+}
+
+type apiClient struct {
+	client *mackerel.Client
+}
+
+const alertGroupSettingsPath = "/api/v0/alert-group-settings"
+
+func (c *apiClient) FindAlertGroupSettings() ([]*alertGroupSetting, error) {
+	var resp struct {
+		AlertGroupSettings []*alertGroupSetting `json:"alertGroupSettings"`
+	}
+	if err := mackerelclient.RequestJSON(c.client, "GET", alertGroupSettingsPath, url.Values{}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.AlertGroupSettings, nil
+}
+
+func (c *apiClient) CreateAlertGroupSetting(param *alertGroupSetting) (*alertGroupSetting, error) {
+	var s alertGroupSetting
+	if err := mackerelclient.RequestJSON(c.client, "POST", alertGroupSettingsPath, url.Values{}, param, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (c *apiClient) UpdateAlertGroupSetting(id string, param *alertGroupSetting) (*alertGroupSetting, error) {
+	var s alertGroupSetting
+	if err := mackerelclient.RequestJSON(c.client, "PUT", alertGroupSettingsPath+"/"+id, url.Values{}, param, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+func (c *apiClient) DeleteAlertGroupSetting(id string) (*alertGroupSetting, error) {
+	var s alertGroupSetting
+	if err := mackerelclient.RequestJSON(c.client, "DELETE", alertGroupSettingsPath+"/"+id, url.Values{}, nil, &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+type appLogger interface {
+	Log(string, string)
+}
+
+type alertGroupsApp struct {
+	client    alertGroupSettingsClient
+	logger    appLogger
+	outStream io.Writer
+}
+
+func (app *alertGroupsApp) list(output *format.Output) error {
+	settings, err := app.client.FindAlertGroupSettings()
+	if err != nil {
+		return err
+	}
+	return output.Print(app.outStream, settings, func() *format.Table {
+		return alertGroupSettingsTable(settings)
+	})
+}
+
+func alertGroupSettingsTable(settings []*alertGroupSetting) *format.Table {
+	t := format.NewTable("ID", "NAME", "SCOPE", "NOTIFICATION_INTERVAL")
+	for _, s := range settings {
+		var scopes []string
+		scopes = append(scopes, s.ServiceScopes...)
+		scopes = append(scopes, s.RoleScopes...)
+		for _, id := range s.MonitorScopes {
+			scopes = append(scopes, "monitor:"+id)
+		}
+		interval := "-"
+		if s.NotificationInterval > 0 {
+			interval = fmt.Sprintf("%dm", s.NotificationInterval)
+		}
+		t.Append(s.ID, s.Name, strings.Join(scopes, ","), interval)
+	}
+	return t
+}
+
+func validateAlertGroupSetting(s *alertGroupSetting) error {
+	if s.Name == "" {
+		return fmt.Errorf("the name of the alert group setting is empty")
+	}
+	if s.NotificationInterval < 0 {
+		return fmt.Errorf("the notification interval of %s should not be negative: %d", s.Name, s.NotificationInterval)
+	}
+	for _, r := range s.RoleScopes {
+		if !strings.Contains(r, ":") {
+			return fmt.Errorf("the role scope of %s should be <service>:<role>: %s", s.Name, r)
+		}
+	}
+	return nil
+}
+
+func (app *alertGroupsApp) create(s *alertGroupSetting) error {
+	if err := validateAlertGroupSetting(s); err != nil {
+		return err
+	}
+	setting, err := app.client.CreateAlertGroupSetting(s)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("created", fmt.Sprintf("%s (%s)", setting.Name, setting.ID))
+	return nil
+}
+
+// update modifies the alert group setting of the id by the function and updates it.
+func (app *alertGroupsApp) update(id string, modify func(*alertGroupSetting)) error {
+	settings, err := app.client.FindAlertGroupSettings()
+	if err != nil {
+		return err
+	}
+	var s *alertGroupSetting
+	for _, x := range settings {
+		if x.ID == id {
+			s = x
+		}
+	}
+	if s == nil {
+		return fmt.Errorf("alert group setting not found: %s", id)
+	}
+	modify(s)
+	if err := validateAlertGroupSetting(s); err != nil {
+		return err
+	}
+	s.ID = ""
+	setting, err := app.client.UpdateAlertGroupSetting(id, s)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("updated", fmt.Sprintf("%s (%s)", setting.Name, setting.ID))
+	return nil
+}
+
+func (app *alertGroupsApp) delete(id string) error {
+	setting, err := app.client.DeleteAlertGroupSetting(id)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("deleted", fmt.Sprintf("%s (%s)", setting.Name, setting.ID))
+	return nil
+}
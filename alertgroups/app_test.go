@@ -0,0 +1,124 @@
+package alertgroups
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+)
+
+type testLogger struct {
+	w io.Writer
+}
+
+func (l *testLogger) Log(prefix, message string) {
+	fmt.Fprintln(l.w, prefix, message)
+}
+
+type fakeClient struct {
+	settings []*alertGroupSetting
+	requests []string
+}
+
+func (c *fakeClient) FindAlertGroupSettings() ([]*alertGroupSetting, error) {
+	return c.settings, nil
+}
+
+func (c *fakeClient) CreateAlertGroupSetting(param *alertGroupSetting) (*alertGroupSetting, error) {
+	c.requests = append(c.requests, fmt.Sprintf("create %+v", *param))
+	s := *param
+	s.ID = "new"
+	return &s, nil
+}
+
+func (c *fakeClient) UpdateAlertGroupSetting(id string, param *alertGroupSetting) (*alertGroupSetting, error) {
+	c.requests = append(c.requests, fmt.Sprintf("update %s %+v", id, *param))
+	s := *param
+	s.ID = id
+	return &s, nil
+}
+
+func (c *fakeClient) DeleteAlertGroupSetting(id string) (*alertGroupSetting, error) {
+	c.requests = append(c.requests, "delete "+id)
+	return &alertGroupSetting{ID: id, Name: "deleted"}, nil
+}
+
+func TestAPIClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v0/alert-group-settings":
+			fmt.Fprint(w, `{"alertGroupSettings": [{"id": "4Xb1vLdE2Yt", "name": "Blog", "serviceScopes": ["Blog"], "notificationInterval": 60}]}`)
+		case "POST /api/v0/alert-group-settings":
+			assert.JSONEq(t, `{"name": "Shop", "monitorScopes": ["2cSZzK3XfmG"]}`, string(body))
+			fmt.Fprint(w, `{"id": "4Xb1vLdE2Yu", "name": "Shop", "monitorScopes": ["2cSZzK3XfmG"]}`)
+		case "PUT /api/v0/alert-group-settings/4Xb1vLdE2Yu":
+			assert.JSONEq(t, `{"name": "Shop", "memo": "updated"}`, string(body))
+			fmt.Fprint(w, `{"id": "4Xb1vLdE2Yu", "name": "Shop", "memo": "updated"}`)
+		case "DELETE /api/v0/alert-group-settings/4Xb1vLdE2Yu":
+			fmt.Fprint(w, `{"id": "4Xb1vLdE2Yu", "name": "Shop"}`)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	client, _ := mackerel.NewClientWithOptions("dummy", ts.URL, false)
+	c := &apiClient{client}
+
+	settings, err := c.FindAlertGroupSettings()
+	assert.NoError(t, err)
+	assert.Equal(t, []*alertGroupSetting{{ID: "4Xb1vLdE2Yt", Name: "Blog", ServiceScopes: []string{"Blog"}, NotificationInterval: 60}}, settings)
+
+	s, err := c.CreateAlertGroupSetting(&alertGroupSetting{Name: "Shop", MonitorScopes: []string{"2cSZzK3XfmG"}})
+	assert.NoError(t, err)
+	assert.Equal(t, "4Xb1vLdE2Yu", s.ID)
+
+	s, err = c.UpdateAlertGroupSetting("4Xb1vLdE2Yu", &alertGroupSetting{Name: "Shop", Memo: "updated"})
+	assert.NoError(t, err)
+	assert.Equal(t, "updated", s.Memo)
+
+	s, err = c.DeleteAlertGroupSetting("4Xb1vLdE2Yu")
+	assert.NoError(t, err)
+	assert.Equal(t, "Shop", s.Name)
+}
+
+func TestAlertGroupsApp(t *testing.T) {
+	client := &fakeClient{settings: []*alertGroupSetting{
+		{ID: "4Xb1vLdE2Yt", Name: "Blog", ServiceScopes: []string{"Blog"}, RoleScopes: []string{"Shop: db"}, NotificationInterval: 60},
+		{ID: "4Xb1vLdE2Yu", Name: "Monitors", MonitorScopes: []string{"2cSZzK3XfmG"}},
+	}}
+	out := new(bytes.Buffer)
+	app := &alertGroupsApp{client: client, logger: &testLogger{out}, outStream: out}
+
+	assert.NoError(t, app.list(&format.Output{Format: format.OutputTable}))
+	assert.Equal(t, `ID           NAME      SCOPE                NOTIFICATION_INTERVAL
+4Xb1vLdE2Yt  Blog      Blog,Shop: db        60m
+4Xb1vLdE2Yu  Monitors  monitor:2cSZzK3XfmG  -
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, app.create(&alertGroupSetting{Name: "Shop", ServiceScopes: []string{"Shop"}}))
+	assert.EqualError(t, app.create(&alertGroupSetting{Name: "Shop", RoleScopes: []string{"db"}}), "the role scope of Shop should be <service>:<role>: db")
+	assert.NoError(t, app.update("4Xb1vLdE2Yu", func(s *alertGroupSetting) { s.Memo = "monitors" }))
+	assert.EqualError(t, app.update("unknown", func(*alertGroupSetting) {}), "alert group setting not found: unknown")
+	assert.NoError(t, app.delete("4Xb1vLdE2Yt"))
+	assert.Equal(t, []string{
+		"create {ID: Name:Shop Memo: ServiceScopes:[Shop] RoleScopes:[] MonitorScopes:[] NotificationInterval:0}",
+		"update 4Xb1vLdE2Yu {ID: Name:Monitors Memo:monitors ServiceScopes:[] RoleScopes:[] MonitorScopes:[2cSZzK3XfmG] NotificationInterval:0}",
+		"delete 4Xb1vLdE2Yt",
+	}, client.requests)
+	assert.Equal(t, `created Shop (new)
+updated Monitors (4Xb1vLdE2Yu)
+deleted deleted (4Xb1vLdE2Yt)
+`, out.String())
+}
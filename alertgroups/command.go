@@ -0,0 +1,221 @@
+package alertgroups
+
+import (
+	"os"
+	"strings"
+
+	"github.com/Songmu/prompter"
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+var alertGroupSettingFlags = []cli.Flag{
+	cli.StringFlag{Name: "name", Value: "", Usage: "The name of the alert group setting"},
+	cli.StringFlag{Name: "memo", Value: "", Usage: "The memo of the alert group setting"},
+	cli.StringSliceFlag{
+		Name:  "service, s",
+		Value: &cli.StringSlice{},
+		Usage: "The service whose alerts are grouped. Multiple choices are allowed",
+	},
+	cli.StringSliceFlag{
+		Name:  "role, r",
+		Value: &cli.StringSlice{},
+		Usage: "The role whose alerts are grouped in the form of <service>:<role>. Multiple choices are allowed",
+	},
+	cli.StringSliceFlag{
+		Name:  "monitor",
+		Value: &cli.StringSlice{},
+		Usage: "The ID of the monitor whose alerts are grouped. Multiple choices are allowed",
+	},
+	cli.IntFlag{Name: "notification-interval", Value: 0, Usage: "The interval in minutes to notify the alert group again. 0 means no re-notification"},
+}
+
+var alertGroupsListFlags = []cli.Flag{
+	cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+}
+
+// Command is the definition of alert-groups subcommand
+var Command = cli.Command{
+	Name:      "alert-groups",
+	Usage:     "List alert group settings",
+	ArgsUsage: "[--output | -o <format>]",
+	Description: `
+    List the alert group settings. With a subcommand, manipulate the alert group settings.
+    Requests APIs under "/api/v0/alert-group-settings". See https://mackerel.io/api-docs/entry/alert-group-settings .
+`,
+	Action: doAlertGroups,
+	Flags:  alertGroupsListFlags,
+	Subcommands: []cli.Command{
+		{
+			Name:      "list",
+			Usage:     "List alert group settings",
+			ArgsUsage: "[--output | -o <format>]",
+			Description: `
+    List the alert group settings.
+`,
+			Action: doAlertGroups,
+			Flags:  alertGroupsListFlags,
+		},
+		{
+			Name:      "create",
+			Usage:     "Create an alert group setting",
+			ArgsUsage: "--name <name> [--service | -s <service>] [--role | -r <service>:<role>] [--monitor <monitorId>] [--notification-interval <minutes>] [--memo <memo>]",
+			Description: `
+    Create a new alert group setting.
+    Requests "POST /api/v0/alert-group-settings". See https://mackerel.io/api-docs/entry/alert-group-settings#create.
+`,
+			Action: doCreateAlertGroup,
+			Flags:  alertGroupSettingFlags,
+		},
+		{
+			Name:      "update",
+			Usage:     "Update an alert group setting",
+			ArgsUsage: "--id <id> [--name <name>] [--service | -s <service>] [--role | -r <service>:<role>] [--monitor <monitorId>] [--notification-interval <minutes>] [--memo <memo>]",
+			Description: `
+    Update the alert group setting. Only the specified fields are changed, and the scopes are replaced if specified.
+    Requests "PUT /api/v0/alert-group-settings/<alertGroupSettingId>". See https://mackerel.io/api-docs/entry/alert-group-settings#update.
+`,
+			Action: doUpdateAlertGroup,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: "id", Value: "", Usage: "The ID of the alert group setting"},
+			}, alertGroupSettingFlags...),
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete an alert group setting",
+			ArgsUsage: "[--force] <alertGroupSettingId>",
+			Description: `
+    Delete the alert group setting.
+    Requests "DELETE /api/v0/alert-group-settings/<alertGroupSettingId>". See https://mackerel.io/api-docs/entry/alert-group-settings#delete.
+`,
+			Action: doDeleteAlertGroup,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "force", Usage: "Delete the alert group setting without confirmation"},
+			},
+		},
+		{
+			Name:      "pull",
+			Usage:     "Pull alert group settings",
+			ArgsUsage: "[--file-path | -F <file>]",
+			Description: `
+    Save the alert group settings to the JSON file, which can be pushed by "mkr alert-groups push".
+`,
+			Action: doPullAlertGroups,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "alert-group-settings.json", Usage: "The JSON file to save the alert group settings"},
+			},
+		},
+		{
+			Name:      "push",
+			Usage:     "Push alert group settings",
+			ArgsUsage: "[--file-path | -F <file>] [--dry-run | -d] [--prune]",
+			Description: `
+    Create or update the alert group settings in the JSON file. The settings are matched by "id", or by "name" if they have no id.
+    With --prune, the alert group settings which do not exist in the file are deleted.
+`,
+			Action: doPushAlertGroups,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "alert-group-settings.json", Usage: "The JSON file of the alert group settings"},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show the changes, but not apply them"},
+				cli.BoolFlag{Name: "prune", Usage: "Delete the alert group settings which do not exist in the file"},
+			},
+		},
+	},
+}
+
+func newAlertGroupsApp(c *cli.Context) *alertGroupsApp {
+	return &alertGroupsApp{
+		client:    &apiClient{mackerelclient.NewFromContext(c)},
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}
+}
+
+func doAlertGroups(c *cli.Context) error {
+	output, err := format.OutputFromContext(c, "table")
+	if err != nil {
+		return err
+	}
+	return newAlertGroupsApp(c).list(output)
+}
+
+// applyFlags sets the fields of the setting by the flags. Only the specified flags are applied if update is true.
+func applyFlags(c *cli.Context, s *alertGroupSetting, update bool) {
+	isSet := func(name string) bool {
+		return !update || c.IsSet(name)
+	}
+	if isSet("name") {
+		s.Name = c.String("name")
+	}
+	if isSet("memo") {
+		s.Memo = c.String("memo")
+	}
+	if isSet("service") {
+		s.ServiceScopes = c.StringSlice("service")
+	}
+	if isSet("role") {
+		s.RoleScopes = nil
+		for _, r := range c.StringSlice("role") {
+			// the API represents the role scopes like "service: role"
+			if kv := strings.SplitN(r, ":", 2); len(kv) == 2 {
+				r = strings.TrimSpace(kv[0]) + ": " + strings.TrimSpace(kv[1])
+			}
+			s.RoleScopes = append(s.RoleScopes, r)
+		}
+	}
+	if isSet("monitor") {
+		s.MonitorScopes = c.StringSlice("monitor")
+	}
+	if isSet("notification-interval") {
+		s.NotificationInterval = int64(c.Int("notification-interval"))
+	}
+}
+
+func doCreateAlertGroup(c *cli.Context) error {
+	if c.String("name") == "" {
+		cli.ShowCommandHelp(c, "create")
+		os.Exit(1)
+	}
+	s := &alertGroupSetting{}
+	applyFlags(c, s, false)
+	return newAlertGroupsApp(c).create(s)
+}
+
+func doUpdateAlertGroup(c *cli.Context) error {
+	id := c.String("id")
+	if id == "" {
+		cli.ShowCommandHelp(c, "update")
+		os.Exit(1)
+	}
+	return newAlertGroupsApp(c).update(id, func(s *alertGroupSetting) {
+		applyFlags(c, s, true)
+	})
+}
+
+func doDeleteAlertGroup(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelp(c, "delete")
+		os.Exit(1)
+	}
+	id := c.Args().Get(0)
+	if !c.Bool("force") && !prompter.YN("Delete the alert group setting "+id+".\nAre you sure?", false) {
+		logger.Log("", "deletion is canceled.")
+		return nil
+	}
+	return newAlertGroupsApp(c).delete(id)
+}
+
+func doPullAlertGroups(c *cli.Context) error {
+	return newAlertGroupsApp(c).pull(c.String("file-path"))
+}
+
+func doPushAlertGroups(c *cli.Context) error {
+	return newAlertGroupsApp(c).push(pushAlertGroupSettingsParam{
+		filePath: c.String("file-path"),
+		dryRun:   c.Bool("dry-run"),
+		prune:    c.Bool("prune"),
+	})
+}
@@ -0,0 +1,110 @@
+package alertgroups
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/resourcesync"
+)
+
+type alertGroupSettingsFile struct {
+	AlertGroupSettings []*alertGroupSetting `json:"alertGroupSettings"`
+}
+
+func (app *alertGroupsApp) pull(filePath string) error {
+	settings, err := app.client.FindAlertGroupSettings()
+	if err != nil {
+		return err
+	}
+	data := format.JSONMarshalIndent(alertGroupSettingsFile{AlertGroupSettings: settings}, "", "    ") + "\n"
+	if err := ioutil.WriteFile(filePath, []byte(data), 0644); err != nil {
+		return err
+	}
+	app.logger.Log("info", fmt.Sprintf("Alert group settings are saved to '%s' (%d settings).", filePath, len(settings)))
+	return nil
+}
+
+func loadAlertGroupSettings(filePath string) ([]*alertGroupSetting, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var file alertGroupSettingsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	for _, s := range file.AlertGroupSettings {
+		if err := validateAlertGroupSetting(s); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+		}
+	}
+	if err := resourcesync.CheckResources("alert group setting", alertGroupSettingResources(file.AlertGroupSettings)); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	return file.AlertGroupSettings, nil
+}
+
+func alertGroupSettingResources(settings []*alertGroupSetting) []resourcesync.Resource {
+	resources := make([]resourcesync.Resource, len(settings))
+	for i, s := range settings {
+		resources[i] = resourcesync.Resource{ID: s.ID, Name: s.Name, Value: s}
+	}
+	return resources
+}
+
+// planAlertGroupSettings returns the changes to make the current settings the desired ones.
+// The desired settings are matched by the id, or by the name if they have no id.
+// The settings which do not exist in the desired ones are deleted only if prune is true.
+func planAlertGroupSettings(current, desired []*alertGroupSetting, prune bool) ([]*resourcesync.ResourceChange, error) {
+	return resourcesync.Plan("alert group setting", alertGroupSettingResources(current), alertGroupSettingResources(desired), prune, func(x, y interface{}) bool {
+		return sameAlertGroupSetting(x.(*alertGroupSetting), y.(*alertGroupSetting))
+	})
+}
+
+// sameAlertGroupSetting compares the settings except for their ids.
+func sameAlertGroupSetting(x, y *alertGroupSetting) bool {
+	xx, yy := *x, *y
+	xx.ID, yy.ID = "", ""
+	a, _ := json.Marshal(xx)
+	b, _ := json.Marshal(yy)
+	return bytes.Equal(a, b)
+}
+
+type pushAlertGroupSettingsParam struct {
+	filePath string
+	dryRun   bool
+	prune    bool
+}
+
+func (app *alertGroupsApp) push(param pushAlertGroupSettingsParam) error {
+	desired, err := loadAlertGroupSettings(param.filePath)
+	if err != nil {
+		return err
+	}
+	current, err := app.client.FindAlertGroupSettings()
+	if err != nil {
+		return err
+	}
+	changes, err := planAlertGroupSettings(current, desired, param.prune)
+	if err != nil {
+		return err
+	}
+	return resourcesync.Apply(app.outStream, app.logger, "alert group settings", resourcesync.Changes(changes), param.dryRun, func(c resourcesync.Change) error {
+		rc := c.(*resourcesync.ResourceChange)
+		s := *rc.Value.(*alertGroupSetting)
+		s.ID = ""
+		var err error
+		switch rc.Action() {
+		case "create":
+			_, err = app.client.CreateAlertGroupSetting(&s)
+		case "update":
+			_, err = app.client.UpdateAlertGroupSetting(rc.ID, &s)
+		case "delete":
+			_, err = app.client.DeleteAlertGroupSetting(rc.ID)
+		}
+		return err
+	})
+}
@@ -0,0 +1,89 @@
+package alertgroups
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mkr/resourcesync"
+)
+
+func TestPlanAlertGroupSettings(t *testing.T) {
+	current := []*alertGroupSetting{
+		{ID: "a", Name: "Blog", ServiceScopes: []string{"Blog"}},
+		{ID: "b", Name: "Shop", ServiceScopes: []string{"Shop"}},
+		{ID: "c", Name: "obsolete", MonitorScopes: []string{"x"}},
+	}
+	desired := []*alertGroupSetting{
+		{ID: "a", Name: "Blog", ServiceScopes: []string{"Blog"}},
+		{Name: "Shop", ServiceScopes: []string{"Shop"}, NotificationInterval: 30},
+		{Name: "new", RoleScopes: []string{"Blog: db"}},
+	}
+	describe := func(changes []*resourcesync.ResourceChange) []string {
+		var xs []string
+		for _, c := range changes {
+			xs = append(xs, c.Action()+" "+c.Target())
+		}
+		return xs
+	}
+
+	changes, err := planAlertGroupSettings(current, desired, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update 'Shop' (b)", "create 'new'"}, describe(changes))
+
+	changes, err = planAlertGroupSettings(current, desired, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update 'Shop' (b)", "create 'new'", "delete 'obsolete' (c)"}, describe(changes))
+
+	_, err = planAlertGroupSettings(current, []*alertGroupSetting{{ID: "z", Name: "unknown"}}, false)
+	assert.EqualError(t, err, "alert group setting not found: z")
+}
+
+func TestAlertGroupsApp_PullPush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-alert-groups")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "alert-group-settings.json")
+
+	client := &fakeClient{settings: []*alertGroupSetting{{ID: "a", Name: "Blog", ServiceScopes: []string{"Blog"}}}}
+	out := new(bytes.Buffer)
+	app := &alertGroupsApp{client: client, logger: &testLogger{out}, outStream: out}
+
+	assert.NoError(t, app.pull(filePath))
+	data, err := ioutil.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+    "alertGroupSettings": [
+        {
+            "id": "a",
+            "name": "Blog",
+            "serviceScopes": [
+                "Blog"
+            ]
+        }
+    ]
+}
+`, string(data))
+
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(`{"alertGroupSettings": [
+		{"id": "a", "name": "Blog", "serviceScopes": ["Blog"], "notificationInterval": 60},
+		{"name": "Shop", "serviceScopes": ["Shop"]}
+	]}`), 0644))
+	out.Reset()
+	assert.NoError(t, app.push(pushAlertGroupSettingsParam{filePath: filePath, dryRun: true, prune: true}))
+	assert.Equal(t, "update 'Blog' (a)\ncreate 'Shop'\n", out.String())
+	assert.Empty(t, client.requests)
+
+	out.Reset()
+	assert.NoError(t, app.push(pushAlertGroupSettingsParam{filePath: filePath}))
+	assert.Equal(t, "updated 'Blog' (a)\ncreated 'Shop'\n", out.String())
+	assert.Len(t, client.requests, 2)
+
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(`{"alertGroupSettings": [{"name": "Shop", "roleScopes": ["db"]}]}`), 0644))
+	assert.EqualError(t, app.push(pushAlertGroupSettingsParam{filePath: filePath}),
+		"failed to load "+filePath+": the role scope of Shop should be <service>:<role>: db")
+}
@@ -1,14 +1,22 @@
 package main
 
 import (
+	"bytes"
 	"errors"
 	"fmt"
+	"io"
+	"io/ioutil"
 	"math"
 	"os"
 	"regexp"
+	"strconv"
 	"strings"
+	"sync"
+	"text/tabwriter"
+	"text/template"
 	"time"
 
+	"github.com/Songmu/prompter"
 	"github.com/fatih/color"
 	"github.com/mackerelio/mackerel-client-go"
 	"github.com/mackerelio/mkr/format"
@@ -20,26 +28,29 @@ import (
 var commandAlerts = cli.Command{
 	Name:      "alerts",
 	Usage:     "Retrieve/Close alerts",
-	ArgsUsage: "[--with-closed | -w] [--limit | -l]",
+	ArgsUsage: "[--with-closed | -w] [--limit | -l] [--monitor-id <monitorId>] [--host-id <hostId>] [--status <status>] [--since <time>] [--until <time>] [--watch [--interval <duration>]]",
 	Description: `
     Retrieve/Close alerts. With no subcommand specified, this will show all alerts.
+    With --watch, the output is cleared and re-rendered on every --interval.
     Requests APIs under "/api/v0/alerts". See https://mackerel.io/api-docs/entry/alerts .
 `,
 	Action: doAlertsRetrieve,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.BoolFlag{Name: "with-closed, w", Usage: "Display open alert including close alert. default: false"},
 		cli.IntFlag{Name: "limit, l", Value: defaultAlertsLimit, Usage: fmt.Sprintf("Set the number of alerts to display. Default is set to %d when -with-closed is set, otherwise all the open alerts are displayed.", defaultAlertsLimit)},
-	},
+	}, append(alertsFilterFlags, format.WatchFlags...)...),
 	Subcommands: []cli.Command{
 		{
 			Name:      "list",
 			Usage:     "list alerts",
-			ArgsUsage: "[--service | -s <service>] [--host-status | -S <file>] [--color | -c] [--with-closed | -w] [--limit | -l]",
+			ArgsUsage: "[--service | -s <service>] [--host-status | -S <file>] [--monitor-id <monitorId>] [--host-id <hostId>] [--status <status>] [--since <time>] [--until <time>] [--output | -o text|table|json] [--color | -c] [--with-closed | -w] [--limit | -l] [--watch [--interval <duration>]]",
 			Description: `
     Shows alerts in human-readable format.
+    The time of --since and --until is a duration before now such as '30m' or '7d', RFC3339 or epoch seconds.
+    With --watch, the output is cleared and re-rendered on every --interval.
 `,
 			Action: doAlertsList,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.StringSliceFlag{
 					Name:  "service, s",
 					Value: &cli.StringSlice{},
@@ -50,29 +61,224 @@ var commandAlerts = cli.Command{
 					Value: &cli.StringSlice{},
 					Usage: "Filters alerts by status of each host. Multiple choices are allowed.",
 				},
+				cli.StringFlag{Name: "output, o", Value: "text", Usage: "Output format: 'text', 'table' or 'json'"},
 				cli.BoolTFlag{Name: "color, c", Usage: "Colorize output. default: true"},
 				cli.BoolFlag{Name: "with-closed, w", Usage: "Display open alert including close alert. default: false"},
 				cli.IntFlag{Name: "limit, l", Value: defaultAlertsLimit, Usage: fmt.Sprintf("Set the number of alerts to display. Default is set to %d when -with-closed is set, otherwise all the open alerts are displayed.", defaultAlertsLimit)},
-			},
+			}, append(alertsFilterFlags, format.WatchFlags...)...),
 		},
 		{
 			Name:      "close",
 			Usage:     "close alerts",
-			ArgsUsage: "<alertIds....>",
+			ArgsUsage: "[--monitor-id <monitorId>] [--status <status>] [--before <duration>] [--reason | -r <reason> | --reason-template <template> | --reason-file <file>] [--parallel <num>] [--dry-run] [--force] [<alertIds....>]",
 			Description: `
     Closes alerts. Multiple alert IDs can be specified.
+    The open alerts can also be selected by --monitor-id, --status and --before, which selects the alerts opened before the duration such as '2h'.
+    The selected alerts are closed after confirmation unless --force is specified.
+    The reason can be a template of text/template, which is given the alert (e.g. 'maintenance of {{.HostID}}').
 `,
 			Action: doAlertsClose,
 			Flags: []cli.Flag{
 				cli.StringFlag{Name: "reason, r", Value: "", Usage: "Reason of closing alert."},
+				cli.StringFlag{Name: "reason-template", Value: "", Usage: "Template of the reason of closing alert."},
+				cli.StringFlag{Name: "reason-file", Value: "", Usage: "File of the template of the reason of closing alert."},
+				cli.StringSliceFlag{Name: "monitor-id", Value: &cli.StringSlice{}, Usage: "Closes the open alerts of the monitor. Multiple choices are allowed."},
+				cli.StringSliceFlag{Name: "status", Value: &cli.StringSlice{}, Usage: "Closes the open alerts of the status. Multiple choices are allowed."},
+				cli.StringFlag{Name: "before", Value: "", Usage: "Closes the open alerts opened before the duration such as '30m' or '7d'."},
+				cli.IntFlag{Name: "parallel", Value: 4, Usage: "Number of the alerts closed concurrently."},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show the alerts to be closed, but not close them."},
+				cli.BoolFlag{Name: "force", Usage: "Close the selected alerts without confirmation."},
+				cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
+			},
+		},
+		{
+			Name:      "watch",
+			Usage:     "watch alerts",
+			ArgsUsage: "[--interval <duration>] [--output | -o text|json] [--webhook <url>]",
+			Description: `
+    Polls the open alerts and prints the alerts opened or closed since the last poll.
+    With --output json, each event is printed as a line of JSON. With --webhook, each event is also posted to the URL as JSON.
+`,
+			Action: doAlertsWatch,
+			Flags: []cli.Flag{
+				cli.DurationFlag{Name: "interval", Value: 30 * time.Second, Usage: "Interval of polling the alerts"},
+				cli.StringFlag{Name: "output, o", Value: "text", Usage: "Output format: 'text' or 'json'"},
+				cli.StringFlag{Name: "webhook", Value: "", Usage: "URL to post the events"},
+			},
+		},
+		{
+			Name:      "logs",
+			Usage:     "show the status transitions of an alert",
+			ArgsUsage: "[--output | -o table|json] <alertId>",
+			Description: `
+    Shows the status transitions of the alert from the oldest.
+    The notification history is not shown because the API does not provide it.
+    Requests "GET /api/v0/alerts/<alertId>/logs".
+`,
+			Action: doAlertsLogs,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "output, o", Value: "table", Usage: "Output format: 'table' or 'json'"},
+			},
+		},
+		{
+			Name:      "stats",
+			Usage:     "aggregate alerts by monitor",
+			ArgsUsage: "[--since <time>] [--top <num>] [--flap-window <duration>] [--output | -o table|json|csv]",
+			Description: `
+    Aggregates the open and closed alerts opened since the time by monitor, and shows the monitors
+    ordered by the number of the alerts with the mean time to close (MTTC) and the number of flaps.
+    An alert is counted as a flap if it is opened within --flap-window after the previous alert of the same monitor and host is closed.
+`,
+			Action: doAlertsStats,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "since", Value: "30d", Usage: "Aggregates alerts opened at or after the time"},
+				cli.IntFlag{Name: "top", Value: 0, Usage: "Shows only the top monitors. default: all"},
+				cli.DurationFlag{Name: "flap-window", Value: time.Hour, Usage: "Window to regard a reopened alert as a flap"},
+				cli.StringFlag{Name: "output, o", Value: "table", Usage: "Output format: 'table', 'json' or 'csv'"},
+			},
+		},
+		{
+			Name:      "ack",
+			Usage:     "acknowledge an alert",
+			ArgsUsage: "[--memo | -m <text>] [--user <name>] [--service | -s <service>] <alertId>",
+			Description: `
+    Acknowledges an open alert by creating a graph annotation at the current time.
+    The annotation is created for the services and the roles of the host of the alert,
+    or the service of the monitor. Specify --service for the other alerts.
+`,
+			Action: doAlertsAck,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "memo, m", Value: "", Usage: "Memo of the acknowledgement"},
+				cli.StringFlag{Name: "user", Value: os.Getenv("USER"), Usage: "Name of the user who acknowledges the alert"},
+				cli.StringFlag{Name: "service, s", Value: "", Usage: "Service to create the annotation"},
+				cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
+			},
+		},
+		{
+			Name:      "snooze",
+			Usage:     "snooze an alert with a downtime",
+			ArgsUsage: "--duration <duration> [--memo | -m <text>] [--user <name>] [--service | -s <service>] <alertId>",
+			Description: `
+    Snoozes an open alert by creating a downtime from now for the duration such as '2h' or '1d'.
+    The downtime is scoped to the monitor of the alert, and the roles of the host or the service of the monitor.
+    A graph annotation of the period of the downtime is also created as "mkr alerts ack" does.
+    Requests "POST /api/v0/downtimes" and "POST /api/v0/graph-annotations".
+`,
+			Action: doAlertsSnooze,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "duration", Value: "", Usage: "Duration of the snooze such as '30m', '2h' or '1d'"},
+				cli.StringFlag{Name: "memo, m", Value: "", Usage: "Memo of the snooze"},
+				cli.StringFlag{Name: "user", Value: os.Getenv("USER"), Usage: "Name of the user who snoozes the alert"},
+				cli.StringFlag{Name: "service, s", Value: "", Usage: "Service to create the annotation"},
 				cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
 			},
 		},
 	},
 }
 
+var alertsFilterFlags = []cli.Flag{
+	cli.StringSliceFlag{Name: "monitor-id", Value: &cli.StringSlice{}, Usage: "Filters alerts by monitor ID. Multiple choices are allowed."},
+	cli.StringSliceFlag{Name: "host-id", Value: &cli.StringSlice{}, Usage: "Filters alerts by host ID. Multiple choices are allowed."},
+	cli.StringSliceFlag{Name: "host-name", Value: &cli.StringSlice{}, Usage: "Filters alerts by host name. Multiple choices are allowed."},
+	cli.StringSliceFlag{Name: "status", Value: &cli.StringSlice{}, Usage: "Filters alerts by status: CRITICAL, WARNING, UNKNOWN or OK. Multiple choices are allowed."},
+	cli.StringFlag{Name: "since", Value: "", Usage: "Filters alerts opened at or after the time"},
+	cli.StringFlag{Name: "until", Value: "", Usage: "Filters alerts opened at or before the time"},
+}
+
 const defaultAlertsLimit int = 100
 
+// alertFilter selects alerts by their own fields. The empty conditions match any alerts.
+type alertFilter struct {
+	monitorIDs []string
+	hostIDs    []string
+	statuses   []string
+	since      time.Time
+	until      time.Time
+}
+
+func newAlertFilter(c *cli.Context, now time.Time) (*alertFilter, error) {
+	f := &alertFilter{
+		monitorIDs: c.StringSlice("monitor-id"),
+		hostIDs:    c.StringSlice("host-id"),
+	}
+	for _, status := range c.StringSlice("status") {
+		status = strings.ToUpper(status)
+		switch status {
+		case "CRITICAL", "WARNING", "UNKNOWN", "OK":
+		default:
+			return nil, fmt.Errorf("status should be CRITICAL, WARNING, UNKNOWN or OK: %s", status)
+		}
+		f.statuses = append(f.statuses, status)
+	}
+	var err error
+	if since := c.String("since"); since != "" {
+		if f.since, err = parseAlertTime(since, now); err != nil {
+			return nil, err
+		}
+	}
+	if until := c.String("until"); until != "" {
+		if f.until, err = parseAlertTime(until, now); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// parseAlertTime parses a duration before now such as "30m", "-7d", "now", RFC3339 or epoch seconds.
+func parseAlertTime(s string, now time.Time) (time.Time, error) {
+	if s == "now" {
+		return now, nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	if epoch, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return time.Unix(epoch, 0), nil
+	}
+	if seconds, err := parseWidgetPeriod(strings.TrimPrefix(s, "-")); err == nil {
+		return now.Add(-time.Duration(seconds) * time.Second), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid time: %s", s)
+}
+
+func containsString(xs []string, x string) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+func (f *alertFilter) match(alert *mackerel.Alert) bool {
+	if len(f.monitorIDs) > 0 && !containsString(f.monitorIDs, alert.MonitorID) {
+		return false
+	}
+	if len(f.hostIDs) > 0 && !containsString(f.hostIDs, alert.HostID) {
+		return false
+	}
+	if len(f.statuses) > 0 && !containsString(f.statuses, alert.Status) {
+		return false
+	}
+	if !f.since.IsZero() && alert.OpenedAt < f.since.Unix() {
+		return false
+	}
+	if !f.until.IsZero() && alert.OpenedAt > f.until.Unix() {
+		return false
+	}
+	return true
+}
+
+func filterAlerts(alerts []*mackerel.Alert, f *alertFilter) []*mackerel.Alert {
+	filtered := make([]*mackerel.Alert, 0, len(alerts))
+	for _, alert := range alerts {
+		if f.match(alert) {
+			filtered = append(filtered, alert)
+		}
+	}
+	return filtered
+}
+
 type alertSet struct {
 	Alert   *mackerel.Alert
 	Host    *mackerel.Host
@@ -246,24 +452,81 @@ func formatCheckMessage(msg string) string {
 	return msg
 }
 
+func printAlertsTable(w io.Writer, alertSets []*alertSet) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tSTATUS\tOPENED_AT\tTYPE\tMONITOR\tHOST\tVALUE")
+	for _, as := range alertSets {
+		var monitorName, hostName string
+		if as.Monitor != nil {
+			monitorName = as.Monitor.MonitorName()
+		}
+		if as.Host != nil {
+			hostName = as.Host.Name
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%.2f\n", as.Alert.ID, as.Alert.Status,
+			format.ISO8601Extended(time.Unix(as.Alert.OpenedAt, 0)), as.Alert.Type, monitorName, hostName, as.Alert.Value)
+	}
+	return tw.Flush()
+}
+
 func doAlertsRetrieve(c *cli.Context) error {
+	if _, err := newAlertFilter(c, time.Now()); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 	client := mackerelclient.NewFromContext(c)
+	hostIDs, err := mackerelclient.HostIDsFromContext(c, client, "host-id")
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 	withClosed := c.Bool("with-closed")
-	alerts, err := fetchAlerts(client, withClosed, getAlertsLimit(c, withClosed))
-	logger.DieIf(err)
-	format.PrettyPrintJSON(os.Stdout, alerts)
-	return nil
+	return format.WatchFromContext(c, os.Stdout, func(w io.Writer) error {
+		// the filter is created in every rendering since --since and --until can be relative to now
+		filter, err := newAlertFilter(c, time.Now())
+		if err != nil {
+			return err
+		}
+		filter.hostIDs = hostIDs
+		alerts, err := fetchAlerts(client, withClosed, getAlertsLimit(c, withClosed))
+		if err != nil {
+			return err
+		}
+		return format.PrettyPrintJSON(w, filterAlerts(alerts, filter))
+	})
 }
 
 func doAlertsList(c *cli.Context) error {
 	filterServices := c.StringSlice("service")
 	filterStatuses := c.StringSlice("host-status")
+	output := format.OutputName(c, "text", "text", "table", "json")
+	if output != "text" && output != "table" && output != "json" {
+		return cli.NewExitError(fmt.Sprintf("output should be 'text', 'table' or 'json': %s", output), 1)
+	}
+	if _, err := newAlertFilter(c, time.Now()); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 	client := mackerelclient.NewFromContext(c)
+	hostIDs, err := mackerelclient.HostIDsFromContext(c, client, "host-id")
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 	withClosed := c.Bool("with-closed")
-	alerts, err := fetchAlerts(client, withClosed, getAlertsLimit(c, withClosed))
-	logger.DieIf(err)
+	return format.WatchFromContext(c, color.Output, func(w io.Writer) error {
+		filter, err := newAlertFilter(c, time.Now())
+		if err != nil {
+			return err
+		}
+		filter.hostIDs = hostIDs
+		alerts, err := fetchAlerts(client, withClosed, getAlertsLimit(c, withClosed))
+		if err != nil {
+			return err
+		}
+		return printAlertsList(w, selectAlertSets(joinMonitorsAndHosts(client, filterAlerts(alerts, filter)), filterServices, filterStatuses), output, c.BoolT("color"))
+	})
+}
 
-	joinedAlerts := joinMonitorsAndHosts(client, alerts)
+// selectAlertSets selects the alerts by the services and the statuses of the hosts
+func selectAlertSets(joinedAlerts []*alertSet, filterServices, filterStatuses []string) []*alertSet {
+	var filtered []*alertSet
 	for _, joinAlert := range joinedAlerts {
 		if len(filterServices) > 0 {
 			found := false
@@ -297,7 +560,24 @@ func doAlertsList(c *cli.Context) error {
 				continue
 			}
 		}
-		fmt.Fprintln(color.Output, formatJoinedAlert(joinAlert, c.BoolT("color")))
+		filtered = append(filtered, joinAlert)
+	}
+	return filtered
+}
+
+func printAlertsList(w io.Writer, filtered []*alertSet, output string, withColor bool) error {
+	switch output {
+	case "table":
+		return printAlertsTable(w, filtered)
+	case "json":
+		alerts := make([]*mackerel.Alert, 0, len(filtered))
+		for _, as := range filtered {
+			alerts = append(alerts, as.Alert)
+		}
+		return format.PrettyPrintJSON(w, alerts)
+	}
+	for _, joinAlert := range filtered {
+		fmt.Fprintln(w, formatJoinedAlert(joinAlert, withColor))
 	}
 	return nil
 }
@@ -368,25 +648,157 @@ func fetchAlerts(client *mackerel.Client, withClosed bool, limit int) ([]*macker
 	return resp.Alerts, nil
 }
 
+// alertCloseReason returns the function to render the reason of closing an alert.
+func alertCloseReason(c *cli.Context) (func(*mackerel.Alert) (string, error), error) {
+	reason := c.String("reason")
+	tmpl := c.String("reason-template")
+	if file := c.String("reason-file"); file != "" {
+		data, err := ioutil.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+		tmpl = strings.TrimRight(string(data), "\n")
+	}
+	if tmpl != "" && reason != "" {
+		return nil, errors.New("--reason cannot be used with --reason-template or --reason-file")
+	}
+	return newAlertReasonFunc(reason, tmpl)
+}
+
+func newAlertReasonFunc(reason, tmpl string) (func(*mackerel.Alert) (string, error), error) {
+	if tmpl == "" {
+		return func(*mackerel.Alert) (string, error) { return reason, nil }, nil
+	}
+	t, err := template.New("reason").Option("missingkey=error").Parse(tmpl)
+	if err != nil {
+		return nil, err
+	}
+	return func(alert *mackerel.Alert) (string, error) {
+		var buf bytes.Buffer
+		if err := t.Execute(&buf, alert); err != nil {
+			return "", err
+		}
+		return buf.String(), nil
+	}, nil
+}
+
+// selectAlertsToClose returns the alerts of the IDs and the open alerts matching the filter.
+// The filter is not used if it is nil.
+func selectAlertsToClose(openAlerts []*mackerel.Alert, ids []string, filter *alertFilter) []*mackerel.Alert {
+	byID := map[string]*mackerel.Alert{}
+	for _, alert := range openAlerts {
+		byID[alert.ID] = alert
+	}
+	var selected []*mackerel.Alert
+	seen := map[string]bool{}
+	for _, id := range ids {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		alert, ok := byID[id]
+		if !ok {
+			alert = &mackerel.Alert{ID: id}
+		}
+		selected = append(selected, alert)
+	}
+	if filter != nil {
+		for _, alert := range filterAlerts(openAlerts, filter) {
+			if !seen[alert.ID] {
+				seen[alert.ID] = true
+				selected = append(selected, alert)
+			}
+		}
+	}
+	return selected
+}
+
 func doAlertsClose(c *cli.Context) error {
 	isVerbose := c.Bool("verbose")
 	argAlertIDs := c.Args()
-	reason := c.String("reason")
 
-	if len(argAlertIDs) < 1 {
-		cli.ShowCommandHelp(c, "alerts")
+	var filter *alertFilter
+	if c.IsSet("monitor-id") || c.IsSet("status") || c.IsSet("before") {
+		var err error
+		if filter, err = newAlertFilter(c, time.Now()); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		if before := c.String("before"); before != "" {
+			if filter.until, err = parseAlertTime(before, time.Now()); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
+		}
+	}
+	if len(argAlertIDs) < 1 && filter == nil {
+		cli.ShowCommandHelp(c, "close")
 		os.Exit(1)
 	}
+	reasonFor, err := alertCloseReason(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 
 	client := mackerelclient.NewFromContext(c)
-	for _, alertID := range argAlertIDs {
-		alert, err := client.CloseAlert(alertID, reason)
+	// the open alerts are needed only to select them by the filter or to render the reason template
+	var openAlerts []*mackerel.Alert
+	if filter != nil || c.String("reason-template") != "" || c.String("reason-file") != "" {
+		openAlerts, err = fetchAlerts(client, false, math.MaxInt32)
 		logger.DieIf(err)
+	}
+	alerts := selectAlertsToClose(openAlerts, argAlertIDs, filter)
 
-		logger.Log("Alert closed", alertID)
-		if isVerbose == true {
-			format.PrettyPrintJSON(os.Stdout, alert)
+	reasons := make([]string, len(alerts))
+	for i, alert := range alerts {
+		if reasons[i], err = reasonFor(alert); err != nil {
+			return cli.NewExitError(fmt.Sprintf("failed to render the reason of %s: %s", alert.ID, err), 1)
 		}
 	}
-	return nil
+	if c.Bool("dry-run") {
+		for i, alert := range alerts {
+			logger.Log("info", fmt.Sprintf("Alert %s will be closed: %s", alert.ID, reasons[i]))
+		}
+		return nil
+	}
+	if filter != nil {
+		if len(alerts) == 0 {
+			logger.Log("", "no alerts are selected.")
+			return nil
+		}
+		if !c.Bool("force") && !prompter.YN(fmt.Sprintf("Close %d alert(s).\nAre you sure?", len(alerts)), false) {
+			logger.Log("", "closing is canceled.")
+			return nil
+		}
+	}
+
+	parallel := c.Int("parallel")
+	if parallel < 1 {
+		parallel = 1
+	}
+	var (
+		wg    sync.WaitGroup
+		mu    sync.Mutex
+		sem   = make(chan struct{}, parallel)
+		count int
+	)
+	for i, alert := range alerts {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(alertID, reason string) {
+			defer func() { <-sem; wg.Done() }()
+			closed, err := client.CloseAlert(alertID, reason)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				logger.Log("error", fmt.Sprintf("failed to close %s: %s", alertID, err))
+				return
+			}
+			count++
+			logger.Log("Alert closed", alertID)
+			if isVerbose == true {
+				format.PrettyPrintJSON(os.Stdout, closed)
+			}
+		}(alert.ID, reasons[i])
+	}
+	wg.Wait()
+	return batchError("close", len(alerts)-count, len(alerts), "alert(s)")
 }
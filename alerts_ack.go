@@ -0,0 +1,134 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// alertAckAnnotations returns the graph annotations to acknowledge the alert.
+// The annotations are created for the services and the roles of the host, or the service of the monitor.
+// service overrides them if it is not empty.
+func alertAckAnnotations(as *alertSet, service, memo, user string, now time.Time) ([]*mackerel.GraphAnnotation, error) {
+	return alertAnnotations(as, service, alertTitle("ack", as), alertMemo(memo, "acknowledged", user), now.Unix(), now.Unix())
+}
+
+// alertTitle returns the title of the alert with the monitor name such as "ack: alert 2tZhm (cpu)".
+func alertTitle(prefix string, as *alertSet) string {
+	title := fmt.Sprintf("%s: alert %s", prefix, as.Alert.ID)
+	if as.Monitor != nil {
+		title += " (" + as.Monitor.MonitorName() + ")"
+	}
+	return title
+}
+
+// alertMemo appends the user who did the action to the memo.
+func alertMemo(memo, action, user string) string {
+	if user == "" {
+		return memo
+	}
+	return strings.TrimSpace(fmt.Sprintf("%s\n%s by %s", memo, action, user))
+}
+
+// alertAnnotations returns the graph annotations of the alert in the same way as alertAckAnnotations.
+func alertAnnotations(as *alertSet, service, title, description string, from, to int64) ([]*mackerel.GraphAnnotation, error) {
+	newAnnotation := func(service string, roles []string) *mackerel.GraphAnnotation {
+		return &mackerel.GraphAnnotation{
+			Title:       title,
+			Description: description,
+			From:        from,
+			To:          to,
+			Service:     service,
+			Roles:       roles,
+		}
+	}
+
+	if service != "" {
+		return []*mackerel.GraphAnnotation{newAnnotation(service, nil)}, nil
+	}
+	if as.Host != nil && len(as.Host.Roles) > 0 {
+		services := make([]string, 0, len(as.Host.Roles))
+		for s := range as.Host.Roles {
+			services = append(services, s)
+		}
+		sort.Strings(services)
+		annotations := make([]*mackerel.GraphAnnotation, 0, len(services))
+		for _, s := range services {
+			annotations = append(annotations, newAnnotation(s, as.Host.Roles[s]))
+		}
+		return annotations, nil
+	}
+	switch m := as.Monitor.(type) {
+	case *mackerel.MonitorServiceMetric:
+		return []*mackerel.GraphAnnotation{newAnnotation(m.Service, nil)}, nil
+	case *mackerel.MonitorExternalHTTP:
+		if m.Service != "" {
+			return []*mackerel.GraphAnnotation{newAnnotation(m.Service, nil)}, nil
+		}
+	}
+	return nil, fmt.Errorf("the service of alert %s is unknown. specify --service", as.Alert.ID)
+}
+
+// findOpenAlertSet finds the open alert with the host and the monitor.
+func findOpenAlertSet(client *mackerel.Client, alertID string) (*alertSet, error) {
+	alerts, err := fetchAlerts(client, false, math.MaxInt32)
+	if err != nil {
+		return nil, err
+	}
+	as := &alertSet{}
+	for _, alert := range alerts {
+		if alert.ID == alertID {
+			as.Alert = alert
+		}
+	}
+	if as.Alert == nil {
+		return nil, fmt.Errorf("open alert not found: %s", alertID)
+	}
+	if as.Alert.HostID != "" {
+		if as.Host, err = client.FindHost(as.Alert.HostID); err != nil {
+			return nil, err
+		}
+	}
+	if as.Alert.MonitorID != "" {
+		if as.Monitor, err = client.GetMonitor(as.Alert.MonitorID); err != nil {
+			return nil, err
+		}
+	}
+	return as, nil
+}
+
+func doAlertsAck(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelp(c, "ack")
+		os.Exit(1)
+	}
+	alertID := c.Args().First()
+	client := mackerelclient.NewFromContext(c)
+	as, err := findOpenAlertSet(client, alertID)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	annotations, err := alertAckAnnotations(as, c.String("service"), c.String("memo"), c.String("user"), time.Now())
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	for _, a := range annotations {
+		created, err := client.CreateGraphAnnotation(a)
+		logger.DieIf(err)
+		logger.Log("Alert acknowledged", fmt.Sprintf("%s (annotation %s of %s)", alertID, created.ID, created.Service))
+		if c.Bool("verbose") {
+			format.PrettyPrintJSON(os.Stdout, created)
+		}
+	}
+	return nil
+}
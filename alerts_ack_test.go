@@ -0,0 +1,42 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestAlertAckAnnotations(t *testing.T) {
+	now := time.Unix(1000, 0)
+	host := &mackerel.Host{ID: "3XYyG", Roles: mackerel.Roles{"foo": {"app"}, "bar": {"web", "db"}}}
+	monitor := &mackerel.MonitorHostMetric{ID: "5rXR3", Name: "cpu"}
+	as := &alertSet{&mackerel.Alert{ID: "2tZhm", HostID: "3XYyG", MonitorID: "5rXR3"}, host, monitor}
+
+	annotations, err := alertAckAnnotations(as, "", "investigating", "alice", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*mackerel.GraphAnnotation{
+		{Title: "ack: alert 2tZhm (cpu)", Description: "investigating\nacknowledged by alice", From: 1000, To: 1000, Service: "bar", Roles: []string{"web", "db"}},
+		{Title: "ack: alert 2tZhm (cpu)", Description: "investigating\nacknowledged by alice", From: 1000, To: 1000, Service: "foo", Roles: []string{"app"}},
+	}
+	if !reflect.DeepEqual(expected, annotations) {
+		t.Errorf("annotations should be %+v but: %+v", expected, annotations)
+	}
+
+	as = &alertSet{&mackerel.Alert{ID: "2tZhm"}, nil, &mackerel.MonitorServiceMetric{Name: "latency", Service: "foo"}}
+	annotations, _ = alertAckAnnotations(as, "", "", "", now)
+	if len(annotations) != 1 || annotations[0].Service != "foo" || annotations[0].Description != "" {
+		t.Errorf("annotation should be created for the service of the monitor but: %+v", annotations)
+	}
+
+	as = &alertSet{&mackerel.Alert{ID: "2tZhm"}, nil, &mackerel.MonitorExpression{Name: "expr"}}
+	if _, err := alertAckAnnotations(as, "", "", "", now); err == nil {
+		t.Errorf("unknown service should be an error")
+	}
+	if annotations, _ := alertAckAnnotations(as, "baz", "", "", now); len(annotations) != 1 || annotations[0].Service != "baz" {
+		t.Errorf("annotation should be created for the specified service but: %+v", annotations)
+	}
+}
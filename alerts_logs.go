@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// alertLog is a status transition of an alert.
+type alertLog struct {
+	ID          string   `json:"id"`
+	CreatedAt   int64    `json:"createdAt"`
+	Status      string   `json:"status"`
+	Trigger     string   `json:"trigger"`
+	MonitorID   string   `json:"monitorId,omitempty"`
+	TargetValue *float64 `json:"targetValue,omitempty"`
+}
+
+type alertLogsResp struct {
+	Logs   []*alertLog `json:"logs"`
+	NextID string      `json:"nextId,omitempty"`
+}
+
+// fetchAlertLogs fetches all the logs of the alert, which are ordered from the newest.
+func fetchAlertLogs(client *mackerel.Client, alertID string) ([]*alertLog, error) {
+	var logs []*alertLog
+	query := url.Values{}
+	for {
+		var resp alertLogsResp
+		if err := mackerelclient.RequestJSON(client, "GET", "/api/v0/alerts/"+alertID+"/logs", query, nil, &resp); err != nil {
+			return nil, err
+		}
+		logs = append(logs, resp.Logs...)
+		if resp.NextID == "" {
+			return logs, nil
+		}
+		query.Set("nextId", resp.NextID)
+	}
+}
+
+func printAlertLogs(w io.Writer, logs []*alertLog) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tSTATUS\tTRIGGER\tVALUE")
+	// print from the oldest to follow the transitions
+	for i := len(logs) - 1; i >= 0; i-- {
+		l := logs[i]
+		value := ""
+		if l.TargetValue != nil {
+			value = fmt.Sprintf("%.2f", *l.TargetValue)
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", format.ISO8601Extended(time.Unix(l.CreatedAt, 0)), l.Status, l.Trigger, value)
+	}
+	return tw.Flush()
+}
+
+func doAlertsLogs(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelp(c, "logs")
+		os.Exit(1)
+	}
+	logs, err := fetchAlertLogs(mackerelclient.NewFromContext(c), c.Args().First())
+	logger.DieIf(err)
+
+	output := format.OutputName(c, "table", "table", "json")
+	switch output {
+	case "json":
+		return format.PrettyPrintJSON(os.Stdout, logs)
+	case "table":
+		return printAlertLogs(os.Stdout, logs)
+	}
+	return cli.NewExitError(fmt.Sprintf("output should be 'table' or 'json': %s", output), 1)
+}
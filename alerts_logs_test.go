@@ -0,0 +1,45 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestFetchAndPrintAlertLogs(t *testing.T) {
+	time.Local = time.UTC
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/alerts/2tZhm/logs" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if r.URL.Query().Get("nextId") == "" {
+			fmt.Fprint(w, `{"logs": [{"id": "3", "createdAt": 300, "status": "OK", "trigger": "monitoring", "targetValue": 5.2}], "nextId": "3"}`)
+		} else {
+			fmt.Fprint(w, `{"logs": [{"id": "2", "createdAt": 200, "status": "CRITICAL", "trigger": "monitoring", "targetValue": 15.7}, {"id": "1", "createdAt": 100, "status": "WARNING", "trigger": "monitoring"}]}`)
+		}
+	}))
+	defer ts.Close()
+
+	client, _ := mackerel.NewClientWithOptions("dummy", ts.URL, false)
+	logs, err := fetchAlertLogs(client, "2tZhm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := printAlertLogs(&buf, logs); err != nil {
+		t.Fatal(err)
+	}
+	want := `TIME                       STATUS    TRIGGER     VALUE
+1970-01-01T00:01:40+00:00  WARNING   monitoring  
+1970-01-01T00:03:20+00:00  CRITICAL  monitoring  15.70
+1970-01-01T00:05:00+00:00  OK        monitoring  5.20
+`
+	if buf.String() != want {
+		t.Errorf("output should be:\n%s\nbut:\n%s", want, buf.String())
+	}
+}
@@ -0,0 +1,90 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// alertSnoozeDowntime returns the downtime to snooze the alert for the duration in seconds.
+// The downtime is scoped to the monitor of the alert, and the roles of the host or the service of the monitor.
+func alertSnoozeDowntime(as *alertSet, duration int64, memo, user string, now time.Time) (*mackerel.Downtime, error) {
+	if as.Monitor == nil {
+		return nil, fmt.Errorf("alert %s has no monitor to snooze", as.Alert.ID)
+	}
+	downtime := &mackerel.Downtime{
+		Name:          alertTitle("snooze", as),
+		Memo:          alertMemo(memo, "snoozed", user),
+		Start:         now.Unix(),
+		Duration:      (duration + 59) / 60, // in minutes
+		MonitorScopes: []string{as.Monitor.MonitorID()},
+	}
+	if as.Host != nil {
+		for service, roles := range as.Host.Roles {
+			for _, role := range roles {
+				// the API represents the role scopes like "service: role"
+				downtime.RoleScopes = append(downtime.RoleScopes, service+": "+role)
+			}
+		}
+		sort.Strings(downtime.RoleScopes)
+		return downtime, nil
+	}
+	switch m := as.Monitor.(type) {
+	case *mackerel.MonitorServiceMetric:
+		downtime.ServiceScopes = []string{m.Service}
+	case *mackerel.MonitorExternalHTTP:
+		if m.Service != "" {
+			downtime.ServiceScopes = []string{m.Service}
+		}
+	}
+	return downtime, nil
+}
+
+func doAlertsSnooze(c *cli.Context) error {
+	if c.NArg() != 1 || c.String("duration") == "" {
+		cli.ShowCommandHelp(c, "snooze")
+		os.Exit(1)
+	}
+	alertID := c.Args().First()
+	duration, err := parseWidgetPeriod(c.String("duration"))
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("invalid duration: %s", c.String("duration")), 1)
+	}
+	client := mackerelclient.NewFromContext(c)
+	as, err := findOpenAlertSet(client, alertID)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	now := time.Now()
+	downtime, err := alertSnoozeDowntime(as, duration, c.String("memo"), c.String("user"), now)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	// the annotation covers the period of the downtime
+	annotations, err := alertAnnotations(as, c.String("service"), downtime.Name, downtime.Memo, now.Unix(), now.Unix()+downtime.Duration*60)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	created, err := client.CreateDowntime(downtime)
+	logger.DieIf(err)
+	logger.Log("Alert snoozed", fmt.Sprintf("%s until %s (downtime %s)", alertID,
+		format.ISO8601Extended(time.Unix(created.Start+created.Duration*60, 0)), created.ID))
+	if c.Bool("verbose") {
+		format.PrettyPrintJSON(os.Stdout, created)
+	}
+	for _, a := range annotations {
+		created, err := client.CreateGraphAnnotation(a)
+		logger.DieIf(err)
+		logger.Log("created", fmt.Sprintf("annotation %s of %s", created.ID, created.Service))
+	}
+	return nil
+}
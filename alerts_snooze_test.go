@@ -0,0 +1,43 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestAlertSnoozeDowntime(t *testing.T) {
+	now := time.Unix(1000, 0)
+	host := &mackerel.Host{ID: "3XYyG", Roles: mackerel.Roles{"foo": {"app"}, "bar": {"web", "db"}}}
+	monitor := &mackerel.MonitorHostMetric{ID: "5rXR3", Name: "cpu"}
+	as := &alertSet{&mackerel.Alert{ID: "2tZhm", HostID: "3XYyG", MonitorID: "5rXR3"}, host, monitor}
+
+	downtime, err := alertSnoozeDowntime(as, 2*60*60, "deploying", "alice", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &mackerel.Downtime{
+		Name:          "snooze: alert 2tZhm (cpu)",
+		Memo:          "deploying\nsnoozed by alice",
+		Start:         1000,
+		Duration:      120,
+		MonitorScopes: []string{"5rXR3"},
+		RoleScopes:    []string{"bar: db", "bar: web", "foo: app"},
+	}
+	if !reflect.DeepEqual(expected, downtime) {
+		t.Errorf("downtime should be %+v but: %+v", expected, downtime)
+	}
+
+	as = &alertSet{&mackerel.Alert{ID: "2tZhm"}, nil, &mackerel.MonitorServiceMetric{ID: "6sYS4", Name: "latency", Service: "foo"}}
+	downtime, _ = alertSnoozeDowntime(as, 90, "", "", now)
+	if downtime.Duration != 2 || !reflect.DeepEqual(downtime.ServiceScopes, []string{"foo"}) || downtime.RoleScopes != nil {
+		t.Errorf("downtime should be scoped to the service of the monitor for 2 minutes but: %+v", downtime)
+	}
+
+	as = &alertSet{&mackerel.Alert{ID: "2tZhm"}, nil, nil}
+	if _, err := alertSnoozeDowntime(as, 60, "", "", now); err == nil {
+		t.Errorf("the alert without monitor should be an error")
+	}
+}
@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// alertStat is the aggregation of the alerts of a monitor.
+type alertStat struct {
+	MonitorID   string `json:"monitorId"`
+	MonitorName string `json:"monitorName"`
+	Count       int    `json:"count"`
+	Open        int    `json:"open"`
+	// mean time to close in seconds
+	MeanTimeToClose int64 `json:"meanTimeToClose"`
+	Flaps           int   `json:"flaps"`
+}
+
+// aggregateAlerts aggregates the alerts by monitor and sorts the results by the counts.
+// An alert is counted as a flap if it is opened within flapWindow after the previous alert
+// of the same monitor and host is closed.
+func aggregateAlerts(alerts []*mackerel.Alert, monitorNames map[string]string, flapWindow time.Duration) []*alertStat {
+	sorted := make([]*mackerel.Alert, len(alerts))
+	copy(sorted, alerts)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].OpenedAt < sorted[j].OpenedAt })
+
+	stats := map[string]*alertStat{}
+	closeTimes := map[string]int64{}
+	lastClosed := map[string]int64{}
+	for _, a := range sorted {
+		st, ok := stats[a.MonitorID]
+		if !ok {
+			st = &alertStat{MonitorID: a.MonitorID, MonitorName: monitorNames[a.MonitorID]}
+			stats[a.MonitorID] = st
+		}
+		st.Count++
+
+		key := a.MonitorID + "/" + a.HostID
+		if closedAt, ok := lastClosed[key]; ok && a.OpenedAt-closedAt <= int64(flapWindow/time.Second) {
+			st.Flaps++
+		}
+		if a.Status == "OK" && a.ClosedAt > 0 {
+			closeTimes[a.MonitorID] += a.ClosedAt - a.OpenedAt
+			lastClosed[key] = a.ClosedAt
+		} else {
+			st.Open++
+			delete(lastClosed, key)
+		}
+	}
+
+	result := make([]*alertStat, 0, len(stats))
+	for _, st := range stats {
+		if closed := st.Count - st.Open; closed > 0 {
+			st.MeanTimeToClose = closeTimes[st.MonitorID] / int64(closed)
+		}
+		result = append(result, st)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Count != result[j].Count {
+			return result[i].Count > result[j].Count
+		}
+		return result[i].MonitorID < result[j].MonitorID
+	})
+	return result
+}
+
+func printAlertStats(w io.Writer, stats []*alertStat, output string) error {
+	switch output {
+	case "json":
+		return format.PrettyPrintJSON(w, stats)
+	case "csv":
+		cw := csv.NewWriter(w)
+		cw.Write([]string{"monitorId", "monitorName", "count", "open", "meanTimeToClose", "flaps"})
+		for _, st := range stats {
+			cw.Write([]string{st.MonitorID, st.MonitorName, strconv.Itoa(st.Count), strconv.Itoa(st.Open), strconv.FormatInt(st.MeanTimeToClose, 10), strconv.Itoa(st.Flaps)})
+		}
+		cw.Flush()
+		return cw.Error()
+	case "table":
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "MONITOR_ID\tMONITOR\tCOUNT\tOPEN\tMTTC\tFLAPS")
+		for _, st := range stats {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%s\t%d\n", st.MonitorID, st.MonitorName, st.Count, st.Open, time.Duration(st.MeanTimeToClose)*time.Second, st.Flaps)
+		}
+		return tw.Flush()
+	}
+	return fmt.Errorf("output should be 'table', 'json' or 'csv': %s", output)
+}
+
+// fetchAlertsSince fetches the alerts including closed ones opened at or after since.
+func fetchAlertsSince(client *mackerel.Client, since time.Time) ([]*mackerel.Alert, error) {
+	resp, err := client.FindWithClosedAlerts()
+	if err != nil {
+		return nil, err
+	}
+	alerts := resp.Alerts
+	for resp.NextID != "" && len(alerts) > 0 && alerts[len(alerts)-1].OpenedAt >= since.Unix() {
+		time.Sleep(1 * time.Second)
+		if resp, err = client.FindWithClosedAlertsByNextID(resp.NextID); err != nil {
+			return nil, err
+		}
+		alerts = append(alerts, resp.Alerts...)
+	}
+	return filterAlerts(alerts, &alertFilter{since: since}), nil
+}
+
+func doAlertsStats(c *cli.Context) error {
+	output := format.OutputName(c, "table", "table", "json", "csv")
+	if output != "table" && output != "json" && output != "csv" {
+		return cli.NewExitError(fmt.Sprintf("output should be 'table', 'json' or 'csv': %s", output), 1)
+	}
+	since, err := parseAlertTime(c.String("since"), time.Now())
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	alerts, err := fetchAlertsSince(client, since)
+	logger.DieIf(err)
+	monitors, err := client.FindMonitors()
+	logger.DieIf(err)
+	monitorNames := map[string]string{}
+	for _, m := range monitors {
+		monitorNames[m.MonitorID()] = m.MonitorName()
+	}
+
+	stats := aggregateAlerts(alerts, monitorNames, c.Duration("flap-window"))
+	if top := c.Int("top"); top > 0 && len(stats) > top {
+		stats = stats[:top]
+	}
+	return printAlertStats(os.Stdout, stats, output)
+}
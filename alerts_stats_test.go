@@ -0,0 +1,49 @@
+package main
+
+import (
+	"bytes"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestAggregateAlerts(t *testing.T) {
+	alerts := []*mackerel.Alert{
+		{ID: "5", MonitorID: "m2", HostID: "h1", Status: "CRITICAL", OpenedAt: 5000},
+		{ID: "4", MonitorID: "m1", HostID: "h1", Status: "OK", OpenedAt: 9000, ClosedAt: 9600},
+		{ID: "3", MonitorID: "m1", HostID: "h2", Status: "OK", OpenedAt: 2000, ClosedAt: 2300},
+		{ID: "2", MonitorID: "m1", HostID: "h1", Status: "OK", OpenedAt: 1500, ClosedAt: 1800},
+		{ID: "1", MonitorID: "m1", HostID: "h1", Status: "OK", OpenedAt: 1000, ClosedAt: 1200},
+	}
+	stats := aggregateAlerts(alerts, map[string]string{"m1": "cpu"}, time.Hour)
+	expected := []*alertStat{
+		{MonitorID: "m1", MonitorName: "cpu", Count: 4, MeanTimeToClose: 350, Flaps: 1},
+		{MonitorID: "m2", Count: 1, Open: 1},
+	}
+	if !reflect.DeepEqual(expected, stats) {
+		t.Errorf("stats should be %+v but: %+v, %+v", expected, stats[0], stats[1])
+	}
+
+	var buf bytes.Buffer
+	if err := printAlertStats(&buf, stats, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	want := "monitorId,monitorName,count,open,meanTimeToClose,flaps\nm1,cpu,4,0,350,1\nm2,,1,1,0,0\n"
+	if buf.String() != want {
+		t.Errorf("csv should be %q but: %q", want, buf.String())
+	}
+
+	buf.Reset()
+	if err := printAlertStats(&buf, stats, "table"); err != nil {
+		t.Fatal(err)
+	}
+	want = `MONITOR_ID  MONITOR  COUNT  OPEN  MTTC   FLAPS
+m1          cpu      4      0     5m50s  1
+m2                   1      1     0s     0
+`
+	if buf.String() != want {
+		t.Errorf("table should be:\n%s\nbut:\n%s", want, buf.String())
+	}
+}
@@ -1,6 +1,8 @@
 package main
 
 import (
+	"bytes"
+	"strings"
 	"testing"
 	"time"
 
@@ -102,3 +104,120 @@ func TestFormatJoinedAlert(t *testing.T) {
 		}
 	}
 }
+
+func TestParseAlertTime(t *testing.T) {
+	now := time.Unix(1600000000, 0)
+	testCases := []struct {
+		src  string
+		want int64
+	}{
+		{"30m", 1600000000 - 30*60},
+		{"7d", 1600000000 - 7*24*60*60},
+		{"-1h", 1600000000 - 60*60},
+		{"now", 1600000000},
+		{"1500000000", 1500000000},
+		{"2020-09-13T12:26:40Z", 1600000000},
+	}
+	for _, tc := range testCases {
+		got, err := parseAlertTime(tc.src, now)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", tc.src, err)
+			continue
+		}
+		if got.Unix() != tc.want {
+			t.Errorf("%s should be parsed to %d but: %d", tc.src, tc.want, got.Unix())
+		}
+	}
+	if _, err := parseAlertTime("yesterday", now); err == nil {
+		t.Errorf("invalid time should be an error")
+	}
+}
+
+func TestFilterAlerts(t *testing.T) {
+	alerts := []*mackerel.Alert{
+		{ID: "1", Status: "CRITICAL", MonitorID: "m1", HostID: "h1", OpenedAt: 100},
+		{ID: "2", Status: "WARNING", MonitorID: "m1", HostID: "h2", OpenedAt: 200},
+		{ID: "3", Status: "CRITICAL", MonitorID: "m2", OpenedAt: 300},
+	}
+	testCases := []struct {
+		filter alertFilter
+		ids    []string
+	}{
+		{alertFilter{}, []string{"1", "2", "3"}},
+		{alertFilter{monitorIDs: []string{"m1"}}, []string{"1", "2"}},
+		{alertFilter{hostIDs: []string{"h2"}}, []string{"2"}},
+		{alertFilter{statuses: []string{"CRITICAL"}}, []string{"1", "3"}},
+		{alertFilter{since: time.Unix(200, 0), until: time.Unix(250, 0)}, []string{"2"}},
+	}
+	for _, tc := range testCases {
+		ids := []string{}
+		for _, a := range filterAlerts(alerts, &tc.filter) {
+			ids = append(ids, a.ID)
+		}
+		if strings.Join(ids, ",") != strings.Join(tc.ids, ",") {
+			t.Errorf("filter %+v should select %v but: %v", tc.filter, tc.ids, ids)
+		}
+	}
+}
+
+func TestPrintAlertsTable(t *testing.T) {
+	time.Local = time.UTC
+	var buf bytes.Buffer
+	err := printAlertsTable(&buf, []*alertSet{
+		{
+			&mackerel.Alert{ID: "2tZhm", Type: "host", Status: "CRITICAL", HostID: "3XYyG", MonitorID: "5rXR3", Value: 15.7, OpenedAt: 200},
+			&mackerel.Host{ID: "3XYyG", Name: "app.example.com"},
+			&mackerel.MonitorHostMetric{ID: "5rXR3", Type: "host", Name: "loadavg5"},
+		},
+		{&mackerel.Alert{ID: "2tZhn", Type: "check", Status: "WARNING", OpenedAt: 300}, nil, nil},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `ID     STATUS    OPENED_AT                  TYPE   MONITOR   HOST             VALUE
+2tZhm  CRITICAL  1970-01-01T00:03:20+00:00  host   loadavg5  app.example.com  15.70
+2tZhn  WARNING   1970-01-01T00:05:00+00:00  check                             0.00
+`
+	if buf.String() != want {
+		t.Errorf("output should be:\n%s\nbut:\n%s", want, buf.String())
+	}
+}
+
+func TestSelectAlertsToClose(t *testing.T) {
+	openAlerts := []*mackerel.Alert{
+		{ID: "1", Status: "CRITICAL", MonitorID: "m1", OpenedAt: 100},
+		{ID: "2", Status: "WARNING", MonitorID: "m1", OpenedAt: 200},
+		{ID: "3", Status: "CRITICAL", MonitorID: "m2", OpenedAt: 300},
+	}
+	selected := selectAlertsToClose(openAlerts, []string{"3", "9"}, &alertFilter{monitorIDs: []string{"m1"}, until: time.Unix(150, 0)})
+	ids := []string{}
+	for _, a := range selected {
+		ids = append(ids, a.ID)
+	}
+	if strings.Join(ids, ",") != "3,9,1" {
+		t.Errorf("selected alerts should be [3 9 1] but: %v", ids)
+	}
+	if selectAlertsToClose(openAlerts, []string{"1"}, nil)[0] != openAlerts[0] {
+		t.Errorf("the open alert should be selected by ID")
+	}
+}
+
+func TestNewAlertReasonFunc(t *testing.T) {
+	alert := &mackerel.Alert{ID: "2tZhm", HostID: "3XYyG"}
+	f, err := newAlertReasonFunc("", "maintenance of {{.HostID}} ({{.ID}})")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if reason, err := f(alert); err != nil || reason != "maintenance of 3XYyG (2tZhm)" {
+		t.Errorf("unexpected reason: %q, %v", reason, err)
+	}
+
+	f, _ = newAlertReasonFunc("fixed", "")
+	if reason, _ := f(alert); reason != "fixed" {
+		t.Errorf("reason should be fixed but: %q", reason)
+	}
+
+	if _, err := newAlertReasonFunc("", "{{.Unknown"); err == nil {
+		t.Errorf("invalid template should be an error")
+	}
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// alertEvent is a change of the open alerts found by `mkr alerts watch`.
+type alertEvent struct {
+	Event string          `json:"event"`
+	Time  int64           `json:"time"`
+	Alert *mackerel.Alert `json:"alert"`
+}
+
+// alertWatcher keeps the open alerts to detect the opened and closed ones.
+type alertWatcher struct {
+	open map[string]*mackerel.Alert
+}
+
+// update compares the open alerts with the previous ones and returns the events.
+// The first call only records the open alerts.
+func (w *alertWatcher) update(alerts []*mackerel.Alert, now time.Time) []*alertEvent {
+	open := make(map[string]*mackerel.Alert, len(alerts))
+	for _, alert := range alerts {
+		open[alert.ID] = alert
+	}
+	if w.open == nil {
+		w.open = open
+		return nil
+	}
+
+	var events []*alertEvent
+	for _, alert := range alerts {
+		if _, ok := w.open[alert.ID]; !ok {
+			events = append(events, &alertEvent{Event: "opened", Time: alert.OpenedAt, Alert: alert})
+		}
+	}
+	var closed []*mackerel.Alert
+	for id, alert := range w.open {
+		if _, ok := open[id]; !ok {
+			closed = append(closed, alert)
+		}
+	}
+	sort.Slice(closed, func(i, j int) bool { return closed[i].OpenedAt < closed[j].OpenedAt })
+	for _, alert := range closed {
+		events = append(events, &alertEvent{Event: "closed", Time: now.Unix(), Alert: alert})
+	}
+	w.open = open
+	return events
+}
+
+func formatAlertEvent(e *alertEvent) string {
+	msg := fmt.Sprintf("%s %-6s %s %s %s", time.Unix(e.Time, 0).Format("2006-01-02 15:04:05"), e.Event, e.Alert.ID, e.Alert.Status, e.Alert.Type)
+	if e.Alert.MonitorID != "" {
+		msg += " monitor:" + e.Alert.MonitorID
+	}
+	if e.Alert.HostID != "" {
+		msg += " host:" + e.Alert.HostID
+	}
+	if e.Alert.Message != "" {
+		msg += " " + formatCheckMessage(e.Alert.Message)
+	}
+	return msg
+}
+
+func writeAlertEvent(w io.Writer, e *alertEvent, output string) error {
+	if output == "json" {
+		return json.NewEncoder(w).Encode(e)
+	}
+	_, err := fmt.Fprintln(w, formatAlertEvent(e))
+	return err
+}
+
+func postAlertEvent(url string, e *alertEvent) error {
+	data, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(url, "application/json", bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("webhook responded %s", resp.Status)
+	}
+	return nil
+}
+
+func doAlertsWatch(c *cli.Context) error {
+	interval := c.Duration("interval")
+	if interval < time.Second {
+		return cli.NewExitError("interval should be 1s or longer", 1)
+	}
+	output := format.OutputName(c, "text", "text", "json")
+	if output != "text" && output != "json" {
+		return cli.NewExitError(fmt.Sprintf("output should be 'text' or 'json': %s", output), 1)
+	}
+	webhook := c.String("webhook")
+
+	client := mackerelclient.NewFromContext(c)
+	watcher := &alertWatcher{}
+	for {
+		alerts, err := fetchAlerts(client, false, math.MaxInt32)
+		if err != nil {
+			logger.Log("error", fmt.Sprintf("failed to fetch alerts: %s", err))
+		} else {
+			for _, e := range watcher.update(alerts, time.Now()) {
+				logger.DieIf(writeAlertEvent(os.Stdout, e, output))
+				if webhook != "" {
+					if err := postAlertEvent(webhook, e); err != nil {
+						logger.Log("error", fmt.Sprintf("failed to forward the event: %s", err))
+					}
+				}
+			}
+		}
+		time.Sleep(interval)
+	}
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestAlertWatcher(t *testing.T) {
+	time.Local = time.UTC
+	w := &alertWatcher{}
+	a1 := &mackerel.Alert{ID: "1", Status: "CRITICAL", Type: "host", HostID: "h1", MonitorID: "m1", OpenedAt: 100}
+	a2 := &mackerel.Alert{ID: "2", Status: "WARNING", Type: "check", HostID: "h1", Message: "disk\nfull", OpenedAt: 200}
+
+	if events := w.update([]*mackerel.Alert{a1}, time.Unix(150, 0)); len(events) != 0 {
+		t.Errorf("the first update should not return events but: %v", events)
+	}
+	events := w.update([]*mackerel.Alert{a2}, time.Unix(250, 0))
+	if len(events) != 2 {
+		t.Fatalf("an opened and a closed events should be returned but: %v", events)
+	}
+
+	var buf bytes.Buffer
+	for _, e := range events {
+		writeAlertEvent(&buf, e, "text")
+	}
+	want := `1970-01-01 00:03:20 opened 2 WARNING check host:h1 disk...
+1970-01-01 00:04:10 closed 1 CRITICAL host monitor:m1 host:h1
+`
+	if buf.String() != want {
+		t.Errorf("output should be:\n%s\nbut:\n%s", want, buf.String())
+	}
+
+	buf.Reset()
+	writeAlertEvent(&buf, events[0], "json")
+	if buf.String() != `{"event":"opened","time":200,"alert":{"id":"2","status":"WARNING","type":"check","hostId":"h1","message":"disk\nfull","openedAt":200}}`+"\n" {
+		t.Errorf("unexpected json: %s", buf.String())
+	}
+}
+
+func TestPostAlertEvent(t *testing.T) {
+	var got alertEvent
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer ts.Close()
+
+	e := &alertEvent{Event: "opened", Time: 100, Alert: &mackerel.Alert{ID: "1"}}
+	if err := postAlertEvent(ts.URL, e); err != nil {
+		t.Fatal(err)
+	}
+	if got.Event != "opened" || got.Alert.ID != "1" {
+		t.Errorf("the event should be posted but: %+v", got)
+	}
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"os"
+	"time"
 
 	"github.com/mackerelio/mackerel-client-go"
 	"github.com/mackerelio/mkr/format"
@@ -21,9 +22,11 @@ var commandAnnotations = cli.Command{
 		{
 			Name:      "create",
 			Usage:     "create a graph annotation",
-			ArgsUsage: "--title <title> [--description <descriptio>] --from <from> --to <to> --service|-s <service> [--role|-r <role>]",
+			ArgsUsage: "(--title <title> [--description <descriptio>] | --from-git [--repo <dir>]) --from <from> --to <to> --service|-s <service> [--role|-r <role>]",
 			Description: `
     Creates a graph annotation.
+    With --from-git, the title and the description are made from the current commit of the git repository,
+    and --from and --to default to now.
 `,
 			Action: doAnnotationsCreate,
 			Flags: []cli.Flag{
@@ -37,20 +40,34 @@ var commandAnnotations = cli.Command{
 					Value: &cli.StringSlice{},
 					Usage: "Roles for annotation. Multiple choices are allowed",
 				},
+				cli.BoolFlag{Name: "from-git", Usage: "Make the annotation of the deployment from the current git commit"},
+				cli.StringFlag{Name: "repo", Value: ".", Usage: "The git repository for --from-git"},
 			},
 		},
 		{
 			Name:      "list",
 			Usage:     "list annotations",
-			ArgsUsage: "--from <from> --to <to> --service|-s <service>",
+			ArgsUsage: "(--from <from> --to <to> | --since <time> [--until <time>]) --service|-s <service> [--role|-r <role>] [--title-match <regex>] [--output|-o json|table|tsv]",
 			Description: `
     Shows annotations by service name and duration (from and to)
+    The time of --since and --until is a duration before now such as '24h' or '7d', RFC3339 or epoch seconds,
+    and --until defaults to now. With --role, only the annotations of the roles are shown.
+    The table output shows the times in the local time zone.
 `,
 			Action: doAnnotationsList,
 			Flags: []cli.Flag{
 				cli.StringFlag{Name: "service, s", Usage: "Service name for annotation"},
 				cli.IntFlag{Name: "from", Usage: "Starting time (epoch seconds)"},
 				cli.IntFlag{Name: "to", Usage: "Ending time (epoch seconds)"},
+				cli.StringFlag{Name: "since", Usage: "Starting time (duration before now, RFC3339 or epoch seconds)"},
+				cli.StringFlag{Name: "until", Usage: "Ending time (duration before now, RFC3339 or epoch seconds)"},
+				cli.StringSliceFlag{
+					Name:  "role, r",
+					Value: &cli.StringSlice{},
+					Usage: "Show only the annotations of the roles. Multiple choices are allowed",
+				},
+				cli.StringFlag{Name: "title-match", Usage: "Show only the annotations whose titles match <regex>"},
+				cli.StringFlag{Name: "output, o", Value: "json", Usage: format.OutputUsage},
 			},
 		},
 		{
@@ -78,13 +95,49 @@ var commandAnnotations = cli.Command{
 		{
 			Name:      "delete",
 			Usage:     "delete annotation",
-			ArgsUsage: "--id <id>",
+			ArgsUsage: "--id <id> | --service|-s <service> --from <from> --to <to> [--title-match <regex>] [--dry-run] [--force]",
 			Description: `
-    Delete graph annotation by annotation id
+    Delete graph annotation by annotation id.
+    With --service, --from and --to instead of --id, deletes the annotations in the range whose titles match --title-match.
 `,
 			Action: doAnnotationsDelete,
 			Flags: []cli.Flag{
 				cli.StringFlag{Name: "id", Usage: "Graph annotation ID"},
+				cli.StringFlag{Name: "service, s", Usage: "Service name for annotations"},
+				cli.IntFlag{Name: "from", Usage: "Starting time (epoch seconds)"},
+				cli.IntFlag{Name: "to", Usage: "Ending time (epoch seconds)"},
+				cli.StringFlag{Name: "title-match", Usage: "Delete only the annotations whose titles match <regex>"},
+				cli.BoolFlag{Name: "dry-run", Usage: "Show the annotations to be deleted, but not delete them"},
+				cli.BoolFlag{Name: "force", Usage: "Delete the annotations without confirmation"},
+			},
+		},
+		{
+			Name:      "export",
+			Usage:     "export annotations",
+			ArgsUsage: "--service|-s <service> --from <from> --to <to> [--out <file>]",
+			Description: `
+    Exports the annotations of the service in the duration (from and to) as JSON, which can be imported by "mkr annotations import".
+`,
+			Action: doAnnotationsExport,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "service, s", Usage: "Service name for annotations"},
+				cli.IntFlag{Name: "from", Usage: "Starting time (epoch seconds)"},
+				cli.IntFlag{Name: "to", Usage: "Ending time (epoch seconds)"},
+				cli.StringFlag{Name: "out", Usage: "File to export the annotations. Prints to stdout if not specified"},
+			},
+		},
+		{
+			Name:      "import",
+			Usage:     "import annotations",
+			ArgsUsage: "--file <file> [--service|-s <service>] [--dry-run]",
+			Description: `
+    Creates the annotations exported by "mkr annotations export", for example to migrate them to another organization.
+`,
+			Action: doAnnotationsImport,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file", Usage: "File of the exported annotations"},
+				cli.StringFlag{Name: "service, s", Usage: "Create the annotations in <service> instead of the exported one"},
+				cli.BoolFlag{Name: "dry-run", Usage: "Show the annotations to be created, but not create them"},
 			},
 		},
 	},
@@ -98,6 +151,27 @@ func doAnnotationsCreate(c *cli.Context) error {
 	service := c.String("service")
 	roles := c.StringSlice("role")
 
+	if c.Bool("from-git") {
+		commit, err := readGitCommit(c.String("repo"))
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		gitTitle, gitDescription := commit.annotation()
+		if title == "" {
+			title = gitTitle
+		}
+		if description == "" {
+			description = gitDescription
+		}
+		now := time.Now().Unix()
+		if from == 0 {
+			from = now
+		}
+		if to == 0 {
+			to = now
+		}
+	}
+
 	if title == "" {
 		_ = cli.ShowCommandHelp(c, "create")
 		return cli.NewExitError("`title` is a required field to create a graph annotation.", 1)
@@ -132,33 +206,6 @@ func doAnnotationsCreate(c *cli.Context) error {
 	return nil
 }
 
-func doAnnotationsList(c *cli.Context) error {
-	service := c.String("service")
-	from := c.Int64("from")
-	to := c.Int64("to")
-
-	if service == "" {
-		_ = cli.ShowCommandHelp(c, "list")
-		return cli.NewExitError("`service` is a required field to list graph annotations.", 1)
-	}
-
-	if from == 0 {
-		_ = cli.ShowCommandHelp(c, "list")
-		return cli.NewExitError("`from` is a required field to list graph annotations.", 1)
-	}
-
-	if to == 0 {
-		_ = cli.ShowCommandHelp(c, "list")
-		return cli.NewExitError("`to` is a required field to list graph annotations.", 1)
-	}
-
-	client := mackerelclient.NewFromContext(c)
-	annotations, err := client.FindGraphAnnotations(service, from, to)
-	logger.DieIf(err)
-	format.PrettyPrintJSON(os.Stdout, annotations)
-	return nil
-}
-
 func doAnnotationsUpdate(c *cli.Context) error {
 	annotationID := c.String("id")
 	title := c.String("title")
@@ -206,8 +253,7 @@ func doAnnotationsDelete(c *cli.Context) error {
 	annotationID := c.String("id")
 
 	if annotationID == "" {
-		_ = cli.ShowCommandHelp(c, "delete")
-		return cli.NewExitError("`id` is a required field to delete a graph annotation.", 1)
+		return doAnnotationsDeleteRange(c)
 	}
 
 	client := mackerelclient.NewFromContext(c)
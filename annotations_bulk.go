@@ -0,0 +1,151 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strings"
+
+	"github.com/Songmu/prompter"
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// filterAnnotations returns the annotations whose titles match the pattern.
+func filterAnnotations(annotations []mackerel.GraphAnnotation, re *regexp.Regexp) []mackerel.GraphAnnotation {
+	filtered := make([]mackerel.GraphAnnotation, 0, len(annotations))
+	for _, a := range annotations {
+		if re == nil || re.MatchString(a.Title) {
+			filtered = append(filtered, a)
+		}
+	}
+	return filtered
+}
+
+// loadAnnotations loads the annotations exported by "mkr annotations export".
+// The IDs are dropped since the annotations are created as new ones.
+func loadAnnotations(filePath string) ([]*mackerel.GraphAnnotation, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var annotations []*mackerel.GraphAnnotation
+	if err := json.Unmarshal(data, &annotations); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	for i, a := range annotations {
+		if a.Title == "" || a.Service == "" || a.From == 0 || a.To == 0 {
+			return nil, fmt.Errorf("failed to load %s: title, service, from and to are required: annotations[%d]", filePath, i)
+		}
+		a.ID = ""
+	}
+	return annotations, nil
+}
+
+func doAnnotationsExport(c *cli.Context) error {
+	service := c.String("service")
+	from := c.Int64("from")
+	to := c.Int64("to")
+	if service == "" || from == 0 || to == 0 {
+		_ = cli.ShowCommandHelp(c, "export")
+		return cli.NewExitError("`service`, `from` and `to` are required fields to export graph annotations.", 1)
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	annotations, err := client.FindGraphAnnotations(service, from, to)
+	logger.DieIf(err)
+
+	out := c.String("out")
+	if out == "" {
+		format.PrettyPrintJSON(os.Stdout, annotations)
+		return nil
+	}
+	f, err := os.Create(out)
+	logger.DieIf(err)
+	defer f.Close()
+	logger.DieIf(format.WriteIndentJSON(f, annotations))
+	logger.Log("info", fmt.Sprintf("%d annotations are exported to '%s'.", len(annotations), out))
+	return nil
+}
+
+func doAnnotationsImport(c *cli.Context) error {
+	filePath := c.String("file")
+	if filePath == "" {
+		_ = cli.ShowCommandHelp(c, "import")
+		return cli.NewExitError("`file` is a required field to import graph annotations.", 1)
+	}
+	annotations, err := loadAnnotations(filePath)
+	logger.DieIf(err)
+	if service := c.String("service"); service != "" {
+		for _, a := range annotations {
+			a.Service = service
+		}
+	}
+
+	if c.Bool("dry-run") {
+		for _, a := range annotations {
+			logger.Log("create", fmt.Sprintf("%s %s (dry-run)", a.Service, a.Title))
+		}
+		return nil
+	}
+	client := mackerelclient.NewFromContext(c)
+	for _, a := range annotations {
+		created, err := client.CreateGraphAnnotation(a)
+		logger.DieIf(err)
+		logger.Log("created", fmt.Sprintf("%s %s %s", created.ID, created.Service, created.Title))
+	}
+	return nil
+}
+
+// doAnnotationsDeleteRange deletes the annotations of the service in the range whose titles match --title-match.
+func doAnnotationsDeleteRange(c *cli.Context) error {
+	service := c.String("service")
+	from := c.Int64("from")
+	to := c.Int64("to")
+	if service == "" || from == 0 || to == 0 {
+		_ = cli.ShowCommandHelp(c, "delete")
+		return cli.NewExitError("either `id`, or `service`, `from` and `to` are required to delete graph annotations.", 1)
+	}
+	var re *regexp.Regexp
+	if titleMatch := c.String("title-match"); titleMatch != "" {
+		var err error
+		if re, err = regexp.Compile(titleMatch); err != nil {
+			return cli.NewExitError(fmt.Sprintf("invalid title-match: %s", err), 1)
+		}
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	annotations, err := client.FindGraphAnnotations(service, from, to)
+	logger.DieIf(err)
+	annotations = filterAnnotations(annotations, re)
+	if len(annotations) == 0 {
+		logger.Log("", "no annotations are matched.")
+		return nil
+	}
+
+	lines := make([]string, 0, len(annotations))
+	for _, a := range annotations {
+		lines = append(lines, fmt.Sprintf("%s %s", a.ID, a.Title))
+	}
+	if c.Bool("dry-run") {
+		for _, line := range lines {
+			logger.Log("delete", line+" (dry-run)")
+		}
+		return nil
+	}
+	if !c.Bool("force") && !prompter.YN("Delete following annotations.\n  "+strings.Join(lines, "\n  ")+"\nAre you sure?", false) {
+		logger.Log("", "deletion is canceled.")
+		return nil
+	}
+	for _, a := range annotations {
+		_, err := client.DeleteGraphAnnotation(a.ID)
+		logger.DieIf(err)
+		logger.Log("deleted", fmt.Sprintf("%s %s", a.ID, a.Title))
+	}
+	return nil
+}
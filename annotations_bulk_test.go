@@ -0,0 +1,50 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestFilterAnnotations(t *testing.T) {
+	annotations := []mackerel.GraphAnnotation{
+		{ID: "1", Title: "deploy v1.0"},
+		{ID: "2", Title: "incident"},
+		{ID: "3", Title: "deploy v1.1"},
+	}
+	got := filterAnnotations(annotations, regexp.MustCompile(`^deploy `))
+	if len(got) != 2 || got[0].ID != "1" || got[1].ID != "3" {
+		t.Errorf("only the deploy annotations should be matched: %+v", got)
+	}
+	if got := filterAnnotations(annotations, nil); len(got) != 3 {
+		t.Errorf("all the annotations should be matched without pattern: %+v", got)
+	}
+}
+
+func TestLoadAnnotations(t *testing.T) {
+	f, err := ioutil.TempFile("", "mkr-annotations")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString(`[{"id": "abc", "title": "deploy", "from": 100, "to": 200, "service": "Blog", "roles": ["db"]}]`)
+	f.Close()
+
+	annotations, err := loadAnnotations(f.Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []*mackerel.GraphAnnotation{{Title: "deploy", From: 100, To: 200, Service: "Blog", Roles: []string{"db"}}}
+	if !reflect.DeepEqual(annotations, expected) {
+		t.Errorf("annotations should be %+v but: %+v", expected, annotations)
+	}
+
+	ioutil.WriteFile(f.Name(), []byte(`[{"title": "deploy", "from": 100, "to": 200}]`), 0644)
+	if _, err := loadAnnotations(f.Name()); err == nil {
+		t.Errorf("err should occur without service")
+	}
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"strings"
+)
+
+type gitCommit struct {
+	hash    string
+	author  string
+	subject string
+	tag     string
+}
+
+// the format of git log to read the hash, the author and the subject separated by NUL
+const gitCommitFormat = "%H%x00%an%x00%s"
+
+func parseGitCommit(out string) (*gitCommit, error) {
+	fields := strings.Split(strings.TrimRight(out, "\n"), "\x00")
+	if len(fields) != 3 || fields[0] == "" {
+		return nil, fmt.Errorf("unexpected output of git log: %q", out)
+	}
+	return &gitCommit{hash: fields[0], author: fields[1], subject: fields[2]}, nil
+}
+
+// readGitCommit reads the current commit of the repository and its tag if any.
+func readGitCommit(repo string) (*gitCommit, error) {
+	out, err := exec.Command("git", "-C", repo, "log", "-1", "--format="+gitCommitFormat).Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read the commit of %s: %s", repo, err)
+	}
+	commit, err := parseGitCommit(string(out))
+	if err != nil {
+		return nil, err
+	}
+	// the commit is not tagged if git describe fails
+	if tag, err := exec.Command("git", "-C", repo, "describe", "--tags", "--exact-match", "HEAD").Output(); err == nil {
+		commit.tag = strings.TrimSpace(string(tag))
+	}
+	return commit, nil
+}
+
+// annotation returns the title and the description of the deploy annotation of the commit.
+func (c *gitCommit) annotation() (string, string) {
+	short := c.hash
+	if len(short) > 7 {
+		short = short[:7]
+	}
+	title := "deploy " + short
+	if c.tag != "" {
+		title = fmt.Sprintf("deploy %s (%s)", c.tag, short)
+	}
+	description := fmt.Sprintf("%s\n\ncommit: %s\nauthor: %s", c.subject, c.hash, c.author)
+	return title, description
+}
@@ -0,0 +1,34 @@
+package main
+
+import (
+	"testing"
+)
+
+func TestParseGitCommit(t *testing.T) {
+	commit, err := parseGitCommit("0123456789abcdef\x00Alice\x00Fix the cache\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if commit.hash != "0123456789abcdef" || commit.author != "Alice" || commit.subject != "Fix the cache" {
+		t.Errorf("unexpected commit: %+v", commit)
+	}
+	if _, err := parseGitCommit(""); err == nil {
+		t.Errorf("err should occur for empty output")
+	}
+}
+
+func TestGitCommitAnnotation(t *testing.T) {
+	commit := &gitCommit{hash: "0123456789abcdef", author: "Alice", subject: "Fix the cache"}
+	title, description := commit.annotation()
+	if title != "deploy 0123456" {
+		t.Errorf("unexpected title: %s", title)
+	}
+	if expected := "Fix the cache\n\ncommit: 0123456789abcdef\nauthor: Alice"; description != expected {
+		t.Errorf("description should be %q but: %q", expected, description)
+	}
+
+	commit.tag = "v1.2.0"
+	if title, _ := commit.annotation(); title != "deploy v1.2.0 (0123456)" {
+		t.Errorf("unexpected title: %s", title)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// annotationsRange returns the range of --from and --to, or --since and --until which accept
+// the relative times. --until defaults to now when --since is specified.
+func annotationsRange(c *cli.Context, now time.Time) (int64, int64, error) {
+	from, to := c.Int64("from"), c.Int64("to")
+	if since := c.String("since"); since != "" {
+		if from != 0 {
+			return 0, 0, fmt.Errorf("specify either --from or --since")
+		}
+		t, err := parseAlertTime(since, now)
+		if err != nil {
+			return 0, 0, err
+		}
+		from = t.Unix()
+		if to == 0 && c.String("until") == "" {
+			to = now.Unix()
+		}
+	}
+	if until := c.String("until"); until != "" {
+		if to != 0 {
+			return 0, 0, fmt.Errorf("specify either --to or --until")
+		}
+		t, err := parseAlertTime(until, now)
+		if err != nil {
+			return 0, 0, err
+		}
+		to = t.Unix()
+	}
+	return from, to, nil
+}
+
+// filterAnnotationsByRoles returns the annotations of any of the roles.
+func filterAnnotationsByRoles(annotations []mackerel.GraphAnnotation, roles []string) []mackerel.GraphAnnotation {
+	if len(roles) == 0 {
+		return annotations
+	}
+	filtered := make([]mackerel.GraphAnnotation, 0, len(annotations))
+	for _, a := range annotations {
+		for _, role := range a.Roles {
+			if containsString(roles, role) {
+				filtered = append(filtered, a)
+				break
+			}
+		}
+	}
+	return filtered
+}
+
+const annotationTimeLayout = "2006-01-02 15:04:05"
+
+// annotationsTable builds the table of the annotations with the times in the location.
+func annotationsTable(annotations []mackerel.GraphAnnotation, loc *time.Location) *format.Table {
+	sorted := make([]mackerel.GraphAnnotation, len(annotations))
+	copy(sorted, annotations)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].From < sorted[j].From })
+	t := format.NewTable("ID", "FROM", "TO", "DURATION", "TITLE", "ROLES")
+	for _, a := range sorted {
+		t.Append(a.ID,
+			time.Unix(a.From, 0).In(loc).Format(annotationTimeLayout),
+			time.Unix(a.To, 0).In(loc).Format(annotationTimeLayout),
+			(time.Duration(a.To-a.From) * time.Second).String(),
+			a.Title, strings.Join(a.Roles, ","))
+	}
+	return t
+}
+
+func doAnnotationsList(c *cli.Context) error {
+	service := c.String("service")
+	from, to, err := annotationsRange(c, time.Now())
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	if service == "" {
+		_ = cli.ShowCommandHelp(c, "list")
+		return cli.NewExitError("`service` is a required field to list graph annotations.", 1)
+	}
+
+	if from == 0 {
+		_ = cli.ShowCommandHelp(c, "list")
+		return cli.NewExitError("`from` or `since` is a required field to list graph annotations.", 1)
+	}
+
+	if to == 0 {
+		_ = cli.ShowCommandHelp(c, "list")
+		return cli.NewExitError("`to` is a required field to list graph annotations.", 1)
+	}
+
+	var re *regexp.Regexp
+	if titleMatch := c.String("title-match"); titleMatch != "" {
+		if re, err = regexp.Compile(titleMatch); err != nil {
+			return cli.NewExitError(fmt.Sprintf("invalid title-match: %s", err), 1)
+		}
+	}
+	output, err := format.OutputFromContext(c, format.OutputJSON)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	annotations, err := client.FindGraphAnnotations(service, from, to)
+	logger.DieIf(err)
+	annotations = filterAnnotationsByRoles(filterAnnotations(annotations, re), c.StringSlice("role"))
+	return output.Print(os.Stdout, annotations, func() *format.Table {
+		return annotationsTable(annotations, time.Local)
+	})
+}
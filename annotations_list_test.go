@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/urfave/cli"
+)
+
+func TestAnnotationsRange(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	testCases := []struct {
+		args     []string
+		from, to int64
+		err      bool
+	}{
+		{[]string{"--from", "100", "--to", "200"}, 100, 200, false},
+		{[]string{"--since", "24h"}, 1700000000 - 86400, 1700000000, false},
+		{[]string{"--since", "7d", "--until", "1d"}, 1700000000 - 7*86400, 1700000000 - 86400, false},
+		{[]string{"--since", "1699990000", "--to", "1699999999"}, 1699990000, 1699999999, false},
+		{[]string{"--from", "100", "--since", "24h"}, 0, 0, true},
+		{[]string{"--since", "yesterday"}, 0, 0, true},
+	}
+	for _, tc := range testCases {
+		set := flag.NewFlagSet("list", flag.ContinueOnError)
+		set.Int64("from", 0, "")
+		set.Int64("to", 0, "")
+		set.String("since", "", "")
+		set.String("until", "", "")
+		if err := set.Parse(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		from, to, err := annotationsRange(cli.NewContext(nil, set, nil), now)
+		if tc.err {
+			if err == nil {
+				t.Errorf("err should occur for %v", tc.args)
+			}
+			continue
+		}
+		if err != nil || from != tc.from || to != tc.to {
+			t.Errorf("the range of %v should be (%d, %d) but: (%d, %d, %v)", tc.args, tc.from, tc.to, from, to, err)
+		}
+	}
+}
+
+func TestAnnotationsTable(t *testing.T) {
+	annotations := []mackerel.GraphAnnotation{
+		{ID: "2", Title: "incident", From: 1700003600, To: 1700003600},
+		{ID: "1", Title: "deploy v1.0", From: 1700000000, To: 1700000090, Roles: []string{"app", "db"}},
+		{ID: "3", Title: "deploy v1.1", From: 1700007200, To: 1700007200, Roles: []string{"batch"}},
+	}
+	if got := filterAnnotationsByRoles(annotations, []string{"db", "batch"}); len(got) != 2 || got[0].ID != "1" || got[1].ID != "3" {
+		t.Errorf("only the annotations of the roles should be matched: %+v", got)
+	}
+
+	output, _ := format.ParseOutput("tsv")
+	var buf bytes.Buffer
+	loc := time.FixedZone("JST", 9*60*60)
+	if err := output.Print(&buf, nil, func() *format.Table { return annotationsTable(annotations, loc) }); err != nil {
+		t.Fatal(err)
+	}
+	expect := "ID\tFROM\tTO\tDURATION\tTITLE\tROLES\n" +
+		"1\t2023-11-15 07:13:20\t2023-11-15 07:14:50\t1m30s\tdeploy v1.0\tapp,db\n" +
+		"2\t2023-11-15 08:13:20\t2023-11-15 08:13:20\t0s\tincident\t\n" +
+		"3\t2023-11-15 09:13:20\t2023-11-15 09:13:20\t0s\tdeploy v1.1\tbatch\n"
+	if buf.String() != expect {
+		t.Errorf("output should be %q but: %q", expect, buf.String())
+	}
+}
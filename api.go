@@ -0,0 +1,218 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandAPI = cli.Command{
+	Name:      "api",
+	Usage:     "Request the API",
+	ArgsUsage: "[--data | -d <data> | @<file>] [--include | -i] <method> <path>",
+	Description: `
+    Requests the path of the API such as /api/v0/hosts?service=MyApp with the apikey of mkr, and prints the raw response.
+    The method is GET, POST, PUT or DELETE. The body of the request is given by --data, which reads the file with @<file>
+    or the standard input with @-. With --include, the status and the headers of the response are also printed.
+    Exits with status 1 if the status of the response is not 2xx.
+    See https://mackerel.io/api-docs/ for the APIs.
+`,
+	Action: doAPI,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "data, d", Value: "", Usage: "The body of the request, or @<file> to read it from the file (@- for stdin)"},
+		cli.BoolFlag{Name: "include, i", Usage: "Print the status and the headers of the response"},
+	},
+	Subcommands: []cli.Command{
+		{
+			Name:      "usage",
+			Usage:     "show the API requests made by the commands",
+			ArgsUsage: "[--reset] [--output | -o <format>]",
+			Description: `
+    Shows the number of the API requests made by each command, which are recorded in ~/.cache/mkr/usage.json,
+    and the number of the requests rate limited (429). The rate limit reported by the last response is also
+    shown if any. The responses served from the cache are not counted.
+    With --reset, the recorded usage is cleared.
+`,
+			Action: doAPIUsage,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "reset", Usage: "Clear the recorded usage"},
+				cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+			},
+		},
+	},
+}
+
+// recordUsageCommands sets the full name of the running command to record the API usage.
+func recordUsageCommands(commands []cli.Command, prefix string) []cli.Command {
+	cmds := make([]cli.Command, len(commands))
+	for i, cmd := range commands {
+		name := strings.TrimSpace(prefix + " " + cmd.Name)
+		cmd.Subcommands = recordUsageCommands(cmd.Subcommands, name)
+		before := cmd.Before
+		cmd.Before = func(c *cli.Context) error {
+			mackerelclient.SetUsageCommand(name)
+			if before != nil {
+				return before(c)
+			}
+			return nil
+		}
+		cmds[i] = cmd
+	}
+	return cmds
+}
+
+func describeRateLimit(rl *mackerelclient.RateLimit) string {
+	if rl == nil {
+		return "no rate limit is reported by the API"
+	}
+	var parts []string
+	if rl.Remaining != "" || rl.Limit != "" {
+		parts = append(parts, fmt.Sprintf("%s of %s requests remaining", orDash(rl.Remaining), orDash(rl.Limit)))
+	}
+	if rl.Reset != "" {
+		parts = append(parts, "reset at "+rl.Reset)
+	}
+	if rl.RetryAfter != "" {
+		parts = append(parts, "retry after "+rl.RetryAfter)
+	}
+	return fmt.Sprintf("rate limit: %s (at %s)", strings.Join(parts, ", "), format.ISO8601Extended(time.Unix(rl.ObservedAt, 0)))
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+// describeCurrentUsage describes the API usage of the running command for --show-rate-limit.
+func describeCurrentUsage() string {
+	usage, rl := mackerelclient.CurrentUsage()
+	return fmt.Sprintf("%d API requests (%d rate limited), %s", usage.Calls, usage.RateLimited, describeRateLimit(rl))
+}
+
+func apiUsageTable(usage *mackerelclient.APIUsage) *format.Table {
+	names := make([]string, 0, len(usage.Commands))
+	for name := range usage.Commands {
+		names = append(names, name)
+	}
+	// the expensive commands first
+	sort.Slice(names, func(i, j int) bool {
+		ci, cj := usage.Commands[names[i]], usage.Commands[names[j]]
+		if ci.Calls != cj.Calls {
+			return ci.Calls > cj.Calls
+		}
+		return names[i] < names[j]
+	})
+	t := format.NewTable("COMMAND", "CALLS", "RATE_LIMITED", "LAST_USED_AT")
+	for _, name := range names {
+		cu := usage.Commands[name]
+		t.Append(name, fmt.Sprint(cu.Calls), fmt.Sprint(cu.RateLimited), format.ISO8601Extended(time.Unix(cu.LastUsedAt, 0)))
+	}
+	return t
+}
+
+func doAPIUsage(c *cli.Context) error {
+	file := mackerelclient.DefaultUsageFile()
+	if c.Bool("reset") {
+		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		logger.Log("info", "the API usage is cleared.")
+		return nil
+	}
+	output, err := format.OutputFromContext(c, "table")
+	if err != nil {
+		return err
+	}
+	usage, err := mackerelclient.LoadUsage(file)
+	if err != nil {
+		return fmt.Errorf("failed to load %s: %s", file, err)
+	}
+	if err := output.Print(os.Stdout, usage, func() *format.Table { return apiUsageTable(usage) }); err != nil {
+		return err
+	}
+	if output.Format == format.OutputTable {
+		logger.Log("api", describeRateLimit(usage.RateLimit))
+	}
+	return nil
+}
+
+var apiMethods = []string{http.MethodGet, http.MethodPost, http.MethodPut, http.MethodDelete}
+
+// readAPIData reads the body of the request from the file of @<file>, or the standard input of @-.
+func readAPIData(data string, stdin io.Reader) ([]byte, error) {
+	switch {
+	case data == "":
+		return nil, nil
+	case data == "@-":
+		return ioutil.ReadAll(stdin)
+	case strings.HasPrefix(data, "@"):
+		return ioutil.ReadFile(strings.TrimPrefix(data, "@"))
+	}
+	return []byte(data), nil
+}
+
+// printAPIResponse prints the response with the status and the headers if include is true.
+func printAPIResponse(w io.Writer, resp *http.Response, include bool) error {
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if include {
+		fmt.Fprintf(w, "%s %s\n", resp.Proto, resp.Status)
+		if err := resp.Header.Write(w); err != nil {
+			return err
+		}
+		fmt.Fprintln(w)
+	}
+	if _, err := w.Write(body); err != nil {
+		return err
+	}
+	if len(body) > 0 && body[len(body)-1] != '\n' {
+		fmt.Fprintln(w)
+	}
+	return nil
+}
+
+func doAPI(c *cli.Context) error {
+	if c.NArg() != 2 {
+		cli.ShowCommandHelp(c, "api")
+		os.Exit(1)
+	}
+	method, path := strings.ToUpper(c.Args().Get(0)), c.Args().Get(1)
+	if !containsString(apiMethods, method) {
+		return cli.NewExitError(fmt.Sprintf("method should be one of %s: %s", strings.Join(apiMethods, ", "), method), 1)
+	}
+	if !strings.HasPrefix(path, "/") {
+		return cli.NewExitError(fmt.Sprintf("path should begin with /: %s", path), 1)
+	}
+	body, err := readAPIData(c.String("data"), os.Stdin)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("failed to read the data: %s", err), 1)
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	resp, err := mackerelclient.RequestRaw(client, method, path, body)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if err := printAPIResponse(os.Stdout, resp, c.Bool("include")); err != nil {
+		return err
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return cli.NewExitError(fmt.Sprintf("%s %s responded %s", method, path, resp.Status), 1)
+	}
+	return nil
+}
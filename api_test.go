@@ -0,0 +1,86 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+func TestReadAPIData(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-api-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "host.json")
+	if err := ioutil.WriteFile(file, []byte(`{"name":"app001"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	testCases := []struct {
+		data   string
+		expect string
+	}{
+		{"", ""},
+		{`{"name":"db001"}`, `{"name":"db001"}`},
+		{"@" + file, `{"name":"app001"}`},
+		{"@-", `{"name":"web001"}`},
+	}
+	for _, tc := range testCases {
+		got, err := readAPIData(tc.data, strings.NewReader(`{"name":"web001"}`))
+		if err != nil || string(got) != tc.expect {
+			t.Errorf("readAPIData(%q) should be %q but: %q, %v", tc.data, tc.expect, got, err)
+		}
+	}
+	if _, err := readAPIData("@"+filepath.Join(dir, "none.json"), nil); err == nil {
+		t.Errorf("err should occur for the file not found")
+	}
+}
+
+func TestRequestRaw(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Api-Key") != "dummy-key" {
+			t.Errorf("the apikey should be sent")
+		}
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Method == "POST" && (string(body) != `{"name":"app001"}` || r.Header.Get("Content-Type") != "application/json") {
+			t.Errorf("the body should be sent as JSON: %s", body)
+		}
+		if r.URL.Path != "/api/v0/hosts" || r.URL.Query().Get("service") != "MyApp" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusNotFound)
+		w.Write([]byte(`{"error":{"message":"Service not found"}}`))
+	}))
+	defer ts.Close()
+	client, _ := mackerel.NewClientWithOptions("dummy-key", ts.URL, false)
+
+	resp, err := mackerelclient.RequestRaw(client, "POST", "/api/v0/hosts?service=MyApp", []byte(`{"name":"app001"}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	var buf bytes.Buffer
+	if err := printAPIResponse(&buf, resp, true); err != nil {
+		t.Fatal(err)
+	}
+	out := buf.String()
+	if !strings.HasPrefix(out, "HTTP/1.1 404 Not Found\n") {
+		t.Errorf("the status should be printed: %q", out)
+	}
+	if !strings.Contains(out, "Content-Type: application/json\r\n") {
+		t.Errorf("the headers should be printed: %q", out)
+	}
+	if !strings.HasSuffix(out, "\r\n\n"+`{"error":{"message":"Service not found"}}`+"\n") {
+		t.Errorf("the raw body of the error response should be printed: %q", out)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/Songmu/prompter"
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/channels"
+	"github.com/mackerelio/mkr/downtimes"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/mackerelio/mkr/notificationgroups"
+	"github.com/mackerelio/mkr/services"
+	"github.com/urfave/cli"
+)
+
+var commandApply = cli.Command{
+	Name:      "apply",
+	Usage:     "Apply a manifest of the resources to the organization",
+	ArgsUsage: "--file-path | -f <file> [--prune] [--dry-run] [--force]",
+	Description: `
+    Reconcile the organization with a manifest in YAML or JSON, which has the sections of
+    "services", "channels", "monitors", "notificationGroups", "downtimes" and "dashboards".
+    Each section has the same format as the file of the push command of the resources,
+    and only the resources of the sections in the manifest are reconciled.
+    The plan of the changes is shown first, and applied after the confirmation.
+    With --prune, the resources not in the manifest are deleted. With --dry-run, only the plan is shown.
+`,
+	Action: doApply,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "file-path, f", Value: "", Usage: "Manifest of the resources in YAML or JSON"},
+		cli.BoolFlag{Name: "prune", Usage: "Delete the resources not in the manifest"},
+		cli.BoolFlag{Name: "dry-run, d", Usage: "Show the plan of the changes, but not apply it"},
+		cli.BoolFlag{Name: "force", Usage: "Apply the changes without confirmation"},
+	},
+}
+
+// applyManifest is the manifest of mkr apply.
+type applyManifest struct {
+	Services           json.RawMessage   `json:"services"`
+	Channels           json.RawMessage   `json:"channels"`
+	Monitors           json.RawMessage   `json:"monitors"`
+	Templates          json.RawMessage   `json:"templates"`
+	NotificationGroups json.RawMessage   `json:"notificationGroups"`
+	Downtimes          json.RawMessage   `json:"downtimes"`
+	Dashboards         []json.RawMessage `json:"dashboards"`
+}
+
+// loadApplyManifest loads the manifest in YAML or JSON. The unknown sections are rejected.
+func loadApplyManifest(filePath string) (*applyManifest, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is also converted as YAML
+	if data, err = format.YAMLToJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to load '%s': %s", filePath, err)
+	}
+	var manifest applyManifest
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("failed to load '%s': %s", filePath, err)
+	}
+	if manifest.Templates != nil && manifest.Monitors == nil {
+		return nil, fmt.Errorf("failed to load '%s': templates are specified without monitors", filePath)
+	}
+	return &manifest, nil
+}
+
+// monitors returns the monitor rules in the manifest, expanding the templates.
+func (m *applyManifest) monitors() ([]mackerel.Monitor, error) {
+	data, err := json.Marshal(map[string]json.RawMessage{
+		"monitors":  m.Monitors,
+		"templates": m.Templates,
+	})
+	if err != nil {
+		return nil, err
+	}
+	return decodeMonitors(bytes.NewReader(data))
+}
+
+// dashboards returns the dashboards in the manifest, labeled by the indices as the paths.
+func (m *applyManifest) dashboards(filePath string) ([]*dashboardFile, error) {
+	files := make([]*dashboardFile, 0, len(m.Dashboards))
+	for i, raw := range m.Dashboards {
+		path := fmt.Sprintf("%s: dashboards[%d]", filePath, i)
+		var dashboard mackerel.Dashboard
+		if err := json.Unmarshal(raw, &dashboard); err != nil {
+			return nil, fmt.Errorf("%s: %s", path, err)
+		}
+		files = append(files, &dashboardFile{path, &dashboard})
+	}
+	return files, nil
+}
+
+// applySection is a section of the manifest to be reconciled.
+type applySection struct {
+	name  string
+	apply func(isDryRun bool) error
+}
+
+// pushFileSection returns the section which writes the resources to a file in dir
+// in the format of the push command, and pushes them with push.
+func pushFileSection(name, dir, fileName, key string, raw json.RawMessage, push func(filePath string, isDryRun bool) error) (*applySection, error) {
+	data, err := json.Marshal(map[string]json.RawMessage{key: raw})
+	if err != nil {
+		return nil, err
+	}
+	filePath := filepath.Join(dir, fileName)
+	if err := ioutil.WriteFile(filePath, data, 0600); err != nil {
+		return nil, err
+	}
+	return &applySection{name, func(isDryRun bool) error {
+		return push(filePath, isDryRun)
+	}}, nil
+}
+
+// applySections returns the sections in the manifest in the order to be applied,
+// so that the services and the channels exist before the monitors and the notification groups refer them.
+func applySections(c *cli.Context, manifest *applyManifest, filePath, dir string, isPrune bool) ([]*applySection, error) {
+	var sections []*applySection
+	type pushSection struct {
+		name, fileName, key string
+		raw                 json.RawMessage
+		push                func(*cli.Context, string, bool, bool) error
+	}
+	add := func(s pushSection) error {
+		if s.raw == nil {
+			return nil
+		}
+		section, err := pushFileSection(s.name, dir, s.fileName, s.key, s.raw, func(filePath string, isDryRun bool) error {
+			return s.push(c, filePath, isDryRun, isPrune)
+		})
+		if err != nil {
+			return err
+		}
+		sections = append(sections, section)
+		return nil
+	}
+
+	if err := add(pushSection{"services", "services.yaml", "services", manifest.Services, services.Push}); err != nil {
+		return nil, err
+	}
+	if err := add(pushSection{"channels", "channels.json", "channels", manifest.Channels, channels.Push}); err != nil {
+		return nil, err
+	}
+	if manifest.Monitors != nil {
+		monitors, err := manifest.monitors()
+		if err != nil {
+			return nil, err
+		}
+		sections = append(sections, &applySection{"monitors", func(isDryRun bool) error {
+			client := mackerelclient.NewFromContext(c)
+			remotes, err := client.FindMonitors()
+			if err != nil {
+				return err
+			}
+			monitorDiff, err := diffMonitors(remotes, monitors)
+			if err != nil {
+				return err
+			}
+			if !isPrune {
+				monitorDiff.onlyRemote = nil
+			}
+			return pushMonitors(client, monitorDiff, isDryRun, 1, nil)
+		}})
+	}
+	if err := add(pushSection{"notification groups", "notification-groups.json", "notificationGroups", manifest.NotificationGroups, notificationgroups.Push}); err != nil {
+		return nil, err
+	}
+	if err := add(pushSection{"downtimes", "downtimes.json", "downtimes", manifest.Downtimes, downtimes.Push}); err != nil {
+		return nil, err
+	}
+	if manifest.Dashboards != nil {
+		files, err := manifest.dashboards(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if !validateDashboardFiles(files) {
+			return nil, fmt.Errorf("problems are found in the dashboards of '%s'", filePath)
+		}
+		sections = append(sections, &applySection{"dashboards", func(isDryRun bool) error {
+			return applyDashboards(mackerelclient.NewFromContext(c), files, isDryRun, isPrune)
+		}})
+	}
+	return sections, nil
+}
+
+func doApply(c *cli.Context) error {
+	filePath := c.String("file-path")
+	if filePath == "" {
+		cli.ShowCommandHelp(c, "apply")
+		return cli.NewExitError("specify a manifest with --file-path.", 1)
+	}
+
+	manifest, err := loadApplyManifest(filePath)
+	logger.DieIf(err)
+
+	dir, err := ioutil.TempDir("", "mkr-apply")
+	logger.DieIf(err)
+	defer os.RemoveAll(dir)
+
+	sections, err := applySections(c, manifest, filePath, dir, c.Bool("prune"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	if len(sections) == 0 {
+		logger.Log("info", "no resources are found in the manifest.")
+		return nil
+	}
+
+	// the plan is the dry run of the sections
+	for _, s := range sections {
+		logger.Log("info", fmt.Sprintf("Plan of the %s:", s.name))
+		if err := s.apply(true); err != nil {
+			return cli.NewExitError(fmt.Sprintf("failed to plan the %s: %s", s.name, err), 1)
+		}
+	}
+	if c.Bool("dry-run") {
+		return nil
+	}
+	if !c.Bool("force") && !prompter.YN("Apply the plan above?", false) {
+		return nil
+	}
+	for _, s := range sections {
+		logger.Log("info", fmt.Sprintf("Apply the %s:", s.name))
+		if err := s.apply(false); err != nil {
+			return cli.NewExitError(fmt.Sprintf("failed to apply the %s: %s", s.name, err), 1)
+		}
+	}
+	return nil
+}
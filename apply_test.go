@@ -0,0 +1,125 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+const testApplyManifest = `
+services:
+  - name: My-Service
+    roles:
+      - name: web
+channels:
+  - name: slack
+    type: slack
+    url: https://hooks.slack.com/services/xxx
+templates:
+  cpu:
+    type: host
+    metric: cpu%
+    operator: '>'
+monitors:
+  - name: cpu warning
+    extends: cpu
+    warning: 80
+dashboards:
+  - title: My Dashboard
+    urlPath: my-dashboard
+    widgets: []
+`
+
+func writeApplyManifest(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	filePath := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(filePath, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return filePath
+}
+
+func TestLoadApplyManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	filePath := writeApplyManifest(t, dir, "org.yaml", testApplyManifest)
+	manifest, err := loadApplyManifest(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.NotificationGroups != nil || manifest.Downtimes != nil {
+		t.Errorf("the sections not in the manifest should be nil: %+v", manifest)
+	}
+
+	monitors, err := manifest.monitors()
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []mackerel.Monitor{&mackerel.MonitorHostMetric{
+		Type:     "host",
+		Name:     "cpu warning",
+		Metric:   "cpu%",
+		Operator: ">",
+		Warning:  pfloat64(80),
+	}}
+	if !reflect.DeepEqual(monitors, expected) {
+		t.Errorf("monitors should be expanded with the templates: %+v", monitors[0])
+	}
+
+	files, err := manifest.dashboards(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 || files[0].dashboard.URLPath != "my-dashboard" || files[0].path != filePath+": dashboards[0]" {
+		t.Errorf("unexpected dashboards: %+v", files)
+	}
+
+	sections, err := applySections(nil, manifest, filePath, dir, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, s := range sections {
+		names = append(names, s.name)
+	}
+	if expected := []string{"services", "channels", "monitors", "dashboards"}; !reflect.DeepEqual(names, expected) {
+		t.Errorf("sections should be %v but got %v", expected, names)
+	}
+	data, err := ioutil.ReadFile(filepath.Join(dir, "channels.json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.HasPrefix(string(data), `{"channels":[{"name":"slack"`) {
+		t.Errorf("the channels should be written in the format of push: %s", data)
+	}
+}
+
+func TestLoadApplyManifest_JSON(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-apply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	manifest, err := loadApplyManifest(writeApplyManifest(t, dir, "org.json", `{"downtimes": [{"name": "maintenance"}]}`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(manifest.Downtimes) != `[{"name":"maintenance"}]` {
+		t.Errorf("unexpected downtimes: %s", manifest.Downtimes)
+	}
+
+	_, err = loadApplyManifest(writeApplyManifest(t, dir, "unknown.yaml", "alerts: []\n"))
+	if err == nil || !strings.Contains(err.Error(), `unknown field "alerts"`) {
+		t.Errorf("the unknown sections should be rejected but got %v", err)
+	}
+}
@@ -0,0 +1,214 @@
+package awsintegrations
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"sort"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+// awsIntegration represents an AWS integration setting.
+// See https://mackerel.io/api-docs/entry/aws-integration .
+type awsIntegration struct {
+	ID           string                            `json:"id,omitempty"`
+	Name         string                            `json:"name"`
+	Memo         string                            `json:"memo"`
+	Key          string                            `json:"key,omitempty"`
+	SecretKey    string                            `json:"secretKey,omitempty"`
+	RoleArn      string                            `json:"roleArn,omitempty"`
+	ExternalID   string                            `json:"externalId,omitempty"`
+	Region       string                            `json:"region"`
+	IncludedTags string                            `json:"includedTags"`
+	ExcludedTags string                            `json:"excludedTags"`
+	Services     map[string]*awsIntegrationService `json:"services"`
+}
+
+// awsIntegrationService represents the setting of an AWS service such as EC2 or RDS.
+type awsIntegrationService struct {
+	Enable              bool     `json:"enable"`
+	Role                *string  `json:"role"`
+	ExcludedMetrics     []string `json:"excludedMetrics"`
+	IncludedMetrics     []string `json:"includedMetrics,omitempty"`
+	RetireAutomatically bool     `json:"retireAutomatically,omitempty"`
+}
+
+// awsIntegrationsClient is the client of the AWS integration API, which mackerel-client-go does not support yet.
+type awsIntegrationsClient interface {
+	FindAWSIntegrations() ([]*awsIntegration, error)
+	FindAWSIntegration(id string) (*awsIntegration, error)
+	CreateAWSIntegration(param *awsIntegration) (*awsIntegration, error)
+	UpdateAWSIntegration(id string, param *awsIntegration) (*awsIntegration, error)
+	DeleteAWSIntegration(id string) (*awsIntegration, error)
+}
+
+type apiClient struct {
+	client *mackerel.Client
+}
+
+const awsIntegrationsPath = "/api/v0/aws-integrations"
+
+func (c *apiClient) FindAWSIntegrations() ([]*awsIntegration, error) {
+	var resp struct {
+		AWSIntegrations []*awsIntegration `json:"aws_integrations"`
+	}
+	if err := mackerelclient.RequestJSON(c.client, "GET", awsIntegrationsPath, url.Values{}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.AWSIntegrations, nil
+}
+
+func (c *apiClient) request(method, path string, body interface{}) (*awsIntegration, error) {
+	var a awsIntegration
+	if err := mackerelclient.RequestJSON(c.client, method, path, url.Values{}, body, &a); err != nil {
+		return nil, err
+	}
+	return &a, nil
+}
+
+func (c *apiClient) FindAWSIntegration(id string) (*awsIntegration, error) {
+	return c.request("GET", awsIntegrationsPath+"/"+id, nil)
+}
+
+func (c *apiClient) CreateAWSIntegration(param *awsIntegration) (*awsIntegration, error) {
+	return c.request("POST", awsIntegrationsPath, param)
+}
+
+func (c *apiClient) UpdateAWSIntegration(id string, param *awsIntegration) (*awsIntegration, error) {
+	return c.request("PUT", awsIntegrationsPath+"/"+id, param)
+}
+
+func (c *apiClient) DeleteAWSIntegration(id string) (*awsIntegration, error) {
+	return c.request("DELETE", awsIntegrationsPath+"/"+id, nil)
+}
+
+type appLogger interface {
+	Log(string, string)
+}
+
+type awsIntegrationsApp struct {
+	client    awsIntegrationsClient
+	logger    appLogger
+	outStream io.Writer
+}
+
+func (app *awsIntegrationsApp) list(output *format.Output) error {
+	integrations, err := app.client.FindAWSIntegrations()
+	if err != nil {
+		return err
+	}
+	return output.Print(app.outStream, integrations, func() *format.Table {
+		t := format.NewTable("ID", "NAME", "REGION", "SERVICES")
+		for _, a := range integrations {
+			t.Append(a.ID, a.Name, a.Region, strings.Join(enabledServices(a), ","))
+		}
+		return t
+	})
+}
+
+func enabledServices(a *awsIntegration) []string {
+	var services []string
+	for name, s := range a.Services {
+		if s != nil && s.Enable {
+			services = append(services, name)
+		}
+	}
+	sort.Strings(services)
+	return services
+}
+
+func (app *awsIntegrationsApp) get(id string) error {
+	a, err := app.client.FindAWSIntegration(id)
+	if err != nil {
+		return err
+	}
+	format.PrettyPrintJSON(app.outStream, a)
+	return nil
+}
+
+func loadAWSIntegration(filePath string) (*awsIntegration, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var a awsIntegration
+	if err := json.Unmarshal(data, &a); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	if a.Name == "" || a.Region == "" {
+		return nil, fmt.Errorf("failed to load %s: the name and the region are required", filePath)
+	}
+	return &a, nil
+}
+
+func (app *awsIntegrationsApp) create(filePath string) error {
+	a, err := loadAWSIntegration(filePath)
+	if err != nil {
+		return err
+	}
+	a.ID = ""
+	created, err := app.client.CreateAWSIntegration(a)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("created", fmt.Sprintf("%s (%s)", created.Name, created.ID))
+	return nil
+}
+
+type updateAWSIntegrationParam struct {
+	id       string
+	filePath string
+	dryRun   bool
+}
+
+func (app *awsIntegrationsApp) update(param updateAWSIntegrationParam) error {
+	a, err := loadAWSIntegration(param.filePath)
+	if err != nil {
+		return err
+	}
+	id := param.id
+	if id == "" {
+		id = a.ID
+	}
+	if id == "" {
+		return fmt.Errorf("the id of the AWS integration is not specified in the flag nor the file")
+	}
+	current, err := app.client.FindAWSIntegration(id)
+	if err != nil {
+		return err
+	}
+	diff := diffAWSIntegration(current, a)
+	if param.dryRun {
+		for _, d := range diff {
+			fmt.Fprintln(app.outStream, d)
+		}
+		return nil
+	}
+	if len(diff) == 0 {
+		app.logger.Log("info", fmt.Sprintf("%s (%s) is up to date.", current.Name, id))
+		return nil
+	}
+	a.ID = ""
+	updated, err := app.client.UpdateAWSIntegration(id, a)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("updated", fmt.Sprintf("%s (%s)", updated.Name, updated.ID))
+	return nil
+}
+
+func (app *awsIntegrationsApp) delete(id string) error {
+	a, err := app.client.DeleteAWSIntegration(id)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("deleted", fmt.Sprintf("%s (%s)", a.Name, a.ID))
+	return nil
+}
@@ -0,0 +1,149 @@
+package awsintegrations
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+)
+
+type testLogger struct {
+	w io.Writer
+}
+
+func (l *testLogger) Log(prefix, message string) {
+	fmt.Fprintln(l.w, prefix, message)
+}
+
+type fakeClient struct {
+	integrations []*awsIntegration
+	updated      []*awsIntegration
+}
+
+func (c *fakeClient) FindAWSIntegrations() ([]*awsIntegration, error) {
+	return c.integrations, nil
+}
+
+func (c *fakeClient) FindAWSIntegration(id string) (*awsIntegration, error) {
+	for _, a := range c.integrations {
+		if a.ID == id {
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("AWS integration not found: %s", id)
+}
+
+func (c *fakeClient) CreateAWSIntegration(param *awsIntegration) (*awsIntegration, error) {
+	a := *param
+	a.ID = "new"
+	return &a, nil
+}
+
+func (c *fakeClient) UpdateAWSIntegration(id string, param *awsIntegration) (*awsIntegration, error) {
+	c.updated = append(c.updated, param)
+	a := *param
+	a.ID = id
+	return &a, nil
+}
+
+func (c *fakeClient) DeleteAWSIntegration(id string) (*awsIntegration, error) {
+	return c.FindAWSIntegration(id)
+}
+
+func TestAPIClient(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.Method + " " + r.URL.Path {
+		case "GET /api/v0/aws-integrations":
+			fmt.Fprint(w, `{"aws_integrations": [{"id": "5DCWXqZmtwc", "name": "production", "memo": "", "key": null, "roleArn": "arn:aws:iam::123456789012:role/mackerel", "externalId": "xxxx", "region": "ap-northeast-1", "includedTags": "", "excludedTags": "", "services": {"EC2": {"enable": true, "role": "Blog: ec2", "excludedMetrics": []}}}]}`)
+		case "GET /api/v0/aws-integrations/5DCWXqZmtwc", "DELETE /api/v0/aws-integrations/5DCWXqZmtwc":
+			fmt.Fprint(w, `{"id": "5DCWXqZmtwc", "name": "production", "region": "ap-northeast-1", "services": {}}`)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	client, _ := mackerel.NewClientWithOptions("dummy", ts.URL, false)
+	c := &apiClient{client}
+
+	integrations, err := c.FindAWSIntegrations()
+	assert.NoError(t, err)
+	assert.Len(t, integrations, 1)
+	assert.Equal(t, "arn:aws:iam::123456789012:role/mackerel", integrations[0].RoleArn)
+	assert.Equal(t, "Blog: ec2", *integrations[0].Services["EC2"].Role)
+
+	a, err := c.FindAWSIntegration("5DCWXqZmtwc")
+	assert.NoError(t, err)
+	assert.Equal(t, "production", a.Name)
+
+	a, err = c.DeleteAWSIntegration("5DCWXqZmtwc")
+	assert.NoError(t, err)
+	assert.Equal(t, "5DCWXqZmtwc", a.ID)
+}
+
+func TestAWSIntegrationsApp(t *testing.T) {
+	role := "Blog: rds"
+	client := &fakeClient{integrations: []*awsIntegration{
+		{
+			ID:     "5DCWXqZmtwc",
+			Name:   "production",
+			Region: "ap-northeast-1",
+			Services: map[string]*awsIntegrationService{
+				"RDS": {Enable: true, Role: &role, ExcludedMetrics: []string{"rds.cpu.used"}},
+				"EC2": {Enable: true, ExcludedMetrics: []string{}},
+				"ELB": {Enable: false, ExcludedMetrics: []string{}},
+			},
+		},
+	}}
+	out := new(bytes.Buffer)
+	app := &awsIntegrationsApp{client: client, logger: &testLogger{out}, outStream: out}
+
+	assert.NoError(t, app.list(&format.Output{Format: format.OutputTable}))
+	assert.Equal(t, `ID           NAME        REGION          SERVICES
+5DCWXqZmtwc  production  ap-northeast-1  EC2,RDS
+`, out.String())
+
+	dir, err := ioutil.TempDir("", "mkr-aws-integrations")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "aws-integration.json")
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(`{
+		"id": "5DCWXqZmtwc", "name": "production", "region": "ap-northeast-1",
+		"services": {
+			"RDS": {"enable": true, "role": "Blog: rds", "excludedMetrics": ["rds.memory.used"]},
+			"EC2": {"enable": true, "excludedMetrics": []}
+		}
+	}`), 0644))
+
+	out.Reset()
+	assert.NoError(t, app.update(updateAWSIntegrationParam{filePath: filePath, dryRun: true}))
+	assert.Equal(t, `RDS: excludedMetrics + rds.memory.used
+RDS: excludedMetrics - rds.cpu.used
+`, out.String())
+	assert.Empty(t, client.updated)
+
+	out.Reset()
+	assert.NoError(t, app.update(updateAWSIntegrationParam{filePath: filePath}))
+	assert.Equal(t, "updated production (5DCWXqZmtwc)\n", out.String())
+	assert.Len(t, client.updated, 1)
+	assert.Equal(t, "", client.updated[0].ID)
+
+	out.Reset()
+	assert.NoError(t, app.create(filePath))
+	assert.Equal(t, "created production (new)\n", out.String())
+
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(`{"name": "production", "region": "ap-northeast-1"}`), 0644))
+	assert.EqualError(t, app.update(updateAWSIntegrationParam{filePath: filePath}),
+		"the id of the AWS integration is not specified in the flag nor the file")
+}
@@ -0,0 +1,151 @@
+package awsintegrations
+
+import (
+	"os"
+
+	"github.com/Songmu/prompter"
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+var awsIntegrationsListFlags = []cli.Flag{
+	cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+}
+
+// Command is the definition of aws-integrations subcommand
+var Command = cli.Command{
+	Name:      "aws-integrations",
+	Usage:     "List AWS integration settings",
+	ArgsUsage: "[--output | -o <format>]",
+	Description: `
+    List the AWS integration settings with their enabled services. With a subcommand, manipulate the AWS integration settings.
+    Requests APIs under "/api/v0/aws-integrations". See https://mackerel.io/api-docs/entry/aws-integration .
+`,
+	Action: doAWSIntegrations,
+	Flags:  awsIntegrationsListFlags,
+	Subcommands: []cli.Command{
+		{
+			Name:      "list",
+			Usage:     "List AWS integration settings",
+			ArgsUsage: "[--output | -o <format>]",
+			Description: `
+    List the AWS integration settings with their enabled services.
+`,
+			Action: doAWSIntegrations,
+			Flags:  awsIntegrationsListFlags,
+		},
+		{
+			Name:      "get",
+			Usage:     "Show an AWS integration setting",
+			ArgsUsage: "<awsIntegrationId>",
+			Description: `
+    Show the AWS integration setting in JSON, which can be modified and applied by "mkr aws-integrations update".
+    Requests "GET /api/v0/aws-integrations/<awsIntegrationId>".
+`,
+			Action: doGetAWSIntegration,
+		},
+		{
+			Name:      "create",
+			Usage:     "Create an AWS integration setting",
+			ArgsUsage: "--file-path | -F <file>",
+			Description: `
+    Create a new AWS integration setting from the JSON file.
+    Requests "POST /api/v0/aws-integrations".
+`,
+			Action: doCreateAWSIntegration,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "The JSON file of the AWS integration setting"},
+			},
+		},
+		{
+			Name:      "update",
+			Usage:     "Update an AWS integration setting",
+			ArgsUsage: "--file-path | -F <file> [--id <awsIntegrationId>] [--dry-run | -d]",
+			Description: `
+    Update the AWS integration setting by the JSON file. The id in the file is used if --id is not specified.
+    With --dry-run, the differences of the settings including the excluded and included metrics of each service are shown.
+    Requests "PUT /api/v0/aws-integrations/<awsIntegrationId>".
+`,
+			Action: doUpdateAWSIntegration,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "The JSON file of the AWS integration setting"},
+				cli.StringFlag{Name: "id", Value: "", Usage: "The ID of the AWS integration setting"},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show the differences, but not apply them"},
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete an AWS integration setting",
+			ArgsUsage: "[--force] <awsIntegrationId>",
+			Description: `
+    Delete the AWS integration setting.
+    Requests "DELETE /api/v0/aws-integrations/<awsIntegrationId>".
+`,
+			Action: doDeleteAWSIntegration,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "force", Usage: "Delete the AWS integration setting without confirmation"},
+			},
+		},
+	},
+}
+
+func newAWSIntegrationsApp(c *cli.Context) *awsIntegrationsApp {
+	return &awsIntegrationsApp{
+		client:    &apiClient{mackerelclient.NewFromContext(c)},
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}
+}
+
+func requireArgs(c *cli.Context, n int) {
+	if len(c.Args()) != n {
+		cli.ShowCommandHelp(c, c.Command.Name)
+		os.Exit(1)
+	}
+}
+
+func doAWSIntegrations(c *cli.Context) error {
+	output, err := format.OutputFromContext(c, "table")
+	if err != nil {
+		return err
+	}
+	return newAWSIntegrationsApp(c).list(output)
+}
+
+func doGetAWSIntegration(c *cli.Context) error {
+	requireArgs(c, 1)
+	return newAWSIntegrationsApp(c).get(c.Args().Get(0))
+}
+
+func doCreateAWSIntegration(c *cli.Context) error {
+	if c.String("file-path") == "" {
+		cli.ShowCommandHelp(c, "create")
+		os.Exit(1)
+	}
+	return newAWSIntegrationsApp(c).create(c.String("file-path"))
+}
+
+func doUpdateAWSIntegration(c *cli.Context) error {
+	if c.String("file-path") == "" {
+		cli.ShowCommandHelp(c, "update")
+		os.Exit(1)
+	}
+	return newAWSIntegrationsApp(c).update(updateAWSIntegrationParam{
+		id:       c.String("id"),
+		filePath: c.String("file-path"),
+		dryRun:   c.Bool("dry-run"),
+	})
+}
+
+func doDeleteAWSIntegration(c *cli.Context) error {
+	requireArgs(c, 1)
+	id := c.Args().Get(0)
+	if !c.Bool("force") && !prompter.YN("Delete the AWS integration setting "+id+".\nAre you sure?", false) {
+		logger.Log("", "deletion is canceled.")
+		return nil
+	}
+	return newAWSIntegrationsApp(c).delete(id)
+}
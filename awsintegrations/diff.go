@@ -0,0 +1,101 @@
+package awsintegrations
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diffAWSIntegration describes the differences of the settings, especially of the metrics of each service.
+// The credentials are not compared since the API does not return the secret key.
+func diffAWSIntegration(current, desired *awsIntegration) []string {
+	var diff []string
+	field := func(name, x, y string) {
+		if x != y {
+			diff = append(diff, fmt.Sprintf("%s: %q -> %q", name, x, y))
+		}
+	}
+	field("name", current.Name, desired.Name)
+	field("memo", current.Memo, desired.Memo)
+	field("region", current.Region, desired.Region)
+	field("includedTags", current.IncludedTags, desired.IncludedTags)
+	field("excludedTags", current.ExcludedTags, desired.ExcludedTags)
+
+	names := map[string]bool{}
+	for name := range current.Services {
+		names[name] = true
+	}
+	for name := range desired.Services {
+		names[name] = true
+	}
+	var sorted []string
+	for name := range names {
+		sorted = append(sorted, name)
+	}
+	sort.Strings(sorted)
+	for _, name := range sorted {
+		diff = append(diff, diffAWSIntegrationService(name, current.Services[name], desired.Services[name])...)
+	}
+	return diff
+}
+
+func diffAWSIntegrationService(name string, current, desired *awsIntegrationService) []string {
+	if current == nil {
+		current = &awsIntegrationService{}
+	}
+	if desired == nil {
+		desired = &awsIntegrationService{}
+	}
+	var diff []string
+	if current.Enable != desired.Enable {
+		diff = append(diff, fmt.Sprintf("%s: enable %t -> %t", name, current.Enable, desired.Enable))
+	}
+	if x, y := stringValue(current.Role), stringValue(desired.Role); x != y {
+		diff = append(diff, fmt.Sprintf("%s: role %q -> %q", name, x, y))
+	}
+	if current.RetireAutomatically != desired.RetireAutomatically {
+		diff = append(diff, fmt.Sprintf("%s: retireAutomatically %t -> %t", name, current.RetireAutomatically, desired.RetireAutomatically))
+	}
+	diff = append(diff, diffMetrics(name+": excludedMetrics", current.ExcludedMetrics, desired.ExcludedMetrics)...)
+	diff = append(diff, diffMetrics(name+": includedMetrics", current.IncludedMetrics, desired.IncludedMetrics)...)
+	return diff
+}
+
+// diffMetrics describes the added metrics with "+" and the removed ones with "-".
+func diffMetrics(prefix string, current, desired []string) []string {
+	var added, removed []string
+	for _, m := range desired {
+		if !containsString(current, m) {
+			added = append(added, m)
+		}
+	}
+	for _, m := range current {
+		if !containsString(desired, m) {
+			removed = append(removed, m)
+		}
+	}
+	var diff []string
+	if len(added) > 0 {
+		diff = append(diff, fmt.Sprintf("%s + %s", prefix, strings.Join(added, ",")))
+	}
+	if len(removed) > 0 {
+		diff = append(diff, fmt.Sprintf("%s - %s", prefix, strings.Join(removed, ",")))
+	}
+	return diff
+}
+
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
+	}
+	return *s
+}
+
+func containsString(xs []string, x string) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
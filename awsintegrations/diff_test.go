@@ -0,0 +1,41 @@
+package awsintegrations
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffAWSIntegration(t *testing.T) {
+	role := "Blog: ec2"
+	current := &awsIntegration{
+		Name:         "production",
+		Region:       "ap-northeast-1",
+		IncludedTags: "env:production",
+		Services: map[string]*awsIntegrationService{
+			"EC2":        {Enable: true, ExcludedMetrics: []string{"ec2.cpu.used"}},
+			"CloudFront": {Enable: true, ExcludedMetrics: []string{}, IncludedMetrics: []string{"cloudfront.requests.request"}},
+		},
+	}
+	desired := &awsIntegration{
+		Name:         "production",
+		Memo:         "managed by mkr",
+		Region:       "ap-northeast-1",
+		IncludedTags: "env:production",
+		Services: map[string]*awsIntegrationService{
+			"EC2":        {Enable: true, Role: &role, ExcludedMetrics: []string{}, RetireAutomatically: true},
+			"CloudFront": {Enable: true, ExcludedMetrics: []string{}, IncludedMetrics: []string{"cloudfront.requests.request", "cloudfront.transfer.download"}},
+			"RDS":        {Enable: true, ExcludedMetrics: []string{"rds.cpu.used"}},
+		},
+	}
+	assert.Equal(t, []string{
+		`memo: "" -> "managed by mkr"`,
+		"CloudFront: includedMetrics + cloudfront.transfer.download",
+		`EC2: role "" -> "Blog: ec2"`,
+		"EC2: retireAutomatically false -> true",
+		"EC2: excludedMetrics - ec2.cpu.used",
+		"RDS: enable false -> true",
+		"RDS: excludedMetrics + rds.cpu.used",
+	}, diffAWSIntegration(current, desired))
+	assert.Empty(t, diffAWSIntegration(current, current))
+}
@@ -0,0 +1,411 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandBackup = cli.Command{
+	Name:      "backup",
+	Usage:     "Back up the configuration of the organization",
+	ArgsUsage: "--out | -o <file> [--resources <resources>]",
+	Description: `
+    Save the services with the roles, the channels, the monitors, the notification groups, the downtimes,
+    the dashboards and the AWS integration settings into a gzipped tar archive, which can be restored by "mkr restore".
+    Each resource type is saved in a JSON file in the archive, such as monitors.json in the format of "mkr monitors pull".
+    The secret access keys of the AWS integration settings are not included, since the API does not return them.
+`,
+	Action: doBackup,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "out, o", Value: "", Usage: "The archive file to write (e.g. backup.tar.gz)"},
+		cli.StringFlag{Name: "resources", Value: strings.Join(backupResources, ","), Usage: "Comma separated resource types to back up"},
+	},
+}
+
+// backupResources are the resources in the backup archive, in the order to be restored
+// so that the referred resources are restored first.
+var backupResources = []string{
+	"services",
+	"channels",
+	"monitors",
+	"notification-groups",
+	"downtimes",
+	"dashboards",
+	"aws-integrations",
+}
+
+// backupManifestFile is the file in the archive describing the backup
+const backupManifestFile = "manifest.json"
+
+const backupVersion = 1
+
+type backupManifest struct {
+	Version   int            `json:"version"`
+	CreatedAt time.Time      `json:"createdAt"`
+	Resources map[string]int `json:"resources"`
+}
+
+type backupService struct {
+	Name  string           `json:"name"`
+	Memo  string           `json:"memo,omitempty"`
+	Roles []*mackerel.Role `json:"roles"`
+}
+
+// orgBackup is the configuration of the organization in the backup archive.
+type orgBackup struct {
+	// the resource types in the backup
+	included map[string]bool
+
+	Services           []*backupService
+	Channels           []*mackerel.Channel
+	Monitors           []mackerel.Monitor
+	NotificationGroups []*mackerel.NotificationGroup
+	Downtimes          []*mackerel.Downtime
+	Dashboards         []*mackerel.Dashboard
+	AWSIntegrations    []json.RawMessage
+}
+
+// backupFile returns the file name and the key of the resources in the archive
+func backupFile(resource string) (string, string) {
+	switch resource {
+	case "notification-groups":
+		return "notification-groups.json", "notificationGroups"
+	case "aws-integrations":
+		return "aws-integrations.json", "awsIntegrations"
+	default:
+		return resource + ".json", resource
+	}
+}
+
+type backupClient interface {
+	FindServices() ([]*mackerel.Service, error)
+	FindRoles(serviceName string) ([]*mackerel.Role, error)
+	FindChannels() ([]*mackerel.Channel, error)
+	FindMonitors() ([]mackerel.Monitor, error)
+	FindNotificationGroups() ([]*mackerel.NotificationGroup, error)
+	FindDowntimes() ([]*mackerel.Downtime, error)
+	FindDashboards() ([]*mackerel.Dashboard, error)
+	FindDashboard(dashboardID string) (*mackerel.Dashboard, error)
+	FindAWSIntegrations() ([]json.RawMessage, error)
+}
+
+// backupAPIClient adds the AWS integration API, which mackerel-client-go does not support yet.
+type backupAPIClient struct {
+	*mackerel.Client
+}
+
+const awsIntegrationsPath = "/api/v0/aws-integrations"
+
+func (c backupAPIClient) FindAWSIntegrations() ([]json.RawMessage, error) {
+	var resp struct {
+		AWSIntegrations []json.RawMessage `json:"aws_integrations"`
+	}
+	if err := mackerelclient.RequestJSON(c.Client, "GET", awsIntegrationsPath, url.Values{}, nil, &resp); err != nil {
+		return nil, err
+	}
+	return resp.AWSIntegrations, nil
+}
+
+func (c backupAPIClient) CreateAWSIntegration(param json.RawMessage) (string, error) {
+	var resp struct {
+		ID string `json:"id"`
+	}
+	if err := mackerelclient.RequestJSON(c.Client, "POST", awsIntegrationsPath, url.Values{}, param, &resp); err != nil {
+		return "", err
+	}
+	return resp.ID, nil
+}
+
+func (c backupAPIClient) UpdateAWSIntegration(id string, param json.RawMessage) error {
+	return mackerelclient.RequestJSON(c.Client, "PUT", awsIntegrationsPath+"/"+id, url.Values{}, param, nil)
+}
+
+// fetchOrgBackup fetches the resources of the organization
+func fetchOrgBackup(client backupClient, resources []string) (*orgBackup, error) {
+	b := &orgBackup{included: map[string]bool{}}
+	for _, r := range resources {
+		b.included[r] = true
+		var err error
+		switch r {
+		case "services":
+			b.Services, err = fetchBackupServices(client)
+		case "channels":
+			b.Channels, err = client.FindChannels()
+		case "monitors":
+			b.Monitors, err = client.FindMonitors()
+		case "notification-groups":
+			b.NotificationGroups, err = client.FindNotificationGroups()
+		case "downtimes":
+			b.Downtimes, err = client.FindDowntimes()
+		case "dashboards":
+			b.Dashboards, err = fetchBackupDashboards(client)
+		case "aws-integrations":
+			b.AWSIntegrations, err = client.FindAWSIntegrations()
+		default:
+			return nil, fmt.Errorf("unknown resource: %s (should be one of %v)", r, backupResources)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch %s: %s", r, err)
+		}
+	}
+	return b, nil
+}
+
+func fetchBackupServices(client backupClient) ([]*backupService, error) {
+	services, err := client.FindServices()
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	bs := make([]*backupService, 0, len(services))
+	for _, s := range services {
+		roles, err := client.FindRoles(s.Name)
+		if err != nil {
+			return nil, err
+		}
+		sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+		bs = append(bs, &backupService{Name: s.Name, Memo: s.Memo, Roles: roles})
+	}
+	return bs, nil
+}
+
+func fetchBackupDashboards(client backupClient) ([]*mackerel.Dashboard, error) {
+	dashboards, err := client.FindDashboards()
+	if err != nil {
+		return nil, err
+	}
+	ds := make([]*mackerel.Dashboard, 0, len(dashboards))
+	for _, d := range dashboards {
+		if d.IsLegacy {
+			logger.Log("warning", fmt.Sprintf("skip the legacy dashboard %s (%s)", d.Title, d.ID))
+			continue
+		}
+		// the list of the dashboards does not have the widgets
+		dashboard, err := client.FindDashboard(d.ID)
+		if err != nil {
+			return nil, err
+		}
+		ds = append(ds, dashboard)
+	}
+	return ds, nil
+}
+
+// resource returns the resources of the type and the number of them.
+// It reports false if the resources are not in the backup.
+func (b *orgBackup) resource(r string) (interface{}, int, bool) {
+	if !b.included[r] {
+		return nil, 0, false
+	}
+	switch r {
+	case "services":
+		return b.Services, len(b.Services), true
+	case "channels":
+		return b.Channels, len(b.Channels), true
+	case "monitors":
+		return b.Monitors, len(b.Monitors), true
+	case "notification-groups":
+		return b.NotificationGroups, len(b.NotificationGroups), true
+	case "downtimes":
+		return b.Downtimes, len(b.Downtimes), true
+	case "dashboards":
+		return b.Dashboards, len(b.Dashboards), true
+	case "aws-integrations":
+		return b.AWSIntegrations, len(b.AWSIntegrations), true
+	}
+	return nil, 0, false
+}
+
+// writeBackupArchive writes the backup as a gzipped tar archive
+func writeBackupArchive(w io.Writer, b *orgBackup, now time.Time) error {
+	gw := gzip.NewWriter(w)
+	tw := tar.NewWriter(gw)
+	writeFile := func(name string, v interface{}) error {
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			return err
+		}
+		data = append(data, '\n')
+		if err := tw.WriteHeader(&tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(data)),
+			ModTime: now,
+		}); err != nil {
+			return err
+		}
+		_, err = tw.Write(data)
+		return err
+	}
+
+	manifest := backupManifest{Version: backupVersion, CreatedAt: now, Resources: map[string]int{}}
+	for _, r := range backupResources {
+		v, n, ok := b.resource(r)
+		if !ok {
+			continue
+		}
+		name, key := backupFile(r)
+		if err := writeFile(name, map[string]interface{}{key: v}); err != nil {
+			return err
+		}
+		manifest.Resources[r] = n
+	}
+	if err := writeFile(backupManifestFile, manifest); err != nil {
+		return err
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gw.Close()
+}
+
+// readBackupArchive reads the backup archive written by writeBackupArchive
+func readBackupArchive(r io.Reader) (*backupManifest, *orgBackup, error) {
+	gr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer gr.Close()
+	files := map[string][]byte{}
+	tr := tar.NewReader(gr)
+	for {
+		h, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		if files[h.Name], err = ioutil.ReadAll(tr); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	data, ok := files[backupManifestFile]
+	if !ok {
+		return nil, nil, fmt.Errorf("%s is not found in the archive", backupManifestFile)
+	}
+	var manifest backupManifest
+	if err := json.Unmarshal(data, &manifest); err != nil {
+		return nil, nil, fmt.Errorf("failed to load %s: %s", backupManifestFile, err)
+	}
+	if manifest.Version != backupVersion {
+		return nil, nil, fmt.Errorf("unsupported backup version: %d", manifest.Version)
+	}
+
+	b := &orgBackup{included: map[string]bool{}}
+	for _, r := range backupResources {
+		name, key := backupFile(r)
+		data, ok := files[name]
+		if !ok {
+			continue
+		}
+		if err := b.decode(r, key, data); err != nil {
+			return nil, nil, fmt.Errorf("failed to load %s: %s", name, err)
+		}
+	}
+	return &manifest, b, nil
+}
+
+func (b *orgBackup) decode(resource, key string, data []byte) error {
+	b.included[resource] = true
+	if resource == "monitors" {
+		monitors, err := decodeMonitors(bytes.NewReader(data))
+		if err != nil {
+			return err
+		}
+		b.Monitors = monitors
+		return nil
+	}
+	var v interface{}
+	switch resource {
+	case "services":
+		v = &b.Services
+	case "channels":
+		v = &b.Channels
+	case "notification-groups":
+		v = &b.NotificationGroups
+	case "downtimes":
+		v = &b.Downtimes
+	case "dashboards":
+		v = &b.Dashboards
+	case "aws-integrations":
+		v = &b.AWSIntegrations
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return err
+	}
+	if raw, ok := fields[key]; ok {
+		return json.Unmarshal(raw, v)
+	}
+	return nil
+}
+
+func splitResources(s string) []string {
+	var resources []string
+	for _, r := range strings.Split(s, ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			resources = append(resources, r)
+		}
+	}
+	return resources
+}
+
+func doBackup(c *cli.Context) error {
+	out := c.String("out")
+	if out == "" {
+		cli.ShowCommandHelp(c, "backup")
+		return cli.NewExitError("specify the archive file with --out.", 1)
+	}
+
+	client := backupAPIClient{mackerelclient.NewFromContext(c)}
+	b, err := fetchOrgBackup(client, splitResources(c.String("resources")))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	var buf bytes.Buffer
+	if err := writeBackupArchive(&buf, b, time.Now()); err != nil {
+		return err
+	}
+	// write the archive after fetching all the resources, not to leave a broken archive
+	if err := ioutil.WriteFile(out, buf.Bytes(), 0600); err != nil {
+		return err
+	}
+	for _, r := range backupResources {
+		if _, n, ok := b.resource(r); ok {
+			logger.Log("info", fmt.Sprintf("%d %s", n, r))
+		}
+	}
+	logger.Log("created", out)
+	return nil
+}
+
+// openBackupArchive reads the backup archive file
+func openBackupArchive(filePath string) (*backupManifest, *orgBackup, error) {
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+	manifest, b, err := readBackupArchive(f)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read '%s': %s", filePath, err)
+	}
+	return manifest, b, nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type fakeRestoreClient struct {
+	*fakeCopyClient
+	notificationGroups []*mackerel.NotificationGroup
+	downtimes          []*mackerel.Downtime
+	awsIntegrations    []json.RawMessage
+}
+
+func (c *fakeRestoreClient) UpdateMonitor(monitorID string, param mackerel.Monitor) (mackerel.Monitor, error) {
+	c.requests = append(c.requests, fmt.Sprintf("update monitor %s %s", monitorID, param.MonitorName()))
+	return param, nil
+}
+
+func (c *fakeRestoreClient) FindNotificationGroups() ([]*mackerel.NotificationGroup, error) {
+	return c.notificationGroups, nil
+}
+
+func (c *fakeRestoreClient) CreateNotificationGroup(param *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error) {
+	var monitors []string
+	for _, m := range param.Monitors {
+		monitors = append(monitors, m.ID)
+	}
+	c.requests = append(c.requests, fmt.Sprintf("notification group %s channels=%v groups=%v monitors=%v",
+		param.Name, param.ChildChannelIDs, param.ChildNotificationGroupIDs, monitors))
+	g := *param
+	g.ID = "new-" + param.Name
+	return &g, nil
+}
+
+func (c *fakeRestoreClient) UpdateNotificationGroup(id string, param *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error) {
+	c.requests = append(c.requests, fmt.Sprintf("update notification group %s %s", id, param.Name))
+	return param, nil
+}
+
+func (c *fakeRestoreClient) FindDowntimes() ([]*mackerel.Downtime, error) {
+	return c.downtimes, nil
+}
+
+func (c *fakeRestoreClient) CreateDowntime(param *mackerel.Downtime) (*mackerel.Downtime, error) {
+	c.requests = append(c.requests, fmt.Sprintf("downtime %s monitors=%v", param.Name, param.MonitorScopes))
+	d := *param
+	d.ID = "new-" + param.Name
+	return &d, nil
+}
+
+func (c *fakeRestoreClient) UpdateDowntime(downtimeID string, param *mackerel.Downtime) (*mackerel.Downtime, error) {
+	c.requests = append(c.requests, fmt.Sprintf("update downtime %s %s", downtimeID, param.Name))
+	return param, nil
+}
+
+func (c *fakeRestoreClient) UpdateDashboard(dashboardID string, param *mackerel.Dashboard) (*mackerel.Dashboard, error) {
+	c.requests = append(c.requests, fmt.Sprintf("update dashboard %s %s", dashboardID, param.URLPath))
+	return param, nil
+}
+
+func (c *fakeRestoreClient) FindAWSIntegrations() ([]json.RawMessage, error) {
+	return c.awsIntegrations, nil
+}
+
+func (c *fakeRestoreClient) CreateAWSIntegration(param json.RawMessage) (string, error) {
+	c.requests = append(c.requests, "aws integration "+string(param))
+	return "new-aws", nil
+}
+
+func (c *fakeRestoreClient) UpdateAWSIntegration(id string, param json.RawMessage) error {
+	c.requests = append(c.requests, fmt.Sprintf("update aws integration %s %s", id, param))
+	return nil
+}
+
+func newBackupSource() *fakeRestoreClient {
+	return &fakeRestoreClient{
+		fakeCopyClient: &fakeCopyClient{
+			services: []*mackerel.Service{{Name: "blog", Memo: "my blog", Roles: []string{"app"}}},
+			roles: map[string][]*mackerel.Role{
+				"blog": {{Name: "app", Memo: "application"}},
+			},
+			channels: []*mackerel.Channel{
+				{ID: "ch1", Name: "mail", Type: "email", Emails: &[]string{"ops@example.com"}},
+			},
+			monitors: []mackerel.Monitor{
+				&mackerel.MonitorConnectivity{ID: "mon1", Name: "connectivity", Type: "connectivity", Scopes: []string{"blog"}},
+				&mackerel.MonitorHostMetric{ID: "mon2", Name: "cpu", Type: "host", Metric: "cpu%", Operator: ">", Duration: 1},
+			},
+			dashboards: []*mackerel.Dashboard{
+				{ID: "dash0", Title: "Legacy", URLPath: "legacy", IsLegacy: true},
+				{ID: "dash1", Title: "Blog", URLPath: "blog", Widgets: []mackerel.Widget{
+					{Type: "markdown", Title: "memo", Markdown: "# blog"},
+				}},
+			},
+		},
+		notificationGroups: []*mackerel.NotificationGroup{
+			{ID: "ng1", Name: "parent", ChildNotificationGroupIDs: []string{"ng2"}, ChildChannelIDs: []string{}},
+			{ID: "ng2", Name: "child", ChildChannelIDs: []string{"ch1", "ch0"},
+				Monitors: []*mackerel.NotificationGroupMonitor{{ID: "mon1"}, {ID: "mon2", SkipDefault: true}}},
+		},
+		downtimes: []*mackerel.Downtime{
+			{ID: "dt1", Name: "maintenance", Start: 1700000000, Duration: 60, MonitorScopes: []string{"mon2"}},
+		},
+		awsIntegrations: []json.RawMessage{
+			json.RawMessage(`{"id":"aws1","name":"production","region":"ap-northeast-1"}`),
+		},
+	}
+}
+
+func TestBackupArchive(t *testing.T) {
+	b, err := fetchOrgBackup(newBackupSource(), backupResources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(b.Dashboards) != 1 {
+		t.Errorf("the legacy dashboards should not be backed up: %d", len(b.Dashboards))
+	}
+
+	var buf bytes.Buffer
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	if err := writeBackupArchive(&buf, b, now); err != nil {
+		t.Fatal(err)
+	}
+	manifest, restored, err := readBackupArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if manifest.Version != backupVersion || !manifest.CreatedAt.Equal(now) {
+		t.Errorf("unexpected manifest: %+v", manifest)
+	}
+	expected := map[string]int{
+		"services": 1, "channels": 1, "monitors": 2, "notification-groups": 2,
+		"downtimes": 1, "dashboards": 1, "aws-integrations": 1,
+	}
+	if !reflect.DeepEqual(manifest.Resources, expected) {
+		t.Errorf("resources in the manifest should be %v but got %v", expected, manifest.Resources)
+	}
+	for _, r := range backupResources {
+		v, _, _ := b.resource(r)
+		w, _, ok := restored.resource(r)
+		if !ok {
+			t.Errorf("%s should be in the archive", r)
+		}
+		// compare in JSON since the archive is indented
+		expected, _ := json.Marshal(v)
+		got, _ := json.Marshal(w)
+		if string(expected) != string(got) {
+			t.Errorf("%s should be restored as\n  %s\nbut got\n  %s", r, expected, got)
+		}
+	}
+
+	b, err = fetchOrgBackup(&fakeRestoreClient{fakeCopyClient: &fakeCopyClient{}}, []string{"monitors"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	buf.Reset()
+	if err := writeBackupArchive(&buf, b, now); err != nil {
+		t.Fatal(err)
+	}
+	_, restored, err = readBackupArchive(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, n, ok := restored.resource("monitors"); !ok || n != 0 {
+		t.Errorf("the empty monitors should be in the archive")
+	}
+	if _, _, ok := restored.resource("channels"); ok {
+		t.Errorf("the channels should not be in the archive")
+	}
+}
+
+func TestOrgRestorer_restore(t *testing.T) {
+	b, err := fetchOrgBackup(newBackupSource(), backupResources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := &fakeRestoreClient{
+		fakeCopyClient: &fakeCopyClient{
+			monitors: []mackerel.Monitor{
+				&mackerel.MonitorHostMetric{ID: "mon9", Name: "cpu", Type: "host", Metric: "cpu%", Operator: ">", Duration: 3},
+			},
+		},
+		downtimes: []*mackerel.Downtime{{ID: "dt9", Name: "maintenance", Start: 1700000000, Duration: 60, MonitorScopes: []string{"mon9"}}},
+		awsIntegrations: []json.RawMessage{
+			json.RawMessage(`{"id":"aws9","name":"production","region":"us-east-1"}`),
+		},
+	}
+	or := newOrgRestorer(dst, b, false, false)
+	if err := or.restore(backupResources); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"service blog",
+		"role blog:app",
+		`channel mail id="" users=[]`,
+		`monitor connectivity id=""`,
+		"notification group child channels=[new-mail] groups=[] monitors=[new-connectivity mon9]",
+		"notification group parent channels=[] groups=[new-child] monitors=[]",
+		`dashboard blog id="" widgets=1`,
+	}
+	if !reflect.DeepEqual(dst.requests, expected) {
+		t.Errorf("requests should be\n  %v\nbut got\n  %v", strings.Join(expected, "\n  "), strings.Join(dst.requests, "\n  "))
+	}
+	if or.created != 7 || or.skipped != 3 || or.failed != 0 {
+		t.Errorf("unexpected counts: created=%d skipped=%d failed=%d", or.created, or.skipped, or.failed)
+	}
+}
+
+func TestOrgRestorer_restore_overwrite(t *testing.T) {
+	b, err := fetchOrgBackup(newBackupSource(), backupResources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := newBackupSource()
+	dst.monitors[1] = &mackerel.MonitorHostMetric{ID: "mon2", Name: "cpu", Type: "host", Metric: "cpu%", Operator: ">", Duration: 3}
+	dst.awsIntegrations[0] = json.RawMessage(`{"id":"aws1","name":"production","region":"us-east-1"}`)
+	or := newOrgRestorer(dst, b, true, false)
+	if err := or.restore([]string{"monitors", "downtimes", "aws-integrations"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"update monitor mon2 cpu",
+		`update aws integration aws1 {"name":"production","region":"ap-northeast-1"}`,
+	}
+	if !reflect.DeepEqual(dst.requests, expected) {
+		t.Errorf("requests should be\n  %v\nbut got\n  %v", strings.Join(expected, "\n  "), strings.Join(dst.requests, "\n  "))
+	}
+	if or.updated != 2 || or.skipped != 2 {
+		t.Errorf("unexpected counts: updated=%d skipped=%d", or.updated, or.skipped)
+	}
+}
+
+func TestOrgRestorer_restore_dryRun(t *testing.T) {
+	b, err := fetchOrgBackup(newBackupSource(), backupResources)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dst := &fakeRestoreClient{fakeCopyClient: &fakeCopyClient{}}
+	or := newOrgRestorer(dst, b, false, true)
+	if err := or.restore(backupResources); err != nil {
+		t.Fatal(err)
+	}
+	if len(dst.requests) > 0 {
+		t.Errorf("nothing should be restored with dry-run: %v", dst.requests)
+	}
+	if or.created != 10 {
+		t.Errorf("unexpected counts: created=%d", or.created)
+	}
+
+	b.included = map[string]bool{"monitors": true}
+	if err := or.restore([]string{"channels"}); err == nil || err.Error() != "channels are not in the backup" {
+		t.Errorf("the resources not in the backup should be rejected but got %v", err)
+	}
+}
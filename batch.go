@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/urfave/cli"
+)
+
+// The exit codes of the commands which operate on multiple items.
+// The commands continue on the failures of the items, and exit with
+// exitCodePartialFailure when some of the items are failed.
+const (
+	exitCodeFailure        = 1
+	exitCodePartialFailure = 2
+)
+
+// batchError returns the error summarizing the failures of the operations on the items,
+// or nil if no items are failed. For example, verb is "close" and items is "alert(s)".
+func batchError(verb string, failed, total int, items string) error {
+	if failed == 0 {
+		return nil
+	}
+	code := exitCodePartialFailure
+	if failed >= total {
+		code = exitCodeFailure
+	}
+	return cli.NewExitError(fmt.Sprintf("failed to %s %d of %d %s.", verb, failed, total, items), code)
+}
+
+// changeCounter counts and logs the changes of the resources by the commands
+// which create or update multiple resources, such as mkr copy and mkr restore.
+type changeCounter struct {
+	isDryRun bool
+
+	created, updated, skipped, failed int
+}
+
+// dryRun logs the change with --dry-run, and reports whether the change should be skipped.
+// The action is "Create" or "Update".
+func (cc *changeCounter) dryRun(action, message string) bool {
+	if !cc.isDryRun {
+		return false
+	}
+	cc.count(action)
+	logger.Log("info", action+" "+message+" (dry-run)")
+	return true
+}
+
+func (cc *changeCounter) succeed(action, message string) {
+	cc.count(action)
+	logger.Log(strings.ToLower(action)+"d", message)
+}
+
+func (cc *changeCounter) count(action string) {
+	if action == "Update" {
+		cc.updated++
+	} else {
+		cc.created++
+	}
+}
+
+func (cc *changeCounter) skip(message string) {
+	cc.skipped++
+	logger.Log("info", "Skip "+message)
+}
+
+func (cc *changeCounter) fail(message string) {
+	cc.failed++
+	logger.Log("error", message)
+}
+
+// result logs the numbers of the changes and returns the error of the failures
+func (cc *changeCounter) result(verb, items string) error {
+	logger.Log("info", fmt.Sprintf("%d created, %d updated, %d skipped, %d failed", cc.created, cc.updated, cc.skipped, cc.failed))
+	return batchError(verb, cc.failed, cc.created+cc.updated+cc.failed, items)
+}
@@ -0,0 +1,38 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+func TestBatchError(t *testing.T) {
+	testCases := []struct {
+		failed, total int
+		code          int
+		message       string
+	}{
+		{failed: 0, total: 3},
+		{failed: 1, total: 3, code: exitCodePartialFailure, message: "failed to close 1 of 3 alert(s)."},
+		{failed: 3, total: 3, code: exitCodeFailure, message: "failed to close 3 of 3 alert(s)."},
+	}
+	for _, tc := range testCases {
+		err := batchError("close", tc.failed, tc.total, "alert(s)")
+		if tc.code == 0 {
+			if err != nil {
+				t.Errorf("batchError(%d, %d) should be nil but got %v", tc.failed, tc.total, err)
+			}
+			continue
+		}
+		exitErr, ok := err.(cli.ExitCoder)
+		if !ok {
+			t.Fatalf("batchError(%d, %d) should be an ExitCoder but got %#v", tc.failed, tc.total, err)
+		}
+		if exitErr.ExitCode() != tc.code {
+			t.Errorf("the exit code should be %d but got %d", tc.code, exitErr.ExitCode())
+		}
+		if exitErr.Error() != tc.message {
+			t.Errorf("the message should be %q but got %q", tc.message, exitErr.Error())
+		}
+	}
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandBridge = cli.Command{
+	Name:  "bridge",
+	Usage: "Bridge metrics from other monitoring systems",
+	Description: `
+    Run a server which receives the metrics from other monitoring systems and posts them to Mackerel.
+    With no subcommand specified, this will show all of subcommands.
+`,
+	Subcommands: []cli.Command{
+		commandBridgePrometheus,
+	},
+}
+
+// metricTarget is the host or the service to post metric values to
+type metricTarget struct {
+	hostID  string
+	service string
+}
+
+func (t metricTarget) String() string {
+	if t.service != "" {
+		return "service " + t.service
+	}
+	return "host " + t.hostID
+}
+
+// metricTargetFromContext returns the target specified by --host-id, --host-name or --service.
+func metricTargetFromContext(c *cli.Context, client mackerelclient.HostFinder) (metricTarget, error) {
+	hostID, err := mackerelclient.HostIDFromContext(c, client, "host-id")
+	if err != nil {
+		return metricTarget{}, err
+	}
+	service := c.String("service")
+	if (hostID == "") == (service == "") {
+		return metricTarget{}, fmt.Errorf("specify either --host-id, --host-name or --service")
+	}
+	return metricTarget{hostID: hostID, service: service}, nil
+}
+
+// customMetricName sanitizes the metric name and prefixes it with "custom." for the host metrics.
+func customMetricName(name string, target metricTarget) string {
+	name = sanitizeMetricName(name)
+	if target.hostID != "" && !strings.HasPrefix(name, hostGraphDefPrefix) {
+		name = hostGraphDefPrefix + name
+	}
+	return name
+}
+
+type metricPoster interface {
+	PostHostMetricValuesByHostID(hostID string, metricValues []*mackerel.MetricValue) error
+	PostServiceMetricValues(serviceName string, metricValues []*mackerel.MetricValue) error
+}
+
+// metricBuffer keeps the metric values received until they are flushed. Since the resolution of
+// the metrics of Mackerel is a minute, only the last value of a metric in each minute is kept.
+type metricBuffer struct {
+	mu     sync.Mutex
+	values map[metricTarget]map[string]*mackerel.MetricValue
+}
+
+func newMetricBuffer() *metricBuffer {
+	return &metricBuffer{values: map[metricTarget]map[string]*mackerel.MetricValue{}}
+}
+
+func (b *metricBuffer) add(target metricTarget, value *mackerel.MetricValue) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.values[target] == nil {
+		b.values[target] = map[string]*mackerel.MetricValue{}
+	}
+	key := fmt.Sprintf("%s@%d", value.Name, value.Time/60)
+	if v, ok := b.values[target][key]; !ok || v.Time <= value.Time {
+		b.values[target][key] = value
+	}
+}
+
+// take returns the buffered values sorted by the names and the times, and clears the buffer.
+func (b *metricBuffer) take() map[metricTarget][]*mackerel.MetricValue {
+	b.mu.Lock()
+	values := b.values
+	b.values = map[metricTarget]map[string]*mackerel.MetricValue{}
+	b.mu.Unlock()
+
+	taken := make(map[metricTarget][]*mackerel.MetricValue, len(values))
+	for target, vs := range values {
+		list := make([]*mackerel.MetricValue, 0, len(vs))
+		for _, v := range vs {
+			list = append(list, v)
+		}
+		sort.Slice(list, func(i, j int) bool {
+			if list[i].Name != list[j].Name {
+				return list[i].Name < list[j].Name
+			}
+			return list[i].Time < list[j].Time
+		})
+		taken[target] = list
+	}
+	return taken
+}
+
+// flush posts the buffered values. The values failed to post are dropped not to grow the buffer.
+func (b *metricBuffer) flush(client metricPoster) error {
+	var errs []string
+	for target, values := range b.take() {
+		var err error
+		if target.service != "" {
+			err = client.PostServiceMetricValues(target.service, values)
+		} else {
+			err = client.PostHostMetricValuesByHostID(target.hostID, values)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("failed to post %d values to the %s: %s", len(values), target, err))
+			continue
+		}
+		logger.Log("debug", fmt.Sprintf("posted %d values to the %s", len(values), target))
+	}
+	if len(errs) > 0 {
+		sort.Strings(errs)
+		return fmt.Errorf("%s", strings.Join(errs, "\n"))
+	}
+	return nil
+}
+
+// runFlushLoop calls flush on every interval until SIGINT or SIGTERM is received,
+// and flushes the rest of the metrics before returning.
+func runFlushLoop(flush func() error, interval time.Duration, errCh <-chan error) error {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
+	defer signal.Stop(sigCh)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+			if err := flush(); err != nil {
+				logger.Log("error", err.Error())
+			}
+		case err := <-errCh:
+			return err
+		case sig := <-sigCh:
+			logger.Log("info", fmt.Sprintf("received %s, flushing the metric values", sig))
+			return flush()
+		}
+	}
+}
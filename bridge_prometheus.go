@@ -0,0 +1,314 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandBridgePrometheus = cli.Command{
+	Name:      "prometheus",
+	Usage:     "Receive Prometheus remote_write and post the samples to Mackerel",
+	ArgsUsage: "[--listen <address>] [--host-id | -H <hostId> | --host-name <name>] [--service | -s <service>] [--config <file>] [--flush-interval <duration>]",
+	Description: `
+    Run an HTTP server which accepts the remote_write requests of Prometheus at /write,
+    and posts the samples to the host metrics or the service metrics of Mackerel.
+    Since the resolution of Mackerel is a minute, the last sample of a metric in each minute is posted on every --flush-interval.
+    The metric name is the Prometheus metric name followed by the label values in the order of the label names,
+    prefixed with "custom." for the host metrics. The names and the destinations of the metrics can be changed
+    with the relabeling rules in --config, which are applied in order and the first matching rule is used.
+
+        rules:
+        - match: ^node_filesystem_(.+)_bytes$  # regexp of the metric name
+          labels:                              # regexps of the label values
+            fstype: ^(ext4|xfs)$
+          name: filesystem.$1.{mountpoint}     # $1 is replaced with the submatch and {label} with the label value
+          service: MyService                   # posts to the service instead of the host of --host-id
+        - match: ^go_
+          action: drop
+        defaultAction: keep                    # keep or drop the metrics matching no rule
+
+    Add the following to the configuration of Prometheus:
+
+        remote_write:
+        - url: http://localhost:9201/write
+
+    Requests "POST /api/v0/tsdb" and "POST /api/v0/services/<serviceName>/tsdb".
+`,
+	Action: doBridgePrometheus,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "listen", Value: ":9201", Usage: "The <address> to listen on"},
+		cli.StringFlag{Name: "host-id, H", Value: "", Usage: "Post host metric values to <hostID>."},
+		mackerelclient.HostNameFlag,
+		cli.StringFlag{Name: "service, s", Value: "", Usage: "Post service metric values to <service>."},
+		cli.StringFlag{Name: "config", Value: "", Usage: "The JSON or YAML file of the relabeling rules"},
+		cli.DurationFlag{Name: "flush-interval", Value: time.Minute, Usage: "The interval to post the received samples"},
+	},
+}
+
+// The messages of the remote_write protocol of Prometheus.
+// See https://github.com/prometheus/prometheus/blob/master/prompb/remote.proto .
+
+type promWriteRequest struct {
+	Timeseries []*promTimeSeries `protobuf:"bytes,1,rep,name=timeseries"`
+}
+
+func (m *promWriteRequest) Reset()         { *m = promWriteRequest{} }
+func (m *promWriteRequest) String() string { return proto.CompactTextString(m) }
+func (*promWriteRequest) ProtoMessage()    {}
+
+type promTimeSeries struct {
+	Labels  []*promLabel  `protobuf:"bytes,1,rep,name=labels"`
+	Samples []*promSample `protobuf:"bytes,2,rep,name=samples"`
+}
+
+func (m *promTimeSeries) Reset()         { *m = promTimeSeries{} }
+func (m *promTimeSeries) String() string { return proto.CompactTextString(m) }
+func (*promTimeSeries) ProtoMessage()    {}
+
+type promLabel struct {
+	Name  string `protobuf:"bytes,1,opt,name=name"`
+	Value string `protobuf:"bytes,2,opt,name=value"`
+}
+
+func (m *promLabel) Reset()         { *m = promLabel{} }
+func (m *promLabel) String() string { return proto.CompactTextString(m) }
+func (*promLabel) ProtoMessage()    {}
+
+type promSample struct {
+	Value     float64 `protobuf:"fixed64,1,opt,name=value"`
+	Timestamp int64   `protobuf:"varint,2,opt,name=timestamp"` // in milliseconds
+}
+
+func (m *promSample) Reset()         { *m = promSample{} }
+func (m *promSample) String() string { return proto.CompactTextString(m) }
+func (*promSample) ProtoMessage()    {}
+
+func decodePromWriteRequest(data []byte) (*promWriteRequest, error) {
+	data, err := snappy.Decode(nil, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decode the snappy body: %s", err)
+	}
+	var req promWriteRequest
+	if err := proto.Unmarshal(data, &req); err != nil {
+		return nil, fmt.Errorf("failed to decode the write request: %s", err)
+	}
+	return &req, nil
+}
+
+type promRelabelConfig struct {
+	Rules         []*promRelabelRule `json:"rules"`
+	DefaultAction string             `json:"defaultAction,omitempty"`
+}
+
+type promRelabelRule struct {
+	Match   string            `json:"match,omitempty"`
+	Labels  map[string]string `json:"labels,omitempty"`
+	Action  string            `json:"action,omitempty"`
+	Name    string            `json:"name,omitempty"`
+	Service string            `json:"service,omitempty"`
+	HostID  string            `json:"hostId,omitempty"`
+
+	match  *regexp.Regexp
+	labels map[string]*regexp.Regexp
+}
+
+var promLabelTemplatePattern = regexp.MustCompile(`\{([a-zA-Z_][a-zA-Z0-9_]*)\}`)
+
+func loadPromRelabelConfig(filePath string) (*promRelabelConfig, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is also accepted since it is a subset of YAML
+	if data, err = format.YAMLToJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	var config promRelabelConfig
+	if err := json.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	if err := config.compile(); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	return &config, nil
+}
+
+func (config *promRelabelConfig) compile() error {
+	if a := config.DefaultAction; a != "" && a != "keep" && a != "drop" {
+		return fmt.Errorf("defaultAction should be keep or drop: %s", a)
+	}
+	for i, r := range config.Rules {
+		if r.Action != "" && r.Action != "keep" && r.Action != "drop" {
+			return fmt.Errorf("the action of the rule #%d should be keep or drop: %s", i+1, r.Action)
+		}
+		if r.Service != "" && r.HostID != "" {
+			return fmt.Errorf("the rule #%d should not have both service and hostId", i+1)
+		}
+		var err error
+		if r.match, err = regexp.Compile(r.Match); err != nil {
+			return fmt.Errorf("invalid match of the rule #%d: %s", i+1, err)
+		}
+		r.labels = make(map[string]*regexp.Regexp, len(r.Labels))
+		for name, pattern := range r.Labels {
+			if r.labels[name], err = regexp.Compile(pattern); err != nil {
+				return fmt.Errorf("invalid label %s of the rule #%d: %s", name, i+1, err)
+			}
+		}
+	}
+	return nil
+}
+
+// relabel returns the metric name and the target of the metric, or false if the metric is dropped.
+func (config *promRelabelConfig) relabel(labels map[string]string, target metricTarget) (string, metricTarget, bool) {
+	name := labels["__name__"]
+	for _, r := range config.Rules {
+		m := r.match.FindStringSubmatchIndex(name)
+		if m == nil || !r.matchLabels(labels) {
+			continue
+		}
+		if r.Action == "drop" {
+			return "", target, false
+		}
+		if r.Service != "" {
+			target = metricTarget{service: r.Service}
+		} else if r.HostID != "" {
+			target = metricTarget{hostID: r.HostID}
+		}
+		metricName := defaultPromMetricName(labels)
+		if r.Name != "" {
+			metricName = string(r.match.ExpandString(nil, r.Name, name, m))
+			metricName = promLabelTemplatePattern.ReplaceAllStringFunc(metricName, func(s string) string {
+				return labels[s[1:len(s)-1]]
+			})
+		}
+		return customMetricName(metricName, target), target, true
+	}
+	if config.DefaultAction == "drop" {
+		return "", target, false
+	}
+	return customMetricName(defaultPromMetricName(labels), target), target, true
+}
+
+func (r *promRelabelRule) matchLabels(labels map[string]string) bool {
+	for name, re := range r.labels {
+		if !re.MatchString(labels[name]) {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultPromMetricName appends the label values to the metric name in the order of the label names
+// as "mkr throw --input-format prometheus" does.
+func defaultPromMetricName(labels map[string]string) string {
+	var keys []string
+	for k := range labels {
+		if k != "__name__" {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	parts := []string{labels["__name__"]}
+	for _, k := range keys {
+		parts = append(parts, labels[k])
+	}
+	return strings.Join(parts, ".")
+}
+
+// addPromWriteRequest relabels the samples in the request and adds them to the buffer.
+// It returns the number of the samples added.
+func addPromWriteRequest(buffer *metricBuffer, req *promWriteRequest, config *promRelabelConfig, target metricTarget) int {
+	var count int
+	for _, ts := range req.Timeseries {
+		labels := make(map[string]string, len(ts.Labels))
+		for _, l := range ts.Labels {
+			labels[l.Name] = l.Value
+		}
+		name, t, ok := config.relabel(labels, target)
+		if !ok {
+			continue
+		}
+		for _, s := range ts.Samples {
+			if math.IsNaN(s.Value) || math.IsInf(s.Value, 0) {
+				continue
+			}
+			buffer.add(t, &mackerel.MetricValue{Name: name, Value: s.Value, Time: s.Timestamp / 1000})
+			count++
+		}
+	}
+	return count
+}
+
+func promWriteHandler(buffer *metricBuffer, config *promRelabelConfig, target metricTarget) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			w.Header().Set("Allow", http.MethodPost)
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		data, err := ioutil.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		req, err := decodePromWriteRequest(data)
+		if err != nil {
+			logger.Log("warning", err.Error())
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		count := addPromWriteRequest(buffer, req, config, target)
+		logger.Log("debug", fmt.Sprintf("received %d samples", count))
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+func doBridgePrometheus(c *cli.Context) error {
+	client := mackerelclient.NewFromContext(c)
+	target, err := metricTargetFromContext(c, client)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	config := &promRelabelConfig{}
+	if filePath := c.String("config"); filePath != "" {
+		if config, err = loadPromRelabelConfig(filePath); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+	interval := c.Duration("flush-interval")
+	if interval <= 0 {
+		return cli.NewExitError("flush-interval should be positive", 1)
+	}
+
+	buffer := newMetricBuffer()
+	mux := http.NewServeMux()
+	mux.Handle("/write", promWriteHandler(buffer, config, target))
+	server := &http.Server{Addr: c.String("listen"), Handler: mux}
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- server.ListenAndServe()
+	}()
+	logger.Log("info", fmt.Sprintf("listening on %s to post the metrics to the %s", server.Addr, target))
+
+	err = runFlushLoop(func() error { return buffer.flush(client) }, interval, errCh)
+	server.Close()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
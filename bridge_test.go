@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/golang/protobuf/proto"
+	"github.com/golang/snappy"
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type fakeMetricPoster struct {
+	hostValues    map[string][]*mackerel.MetricValue
+	serviceValues map[string][]*mackerel.MetricValue
+}
+
+func (f *fakeMetricPoster) PostHostMetricValuesByHostID(hostID string, metricValues []*mackerel.MetricValue) error {
+	if hostID == "retired" {
+		return fmt.Errorf("host not found")
+	}
+	f.hostValues[hostID] = append(f.hostValues[hostID], metricValues...)
+	return nil
+}
+
+func (f *fakeMetricPoster) PostServiceMetricValues(serviceName string, metricValues []*mackerel.MetricValue) error {
+	f.serviceValues[serviceName] = append(f.serviceValues[serviceName], metricValues...)
+	return nil
+}
+
+func TestMetricBuffer_flush(t *testing.T) {
+	buffer := newMetricBuffer()
+	host, service := metricTarget{hostID: "host1"}, metricTarget{service: "MyApp"}
+	buffer.add(host, &mackerel.MetricValue{Name: "custom.b", Value: 1, Time: 120})
+	buffer.add(host, &mackerel.MetricValue{Name: "custom.b", Value: 2, Time: 150})
+	buffer.add(host, &mackerel.MetricValue{Name: "custom.b", Value: 3, Time: 130})
+	buffer.add(host, &mackerel.MetricValue{Name: "custom.b", Value: 4, Time: 60})
+	buffer.add(host, &mackerel.MetricValue{Name: "custom.a", Value: 5, Time: 120})
+	buffer.add(service, &mackerel.MetricValue{Name: "a", Value: 6, Time: 120})
+	buffer.add(metricTarget{hostID: "retired"}, &mackerel.MetricValue{Name: "custom.a", Value: 7, Time: 120})
+
+	client := &fakeMetricPoster{hostValues: map[string][]*mackerel.MetricValue{}, serviceValues: map[string][]*mackerel.MetricValue{}}
+	err := buffer.flush(client)
+	if err == nil || err.Error() != "failed to post 1 values to the host retired: host not found" {
+		t.Errorf("the values failed to post should be reported but: %v", err)
+	}
+	expect := []*mackerel.MetricValue{
+		{Name: "custom.a", Value: 5, Time: 120},
+		{Name: "custom.b", Value: 4, Time: 60},
+		{Name: "custom.b", Value: 2, Time: 150},
+	}
+	if !reflect.DeepEqual(client.hostValues["host1"], expect) {
+		t.Errorf("the last value of each minute should be posted but: %v", client.hostValues["host1"])
+	}
+	if len(client.serviceValues["MyApp"]) != 1 {
+		t.Errorf("the service metric should be posted but: %v", client.serviceValues)
+	}
+	if len(buffer.take()) != 0 {
+		t.Errorf("the buffer should be cleared")
+	}
+}
+
+func TestPromRelabelConfig_relabel(t *testing.T) {
+	config := &promRelabelConfig{
+		Rules: []*promRelabelRule{
+			{Match: `^node_filesystem_(.+)_bytes$`, Labels: map[string]string{"fstype": "^(ext4|xfs)$"}, Name: "filesystem.$1.{mountpoint}", Service: "MyApp"},
+			{Match: `^node_filesystem_`, Action: "drop"},
+			{Match: `^go_`, Action: "drop"},
+			{Match: `^up$`, HostID: "host2"},
+		},
+	}
+	if err := config.compile(); err != nil {
+		t.Fatal(err)
+	}
+	target := metricTarget{hostID: "host1"}
+	testCases := []struct {
+		labels map[string]string
+		name   string
+		target metricTarget
+		ok     bool
+	}{
+		{map[string]string{"__name__": "node_filesystem_avail_bytes", "fstype": "ext4", "mountpoint": "/"}, "filesystem.avail._", metricTarget{service: "MyApp"}, true},
+		{map[string]string{"__name__": "node_filesystem_avail_bytes", "fstype": "tmpfs", "mountpoint": "/run"}, "", target, false},
+		{map[string]string{"__name__": "go_goroutines"}, "", target, false},
+		{map[string]string{"__name__": "up", "job": "node"}, "custom.up.node", metricTarget{hostID: "host2"}, true},
+		{map[string]string{"__name__": "http_requests_total", "method": "get", "code": "200"}, "custom.http_requests_total.200.get", target, true},
+	}
+	for _, tc := range testCases {
+		name, got, ok := config.relabel(tc.labels, target)
+		if name != tc.name || got != tc.target || ok != tc.ok {
+			t.Errorf("relabel(%v) should be (%q, %v, %t) but: (%q, %v, %t)", tc.labels, tc.name, tc.target, tc.ok, name, got, ok)
+		}
+	}
+
+	config.DefaultAction = "drop"
+	if _, _, ok := config.relabel(map[string]string{"__name__": "http_requests_total"}, target); ok {
+		t.Errorf("the metric matching no rule should be dropped with defaultAction: drop")
+	}
+
+	if err := (&promRelabelConfig{Rules: []*promRelabelRule{{Action: "replace"}}}).compile(); err == nil {
+		t.Errorf("err should occur for the unknown action")
+	}
+}
+
+func TestPromWriteHandler(t *testing.T) {
+	req := &promWriteRequest{
+		Timeseries: []*promTimeSeries{
+			{
+				Labels:  []*promLabel{{Name: "__name__", Value: "temperature"}, {Name: "room", Value: "A-1"}},
+				Samples: []*promSample{{Value: 21.5, Timestamp: 1600000000000}, {Value: math.NaN(), Timestamp: 1600000001000}},
+			},
+		},
+	}
+	data, err := proto.Marshal(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	buffer := newMetricBuffer()
+	handler := promWriteHandler(buffer, &promRelabelConfig{}, metricTarget{service: "MyApp"})
+
+	w := httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/write", bytes.NewReader(snappy.Encode(nil, data))))
+	if w.Code != http.StatusNoContent {
+		t.Errorf("status code should be %d but: %d", http.StatusNoContent, w.Code)
+	}
+	expect := map[metricTarget][]*mackerel.MetricValue{
+		{service: "MyApp"}: {{Name: "temperature.A-1", Value: 21.5, Time: 1600000000}},
+	}
+	if got := buffer.take(); !reflect.DeepEqual(got, expect) {
+		t.Errorf("the samples should be buffered as %v but: %v", expect, got)
+	}
+
+	w = httptest.NewRecorder()
+	handler(w, httptest.NewRequest(http.MethodPost, "/write", bytes.NewReader(data)))
+	if w.Code != http.StatusBadRequest {
+		t.Errorf("status code should be %d for the body not compressed but: %d", http.StatusBadRequest, w.Code)
+	}
+}
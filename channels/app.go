@@ -5,6 +5,7 @@ import (
 	"io"
 	"log"
 	"os"
+	"strings"
 
 	"github.com/mackerelio/mackerel-client-go"
 	"github.com/mackerelio/mkr/format"
@@ -12,19 +13,33 @@ import (
 	"github.com/mackerelio/mkr/mackerelclient"
 )
 
+type appLogger interface {
+	Log(string, string)
+}
+
 type channelsApp struct {
 	client    mackerelclient.Client
+	logger    appLogger
 	outStream io.Writer
 }
 
-func (app *channelsApp) run() error {
+func (app *channelsApp) run(output *format.Output) error {
 	channels, err := app.client.FindChannels()
 	if err != nil {
 		return err
 	}
 
-	format.PrettyPrintJSON(app.outStream, channels)
-	return nil
+	return output.Print(app.outStream, channels, func() *format.Table {
+		t := format.NewTable("ID", "NAME", "TYPE", "EVENTS")
+		for _, ch := range channels {
+			var events []string
+			if ch.Events != nil {
+				events = *ch.Events
+			}
+			t.Append(ch.ID, ch.Name, ch.Type, strings.Join(events, ","))
+		}
+		return t
+	})
 }
 
 func (app *channelsApp) pullChannels(isVerbose bool, optFilePath string) error {
@@ -64,3 +79,62 @@ func channelSaveRules(rules []*mackerel.Channel, filePath string) error {
 	}
 	return nil
 }
+
+var channelEvents = []string{"alert", "alertGroup", "hostStatus", "hostRegister", "hostRetire", "monitor"}
+
+// validateChannel checks the channel of the types which can be created by the API.
+func validateChannel(ch *mackerel.Channel) error {
+	if ch.Name == "" {
+		return fmt.Errorf("the name of the channel is empty")
+	}
+	switch ch.Type {
+	case "email":
+		if (ch.Emails == nil || len(*ch.Emails) == 0) && (ch.UserIDs == nil || len(*ch.UserIDs) == 0) {
+			return fmt.Errorf("the email channel %s should have emails or user ids", ch.Name)
+		}
+	case "slack", "webhook":
+		if ch.URL == "" {
+			return fmt.Errorf("the %s channel %s should have the url", ch.Type, ch.Name)
+		}
+	default:
+		return fmt.Errorf("the type of the channel %s should be email, slack or webhook: %q", ch.Name, ch.Type)
+	}
+	if ch.Events != nil {
+		for _, e := range *ch.Events {
+			if !containsString(channelEvents, e) {
+				return fmt.Errorf("unknown event of the channel %s: %s", ch.Name, e)
+			}
+		}
+	}
+	return nil
+}
+
+func containsString(xs []string, x string) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+func (app *channelsApp) createChannel(ch *mackerel.Channel) error {
+	if err := validateChannel(ch); err != nil {
+		return err
+	}
+	channel, err := app.client.CreateChannel(ch)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("created", fmt.Sprintf("%s (%s)", channel.Name, channel.ID))
+	return nil
+}
+
+func (app *channelsApp) deleteChannel(id string) error {
+	channel, err := app.client.DeleteChannel(id)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("deleted", fmt.Sprintf("%s (%s)", channel.Name, channel.ID))
+	return nil
+}
@@ -3,9 +3,11 @@ package channels
 import (
 	"bytes"
 	"fmt"
+	"io"
 	"testing"
 
 	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
 	"github.com/mackerelio/mkr/mackerelclient"
 	"github.com/stretchr/testify/assert"
 )
@@ -127,7 +129,7 @@ func TestChannelsApp_Run(t *testing.T) {
 				client:    client,
 				outStream: out,
 			}
-			assert.NoError(t, app.run())
+			assert.NoError(t, app.run(&format.Output{Format: format.OutputJSON}))
 			assert.Equal(t, tc.expected, out.String())
 		})
 	}
@@ -175,3 +177,85 @@ func TestChannelsApp_PullChannels(t *testing.T) {
 		assert.Equal(t, tc.expected, out.String())
 	}
 }
+
+type testLogger struct {
+	w io.Writer
+}
+
+func (l *testLogger) Log(prefix, message string) {
+	fmt.Fprintln(l.w, prefix, message)
+}
+
+func TestValidateChannel(t *testing.T) {
+	testCases := []struct {
+		id       string
+		channel  *mackerel.Channel
+		expected string
+	}{
+		{
+			id:      "email",
+			channel: &mackerel.Channel{Name: "ops", Type: "email", UserIDs: &[]string{"1234"}, Events: &[]string{"alert", "hostRetire"}},
+		},
+		{
+			id:      "slack",
+			channel: &mackerel.Channel{Name: "ops", Type: "slack", URL: "https://hooks.slack.com/services/TAAAA/BBBB/XXXXX"},
+		},
+		{
+			id:       "no name",
+			channel:  &mackerel.Channel{Type: "webhook", URL: "http://example.com/webhook"},
+			expected: "the name of the channel is empty",
+		},
+		{
+			id:       "no emails",
+			channel:  &mackerel.Channel{Name: "ops", Type: "email", Emails: &[]string{}},
+			expected: "the email channel ops should have emails or user ids",
+		},
+		{
+			id:       "no url",
+			channel:  &mackerel.Channel{Name: "ops", Type: "webhook"},
+			expected: "the webhook channel ops should have the url",
+		},
+		{
+			id:       "unsupported type",
+			channel:  &mackerel.Channel{Name: "ops", Type: "line"},
+			expected: `the type of the channel ops should be email, slack or webhook: "line"`,
+		},
+		{
+			id:       "unknown event",
+			channel:  &mackerel.Channel{Name: "ops", Type: "webhook", URL: "http://example.com/webhook", Events: &[]string{"deploy"}},
+			expected: "unknown event of the channel ops: deploy",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			err := validateChannel(tc.channel)
+			if tc.expected == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, tc.expected)
+			}
+		})
+	}
+}
+
+func TestChannelsApp_CreateDelete(t *testing.T) {
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockCreateChannel(func(ch *mackerel.Channel) (*mackerel.Channel, error) {
+			assert.Equal(t, &mackerel.Channel{Name: "ops", Type: "webhook", URL: "http://example.com/webhook"}, ch)
+			return &mackerel.Channel{ID: "abcdefabc", Name: ch.Name, Type: ch.Type, URL: ch.URL}, nil
+		}),
+		mackerelclient.MockDeleteChannel(func(id string) (*mackerel.Channel, error) {
+			return &mackerel.Channel{ID: id, Name: "ops"}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &channelsApp{
+		client:    client,
+		logger:    &testLogger{out},
+		outStream: out,
+	}
+	assert.NoError(t, app.createChannel(&mackerel.Channel{Name: "ops", Type: "webhook", URL: "http://example.com/webhook"}))
+	assert.Error(t, app.createChannel(&mackerel.Channel{Name: "ops", Type: "webhook"}))
+	assert.NoError(t, app.deleteChannel("abcdefabc"))
+	assert.Equal(t, "created ops (abcdefabc)\ndeleted ops (abcdefabc)\n", out.String())
+}
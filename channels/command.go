@@ -1,21 +1,32 @@
 package channels
 
 import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
 	"os"
 
+	"github.com/Songmu/prompter"
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
 	"github.com/mackerelio/mkr/mackerelclient"
 	"github.com/urfave/cli"
 )
 
 // Command is the definition of channels subcommand
 var Command = cli.Command{
-	Name:  "channels",
-	Usage: "List notification channels",
+	Name:      "channels",
+	Usage:     "List notification channels",
+	ArgsUsage: "[--output | -o <format>]",
 	Description: `
 	Lists notification channels. With no subcommand specified, this will show all channels.
 	Requests APIs under "/api/v0/channels". See https://mackerel.io/api-docs/entry/channels .
 	`,
 	Action: doChannels,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "output, o", Value: "json", Usage: format.OutputUsage},
+	},
 	Subcommands: []cli.Command{
 		{
 			Name:      "pull",
@@ -30,32 +41,196 @@ var Command = cli.Command{
 				cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
 			},
 		},
+		{
+			Name:      "push",
+			Usage:     "push channel settings",
+			ArgsUsage: "[--file-path | -F <file>] [--dry-run | -d] [--prune]",
+			Description: `
+    Create the channels in the file saved by "mkr channels pull" which do not exist. The default is 'channels.json'.
+    The channels are matched by "id", or by "name" if they have no id.
+    With --prune, the channels which do not exist in the file are deleted.
+    The channels cannot be updated since the API does not support it, and their differences are warned.
+`,
+			Action: doChannelsPush,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "channels.json", Usage: "The file of channel settings"},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show the changes, but not apply them"},
+				cli.BoolFlag{Name: "prune", Usage: "Delete the channels which do not exist in the file"},
+			},
+		},
+		{
+			Name:      "create",
+			Usage:     "create a channel",
+			ArgsUsage: "[--file-path | -F <file>] --name <name> --type email|slack|webhook [--email <email>] [--user-id <userId>] [--url <url>] [--mention-ok|--mention-warning|--mention-critical <mention>] [--enable-graph-image] [--event <event>]",
+			Description: `
+    Create an email, Slack or webhook channel. The settings are read from the JSON file of a channel with --file-path,
+    and overridden by the flags.
+    Requests "POST /api/v0/channels". See https://mackerel.io/api-docs/entry/channels#create.
+`,
+			Action: doChannelsCreate,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "The JSON file of the channel"},
+				cli.StringFlag{Name: "name", Value: "", Usage: "The name of the channel"},
+				cli.StringFlag{Name: "type", Value: "", Usage: "The type of the channel: email, slack or webhook"},
+				cli.StringSliceFlag{Name: "email", Value: &cli.StringSlice{}, Usage: "The email address of the email channel. Multiple choices are allowed"},
+				cli.StringSliceFlag{Name: "user-id", Value: &cli.StringSlice{}, Usage: "The user ID of the email channel. Multiple choices are allowed"},
+				cli.StringFlag{Name: "url", Value: "", Usage: "The URL of the Slack or webhook channel"},
+				cli.StringFlag{Name: "mention-ok", Value: "", Usage: "The mention of the Slack channel for OK alerts"},
+				cli.StringFlag{Name: "mention-warning", Value: "", Usage: "The mention of the Slack channel for WARNING alerts"},
+				cli.StringFlag{Name: "mention-critical", Value: "", Usage: "The mention of the Slack channel for CRITICAL alerts"},
+				cli.BoolFlag{Name: "enable-graph-image", Usage: "Post the graph images to the Slack channel"},
+				cli.StringSliceFlag{
+					Name:  "event",
+					Value: &cli.StringSlice{},
+					Usage: "The event to notify: alert, alertGroup, hostStatus, hostRegister, hostRetire or monitor. Multiple choices are allowed",
+				},
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "delete a channel",
+			ArgsUsage: "[--force] <channelId>",
+			Description: `
+    Delete the channel.
+    Requests "DELETE /api/v0/channels/<channelId>". See https://mackerel.io/api-docs/entry/channels#delete.
+`,
+			Action: doChannelsDelete,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "force", Usage: "Delete the channel without confirmation"},
+			},
+		},
 	},
 }
 
-func doChannels(c *cli.Context) error {
-	client, err := mackerelclient.New(c.GlobalString("conf"), c.GlobalString("apibase"))
+func newChannelsApp(c *cli.Context) (*channelsApp, error) {
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
 	if err != nil {
-		return err
+		return nil, err
 	}
-
-	return (&channelsApp{
+	return &channelsApp{
 		client:    client,
+		logger:    logger.New(),
 		outStream: os.Stdout,
-	}).run()
+	}, nil
+}
+
+func doChannels(c *cli.Context) error {
+	app, err := newChannelsApp(c)
+	if err != nil {
+		return err
+	}
+	output, err := format.OutputFromContext(c, "json")
+	if err != nil {
+		return err
+	}
+	return app.run(output)
 }
 
 func doChannelsPull(c *cli.Context) error {
-	client, err := mackerelclient.New(c.GlobalString("conf"), c.GlobalString("apibase"))
+	app, err := newChannelsApp(c)
 	if err != nil {
 		return err
 	}
+	return app.pullChannels(c.Bool("verbose"), c.String("file-path"))
+}
 
-	isVerbose := c.Bool("verbose")
-	filePath := c.String("file-path")
+func doChannelsPush(c *cli.Context) error {
+	return Push(c, c.String("file-path"), c.Bool("dry-run"), c.Bool("prune"))
+}
 
-	return (&channelsApp{
-		client:    client,
-		outStream: os.Stdout,
-	}).pullChannels(isVerbose, filePath)
+// Push creates and deletes the channels in the JSON file as "mkr channels push" does.
+// It is also used by "mkr apply".
+func Push(c *cli.Context, filePath string, dryRun, prune bool) error {
+	app, err := newChannelsApp(c)
+	if err != nil {
+		return err
+	}
+	return app.pushChannels(pushChannelsParam{
+		filePath: filePath,
+		dryRun:   dryRun,
+		prune:    prune,
+	})
+}
+
+// buildChannel reads the channel from the file and overrides it by the flags.
+func buildChannel(c *cli.Context) (*mackerel.Channel, error) {
+	ch := &mackerel.Channel{}
+	if filePath := c.String("file-path"); filePath != "" {
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		if err := json.Unmarshal(data, ch); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+		}
+		ch.ID = ""
+	}
+	if c.IsSet("name") {
+		ch.Name = c.String("name")
+	}
+	if c.IsSet("type") {
+		ch.Type = c.String("type")
+	}
+	if c.IsSet("email") {
+		emails := c.StringSlice("email")
+		ch.Emails = &emails
+	}
+	if c.IsSet("user-id") {
+		userIDs := c.StringSlice("user-id")
+		ch.UserIDs = &userIDs
+	}
+	if c.IsSet("url") {
+		ch.URL = c.String("url")
+	}
+	if c.IsSet("mention-ok") {
+		ch.Mentions.OK = c.String("mention-ok")
+	}
+	if c.IsSet("mention-warning") {
+		ch.Mentions.Warning = c.String("mention-warning")
+	}
+	if c.IsSet("mention-critical") {
+		ch.Mentions.Critical = c.String("mention-critical")
+	}
+	if c.IsSet("enable-graph-image") {
+		enabled := c.Bool("enable-graph-image")
+		ch.EnabledGraphImage = &enabled
+	}
+	if c.IsSet("event") {
+		events := c.StringSlice("event")
+		ch.Events = &events
+	}
+	return ch, nil
+}
+
+func doChannelsCreate(c *cli.Context) error {
+	if c.String("file-path") == "" && (c.String("name") == "" || c.String("type") == "") {
+		cli.ShowCommandHelp(c, "create")
+		os.Exit(1)
+	}
+	ch, err := buildChannel(c)
+	if err != nil {
+		return err
+	}
+	app, err := newChannelsApp(c)
+	if err != nil {
+		return err
+	}
+	return app.createChannel(ch)
+}
+
+func doChannelsDelete(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelp(c, "delete")
+		os.Exit(1)
+	}
+	id := c.Args().Get(0)
+	if !c.Bool("force") && !prompter.YN("Delete the channel "+id+".\nAre you sure?", false) {
+		logger.Log("", "deletion is canceled.")
+		return nil
+	}
+	app, err := newChannelsApp(c)
+	if err != nil {
+		return err
+	}
+	return app.deleteChannel(id)
 }
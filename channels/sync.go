@@ -0,0 +1,108 @@
+package channels
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/resourcesync"
+)
+
+func loadChannels(filePath string) ([]*mackerel.Channel, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var file struct {
+		Channels []*mackerel.Channel `json:"channels"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	if err := resourcesync.CheckResources("channel", channelResources(file.Channels)); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	return file.Channels, nil
+}
+
+func channelResources(channels []*mackerel.Channel) []resourcesync.Resource {
+	resources := make([]resourcesync.Resource, len(channels))
+	for i, ch := range channels {
+		resources[i] = resourcesync.Resource{ID: ch.ID, Name: ch.Name, Value: ch}
+	}
+	return resources
+}
+
+// planChannels returns the changes to make the current channels the desired ones, and the warnings of
+// the differences which cannot be applied since the API does not support updating channels.
+// The desired channels are matched by the id, or by the name if they have no id.
+// The channels which do not exist in the desired ones are deleted only if prune is true.
+func planChannels(current, desired []*mackerel.Channel, prune bool) ([]*resourcesync.ResourceChange, []string, error) {
+	planned, err := resourcesync.Plan("channel", channelResources(current), channelResources(desired), prune, func(x, y interface{}) bool {
+		return sameChannel(x.(*mackerel.Channel), y.(*mackerel.Channel))
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	var changes []*resourcesync.ResourceChange
+	var warnings []string
+	for _, c := range planned {
+		switch c.Action() {
+		case "create":
+			if err := validateChannel(c.Value.(*mackerel.Channel)); err != nil {
+				return nil, nil, err
+			}
+		case "update":
+			warnings = append(warnings, fmt.Sprintf("the channel %s differs but cannot be updated", c.Target()))
+			continue
+		}
+		changes = append(changes, c)
+	}
+	return changes, warnings, nil
+}
+
+// sameChannel compares the channels except for their ids.
+func sameChannel(x, y *mackerel.Channel) bool {
+	xx, yy := *x, *y
+	xx.ID, yy.ID = "", ""
+	a, _ := json.Marshal(xx)
+	b, _ := json.Marshal(yy)
+	return bytes.Equal(a, b)
+}
+
+type pushChannelsParam struct {
+	filePath string
+	dryRun   bool
+	prune    bool
+}
+
+func (app *channelsApp) pushChannels(param pushChannelsParam) error {
+	desired, err := loadChannels(param.filePath)
+	if err != nil {
+		return err
+	}
+	current, err := app.client.FindChannels()
+	if err != nil {
+		return err
+	}
+	changes, warnings, err := planChannels(current, desired, param.prune)
+	if err != nil {
+		return err
+	}
+	for _, w := range warnings {
+		app.logger.Log("warning", w)
+	}
+	return resourcesync.Apply(app.outStream, app.logger, "channels", resourcesync.Changes(changes), param.dryRun, func(c resourcesync.Change) error {
+		ch := c.(*resourcesync.ResourceChange)
+		var err error
+		switch ch.Action() {
+		case "create":
+			_, err = app.client.CreateChannel(ch.Value.(*mackerel.Channel))
+		case "delete":
+			_, err = app.client.DeleteChannel(ch.ID)
+		}
+		return err
+	})
+}
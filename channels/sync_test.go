@@ -0,0 +1,91 @@
+package channels
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/mackerelio/mkr/resourcesync"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanChannels(t *testing.T) {
+	current := []*mackerel.Channel{
+		{ID: "a", Name: "ops", Type: "email", Emails: &[]string{"ops@example.com"}},
+		{ID: "b", Name: "slack", Type: "slack", URL: "https://hooks.slack.com/services/TAAAA/BBBB/XXXXX"},
+		{ID: "c", Name: "line", Type: "line"},
+	}
+	desired := []*mackerel.Channel{
+		{ID: "a", Name: "ops", Type: "email", Emails: &[]string{"ops@example.com"}},
+		{Name: "slack", Type: "slack", URL: "https://hooks.slack.com/services/TAAAA/BBBB/YYYYY"},
+		{Name: "webhook", Type: "webhook", URL: "http://example.com/webhook"},
+	}
+	describe := func(changes []*resourcesync.ResourceChange) []string {
+		var xs []string
+		for _, c := range changes {
+			xs = append(xs, c.Action()+" "+c.Target())
+		}
+		return xs
+	}
+
+	changes, warnings, err := planChannels(current, desired, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"create 'webhook'"}, describe(changes))
+	assert.Equal(t, []string{"the channel 'slack' (b) differs but cannot be updated"}, warnings)
+
+	changes, _, err = planChannels(current, desired, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"create 'webhook'", "delete 'line' (c)"}, describe(changes))
+
+	_, _, err = planChannels(current, []*mackerel.Channel{{ID: "x", Name: "unknown"}}, false)
+	assert.EqualError(t, err, "channel not found: x")
+	_, _, err = planChannels(current, []*mackerel.Channel{{Name: "new", Type: "webhook"}}, false)
+	assert.EqualError(t, err, "the webhook channel new should have the url")
+}
+
+func TestChannelsApp_PushChannels(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-channels")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "channels.json")
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(`{"channels": [
+		{"id": "a", "name": "ops", "type": "email", "emails": ["ops@example.com"]},
+		{"name": "webhook", "type": "webhook", "url": "http://example.com/webhook", "events": ["alert"]}
+	]}`), 0644))
+
+	var created []*mackerel.Channel
+	var deleted []string
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindChannels(func() ([]*mackerel.Channel, error) {
+			return []*mackerel.Channel{
+				{ID: "a", Name: "ops", Type: "email", Emails: &[]string{"ops@example.com"}},
+				{ID: "b", Name: "old", Type: "webhook", URL: "http://example.com/old"},
+			}, nil
+		}),
+		mackerelclient.MockCreateChannel(func(ch *mackerel.Channel) (*mackerel.Channel, error) {
+			created = append(created, ch)
+			return ch, nil
+		}),
+		mackerelclient.MockDeleteChannel(func(id string) (*mackerel.Channel, error) {
+			deleted = append(deleted, id)
+			return &mackerel.Channel{ID: id}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &channelsApp{client: client, logger: &testLogger{out}, outStream: out}
+
+	assert.NoError(t, app.pushChannels(pushChannelsParam{filePath: filePath, dryRun: true, prune: true}))
+	assert.Equal(t, "create 'webhook'\ndelete 'old' (b)\n", out.String())
+	assert.Empty(t, created)
+	assert.Empty(t, deleted)
+
+	out.Reset()
+	assert.NoError(t, app.pushChannels(pushChannelsParam{filePath: filePath}))
+	assert.Equal(t, "created 'webhook'\n", out.String())
+	assert.Equal(t, []*mackerel.Channel{{Name: "webhook", Type: "webhook", URL: "http://example.com/webhook", Events: &[]string{"alert"}}}, created)
+	assert.Empty(t, deleted)
+}
@@ -5,13 +5,19 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
 	"github.com/mackerelio/checkers"
 	"github.com/mackerelio/mackerel-agent/config"
+	"github.com/mackerelio/mackerel-client-go"
 	"github.com/urfave/cli"
 	yaml "gopkg.in/yaml.v2"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
 )
 
 // Command is command definition of mkr checks
@@ -24,33 +30,83 @@ var Command = cli.Command{
 }
 
 var commandRun = cli.Command{
-	Name:  "run",
-	Usage: "run check commands in mackerel-agent.conf",
+	Name:      "run",
+	Usage:     "run check commands in mackerel-agent.conf",
+	ArgsUsage: "[--conf <file>] [--report] [<checkNames...>]",
 	Description: `
     Execute command of check plugins in mackerel-agent.conf all at once.
     It is used for checking setting and operation of the check plugins.
     The result is output to stdout in TAP format. If any check fails,
-    it exits non-zero.
+    it exits non-zero. With <checkNames>, only the check plugins of the names are executed.
+    With --report, the results are also posted as the check reports of the host of mackerel-agent,
+    or the host of customIdentifier of the check plugin, as mackerel-agent does.
 `,
 	Action: doRunChecks,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "conf", Value: "", Usage: "Config file path of mackerel-agent. default: the global --conf"},
+		cli.BoolFlag{Name: "report", Usage: "Post the results as the check reports"},
+	},
 }
 
 func doRunChecks(c *cli.Context) error {
-	confFile := c.GlobalString("conf")
+	confFile := c.String("conf")
+	if confFile == "" {
+		confFile = c.GlobalString("conf")
+	}
 	conf, err := config.LoadConfig(confFile)
 	if err != nil {
 		return err
 	}
-	checkers := make([]checker, len(conf.CheckPlugins))
-	i := 0
-	for name, p := range conf.CheckPlugins {
-		checkers[i] = &checkPluginChecker{
+	plugins, err := selectCheckPlugins(conf.CheckPlugins, c.Args())
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	checkers := make([]checker, 0, len(plugins))
+	for _, name := range sortedCheckPluginNames(plugins) {
+		checkers = append(checkers, &checkPluginChecker{
 			name: name,
-			cp:   p,
+			cp:   plugins[name],
+		})
+	}
+	results, err := runChecks(checkers, os.Stdout)
+	if c.Bool("report") {
+		client := mackerelclient.NewFromContext(c)
+		// the host ID is empty if mackerel-agent has not registered the host, which is reported by buildResultReports
+		hostID, _ := conf.LoadHostID()
+		reports, rerr := buildResultReports(client, results, plugins, hostID, time.Now())
+		if rerr != nil {
+			return cli.NewExitError(rerr.Error(), 1)
 		}
-		i++
+		logger.DieIf(client.PostCheckReports(reports))
+		logger.Log("info", fmt.Sprintf("Check reports are posted (%d reports).", len(reports.Reports)))
 	}
-	return runChecks(checkers, os.Stdout)
+	return err
+}
+
+// selectCheckPlugins returns the check plugins of the names, or all the check plugins if no names are specified.
+func selectCheckPlugins(plugins map[string]*config.CheckPlugin, names []string) (map[string]*config.CheckPlugin, error) {
+	if len(names) == 0 {
+		return plugins, nil
+	}
+	selected := make(map[string]*config.CheckPlugin, len(names))
+	for _, name := range names {
+		// the names in the config file are prefixed with "plugin.checks."
+		p, ok := plugins[strings.TrimPrefix(name, "plugin.checks.")]
+		if !ok {
+			return nil, fmt.Errorf("check plugin not found: %s", name)
+		}
+		selected[strings.TrimPrefix(name, "plugin.checks.")] = p
+	}
+	return selected, nil
+}
+
+func sortedCheckPluginNames(plugins map[string]*config.CheckPlugin) []string {
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
 }
 
 type result struct {
@@ -113,7 +169,9 @@ type checker interface {
 	check() *result
 }
 
-func runChecks(checkers []checker, w io.Writer) error {
+// runChecks runs the checkers and prints the results in TAP format. The results are returned in the order
+// of the completion, with the error if any check fails.
+func runChecks(checkers []checker, w io.Writer) ([]*result, error) {
 	ch := make(chan *result)
 	total := len(checkers)
 	go func() {
@@ -134,16 +192,60 @@ func runChecks(checkers []checker, w io.Writer) error {
 	fmt.Fprintln(w, "TAP version 13")
 	fmt.Fprintf(w, "1..%d\n", total)
 	testNum, errNum := 1, 0
+	results := make([]*result, 0, total)
 	for re := range ch {
 		fmt.Fprintln(w, re.tapFormat(testNum))
+		results = append(results, re)
 		testNum++
 		if !re.ok() {
 			errNum++
 		}
 	}
 	if errNum > 0 {
-		return fmt.Errorf("Failed %d/%d tests, %3.2f%% okay",
+		return results, fmt.Errorf("Failed %d/%d tests, %3.2f%% okay",
 			errNum, total, float64(100*(total-errNum))/float64(total))
 	}
-	return nil
+	return results, nil
+}
+
+// buildResultReports converts the results into the check reports as mackerel-agent does. The reports are
+// of the host of mackerel-agent, or the host of customIdentifier if the check plugin has it.
+func buildResultReports(client mackerelclient.HostFinder, results []*result, plugins map[string]*config.CheckPlugin, hostID string, now time.Time) (*mackerel.CheckReports, error) {
+	reports := &mackerel.CheckReports{}
+	for _, re := range results {
+		p := plugins[re.Name]
+		reportHostID := hostID
+		if p.CustomIdentifier != nil {
+			hosts, err := client.FindHosts(&mackerel.FindHostsParam{CustomIdentifier: *p.CustomIdentifier})
+			if err != nil {
+				return nil, err
+			}
+			if len(hosts) == 0 {
+				return nil, fmt.Errorf("the host of the customIdentifier %s of %s is not found", *p.CustomIdentifier, re.Name)
+			}
+			reportHostID = hosts[0].ID
+		}
+		if reportHostID == "" {
+			return nil, fmt.Errorf("the host ID of mackerel-agent is not found to report %s", re.Name)
+		}
+		status, message := mackerel.CheckStatus(re.Status), re.Stdout
+		if re.ErrMsg != "" {
+			status, message = mackerel.CheckStatusUnknown, re.ErrMsg
+		}
+		r := &mackerel.CheckReport{
+			Source:     mackerel.NewCheckSourceHost(reportHostID),
+			Name:       re.Name,
+			Status:     status,
+			Message:    message,
+			OccurredAt: now.Unix(),
+		}
+		if p.NotificationInterval != nil {
+			r.NotificationInterval = uint(*p.NotificationInterval)
+		}
+		if p.MaxCheckAttempts != nil {
+			r.MaxCheckAttempts = uint(*p.MaxCheckAttempts)
+		}
+		reports.Reports = append(reports.Reports, r)
+	}
+	return reports, nil
 }
@@ -2,7 +2,12 @@ package checks
 
 import (
 	"bytes"
+	"reflect"
 	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-agent/config"
+	"github.com/mackerelio/mackerel-client-go"
 )
 
 type testChecker struct {
@@ -38,3 +43,63 @@ ok 1 - hoge
 		t.Errorf("something went wrong\ngot:\n%s\nexpect:\n%s", got, expect)
 	}
 }
+
+func TestSelectCheckPlugins(t *testing.T) {
+	plugins := map[string]*config.CheckPlugin{"ssh": {Memo: "ssh"}, "http": {Memo: "http"}}
+	selected, err := selectCheckPlugins(plugins, []string{"plugin.checks.ssh"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(selected, map[string]*config.CheckPlugin{"ssh": plugins["ssh"]}) {
+		t.Errorf("only the check plugin of the name should be selected: %v", selected)
+	}
+	if selected, _ := selectCheckPlugins(plugins, nil); len(selected) != 2 {
+		t.Errorf("all the check plugins should be selected: %v", selected)
+	}
+	if _, err := selectCheckPlugins(plugins, []string{"ntp"}); err == nil {
+		t.Errorf("err should occur for the unknown check plugin")
+	}
+}
+
+type fakeHostFinder struct {
+	param *mackerel.FindHostsParam
+}
+
+func (f *fakeHostFinder) FindHosts(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+	f.param = param
+	return []*mackerel.Host{{ID: "customHost"}}, nil
+}
+
+func TestBuildResultReports(t *testing.T) {
+	customIdentifier, interval, attempts := "i-0123", int32(30), int32(3)
+	plugins := map[string]*config.CheckPlugin{
+		"ssh":  {NotificationInterval: &interval, MaxCheckAttempts: &attempts},
+		"http": {CustomIdentifier: &customIdentifier},
+	}
+	results := []*result{
+		{Name: "ssh", Status: "CRITICAL", Stdout: "connection refused", ExitCode: 2},
+		{Name: "http", Status: "UNKNOWN", ErrMsg: "exec: not found", ExitCode: -1},
+	}
+	client := &fakeHostFinder{}
+	now := time.Unix(1700000000, 0)
+	reports, err := buildResultReports(client, results, plugins, "agentHost", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &mackerel.CheckReports{Reports: []*mackerel.CheckReport{
+		{Source: mackerel.NewCheckSourceHost("agentHost"), Name: "ssh", Status: mackerel.CheckStatusCritical,
+			Message: "connection refused", OccurredAt: now.Unix(), NotificationInterval: 30, MaxCheckAttempts: 3},
+		{Source: mackerel.NewCheckSourceHost("customHost"), Name: "http", Status: mackerel.CheckStatusUnknown,
+			Message: "exec: not found", OccurredAt: now.Unix()},
+	}}
+	if !reflect.DeepEqual(reports, expect) {
+		t.Errorf("reports should be %+v but: %+v", expect, reports)
+	}
+	if client.param.CustomIdentifier != customIdentifier {
+		t.Errorf("the host should be found by the customIdentifier: %+v", client.param)
+	}
+
+	if _, err := buildResultReports(client, results[:1], plugins, "", now); err == nil {
+		t.Errorf("err should occur without the host ID of mackerel-agent")
+	}
+}
@@ -0,0 +1,175 @@
+package checks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+// CommandReport is command definition of mkr check-report
+var CommandReport = cli.Command{
+	Name:      "check-report",
+	Usage:     "Report check monitoring results",
+	ArgsUsage: "--name <check> [--host-id | -H <hostId> | --host-name <name>] --status OK|WARNING|CRITICAL|UNKNOWN [--message <message>] | --stdin",
+	Description: `
+    Report a result of the check monitoring without running mackerel-agent, for example from cron jobs.
+    The host ID defaults to the one of mackerel-agent on the host, and accepts name:<name> to specify the host by the name.
+    With --stdin, the reports are read from stdin as JSON objects or arrays of them such as
+    {"name": "backup", "hostId": "<hostId>", "status": "CRITICAL", "message": "backup failed"}.
+    The keys "occurredAt", "notificationInterval" and "maxCheckAttempts" are also available.
+    Requests "POST /api/v0/monitoring/checks/report". See https://mackerel.io/api-docs/entry/check-monitoring#post .
+`,
+	Action: doReportChecks,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "name", Value: "", Usage: "The name of the check monitoring"},
+		cli.StringFlag{Name: "host-id, H", Value: "", Usage: "The host ID of the check monitoring"},
+		mackerelclient.HostNameFlag,
+		cli.StringFlag{Name: "status", Value: "", Usage: "The status: OK, WARNING, CRITICAL or UNKNOWN"},
+		cli.StringFlag{Name: "message", Value: "", Usage: "The message of the result"},
+		cli.IntFlag{Name: "notification-interval", Value: 0, Usage: "The interval in minutes to notify the alert again"},
+		cli.IntFlag{Name: "max-check-attempts", Value: 0, Usage: "The number of the attempts before the alert is opened"},
+		cli.BoolFlag{Name: "stdin", Usage: "Read the reports from stdin as JSON"},
+	},
+}
+
+// reportInput is a check report in the input, which is flattened from mackerel.CheckReport.
+type reportInput struct {
+	Name                 string `json:"name"`
+	HostID               string `json:"hostId"`
+	Status               string `json:"status"`
+	Message              string `json:"message"`
+	OccurredAt           int64  `json:"occurredAt"`
+	NotificationInterval uint   `json:"notificationInterval"`
+	MaxCheckAttempts     uint   `json:"maxCheckAttempts"`
+}
+
+var checkStatuses = []mackerel.CheckStatus{
+	mackerel.CheckStatusOK, mackerel.CheckStatusWarning, mackerel.CheckStatusCritical, mackerel.CheckStatusUnknown,
+}
+
+// report validates the input and converts it into the report. The host ID and the time are filled with the defaults.
+func (in *reportInput) report(defaultHostID string, now time.Time) (*mackerel.CheckReport, error) {
+	if in.Name == "" {
+		return nil, fmt.Errorf("the name of the check report is empty")
+	}
+	hostID := in.HostID
+	if hostID == "" {
+		hostID = defaultHostID
+	}
+	if hostID == "" {
+		return nil, fmt.Errorf("the host ID of the check report %s is not specified", in.Name)
+	}
+	status := mackerel.CheckStatus(strings.ToUpper(in.Status))
+	valid := false
+	for _, s := range checkStatuses {
+		valid = valid || s == status
+	}
+	if !valid {
+		return nil, fmt.Errorf("the status of the check report %s should be OK, WARNING, CRITICAL or UNKNOWN: %q", in.Name, in.Status)
+	}
+	occurredAt := in.OccurredAt
+	if occurredAt == 0 {
+		occurredAt = now.Unix()
+	}
+	return &mackerel.CheckReport{
+		Source:               mackerel.NewCheckSourceHost(hostID),
+		Name:                 in.Name,
+		Status:               status,
+		Message:              in.Message,
+		OccurredAt:           occurredAt,
+		NotificationInterval: in.NotificationInterval,
+		MaxCheckAttempts:     in.MaxCheckAttempts,
+	}, nil
+}
+
+// readReportInputs reads the JSON objects or arrays of them.
+func readReportInputs(r io.Reader) ([]*reportInput, error) {
+	var inputs []*reportInput
+	dec := json.NewDecoder(r)
+	for {
+		var v json.RawMessage
+		if err := dec.Decode(&v); err == io.EOF {
+			return inputs, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if strings.HasPrefix(strings.TrimSpace(string(v)), "[") {
+			var xs []*reportInput
+			if err := json.Unmarshal(v, &xs); err != nil {
+				return nil, err
+			}
+			inputs = append(inputs, xs...)
+		} else {
+			var x reportInput
+			if err := json.Unmarshal(v, &x); err != nil {
+				return nil, err
+			}
+			inputs = append(inputs, &x)
+		}
+	}
+}
+
+func buildCheckReports(inputs []*reportInput, defaultHostID string, now time.Time) (*mackerel.CheckReports, error) {
+	if len(inputs) == 0 {
+		return nil, fmt.Errorf("no check reports")
+	}
+	reports := &mackerel.CheckReports{}
+	for _, in := range inputs {
+		r, err := in.report(defaultHostID, now)
+		if err != nil {
+			return nil, err
+		}
+		reports.Reports = append(reports.Reports, r)
+	}
+	return reports, nil
+}
+
+func doReportChecks(c *cli.Context) error {
+	client := mackerelclient.NewFromContext(c)
+	var inputs []*reportInput
+	if c.Bool("stdin") {
+		var err error
+		if inputs, err = readReportInputs(os.Stdin); err != nil {
+			return cli.NewExitError(fmt.Sprintf("failed to read the check reports: %s", err), 1)
+		}
+	} else {
+		if c.String("name") == "" || c.String("status") == "" {
+			cli.ShowCommandHelp(c, "check-report")
+			os.Exit(1)
+		}
+		hostID, err := mackerelclient.HostIDFromContext(c, client, "host-id")
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		inputs = []*reportInput{{
+			Name:                 c.String("name"),
+			HostID:               hostID,
+			Status:               c.String("status"),
+			Message:              c.String("message"),
+			NotificationInterval: uint(c.Int("notification-interval")),
+			MaxCheckAttempts:     uint(c.Int("max-check-attempts")),
+		}}
+	}
+	for _, in := range inputs {
+		var err error
+		if in.HostID, err = mackerelclient.ResolveHostID(client, in.HostID); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+	reports, err := buildCheckReports(inputs, mackerelclient.LoadHostIDFromConfig(c.GlobalString("conf")), time.Now())
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	logger.DieIf(client.PostCheckReports(reports))
+	logger.Log("info", fmt.Sprintf("Check reports are posted (%d reports).", len(reports.Reports)))
+	return nil
+}
@@ -0,0 +1,60 @@
+package checks
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadReportInputs(t *testing.T) {
+	inputs, err := readReportInputs(strings.NewReader(`
+{"name": "backup", "status": "OK"}
+[{"name": "disk", "hostId": "2eQGEaLxibb", "status": "warning", "message": "80% used"}, {"name": "cron", "status": "CRITICAL"}]
+`))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(inputs) != 3 || inputs[0].Name != "backup" || inputs[1].HostID != "2eQGEaLxibb" || inputs[2].Name != "cron" {
+		t.Errorf("unexpected inputs: %+v", inputs)
+	}
+
+	if _, err := readReportInputs(strings.NewReader(`{"name": "backup"`)); err == nil {
+		t.Errorf("error should occur for broken JSON")
+	}
+}
+
+func TestBuildCheckReports(t *testing.T) {
+	now := time.Unix(1609462800, 0)
+	reports, err := buildCheckReports([]*reportInput{
+		{Name: "backup", Status: "ok"},
+		{Name: "disk", HostID: "2eQGEaLxibb", Status: "WARNING", Message: "80% used", OccurredAt: 1609459200, MaxCheckAttempts: 3},
+	}, "2eQGEaLxiba", now)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, _ := json.Marshal(reports)
+	want := `{"reports":[` +
+		`{"source":{"type":"host","hostId":"2eQGEaLxiba"},"name":"backup","status":"OK","message":"","occurredAt":1609462800},` +
+		`{"source":{"type":"host","hostId":"2eQGEaLxibb"},"name":"disk","status":"WARNING","message":"80% used","occurredAt":1609459200,"maxCheckAttempts":3}]}`
+	if string(data) != want {
+		t.Errorf("reports should be\n%s\nbut got\n%s", want, data)
+	}
+
+	testCases := []struct {
+		inputs []*reportInput
+		hostID string
+		err    string
+	}{
+		{inputs: nil, hostID: "2eQGEaLxiba", err: "no check reports"},
+		{inputs: []*reportInput{{Status: "OK"}}, hostID: "2eQGEaLxiba", err: "the name of the check report is empty"},
+		{inputs: []*reportInput{{Name: "backup", Status: "OK"}}, hostID: "", err: "the host ID of the check report backup is not specified"},
+		{inputs: []*reportInput{{Name: "backup", Status: "FAILED"}}, hostID: "2eQGEaLxiba", err: `the status of the check report backup should be OK, WARNING, CRITICAL or UNKNOWN: "FAILED"`},
+	}
+	for _, tc := range testCases {
+		_, err := buildCheckReports(tc.inputs, tc.hostID, now)
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("error should be %q but got %v", tc.err, err)
+		}
+	}
+}
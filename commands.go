@@ -1,21 +1,32 @@
 package main
 
 import (
+	"fmt"
+	"io"
+	"math"
 	"os"
 	"strings"
 	"time"
 
 	"github.com/Songmu/prompter"
 	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/alertgroups"
+	"github.com/mackerelio/mkr/awsintegrations"
 	"github.com/mackerelio/mkr/channels"
 	"github.com/mackerelio/mkr/checks"
+	"github.com/mackerelio/mkr/doctor"
+	"github.com/mackerelio/mkr/downtimes"
+	"github.com/mackerelio/mkr/export"
 	"github.com/mackerelio/mkr/format"
 	"github.com/mackerelio/mkr/hosts"
 	"github.com/mackerelio/mkr/logger"
 	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/mackerelio/mkr/notificationgroups"
 	"github.com/mackerelio/mkr/org"
 	"github.com/mackerelio/mkr/plugin"
 	"github.com/mackerelio/mkr/services"
+	"github.com/mackerelio/mkr/top"
+	"github.com/mackerelio/mkr/users"
 	"github.com/mackerelio/mkr/wrap"
 	"github.com/urfave/cli"
 )
@@ -27,41 +38,80 @@ var Commands = []cli.Command{
 	hosts.CommandCreate,
 	commandUpdate,
 	commandThrow,
+	commandGraphDefs,
 	commandMetrics,
 	commandFetch,
+	commandMetricNames,
+	commandQuery,
 	commandRetire,
 	services.Command,
 	commandMonitors,
 	channels.Command,
+	downtimes.Command,
+	notificationgroups.Command,
 	commandAlerts,
+	alertgroups.Command,
 	commandDashboards,
 	commandAnnotations,
+	commandDeploy,
+	commandApply,
+	commandCopy,
+	commandBackup,
+	commandRestore,
+	commandBridge,
+	commandListen,
+	commandEvents,
+	awsintegrations.Command,
 	org.Command,
+	doctor.Command,
+	commandAPI,
+	export.Command,
+	commandConfigure,
+	users.Command,
+	users.CommandInvitations,
 	plugin.CommandPlugin,
 	checks.Command,
+	checks.CommandReport,
 	wrap.Command,
+	top.Command,
 }
 
 var commandStatus = cli.Command{
 	Name:      "status",
-	Usage:     "Show the host",
-	ArgsUsage: "[--verbose | -v] <hostId>",
+	Usage:     "Show the hosts",
+	ArgsUsage: "[--verbose | -v] [--service | -s <service> [[--role | -r <role>]...]] [--with-metrics] [--output | -o <format>] [--parallel <num>] [--watch [--interval <duration>]] [<hostIds...>]",
 	Description: `
-    Show the information of the host identified with <hostId>.
-    Requests "GET /api/v0/hosts/<hostId>". See https://mackerel.io/api-docs/entry/hosts#get .
+    Show the information of the hosts identified with <hostIds>, or the host of the agent if not specified.
+    The hosts can also be selected by --service and --role, and are shown in a JSON array or a table with --output table.
+    With --with-metrics, the latest loadavg5, cpu, memory and disk usages and the open alerts of the hosts are also shown,
+    with the state of the host: CRITICAL with a connectivity alert, WARNING with the other alerts, or OK.
+    With --watch, the output is cleared and re-rendered on every --interval.
+    Requests "GET /api/v0/hosts/<hostId>" concurrently. See https://mackerel.io/api-docs/entry/hosts#get .
 `,
 	Action: doStatus,
-	Flags: []cli.Flag{
+	Flags: append([]cli.Flag{
 		cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
-	},
+		cli.StringFlag{Name: "service, s", Value: "", Usage: "Show the hosts belonging to <service>."},
+		cli.StringSliceFlag{
+			Name:  "role, r",
+			Value: &cli.StringSlice{},
+			Usage: "Show the hosts belonging to <role>. Multiple choices are allowed. Required --service",
+		},
+		cli.StringFlag{Name: "output, o", Value: "json", Usage: format.OutputUsage},
+		cli.IntFlag{Name: "parallel", Value: 4, Usage: "Number of the hosts fetched concurrently."},
+		cli.BoolFlag{Name: "with-metrics", Usage: "Show the latest metrics and the open alerts of the hosts"},
+	}, format.WatchFlags...),
 }
 
 var commandUpdate = cli.Command{
 	Name:      "update",
 	Usage:     "Update the host",
-	ArgsUsage: "[--name | -n <name>] [--displayName <displayName>] [--status | -st <status>] [--roleFullname | -R <service:role>] [--overwriteRoles | -o] [<hostIds...>]",
+	ArgsUsage: "[--name | -n <name>] [--displayName <displayName>] [--status | -st <status>] [--roleFullname | -R <service:role>] [--overwriteRoles | -o] [--service | -s <service> [[--role | -r <role>]...]] [--dry-run] [--force] [<hostIds...>] | --file-path | -F <file> [--dry-run] [--force]",
 	Description: `
     Update the host identified with <hostId>.
+    The hosts can also be selected by --service and --role, which are updated after confirmation.
+    With --file-path, the name, displayName, status and roleFullnames of the hosts listed in the YAML or JSON file
+    are updated after showing the changes. The hosts are matched by id or customIdentifier.
     Requests "PUT /api/v0/hosts/<hostId>". See https://mackerel.io/api-docs/entry/hosts#update-information .
 `,
 	Action: doUpdate,
@@ -75,13 +125,22 @@ var commandUpdate = cli.Command{
 			Usage: "Update rolefullname.",
 		},
 		cli.BoolFlag{Name: "overwriteRoles, o", Usage: "Overwrite roles instead of adding specified roles."},
+		cli.StringFlag{Name: "service, s", Value: "", Usage: "Update the hosts belonging to <service>."},
+		cli.StringSliceFlag{
+			Name:  "role, r",
+			Value: &cli.StringSlice{},
+			Usage: "Update the hosts belonging to <role>. Multiple choices are allowed. Required --service",
+		},
+		cli.BoolFlag{Name: "dry-run", Usage: "Show the hosts to be updated, but not update them."},
+		cli.BoolFlag{Name: "force", Usage: "Update the selected hosts without confirmation."},
+		cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Update the hosts as described in <file>."},
 	},
 }
 
 var commandMetrics = cli.Command{
 	Name:      "metrics",
 	Usage:     "Fetch metric values",
-	ArgsUsage: "[--host | -H <hostId>] [--service | -s <service>] [--name | -n <metricName>] --from int --to int",
+	ArgsUsage: "[--host | -H <hostId> | --host-name <name>] [--service | -s <service>] [--name | -n <metricName>] --from int --to int",
 	Description: `
     Fetch metric values of 'host metric' or 'service metric'.
     Requests "/api/v0/hosts/<hostId>/metrics" or "/api/v0/services/<serviceName>/tsdb".
@@ -90,6 +149,7 @@ var commandMetrics = cli.Command{
 	Action: doMetrics,
 	Flags: []cli.Flag{
 		cli.StringFlag{Name: "host, H", Value: "", Usage: "Fetch host metric values of <hostID>."},
+		mackerelclient.HostNameFlag,
 		cli.StringFlag{Name: "service, s", Value: "", Usage: "Fetch service metric values of <service>."},
 		cli.StringFlag{Name: "name, n", Value: "", Usage: "The name of the metric for which you want to obtain the metric."},
 		cli.Int64Flag{Name: "from", Usage: "The first of the period for which you want to obtain the metric. (epoch seconds)"},
@@ -100,10 +160,19 @@ var commandMetrics = cli.Command{
 var commandFetch = cli.Command{
 	Name:      "fetch",
 	Usage:     "Fetch latest metric values",
-	ArgsUsage: "[--name | -n <metricName>] hostIds...",
+	ArgsUsage: "[--name | -n <metricName>] [--from <time>] [--to <time>] [--duration <duration>] [--step <duration> [--agg avg|max|min]] [--output | -o json|csv|tsv|table] [--parallel <num>] (--service | -s <service> [--hosts] [[--role | -r <role>]...] | hostIds...)",
 	Description: `
-    Fetch latest metric values about the hosts.
+    Fetch latest metric values about the hosts, or the service with --service.
+    With --hosts or --role, fetches the values of the hosts belonging to --service and --role instead,
+    which are requested for up to 100 hosts at once concurrently. With --output table, the latest values are shown
+    in a matrix of the hosts and the metrics.
     Requests "GET /api/v0/tsdb/latest". See https://mackerel.io/api-docs/entry/host-metrics#get-latest .
+    The latest service metric values are searched within the last 24 hours.
+    With --from, --to or --duration, fetches the metric values over the range instead.
+    The times are specified in RFC3339, epoch seconds or durations before now such as 30m or 7d.
+    The values are aggregated in each --step by --agg.
+    Requests "GET /api/v0/hosts/<hostId>/metrics". See https://mackerel.io/api-docs/entry/host-metrics#get .
+    Requests "GET /api/v0/services/<serviceName>/metrics" for the service. See https://mackerel.io/api-docs/entry/service-metrics#get .
 `,
 	Action: doFetch,
 	Flags: []cli.Flag{
@@ -112,53 +181,137 @@ var commandFetch = cli.Command{
 			Value: &cli.StringSlice{},
 			Usage: "Fetch metric values identified with <name>. Required. Multiple choices are allowed. ",
 		},
+		cli.StringFlag{Name: "service, s", Value: "", Usage: "Fetch service metric values of <service>."},
+		cli.BoolFlag{Name: "hosts", Usage: "Fetch the host metric values of the hosts belonging to --service instead of the service metric values."},
+		cli.StringSliceFlag{
+			Name:  "role, r",
+			Value: &cli.StringSlice{},
+			Usage: "Fetch the host metric values of the hosts belonging to <role>. Multiple choices are allowed. Required --service",
+		},
+		cli.IntFlag{Name: "parallel", Value: 4, Usage: "Number of the requests of the latest metric values sent concurrently, each of which fetches up to 100 hosts."},
+		cli.StringFlag{Name: "from", Value: "", Usage: "Fetch the metric values since <time>."},
+		cli.StringFlag{Name: "to", Value: "", Usage: "Fetch the metric values until <time>. Defaults to now."},
+		cli.StringFlag{Name: "duration", Value: "", Usage: "Fetch the metric values for <duration> until --to."},
+		cli.StringFlag{Name: "step", Value: "", Usage: "Downsample the metric values in each <duration>."},
+		cli.StringFlag{Name: "agg", Value: "avg", Usage: "Aggregation of downsampling: 'avg', 'max' or 'min'"},
+		cli.StringFlag{Name: "output, o", Value: "json", Usage: "Output format: 'json', 'csv', 'tsv' or 'table'"},
 	},
 }
 
 var commandRetire = cli.Command{
 	Name:      "retire",
 	Usage:     "Retire hosts",
-	ArgsUsage: "[--force] hostIds...",
+	ArgsUsage: "[--service | -s <service> [[--role | -r <role>]...]] [[--status | -st <status>]...] [--older-than <duration>] [--freshness <duration>] [--force] [hostIds...]",
 	Description: `
     Retire host identified by <hostId>. Be careful because this is an irreversible operation.
+    The hosts can also be selected by --service, --role, --status and --older-than.
+    The selected hosts which have posted the agent metrics (loadavg5, cpu.user.percentage or memory.used) within --freshness are not retired unless --force is specified.
     Requests POST /api/v0/hosts/<hostId>/retire parallelly. See https://mackerel.io/api-docs/entry/hosts#retire .
 `,
 	Action: doRetire,
 	Flags: []cli.Flag{
+		cli.StringFlag{Name: "service, s", Value: "", Usage: "Retire the hosts belonging to <service>."},
+		cli.StringSliceFlag{
+			Name:  "role, r",
+			Value: &cli.StringSlice{},
+			Usage: "Retire the hosts belonging to <role>. Multiple choices are allowed. Required --service",
+		},
+		cli.StringSliceFlag{
+			Name:  "status, st",
+			Value: &cli.StringSlice{},
+			Usage: "Retire the hosts with <status>. Multiple choices are allowed.",
+		},
+		cli.StringFlag{Name: "older-than", Value: "", Usage: "Retire the hosts created more than <duration> ago (e.g. 12h, 30d)."},
+		cli.StringFlag{Name: "freshness", Value: "1h", Usage: "Refuse to retire the selected hosts which have posted metrics within <duration>."},
 		cli.BoolFlag{Name: "force", Usage: "Force retirement without confirmation."},
 	},
 }
 
 func doStatus(c *cli.Context) error {
 	confFile := c.GlobalString("conf")
-	argHostID := c.Args().Get(0)
+	argHostIDs := c.Args()
 	isVerbose := c.Bool("verbose")
+	withMetrics := c.Bool("with-metrics")
+	selector := &hostSelector{service: c.String("service"), roles: c.StringSlice("role")}
 
-	if argHostID == "" {
-		if argHostID = mackerelclient.LoadHostIDFromConfig(confFile); argHostID == "" {
+	if len(argHostIDs) < 1 && selector.isEmpty() {
+		hostID := mackerelclient.LoadHostIDFromConfig(confFile)
+		if hostID == "" {
 			cli.ShowCommandHelp(c, "status")
 			os.Exit(1)
 		}
+		argHostIDs = []string{hostID}
+	}
+	// a host is shown in an object as before, and multiple hosts in an array
+	isSingle := len(argHostIDs) == 1 && selector.isEmpty()
+	output, err := format.OutputFromContext(c, "json")
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
 	}
 
-	host, err := mackerelclient.NewFromContext(c).FindHost(argHostID)
-	logger.DieIf(err)
-
-	if isVerbose {
-		format.PrettyPrintJSON(os.Stdout, host)
-	} else {
-		format.PrettyPrintJSON(os.Stdout, &format.Host{
-			ID:            host.ID,
-			Name:          host.Name,
-			DisplayName:   host.DisplayName,
-			Status:        host.Status,
-			RoleFullnames: host.GetRoleFullnames(),
-			IsRetired:     host.IsRetired,
-			CreatedAt:     format.ISO8601Extended(host.DateFromCreatedAt()),
-			IPAddresses:   host.IPAddresses(),
-		})
+	client := mackerelclient.NewFromContext(c)
+	if argHostIDs, err = mackerelclient.ResolveHostIDs(client, argHostIDs); err != nil {
+		return cli.NewExitError(err.Error(), 1)
 	}
-	return nil
+	return format.WatchFromContext(c, os.Stdout, func(w io.Writer) error {
+		hosts, errs, err := findStatusHosts(client, argHostIDs, selector, c.Int("parallel"))
+		if err != nil {
+			return err
+		}
+		if isSingle && len(errs) > 0 {
+			return errs[0]
+		}
+		for _, err := range errs {
+			logger.Log("error", err.Error())
+		}
+
+		var healths map[string]*hostHealth
+		if withMetrics {
+			alerts, err := fetchAlerts(client, false, math.MaxInt32)
+			if err != nil {
+				return err
+			}
+			if healths, err = fetchHostHealths(client, hosts, alerts); err != nil {
+				return err
+			}
+		}
+		status := func(h *mackerel.Host) interface{} {
+			switch {
+			case isVerbose && withMetrics:
+				return &hostWithHealth{Host: h, Health: healths[h.ID]}
+			case isVerbose:
+				return h
+			case withMetrics:
+				return &hostStatusWithHealth{Host: hostStatus(h), Health: healths[h.ID]}
+			}
+			return hostStatus(h)
+		}
+
+		var v interface{}
+		if isSingle {
+			v = status(hosts[0])
+		} else {
+			statuses := make([]interface{}, 0, len(hosts))
+			for _, h := range hosts {
+				statuses = append(statuses, status(h))
+			}
+			v = statuses
+		}
+		if err := output.Print(w, v, func() *format.Table {
+			if withMetrics {
+				return hostHealthsTable(hosts, healths)
+			}
+			return hostStatusesTable(hosts)
+		}); err != nil {
+			return err
+		}
+		if withMetrics && output.Format != format.OutputTable {
+			for _, h := range hosts {
+				logger.Log("health", fmt.Sprintf("%s %s: %s", h.ID, h.Name, healths[h.ID].summary()))
+			}
+		}
+		return batchError("fetch", len(errs), len(errs)+len(hosts), "host(s)")
+	})
 }
 
 func doUpdate(c *cli.Context) error {
@@ -169,8 +322,17 @@ func doUpdate(c *cli.Context) error {
 	optStatus := c.String("status")
 	optRoleFullnames := c.StringSlice("roleFullname")
 	overwriteRoles := c.Bool("overwriteRoles")
+	selector := &hostSelector{service: c.String("service"), roles: c.StringSlice("role")}
+	isDryRun := c.Bool("dry-run")
+
+	if c.String("file-path") != "" {
+		if len(argHostIDs) > 0 || !selector.isEmpty() || optName != "" || optDisplayName != "" || optStatus != "" || len(optRoleFullnames) > 0 || overwriteRoles {
+			return cli.NewExitError("--file-path cannot be used with the hosts or the fields to update", 1)
+		}
+		return doUpdateFromFile(c)
+	}
 
-	if len(argHostIDs) < 1 {
+	if len(argHostIDs) < 1 && selector.isEmpty() {
 		argHostIDs = make([]string, 1)
 		if argHostIDs[0] = mackerelclient.LoadHostIDFromConfig(confFile); argHostIDs[0] == "" {
 			cli.ShowCommandHelp(c, "update")
@@ -189,21 +351,48 @@ func doUpdate(c *cli.Context) error {
 	}
 
 	client := mackerelclient.NewFromContext(c)
+	argHostIDs, err := mackerelclient.ResolveHostIDs(client, argHostIDs)
+	logger.DieIf(err)
 
-	for _, hostID := range argHostIDs {
+	if !selector.isEmpty() {
+		hosts, err := selectHosts(client, selector)
+		logger.DieIf(err)
+		if len(hosts) == 0 {
+			logger.Log("", "no hosts are selected.")
+			return nil
+		}
+		if !isDryRun && !c.Bool("force") && !prompter.YN("Update following hosts.\n  "+describeHosts(hosts)+"\nAre you sure?", false) {
+			logger.Log("", "update is canceled.")
+			return nil
+		}
+		argHostIDs = mergeHostIDs(argHostIDs, hosts)
+	}
+
+	if isDryRun {
+		for _, hostID := range argHostIDs {
+			logger.Log("update", hostID+" (dry-run)")
+		}
+		return nil
+	}
+
+	update := func(hostID string) error {
 		if needUpdateHostStatus {
-			err := client.UpdateHostStatus(hostID, optStatus)
-			logger.DieIf(err)
+			if err := client.UpdateHostStatus(hostID, optStatus); err != nil {
+				return err
+			}
 		}
 
 		if overwriteRoles {
-			err := client.UpdateHostRoleFullnames(hostID, optRoleFullnames)
-			logger.DieIf(err)
+			if err := client.UpdateHostRoleFullnames(hostID, optRoleFullnames); err != nil {
+				return err
+			}
 		}
 
 		if needUpdateHost {
 			host, err := client.FindHost(hostID)
-			logger.DieIf(err)
+			if err != nil {
+				return err
+			}
 			name := ""
 			if optName == "" {
 				name = host.Name
@@ -225,13 +414,23 @@ func doUpdate(c *cli.Context) error {
 			if needUpdateRolesInHostUpdate {
 				param.RoleFullnames = optRoleFullnames
 			}
-			_, err = client.UpdateHost(hostID, param)
-			logger.DieIf(err)
+			if _, err := client.UpdateHost(hostID, param); err != nil {
+				return err
+			}
 		}
+		return nil
+	}
 
+	var failed int
+	for _, hostID := range argHostIDs {
+		if err := update(hostID); err != nil {
+			logger.Log("error", fmt.Sprintf("failed to update %s: %s", hostID, err))
+			failed++
+			continue
+		}
 		logger.Log("updated", hostID)
 	}
-	return nil
+	return batchError("update", failed, len(argHostIDs), "host(s)")
 }
 
 func split(ids []string, count int) [][]string {
@@ -246,7 +445,6 @@ func split(ids []string, count int) [][]string {
 }
 
 func doMetrics(c *cli.Context) error {
-	optHostID := c.String("host")
 	optService := c.String("service")
 	optMetricName := c.String("name")
 
@@ -257,6 +455,8 @@ func doMetrics(c *cli.Context) error {
 	}
 
 	client := mackerelclient.NewFromContext(c)
+	optHostID, err := mackerelclient.HostIDFromContext(c, client, "host")
+	logger.DieIf(err)
 
 	if optHostID != "" {
 		metricValue, err := client.FetchHostMetricValues(optHostID, optMetricName, from, to)
@@ -278,32 +478,112 @@ func doMetrics(c *cli.Context) error {
 func doFetch(c *cli.Context) error {
 	argHostIDs := c.Args()
 	optMetricNames := c.StringSlice("name")
+	optService := c.String("service")
+	output := format.OutputName(c, "json", "json", "csv", "tsv", "table")
+	selector := &hostSelector{roles: c.StringSlice("role")}
+	if c.Bool("hosts") || len(selector.roles) > 0 {
+		// --service selects the hosts instead of the service metrics
+		selector.service, optService = optService, ""
+	}
 
-	if len(argHostIDs) < 1 || len(optMetricNames) < 1 {
+	if (len(argHostIDs) < 1 && optService == "" && selector.isEmpty()) || len(optMetricNames) < 1 {
 		cli.ShowCommandHelp(c, "fetch")
 		os.Exit(1)
 	}
+	isLatest := c.String("from") == "" && c.String("to") == "" && c.String("duration") == ""
+	if output != "json" && output != "csv" && output != "tsv" && output != "table" {
+		return fmt.Errorf("output should be 'json', 'csv', 'tsv' or 'table': %s", output)
+	}
+	if output == "table" && (optService != "" || !isLatest) {
+		return fmt.Errorf("output 'table' is supported only for the latest metric values of the hosts")
+	}
 
-	allMetricValues := make(mackerel.LatestMetricValues)
-	// Fetches 100 hosts per one request (to avoid URL maximum length).
-	for _, hostIds := range split(argHostIDs, 100) {
-		metricValues, err := mackerelclient.NewFromContext(c).FetchLatestMetricValues(hostIds, optMetricNames)
+	client := mackerelclient.NewFromContext(c)
+	argHostIDs, err := mackerelclient.ResolveHostIDs(client, argHostIDs)
+	logger.DieIf(err)
+	hostNames := map[string]string{}
+	if !selector.isEmpty() {
+		hosts, err := selectHosts(client, selector)
 		logger.DieIf(err)
-		for key := range metricValues {
-			allMetricValues[key] = metricValues[key]
+		for _, h := range hosts {
+			hostNames[h.ID] = h.Name
+		}
+		if argHostIDs = mergeHostIDs(argHostIDs, hosts); len(argHostIDs) == 0 {
+			logger.Log("", "no hosts are selected.")
+			return nil
 		}
 	}
 
-	format.PrettyPrintJSON(os.Stdout, allMetricValues)
-	return nil
+	if optService != "" && isLatest {
+		now := time.Now()
+		series, err := fetchServiceMetricSeries(client, optService, optMetricNames, now.Add(-serviceMetricLatestLookback).Unix(), now.Unix())
+		logger.DieIf(err)
+		return printMetricSeries(os.Stdout, lastMetricValues(series), output)
+	}
+
+	if isLatest {
+		allMetricValues, err := fetchLatestHostMetricValues(client, argHostIDs, optMetricNames, c.Int("parallel"))
+		logger.DieIf(err)
+
+		switch output {
+		case "json":
+			format.PrettyPrintJSON(os.Stdout, allMetricValues)
+			return nil
+		case "table":
+			o, _ := format.ParseOutput(format.OutputTable)
+			return o.Print(os.Stdout, nil, func() *format.Table {
+				return latestMetricTable(allMetricValues, argHostIDs, hostNames, optMetricNames)
+			})
+		}
+		return printMetricSeries(os.Stdout, latestMetricSeries(allMetricValues, argHostIDs, optMetricNames), output)
+	}
+
+	from, to, err := metricRange(c, time.Now())
+	if err != nil {
+		return err
+	}
+	var step int64
+	if c.String("step") != "" {
+		if step, err = parseWidgetPeriod(c.String("step")); err != nil {
+			return err
+		}
+	}
+	agg, ok := metricAggregators[c.String("agg")]
+	if !ok {
+		return fmt.Errorf("agg should be 'avg', 'max' or 'min': %s", c.String("agg"))
+	}
+
+	var series []*metricSeries
+	if optService != "" {
+		series, err = fetchServiceMetricSeries(client, optService, optMetricNames, from, to)
+	} else {
+		series, err = fetchHostMetricSeries(client, argHostIDs, optMetricNames, from, to)
+	}
+	logger.DieIf(err)
+	if step > 0 {
+		for _, s := range series {
+			s.values = downsampleMetricValues(s.values, step, agg)
+		}
+	}
+	return printMetricSeries(os.Stdout, series, output)
 }
 
 func doRetire(c *cli.Context) error {
 	confFile := c.GlobalString("conf")
 	force := c.Bool("force")
 	argHostIDs := c.Args()
+	selector := &hostSelector{
+		service:  c.String("service"),
+		roles:    c.StringSlice("role"),
+		statuses: c.StringSlice("status"),
+	}
+	if olderThan := c.String("older-than"); olderThan != "" {
+		d, err := parseWidgetPeriod(olderThan)
+		logger.DieIf(err)
+		selector.createdBefore = time.Now().Add(-time.Duration(d) * time.Second)
+	}
 
-	if len(argHostIDs) < 1 {
+	if len(argHostIDs) < 1 && selector.isEmpty() {
 		argHostIDs = make([]string, 1)
 		if argHostIDs[0] = mackerelclient.LoadHostIDFromConfig(confFile); argHostIDs[0] == "" {
 			cli.ShowCommandHelp(c, "retire")
@@ -311,13 +591,43 @@ func doRetire(c *cli.Context) error {
 		}
 	}
 
-	if !force && !prompter.YN("Retire following hosts.\n  "+strings.Join(argHostIDs, "\n  ")+"\nAre you sure?", true) {
+	client := mackerelclient.NewFromContext(c)
+	argHostIDs, err := mackerelclient.ResolveHostIDs(client, argHostIDs)
+	logger.DieIf(err)
+
+	prompt := "Retire following hosts."
+	if len(argHostIDs) > 0 {
+		prompt += "\n  " + strings.Join(argHostIDs, "\n  ")
+	}
+	if !selector.isEmpty() {
+		hosts, err := selectHosts(client, selector)
+		logger.DieIf(err)
+		if len(hosts) == 0 && len(argHostIDs) == 0 {
+			logger.Log("", "no hosts are selected.")
+			return nil
+		}
+		if !force {
+			freshness, err := parseWidgetPeriod(c.String("freshness"))
+			logger.DieIf(err)
+			ids := mergeHostIDs(nil, hosts)
+			fresh, err := findFreshHosts(client, ids, time.Now().Add(-time.Duration(freshness)*time.Second))
+			logger.DieIf(err)
+			if len(fresh) > 0 {
+				logger.Log("error", "following hosts have posted metrics recently. specify --force to retire them.\n  "+strings.Join(fresh, "\n  "))
+				logger.Exit(1)
+			}
+		}
+		if len(hosts) > 0 {
+			prompt += "\n  " + describeHosts(hosts)
+		}
+		argHostIDs = mergeHostIDs(argHostIDs, hosts)
+	}
+
+	if !force && !prompter.YN(prompt+"\nAre you sure?", true) {
 		logger.Log("", "retirement is canceled.")
 		return nil
 	}
 
-	client := mackerelclient.NewFromContext(c)
-
 	for _, hostID := range argHostIDs {
 		err := client.RetireHost(hostID)
 		logger.DieIf(err)
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/Songmu/prompter"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandConfigure = cli.Command{
+	Name:      "configure",
+	Usage:     "Configure the apikey of a profile",
+	ArgsUsage: "[--keychain | --apikey-command <command>]",
+	Description: `
+    Configure the apikey of the profile selected by --profile (or "default") in ~/.config/mkr/config.toml.
+    The apikey is prompted and stored in the OS keychain with --keychain (security on macOS, secret-tool of
+    Secret Service on Linux, Credential Manager on Windows), or in the configuration file otherwise. With --apikey-command, the apikey is not
+    stored anywhere and the output of the command (e.g. "vault kv get -field=apikey secret/mackerel") is used.
+    The apibase is also stored when --apibase is specified.
+    The "default" profile is used when neither MACKEREL_APIKEY nor mackerel-agent.conf specifies the apikey.
+`,
+	Action: doConfigure,
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "keychain", Usage: "Store the apikey in the OS keychain"},
+		cli.StringFlag{Name: "apikey-command", Value: "", Usage: "Use the output of <command> as the apikey"},
+	},
+}
+
+type configureParam struct {
+	profile       string
+	apikey        string
+	apikeyCommand string
+	apibase       string
+	keychain      bool
+}
+
+func doConfigure(c *cli.Context) error {
+	param := &configureParam{
+		profile:       c.GlobalString("profile"),
+		apikeyCommand: c.String("apikey-command"),
+		apibase:       c.GlobalString("apibase"),
+		keychain:      c.Bool("keychain"),
+	}
+	if param.profile == "" {
+		param.profile = mackerelclient.DefaultProfile
+	}
+	if param.keychain && param.apikeyCommand != "" {
+		return cli.NewExitError("specify either --keychain or --apikey-command", 1)
+	}
+	if param.apikeyCommand == "" {
+		if param.apikey = prompter.Password("Mackerel apikey"); param.apikey == "" {
+			return cli.NewExitError("apikey is empty", 1)
+		}
+	}
+
+	confFile := mackerelclient.DefaultConfigFile()
+	conf, err := mackerelclient.LoadConfig(confFile)
+	if err != nil {
+		return err
+	}
+	if err := configureProfile(conf, param, mackerelclient.StoreKeychainApikey); err != nil {
+		return err
+	}
+	// make sure that the apikey is available, especially from apikey_command
+	if _, err := conf.Apikey(param.profile); err != nil {
+		return err
+	}
+	if err := conf.Save(confFile); err != nil {
+		return err
+	}
+	logger.Log("", fmt.Sprintf("Configured profile %q in %s", param.profile, confFile))
+	return nil
+}
+
+// configureProfile updates the profile in conf. Only one of the apikey, the apikey command
+// and the keychain is kept in the profile.
+func configureProfile(conf *mackerelclient.Config, param *configureParam, storeKeychain func(profile, apikey string) error) error {
+	if conf.Profiles == nil {
+		conf.Profiles = make(map[string]*mackerelclient.Profile)
+	}
+	p, ok := conf.Profiles[param.profile]
+	if !ok {
+		p = &mackerelclient.Profile{}
+		conf.Profiles[param.profile] = p
+	}
+	p.Apikey, p.ApikeyCommand, p.ApikeyKeychain = "", "", false
+	switch {
+	case param.apikeyCommand != "":
+		p.ApikeyCommand = param.apikeyCommand
+	case param.keychain:
+		if err := storeKeychain(param.profile, param.apikey); err != nil {
+			return err
+		}
+		p.ApikeyKeychain = true
+	default:
+		p.Apikey = param.apikey
+	}
+	if param.apibase != "" {
+		p.Apibase = param.apibase
+	}
+	return nil
+}
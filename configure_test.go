@@ -0,0 +1,56 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+func TestConfigureProfile(t *testing.T) {
+	conf := &mackerelclient.Config{}
+	stored := map[string]string{}
+	storeKeychain := func(profile, apikey string) error {
+		stored[profile] = apikey
+		return nil
+	}
+
+	err := configureProfile(conf, &configureParam{profile: "default", apikey: "PLAIN123", apibase: "https://example.com/"}, storeKeychain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &mackerelclient.Profile{Apikey: "PLAIN123", Apibase: "https://example.com/"}
+	if !reflect.DeepEqual(conf.Profiles["default"], expected) {
+		t.Errorf("profile should be %+v but got %+v", expected, conf.Profiles["default"])
+	}
+
+	// the apikey in the file is removed and the apibase is kept
+	err = configureProfile(conf, &configureParam{profile: "default", apikey: "KEYCHAIN123", keychain: true}, storeKeychain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = &mackerelclient.Profile{ApikeyKeychain: true, Apibase: "https://example.com/"}
+	if !reflect.DeepEqual(conf.Profiles["default"], expected) {
+		t.Errorf("profile should be %+v but got %+v", expected, conf.Profiles["default"])
+	}
+	if stored["default"] != "KEYCHAIN123" {
+		t.Errorf("apikey should be stored in the keychain but got %+v", stored)
+	}
+
+	err = configureProfile(conf, &configureParam{profile: "prod", apikeyCommand: "echo COMMAND123"}, storeKeychain)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected = &mackerelclient.Profile{ApikeyCommand: "echo COMMAND123"}
+	if !reflect.DeepEqual(conf.Profiles["prod"], expected) {
+		t.Errorf("profile should be %+v but got %+v", expected, conf.Profiles["prod"])
+	}
+
+	err = configureProfile(conf, &configureParam{profile: "staging", apikey: "KEY", keychain: true}, func(string, string) error {
+		return errors.New("the keychain is not supported")
+	})
+	if err == nil {
+		t.Error("the failure of the keychain should be an error")
+	}
+}
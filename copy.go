@@ -0,0 +1,419 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandCopy = cli.Command{
+	Name:      "copy",
+	Usage:     "Copy the resources from an organization to another",
+	ArgsUsage: "[--src-profile <profile>] --dst-profile <profile> [--resources <resources>] [--dry-run]",
+	Description: `
+    Read the services with the roles, the channels, the monitors and the dashboards of the organization of --src-profile,
+    and create them in the organization of --dst-profile. The resources are created with the new IDs,
+    and the resources which already exist in the destination (by the names, or the URL paths of the dashboards) are skipped.
+    The resources which refer to the hosts, or the services and the roles not in the destination, are not copied
+    and reported as unresolvable. With --dry-run, the resources to be copied are shown.
+`,
+	Action: doCopy,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "src-profile", Value: "", Usage: "Profile of the source organization. The apikey of mkr is used if not specified"},
+		cli.StringFlag{Name: "dst-profile", Value: "", Usage: "Profile of the destination organization"},
+		cli.StringFlag{Name: "resources", Value: strings.Join(copyResources, ","), Usage: "Comma separated resource types to copy"},
+		cli.BoolFlag{Name: "dry-run, d", Usage: "Show the resources to be copied, but not create them"},
+	},
+}
+
+// copyResources are the resources which can be copied, in the order to be copied
+var copyResources = []string{"services", "channels", "monitors", "dashboards"}
+
+type copyClient interface {
+	FindServices() ([]*mackerel.Service, error)
+	CreateService(param *mackerel.CreateServiceParam) (*mackerel.Service, error)
+	FindRoles(serviceName string) ([]*mackerel.Role, error)
+	CreateRole(serviceName string, param *mackerel.CreateRoleParam) (*mackerel.Role, error)
+	FindChannels() ([]*mackerel.Channel, error)
+	CreateChannel(param *mackerel.Channel) (*mackerel.Channel, error)
+	FindMonitors() ([]mackerel.Monitor, error)
+	CreateMonitor(param mackerel.Monitor) (mackerel.Monitor, error)
+	FindDashboards() ([]*mackerel.Dashboard, error)
+	FindDashboard(dashboardID string) (*mackerel.Dashboard, error)
+	CreateDashboard(param *mackerel.Dashboard) (*mackerel.Dashboard, error)
+}
+
+// orgCopier copies the resources from src to dst
+type orgCopier struct {
+	changeCounter
+	src, dst copyClient
+
+	// the roles of the services in the destination, including the ones to be created
+	dstRoles map[string]map[string]bool
+}
+
+func newOrgCopier(src, dst copyClient, isDryRun bool) *orgCopier {
+	return &orgCopier{changeCounter: changeCounter{isDryRun: isDryRun}, src: src, dst: dst}
+}
+
+func (oc *orgCopier) copy(resources []string) error {
+	for _, r := range resources {
+		if !containsString(copyResources, r) {
+			return fmt.Errorf("unknown resource: %s (should be one of %v)", r, copyResources)
+		}
+	}
+	// the services in the destination are needed to resolve the scopes of the monitors and the dashboards
+	if err := oc.loadDstRoles(); err != nil {
+		return err
+	}
+	for _, r := range copyResources {
+		if !containsString(resources, r) {
+			continue
+		}
+		var err error
+		switch r {
+		case "services":
+			err = oc.copyServices()
+		case "channels":
+			err = oc.copyChannels()
+		case "monitors":
+			err = oc.copyMonitors()
+		case "dashboards":
+			err = oc.copyDashboards()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %s", r, err)
+		}
+	}
+	return nil
+}
+
+func (oc *orgCopier) loadDstRoles() error {
+	services, err := oc.dst.FindServices()
+	if err != nil {
+		return err
+	}
+	oc.dstRoles = make(map[string]map[string]bool, len(services))
+	for _, s := range services {
+		oc.dstRoles[s.Name] = map[string]bool{}
+		for _, r := range s.Roles {
+			oc.dstRoles[s.Name][r] = true
+		}
+	}
+	return nil
+}
+
+func (oc *orgCopier) copyServices() error {
+	services, err := oc.src.FindServices()
+	if err != nil {
+		return err
+	}
+	for _, s := range services {
+		if oc.dstRoles[s.Name] != nil {
+			oc.skip(fmt.Sprintf("the service %s which exists", s.Name))
+		} else if !oc.dryRun("Create", "the service "+s.Name) {
+			if _, err := oc.dst.CreateService(&mackerel.CreateServiceParam{Name: s.Name, Memo: s.Memo}); err != nil {
+				oc.fail(fmt.Sprintf("failed to create the service %s: %s", s.Name, err))
+				continue
+			}
+			oc.succeed("Create", "the service "+s.Name)
+		}
+		if oc.dstRoles[s.Name] == nil {
+			oc.dstRoles[s.Name] = map[string]bool{}
+		}
+
+		roles, err := oc.src.FindRoles(s.Name)
+		if err != nil {
+			return err
+		}
+		for _, r := range roles {
+			fullname := s.Name + ":" + r.Name
+			if oc.dstRoles[s.Name][r.Name] {
+				oc.skip(fmt.Sprintf("the role %s which exists", fullname))
+				continue
+			}
+			if !oc.dryRun("Create", "the role "+fullname) {
+				if _, err := oc.dst.CreateRole(s.Name, &mackerel.CreateRoleParam{Name: r.Name, Memo: r.Memo}); err != nil {
+					oc.fail(fmt.Sprintf("failed to create the role %s: %s", fullname, err))
+					continue
+				}
+				oc.succeed("Create", "the role "+fullname)
+			}
+			oc.dstRoles[s.Name][r.Name] = true
+		}
+	}
+	return nil
+}
+
+// copyableChannelTypes are the types of the channels which can be created by the API
+var copyableChannelTypes = map[string]bool{"email": true, "slack": true, "webhook": true}
+
+func (oc *orgCopier) copyChannels() error {
+	channels, err := oc.src.FindChannels()
+	if err != nil {
+		return err
+	}
+	dstChannels, err := oc.dst.FindChannels()
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, ch := range dstChannels {
+		names[ch.Name] = true
+	}
+	for _, ch := range channels {
+		if names[ch.Name] {
+			oc.skip(fmt.Sprintf("the channel %s which exists", ch.Name))
+			continue
+		}
+		if !copyableChannelTypes[ch.Type] {
+			oc.fail(fmt.Sprintf("the channel %s (%s) cannot be copied: the type %s is not supported by the API", ch.Name, ch.ID, ch.Type))
+			continue
+		}
+		param := *ch
+		param.ID = ""
+		if param.UserIDs != nil && len(*param.UserIDs) > 0 {
+			// the users are not shared between the organizations, while the email addresses are kept
+			logger.Log("warning", fmt.Sprintf("the users of the channel %s are not copied: %s", ch.Name, strings.Join(*param.UserIDs, ", ")))
+			param.UserIDs = &[]string{}
+		}
+		if oc.dryRun("Create", "the channel "+ch.Name) {
+			continue
+		}
+		created, err := oc.dst.CreateChannel(&param)
+		if err != nil {
+			oc.fail(fmt.Sprintf("failed to create the channel %s: %s", ch.Name, err))
+			continue
+		}
+		oc.succeed("Create", fmt.Sprintf("the channel %s (%s -> %s)", ch.Name, ch.ID, created.ID))
+	}
+	return nil
+}
+
+var expressionHostPattern = regexp.MustCompile(`\bhost\(\s*([^,\s)]+)`)
+
+// unresolvedExpression returns the problem of the references to the hosts in the expression,
+// which are not shared between the organizations
+func unresolvedExpression(expression string) string {
+	var hostIDs []string
+	for _, m := range expressionHostPattern.FindAllStringSubmatch(expression, -1) {
+		hostIDs = append(hostIDs, m[1])
+	}
+	if len(hostIDs) == 0 {
+		return ""
+	}
+	return "refers to the hosts " + strings.Join(hostIDs, ", ")
+}
+
+// unresolvedScope returns the problem of the scope such as "service" or "service:role" in the destination
+func (oc *orgCopier) unresolvedScope(scope string) string {
+	// the scopes may be excluded with '!', and written as "service: role"
+	scope = strings.Replace(strings.TrimPrefix(scope, "!"), ": ", ":", 1)
+	xs := strings.SplitN(scope, ":", 2)
+	roles, ok := oc.dstRoles[xs[0]]
+	if !ok {
+		return "refers to the service " + xs[0]
+	}
+	if len(xs) == 2 && !roles[xs[1]] {
+		return "refers to the role " + xs[0] + ":" + xs[1]
+	}
+	return ""
+}
+
+func (oc *orgCopier) unresolvedMonitor(m mackerel.Monitor) []string {
+	var problems []string
+	for _, scope := range monitorScopes(m) {
+		if p := oc.unresolvedScope(scope); p != "" {
+			problems = append(problems, p)
+		}
+	}
+	if m, ok := m.(*mackerel.MonitorExpression); ok {
+		if p := unresolvedExpression(m.Expression); p != "" {
+			problems = append(problems, p)
+		}
+	}
+	return problems
+}
+
+func (oc *orgCopier) copyMonitors() error {
+	monitors, err := oc.src.FindMonitors()
+	if err != nil {
+		return err
+	}
+	dstMonitors, err := oc.dst.FindMonitors()
+	if err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, m := range dstMonitors {
+		names[m.MonitorType()+":"+m.MonitorName()] = true
+	}
+	for _, m := range monitors {
+		label := fmt.Sprintf("the monitor %s (%s)", m.MonitorName(), m.MonitorID())
+		if names[m.MonitorType()+":"+m.MonitorName()] {
+			oc.skip(label + " which exists")
+			continue
+		}
+		if problems := oc.unresolvedMonitor(m); len(problems) > 0 {
+			oc.fail(fmt.Sprintf("%s cannot be copied: %s", label, strings.Join(problems, "; ")))
+			continue
+		}
+		if oc.dryRun("Create", label) {
+			continue
+		}
+		param, err := monitorWithoutID(m)
+		if err != nil {
+			return err
+		}
+		created, err := oc.dst.CreateMonitor(param)
+		if err != nil {
+			oc.fail(fmt.Sprintf("failed to create %s: %s", label, err))
+			continue
+		}
+		oc.succeed("Create", fmt.Sprintf("the monitor %s (%s -> %s)", m.MonitorName(), m.MonitorID(), created.MonitorID()))
+	}
+	return nil
+}
+
+// monitorWithoutID returns the copy of the monitor without the ID to be created
+func monitorWithoutID(m mackerel.Monitor) (mackerel.Monitor, error) {
+	data, err := json.Marshal(m)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	delete(fields, "id")
+	if data, err = json.Marshal(fields); err != nil {
+		return nil, err
+	}
+	return decodeMonitor(data)
+}
+
+func (oc *orgCopier) unresolvedDashboard(d *mackerel.Dashboard) []string {
+	var problems []string
+	for i, w := range d.Widgets {
+		var hostID, scope, expression string
+		switch w.Type {
+		case "graph":
+			hostID, expression = w.Graph.HostID, w.Graph.Expression
+			switch w.Graph.Type {
+			case "role":
+				scope = w.Graph.RoleFullName
+			case "service":
+				scope = w.Graph.ServiceName
+			}
+		case "value":
+			hostID, scope, expression = w.Metric.HostID, w.Metric.ServiceName, w.Metric.Expression
+		}
+		var p string
+		if hostID != "" {
+			p = "refers to the host " + hostID
+		} else if scope != "" {
+			p = oc.unresolvedScope(scope)
+		} else if expression != "" {
+			p = unresolvedExpression(expression)
+		}
+		if p != "" {
+			problems = append(problems, fmt.Sprintf("widgets[%d] %s", i, p))
+		}
+	}
+	return problems
+}
+
+func (oc *orgCopier) copyDashboards() error {
+	dashboards, err := oc.src.FindDashboards()
+	if err != nil {
+		return err
+	}
+	dstDashboards, err := oc.dst.FindDashboards()
+	if err != nil {
+		return err
+	}
+	urlPaths := map[string]bool{}
+	for _, d := range dstDashboards {
+		urlPaths[d.URLPath] = true
+	}
+	for _, d := range dashboards {
+		label := fmt.Sprintf("the dashboard %s (%s)", d.Title, d.ID)
+		if urlPaths[d.URLPath] {
+			oc.skip(label + " which exists")
+			continue
+		}
+		if d.IsLegacy {
+			oc.fail(label + " cannot be copied: legacy dashboards are not supported")
+			continue
+		}
+		// the list of the dashboards does not have the widgets
+		dashboard, err := oc.src.FindDashboard(d.ID)
+		if err != nil {
+			return err
+		}
+		if problems := oc.unresolvedDashboard(dashboard); len(problems) > 0 {
+			oc.fail(fmt.Sprintf("%s cannot be copied: %s", label, strings.Join(problems, "; ")))
+			continue
+		}
+		if oc.dryRun("Create", label) {
+			continue
+		}
+		param := *dashboard
+		param.ID, param.CreatedAt, param.UpdatedAt = "", 0, 0
+		created, err := oc.dst.CreateDashboard(&param)
+		if err != nil {
+			oc.fail(fmt.Sprintf("failed to create %s: %s", label, err))
+			continue
+		}
+		oc.succeed("Create", fmt.Sprintf("the dashboard %s (%s -> %s)", d.Title, d.ID, created.ID))
+	}
+	return nil
+}
+
+// newProfileClient returns the client of the profile, or of the apikey of mkr if the profile is empty
+func newProfileClient(c *cli.Context, profile string) (*mackerel.Client, error) {
+	confFile := c.GlobalString("conf")
+	apikey, err := mackerelclient.ResolveApikey(confFile, profile)
+	if err != nil {
+		return nil, err
+	}
+	return mackerelclient.NewClient(apikey.Value, mackerelclient.ResolveApibase(confFile, c.GlobalString("apibase"), profile))
+}
+
+func doCopy(c *cli.Context) error {
+	srcProfile, dstProfile := c.String("src-profile"), c.String("dst-profile")
+	if srcProfile == "" {
+		srcProfile = c.GlobalString("profile")
+	}
+	if dstProfile == "" {
+		cli.ShowCommandHelp(c, "copy")
+		return cli.NewExitError("specify the profile of the destination with --dst-profile.", 1)
+	}
+	if srcProfile == dstProfile {
+		return cli.NewExitError("the source and the destination should be different profiles.", 1)
+	}
+
+	src, err := newProfileClient(c, srcProfile)
+	logger.DieIf(err)
+	dst, err := newProfileClient(c, dstProfile)
+	logger.DieIf(err)
+
+	var resources []string
+	for _, r := range strings.Split(c.String("resources"), ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			resources = append(resources, r)
+		}
+	}
+
+	oc := newOrgCopier(src, dst, c.Bool("dry-run"))
+	if err := oc.copy(resources); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return oc.result("copy", "resource(s)")
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type fakeCopyClient struct {
+	services   []*mackerel.Service
+	roles      map[string][]*mackerel.Role
+	channels   []*mackerel.Channel
+	monitors   []mackerel.Monitor
+	dashboards []*mackerel.Dashboard
+	requests   []string
+}
+
+func (c *fakeCopyClient) FindServices() ([]*mackerel.Service, error) {
+	return c.services, nil
+}
+
+func (c *fakeCopyClient) CreateService(param *mackerel.CreateServiceParam) (*mackerel.Service, error) {
+	c.requests = append(c.requests, "service "+param.Name)
+	return &mackerel.Service{Name: param.Name, Memo: param.Memo}, nil
+}
+
+func (c *fakeCopyClient) FindRoles(serviceName string) ([]*mackerel.Role, error) {
+	return c.roles[serviceName], nil
+}
+
+func (c *fakeCopyClient) CreateRole(serviceName string, param *mackerel.CreateRoleParam) (*mackerel.Role, error) {
+	c.requests = append(c.requests, "role "+serviceName+":"+param.Name)
+	return &mackerel.Role{Name: param.Name, Memo: param.Memo}, nil
+}
+
+func (c *fakeCopyClient) FindChannels() ([]*mackerel.Channel, error) {
+	return c.channels, nil
+}
+
+func (c *fakeCopyClient) CreateChannel(param *mackerel.Channel) (*mackerel.Channel, error) {
+	var userIDs []string
+	if param.UserIDs != nil {
+		userIDs = *param.UserIDs
+	}
+	c.requests = append(c.requests, fmt.Sprintf("channel %s id=%q users=%v", param.Name, param.ID, userIDs))
+	ch := *param
+	ch.ID = "new-" + param.Name
+	return &ch, nil
+}
+
+func (c *fakeCopyClient) FindMonitors() ([]mackerel.Monitor, error) {
+	return c.monitors, nil
+}
+
+func (c *fakeCopyClient) CreateMonitor(param mackerel.Monitor) (mackerel.Monitor, error) {
+	c.requests = append(c.requests, fmt.Sprintf("monitor %s id=%q", param.MonitorName(), param.MonitorID()))
+	return &mackerel.MonitorConnectivity{ID: "new-" + param.MonitorName(), Name: param.MonitorName()}, nil
+}
+
+func (c *fakeCopyClient) FindDashboards() ([]*mackerel.Dashboard, error) {
+	var dashboards []*mackerel.Dashboard
+	for _, d := range c.dashboards {
+		dashboards = append(dashboards, &mackerel.Dashboard{ID: d.ID, Title: d.Title, URLPath: d.URLPath, IsLegacy: d.IsLegacy})
+	}
+	return dashboards, nil
+}
+
+func (c *fakeCopyClient) FindDashboard(dashboardID string) (*mackerel.Dashboard, error) {
+	for _, d := range c.dashboards {
+		if d.ID == dashboardID {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("dashboard not found: %s", dashboardID)
+}
+
+func (c *fakeCopyClient) CreateDashboard(param *mackerel.Dashboard) (*mackerel.Dashboard, error) {
+	c.requests = append(c.requests, fmt.Sprintf("dashboard %s id=%q widgets=%d", param.URLPath, param.ID, len(param.Widgets)))
+	d := *param
+	d.ID = "new-" + param.URLPath
+	return &d, nil
+}
+
+func newCopySource() *fakeCopyClient {
+	return &fakeCopyClient{
+		services: []*mackerel.Service{{Name: "blog", Roles: []string{"app", "db"}}, {Name: "shop"}},
+		roles: map[string][]*mackerel.Role{
+			"blog": {{Name: "app"}, {Name: "db"}},
+		},
+		channels: []*mackerel.Channel{
+			{ID: "ch1", Name: "mail", Type: "email", Emails: &[]string{"ops@example.com"}, UserIDs: &[]string{"user1"}},
+			{ID: "ch2", Name: "line", Type: "line"},
+			{ID: "ch3", Name: "existing", Type: "webhook", URL: "https://example.com"},
+		},
+		monitors: []mackerel.Monitor{
+			&mackerel.MonitorConnectivity{ID: "mon1", Name: "connectivity", Type: "connectivity", Scopes: []string{"blog: app"}},
+			&mackerel.MonitorServiceMetric{ID: "mon2", Name: "orders", Type: "service", Service: "shop", Metric: "orders"},
+			&mackerel.MonitorExpression{ID: "mon3", Name: "expr", Type: "expression", Expression: "avg(host(2u4PP3TJqbw, loadavg5))"},
+			&mackerel.MonitorHostMetric{ID: "mon4", Name: "cpu", Type: "host", Scopes: []string{"blog:cache"}},
+		},
+		dashboards: []*mackerel.Dashboard{
+			{ID: "dash1", Title: "Blog", URLPath: "blog", Widgets: []mackerel.Widget{
+				{Type: "graph", Graph: mackerel.Graph{Type: "role", RoleFullName: "blog:db", Name: "loadavg5"}},
+			}},
+			{ID: "dash2", Title: "Host", URLPath: "host", Widgets: []mackerel.Widget{
+				{Type: "markdown", Markdown: "# host"},
+				{Type: "value", Metric: mackerel.Metric{Type: "host", HostID: "2u4PP3TJqbw", Name: "loadavg5"}},
+			}},
+		},
+	}
+}
+
+func TestOrgCopier_copy(t *testing.T) {
+	src := newCopySource()
+	dst := &fakeCopyClient{
+		services: []*mackerel.Service{{Name: "blog", Roles: []string{"app"}}},
+		channels: []*mackerel.Channel{{ID: "ch9", Name: "existing", Type: "slack"}},
+	}
+	oc := newOrgCopier(src, dst, false)
+	if err := oc.copy([]string{"dashboards", "monitors", "channels", "services"}); err != nil {
+		t.Fatal(err)
+	}
+	expected := []string{
+		"role blog:db",
+		"service shop",
+		`channel mail id="" users=[]`,
+		`monitor connectivity id=""`,
+		`monitor orders id=""`,
+		`dashboard blog id="" widgets=1`,
+	}
+	if !reflect.DeepEqual(dst.requests, expected) {
+		t.Errorf("requests should be\n  %v\nbut got\n  %v", strings.Join(expected, "\n  "), strings.Join(dst.requests, "\n  "))
+	}
+	if oc.created != 6 || oc.skipped != 3 || oc.failed != 4 {
+		t.Errorf("unexpected counts: created=%d skipped=%d failed=%d", oc.created, oc.skipped, oc.failed)
+	}
+}
+
+func TestOrgCopier_copy_dryRun(t *testing.T) {
+	src := newCopySource()
+	dst := &fakeCopyClient{}
+	oc := newOrgCopier(src, dst, true)
+	if err := oc.copy([]string{"monitors", "services"}); err != nil {
+		t.Fatal(err)
+	}
+	if len(dst.requests) > 0 {
+		t.Errorf("nothing should be created with dry-run: %v", dst.requests)
+	}
+	// the monitors refer to the services to be created
+	if oc.created != 6 || oc.failed != 2 {
+		t.Errorf("unexpected counts: created=%d failed=%d", oc.created, oc.failed)
+	}
+
+	if err := oc.copy([]string{"alerts"}); err == nil || err.Error() != "unknown resource: alerts (should be one of [services channels monitors dashboards])" {
+		t.Errorf("unknown resources should be rejected but got %v", err)
+	}
+}
+
+func TestOrgCopier_unresolvedScope(t *testing.T) {
+	oc := newOrgCopier(newCopySource(), &fakeCopyClient{services: []*mackerel.Service{{Name: "blog", Roles: []string{"app"}}}}, false)
+	if err := oc.loadDstRoles(); err != nil {
+		t.Fatal(err)
+	}
+	testCases := []struct {
+		scope, expected string
+	}{
+		{"blog", ""},
+		{"blog:app", ""},
+		{"blog: app", ""},
+		{"!blog: app", ""},
+		{"blog: db", "refers to the role blog:db"},
+		{"shop", "refers to the service shop"},
+	}
+	for _, tc := range testCases {
+		if got := oc.unresolvedScope(tc.scope); got != tc.expected {
+			t.Errorf("unresolvedScope(%q) should be %q but got %q", tc.scope, tc.expected, got)
+		}
+	}
+}
+
+func TestUnresolvedExpression(t *testing.T) {
+	testCases := []struct {
+		expression, expected string
+	}{
+		{"role(blog:app, loadavg5)", ""},
+		{"max(host(2u4PP3TJqbw, loadavg5), host( 2u4PP3TJqbx,cpu.user.percentage))", "refers to the hosts 2u4PP3TJqbw, 2u4PP3TJqbx"},
+	}
+	for _, tc := range testCases {
+		if got := unresolvedExpression(tc.expression); got != tc.expected {
+			t.Errorf("unresolvedExpression(%q) should be %q but got %q", tc.expression, tc.expected, got)
+		}
+	}
+}
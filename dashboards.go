@@ -1,13 +1,24 @@
 package main
 
 import (
+	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
+	"text/tabwriter"
+	"text/template"
+	"time"
 
+	"github.com/Songmu/prompter"
 	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
 	"github.com/mackerelio/mkr/logger"
 	"github.com/mackerelio/mkr/mackerelclient"
 	"github.com/urfave/cli"
@@ -15,28 +26,195 @@ import (
 )
 
 var commandDashboards = cli.Command{
-	Name:  "dashboards",
-	Usage: "Generating custom dashboards",
+	Name:      "dashboards",
+	Usage:     "Generating custom dashboards",
+	ArgsUsage: "[--output | -o <format>] [--filter-title <title>] [--filter-url-path <urlPath>]",
 	Description: `
-    Generating dashboards. See https://mackerel.io/docs/entry/advanced/cli
+    Generating dashboards. With no subcommand specified, this will show all dashboards.
+    See https://mackerel.io/docs/entry/advanced/cli
 `,
+	Action: doListDashboards,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "output, o", Value: "json", Usage: format.OutputUsage},
+		cli.StringFlag{Name: "filter-title", Value: "", Usage: "Show only the dashboards whose title contains <title> (case-insensitive)."},
+		cli.StringFlag{Name: "filter-url-path", Value: "", Usage: "Show only the dashboards whose urlPath contains <urlPath>."},
+	},
 	Subcommands: []cli.Command{
 		{
 			Name:      "generate",
 			Usage:     "Generate custom dashboard",
-			ArgsUsage: "[--print | -p] <file>",
+			ArgsUsage: "[--print | -p] [--var <key=value>] [--vars-file <file>] <file>",
 			Description: `
     A custom dashboard is registered from a yaml file.
+    When --var or --vars-file is given, the file is expanded as a Go template with the variables, e.g. {{ .service }} or {{ env "ENV" }}.
     Requests "POST /api/v0/dashboards". See https://mackerel.io/api-docs/entry/dashboards#create.
 `,
 			Action: doGenerateDashboards,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.BoolFlag{Name: "print, p", Usage: "markdown is output in standard output."},
+			}, dashboardTemplateFlags...),
+		},
+		{
+			Name:      "generate-from-role",
+			Usage:     "Generate custom dashboard of a role",
+			ArgsUsage: "--service | -s <service> --role | -r <role> [--title <title>] [--url-path <urlPath>] [--print | -p]",
+			Description: `
+    Generate a custom dashboard from the graphs of the hosts in a role. The dashboard contains the system graphs
+    (cpu, memory, disk and interface) and all the plugin graphs present on the hosts.
+    Requests "GET /api/v0/hosts/<hostId>/metric-names" and "POST /api/v0/dashboards". See https://mackerel.io/api-docs/entry/dashboards#create.
+`,
+			Action: doGenerateDashboardFromRole,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "service, s", Value: "", Usage: "Service name of the role."},
+				cli.StringFlag{Name: "role, r", Value: "", Usage: "Role name."},
+				cli.StringFlag{Name: "title", Value: "", Usage: "Title of the dashboard. default: <service>:<role>"},
+				cli.StringFlag{Name: "url-path", Value: "", Usage: "urlPath of the dashboard. default: <service>-<role>"},
+				cli.BoolFlag{Name: "print, p", Usage: "the dashboard is output in standard output."},
+			},
+		},
+		{
+			Name:      "pull",
+			Usage:     "pull dashboards",
+			ArgsUsage: "[--id <dashboardId>] [--url-path <urlPath>] [--dir <dir>] [--filename-format id|url-path|title] [--format json|yaml]",
+			Description: `
+    Pull custom dashboards from Mackerel server and save them to files named "dashboard-<name>.json" (or ".yaml").
+    All the dashboards are pulled unless --id or --url-path is specified.
+    Requests "GET /api/v0/dashboards/<dashboardId>". See https://mackerel.io/api-docs/entry/dashboards#get .
+`,
+			Action: doPullDashboard,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "id", Value: "", Usage: "Pull only the dashboard identified with <dashboardId>."},
+				cli.StringFlag{Name: "url-path", Value: "", Usage: "Pull only the dashboard identified with <urlPath>."},
+				cli.StringFlag{Name: "dir", Value: ".", Usage: "Directory to store dashboard definitions."},
+				cli.StringFlag{Name: "filename-format", Value: "id", Usage: "Name files by 'id', 'url-path' or 'title'."},
+				cli.StringFlag{Name: "format", Value: "json", Usage: "Format of dashboard files, 'json' or 'yaml'."},
+			},
+		},
+		{
+			Name:      "diff",
+			Usage:     "diff a dashboard",
+			ArgsUsage: "--file-path | -F <file> [--reverse] [--var <key=value>] [--vars-file <file>]",
+			Description: `
+    Show difference of a dashboard between Mackerel and a JSON or YAML file.
+    The remote dashboard is looked up by "id" in the file, or by "urlPath" when "id" is not specified.
+    The difference is shown in the unified format of the JSON of the dashboards like "diff -u".
+    Exits with code 1 if there are differences and 0 if there aren't. This is similar to diff(1).
+`,
+			Action: doDiffDashboard,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Filename of the dashboard definition."},
+				cli.BoolFlag{Name: "reverse", Usage: "The difference on the remote server is represented by plus and the difference on the local file is represented by minus"},
+			}, dashboardTemplateFlags...),
+		},
+		{
+			Name:      "push",
+			Usage:     "push a dashboard",
+			ArgsUsage: "--file-path | -F <file> [--format json|yaml] [--dry-run | -d] [--rollback] [--var <key=value>] [--vars-file <file>]",
+			Description: `
+    Push dashboards stored in JSON or YAML files to Mackerel. The dashboard is updated when "id" is specified
+    or a dashboard with the same "urlPath" exists, otherwise it is created.
+    Multiple files (or glob patterns) can be specified. All the files are validated before any of them are pushed,
+    and with --rollback, the pushed dashboards are restored when pushing fails in the middle.
+    When --var or --vars-file is given, the file is expanded as a Go template with the variables, e.g. {{ .service }} or {{ env "ENV" }}.
+    The widgets are validated before pushing.
+    Requests "POST /api/v0/dashboards" or "PUT /api/v0/dashboards/<dashboardId>". See https://mackerel.io/api-docs/entry/dashboards .
+`,
+			Action: doPushDashboard,
+			Flags: append([]cli.Flag{
+				cli.StringSliceFlag{
+					Name:  "file-path, F",
+					Value: &cli.StringSlice{},
+					Usage: "Filename or glob pattern of the dashboard definitions. Multiple choices are allowed.",
+				},
+				cli.StringFlag{Name: "format", Value: "", Usage: "Format of the dashboard file, 'json' or 'yaml'. default: detected by the file extension"},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Validate the dashboard and show which apis are called, but not execute."},
+				cli.BoolFlag{Name: "rollback", Usage: "Restore the pushed dashboards when pushing fails in the middle."},
+			}, dashboardTemplateFlags...),
+		},
+		{
+			Name:      "apply",
+			Usage:     "apply dashboards in a directory",
+			ArgsUsage: "--dir <dir> [--prune] [--dry-run | -d] [--var <key=value>] [--vars-file <file>]",
+			Description: `
+    Reconcile the custom dashboards with JSON and YAML files in a directory. Dashboards which do not exist are created,
+    and ones which differ from the files are updated. With --prune, remote dashboards which are not in the directory are deleted.
+    Dashboards are matched by "id", or by "urlPath" when "id" is not specified.
+    When --var or --vars-file is given, the files are expanded as Go templates with the variables.
+`,
+			Action: doApplyDashboards,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: "dir", Value: "", Usage: "Directory of dashboard definitions."},
+				cli.BoolFlag{Name: "prune", Usage: "Delete remote dashboards which are not in the directory."},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show which apis are called, but not execute."},
+			}, dashboardTemplateFlags...),
+		},
+		{
+			Name:      "export",
+			Usage:     "export a dashboard",
+			ArgsUsage: "--id <dashboardId> [--format json|grafana] [--file-path | -F <file>]",
+			Description: `
+    Export a custom dashboard. With --format grafana, the dashboard is translated into a Grafana dashboard JSON model
+    which uses the Mackerel datasource given by the "${DS_MACKEREL}" variable.
+`,
+			Action: doExportDashboard,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "id", Value: "", Usage: "Export the dashboard identified with <dashboardId>."},
+				cli.StringFlag{Name: "format", Value: "json", Usage: "Format of the output, 'json' or 'grafana'."},
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Filename to write the output. default: standard output"},
+			},
+		},
+		{
+			Name:      "clone",
+			Usage:     "clone a dashboard",
+			ArgsUsage: "--id <dashboardId> --title <title> --url-path <urlPath> [--search <regexp> --replace <replacement>] [--dry-run | -d]",
+			Description: `
+    Create a new dashboard from an existing one with the new title and urlPath.
+    With --search and --replace, the targets of the widgets (host IDs, service names, role fullnames, metric names and expressions)
+    are rewritten by the regular expression, e.g. --search '^blog:app$' --replace 'shop:app'.
+    Requests "POST /api/v0/dashboards". See https://mackerel.io/api-docs/entry/dashboards#create .
+`,
+			Action: doCloneDashboard,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "id", Value: "", Usage: "The dashboard to be cloned."},
+				cli.StringFlag{Name: "title", Value: "", Usage: "Title of the new dashboard."},
+				cli.StringFlag{Name: "url-path", Value: "", Usage: "urlPath of the new dashboard."},
+				cli.StringFlag{Name: "search", Value: "", Usage: "Regular expression to search in the targets of the widgets."},
+				cli.StringFlag{Name: "replace", Value: "", Usage: "Replacement for --search. $1 and so on are expanded to the submatches."},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show the new dashboard, but not create it."},
+			},
+		},
+		{
+			Name:      "migrate",
+			Usage:     "migrate legacy dashboards",
+			ArgsUsage: "--id <dashboardId> | --all [--convert-graphs] [--dry-run | -d] [--force] [--dump-dir <dir>]",
+			Description: `
+    Migrate legacy dashboards to the current dashboards. The markdown of a legacy dashboard is converted into a markdown widget.
+    With --convert-graphs, the embedded graphs (iframes and images) in the markdown are converted into graph widgets.
+    With --all, all the legacy dashboards in the organization are migrated. The dashboards which failed to be migrated are
+    dumped to files named "dashboard-migrate-failed-<dashboardId>.json" in the directory specified by --dump-dir.
+`,
+			Action: doMigrateDashboards,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "id", Value: "", Usage: "Migrate the dashboard identified with <dashboardId>."},
+				cli.BoolFlag{Name: "all", Usage: "Migrate all the legacy dashboards."},
+				cli.BoolFlag{Name: "convert-graphs", Usage: "Convert the embedded graphs into graph widgets."},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show which dashboards are migrated, but not execute."},
+				cli.BoolFlag{Name: "force", Usage: "Migrate without confirmation."},
+				cli.StringFlag{Name: "dump-dir", Value: ".", Usage: "Directory to dump the dashboards which failed to be migrated."},
 			},
 		},
 	},
 }
 
+var dashboardTemplateFlags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "var",
+		Value: &cli.StringSlice{},
+		Usage: "Set a template variable in the form of key=value. Multiple choices are allowed.",
+	},
+	cli.StringFlag{Name: "vars-file", Value: "", Usage: "YAML file of template variables."},
+}
+
 type graphsConfig struct {
 	ConfigVersion   string             `yaml:"config_version"`
 	Title           string             `yaml:"title"`
@@ -46,6 +224,10 @@ type graphsConfig struct {
 	Width           int                `yaml:"width"`
 	HostGraphFormat []*hostGraphFormat `yaml:"host_graphs"`
 	GraphFormat     []*graphFormat     `yaml:"graphs"`
+
+	// config_version 1.0
+	Memo    string          `yaml:"memo"`
+	Widgets []*widgetConfig `yaml:"widgets"`
 }
 
 type hostGraphFormat struct {
@@ -371,22 +553,27 @@ func doGenerateDashboards(c *cli.Context) error {
 		return cli.NewExitError("specify a yaml file.", 1)
 	}
 
+	vars, err := dashboardTemplateVars(c)
+	logger.DieIf(err)
+
 	buf, err := ioutil.ReadFile(argFilePath[0])
 	logger.DieIf(err)
 
+	if vars != nil {
+		buf, err = expandDashboardTemplate(argFilePath[0], buf, vars)
+		logger.DieIf(err)
+	}
+
 	yml := graphsConfig{}
 	err = yaml.Unmarshal(buf, &yml)
 	logger.DieIf(err)
 
 	client := mackerelclient.NewFromContext(c)
 
-	org, err := client.GetOrg()
-	logger.DieIf(err)
-
 	if yml.ConfigVersion == "" {
 		return cli.NewExitError("config_version is required in yaml.", 1)
 	}
-	if yml.ConfigVersion != "0.9" {
+	if yml.ConfigVersion != "0.9" && yml.ConfigVersion != "1.0" {
 		return cli.NewExitError(fmt.Sprintf("config_version %s is not suport.", yml.ConfigVersion), 1)
 	}
 	if yml.Title == "" {
@@ -395,6 +582,24 @@ func doGenerateDashboards(c *cli.Context) error {
 	if yml.URLPath == "" {
 		return cli.NewExitError("url_path is required in yaml.", 1)
 	}
+
+	if yml.ConfigVersion == "1.0" {
+		dashboard, err := generateWidgetDashboard(&yml)
+		if err != nil {
+			return err
+		}
+		if problems := validateDashboard(dashboard); len(problems) > 0 {
+			for _, p := range problems {
+				fmt.Println(p)
+			}
+			return cli.NewExitError(fmt.Sprintf("%d problems are found in '%s'.", len(problems), argFilePath[0]), 1)
+		}
+		if isStdout {
+			format.PrettyPrintJSON(os.Stdout, dashboard)
+			return nil
+		}
+		return upsertDashboard(client, dashboard)
+	}
 	if yml.Format == "" {
 		yml.Format = "iframe"
 	}
@@ -412,6 +617,10 @@ func doGenerateDashboards(c *cli.Context) error {
 		return cli.NewExitError("you cannot specify both 'graphs' and host_graphs'.", 1)
 	}
 
+	// the name of the organization is needed only for the URLs of the graphs in the markdown
+	org, err := client.GetOrg()
+	logger.DieIf(err)
+
 	var markdown string
 	for _, h := range yml.HostGraphFormat {
 		mdf := generateHostGraphsMarkdownFactory(h, yml.Format, yml.Height, yml.Width)
@@ -433,24 +642,7 @@ func doGenerateDashboards(c *cli.Context) error {
 			BodyMarkDown: markdown,
 			URLPath:      yml.URLPath,
 		}
-
-		dashboards, fetchError := client.FindDashboards()
-		logger.DieIf(fetchError)
-
-		dashboardID := ""
-		for _, ds := range dashboards {
-			if ds.URLPath == yml.URLPath {
-				dashboardID = ds.ID
-			}
-		}
-
-		if dashboardID == "" {
-			_, createError := client.CreateDashboard(updateDashboard)
-			logger.DieIf(createError)
-		} else {
-			_, updateError := client.UpdateDashboard(dashboardID, updateDashboard)
-			logger.DieIf(updateError)
-		}
+		return upsertDashboard(client, updateDashboard)
 	}
 
 	return nil
@@ -531,3 +723,1098 @@ func generateGraphsMarkdownFactory(graphs *graphFormat, graphType string, height
 func generateAlignmentLine(count int) string {
 	return strings.Repeat("|:-:", count) + "|\n"
 }
+
+// dashboardFileFormat returns the format of a dashboard file, "json" or "yaml".
+// The format is detected by the extension of the file unless optFormat is specified.
+func dashboardFileFormat(filePath, optFormat string) (string, error) {
+	switch optFormat {
+	case "json", "yaml":
+		return optFormat, nil
+	case "":
+	default:
+		return "", fmt.Errorf("format should be 'json' or 'yaml': %s", optFormat)
+	}
+	switch strings.ToLower(filepath.Ext(filePath)) {
+	case ".yaml", ".yml":
+		return "yaml", nil
+	default:
+		return "json", nil
+	}
+}
+
+func dashboardSaveFile(dashboard *mackerel.Dashboard, filePath, optFormat string) error {
+	fileFormat, err := dashboardFileFormat(filePath, optFormat)
+	if err != nil {
+		return err
+	}
+	var data []byte
+	if fileFormat == "yaml" {
+		if data, err = format.YAMLMarshal(dashboard); err != nil {
+			return err
+		}
+	} else {
+		data = []byte(format.JSONMarshalIndent(dashboard, "", "    ") + "\n")
+	}
+	return ioutil.WriteFile(filePath, data, 0644)
+}
+
+// dashboardLoadFile loads a dashboard file. The file is expanded as a template with vars unless vars is nil.
+func dashboardLoadFile(filePath, optFormat string, vars map[string]interface{}) (*mackerel.Dashboard, error) {
+	fileFormat, err := dashboardFileFormat(filePath, optFormat)
+	if err != nil {
+		return nil, err
+	}
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	if vars != nil {
+		if data, err = expandDashboardTemplate(filePath, data, vars); err != nil {
+			return nil, err
+		}
+	}
+	if fileFormat == "yaml" {
+		if data, err = format.YAMLToJSON(data); err != nil {
+			return nil, err
+		}
+	}
+
+	var dashboard mackerel.Dashboard
+	if err := json.Unmarshal(data, &dashboard); err != nil {
+		return nil, err
+	}
+	return &dashboard, nil
+}
+
+// findRemoteDashboard returns the remote dashboard corresponding to the local one.
+// It is looked up by ID, or by URLPath when the local dashboard has no ID.
+func findRemoteDashboard(client *mackerel.Client, local *mackerel.Dashboard) (*mackerel.Dashboard, error) {
+	if local.ID != "" {
+		return client.FindDashboard(local.ID)
+	}
+	if local.URLPath == "" {
+		return nil, fmt.Errorf("either id or urlPath is required in the dashboard definition")
+	}
+
+	dashboards, err := client.FindDashboards()
+	if err != nil {
+		return nil, err
+	}
+	for _, ds := range dashboards {
+		if ds.URLPath == local.URLPath {
+			// the list API does not return widgets, so fetch the dashboard itself
+			return client.FindDashboard(ds.ID)
+		}
+	}
+	return nil, fmt.Errorf("dashboard is not found: urlPath=%s", local.URLPath)
+}
+
+// diffDashboard returns the unified diff of the JSON of the dashboards, or "" if they are the same.
+// It ignores the fields assigned by the server (id, createdAt and updatedAt).
+func diffDashboard(a *mackerel.Dashboard, b *mackerel.Dashboard, aName, bName string) string {
+	normalize := func(d *mackerel.Dashboard) string {
+		dd := *d
+		dd.ID = ""
+		dd.CreatedAt = 0
+		dd.UpdatedAt = 0
+		return format.JSONMarshalIndent(dd, "", "  ")
+	}
+	return strings.TrimRight(unifiedDiff(aName, bName, normalize(a), normalize(b)), "\n")
+}
+
+func doDiffDashboard(c *cli.Context) error {
+	filePath := c.String("file-path")
+	isReverse := c.Bool("reverse")
+
+	if filePath == "" {
+		cli.ShowCommandHelp(c, "diff")
+		return cli.NewExitError("specify a file path of the dashboard.", 1)
+	}
+
+	vars, err := dashboardTemplateVars(c)
+	logger.DieIf(err)
+
+	local, err := dashboardLoadFile(filePath, "", vars)
+	logger.DieIf(err)
+
+	remote, err := findRemoteDashboard(mackerelclient.NewFromContext(c), local)
+	logger.DieIf(err)
+
+	var diff string
+	if isReverse {
+		diff = diffDashboard(local, remote, filePath, "remote")
+	} else {
+		diff = diffDashboard(remote, local, "remote", filePath)
+	}
+	if diff == "" {
+		return nil
+	}
+	fmt.Println(diff)
+	logger.Exit(1)
+	return nil
+}
+
+// the width of the grid of dashboards
+const dashboardGridWidth = 24
+
+type dashboardProblem struct {
+	index   int
+	widget  mackerel.Widget
+	message string
+}
+
+func (p dashboardProblem) String() string {
+	if p.index < 0 {
+		return p.message
+	}
+	return fmt.Sprintf("widgets[%d] (%s %q): %s", p.index, p.widget.Type, p.widget.Title, p.message)
+}
+
+// validateDashboard checks the dashboard and returns the problems found.
+func validateDashboard(d *mackerel.Dashboard) []dashboardProblem {
+	var problems []dashboardProblem
+	add := func(i int, w mackerel.Widget, format string, args ...interface{}) {
+		problems = append(problems, dashboardProblem{i, w, fmt.Sprintf(format, args...)})
+	}
+
+	if d.Title == "" {
+		add(-1, mackerel.Widget{}, "title is required")
+	}
+	if d.URLPath == "" {
+		add(-1, mackerel.Widget{}, "urlPath is required")
+	}
+	if d.IsLegacy || d.BodyMarkDown != "" {
+		add(-1, mackerel.Widget{}, "legacy dashboards are not supported")
+	}
+
+	for i, w := range d.Widgets {
+		switch w.Type {
+		case "graph":
+			if msg := validateDashboardGraph(w.Graph); msg != "" {
+				add(i, w, msg)
+			}
+		case "value":
+			if msg := validateDashboardMetric(w.Metric); msg != "" {
+				add(i, w, msg)
+			}
+		case "markdown":
+		case "":
+			add(i, w, "type is required")
+		default:
+			add(i, w, "unknown widget type")
+		}
+
+		l := w.Layout
+		if l.X < 0 || l.Y < 0 {
+			add(i, w, "layout position should not be negative")
+		}
+		if l.Width <= 0 || l.Height <= 0 {
+			add(i, w, "layout width and height should be positive")
+		}
+		if l.X+l.Width > dashboardGridWidth {
+			add(i, w, "layout exceeds the width of the dashboard (%d)", dashboardGridWidth)
+		}
+		for j := 0; j < i; j++ {
+			if isOverlappedLayout(d.Widgets[j].Layout, l) {
+				add(i, w, "layout overlaps with widgets[%d]", j)
+			}
+		}
+	}
+	return problems
+}
+
+func validateDashboardGraph(g mackerel.Graph) string {
+	switch g.Type {
+	case "host":
+		if g.HostID == "" || g.Name == "" {
+			return "hostId and name are required for host graph"
+		}
+	case "role":
+		if g.RoleFullName == "" || g.Name == "" {
+			return "roleFullname and name are required for role graph"
+		}
+	case "service":
+		if g.ServiceName == "" || g.Name == "" {
+			return "serviceName and name are required for service graph"
+		}
+	case "expression":
+		if g.Expression == "" {
+			return "expression is required for expression graph"
+		}
+	case "":
+		return "graph is required"
+	default:
+		return fmt.Sprintf("unknown graph type: %s", g.Type)
+	}
+	return ""
+}
+
+func validateDashboardMetric(m mackerel.Metric) string {
+	switch m.Type {
+	case "host":
+		if m.HostID == "" || m.Name == "" {
+			return "hostId and name are required for host metric"
+		}
+	case "service":
+		if m.ServiceName == "" || m.Name == "" {
+			return "serviceName and name are required for service metric"
+		}
+	case "expression":
+		if m.Expression == "" {
+			return "expression is required for expression metric"
+		}
+	case "":
+		return "metric is required"
+	default:
+		return fmt.Sprintf("unknown metric type: %s", m.Type)
+	}
+	return ""
+}
+
+func isOverlappedLayout(a, b mackerel.Layout) bool {
+	return a.X < b.X+b.Width && b.X < a.X+a.Width &&
+		a.Y < b.Y+b.Height && b.Y < a.Y+a.Height
+}
+
+// expandFilePaths expands the glob patterns in the file paths.
+func expandFilePaths(patterns []string) ([]string, error) {
+	var filePaths []string
+	seen := map[string]bool{}
+	for _, pattern := range patterns {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("no files match '%s'", pattern)
+		}
+		for _, m := range matches {
+			if !seen[m] {
+				seen[m] = true
+				filePaths = append(filePaths, m)
+			}
+		}
+	}
+	return filePaths, nil
+}
+
+type dashboardWriter interface {
+	CreateDashboard(*mackerel.Dashboard) (*mackerel.Dashboard, error)
+	UpdateDashboard(string, *mackerel.Dashboard) (*mackerel.Dashboard, error)
+	DeleteDashboard(string) (*mackerel.Dashboard, error)
+}
+
+type dashboardPushStep struct {
+	file *dashboardFile
+	// the ID of the remote dashboard to be updated. it is empty when the dashboard is created
+	remoteID string
+	// the remote dashboard before updated, which is used for rollback
+	snapshot *mackerel.Dashboard
+	// the ID of the created dashboard
+	createdID string
+	applied   bool
+}
+
+func (s *dashboardPushStep) String() string {
+	if s.remoteID == "" {
+		return fmt.Sprintf("%s (create %s)", s.file.path, s.file.dashboard.URLPath)
+	}
+	return fmt.Sprintf("%s (update %s)", s.file.path, s.remoteID)
+}
+
+// applyDashboardPushSteps applies the steps in order and stops at the first failure.
+func applyDashboardPushSteps(client dashboardWriter, steps []*dashboardPushStep) error {
+	for _, s := range steps {
+		if s.remoteID == "" {
+			created, err := client.CreateDashboard(s.file.dashboard)
+			if err != nil {
+				return fmt.Errorf("%s: %s", s, err)
+			}
+			s.createdID = created.ID
+		} else {
+			if _, err := client.UpdateDashboard(s.remoteID, s.file.dashboard); err != nil {
+				return fmt.Errorf("%s: %s", s, err)
+			}
+		}
+		s.applied = true
+	}
+	return nil
+}
+
+// rollbackDashboardPushSteps reverts the applied steps in reverse order.
+// The created dashboards are deleted and the updated dashboards are restored from the snapshots.
+func rollbackDashboardPushSteps(client dashboardWriter, steps []*dashboardPushStep) []error {
+	var errs []error
+	for i := len(steps) - 1; i >= 0; i-- {
+		s := steps[i]
+		if !s.applied {
+			continue
+		}
+		var err error
+		if s.remoteID == "" {
+			_, err = client.DeleteDashboard(s.createdID)
+		} else {
+			snapshot := *s.snapshot
+			snapshot.ID, snapshot.CreatedAt, snapshot.UpdatedAt = "", 0, 0
+			_, err = client.UpdateDashboard(s.remoteID, &snapshot)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to rollback %s: %s", s, err))
+			continue
+		}
+		s.applied = false
+	}
+	return errs
+}
+
+// planDashboardPushSteps resolves the remote dashboards of the files by "id", or by "urlPath" when "id" is not specified.
+// It returns an error if multiple files resolve to the same dashboard, before any of them is pushed.
+func planDashboardPushSteps(files []*dashboardFile, remotes []*mackerel.Dashboard) ([]*dashboardPushStep, error) {
+	var steps []*dashboardPushStep
+	targets := map[string]string{}
+	for _, f := range files {
+		s := &dashboardPushStep{file: f, remoteID: f.dashboard.ID}
+		if s.remoteID == "" {
+			for _, r := range remotes {
+				if r.URLPath == f.dashboard.URLPath {
+					s.remoteID = r.ID
+					break
+				}
+			}
+		}
+		target := "id=" + s.remoteID
+		if s.remoteID == "" {
+			target = "urlPath=" + f.dashboard.URLPath
+		}
+		if path, ok := targets[target]; ok {
+			return nil, fmt.Errorf("the dashboard %s is pushed by multiple files: %s and %s", target, path, f.path)
+		}
+		targets[target] = f.path
+		steps = append(steps, s)
+	}
+	return steps, nil
+}
+
+func doPushDashboard(c *cli.Context) error {
+	isDryRun := c.Bool("dry-run")
+	isRollback := c.Bool("rollback")
+
+	if len(c.StringSlice("file-path")) == 0 {
+		cli.ShowCommandHelp(c, "push")
+		return cli.NewExitError("specify a file path of the dashboard.", 1)
+	}
+	filePaths, err := expandFilePaths(c.StringSlice("file-path"))
+	logger.DieIf(err)
+
+	vars, err := dashboardTemplateVars(c)
+	logger.DieIf(err)
+
+	// validate all the files before applying any of them
+	var files []*dashboardFile
+	invalid := false
+	for _, filePath := range filePaths {
+		dashboard, err := dashboardLoadFile(filePath, c.String("format"), vars)
+		logger.DieIf(err)
+		for _, p := range validateDashboard(dashboard) {
+			fmt.Printf("%s: %s\n", filePath, p)
+			invalid = true
+		}
+		files = append(files, &dashboardFile{filePath, dashboard})
+	}
+	if invalid {
+		return cli.NewExitError("problems are found in the dashboard files.", 1)
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	remotes, err := client.FindDashboards()
+	logger.DieIf(err)
+
+	steps, err := planDashboardPushSteps(files, remotes)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	for _, s := range steps {
+		if s.remoteID != "" {
+			// take the snapshot to restore the dashboard on rollback
+			s.snapshot, err = client.FindDashboard(s.remoteID)
+			logger.DieIf(err)
+		}
+		logger.Log("info", fmt.Sprintf("Push %s", s))
+	}
+	if isDryRun {
+		return nil
+	}
+
+	if err := applyDashboardPushSteps(client, steps); err != nil {
+		logger.Log("error", err.Error())
+		if isRollback {
+			for _, err := range rollbackDashboardPushSteps(client, steps) {
+				logger.Log("error", err.Error())
+			}
+		}
+		for _, s := range steps {
+			status := "not applied"
+			if s.applied {
+				status = "applied"
+			}
+			fmt.Printf("%s: %s\n", status, s)
+		}
+		return cli.NewExitError("failed to push the dashboards.", 1)
+	}
+	return nil
+}
+
+var slugInvalidPattern = regexp.MustCompile(`[^a-z0-9]+`)
+
+func slugify(s string) string {
+	return strings.Trim(slugInvalidPattern.ReplaceAllString(strings.ToLower(s), "-"), "-")
+}
+
+func dashboardFileName(dashboard *mackerel.Dashboard, filenameFormat, fileFormat string) (string, error) {
+	var name string
+	switch filenameFormat {
+	case "", "id":
+		name = dashboard.ID
+	case "url-path":
+		name = slugify(dashboard.URLPath)
+	case "title":
+		name = slugify(dashboard.Title)
+	default:
+		return "", fmt.Errorf("filename-format should be 'id', 'url-path' or 'title': %s", filenameFormat)
+	}
+	if name == "" {
+		name = dashboard.ID
+	}
+	ext := ".json"
+	if fileFormat == "yaml" {
+		ext = ".yaml"
+	}
+	return "dashboard-" + name + ext, nil
+}
+
+func doPullDashboard(c *cli.Context) error {
+	optID := c.String("id")
+	optURLPath := c.String("url-path")
+	dir := c.String("dir")
+	filenameFormat := c.String("filename-format")
+	fileFormat := c.String("format")
+	if fileFormat == "" {
+		fileFormat = "json"
+	}
+	if fileFormat != "json" && fileFormat != "yaml" {
+		return cli.NewExitError("format should be 'json' or 'yaml'.", 1)
+	}
+
+	client := mackerelclient.NewFromContext(c)
+
+	var ids []string
+	if optID != "" {
+		ids = append(ids, optID)
+	} else {
+		dashboards, err := client.FindDashboards()
+		logger.DieIf(err)
+		for _, ds := range dashboards {
+			if optURLPath == "" || ds.URLPath == optURLPath {
+				ids = append(ids, ds.ID)
+			}
+		}
+		if optURLPath != "" && len(ids) == 0 {
+			return cli.NewExitError(fmt.Sprintf("dashboard is not found: urlPath=%s", optURLPath), 1)
+		}
+	}
+
+	logger.DieIf(os.MkdirAll(dir, 0755))
+	var failed int
+	for _, id := range ids {
+		if err := pullDashboard(client, id, dir, filenameFormat, fileFormat); err != nil {
+			logger.Log("error", fmt.Sprintf("failed to pull the dashboard %s: %s", id, err))
+			failed++
+		}
+	}
+	return batchError("pull", failed, len(ids), "dashboard(s)")
+}
+
+func pullDashboard(client *mackerel.Client, id, dir, filenameFormat, fileFormat string) error {
+	dashboard, err := client.FindDashboard(id)
+	if err != nil {
+		return err
+	}
+	name, err := dashboardFileName(dashboard, filenameFormat, fileFormat)
+	if err != nil {
+		return err
+	}
+	filePath := filepath.Join(dir, name)
+	if err := dashboardSaveFile(dashboard, filePath, fileFormat); err != nil {
+		return err
+	}
+	logger.Log("info", fmt.Sprintf("Dashboard file is saved to '%s' (title: %s)", filePath, dashboard.Title))
+	return nil
+}
+
+type dashboardFile struct {
+	path      string
+	dashboard *mackerel.Dashboard
+}
+
+// dashboardLoadDir loads the JSON and YAML dashboard files in a directory.
+func dashboardLoadDir(dir string, vars map[string]interface{}) ([]*dashboardFile, error) {
+	entries, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+	var files []*dashboardFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		switch strings.ToLower(filepath.Ext(e.Name())) {
+		case ".json", ".yaml", ".yml":
+		default:
+			continue
+		}
+		path := filepath.Join(dir, e.Name())
+		d, err := dashboardLoadFile(path, "", vars)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load '%s': %s", path, err)
+		}
+		files = append(files, &dashboardFile{path, d})
+	}
+	return files, nil
+}
+
+type dashboardPlan struct {
+	create []*dashboardFile
+	update []*dashboardFile
+	delete []*mackerel.Dashboard
+}
+
+// planDashboards compares the local dashboard files with the remote dashboards.
+// fetch is called to get the widgets of the remote dashboards to be compared.
+func planDashboards(files []*dashboardFile, remotes []*mackerel.Dashboard, fetch func(string) (*mackerel.Dashboard, error)) (*dashboardPlan, error) {
+	byID := map[string]*mackerel.Dashboard{}
+	byURLPath := map[string]*mackerel.Dashboard{}
+	for _, r := range remotes {
+		byID[r.ID] = r
+		byURLPath[r.URLPath] = r
+	}
+
+	plan := &dashboardPlan{}
+	used := map[string]bool{}
+	for _, f := range files {
+		var r *mackerel.Dashboard
+		var ok bool
+		// the dashboards are matched by "urlPath" only if the file has no "id", so that a stale id is not missed
+		if f.dashboard.ID != "" {
+			if r, ok = byID[f.dashboard.ID]; !ok {
+				return nil, fmt.Errorf("dashboard is not found: id=%s (%s)", f.dashboard.ID, f.path)
+			}
+		} else if r, ok = byURLPath[f.dashboard.URLPath]; !ok {
+			plan.create = append(plan.create, f)
+			continue
+		}
+		if used[r.ID] {
+			return nil, fmt.Errorf("dashboard %s is defined in multiple files (%s)", r.ID, f.path)
+		}
+		used[r.ID] = true
+		remote, err := fetch(r.ID)
+		if err != nil {
+			return nil, err
+		}
+		if diffDashboard(remote, f.dashboard, "", "") != "" {
+			f.dashboard.ID = r.ID
+			plan.update = append(plan.update, f)
+		}
+	}
+	for _, r := range remotes {
+		if !used[r.ID] {
+			plan.delete = append(plan.delete, r)
+		}
+	}
+	return plan, nil
+}
+
+func doApplyDashboards(c *cli.Context) error {
+	dir := c.String("dir")
+	isPrune := c.Bool("prune")
+	isDryRun := c.Bool("dry-run")
+
+	if dir == "" {
+		cli.ShowCommandHelp(c, "apply")
+		return cli.NewExitError("specify a directory of dashboards.", 1)
+	}
+
+	vars, err := dashboardTemplateVars(c)
+	logger.DieIf(err)
+
+	files, err := dashboardLoadDir(dir, vars)
+	logger.DieIf(err)
+
+	if !validateDashboardFiles(files) {
+		return cli.NewExitError(fmt.Sprintf("problems are found in '%s'.", dir), 1)
+	}
+
+	return applyDashboards(mackerelclient.NewFromContext(c), files, isDryRun, isPrune)
+}
+
+// validateDashboardFiles prints the problems of the dashboard files and reports whether they are valid.
+func validateDashboardFiles(files []*dashboardFile) bool {
+	valid := true
+	for _, f := range files {
+		for _, p := range validateDashboard(f.dashboard) {
+			fmt.Printf("%s: %s\n", f.path, p)
+			valid = false
+		}
+	}
+	return valid
+}
+
+// dashboardApplier is the client to apply the dashboard files.
+type dashboardApplier interface {
+	dashboardWriter
+	FindDashboards() ([]*mackerel.Dashboard, error)
+	FindDashboard(dashboardID string) (*mackerel.Dashboard, error)
+}
+
+// applyDashboards creates and updates the dashboards in the files, and deletes the other dashboards with isPrune.
+func applyDashboards(client dashboardApplier, files []*dashboardFile, isDryRun, isPrune bool) error {
+	remotes, err := client.FindDashboards()
+	if err != nil {
+		return err
+	}
+
+	plan, err := planDashboards(files, remotes, client.FindDashboard)
+	if err != nil {
+		return err
+	}
+
+	for _, f := range plan.create {
+		logger.Log("info", fmt.Sprintf("Create a new dashboard: %s (%s)", f.dashboard.URLPath, f.path))
+		if !isDryRun {
+			if _, err := client.CreateDashboard(f.dashboard); err != nil {
+				return err
+			}
+		}
+	}
+	for _, f := range plan.update {
+		logger.Log("info", fmt.Sprintf("Update the dashboard: %s (%s)", f.dashboard.ID, f.path))
+		if !isDryRun {
+			if _, err := client.UpdateDashboard(f.dashboard.ID, f.dashboard); err != nil {
+				return err
+			}
+		}
+	}
+	deleted := 0
+	if isPrune {
+		deleted = len(plan.delete)
+		for _, d := range plan.delete {
+			logger.Log("info", fmt.Sprintf("Delete the dashboard: %s (%s)", d.ID, d.Title))
+			if !isDryRun {
+				if _, err := client.DeleteDashboard(d.ID); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	logger.Log("info", fmt.Sprintf("%d created, %d updated, %d deleted", len(plan.create), len(plan.update), deleted))
+	return nil
+}
+
+// dashboardTemplateVars returns the template variables given by --vars-file and --var.
+// The variables given by --var take precedence. It returns nil when no variables are given,
+// so that the files are not expanded as templates (markdown widgets may contain "{{" literally).
+func dashboardTemplateVars(c *cli.Context) (map[string]interface{}, error) {
+	if c.String("vars-file") == "" && len(c.StringSlice("var")) == 0 {
+		return nil, nil
+	}
+	vars := map[string]interface{}{}
+	if varsFile := c.String("vars-file"); varsFile != "" {
+		buf, err := ioutil.ReadFile(varsFile)
+		if err != nil {
+			return nil, err
+		}
+		if err := yaml.Unmarshal(buf, &vars); err != nil {
+			return nil, fmt.Errorf("failed to load '%s': %s", varsFile, err)
+		}
+	}
+	for _, kv := range c.StringSlice("var") {
+		xs := strings.SplitN(kv, "=", 2)
+		if len(xs) != 2 || xs[0] == "" {
+			return nil, fmt.Errorf("invalid variable (should be key=value): %s", kv)
+		}
+		vars[xs[0]] = xs[1]
+	}
+	return vars, nil
+}
+
+var dashboardTemplateFuncs = template.FuncMap{
+	"env": os.Getenv,
+}
+
+// expandDashboardTemplate expands the content of a dashboard file as a Go template.
+func expandDashboardTemplate(name string, data []byte, vars map[string]interface{}) ([]byte, error) {
+	t, err := template.New(filepath.Base(name)).Funcs(dashboardTemplateFuncs).Option("missingkey=error").Parse(string(data))
+	if err != nil {
+		return nil, err
+	}
+	var buf strings.Builder
+	if err := t.Execute(&buf, vars); err != nil {
+		return nil, err
+	}
+	return []byte(buf.String()), nil
+}
+
+// upsertDashboard updates the dashboard when its ID is specified or a dashboard
+// with the same URLPath exists, otherwise creates it.
+func upsertDashboard(client *mackerel.Client, dashboard *mackerel.Dashboard) error {
+	dashboardID := dashboard.ID
+	if dashboardID == "" {
+		dashboards, err := client.FindDashboards()
+		if err != nil {
+			return err
+		}
+		for _, ds := range dashboards {
+			if ds.URLPath == dashboard.URLPath {
+				dashboardID = ds.ID
+			}
+		}
+	}
+
+	if dashboardID == "" {
+		logger.Log("info", fmt.Sprintf("Create a new dashboard: %s", dashboard.URLPath))
+		_, err := client.CreateDashboard(dashboard)
+		return err
+	}
+	logger.Log("info", fmt.Sprintf("Update the dashboard: %s", dashboardID))
+	_, err := client.UpdateDashboard(dashboardID, dashboard)
+	return err
+}
+
+// the height of the markdown widget converted from a legacy dashboard
+const migratedMarkdownHeight = 32
+
+// migrateLegacyDashboard converts a legacy dashboard into a current dashboard.
+func migrateLegacyDashboard(legacy *mackerel.Dashboard, convertGraphs bool) (*mackerel.Dashboard, error) {
+	if !legacy.IsLegacy {
+		return nil, errors.New("the dashboard is not legacy")
+	}
+	if convertGraphs {
+		current, err := generateWidgetDashboard(&graphsConfig{
+			Title:   legacy.Title,
+			URLPath: legacy.URLPath,
+			Widgets: convertLegacyMarkdown(legacy.BodyMarkDown),
+		})
+		if err != nil {
+			return nil, err
+		}
+		current.ID = legacy.ID
+		return current, nil
+	}
+	return &mackerel.Dashboard{
+		ID:      legacy.ID,
+		Title:   legacy.Title,
+		URLPath: legacy.URLPath,
+		Widgets: []mackerel.Widget{
+			{
+				Type:     "markdown",
+				Title:    legacy.Title,
+				Markdown: legacy.BodyMarkDown,
+				Layout:   mackerel.Layout{X: 0, Y: 0, Width: dashboardGridWidth, Height: migratedMarkdownHeight},
+			},
+		},
+	}, nil
+}
+
+type dashboardMigration struct {
+	legacy *mackerel.Dashboard
+	err    error
+}
+
+func dumpFailedMigration(dir string, m *dashboardMigration) (string, error) {
+	filePath := filepath.Join(dir, fmt.Sprintf("dashboard-migrate-failed-%s.json", m.legacy.ID))
+	dump := map[string]interface{}{
+		"error":     m.err.Error(),
+		"dashboard": m.legacy,
+	}
+	data := format.JSONMarshalIndent(dump, "", "    ") + "\n"
+	return filePath, ioutil.WriteFile(filePath, []byte(data), 0644)
+}
+
+func doMigrateDashboards(c *cli.Context) error {
+	optID := c.String("id")
+	isAll := c.Bool("all")
+	convertGraphs := c.Bool("convert-graphs")
+	isDryRun := c.Bool("dry-run")
+	force := c.Bool("force")
+	dumpDir := c.String("dump-dir")
+
+	if (optID == "") == !isAll {
+		cli.ShowCommandHelp(c, "migrate")
+		return cli.NewExitError("specify either --id or --all.", 1)
+	}
+
+	client := mackerelclient.NewFromContext(c)
+
+	var ids []string
+	if isAll {
+		dashboards, err := client.FindDashboards()
+		logger.DieIf(err)
+		for _, ds := range dashboards {
+			if ds.IsLegacy {
+				ids = append(ids, ds.ID)
+			}
+		}
+		if len(ids) == 0 {
+			logger.Log("info", "No legacy dashboards are found.")
+			return nil
+		}
+		if !isDryRun && !force && !prompter.YN("Migrate following dashboards.\n  "+strings.Join(ids, "\n  ")+"\nAre you sure?", true) {
+			logger.Log("", "migration is canceled.")
+			return nil
+		}
+	} else {
+		ids = append(ids, optID)
+	}
+
+	var migrations []*dashboardMigration
+	for _, id := range ids {
+		m := &dashboardMigration{legacy: &mackerel.Dashboard{ID: id}}
+		migrations = append(migrations, m)
+
+		legacy, err := client.FindDashboard(id)
+		if err != nil {
+			m.err = err
+			continue
+		}
+		m.legacy = legacy
+		current, err := migrateLegacyDashboard(legacy, convertGraphs)
+		if err != nil {
+			m.err = err
+			continue
+		}
+		logger.Log("info", fmt.Sprintf("Migrate the dashboard: %s (%s)", id, legacy.Title))
+		if !isDryRun {
+			_, m.err = client.UpdateDashboard(id, current)
+		}
+	}
+
+	failed := 0
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTITLE\tRESULT")
+	for _, m := range migrations {
+		result := "succeeded"
+		if isDryRun {
+			result = "skipped (dry-run)"
+		}
+		if m.err != nil {
+			failed++
+			result = "failed: " + m.err.Error()
+			if filePath, err := dumpFailedMigration(dumpDir, m); err != nil {
+				logger.Log("warning", fmt.Sprintf("Failed to dump the dashboard %s: %s", m.legacy.ID, err))
+			} else {
+				result += fmt.Sprintf(" (dumped to '%s')", filePath)
+			}
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", m.legacy.ID, m.legacy.Title, result)
+	}
+	w.Flush()
+
+	if failed > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d of %d dashboards failed to be migrated.", failed, len(migrations)), 1)
+	}
+	return nil
+}
+
+func doExportDashboard(c *cli.Context) error {
+	optID := c.String("id")
+	exportFormat := c.String("format")
+	filePath := c.String("file-path")
+
+	if optID == "" {
+		cli.ShowCommandHelp(c, "export")
+		return cli.NewExitError("specify a dashboard id.", 1)
+	}
+	if exportFormat != "json" && exportFormat != "grafana" {
+		return cli.NewExitError("format should be 'json' or 'grafana'.", 1)
+	}
+
+	dashboard, err := mackerelclient.NewFromContext(c).FindDashboard(optID)
+	logger.DieIf(err)
+
+	var out interface{} = dashboard
+	if exportFormat == "grafana" {
+		if out, err = convertToGrafana(dashboard); err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+	}
+
+	if filePath == "" {
+		return format.PrettyPrintJSON(os.Stdout, out)
+	}
+	data := format.JSONMarshalIndent(out, "", "    ") + "\n"
+	logger.DieIf(ioutil.WriteFile(filePath, []byte(data), 0644))
+	logger.Log("info", fmt.Sprintf("Dashboard is exported to '%s'.", filePath))
+	return nil
+}
+
+func filterDashboards(dashboards []*mackerel.Dashboard, title, urlPath string) []*mackerel.Dashboard {
+	filtered := make([]*mackerel.Dashboard, 0, len(dashboards))
+	for _, d := range dashboards {
+		if title != "" && !strings.Contains(strings.ToLower(d.Title), strings.ToLower(title)) {
+			continue
+		}
+		if urlPath != "" && !strings.Contains(d.URLPath, urlPath) {
+			continue
+		}
+		filtered = append(filtered, d)
+	}
+	return filtered
+}
+
+func printDashboards(w io.Writer, dashboards []*mackerel.Dashboard, output string) error {
+	if output == "" {
+		output = format.OutputJSON
+	}
+	o, err := format.ParseOutput(output)
+	if err != nil {
+		return err
+	}
+	return o.Print(w, dashboards, func() *format.Table {
+		t := format.NewTable("ID", "TITLE", "URL_PATH", "LEGACY", "UPDATED_AT")
+		for _, d := range dashboards {
+			t.Append(d.ID, d.Title, d.URLPath, strconv.FormatBool(d.IsLegacy), format.ISO8601Extended(dashboardUpdatedAt(d)))
+		}
+		return t
+	})
+}
+
+// dashboardUpdatedAt returns updatedAt of the dashboard,
+// which is in milliseconds for legacy dashboards and in seconds for current dashboards.
+func dashboardUpdatedAt(d *mackerel.Dashboard) time.Time {
+	if d.IsLegacy {
+		return time.Unix(0, d.UpdatedAt*int64(time.Millisecond))
+	}
+	return time.Unix(d.UpdatedAt, 0)
+}
+
+func doListDashboards(c *cli.Context) error {
+	dashboards, err := mackerelclient.NewFromContext(c).FindDashboards()
+	logger.DieIf(err)
+
+	dashboards = filterDashboards(dashboards, c.String("filter-title"), c.String("filter-url-path"))
+	if err := printDashboards(os.Stdout, dashboards, format.OutputName(c, "json", format.OutputFormats...)); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
+
+// cloneDashboard returns a copy of the dashboard with the new title and urlPath.
+// The targets of the widgets are rewritten by re and repl unless re is nil.
+func cloneDashboard(d *mackerel.Dashboard, title, urlPath string, re *regexp.Regexp, repl string) *mackerel.Dashboard {
+	replace := func(s string) string {
+		if re == nil || s == "" {
+			return s
+		}
+		return re.ReplaceAllString(s, repl)
+	}
+	cloned := &mackerel.Dashboard{
+		Title:   title,
+		URLPath: urlPath,
+		Memo:    d.Memo,
+		Widgets: make([]mackerel.Widget, len(d.Widgets)),
+	}
+	for i, w := range d.Widgets {
+		w.Graph.HostID = replace(w.Graph.HostID)
+		w.Graph.ServiceName = replace(w.Graph.ServiceName)
+		w.Graph.RoleFullName = replace(w.Graph.RoleFullName)
+		w.Graph.Name = replace(w.Graph.Name)
+		w.Graph.Expression = replace(w.Graph.Expression)
+		w.Metric.HostID = replace(w.Metric.HostID)
+		w.Metric.ServiceName = replace(w.Metric.ServiceName)
+		w.Metric.Name = replace(w.Metric.Name)
+		w.Metric.Expression = replace(w.Metric.Expression)
+		cloned.Widgets[i] = w
+	}
+	return cloned
+}
+
+func doCloneDashboard(c *cli.Context) error {
+	optID := c.String("id")
+	title := c.String("title")
+	urlPath := c.String("url-path")
+	search := c.String("search")
+	isDryRun := c.Bool("dry-run")
+
+	if optID == "" || title == "" || urlPath == "" {
+		cli.ShowCommandHelp(c, "clone")
+		return cli.NewExitError("--id, --title and --url-path are required.", 1)
+	}
+	var re *regexp.Regexp
+	if search != "" {
+		var err error
+		if re, err = regexp.Compile(search); err != nil {
+			return cli.NewExitError(fmt.Sprintf("invalid --search: %s", err), 1)
+		}
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	dashboard, err := client.FindDashboard(optID)
+	logger.DieIf(err)
+	if dashboard.IsLegacy {
+		return cli.NewExitError("legacy dashboards cannot be cloned. migrate the dashboard first.", 1)
+	}
+
+	cloned := cloneDashboard(dashboard, title, urlPath, re, c.String("replace"))
+	if isDryRun {
+		return format.PrettyPrintJSON(os.Stdout, cloned)
+	}
+	created, err := client.CreateDashboard(cloned)
+	logger.DieIf(err)
+	logger.Log("created", fmt.Sprintf("%s (%s)", created.ID, created.URLPath))
+	return nil
+}
+
+func doGenerateDashboardFromRole(c *cli.Context) error {
+	service := c.String("service")
+	role := c.String("role")
+	title := c.String("title")
+	urlPath := c.String("url-path")
+	isStdout := c.Bool("print")
+
+	if service == "" || role == "" {
+		cli.ShowCommandHelp(c, "generate-from-role")
+		return cli.NewExitError("--service and --role are required.", 1)
+	}
+	if title == "" {
+		title = service + ":" + role
+	}
+	if urlPath == "" {
+		urlPath = slugify(service + "-" + role)
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	hosts, err := client.FindHosts(&mackerel.FindHostsParam{Service: service, Roles: []string{role}})
+	logger.DieIf(err)
+	if len(hosts) == 0 {
+		return cli.NewExitError(fmt.Sprintf("no hosts are found in %s:%s.", service, role), 1)
+	}
+
+	var metricNames []string
+	for _, host := range hosts {
+		names, err := client.ListHostMetricNames(host.ID)
+		logger.DieIf(err)
+		metricNames = append(metricNames, names...)
+	}
+
+	dashboard, err := generateWidgetDashboard(&graphsConfig{
+		Title:   title,
+		URLPath: urlPath,
+		Widgets: roleDashboardWidgets(service, role, metricNames),
+	})
+	if err != nil {
+		return err
+	}
+	if isStdout {
+		return format.PrettyPrintJSON(os.Stdout, dashboard)
+	}
+	return upsertDashboard(client, dashboard)
+}
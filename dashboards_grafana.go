@@ -0,0 +1,143 @@
+package main
+
+import (
+	"fmt"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// the name of the datasource variable in exported Grafana dashboards
+const grafanaDatasourceVariable = "DS_MACKEREL"
+
+type grafanaDashboard struct {
+	Title         string            `json:"title"`
+	Tags          []string          `json:"tags"`
+	Timezone      string            `json:"timezone"`
+	SchemaVersion int               `json:"schemaVersion"`
+	Time          grafanaTime       `json:"time"`
+	Templating    grafanaTemplating `json:"templating"`
+	Panels        []*grafanaPanel   `json:"panels"`
+	Description   string            `json:"description,omitempty"`
+}
+
+type grafanaTime struct {
+	From string `json:"from"`
+	To   string `json:"to"`
+}
+
+type grafanaTemplating struct {
+	List []grafanaVariable `json:"list"`
+}
+
+type grafanaVariable struct {
+	Name  string `json:"name"`
+	Label string `json:"label"`
+	Type  string `json:"type"`
+	Query string `json:"query"`
+}
+
+type grafanaPanel struct {
+	ID         int                    `json:"id"`
+	Type       string                 `json:"type"`
+	Title      string                 `json:"title"`
+	GridPos    grafanaGridPos         `json:"gridPos"`
+	Datasource string                 `json:"datasource,omitempty"`
+	Targets    []*grafanaTarget       `json:"targets,omitempty"`
+	Options    map[string]interface{} `json:"options,omitempty"`
+	TimeFrom   string                 `json:"timeFrom,omitempty"`
+}
+
+type grafanaGridPos struct {
+	X int64 `json:"x"`
+	Y int64 `json:"y"`
+	W int64 `json:"w"`
+	H int64 `json:"h"`
+}
+
+// grafanaTarget is a query of the Mackerel datasource
+type grafanaTarget struct {
+	RefID        string `json:"refId"`
+	Type         string `json:"type"`
+	HostID       string `json:"hostId,omitempty"`
+	ServiceName  string `json:"serviceName,omitempty"`
+	RoleFullname string `json:"roleFullname,omitempty"`
+	MetricName   string `json:"metricName,omitempty"`
+	Expression   string `json:"expression,omitempty"`
+	Stacked      bool   `json:"stacked,omitempty"`
+}
+
+// convertToGrafana translates a dashboard into a Grafana dashboard JSON model.
+// Graph widgets are translated into timeseries panels, value widgets into stat panels
+// and markdown widgets into text panels.
+func convertToGrafana(d *mackerel.Dashboard) (*grafanaDashboard, error) {
+	if d.IsLegacy {
+		return nil, fmt.Errorf("legacy dashboards cannot be exported. migrate the dashboard first")
+	}
+	datasource := "${" + grafanaDatasourceVariable + "}"
+	g := &grafanaDashboard{
+		Title:         d.Title,
+		Description:   d.Memo,
+		Tags:          []string{"mackerel"},
+		Timezone:      "browser",
+		SchemaVersion: 27,
+		Time:          grafanaTime{From: "now-6h", To: "now"},
+		Templating: grafanaTemplating{
+			List: []grafanaVariable{
+				{Name: grafanaDatasourceVariable, Label: "Mackerel", Type: "datasource", Query: "mackerel-datasource"},
+			},
+		},
+		Panels: []*grafanaPanel{},
+	}
+
+	for i, w := range d.Widgets {
+		p := &grafanaPanel{
+			ID:    i + 1,
+			Title: w.Title,
+			GridPos: grafanaGridPos{
+				X: w.Layout.X,
+				Y: w.Layout.Y,
+				W: w.Layout.Width,
+				H: w.Layout.Height,
+			},
+		}
+		switch w.Type {
+		case "graph":
+			p.Type = "timeseries"
+			p.Datasource = datasource
+			p.Targets = []*grafanaTarget{{
+				RefID:        "A",
+				Type:         w.Graph.Type,
+				HostID:       w.Graph.HostID,
+				ServiceName:  w.Graph.ServiceName,
+				RoleFullname: w.Graph.RoleFullName,
+				MetricName:   w.Graph.Name,
+				Expression:   w.Graph.Expression,
+				Stacked:      w.Graph.IsStacked,
+			}}
+			if w.Range.Type == "relative" && w.Range.Period > 0 {
+				p.TimeFrom = fmt.Sprintf("%ds", w.Range.Period)
+			}
+		case "value":
+			p.Type = "stat"
+			p.Datasource = datasource
+			p.Targets = []*grafanaTarget{{
+				RefID:       "A",
+				Type:        w.Metric.Type,
+				HostID:      w.Metric.HostID,
+				ServiceName: w.Metric.ServiceName,
+				MetricName:  w.Metric.Name,
+				Expression:  w.Metric.Expression,
+			}}
+		case "markdown":
+			p.Type = "text"
+			p.Options = map[string]interface{}{
+				"mode":    "markdown",
+				"content": w.Markdown,
+			}
+		default:
+			return nil, fmt.Errorf("widgets[%d]: unknown widget type: %s", i, w.Type)
+		}
+		g.Panels = append(g.Panels, p)
+	}
+	return g, nil
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+)
+
+func TestConvertToGrafana(t *testing.T) {
+	d := &mackerel.Dashboard{
+		ID:      "abcde",
+		Title:   "blog",
+		URLPath: "blog",
+		Memo:    "dashboard for blog",
+		Widgets: []mackerel.Widget{
+			{
+				Type:     "markdown",
+				Title:    "about",
+				Markdown: "# blog",
+				Layout:   mackerel.Layout{X: 0, Y: 0, Width: 24, Height: 3},
+			},
+			{
+				Type:   "graph",
+				Title:  "cpu",
+				Graph:  mackerel.Graph{Type: "role", RoleFullName: "blog:app", Name: "cpu.*", IsStacked: true},
+				Range:  mackerel.Range{Type: "relative", Period: 21600},
+				Layout: mackerel.Layout{X: 0, Y: 3, Width: 12, Height: 6},
+			},
+			{
+				Type:   "value",
+				Title:  "loadavg",
+				Metric: mackerel.Metric{Type: "host", HostID: "abcde", Name: "loadavg5"},
+				Layout: mackerel.Layout{X: 12, Y: 3, Width: 12, Height: 6},
+			},
+		},
+	}
+	g, err := convertToGrafana(d)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+  "title": "blog",
+  "tags": [
+    "mackerel"
+  ],
+  "timezone": "browser",
+  "schemaVersion": 27,
+  "time": {
+    "from": "now-6h",
+    "to": "now"
+  },
+  "templating": {
+    "list": [
+      {
+        "name": "DS_MACKEREL",
+        "label": "Mackerel",
+        "type": "datasource",
+        "query": "mackerel-datasource"
+      }
+    ]
+  },
+  "panels": [
+    {
+      "id": 1,
+      "type": "text",
+      "title": "about",
+      "gridPos": {
+        "x": 0,
+        "y": 0,
+        "w": 24,
+        "h": 3
+      },
+      "options": {
+        "content": "# blog",
+        "mode": "markdown"
+      }
+    },
+    {
+      "id": 2,
+      "type": "timeseries",
+      "title": "cpu",
+      "gridPos": {
+        "x": 0,
+        "y": 3,
+        "w": 12,
+        "h": 6
+      },
+      "datasource": "${DS_MACKEREL}",
+      "targets": [
+        {
+          "refId": "A",
+          "type": "role",
+          "roleFullname": "blog:app",
+          "metricName": "cpu.*",
+          "stacked": true
+        }
+      ],
+      "timeFrom": "21600s"
+    },
+    {
+      "id": 3,
+      "type": "stat",
+      "title": "loadavg",
+      "gridPos": {
+        "x": 12,
+        "y": 3,
+        "w": 12,
+        "h": 6
+      },
+      "datasource": "${DS_MACKEREL}",
+      "targets": [
+        {
+          "refId": "A",
+          "type": "host",
+          "hostId": "abcde",
+          "metricName": "loadavg5"
+        }
+      ]
+    }
+  ],
+  "description": "dashboard for blog"
+}`
+	if got := format.JSONMarshalIndent(g, "", "  "); got != expected {
+		t.Errorf("output should be:\n%s\nbut:\n%s", expected, got)
+	}
+
+	if _, err := convertToGrafana(&mackerel.Dashboard{IsLegacy: true}); err == nil {
+		t.Error("convertToGrafana should return an error for legacy dashboards")
+	}
+}
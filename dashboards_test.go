@@ -1,7 +1,20 @@
 package main
 
 import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/urfave/cli"
 )
 
 func TestHostIFrameGraph(t *testing.T) {
@@ -191,3 +204,504 @@ func TestGenerateMarkDown(t *testing.T) {
 		t.Errorf("output should be:\n%s\nbut:\n%s", expected, actual)
 	}
 }
+
+func TestDiffDashboard(t *testing.T) {
+	remote := &mackerel.Dashboard{
+		ID:        "abcde",
+		Title:     "foo",
+		URLPath:   "bar",
+		CreatedAt: 1552909732,
+		UpdatedAt: 1552992837,
+		Widgets: []mackerel.Widget{
+			{Type: "markdown", Title: "memo", Markdown: "# hello"},
+		},
+	}
+	local := &mackerel.Dashboard{
+		Title:   "foo",
+		URLPath: "bar",
+		Widgets: []mackerel.Widget{
+			{Type: "markdown", Title: "memo", Markdown: "# hello"},
+		},
+	}
+	if got := diffDashboard(remote, local, "remote", "local"); got != "" {
+		t.Errorf("diffDashboard should ignore server assigned fields but got:\n%s", got)
+	}
+
+	remote.Widgets = nil
+	local.Widgets = nil
+	local.Title = "baz"
+	const want = `--- remote
++++ local
+@@ -1,4 +1,4 @@
+ {
+-  "title": "foo",
++  "title": "baz",
+   "urlPath": "bar"
+ }`
+	if got := diffDashboard(remote, local, "remote", "local"); got != want {
+		t.Errorf("diffDashboard: got\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestValidateDashboard(t *testing.T) {
+	d := &mackerel.Dashboard{
+		Title:   "foo",
+		URLPath: "bar",
+		Widgets: []mackerel.Widget{
+			{
+				Type:   "markdown",
+				Title:  "memo",
+				Layout: mackerel.Layout{X: 0, Y: 0, Width: 24, Height: 3},
+			},
+			{
+				Type:   "graph",
+				Title:  "cpu",
+				Graph:  mackerel.Graph{Type: "role", RoleFullName: "service:role", Name: "cpu.*"},
+				Layout: mackerel.Layout{X: 0, Y: 3, Width: 12, Height: 6},
+			},
+			{
+				Type:   "value",
+				Title:  "loadavg",
+				Metric: mackerel.Metric{Type: "host", HostID: "abcde", Name: "loadavg5"},
+				Layout: mackerel.Layout{X: 12, Y: 3, Width: 12, Height: 6},
+			},
+		},
+	}
+	if problems := validateDashboard(d); len(problems) != 0 {
+		t.Errorf("should validate the dashboard but: %v", problems)
+	}
+
+	d.Widgets = append(d.Widgets,
+		mackerel.Widget{
+			Type:   "graph",
+			Title:  "memory",
+			Graph:  mackerel.Graph{Type: "host", Name: "memory.*"},
+			Layout: mackerel.Layout{X: 20, Y: 8, Width: 8, Height: 6},
+		},
+		mackerel.Widget{
+			Type:   "chart",
+			Title:  "alerts",
+			Layout: mackerel.Layout{X: 0, Y: 20, Width: 8, Height: 6},
+		},
+	)
+	expected := []string{
+		`widgets[3] (graph "memory"): hostId and name are required for host graph`,
+		`widgets[3] (graph "memory"): layout exceeds the width of the dashboard (24)`,
+		`widgets[3] (graph "memory"): layout overlaps with widgets[2]`,
+		`widgets[4] (chart "alerts"): unknown widget type`,
+	}
+	problems := validateDashboard(d)
+	if len(problems) != len(expected) {
+		t.Fatalf("validateDashboard should return %d problems but: %v", len(expected), problems)
+	}
+	for i, p := range problems {
+		if p.String() != expected[i] {
+			t.Errorf("problem should be:\n%s\nbut:\n%s", expected[i], p.String())
+		}
+	}
+}
+
+func TestDashboardFileName(t *testing.T) {
+	d := &mackerel.Dashboard{ID: "abcde", Title: "My Service (Production)", URLPath: "my/service"}
+	testCases := []struct {
+		format     string
+		fileFormat string
+		expected   string
+	}{
+		{"", "json", "dashboard-abcde.json"},
+		{"id", "json", "dashboard-abcde.json"},
+		{"url-path", "json", "dashboard-my-service.json"},
+		{"title", "json", "dashboard-my-service-production.json"},
+		{"id", "yaml", "dashboard-abcde.yaml"},
+	}
+	for _, tc := range testCases {
+		name, err := dashboardFileName(d, tc.format, tc.fileFormat)
+		if err != nil {
+			t.Errorf("dashboardFileName(%q) returns an error: %s", tc.format, err)
+		}
+		if name != tc.expected {
+			t.Errorf("dashboardFileName(%q) should be %s but: %s", tc.format, tc.expected, name)
+		}
+	}
+
+	if _, err := dashboardFileName(d, "unknown", "json"); err == nil {
+		t.Error("dashboardFileName should return an error for unknown format")
+	}
+}
+
+func TestDashboardSaveAndLoadFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-dashboards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	d := &mackerel.Dashboard{
+		ID:      "abcde",
+		Title:   "foo",
+		URLPath: "bar",
+		Widgets: []mackerel.Widget{
+			{
+				Type:   "graph",
+				Title:  "cpu",
+				Graph:  mackerel.Graph{Type: "role", RoleFullName: "service:role", Name: "cpu.*"},
+				Layout: mackerel.Layout{X: 0, Y: 3, Width: 12, Height: 6},
+			},
+		},
+	}
+	for _, name := range []string{"dashboard.json", "dashboard.yaml", "dashboard.yml"} {
+		filePath := filepath.Join(dir, name)
+		if err := dashboardSaveFile(d, filePath, ""); err != nil {
+			t.Fatal(err)
+		}
+		got, err := dashboardLoadFile(filePath, "", nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !reflect.DeepEqual(d, got) {
+			t.Errorf("%s: loaded dashboard should be %+v but: %+v", name, d, got)
+		}
+	}
+
+	content, _ := ioutil.ReadFile(filepath.Join(dir, "dashboard.yaml"))
+	if !strings.Contains(string(content), "urlPath: bar") {
+		t.Errorf("YAML file should use the keys of JSON but:\n%s", content)
+	}
+}
+
+func TestPlanDashboards(t *testing.T) {
+	remotes := map[string]*mackerel.Dashboard{
+		"id1": {ID: "id1", Title: "same", URLPath: "same"},
+		"id2": {ID: "id2", Title: "before", URLPath: "changed"},
+		"id3": {ID: "id3", Title: "only remote", URLPath: "remote"},
+	}
+	fetch := func(id string) (*mackerel.Dashboard, error) {
+		return remotes[id], nil
+	}
+	files := []*dashboardFile{
+		{"same.json", &mackerel.Dashboard{ID: "id1", Title: "same", URLPath: "same"}},
+		{"changed.json", &mackerel.Dashboard{Title: "after", URLPath: "changed"}},
+		{"new.json", &mackerel.Dashboard{Title: "new", URLPath: "new"}},
+	}
+
+	plan, err := planDashboards(files, []*mackerel.Dashboard{remotes["id1"], remotes["id2"], remotes["id3"]}, fetch)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.create) != 1 || plan.create[0].path != "new.json" {
+		t.Errorf("new.json should be created but: %v", plan.create)
+	}
+	if len(plan.update) != 1 || plan.update[0].path != "changed.json" || plan.update[0].dashboard.ID != "id2" {
+		t.Errorf("changed.json should be updated but: %v", plan.update)
+	}
+	if len(plan.delete) != 1 || plan.delete[0].ID != "id3" {
+		t.Errorf("id3 should be deleted but: %v", plan.delete)
+	}
+
+	stale := append(files[:len(files):len(files)], &dashboardFile{"stale.json", &mackerel.Dashboard{ID: "id9", Title: "stale", URLPath: "remote"}})
+	if _, err := planDashboards(stale, []*mackerel.Dashboard{remotes["id1"], remotes["id2"], remotes["id3"]}, fetch); err == nil {
+		t.Error("planDashboards should return an error for the stale id even if the urlPath exists")
+	}
+
+	files = append(files, &dashboardFile{"unknown.json", &mackerel.Dashboard{ID: "id4", Title: "unknown", URLPath: "unknown"}})
+	if _, err := planDashboards(files, []*mackerel.Dashboard{remotes["id1"], remotes["id2"], remotes["id3"]}, fetch); err == nil {
+		t.Error("planDashboards should return an error for unknown id")
+	}
+}
+
+func TestExpandDashboardTemplate(t *testing.T) {
+	os.Setenv("MKR_TEST_ENV", "production")
+	defer os.Unsetenv("MKR_TEST_ENV")
+
+	src := `{"title": "{{ .service }} ({{ env "MKR_TEST_ENV" }})", "urlPath": "{{ .service }}-{{ env "MKR_TEST_ENV" }}"}`
+	got, err := expandDashboardTemplate("dashboard.json", []byte(src), map[string]interface{}{"service": "blog"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := `{"title": "blog (production)", "urlPath": "blog-production"}`
+	if string(got) != expected {
+		t.Errorf("output should be:\n%s\nbut:\n%s", expected, got)
+	}
+
+	if _, err := expandDashboardTemplate("dashboard.json", []byte(src), map[string]interface{}{}); err == nil {
+		t.Error("expandDashboardTemplate should return an error for missing variables")
+	}
+}
+
+func TestMigrateLegacyDashboard(t *testing.T) {
+	legacy := &mackerel.Dashboard{
+		ID:           "abcde",
+		Title:        "legacy",
+		URLPath:      "legacy",
+		IsLegacy:     true,
+		BodyMarkDown: "# legacy dashboard",
+		CreatedAt:    1439346145003,
+	}
+	got, err := migrateLegacyDashboard(legacy, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &mackerel.Dashboard{
+		ID:      "abcde",
+		Title:   "legacy",
+		URLPath: "legacy",
+		Widgets: []mackerel.Widget{
+			{
+				Type:     "markdown",
+				Title:    "legacy",
+				Markdown: "# legacy dashboard",
+				Layout:   mackerel.Layout{X: 0, Y: 0, Width: 24, Height: 32},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("dashboard should be:\n%+v\nbut:\n%+v", expected, got)
+	}
+	if problems := validateDashboard(got); len(problems) != 0 {
+		t.Errorf("migrated dashboard should be valid but: %v", problems)
+	}
+
+	if _, err := migrateLegacyDashboard(got, false); err == nil {
+		t.Error("migrateLegacyDashboard should return an error for current dashboard")
+	}
+}
+
+func TestFilterAndPrintDashboards(t *testing.T) {
+	time.Local = time.FixedZone("Asia/Tokyo", 9*60*60)
+	defer func() { time.Local = nil }()
+
+	dashboards := []*mackerel.Dashboard{
+		{ID: "id1", Title: "Blog Production", URLPath: "blog-prod", UpdatedAt: 1552992837},
+		{ID: "id2", Title: "Blog Staging", URLPath: "blog-stg", UpdatedAt: 1552992837},
+		{ID: "id3", Title: "Legacy", URLPath: "legacy", UpdatedAt: 1439346145003, IsLegacy: true},
+	}
+
+	filtered := filterDashboards(dashboards, "blog", "")
+	if len(filtered) != 2 {
+		t.Errorf("filterDashboards by title should return 2 dashboards but: %d", len(filtered))
+	}
+	filtered = filterDashboards(dashboards, "blog", "prod")
+	if len(filtered) != 1 || filtered[0].ID != "id1" {
+		t.Errorf("filterDashboards by title and urlPath should return id1 but: %v", filtered)
+	}
+
+	out := new(bytes.Buffer)
+	if err := printDashboards(out, dashboards, "table"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `ID   TITLE            URL_PATH   LEGACY  UPDATED_AT
+id1  Blog Production  blog-prod  false   2019-03-19T19:53:57+09:00
+id2  Blog Staging     blog-stg   false   2019-03-19T19:53:57+09:00
+id3  Legacy           legacy     true    2015-08-12T11:22:25+09:00
+`
+	if out.String() != expected {
+		t.Errorf("output should be:\n%s\nbut:\n%s", expected, out.String())
+	}
+
+	out.Reset()
+	if err := printDashboards(out, filtered, "yaml"); err != nil {
+		t.Fatal(err)
+	}
+	expected = `- id: id1
+  title: Blog Production
+  updatedAt: 1552992837
+  urlPath: blog-prod
+`
+	if out.String() != expected {
+		t.Errorf("output should be:\n%s\nbut:\n%s", expected, out.String())
+	}
+
+	if err := printDashboards(out, dashboards, "csv"); err == nil {
+		t.Error("printDashboards should return an error for unknown output")
+	}
+}
+
+func TestCloneDashboard(t *testing.T) {
+	d := &mackerel.Dashboard{
+		ID:        "abcde",
+		Title:     "blog",
+		URLPath:   "blog",
+		Memo:      "memo",
+		CreatedAt: 1552909732,
+		Widgets: []mackerel.Widget{
+			{
+				Type:   "graph",
+				Title:  "cpu",
+				Graph:  mackerel.Graph{Type: "role", RoleFullName: "blog:app", Name: "cpu.*"},
+				Layout: mackerel.Layout{X: 0, Y: 0, Width: 12, Height: 6},
+			},
+			{
+				Type:   "value",
+				Title:  "requests",
+				Metric: mackerel.Metric{Type: "expression", Expression: "sum(roleSlots('blog:app','custom.access.count'))"},
+				Layout: mackerel.Layout{X: 12, Y: 0, Width: 12, Height: 6},
+			},
+		},
+	}
+	got := cloneDashboard(d, "shop", "shop", regexp.MustCompile(`\bblog:`), "shop:")
+	expected := &mackerel.Dashboard{
+		Title:   "shop",
+		URLPath: "shop",
+		Memo:    "memo",
+		Widgets: []mackerel.Widget{
+			{
+				Type:   "graph",
+				Title:  "cpu",
+				Graph:  mackerel.Graph{Type: "role", RoleFullName: "shop:app", Name: "cpu.*"},
+				Layout: mackerel.Layout{X: 0, Y: 0, Width: 12, Height: 6},
+			},
+			{
+				Type:   "value",
+				Title:  "requests",
+				Metric: mackerel.Metric{Type: "expression", Expression: "sum(roleSlots('shop:app','custom.access.count'))"},
+				Layout: mackerel.Layout{X: 12, Y: 0, Width: 12, Height: 6},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("dashboard should be:\n%+v\nbut:\n%+v", expected, got)
+	}
+	if d.Widgets[0].Graph.RoleFullName != "blog:app" {
+		t.Error("cloneDashboard should not modify the original dashboard")
+	}
+}
+
+type fakeDashboardWriter struct {
+	calls   []string
+	failURL string
+}
+
+func (w *fakeDashboardWriter) CreateDashboard(d *mackerel.Dashboard) (*mackerel.Dashboard, error) {
+	w.calls = append(w.calls, "create "+d.URLPath)
+	if d.URLPath == w.failURL {
+		return nil, fmt.Errorf("API error")
+	}
+	return &mackerel.Dashboard{ID: "new-" + d.URLPath}, nil
+}
+
+func (w *fakeDashboardWriter) UpdateDashboard(id string, d *mackerel.Dashboard) (*mackerel.Dashboard, error) {
+	w.calls = append(w.calls, "update "+id+" "+d.Title)
+	if d.URLPath == w.failURL {
+		return nil, fmt.Errorf("API error")
+	}
+	return d, nil
+}
+
+func (w *fakeDashboardWriter) DeleteDashboard(id string) (*mackerel.Dashboard, error) {
+	w.calls = append(w.calls, "delete "+id)
+	return &mackerel.Dashboard{ID: id}, nil
+}
+
+func TestApplyAndRollbackDashboardPushSteps(t *testing.T) {
+	steps := []*dashboardPushStep{
+		{
+			file:     &dashboardFile{"a.json", &mackerel.Dashboard{Title: "new a", URLPath: "a"}},
+			remoteID: "id-a",
+			snapshot: &mackerel.Dashboard{ID: "id-a", Title: "old a", URLPath: "a", UpdatedAt: 1552992837},
+		},
+		{
+			file: &dashboardFile{"b.json", &mackerel.Dashboard{Title: "new b", URLPath: "b"}},
+		},
+		{
+			file: &dashboardFile{"c.json", &mackerel.Dashboard{Title: "new c", URLPath: "c"}},
+		},
+		{
+			file:     &dashboardFile{"d.json", &mackerel.Dashboard{Title: "new d", URLPath: "d"}},
+			remoteID: "id-d",
+			snapshot: &mackerel.Dashboard{ID: "id-d", Title: "old d", URLPath: "d"},
+		},
+	}
+	w := &fakeDashboardWriter{failURL: "c"}
+	if err := applyDashboardPushSteps(w, steps); err == nil {
+		t.Fatal("applyDashboardPushSteps should return an error")
+	}
+	applied := []bool{true, true, false, false}
+	for i, s := range steps {
+		if s.applied != applied[i] {
+			t.Errorf("steps[%d].applied should be %t", i, applied[i])
+		}
+	}
+
+	if errs := rollbackDashboardPushSteps(w, steps); len(errs) != 0 {
+		t.Errorf("rollbackDashboardPushSteps should not return errors but: %v", errs)
+	}
+	expected := []string{
+		"update id-a new a",
+		"create b",
+		"create c",
+		"delete new-b",
+		"update id-a old a",
+	}
+	if !reflect.DeepEqual(expected, w.calls) {
+		t.Errorf("calls should be %v but: %v", expected, w.calls)
+	}
+	for i, s := range steps {
+		if s.applied {
+			t.Errorf("steps[%d] should be rolled back", i)
+		}
+	}
+}
+
+func TestDashboardTemplateVars_markdownWithoutVars(t *testing.T) {
+	set := flag.NewFlagSet("push", flag.ContinueOnError)
+	set.String("vars-file", "", "")
+	set.Var(&cli.StringSlice{}, "var", "")
+	if err := set.Parse(nil); err != nil {
+		t.Fatal(err)
+	}
+	vars, err := dashboardTemplateVars(cli.NewContext(nil, set, nil))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if vars != nil {
+		t.Errorf("vars should be nil without --var and --vars-file but: %v", vars)
+	}
+
+	dir, err := ioutil.TempDir("", "mkr-dashboards")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "dashboard.json")
+	src := `{"title": "docs", "urlPath": "docs", "widgets": [{"type": "markdown", "title": "memo", "markdown": "use {{ .service }} in the templates"}]}`
+	if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+	d, err := dashboardLoadFile(filePath, "", vars)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.Widgets) != 1 || d.Widgets[0].Markdown != "use {{ .service }} in the templates" {
+		t.Errorf("markdown should be kept literally but: %+v", d.Widgets)
+	}
+}
+
+func TestPlanDashboardPushSteps(t *testing.T) {
+	remotes := []*mackerel.Dashboard{{ID: "id1", URLPath: "blog"}, {ID: "id2", URLPath: "shop"}}
+	files := []*dashboardFile{
+		{"blog.json", &mackerel.Dashboard{URLPath: "blog"}},
+		{"shop.json", &mackerel.Dashboard{ID: "id2", URLPath: "shop"}},
+		{"new.json", &mackerel.Dashboard{URLPath: "new"}},
+	}
+	steps, err := planDashboardPushSteps(files, remotes)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, s := range steps {
+		got = append(got, s.String())
+	}
+	expected := []string{"blog.json (update id1)", "shop.json (update id2)", "new.json (create new)"}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("steps should be %v but: %v", expected, got)
+	}
+
+	for _, dup := range []*dashboardFile{
+		{"blog2.json", &mackerel.Dashboard{ID: "id1", URLPath: "blog2"}},
+		{"new2.json", &mackerel.Dashboard{URLPath: "new"}},
+	} {
+		if _, err := planDashboardPushSteps(append(files[:len(files):len(files)], dup), remotes); err == nil {
+			t.Errorf("%s should be rejected since it pushes the same dashboard", dup.path)
+		}
+	}
+}
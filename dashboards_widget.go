@@ -0,0 +1,294 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/urfave/cli"
+)
+
+// widgetConfig is a widget definition of config_version 1.0
+type widgetConfig struct {
+	Type        string `yaml:"type"`
+	Title       string `yaml:"title"`
+	HostID      string `yaml:"host_id"`
+	ServiceName string `yaml:"service_name"`
+	RoleName    string `yaml:"role_name"`
+	Query       string `yaml:"query"`
+	GraphName   string `yaml:"graph_name"`
+	MetricName  string `yaml:"metric_name"`
+	Stacked     bool   `yaml:"stacked"`
+	Period      string `yaml:"period"`
+	Markdown    string `yaml:"markdown"`
+
+	Layout *widgetLayout `yaml:"layout"`
+}
+
+type widgetLayout struct {
+	X      int64 `yaml:"x"`
+	Y      int64 `yaml:"y"`
+	Width  int64 `yaml:"width"`
+	Height int64 `yaml:"height"`
+}
+
+// default sizes of widgets used when the layout is not specified
+var defaultWidgetSizes = map[string]mackerel.Layout{
+	"graph":    {Width: 8, Height: 6},
+	"value":    {Width: 8, Height: 5},
+	"markdown": {Width: dashboardGridWidth, Height: 3},
+}
+
+func (w widgetConfig) toGraph() (mackerel.Graph, error) {
+	switch {
+	case w.Query != "":
+		return mackerel.Graph{Type: "expression", Expression: w.Query}, nil
+	case w.HostID != "":
+		if w.GraphName == "" {
+			return mackerel.Graph{}, fmt.Errorf("graph_name is required for host graph")
+		}
+		return mackerel.Graph{Type: "host", HostID: w.HostID, Name: w.GraphName}, nil
+	case w.ServiceName != "" && w.RoleName != "":
+		if w.GraphName == "" {
+			return mackerel.Graph{}, fmt.Errorf("graph_name is required for role graph")
+		}
+		return mackerel.Graph{
+			Type:         "role",
+			RoleFullName: w.ServiceName + ":" + w.RoleName,
+			Name:         w.GraphName,
+			IsStacked:    w.Stacked,
+		}, nil
+	case w.ServiceName != "":
+		if w.GraphName == "" {
+			return mackerel.Graph{}, fmt.Errorf("graph_name is required for service graph")
+		}
+		return mackerel.Graph{Type: "service", ServiceName: w.ServiceName, Name: w.GraphName}, nil
+	}
+	return mackerel.Graph{}, fmt.Errorf("either host_id, service_name or query should be specified")
+}
+
+func (w widgetConfig) toMetric() (mackerel.Metric, error) {
+	switch {
+	case w.Query != "":
+		return mackerel.Metric{Type: "expression", Expression: w.Query}, nil
+	case w.HostID != "":
+		if w.MetricName == "" {
+			return mackerel.Metric{}, fmt.Errorf("metric_name is required for host metric")
+		}
+		return mackerel.Metric{Type: "host", HostID: w.HostID, Name: w.MetricName}, nil
+	case w.ServiceName != "":
+		if w.MetricName == "" {
+			return mackerel.Metric{}, fmt.Errorf("metric_name is required for service metric")
+		}
+		return mackerel.Metric{Type: "service", ServiceName: w.ServiceName, Name: w.MetricName}, nil
+	}
+	return mackerel.Metric{}, fmt.Errorf("either host_id, service_name or query should be specified")
+}
+
+func (w widgetConfig) toWidget() (mackerel.Widget, error) {
+	widget := mackerel.Widget{Type: w.Type, Title: w.Title}
+	var err error
+	switch w.Type {
+	case "graph":
+		if widget.Graph, err = w.toGraph(); err != nil {
+			return widget, err
+		}
+		if w.Period != "" {
+			period, err := parseWidgetPeriod(w.Period)
+			if err != nil {
+				return widget, err
+			}
+			widget.Range = mackerel.Range{Type: "relative", Period: period}
+		}
+	case "value":
+		if widget.Metric, err = w.toMetric(); err != nil {
+			return widget, err
+		}
+	case "markdown":
+		widget.Markdown = w.Markdown
+	case "alertStatus":
+		// mackerel-client-go does not support roleFullname of alert status widgets yet
+		return widget, fmt.Errorf("alertStatus widget is not supported yet")
+	default:
+		return widget, fmt.Errorf("type should be 'graph', 'value' or 'markdown'")
+	}
+	return widget, nil
+}
+
+// parseWidgetPeriod parses a period such as "30m", "6h" or "1d" and returns it in seconds.
+func parseWidgetPeriod(s string) (int64, error) {
+	if strings.HasSuffix(s, "d") {
+		days, err := strconv.ParseInt(strings.TrimSuffix(s, "d"), 10, 64)
+		if err != nil || days <= 0 {
+			return 0, fmt.Errorf("invalid period: %s", s)
+		}
+		return days * 24 * 60 * 60, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid period: %s", s)
+	}
+	return int64(d / time.Second), nil
+}
+
+// generateWidgetDashboard converts config_version 1.0 into a dashboard.
+// The widgets without layout are placed from left to right below the preceding widgets.
+func generateWidgetDashboard(yml *graphsConfig) (*mackerel.Dashboard, error) {
+	dashboard := &mackerel.Dashboard{
+		Title:   yml.Title,
+		URLPath: yml.URLPath,
+		Memo:    yml.Memo,
+	}
+
+	var x, y, rowHeight int64
+	for i, w := range yml.Widgets {
+		widget, err := w.toWidget()
+		if err != nil {
+			return nil, cli.NewExitError(fmt.Sprintf("widgets[%d]: %s.", i, err), 1)
+		}
+
+		if w.Layout != nil {
+			widget.Layout = mackerel.Layout{X: w.Layout.X, Y: w.Layout.Y, Width: w.Layout.Width, Height: w.Layout.Height}
+			if bottom := widget.Layout.Y + widget.Layout.Height; bottom > y+rowHeight {
+				x, y, rowHeight = 0, bottom, 0
+			}
+		} else {
+			size := defaultWidgetSizes[w.Type]
+			if x+size.Width > dashboardGridWidth {
+				x, y, rowHeight = 0, y+rowHeight, 0
+			}
+			widget.Layout = mackerel.Layout{X: x, Y: y, Width: size.Width, Height: size.Height}
+			x += size.Width
+			if size.Height > rowHeight {
+				rowHeight = size.Height
+			}
+		}
+		dashboard.Widgets = append(dashboard.Widgets, widget)
+	}
+	return dashboard, nil
+}
+
+var (
+	embedGraphURLPattern  = regexp.MustCompile(`https://mackerel\.io/embed/orgs/[^"'\s)]+`)
+	embedGraphPathPattern = regexp.MustCompile(`^/embed/orgs/[^/]+/(?:hosts/([^/]+)|services/([^/]+)(?:/([^/]+))?|(advanced-graph))$`)
+	// matches the lines consist of the markups of tables and embedded graphs
+	legacyLayoutLinePattern = regexp.MustCompile(`^(?:[|:\-\s]|<iframe[^>]*>\s*</iframe>|\[!\[[^\]]*\]\([^)]*\)\]\([^)]*\)|!\[[^\]]*\]\([^)]*\))*$`)
+)
+
+// parseEmbedGraphURL converts the URL of an embedded graph into a graph widget definition.
+func parseEmbedGraphURL(rawurl string) (*widgetConfig, bool) {
+	u, err := url.Parse(html.UnescapeString(rawurl))
+	if err != nil {
+		return nil, false
+	}
+	m := embedGraphPathPattern.FindStringSubmatch(strings.TrimSuffix(u.Path, ".png"))
+	if m == nil {
+		return nil, false
+	}
+	q := u.Query()
+	w := &widgetConfig{Type: "graph", GraphName: q.Get("graph"), Title: q.Get("graph")}
+	switch {
+	case m[1] != "":
+		w.HostID = m[1]
+	case m[2] != "":
+		w.ServiceName = m[2]
+		w.RoleName = m[3]
+		w.Stacked = q.Get("stacked") == "true"
+	case m[4] != "":
+		w.Query = q.Get("query")
+		w.Title = q.Get("title")
+		if w.Title == "" {
+			w.Title = w.Query
+		}
+	}
+	if period := q.Get("period"); period != "" {
+		if _, err := parseWidgetPeriod(period); err == nil {
+			w.Period = period
+		}
+	}
+	if _, err := w.toGraph(); err != nil {
+		return nil, false
+	}
+	return w, true
+}
+
+// convertLegacyMarkdown converts the markdown of a legacy dashboard into widget definitions.
+// The embedded graphs are converted into graph widgets and the other texts are kept in markdown widgets.
+func convertLegacyMarkdown(markdown string) []*widgetConfig {
+	var widgets []*widgetConfig
+	var texts []string
+	flush := func() {
+		text := strings.Trim(strings.Join(texts, "\n"), "\n")
+		if text != "" {
+			widgets = append(widgets, &widgetConfig{Type: "markdown", Markdown: text})
+		}
+		texts = nil
+	}
+
+	for _, line := range strings.Split(markdown, "\n") {
+		var graphs []*widgetConfig
+		allConverted := true
+		for _, rawurl := range embedGraphURLPattern.FindAllString(line, -1) {
+			if w, ok := parseEmbedGraphURL(rawurl); ok {
+				graphs = append(graphs, w)
+			} else {
+				allConverted = false
+			}
+		}
+		if len(graphs) > 0 {
+			flush()
+			widgets = append(widgets, graphs...)
+		}
+		if (len(graphs) > 0 && allConverted) || (strings.TrimSpace(line) != "" && legacyLayoutLinePattern.MatchString(line)) {
+			continue
+		}
+		texts = append(texts, line)
+	}
+	flush()
+	return widgets
+}
+
+// the system graphs shown in the dashboards generated from roles
+var roleDashboardSystemGraphs = []string{"cpu", "memory", "disk", "interface"}
+
+// roleDashboardWidgets returns the widget definitions of a role with the metric names of its hosts.
+func roleDashboardWidgets(service, role string, metricNames []string) []*widgetConfig {
+	system := map[string]bool{}
+	custom := map[string]bool{}
+	for _, name := range metricNames {
+		parts := strings.Split(name, ".")
+		if len(parts) < 2 {
+			continue
+		}
+		if parts[0] == "custom" {
+			// the graph of custom metrics is named by the metric name except the last part
+			custom[strings.Join(parts[:len(parts)-1], ".")+".*"] = true
+		} else {
+			system[parts[0]] = true
+		}
+	}
+
+	widgets := []*widgetConfig{
+		{Type: "markdown", Title: service + ":" + role, Markdown: fmt.Sprintf("# %s:%s", service, role)},
+	}
+	for _, graph := range roleDashboardSystemGraphs {
+		if system[graph] {
+			widgets = append(widgets, &widgetConfig{Type: "graph", Title: graph, ServiceName: service, RoleName: role, GraphName: graph})
+		}
+	}
+	graphs := make([]string, 0, len(custom))
+	for graph := range custom {
+		graphs = append(graphs, graph)
+	}
+	sort.Strings(graphs)
+	for _, graph := range graphs {
+		widgets = append(widgets, &widgetConfig{Type: "graph", Title: graph, ServiceName: service, RoleName: role, GraphName: graph})
+	}
+	return widgets
+}
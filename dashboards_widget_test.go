@@ -0,0 +1,189 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	yaml "gopkg.in/yaml.v2"
+)
+
+func TestGenerateWidgetDashboard(t *testing.T) {
+	src := `
+config_version: "1.0"
+title: "blog"
+url_path: "blog"
+memo: "dashboard for blog"
+widgets:
+  - type: markdown
+    title: "about"
+    markdown: "# blog"
+  - type: graph
+    title: "cpu"
+    service_name: "blog"
+    role_name: "app"
+    graph_name: "cpu.*"
+    stacked: true
+    period: "6h"
+  - type: graph
+    title: "requests"
+    service_name: "blog"
+    graph_name: "access.*"
+  - type: value
+    title: "loadavg"
+    host_id: "abcde"
+    metric_name: "loadavg5"
+  - type: graph
+    title: "expression"
+    query: "avg(roleSlots('blog:app','loadavg5'))"
+    period: "1d"
+  - type: markdown
+    title: "footer"
+    markdown: "footer"
+    layout:
+      x: 0
+      y: 20
+      width: 24
+      height: 2
+`
+	var yml graphsConfig
+	if err := yaml.Unmarshal([]byte(src), &yml); err != nil {
+		t.Fatal(err)
+	}
+	got, err := generateWidgetDashboard(&yml)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := &mackerel.Dashboard{
+		Title:   "blog",
+		URLPath: "blog",
+		Memo:    "dashboard for blog",
+		Widgets: []mackerel.Widget{
+			{
+				Type:     "markdown",
+				Title:    "about",
+				Markdown: "# blog",
+				Layout:   mackerel.Layout{X: 0, Y: 0, Width: 24, Height: 3},
+			},
+			{
+				Type:   "graph",
+				Title:  "cpu",
+				Graph:  mackerel.Graph{Type: "role", RoleFullName: "blog:app", Name: "cpu.*", IsStacked: true},
+				Range:  mackerel.Range{Type: "relative", Period: 6 * 60 * 60},
+				Layout: mackerel.Layout{X: 0, Y: 3, Width: 8, Height: 6},
+			},
+			{
+				Type:   "graph",
+				Title:  "requests",
+				Graph:  mackerel.Graph{Type: "service", ServiceName: "blog", Name: "access.*"},
+				Layout: mackerel.Layout{X: 8, Y: 3, Width: 8, Height: 6},
+			},
+			{
+				Type:   "value",
+				Title:  "loadavg",
+				Metric: mackerel.Metric{Type: "host", HostID: "abcde", Name: "loadavg5"},
+				Layout: mackerel.Layout{X: 16, Y: 3, Width: 8, Height: 5},
+			},
+			{
+				Type:   "graph",
+				Title:  "expression",
+				Graph:  mackerel.Graph{Type: "expression", Expression: "avg(roleSlots('blog:app','loadavg5'))"},
+				Range:  mackerel.Range{Type: "relative", Period: 24 * 60 * 60},
+				Layout: mackerel.Layout{X: 0, Y: 9, Width: 8, Height: 6},
+			},
+			{
+				Type:     "markdown",
+				Title:    "footer",
+				Markdown: "footer",
+				Layout:   mackerel.Layout{X: 0, Y: 20, Width: 24, Height: 2},
+			},
+		},
+	}
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("dashboard should be:\n%+v\nbut:\n%+v", expected, got)
+	}
+	if problems := validateDashboard(got); len(problems) != 0 {
+		t.Errorf("generated dashboard should be valid but: %v", problems)
+	}
+}
+
+func TestGenerateWidgetDashboard_Error(t *testing.T) {
+	testCases := []struct {
+		id     string
+		widget *widgetConfig
+	}{
+		{"unknown type", &widgetConfig{Type: "chart"}},
+		{"no target", &widgetConfig{Type: "graph"}},
+		{"no graph name", &widgetConfig{Type: "graph", HostID: "abcde"}},
+		{"no metric name", &widgetConfig{Type: "value", ServiceName: "blog"}},
+		{"invalid period", &widgetConfig{Type: "graph", Query: "host(abcde, loadavg5)", Period: "1week"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			yml := &graphsConfig{Title: "blog", URLPath: "blog", Widgets: []*widgetConfig{tc.widget}}
+			if _, err := generateWidgetDashboard(yml); err == nil {
+				t.Error("generateWidgetDashboard should return an error")
+			}
+		})
+	}
+}
+
+func TestConvertLegacyMarkdown(t *testing.T) {
+	defs := []*graphDef{
+		{ServiceName: "hoge", RoleName: "api", GraphName: "cpu", Stacked: true},
+		{HostID: "abcde", GraphName: "loadavg5", Period: "6h"},
+		{ServiceName: "hoge", GraphName: "custom.access.*"},
+		{Query: "max(roleSlots('hoge:api','loadavg5'))", GraphTitle: "max loadavg5"},
+	}
+	md, err := generateGraphsMarkdownFactory(&graphFormat{Headline: "headline", ColumnCount: 2, GraphDefs: defs}, "iframe", 200, 400)
+	if err != nil {
+		t.Fatal(err)
+	}
+	markdown := md.generate("orgname") + "some notes\n"
+
+	expected := []*widgetConfig{
+		{Type: "markdown", Markdown: "## headline"},
+		{Type: "graph", Title: "cpu", ServiceName: "hoge", RoleName: "api", GraphName: "cpu", Stacked: true, Period: "1h"},
+		{Type: "graph", Title: "loadavg5", HostID: "abcde", GraphName: "loadavg5", Period: "6h"},
+		{Type: "graph", Title: "custom.access.*", ServiceName: "hoge", GraphName: "custom.access.*", Period: "1h"},
+		{Type: "graph", Title: "max loadavg5", Query: "max(roleSlots('hoge:api','loadavg5'))", Period: "1h"},
+		{Type: "markdown", Markdown: "some notes"},
+	}
+	got := convertLegacyMarkdown(markdown)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("widgets should be:\n%s\nbut:\n%s", format.JSONMarshalIndent(expected, "", "  "), format.JSONMarshalIndent(got, "", "  "))
+	}
+
+	md, _ = generateGraphsMarkdownFactory(&graphFormat{ColumnCount: 1, GraphDefs: defs[1:2]}, "image", 200, 400)
+	got = convertLegacyMarkdown(md.generate("orgname"))
+	if len(got) != 1 || got[0].HostID != "abcde" {
+		t.Errorf("image graph should be converted but: %s", format.JSONMarshalIndent(got, "", "  "))
+	}
+}
+
+func TestRoleDashboardWidgets(t *testing.T) {
+	metricNames := []string{
+		"loadavg5",
+		"cpu.user.percentage",
+		"cpu.system.percentage",
+		"interface.eth0.rxBytes.delta",
+		"memory.used",
+		"custom.nginx.requests.requests",
+		"custom.nginx.connections.active",
+		"custom.nginx.connections.reading",
+	}
+	expected := []*widgetConfig{
+		{Type: "markdown", Title: "blog:app", Markdown: "# blog:app"},
+		{Type: "graph", Title: "cpu", ServiceName: "blog", RoleName: "app", GraphName: "cpu"},
+		{Type: "graph", Title: "memory", ServiceName: "blog", RoleName: "app", GraphName: "memory"},
+		{Type: "graph", Title: "interface", ServiceName: "blog", RoleName: "app", GraphName: "interface"},
+		{Type: "graph", Title: "custom.nginx.connections.*", ServiceName: "blog", RoleName: "app", GraphName: "custom.nginx.connections.*"},
+		{Type: "graph", Title: "custom.nginx.requests.*", ServiceName: "blog", RoleName: "app", GraphName: "custom.nginx.requests.*"},
+	}
+	got := roleDashboardWidgets("blog", "app", metricNames)
+	if !reflect.DeepEqual(expected, got) {
+		t.Errorf("widgets should be:\n%s\nbut:\n%s", format.JSONMarshalIndent(expected, "", "  "), format.JSONMarshalIndent(got, "", "  "))
+	}
+}
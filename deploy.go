@@ -0,0 +1,331 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandDeploy = cli.Command{
+	Name:  "deploy",
+	Usage: "Track a deployment",
+	Description: `
+    Track a deployment of the service with a graph annotation. 'mkr deploy start' posts the annotation of the revision,
+    and 'mkr deploy finish' extends it to the end of the deployment and posts the duration as a service metric.
+    The started deployments are recorded in ~/.cache/mkr/deploys and in the service metadata of the namespace
+    'mkr-deploy-<revision>', so 'mkr deploy finish' can also run on another machine.
+`,
+	Subcommands: []cli.Command{
+		{
+			Name:      "start",
+			Usage:     "start a deployment",
+			ArgsUsage: "--service | -s <service> --revision <revision> [[--role | -r <role>]...] [--maintenance] [--description <description>]",
+			Description: `
+    Posts the annotation of the deployment of the revision to the service, or the roles with --role.
+    With --maintenance, the working hosts of the roles are changed to maintenance until 'mkr deploy finish'.
+`,
+			Action: doDeployStart,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "service, s", Value: "", Usage: "The service to deploy"},
+				cli.StringFlag{Name: "revision", Value: "", Usage: "The revision to deploy such as the commit hash"},
+				cli.StringSliceFlag{Name: "role, r", Value: &cli.StringSlice{}, Usage: "The roles to deploy. Multiple choices are allowed"},
+				cli.BoolFlag{Name: "maintenance", Usage: "Change the working hosts of the roles to maintenance during the deployment"},
+				cli.StringFlag{Name: "description", Value: "", Usage: "The description of the annotation"},
+			},
+		},
+		{
+			Name:      "finish",
+			Usage:     "finish a deployment",
+			ArgsUsage: "--service | -s <service> --revision <revision> [--metric-name <name>]",
+			Description: `
+    Extends the annotation of the deployment to now, changes the hosts back to working if they were changed
+    to maintenance, and posts the duration of the deployment in seconds as the service metric.
+    The hosts failed to change back are kept in the record, and they are retried by running the command again.
+`,
+			Action: doDeployFinish,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "service, s", Value: "", Usage: "The service to deploy"},
+				cli.StringFlag{Name: "revision", Value: "", Usage: "The revision to deploy such as the commit hash"},
+				cli.StringFlag{Name: "metric-name", Value: "deploy.duration", Usage: "The name of the service metric of the duration"},
+			},
+		},
+	},
+}
+
+// deployState is the deployment recorded by mkr deploy start
+type deployState struct {
+	Service          string   `json:"service"`
+	Revision         string   `json:"revision"`
+	Roles            []string `json:"roles,omitempty"`
+	Description      string   `json:"description,omitempty"`
+	StartedAt        int64    `json:"startedAt"`
+	AnnotationID     string   `json:"annotationId"`
+	MaintenanceHosts []string `json:"maintenanceHosts,omitempty"`
+	FinishedAt       int64    `json:"finishedAt,omitempty"`
+}
+
+func deployStateFile(service, revision string) string {
+	return filepath.Join(mackerelclient.DefaultCacheDir(), "deploys", url.PathEscape(service)+"@"+url.PathEscape(revision)+".json")
+}
+
+func loadDeployState(file string) (*deployState, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	var s deployState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", file, err)
+	}
+	return &s, nil
+}
+
+func saveDeployState(file string, s *deployState) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(file, data, 0600)
+}
+
+var invalidNamespaceChars = regexp.MustCompile(`[^a-zA-Z0-9_-]`)
+
+// deployMetadataNamespace returns the namespace of the service metadata to record the deployment.
+func deployMetadataNamespace(revision string) string {
+	return "mkr-deploy-" + invalidNamespaceChars.ReplaceAllString(revision, "_")
+}
+
+// loadRemoteDeployState loads the deployment recorded in the service metadata. It returns nil if not found.
+func loadRemoteDeployState(client deployClient, service, revision string) (*deployState, error) {
+	resp, err := client.GetServiceMetaData(service, deployMetadataNamespace(revision))
+	if err != nil {
+		if apiErr, ok := err.(*mackerel.APIError); ok && apiErr.StatusCode == http.StatusNotFound {
+			return nil, nil
+		}
+		return nil, err
+	}
+	data, err := json.Marshal(resp.ServiceMetaData)
+	if err != nil {
+		return nil, err
+	}
+	var s deployState
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to load the service metadata of %s: %s", deployMetadataNamespace(revision), err)
+	}
+	return &s, nil
+}
+
+// saveDeployStates saves the deployment to the file and to the service metadata.
+// The failure of the service metadata is only logged since the file is enough on the same machine.
+func saveDeployStates(client deployClient, file string, s *deployState) error {
+	if err := saveDeployState(file, s); err != nil {
+		return err
+	}
+	if err := client.PutServiceMetaData(s.Service, deployMetadataNamespace(s.Revision), s); err != nil {
+		logger.Log("warning", fmt.Sprintf("failed to record the deployment in the service metadata: %s", err))
+	}
+	return nil
+}
+
+// removeDeployStates removes the deployment from the file and the service metadata.
+func removeDeployStates(client deployClient, file string, s *deployState) error {
+	if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err := client.DeleteServiceMetaData(s.Service, deployMetadataNamespace(s.Revision)); err != nil {
+		logger.Log("warning", fmt.Sprintf("failed to remove the deployment from the service metadata: %s", err))
+	}
+	return nil
+}
+
+// shortRevision shortens the commit hash to 7 characters as git does.
+func shortRevision(revision string) string {
+	if len(revision) > 7 && strings.Trim(revision, "0123456789abcdef") == "" {
+		return revision[:7]
+	}
+	return revision
+}
+
+func (s *deployState) annotation(to int64, inProgress bool) *mackerel.GraphAnnotation {
+	title := "deploy " + shortRevision(s.Revision)
+	description := s.Description
+	if description != "" {
+		description += "\n\n"
+	}
+	description += "revision: " + s.Revision
+	if inProgress {
+		title += " (in progress)"
+	} else {
+		description += fmt.Sprintf("\nduration: %s", time.Duration(to-s.StartedAt)*time.Second)
+	}
+	return &mackerel.GraphAnnotation{
+		ID:          s.AnnotationID,
+		Title:       title,
+		Description: description,
+		From:        s.StartedAt,
+		To:          to,
+		Service:     s.Service,
+		Roles:       s.Roles,
+	}
+}
+
+type deployClient interface {
+	hostFinder
+	CreateGraphAnnotation(annotation *mackerel.GraphAnnotation) (*mackerel.GraphAnnotation, error)
+	UpdateGraphAnnotation(annotationID string, annotation *mackerel.GraphAnnotation) (*mackerel.GraphAnnotation, error)
+	UpdateHostStatus(hostID string, status string) error
+	PostServiceMetricValues(serviceName string, metricValues []*mackerel.MetricValue) error
+	GetServiceMetaData(serviceName, namespace string) (*mackerel.ServiceMetaDataResp, error)
+	PutServiceMetaData(serviceName, namespace string, metadata mackerel.ServiceMetaData) error
+	DeleteServiceMetaData(serviceName, namespace string) error
+}
+
+// startDeploy posts the annotation of the deployment and changes the working hosts of the roles to maintenance
+// with maintenance. The hosts changed to maintenance are recorded in the state even if some of them are failed.
+func startDeploy(client deployClient, s *deployState, maintenance bool) (failed, total int, err error) {
+	created, err := client.CreateGraphAnnotation(s.annotation(s.StartedAt, true))
+	if err != nil {
+		return 0, 0, err
+	}
+	s.AnnotationID = created.ID
+	logger.Log("created", fmt.Sprintf("annotation %s of %s", created.ID, created.Title))
+	if !maintenance {
+		return 0, 0, nil
+	}
+
+	hosts, err := client.FindHosts(&mackerel.FindHostsParam{Service: s.Service, Roles: s.Roles, Statuses: []string{"working"}})
+	if err != nil {
+		return 0, 0, err
+	}
+	for _, h := range hosts {
+		if err := client.UpdateHostStatus(h.ID, "maintenance"); err != nil {
+			logger.Log("error", fmt.Sprintf("failed to change %s to maintenance: %s", h.ID, err))
+			failed++
+			continue
+		}
+		s.MaintenanceHosts = append(s.MaintenanceHosts, h.ID)
+		logger.Log("maintenance", h.ID+" "+h.Name)
+	}
+	return failed, len(hosts), nil
+}
+
+// finishDeploy changes the hosts back to working, extends the annotation to now and posts the duration.
+// Only the hosts failed to change are left in the state to retry them, and the annotation and the duration
+// are not posted again once the deployment is finished.
+func finishDeploy(client deployClient, s *deployState, metricName string, now time.Time) (failed, total int, err error) {
+	total = len(s.MaintenanceHosts)
+	var remaining []string
+	for _, id := range s.MaintenanceHosts {
+		if err := client.UpdateHostStatus(id, "working"); err != nil {
+			logger.Log("error", fmt.Sprintf("failed to change %s back to working: %s", id, err))
+			remaining = append(remaining, id)
+			continue
+		}
+		logger.Log("working", id)
+	}
+	s.MaintenanceHosts = remaining
+	failed = len(remaining)
+	if s.FinishedAt != 0 {
+		return failed, total, nil
+	}
+
+	a := s.annotation(now.Unix(), false)
+	if _, err := client.UpdateGraphAnnotation(s.AnnotationID, a); err != nil {
+		return failed, total, err
+	}
+	logger.Log("updated", fmt.Sprintf("annotation %s of %s", s.AnnotationID, a.Title))
+
+	duration := now.Unix() - s.StartedAt
+	err = client.PostServiceMetricValues(s.Service, []*mackerel.MetricValue{{Name: metricName, Time: now.Unix(), Value: duration}})
+	if err != nil {
+		return failed, total, err
+	}
+	logger.Log("posted", fmt.Sprintf("%s of %s: %d", metricName, s.Service, duration))
+	s.FinishedAt = now.Unix()
+	return failed, total, nil
+}
+
+func deployFlags(c *cli.Context, command string) (string, string) {
+	service, revision := c.String("service"), c.String("revision")
+	if service == "" || revision == "" {
+		cli.ShowCommandHelp(c, command)
+		os.Exit(1)
+	}
+	return service, revision
+}
+
+func doDeployStart(c *cli.Context) error {
+	service, revision := deployFlags(c, "start")
+	roles := c.StringSlice("role")
+	if c.Bool("maintenance") && len(roles) == 0 {
+		return cli.NewExitError("--maintenance requires --role", 1)
+	}
+	file := deployStateFile(service, revision)
+	if _, err := os.Stat(file); err == nil {
+		return cli.NewExitError(fmt.Sprintf("the deployment of %s to %s is already started. Run 'mkr deploy finish' or remove %s", revision, service, file), 1)
+	}
+	client := mackerelclient.NewFromContext(c)
+	remote, err := loadRemoteDeployState(client, service, revision)
+	logger.DieIf(err)
+	if remote != nil {
+		return cli.NewExitError(fmt.Sprintf("the deployment of %s to %s is already started on another machine. Run 'mkr deploy finish' or remove the service metadata of %s", revision, service, deployMetadataNamespace(revision)), 1)
+	}
+
+	s := &deployState{
+		Service:     service,
+		Revision:    revision,
+		Roles:       roles,
+		Description: c.String("description"),
+		StartedAt:   time.Now().Unix(),
+	}
+	failed, total, err := startDeploy(client, s, c.Bool("maintenance"))
+	if s.AnnotationID != "" {
+		// the state is saved to change the hosts back even if some of them are failed
+		logger.DieIf(saveDeployStates(client, file, s))
+	}
+	if err != nil {
+		return err
+	}
+	return batchError("change to maintenance", failed, total, "host(s)")
+}
+
+func doDeployFinish(c *cli.Context) error {
+	service, revision := deployFlags(c, "finish")
+	file := deployStateFile(service, revision)
+	client := mackerelclient.NewFromContext(c)
+	s, err := loadDeployState(file)
+	if os.IsNotExist(err) {
+		// the deployment may be started on another machine
+		s, err = loadRemoteDeployState(client, service, revision)
+		if err == nil && s == nil {
+			return cli.NewExitError(fmt.Sprintf("the deployment of %s to %s is not started", revision, service), 1)
+		}
+	}
+	logger.DieIf(err)
+
+	failed, total, err := finishDeploy(client, s, c.String("metric-name"), time.Now())
+	if err != nil || failed > 0 {
+		// the state is kept to retry the hosts failed to change back by running finish again
+		logger.DieIf(saveDeployStates(client, file, s))
+		if err != nil {
+			return err
+		}
+		return batchError("change back to working", failed, total, "host(s)")
+	}
+	return removeDeployStates(client, file, s)
+}
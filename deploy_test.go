@@ -0,0 +1,207 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type fakeDeployClient struct {
+	fakeHostFinder
+	annotations []*mackerel.GraphAnnotation
+	statuses    map[string]string
+	metrics     []*mackerel.MetricValue
+	failHost    string
+	metadata    map[string]mackerel.ServiceMetaData
+}
+
+func (f *fakeDeployClient) CreateGraphAnnotation(a *mackerel.GraphAnnotation) (*mackerel.GraphAnnotation, error) {
+	created := *a
+	created.ID = "anno1"
+	f.annotations = append(f.annotations, &created)
+	return &created, nil
+}
+
+func (f *fakeDeployClient) UpdateGraphAnnotation(id string, a *mackerel.GraphAnnotation) (*mackerel.GraphAnnotation, error) {
+	f.annotations = append(f.annotations, a)
+	return a, nil
+}
+
+func (f *fakeDeployClient) UpdateHostStatus(hostID string, status string) error {
+	if hostID == f.failHost {
+		return errors.New("internal server error")
+	}
+	f.statuses[hostID] = status
+	return nil
+}
+
+func (f *fakeDeployClient) PostServiceMetricValues(service string, values []*mackerel.MetricValue) error {
+	f.metrics = append(f.metrics, values...)
+	return nil
+}
+
+func (f *fakeDeployClient) GetServiceMetaData(service, namespace string) (*mackerel.ServiceMetaDataResp, error) {
+	metadata, ok := f.metadata[service+"/"+namespace]
+	if !ok {
+		return nil, &mackerel.APIError{StatusCode: 404, Message: "Metadata not found"}
+	}
+	return &mackerel.ServiceMetaDataResp{ServiceMetaData: metadata}, nil
+}
+
+func (f *fakeDeployClient) PutServiceMetaData(service, namespace string, metadata mackerel.ServiceMetaData) error {
+	// the metadata is stored as JSON by the API
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	f.metadata[service+"/"+namespace] = v
+	return nil
+}
+
+func (f *fakeDeployClient) DeleteServiceMetaData(service, namespace string) error {
+	delete(f.metadata, service+"/"+namespace)
+	return nil
+}
+
+func TestDeploy(t *testing.T) {
+	client := &fakeDeployClient{
+		fakeHostFinder: fakeHostFinder{hosts: []*mackerel.Host{{ID: "host1"}, {ID: "host2"}, {ID: "host3"}}},
+		statuses:       map[string]string{},
+		failHost:       "host3",
+	}
+	revision := "0123456789abcdef0123456789abcdef01234567"
+	s := &deployState{Service: "MyApp", Revision: revision, Roles: []string{"web"}, StartedAt: 1700000000}
+
+	failed, total, err := startDeploy(client, s, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if failed != 1 || total != 3 {
+		t.Errorf("the host failed to change should be reported: %d of %d", failed, total)
+	}
+	expectParam := &mackerel.FindHostsParam{Service: "MyApp", Roles: []string{"web"}, Statuses: []string{"working"}}
+	if !reflect.DeepEqual(client.param, expectParam) {
+		t.Errorf("param should be %+v but: %+v", expectParam, client.param)
+	}
+	if !reflect.DeepEqual(s.MaintenanceHosts, []string{"host1", "host2"}) {
+		t.Errorf("the hosts changed to maintenance should be recorded: %v", s.MaintenanceHosts)
+	}
+	expect := &mackerel.GraphAnnotation{ID: "anno1", Title: "deploy 0123456 (in progress)", Description: "revision: " + revision,
+		From: 1700000000, To: 1700000000, Service: "MyApp", Roles: []string{"web"}}
+	if !reflect.DeepEqual(client.annotations[0], expect) || s.AnnotationID != "anno1" {
+		t.Errorf("annotation should be %+v but: %+v", expect, client.annotations[0])
+	}
+
+	client.failHost = ""
+	failed, total, err = finishDeploy(client, s, "deploy.duration", time.Unix(1700000200, 0))
+	if err != nil || failed != 0 || total != 2 {
+		t.Fatalf("finishDeploy should succeed: %d of %d, %v", failed, total, err)
+	}
+	if expect := map[string]string{"host1": "working", "host2": "working"}; !reflect.DeepEqual(client.statuses, expect) {
+		t.Errorf("the hosts should be changed back to working: %v", client.statuses)
+	}
+	expect = &mackerel.GraphAnnotation{ID: "anno1", Title: "deploy 0123456", Description: "revision: " + revision + "\nduration: 3m20s",
+		From: 1700000000, To: 1700000200, Service: "MyApp", Roles: []string{"web"}}
+	if !reflect.DeepEqual(client.annotations[1], expect) {
+		t.Errorf("annotation should be %+v but: %+v", expect, client.annotations[1])
+	}
+	expectMetrics := []*mackerel.MetricValue{{Name: "deploy.duration", Time: 1700000200, Value: int64(200)}}
+	if !reflect.DeepEqual(client.metrics, expectMetrics) {
+		t.Errorf("the duration should be posted: %+v", client.metrics[0])
+	}
+}
+
+func TestFinishDeploy_retry(t *testing.T) {
+	client := &fakeDeployClient{statuses: map[string]string{}, failHost: "host2"}
+	s := &deployState{Service: "MyApp", Revision: "v1", StartedAt: 1700000000, AnnotationID: "anno1", MaintenanceHosts: []string{"host1", "host2"}}
+
+	failed, total, err := finishDeploy(client, s, "deploy.duration", time.Unix(1700000200, 0))
+	if err != nil || failed != 1 || total != 2 {
+		t.Fatalf("the host failed to change should be reported: %d of %d, %v", failed, total, err)
+	}
+	if !reflect.DeepEqual(s.MaintenanceHosts, []string{"host2"}) || s.FinishedAt != 1700000200 {
+		t.Errorf("only the failed host should be left in the state: %+v", s)
+	}
+
+	client.failHost = ""
+	failed, total, err = finishDeploy(client, s, "deploy.duration", time.Unix(1700000300, 0))
+	if err != nil || failed != 0 || total != 1 {
+		t.Fatalf("the failed host should be retried: %d of %d, %v", failed, total, err)
+	}
+	if len(s.MaintenanceHosts) != 0 || client.statuses["host2"] != "working" {
+		t.Errorf("the failed host should be changed back to working: %+v", s)
+	}
+	if len(client.annotations) != 1 || len(client.metrics) != 1 {
+		t.Errorf("the annotation and the duration should not be posted again: %v, %v", client.annotations, client.metrics)
+	}
+}
+
+func TestRemoteDeployState(t *testing.T) {
+	client := &fakeDeployClient{metadata: map[string]mackerel.ServiceMetaData{}}
+	s := &deployState{Service: "MyApp", Revision: "v1.2.3", StartedAt: 1700000000, AnnotationID: "anno1", MaintenanceHosts: []string{"host1"}}
+
+	if loaded, err := loadRemoteDeployState(client, "MyApp", "v1.2.3"); err != nil || loaded != nil {
+		t.Fatalf("nothing should be loaded before saving: %+v, %v", loaded, err)
+	}
+	dir, err := ioutil.TempDir("", "mkr-deploy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "MyApp@v1.2.3.json")
+	if err := saveDeployStates(client, file, s); err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := client.metadata["MyApp/mkr-deploy-v1_2_3"]; !ok {
+		t.Errorf("the state should be saved in the service metadata: %v", client.metadata)
+	}
+	loaded, err := loadRemoteDeployState(client, "MyApp", "v1.2.3")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("state should be %+v but: %+v", s, loaded)
+	}
+
+	if err := removeDeployStates(client, file, s); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) || len(client.metadata) != 0 {
+		t.Errorf("the state should be removed: %v", client.metadata)
+	}
+}
+
+func TestSaveDeployState(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-deploy-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "deploys", "MyApp@v1.json")
+
+	s := &deployState{Service: "MyApp", Revision: "v1", StartedAt: 1700000000, AnnotationID: "anno1", MaintenanceHosts: []string{"host1"}}
+	if err := saveDeployState(file, s); err != nil {
+		t.Fatal(err)
+	}
+	loaded, err := loadDeployState(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(loaded, s) {
+		t.Errorf("state should be %+v but: %+v", s, loaded)
+	}
+	if got := shortRevision("v1.2.3"); got != "v1.2.3" {
+		t.Errorf("the revision not of a commit hash should not be shortened: %s", got)
+	}
+}
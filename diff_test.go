@@ -0,0 +1,49 @@
+package main
+
+import "testing"
+
+func TestUnifiedDiff(t *testing.T) {
+	testCases := []struct {
+		name string
+		a, b string
+		want string
+	}{
+		{
+			name: "same",
+			a:    "a\nb\n",
+			b:    "a\nb\n",
+			want: "",
+		},
+		{
+			name: "replaced",
+			a:    "1\n2\n3\n4\n5\n6\n7\n",
+			b:    "1\n2\n3\nx\n5\n6\n7\n",
+			want: "--- a\n+++ b\n@@ -1,7 +1,7 @@\n 1\n 2\n 3\n-4\n+x\n 5\n 6\n 7\n",
+		},
+		{
+			name: "separate hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n",
+			b:    "0\n1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n12\n",
+			want: "--- a\n+++ b\n@@ -1,3 +1,4 @@\n+0\n 1\n 2\n 3\n@@ -8,5 +9,4 @@\n 8\n 9\n 10\n-11\n 12\n",
+		},
+		{
+			name: "joined hunks",
+			a:    "1\n2\n3\n4\n5\n6\n7\n8\n",
+			b:    "x\n2\n3\n4\n5\n6\n7\ny\n",
+			want: "--- a\n+++ b\n@@ -1,8 +1,8 @@\n-1\n+x\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+y\n",
+		},
+		{
+			name: "empty",
+			a:    "",
+			b:    "a\n",
+			want: "--- a\n+++ b\n@@ -0,0 +1 @@\n+a\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := unifiedDiff("a", "b", tc.a, tc.b); got != tc.want {
+				t.Errorf("unifiedDiff should be\n%q\nbut\n%q", tc.want, got)
+			}
+		})
+	}
+}
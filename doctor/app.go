@@ -0,0 +1,210 @@
+package doctor
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/mackerelio/mackerel-agent/config"
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+// The statuses of the findings
+const (
+	statusOK      = "ok"
+	statusWarning = "warning"
+	statusError   = "error"
+	statusSkipped = "skipped"
+)
+
+// maxClockSkew is the clock skew to warn, since the metric values posted by mkr throw use the local time
+const maxClockSkew = 30 * time.Second
+
+type requester interface {
+	Request(req *http.Request) (*http.Response, error)
+}
+
+type doctorApp struct {
+	apikey           *mackerelclient.Apikey
+	apikeyErr        error
+	apibase          string
+	client           requester
+	detectPermission func() (string, error)
+	proxy            func(*http.Request) (*url.URL, error)
+	conffile         string
+	pluginDir        string
+	now              func() time.Time
+	outStream        io.Writer
+}
+
+type finding struct {
+	Check  string `json:"check"`
+	Status string `json:"status"`
+	Detail string `json:"detail,omitempty"`
+	Action string `json:"action,omitempty"`
+}
+
+// run checks the environment and prints the findings. It returns the number of the errors.
+func (app *doctorApp) run(output *format.Output) (int, error) {
+	var findings []*finding
+	findings = append(findings, app.checkAPI()...)
+	findings = append(findings, app.checkAgentConf(), app.checkPluginDir())
+
+	var errors int
+	for _, f := range findings {
+		if f.Status == statusError {
+			errors++
+		}
+	}
+	return errors, output.Print(app.outStream, findings, func() *format.Table {
+		t := format.NewTable("CHECK", "STATUS", "DETAIL", "ACTION")
+		for _, f := range findings {
+			t.Append(f.Check, f.Status, f.Detail, f.Action)
+		}
+		return t
+	})
+}
+
+// checkAPI checks the apikey, the connectivity to the apibase, the permission of the apikey and the clock skew.
+func (app *doctorApp) checkAPI() []*finding {
+	apikey := &finding{Check: "apikey"}
+	connectivity := &finding{Check: "connectivity"}
+	permission := &finding{Check: "permission"}
+	clock := &finding{Check: "clock"}
+	findings := []*finding{apikey, connectivity, permission, clock}
+	skip := func(fs ...*finding) []*finding {
+		for _, f := range fs {
+			f.Status, f.Detail = statusSkipped, "skipped due to the failure above"
+		}
+		return findings
+	}
+
+	if app.apikeyErr != nil {
+		apikey.Status, apikey.Detail = statusError, app.apikeyErr.Error()
+		apikey.Action = `export MACKEREL_APIKEY, set apikey in mackerel-agent.conf or configure a profile with "mkr configure"`
+		return skip(connectivity, permission, clock)
+	}
+	apikey.Status, apikey.Detail = statusOK, "configured in "+app.apikey.Source
+
+	u, err := url.Parse(app.apibase)
+	if err != nil {
+		connectivity.Status, connectivity.Detail = statusError, fmt.Sprintf("invalid apibase %s: %s", app.apibase, err)
+		connectivity.Action = "fix --apibase, apibase in mackerel-agent.conf or the apibase of the profile"
+		return skip(permission, clock)
+	}
+	u.Path = "/api/v0/org"
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		connectivity.Status, connectivity.Detail = statusError, err.Error()
+		return skip(permission, clock)
+	}
+	via := "directly"
+	if proxy, err := app.proxy(req); err == nil && proxy != nil {
+		// hide the credentials of the proxy
+		p := *proxy
+		p.User = nil
+		via = "via the proxy " + p.String()
+	}
+
+	sent := app.now()
+	resp, err := app.client.Request(req)
+	if err != nil {
+		if apiErr, ok := err.(*mackerel.APIError); ok && apiErr.StatusCode == http.StatusUnauthorized {
+			connectivity.Status, connectivity.Detail = statusOK, fmt.Sprintf("connected to %s %s", app.apibase, via)
+			apikey.Status, apikey.Detail = statusError, fmt.Sprintf("the apikey configured in %s is rejected: %s", app.apikey.Source, apiErr.Message)
+			apikey.Action = "check the apikey on the API keys page of the organization settings"
+			return skip(permission, clock)
+		}
+		connectivity.Status, connectivity.Detail = statusError, fmt.Sprintf("failed to request %s %s: %s", app.apibase, via, err)
+		connectivity.Action = "check the network, the firewall and HTTPS_PROXY or NO_PROXY environment variables"
+		return skip(permission, clock)
+	}
+	resp.Body.Close()
+	connectivity.Status, connectivity.Detail = statusOK, fmt.Sprintf("connected to %s %s", app.apibase, via)
+
+	if p, err := app.detectPermission(); err != nil {
+		permission.Status, permission.Detail = statusWarning, fmt.Sprintf("failed to detect the permission: %s", err)
+	} else if p == mackerelclient.PermissionRead {
+		permission.Status, permission.Detail = statusWarning, "the apikey is read-only"
+		permission.Action = "use an apikey with the write permission to create, update or delete the resources"
+	} else {
+		permission.Status, permission.Detail = statusOK, p
+	}
+
+	serverTime, err := http.ParseTime(resp.Header.Get("Date"))
+	if err != nil {
+		clock.Status, clock.Detail = statusSkipped, "the server time is unknown"
+		return findings
+	}
+	// the Date header is truncated to seconds
+	skew := sent.Sub(serverTime)
+	if skew < 0 {
+		skew = -skew
+	}
+	if skew > maxClockSkew {
+		clock.Status, clock.Detail = statusWarning, fmt.Sprintf("the local clock is off by %s from the server", skew.Round(time.Second))
+		clock.Action = "synchronize the clock with NTP, since the metrics are posted with the local time"
+	} else {
+		clock.Status, clock.Detail = statusOK, fmt.Sprintf("the local clock is off by %s from the server", skew.Round(time.Second))
+	}
+	return findings
+}
+
+// checkAgentConf checks whether the config file of mackerel-agent is readable if it exists.
+func (app *doctorApp) checkAgentConf() *finding {
+	f := &finding{Check: "agent conf"}
+	if _, err := os.Stat(app.conffile); os.IsNotExist(err) {
+		f.Status, f.Detail = statusSkipped, fmt.Sprintf("%s does not exist", app.conffile)
+		return f
+	}
+	if _, err := ioutil.ReadFile(app.conffile); err != nil {
+		f.Status, f.Detail = statusWarning, err.Error()
+		if os.IsPermission(err) {
+			f.Action = "run mkr with sudo to use the apikey and the host ID of mackerel-agent, or export MACKEREL_APIKEY"
+		}
+		return f
+	}
+	if _, err := config.LoadConfig(app.conffile); err != nil {
+		f.Status, f.Detail = statusError, fmt.Sprintf("failed to load %s: %s", app.conffile, err)
+		f.Action = "fix the syntax of the config file"
+		return f
+	}
+	f.Status, f.Detail = statusOK, fmt.Sprintf("%s is readable", app.conffile)
+	return f
+}
+
+// checkPluginDir checks whether the plugins can be installed in the plugin directory.
+func (app *doctorApp) checkPluginDir() *finding {
+	f := &finding{Check: "plugin dir"}
+	info, err := os.Stat(app.pluginDir)
+	if os.IsNotExist(err) {
+		f.Status, f.Detail = statusSkipped, fmt.Sprintf("%s does not exist", app.pluginDir)
+		return f
+	}
+	if err != nil {
+		f.Status, f.Detail = statusWarning, err.Error()
+		return f
+	}
+	if !info.IsDir() {
+		f.Status, f.Detail = statusError, fmt.Sprintf("%s is not a directory", app.pluginDir)
+		f.Action = "remove the file or install the plugins with --prefix"
+		return f
+	}
+	tmp, err := ioutil.TempFile(app.pluginDir, ".mkr-doctor-")
+	if err != nil {
+		f.Status, f.Detail = statusWarning, fmt.Sprintf("%s is not writable", app.pluginDir)
+		f.Action = `run "mkr plugin install" with sudo or install the plugins with --prefix`
+		return f
+	}
+	tmp.Close()
+	os.Remove(tmp.Name())
+	f.Status, f.Detail = statusOK, fmt.Sprintf("%s is writable", app.pluginDir)
+	return f
+}
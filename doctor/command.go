@@ -0,0 +1,70 @@
+package doctor
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/mackerelio/mkr/plugin"
+)
+
+// Command is the definition of doctor subcommand
+var Command = cli.Command{
+	Name:      "doctor",
+	Usage:     "Diagnose the environment of mkr",
+	ArgsUsage: "[--plugin-dir <dir>] [--output | -o <format>]",
+	Description: `
+    Check the environment where mkr runs and show the findings with the actions to fix them.
+    It checks the apikey, the connectivity to the apibase (via the proxy of HTTPS_PROXY if configured),
+    the permission of the apikey (read or read/write), the clock skew from the server,
+    whether mackerel-agent.conf is readable, and whether the plugins can be installed in the plugin directory.
+    The permission is detected by a probe request that is always rejected and never changes the organization.
+    Exits with status 1 if any check fails.
+`,
+	Action: doDoctor,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "plugin-dir", Value: plugin.DefaultInstallLocation(), Usage: "The directory to install the plugins"},
+		cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+	},
+}
+
+func doDoctor(c *cli.Context) error {
+	output, err := format.OutputFromContext(c, "table")
+	if err != nil {
+		return err
+	}
+	confFile, profile := c.GlobalString("conf"), c.GlobalString("profile")
+	apibase := mackerelclient.ResolveApibase(confFile, c.GlobalString("apibase"), profile)
+	app := &doctorApp{
+		apibase:   apibase,
+		proxy:     http.ProxyFromEnvironment,
+		conffile:  confFile,
+		pluginDir: c.String("plugin-dir"),
+		now:       time.Now,
+		outStream: os.Stdout,
+	}
+	if app.apikey, app.apikeyErr = mackerelclient.ResolveApikey(confFile, profile); app.apikeyErr == nil {
+		client, err := mackerelclient.NewClient(app.apikey.Value, apibase)
+		if err != nil {
+			return err
+		}
+		app.client = client
+		app.detectPermission = func() (string, error) {
+			return mackerelclient.DetectPermission(client)
+		}
+	}
+
+	errors, err := app.run(output)
+	if err != nil {
+		return err
+	}
+	if errors > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d problems are found", errors), 1)
+	}
+	return nil
+}
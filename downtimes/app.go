@@ -0,0 +1,147 @@
+package downtimes
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+type appLogger interface {
+	Log(string, string)
+}
+
+type downtimesApp struct {
+	client    mackerelclient.Client
+	logger    appLogger
+	outStream io.Writer
+}
+
+func (app *downtimesApp) list(output *format.Output) error {
+	downtimes, err := app.client.FindDowntimes()
+	if err != nil {
+		return err
+	}
+	return output.Print(app.outStream, downtimes, func() *format.Table {
+		return downtimesTable(downtimes)
+	})
+}
+
+func downtimesTable(downtimes []*mackerel.Downtime) *format.Table {
+	t := format.NewTable("ID", "NAME", "START", "DURATION", "RECURRENCE", "SCOPE")
+	for _, d := range downtimes {
+		t.Append(d.ID, d.Name,
+			format.ISO8601Extended(time.Unix(d.Start, 0)), formatMinutes(d.Duration),
+			describeRecurrence(d.Recurrence), strings.Join(describeScopes(d), ","))
+	}
+	return t
+}
+
+// formatMinutes formats the duration in minutes like 1d2h30m.
+func formatMinutes(minutes int64) string {
+	if minutes == 0 {
+		return "0m"
+	}
+	var s string
+	if d := minutes / (24 * 60); d > 0 {
+		s += fmt.Sprintf("%dd", d)
+	}
+	if h := minutes / 60 % 24; h > 0 {
+		s += fmt.Sprintf("%dh", h)
+	}
+	if m := minutes % 60; m > 0 {
+		s += fmt.Sprintf("%dm", m)
+	}
+	return s
+}
+
+func describeRecurrence(r *mackerel.DowntimeRecurrence) string {
+	if r == nil {
+		return "-"
+	}
+	s := r.Type.String()
+	if r.Interval > 1 {
+		s += fmt.Sprintf(" every %d", r.Interval)
+	}
+	if len(r.Weekdays) > 0 {
+		weekdays := make([]string, len(r.Weekdays))
+		for i, w := range r.Weekdays {
+			weekdays[i] = w.String()[:3]
+		}
+		s += " on " + strings.Join(weekdays, ",")
+	}
+	if r.Until > 0 {
+		s += " until " + format.ISO8601Extended(time.Unix(r.Until, 0))
+	}
+	return s
+}
+
+// describeScopes returns the scopes of the downtime, where the excluded ones are prefixed with "!".
+func describeScopes(d *mackerel.Downtime) []string {
+	var scopes []string
+	add := func(prefix string, xs []string) {
+		for _, x := range xs {
+			scopes = append(scopes, prefix+x)
+		}
+	}
+	add("", d.ServiceScopes)
+	add("", d.RoleScopes)
+	add("monitor:", d.MonitorScopes)
+	add("!", d.ServiceExcludeScopes)
+	add("!", d.RoleExcludeScopes)
+	add("!monitor:", d.MonitorExcludeScopes)
+	return scopes
+}
+
+func (app *downtimesApp) create(d *mackerel.Downtime) error {
+	downtime, err := app.client.CreateDowntime(d)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("created", fmt.Sprintf("%s (%s)", downtime.Name, downtime.ID))
+	return nil
+}
+
+func (app *downtimesApp) findDowntime(id string) (*mackerel.Downtime, error) {
+	downtimes, err := app.client.FindDowntimes()
+	if err != nil {
+		return nil, err
+	}
+	for _, d := range downtimes {
+		if d.ID == id {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("downtime not found: %s", id)
+}
+
+// update modifies the downtime of the id by the function and updates it.
+func (app *downtimesApp) update(id string, modify func(*mackerel.Downtime) error) error {
+	d, err := app.findDowntime(id)
+	if err != nil {
+		return err
+	}
+	if err := modify(d); err != nil {
+		return err
+	}
+	downtime, err := app.client.UpdateDowntime(id, d)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("updated", fmt.Sprintf("%s (%s)", downtime.Name, downtime.ID))
+	return nil
+}
+
+func (app *downtimesApp) delete(id string) error {
+	downtime, err := app.client.DeleteDowntime(id)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("deleted", fmt.Sprintf("%s (%s)", downtime.Name, downtime.ID))
+	return nil
+}
@@ -0,0 +1,97 @@
+package downtimes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+type testLogger struct {
+	w io.Writer
+}
+
+func (l *testLogger) Log(prefix, message string) {
+	fmt.Fprintln(l.w, prefix, message)
+}
+
+func TestDowntimesApp_List(t *testing.T) {
+	time.Local = time.UTC
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindDowntimes(func() ([]*mackerel.Downtime, error) {
+			return []*mackerel.Downtime{
+				{
+					ID:       "3yAYEDLXKL5",
+					Name:     "weekly maintenance",
+					Start:    1609462800,
+					Duration: 150,
+					Recurrence: &mackerel.DowntimeRecurrence{
+						Type:     mackerel.DowntimeRecurrenceTypeWeekly,
+						Interval: 2,
+						Weekdays: []mackerel.DowntimeWeekday{mackerel.DowntimeWeekday(time.Monday), mackerel.DowntimeWeekday(time.Thursday)},
+					},
+					ServiceScopes:        []string{"Blog"},
+					RoleExcludeScopes:    []string{"Blog: db"},
+					MonitorExcludeScopes: []string{"2cSZzK3XfmG"},
+				},
+				{
+					ID:            "3yAYEDLXKL6",
+					Name:          "migration",
+					Start:         1609549200,
+					Duration:      1440,
+					MonitorScopes: []string{"2cSZzK3XfmH"},
+				},
+			}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &downtimesApp{client: client, logger: &testLogger{out}, outStream: out}
+	assert.NoError(t, app.list(&format.Output{Format: format.OutputTable}))
+	assert.Equal(t, `ID           NAME                START                      DURATION  RECURRENCE                 SCOPE
+3yAYEDLXKL5  weekly maintenance  2021-01-01T01:00:00+00:00  2h30m     weekly every 2 on Mon,Thu  Blog,!Blog: db,!monitor:2cSZzK3XfmG
+3yAYEDLXKL6  migration           2021-01-02T01:00:00+00:00  1d        -                          monitor:2cSZzK3XfmH
+`, out.String())
+}
+
+func TestDowntimesApp_CreateUpdateDelete(t *testing.T) {
+	current := &mackerel.Downtime{ID: "3yAYEDLXKL5", Name: "maintenance", Start: 1609462800, Duration: 60}
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindDowntimes(func() ([]*mackerel.Downtime, error) {
+			return []*mackerel.Downtime{current}, nil
+		}),
+		mackerelclient.MockCreateDowntime(func(d *mackerel.Downtime) (*mackerel.Downtime, error) {
+			assert.Equal(t, "migration", d.Name)
+			d.ID = "3yAYEDLXKL6"
+			return d, nil
+		}),
+		mackerelclient.MockUpdateDowntime(func(id string, d *mackerel.Downtime) (*mackerel.Downtime, error) {
+			assert.Equal(t, "3yAYEDLXKL5", id)
+			assert.Equal(t, &mackerel.Downtime{ID: "3yAYEDLXKL5", Name: "maintenance", Start: 1609462800, Duration: 90}, d)
+			return d, nil
+		}),
+		mackerelclient.MockDeleteDowntime(func(id string) (*mackerel.Downtime, error) {
+			return &mackerel.Downtime{ID: id, Name: "maintenance"}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &downtimesApp{client: client, logger: &testLogger{out}, outStream: out}
+	assert.NoError(t, app.create(&mackerel.Downtime{Name: "migration", Start: 1609549200, Duration: 30}))
+	assert.NoError(t, app.update("3yAYEDLXKL5", func(d *mackerel.Downtime) error {
+		d.Duration = 90
+		return nil
+	}))
+	assert.EqualError(t, app.update("unknown", func(d *mackerel.Downtime) error { return nil }), "downtime not found: unknown")
+	assert.NoError(t, app.delete("3yAYEDLXKL5"))
+	assert.Equal(t, `created migration (3yAYEDLXKL6)
+updated maintenance (3yAYEDLXKL5)
+deleted maintenance (3yAYEDLXKL5)
+`, out.String())
+}
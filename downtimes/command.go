@@ -0,0 +1,272 @@
+package downtimes
+
+import (
+	"os"
+	"time"
+
+	"github.com/Songmu/prompter"
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+var downtimeFlags = []cli.Flag{
+	cli.StringFlag{Name: "name", Value: "", Usage: "The name of the downtime"},
+	cli.StringFlag{Name: "memo", Value: "", Usage: "The memo of the downtime"},
+	cli.StringFlag{Name: "start", Value: "now", Usage: "The start time of the downtime (RFC3339, epoch seconds or now)"},
+	cli.StringFlag{Name: "duration", Value: "", Usage: "The duration of the downtime such as 90 (minutes), 30m, 2h or 1d"},
+	cli.StringFlag{Name: "recurrence", Value: "", Usage: "The recurrence of the downtime (hourly, daily, weekly, monthly or yearly)"},
+	cli.IntFlag{Name: "interval", Value: 1, Usage: "The interval of the recurrence"},
+	cli.StringSliceFlag{
+		Name:  "weekday",
+		Value: &cli.StringSlice{},
+		Usage: "The weekdays of the weekly recurrence such as Monday or mon. Multiple choices are allowed",
+	},
+	cli.StringFlag{Name: "until", Value: "", Usage: "The end time of the recurrence (RFC3339 or epoch seconds)"},
+	cli.StringSliceFlag{
+		Name:  "scope",
+		Value: &cli.StringSlice{},
+		Usage: "The scope of the downtime: <service>, <service>:<role> or monitor:<monitorId>. Multiple choices are allowed",
+	},
+	cli.StringSliceFlag{
+		Name:  "exclude-scope",
+		Value: &cli.StringSlice{},
+		Usage: "The scope excluded from the downtime in the same form as --scope. Multiple choices are allowed",
+	},
+}
+
+// Command is the definition of downtimes subcommand
+var Command = cli.Command{
+	Name:      "downtimes",
+	Usage:     "List downtimes",
+	ArgsUsage: "[--output | -o <format>]",
+	Description: `
+    List the scheduled downtimes. With a subcommand, manipulate the downtimes.
+    Requests APIs under "/api/v0/downtimes". See https://mackerel.io/api-docs/entry/downtimes .
+`,
+	Action: doDowntimes,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+	},
+	Subcommands: []cli.Command{
+		{
+			Name:      "create",
+			Usage:     "Create a downtime",
+			ArgsUsage: "--name <name> [--start <start>] --duration <duration> [--recurrence <type> [--interval <n>] [--weekday <weekday>] [--until <until>]] [--scope <scope>] [--exclude-scope <scope>] [--memo <memo>]",
+			Description: `
+    Create a new downtime. The scopes are <service>, <service>:<role> or monitor:<monitorId>.
+    Requests "POST /api/v0/downtimes". See https://mackerel.io/api-docs/entry/downtimes#create.
+`,
+			Action: doCreateDowntime,
+			Flags:  downtimeFlags,
+		},
+		{
+			Name:      "update",
+			Usage:     "Update a downtime",
+			ArgsUsage: "--id <id> [--name <name>] [--start <start>] [--duration <duration>] [--recurrence <type>|none ...] [--scope <scope>] [--exclude-scope <scope>] [--memo <memo>]",
+			Description: `
+    Update the downtime. Only the specified fields are changed, and the scopes are replaced if specified.
+    The recurrence is removed with "--recurrence none".
+    Requests "PUT /api/v0/downtimes/<downtimeId>". See https://mackerel.io/api-docs/entry/downtimes#update.
+`,
+			Action: doUpdateDowntime,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: "id", Value: "", Usage: "The ID of the downtime"},
+			}, downtimeFlags...),
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete a downtime",
+			ArgsUsage: "[--force] <downtimeId>",
+			Description: `
+    Delete the downtime.
+    Requests "DELETE /api/v0/downtimes/<downtimeId>". See https://mackerel.io/api-docs/entry/downtimes#delete.
+`,
+			Action: doDeleteDowntime,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "force", Usage: "Delete the downtime without confirmation"},
+			},
+		},
+		{
+			Name:      "pull",
+			Usage:     "Pull downtimes",
+			ArgsUsage: "[--file-path | -F <file>]",
+			Description: `
+    Save the downtimes to the JSON file, which can be pushed by "mkr downtimes push".
+`,
+			Action: doPullDowntimes,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "downtimes.json", Usage: "The JSON file to save the downtimes"},
+			},
+		},
+		{
+			Name:      "push",
+			Usage:     "Push downtimes",
+			ArgsUsage: "[--file-path | -F <file>] [--dry-run | -d] [--prune]",
+			Description: `
+    Create or update the downtimes in the JSON file. The downtimes are matched by "id", or by "name" if they have no id.
+    With --prune, the downtimes which do not exist in the file are deleted.
+`,
+			Action: doPushDowntimes,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "downtimes.json", Usage: "The JSON file of the downtimes"},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show the changes, but not apply them"},
+				cli.BoolFlag{Name: "prune", Usage: "Delete the downtimes which do not exist in the file"},
+			},
+		},
+	},
+}
+
+func newDowntimesApp(c *cli.Context) (*downtimesApp, error) {
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
+	if err != nil {
+		return nil, err
+	}
+	return &downtimesApp{
+		client:    client,
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}, nil
+}
+
+func doDowntimes(c *cli.Context) error {
+	app, err := newDowntimesApp(c)
+	if err != nil {
+		return err
+	}
+	output, err := format.OutputFromContext(c, "table")
+	if err != nil {
+		return err
+	}
+	return app.list(output)
+}
+
+// applyFlags sets the fields of the downtime by the flags. Only the specified flags are applied if update is true.
+func applyFlags(c *cli.Context, d *mackerel.Downtime, update bool, now time.Time) error {
+	isSet := func(name string) bool {
+		return !update || c.IsSet(name)
+	}
+	var err error
+	if isSet("name") {
+		d.Name = c.String("name")
+	}
+	if isSet("memo") {
+		d.Memo = c.String("memo")
+	}
+	if isSet("start") {
+		if d.Start, err = parseTime(c.String("start"), now); err != nil {
+			return err
+		}
+	}
+	if isSet("duration") {
+		if d.Duration, err = parseMinutes(c.String("duration")); err != nil {
+			return err
+		}
+	}
+	if c.String("recurrence") == "none" {
+		d.Recurrence = nil
+	} else if c.String("recurrence") != "" {
+		var until int64
+		if c.String("until") != "" {
+			if until, err = parseTime(c.String("until"), now); err != nil {
+				return err
+			}
+		}
+		if d.Recurrence, err = parseRecurrence(c.String("recurrence"), int64(c.Int("interval")), c.StringSlice("weekday"), until); err != nil {
+			return err
+		}
+	}
+	if isSet("scope") {
+		s, err := parseScopes(c.StringSlice("scope"))
+		if err != nil {
+			return err
+		}
+		d.ServiceScopes, d.RoleScopes, d.MonitorScopes = s.services, s.roles, s.monitors
+	}
+	if isSet("exclude-scope") {
+		s, err := parseScopes(c.StringSlice("exclude-scope"))
+		if err != nil {
+			return err
+		}
+		d.ServiceExcludeScopes, d.RoleExcludeScopes, d.MonitorExcludeScopes = s.services, s.roles, s.monitors
+	}
+	return nil
+}
+
+func doCreateDowntime(c *cli.Context) error {
+	if c.String("name") == "" || c.String("duration") == "" {
+		cli.ShowCommandHelp(c, "create")
+		os.Exit(1)
+	}
+	d := &mackerel.Downtime{}
+	if err := applyFlags(c, d, false, time.Now()); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	app, err := newDowntimesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.create(d)
+}
+
+func doUpdateDowntime(c *cli.Context) error {
+	id := c.String("id")
+	if id == "" {
+		cli.ShowCommandHelp(c, "update")
+		os.Exit(1)
+	}
+	app, err := newDowntimesApp(c)
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	return app.update(id, func(d *mackerel.Downtime) error {
+		return applyFlags(c, d, true, now)
+	})
+}
+
+func doDeleteDowntime(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelp(c, "delete")
+		os.Exit(1)
+	}
+	id := c.Args().Get(0)
+	if !c.Bool("force") && !prompter.YN("Delete the downtime "+id+".\nAre you sure?", false) {
+		logger.Log("", "deletion is canceled.")
+		return nil
+	}
+	app, err := newDowntimesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.delete(id)
+}
+
+func doPullDowntimes(c *cli.Context) error {
+	app, err := newDowntimesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.pull(c.String("file-path"))
+}
+
+func doPushDowntimes(c *cli.Context) error {
+	return Push(c, c.String("file-path"), c.Bool("dry-run"), c.Bool("prune"))
+}
+
+// Push creates, updates and deletes the downtimes in the JSON file as "mkr downtimes push" does.
+// It is also used by "mkr apply".
+func Push(c *cli.Context, filePath string, dryRun, prune bool) error {
+	app, err := newDowntimesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.push(pushDowntimesParam{
+		filePath: filePath,
+		dryRun:   dryRun,
+		prune:    prune,
+	})
+}
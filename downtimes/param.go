@@ -0,0 +1,103 @@
+package downtimes
+
+import (
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// parseTime parses the time in RFC3339, epoch seconds or "now".
+func parseTime(s string, now time.Time) (int64, error) {
+	if s == "now" {
+		return now.Unix(), nil
+	}
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t.Unix(), nil
+	}
+	if epoch, err := strconv.ParseInt(s, 10, 64); err == nil {
+		return epoch, nil
+	}
+	return 0, fmt.Errorf("invalid time (should be RFC3339, epoch seconds or now): %s", s)
+}
+
+// parseMinutes parses the duration such as 90, 30m, 2h or 1d into minutes.
+// The number without unit is regarded as minutes.
+func parseMinutes(s string) (int64, error) {
+	if m, err := strconv.ParseInt(s, 10, 64); err == nil && m > 0 {
+		return m, nil
+	}
+	var d time.Duration
+	var err error
+	if strings.HasSuffix(s, "d") {
+		var days int64
+		days, err = strconv.ParseInt(strings.TrimSuffix(s, "d"), 10, 64)
+		d = time.Duration(days) * 24 * time.Hour
+	} else {
+		d, err = time.ParseDuration(s)
+	}
+	if err != nil || d < time.Minute || d%time.Minute != 0 {
+		return 0, fmt.Errorf("invalid duration (should be minutes such as 90, 30m, 2h or 1d): %s", s)
+	}
+	return int64(d / time.Minute), nil
+}
+
+// parseRecurrence parses the recurrence type such as "weekly" with the options.
+// The weekdays are case insensitive and can be abbreviated like "mon".
+func parseRecurrence(typ string, interval int64, weekdays []string, until int64) (*mackerel.DowntimeRecurrence, error) {
+	r := &mackerel.DowntimeRecurrence{Interval: interval, Until: until}
+	if r.Interval == 0 {
+		r.Interval = 1
+	}
+	if err := json.Unmarshal([]byte(strconv.Quote(strings.ToLower(typ))), &r.Type); err != nil {
+		return nil, err
+	}
+	if len(weekdays) > 0 && r.Type != mackerel.DowntimeRecurrenceTypeWeekly {
+		return nil, fmt.Errorf("weekdays can be specified only for the weekly recurrence")
+	}
+	for _, w := range weekdays {
+		weekday, err := parseWeekday(w)
+		if err != nil {
+			return nil, err
+		}
+		r.Weekdays = append(r.Weekdays, weekday)
+	}
+	return r, nil
+}
+
+func parseWeekday(s string) (mackerel.DowntimeWeekday, error) {
+	for w := time.Sunday; w <= time.Saturday; w++ {
+		name := w.String()
+		if strings.EqualFold(s, name) || strings.EqualFold(s, name[:3]) {
+			return mackerel.DowntimeWeekday(w), nil
+		}
+	}
+	return 0, fmt.Errorf("unknown weekday: %s", s)
+}
+
+// scopes holds the scopes of a downtime in the form of "<service>", "<service>:<role>" or "monitor:<monitorId>".
+type scopes struct {
+	services, roles, monitors []string
+}
+
+func parseScopes(values []string) (*scopes, error) {
+	s := &scopes{}
+	for _, v := range values {
+		kv := strings.SplitN(v, ":", 2)
+		switch {
+		case len(kv) == 1 && kv[0] != "":
+			s.services = append(s.services, kv[0])
+		case len(kv) == 2 && kv[0] == "monitor" && kv[1] != "":
+			s.monitors = append(s.monitors, kv[1])
+		case len(kv) == 2 && strings.TrimSpace(kv[0]) != "" && strings.TrimSpace(kv[1]) != "":
+			// the API represents the role scopes like "service: role"
+			s.roles = append(s.roles, strings.TrimSpace(kv[0])+": "+strings.TrimSpace(kv[1]))
+		default:
+			return nil, fmt.Errorf("invalid scope (should be <service>, <service>:<role> or monitor:<monitorId>): %s", v)
+		}
+	}
+	return s, nil
+}
@@ -0,0 +1,90 @@
+package downtimes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestParseTime(t *testing.T) {
+	now := time.Unix(1609462800, 0)
+	for _, tc := range []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{in: "now", want: 1609462800},
+		{in: "2021-01-02T10:00:00+09:00", want: 1609549200},
+		{in: "1609549200", want: 1609549200},
+		{in: "tomorrow", err: true},
+	} {
+		got, err := parseTime(tc.in, now)
+		if tc.err {
+			assert.Error(t, err, tc.in)
+			continue
+		}
+		assert.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestParseMinutes(t *testing.T) {
+	for _, tc := range []struct {
+		in   string
+		want int64
+		err  bool
+	}{
+		{in: "90", want: 90},
+		{in: "30m", want: 30},
+		{in: "1h30m", want: 90},
+		{in: "2d", want: 2880},
+		{in: "0", err: true},
+		{in: "30s", err: true},
+		{in: "-1h", err: true},
+		{in: "an hour", err: true},
+	} {
+		got, err := parseMinutes(tc.in)
+		if tc.err {
+			assert.Error(t, err, tc.in)
+			continue
+		}
+		assert.NoError(t, err, tc.in)
+		assert.Equal(t, tc.want, got, tc.in)
+	}
+}
+
+func TestParseRecurrence(t *testing.T) {
+	r, err := parseRecurrence("Weekly", 0, []string{"mon", "Friday"}, 1612141200)
+	assert.NoError(t, err)
+	assert.Equal(t, &mackerel.DowntimeRecurrence{
+		Type:     mackerel.DowntimeRecurrenceTypeWeekly,
+		Interval: 1,
+		Weekdays: []mackerel.DowntimeWeekday{mackerel.DowntimeWeekday(time.Monday), mackerel.DowntimeWeekday(time.Friday)},
+		Until:    1612141200,
+	}, r)
+
+	_, err = parseRecurrence("daily", 1, []string{"mon"}, 0)
+	assert.EqualError(t, err, "weekdays can be specified only for the weekly recurrence")
+	_, err = parseRecurrence("weekly", 1, []string{"someday"}, 0)
+	assert.EqualError(t, err, "unknown weekday: someday")
+	_, err = parseRecurrence("biweekly", 1, nil, 0)
+	assert.Error(t, err)
+}
+
+func TestParseScopes(t *testing.T) {
+	s, err := parseScopes([]string{"Blog", "Blog:db", "Shop: app", "monitor:2cSZzK3XfmG"})
+	assert.NoError(t, err)
+	assert.Equal(t, &scopes{
+		services: []string{"Blog"},
+		roles:    []string{"Blog: db", "Shop: app"},
+		monitors: []string{"2cSZzK3XfmG"},
+	}, s)
+
+	for _, v := range []string{"", "Blog:", ":db", "monitor:"} {
+		_, err := parseScopes([]string{v})
+		assert.Error(t, err, v)
+	}
+}
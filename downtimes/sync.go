@@ -0,0 +1,107 @@
+package downtimes
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/resourcesync"
+)
+
+type downtimesFile struct {
+	Downtimes []*mackerel.Downtime `json:"downtimes"`
+}
+
+func (app *downtimesApp) pull(filePath string) error {
+	downtimes, err := app.client.FindDowntimes()
+	if err != nil {
+		return err
+	}
+	data := format.JSONMarshalIndent(downtimesFile{Downtimes: downtimes}, "", "    ") + "\n"
+	if err := ioutil.WriteFile(filePath, []byte(data), 0644); err != nil {
+		return err
+	}
+	app.logger.Log("info", fmt.Sprintf("Downtimes are saved to '%s' (%d downtimes).", filePath, len(downtimes)))
+	return nil
+}
+
+func loadDowntimes(filePath string) ([]*mackerel.Downtime, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var file downtimesFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	if err := resourcesync.CheckResources("downtime", downtimeResources(file.Downtimes)); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	return file.Downtimes, nil
+}
+
+func downtimeResources(downtimes []*mackerel.Downtime) []resourcesync.Resource {
+	resources := make([]resourcesync.Resource, len(downtimes))
+	for i, d := range downtimes {
+		resources[i] = resourcesync.Resource{ID: d.ID, Name: d.Name, Value: d}
+	}
+	return resources
+}
+
+// planDowntimes returns the changes to make the current downtimes the desired ones.
+// The desired downtimes are matched by the id, or by the name if they have no id.
+// The downtimes which do not exist in the desired ones are deleted only if prune is true.
+func planDowntimes(current, desired []*mackerel.Downtime, prune bool) ([]*resourcesync.ResourceChange, error) {
+	return resourcesync.Plan("downtime", downtimeResources(current), downtimeResources(desired), prune, func(x, y interface{}) bool {
+		return sameDowntime(x.(*mackerel.Downtime), y.(*mackerel.Downtime))
+	})
+}
+
+// sameDowntime compares the downtimes except for their ids.
+func sameDowntime(x, y *mackerel.Downtime) bool {
+	xx, yy := *x, *y
+	xx.ID, yy.ID = "", ""
+	a, _ := json.Marshal(xx)
+	b, _ := json.Marshal(yy)
+	return bytes.Equal(a, b)
+}
+
+type pushDowntimesParam struct {
+	filePath string
+	dryRun   bool
+	prune    bool
+}
+
+func (app *downtimesApp) push(param pushDowntimesParam) error {
+	desired, err := loadDowntimes(param.filePath)
+	if err != nil {
+		return err
+	}
+	current, err := app.client.FindDowntimes()
+	if err != nil {
+		return err
+	}
+	changes, err := planDowntimes(current, desired, param.prune)
+	if err != nil {
+		return err
+	}
+	return resourcesync.Apply(app.outStream, app.logger, "downtimes", resourcesync.Changes(changes), param.dryRun, func(c resourcesync.Change) error {
+		rc := c.(*resourcesync.ResourceChange)
+		d := *rc.Value.(*mackerel.Downtime)
+		d.ID = ""
+		var err error
+		switch rc.Action() {
+		case "create":
+			_, err = app.client.CreateDowntime(&d)
+		case "update":
+			_, err = app.client.UpdateDowntime(rc.ID, &d)
+		case "delete":
+			_, err = app.client.DeleteDowntime(rc.ID)
+		}
+		return err
+	})
+}
@@ -0,0 +1,112 @@
+package downtimes
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/mackerelio/mkr/resourcesync"
+)
+
+func TestPlanDowntimes(t *testing.T) {
+	current := []*mackerel.Downtime{
+		{ID: "a", Name: "nightly", Start: 100, Duration: 60},
+		{ID: "b", Name: "weekly", Start: 200, Duration: 60},
+		{ID: "c", Name: "obsolete", Start: 300, Duration: 60},
+		{ID: "d", Name: "nightly", Start: 400, Duration: 60},
+	}
+	desired := []*mackerel.Downtime{
+		{Name: "nightly", Start: 100, Duration: 60},
+		{ID: "b", Name: "weekly (renamed)", Start: 200, Duration: 60},
+		{Name: "new", Start: 500, Duration: 30},
+		{ID: "d", Name: "nightly", Start: 400, Duration: 60},
+	}
+	describe := func(changes []*resourcesync.ResourceChange) []string {
+		var xs []string
+		for _, c := range changes {
+			xs = append(xs, c.Action()+" "+c.Target())
+		}
+		return xs
+	}
+
+	changes, err := planDowntimes(current, desired, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update 'weekly (renamed)' (b)", "create 'new'"}, describe(changes))
+
+	changes, err = planDowntimes(current, desired, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update 'weekly (renamed)' (b)", "create 'new'", "delete 'obsolete' (c)"}, describe(changes))
+
+	_, err = planDowntimes(current, []*mackerel.Downtime{{ID: "x", Name: "unknown"}}, false)
+	assert.EqualError(t, err, "downtime not found: x")
+}
+
+func TestDowntimesApp_PullPush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-downtimes")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "downtimes.json")
+
+	var created, updated []*mackerel.Downtime
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindDowntimes(func() ([]*mackerel.Downtime, error) {
+			return []*mackerel.Downtime{{ID: "a", Name: "nightly", Start: 100, Duration: 60, ServiceScopes: []string{"Blog"}}}, nil
+		}),
+		mackerelclient.MockCreateDowntime(func(d *mackerel.Downtime) (*mackerel.Downtime, error) {
+			created = append(created, d)
+			return d, nil
+		}),
+		mackerelclient.MockUpdateDowntime(func(id string, d *mackerel.Downtime) (*mackerel.Downtime, error) {
+			assert.Equal(t, "a", id)
+			updated = append(updated, d)
+			return d, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &downtimesApp{client: client, logger: &testLogger{out}, outStream: out}
+
+	assert.NoError(t, app.pull(filePath))
+	data, err := ioutil.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+    "downtimes": [
+        {
+            "id": "a",
+            "name": "nightly",
+            "start": 100,
+            "duration": 60,
+            "serviceScopes": [
+                "Blog"
+            ]
+        }
+    ]
+}
+`, string(data))
+
+	assert.NoError(t, app.push(pushDowntimesParam{filePath: filePath}))
+	assert.Empty(t, created)
+	assert.Empty(t, updated)
+
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(`{"downtimes": [
+		{"id": "a", "name": "nightly", "start": 100, "duration": 90},
+		{"name": "weekly", "start": 200, "duration": 60, "recurrence": {"type": "weekly", "interval": 1, "weekdays": ["Sunday"]}}
+	]}`), 0644))
+	out.Reset()
+	assert.NoError(t, app.push(pushDowntimesParam{filePath: filePath, dryRun: true}))
+	assert.Equal(t, "update 'nightly' (a)\ncreate 'weekly'\n", out.String())
+	assert.Empty(t, created)
+
+	out.Reset()
+	assert.NoError(t, app.push(pushDowntimesParam{filePath: filePath}))
+	assert.Equal(t, "updated 'nightly' (a)\ncreated 'weekly'\n", out.String())
+	assert.Equal(t, []*mackerel.Downtime{{Name: "nightly", Start: 100, Duration: 90}}, updated)
+	assert.Len(t, created, 1)
+	assert.Equal(t, mackerel.DowntimeRecurrenceTypeWeekly, created[0].Recurrence.Type)
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandEvents = cli.Command{
+	Name:  "events",
+	Usage: "Post events to services",
+	Description: `
+    Post events across services as graph annotations. With no subcommand specified, this will show all of subcommands.
+`,
+	Subcommands: []cli.Command{
+		{
+			Name:      "post",
+			Usage:     "Post an event",
+			ArgsUsage: "--title <title> [--description <description>] --services <services> [--roles <roles>] [--from <from>] [--to <to>]",
+			Description: `
+    Post an event to the services as the graph annotations with the same title.
+    The services and the roles are validated before posting, and the roles are <role> which all the services have,
+    or <service>:<role> which is applied only to the service. The services and the roles can be separated by commas.
+    The annotations already created are deleted if one of them fails to be created.
+    The time is RFC3339, epoch seconds, now, or the duration before now such as 30m. Both default to now.
+`,
+			Action: doEventsPost,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "title", Usage: "Title of the event"},
+				cli.StringFlag{Name: "description", Usage: "Description of the event"},
+				cli.StringSliceFlag{
+					Name:  "services, s",
+					Value: &cli.StringSlice{},
+					Usage: "Services of the event. Multiple choices are allowed",
+				},
+				cli.StringSliceFlag{
+					Name:  "roles, r",
+					Value: &cli.StringSlice{},
+					Usage: "Roles of the event in the form of <role> or <service>:<role>. Multiple choices are allowed",
+				},
+				cli.StringFlag{Name: "from", Value: "now", Usage: "Starting time of the event"},
+				cli.StringFlag{Name: "to", Value: "now", Usage: "Ending time of the event"},
+			},
+		},
+	},
+}
+
+// eventPoster is the subset of the client to post events.
+type eventPoster interface {
+	FindServices() ([]*mackerel.Service, error)
+	CreateGraphAnnotation(annotation *mackerel.GraphAnnotation) (*mackerel.GraphAnnotation, error)
+	DeleteGraphAnnotation(annotationID string) (*mackerel.GraphAnnotation, error)
+}
+
+type eventParam struct {
+	title       string
+	description string
+	services    []string
+	roles       []string
+	from, to    int64
+}
+
+// splitCommaValues splits the values separated by commas such as "Blog,Shop".
+func splitCommaValues(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
+
+// planEventAnnotations validates the services and the roles, and returns an annotation for each service.
+func planEventAnnotations(services []*mackerel.Service, param eventParam) ([]*mackerel.GraphAnnotation, error) {
+	existing := map[string][]string{}
+	for _, s := range services {
+		existing[s.Name] = s.Roles
+	}
+	roles := map[string][]string{}
+	for _, name := range param.services {
+		if _, ok := existing[name]; !ok {
+			return nil, fmt.Errorf("service not found: %s", name)
+		}
+		if _, ok := roles[name]; ok {
+			return nil, fmt.Errorf("the service %s is duplicated", name)
+		}
+		roles[name] = []string{}
+	}
+	for _, r := range param.roles {
+		targets := param.services
+		role := r
+		if kv := strings.SplitN(r, ":", 2); len(kv) == 2 {
+			if _, ok := roles[kv[0]]; !ok {
+				return nil, fmt.Errorf("the service of the role %s is not specified in the services", r)
+			}
+			targets, role = []string{kv[0]}, kv[1]
+		}
+		for _, s := range targets {
+			if !containsString(existing[s], role) {
+				return nil, fmt.Errorf("role not found: %s:%s", s, role)
+			}
+			if !containsString(roles[s], role) {
+				roles[s] = append(roles[s], role)
+			}
+		}
+	}
+	annotations := make([]*mackerel.GraphAnnotation, len(param.services))
+	for i, s := range param.services {
+		annotations[i] = &mackerel.GraphAnnotation{
+			Title:       param.title,
+			Description: param.description,
+			From:        param.from,
+			To:          param.to,
+			Service:     s,
+			Roles:       roles[s],
+		}
+	}
+	return annotations, nil
+}
+
+// postEvent creates the annotations of the event, and deletes the created ones if any of them fails.
+func postEvent(client eventPoster, param eventParam) ([]*mackerel.GraphAnnotation, error) {
+	services, err := client.FindServices()
+	if err != nil {
+		return nil, err
+	}
+	annotations, err := planEventAnnotations(services, param)
+	if err != nil {
+		return nil, err
+	}
+	var created []*mackerel.GraphAnnotation
+	for _, a := range annotations {
+		c, err := client.CreateGraphAnnotation(a)
+		if err != nil {
+			err = fmt.Errorf("failed to create the annotation of %s: %s", a.Service, err)
+			for _, c := range created {
+				if _, e := client.DeleteGraphAnnotation(c.ID); e != nil {
+					return nil, fmt.Errorf("%s, and failed to delete the created annotation %s: %s", err, c.ID, e)
+				}
+			}
+			return nil, err
+		}
+		created = append(created, c)
+	}
+	return created, nil
+}
+
+func doEventsPost(c *cli.Context) error {
+	param := eventParam{
+		title:       c.String("title"),
+		description: c.String("description"),
+		services:    splitCommaValues(c.StringSlice("services")),
+		roles:       splitCommaValues(c.StringSlice("roles")),
+	}
+	if param.title == "" || len(param.services) == 0 {
+		cli.ShowCommandHelp(c, "post")
+		os.Exit(1)
+	}
+	now := time.Now()
+	from, err := parseAlertTime(c.String("from"), now)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	to, err := parseAlertTime(c.String("to"), now)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	if from.After(to) {
+		return cli.NewExitError("--from should not be after --to", 1)
+	}
+	param.from, param.to = from.Unix(), to.Unix()
+
+	annotations, err := postEvent(mackerelclient.NewFromContext(c), param)
+	logger.DieIf(err)
+	format.PrettyPrintJSON(os.Stdout, annotations)
+	return nil
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type fakeEventPoster struct {
+	services []*mackerel.Service
+	failOn   string
+	created  []string
+	deleted  []string
+}
+
+func (p *fakeEventPoster) FindServices() ([]*mackerel.Service, error) {
+	return p.services, nil
+}
+
+func (p *fakeEventPoster) CreateGraphAnnotation(a *mackerel.GraphAnnotation) (*mackerel.GraphAnnotation, error) {
+	if a.Service == p.failOn {
+		return nil, errors.New("API error")
+	}
+	p.created = append(p.created, a.Service)
+	c := *a
+	c.ID = "id-" + a.Service
+	return &c, nil
+}
+
+func (p *fakeEventPoster) DeleteGraphAnnotation(id string) (*mackerel.GraphAnnotation, error) {
+	p.deleted = append(p.deleted, id)
+	return &mackerel.GraphAnnotation{ID: id}, nil
+}
+
+var testEventServices = []*mackerel.Service{
+	{Name: "Blog", Roles: []string{"app", "db"}},
+	{Name: "Shop", Roles: []string{"app", "batch"}},
+}
+
+func TestPlanEventAnnotations(t *testing.T) {
+	param := eventParam{title: "release", services: []string{"Blog", "Shop"}, roles: []string{"app", "Shop:batch"}, from: 100, to: 200}
+	annotations, err := planEventAnnotations(testEventServices, param)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*mackerel.GraphAnnotation{
+		{Title: "release", From: 100, To: 200, Service: "Blog", Roles: []string{"app"}},
+		{Title: "release", From: 100, To: 200, Service: "Shop", Roles: []string{"app", "batch"}},
+	}
+	if !reflect.DeepEqual(annotations, want) {
+		t.Errorf("annotations should be %+v but got %+v", want, annotations)
+	}
+
+	for _, tc := range []struct {
+		services, roles []string
+		err             string
+	}{
+		{services: []string{"Wiki"}, err: "service not found: Wiki"},
+		{services: []string{"Blog", "Blog"}, err: "the service Blog is duplicated"},
+		{services: []string{"Blog", "Shop"}, roles: []string{"db"}, err: "role not found: Shop:db"},
+		{services: []string{"Blog"}, roles: []string{"Shop:app"}, err: "the service of the role Shop:app is not specified in the services"},
+	} {
+		_, err := planEventAnnotations(testEventServices, eventParam{services: tc.services, roles: tc.roles})
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("error should be %q but got %v", tc.err, err)
+		}
+	}
+}
+
+func TestPostEvent(t *testing.T) {
+	client := &fakeEventPoster{services: testEventServices}
+	created, err := postEvent(client, eventParam{title: "release", services: []string{"Blog", "Shop"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(created) != 2 || created[1].ID != "id-Shop" {
+		t.Errorf("unexpected annotations: %+v", created)
+	}
+
+	client = &fakeEventPoster{services: testEventServices, failOn: "Shop"}
+	_, err = postEvent(client, eventParam{title: "release", services: []string{"Blog", "Shop"}})
+	if err == nil || err.Error() != "failed to create the annotation of Shop: API error" {
+		t.Errorf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(client.deleted, []string{"id-Blog"}) {
+		t.Errorf("the created annotations should be deleted but got %v", client.deleted)
+	}
+}
+
+func TestSplitCommaValues(t *testing.T) {
+	got := splitCommaValues([]string{"Blog,Shop", " Wiki ", ""})
+	if want := []string{"Blog", "Shop", "Wiki"}; !reflect.DeepEqual(got, want) {
+		t.Errorf("values should be %v but got %v", want, got)
+	}
+}
@@ -0,0 +1,51 @@
+package export
+
+import (
+	"strings"
+
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+// Command is the definition of export subcommand
+var Command = cli.Command{
+	Name:  "export",
+	Usage: "Export the resources of the organization to other tools",
+	Subcommands: []cli.Command{
+		{
+			Name:      "terraform",
+			Usage:     "Export the resources as Terraform configuration",
+			ArgsUsage: "[--resources <resources>] [--out <dir>]",
+			Description: `
+    Convert the services with the roles, the monitors and the dashboards into Terraform configuration
+    for the mackerel provider (mackerelio-labs/mackerel), with the import blocks of the IDs of the resources.
+    The configuration of each resource type is written to <dir>/<resources>.tf, and provider.tf is written unless it exists.
+    Run "terraform plan" to import the resources into the state. The import blocks require Terraform 1.5 or later.
+`,
+			Action: doExportTerraform,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "resources", Value: strings.Join(terraformResources, ","), Usage: "Comma separated resource types to export"},
+				cli.StringFlag{Name: "out", Value: ".", Usage: "Directory to write the configuration"},
+			},
+		},
+	},
+}
+
+func doExportTerraform(c *cli.Context) error {
+	var resources []string
+	for _, r := range strings.Split(c.String("resources"), ",") {
+		if r = strings.TrimSpace(r); r != "" {
+			resources = append(resources, r)
+		}
+	}
+	app := &exportApp{
+		client: mackerelclient.NewFromContext(c),
+		logger: logger.New(),
+	}
+	return app.exportTerraform(exportTerraformParam{
+		resources: resources,
+		outDir:    c.String("out"),
+	})
+}
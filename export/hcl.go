@@ -0,0 +1,185 @@
+package export
+
+import (
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hclBlock is a block of HCL such as resource "type" "name" { ... }
+type hclBlock struct {
+	typ    string
+	labels []string
+	attrs  []*hclAttr
+	blocks []*hclBlock
+}
+
+type hclAttr struct {
+	name  string
+	value interface{}
+}
+
+// hclExpr is an expression written as is, such as a reference to another resource
+type hclExpr string
+
+func newHCLBlock(typ string, labels ...string) *hclBlock {
+	return &hclBlock{typ: typ, labels: labels}
+}
+
+// set sets the attribute
+func (b *hclBlock) set(name string, value interface{}) *hclBlock {
+	b.attrs = append(b.attrs, &hclAttr{name, value})
+	return b
+}
+
+// setNonZero sets the attribute unless the value is the zero value
+func (b *hclBlock) setNonZero(name string, value interface{}) *hclBlock {
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return b
+		}
+	case bool:
+		if !v {
+			return b
+		}
+	case int64:
+		if v == 0 {
+			return b
+		}
+	case uint64:
+		if v == 0 {
+			return b
+		}
+	case []string:
+		if len(v) == 0 {
+			return b
+		}
+	case map[string]string:
+		if len(v) == 0 {
+			return b
+		}
+	}
+	return b.set(name, value)
+}
+
+// block appends a nested block and returns it
+func (b *hclBlock) block(typ string, labels ...string) *hclBlock {
+	nb := newHCLBlock(typ, labels...)
+	b.blocks = append(b.blocks, nb)
+	return nb
+}
+
+// write writes the block in the style of terraform fmt
+func (b *hclBlock) write(w io.Writer, indent string) {
+	fmt.Fprint(w, indent, b.typ)
+	for _, l := range b.labels {
+		fmt.Fprint(w, " ", hclQuote(l))
+	}
+	fmt.Fprintln(w, " {")
+	width := 0
+	for _, a := range b.attrs {
+		if len(a.name) > width {
+			width = len(a.name)
+		}
+	}
+	for _, a := range b.attrs {
+		fmt.Fprintf(w, "%s  %-*s = %s\n", indent, width, a.name, hclValue(a.value))
+	}
+	for i, nb := range b.blocks {
+		if i > 0 || len(b.attrs) > 0 {
+			fmt.Fprintln(w)
+		}
+		nb.write(w, indent+"  ")
+	}
+	fmt.Fprintln(w, indent+"}")
+}
+
+func hclValue(value interface{}) string {
+	switch v := value.(type) {
+	case hclExpr:
+		return string(v)
+	case string:
+		return hclQuote(v)
+	case bool:
+		return strconv.FormatBool(v)
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case []string:
+		xs := make([]string, len(v))
+		for i, s := range v {
+			xs[i] = hclQuote(s)
+		}
+		return "[" + strings.Join(xs, ", ") + "]"
+	case map[string]string:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		xs := make([]string, len(keys))
+		for i, k := range keys {
+			xs[i] = hclQuote(k) + " = " + hclQuote(v[k])
+		}
+		return "{ " + strings.Join(xs, ", ") + " }"
+	default:
+		panic(fmt.Sprintf("unsupported HCL value: %#v", value))
+	}
+}
+
+// hclQuote quotes the string as a HCL string literal,
+// escaping the template sequences ${ and %{ not to be interpolated.
+func hclQuote(s string) string {
+	var sb strings.Builder
+	sb.WriteByte('"')
+	for i, r := range s {
+		switch {
+		case r == '"':
+			sb.WriteString(`\"`)
+		case r == '\\':
+			sb.WriteString(`\\`)
+		case r == '\n':
+			sb.WriteString(`\n`)
+		case r == '\r':
+			sb.WriteString(`\r`)
+		case r == '\t':
+			sb.WriteString(`\t`)
+		case r < 0x20 || r == 0x7f:
+			fmt.Fprintf(&sb, `\u%04X`, r)
+		case (r == '$' || r == '%') && strings.HasPrefix(s[i+1:], "{"):
+			sb.WriteRune(r)
+			sb.WriteRune(r)
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	sb.WriteByte('"')
+	return sb.String()
+}
+
+var invalidNameChars = regexp.MustCompile(`[^a-z0-9_]+`)
+
+// resourceNamer names the resources uniquely within the resource types
+type resourceNamer map[string]int
+
+// name returns the name of the resource which is a valid identifier of Terraform
+func (n resourceNamer) name(typ, s string) string {
+	name := strings.Trim(invalidNameChars.ReplaceAllString(strings.ToLower(s), "_"), "_")
+	if name == "" || name[0] >= '0' && name[0] <= '9' {
+		name = "r_" + name
+	}
+	key := typ + "." + name
+	n[key]++
+	if c := n[key]; c > 1 {
+		name = fmt.Sprintf("%s_%d", name, c)
+		n[typ+"."+name]++
+	}
+	return name
+}
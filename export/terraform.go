@@ -0,0 +1,358 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// terraformResources are the resources which can be exported to Terraform
+var terraformResources = []string{"services", "monitors", "dashboards"}
+
+// terraformProvider is written to provider.tf unless it exists.
+// The import blocks require Terraform 1.5 or later.
+const terraformProvider = `terraform {
+  required_version = ">= 1.5.0"
+
+  required_providers {
+    mackerel = {
+      source = "mackerelio-labs/mackerel"
+    }
+  }
+}
+`
+
+type exportClient interface {
+	FindServices() ([]*mackerel.Service, error)
+	FindRoles(serviceName string) ([]*mackerel.Role, error)
+	FindMonitors() ([]mackerel.Monitor, error)
+	FindDashboards() ([]*mackerel.Dashboard, error)
+	FindDashboard(dashboardID string) (*mackerel.Dashboard, error)
+}
+
+type appLogger interface {
+	Log(string, string)
+}
+
+type exportApp struct {
+	client exportClient
+	logger appLogger
+	names  resourceNamer
+}
+
+type exportTerraformParam struct {
+	resources []string
+	outDir    string
+}
+
+func (app *exportApp) exportTerraform(param exportTerraformParam) error {
+	for _, r := range param.resources {
+		if !containsString(terraformResources, r) {
+			return fmt.Errorf("unknown resource: %s (should be one of %v)", r, terraformResources)
+		}
+	}
+	if err := os.MkdirAll(param.outDir, 0755); err != nil {
+		return err
+	}
+	app.names = resourceNamer{}
+	for _, r := range terraformResources {
+		if !containsString(param.resources, r) {
+			continue
+		}
+		var buf bytes.Buffer
+		var err error
+		switch r {
+		case "services":
+			err = app.writeServices(&buf)
+		case "monitors":
+			err = app.writeMonitors(&buf)
+		case "dashboards":
+			err = app.writeDashboards(&buf)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to export %s: %s", r, err)
+		}
+		filePath := filepath.Join(param.outDir, r+".tf")
+		if err := ioutil.WriteFile(filePath, buf.Bytes(), 0644); err != nil {
+			return err
+		}
+		app.logger.Log("created", filePath)
+	}
+
+	filePath := filepath.Join(param.outDir, "provider.tf")
+	if _, err := os.Stat(filePath); err == nil {
+		return nil
+	}
+	if err := ioutil.WriteFile(filePath, []byte(terraformProvider), 0644); err != nil {
+		return err
+	}
+	app.logger.Log("created", filePath)
+	return nil
+}
+
+// writeResource writes the resource and the import block of it
+func writeResource(w io.Writer, resource *hclBlock, id string) {
+	resource.write(w, "")
+	fmt.Fprintln(w)
+	newHCLBlock("import").
+		set("to", hclExpr(resource.labels[0]+"."+resource.labels[1])).
+		set("id", id).
+		write(w, "")
+	fmt.Fprintln(w)
+}
+
+func (app *exportApp) writeServices(w io.Writer) error {
+	services, err := app.client.FindServices()
+	if err != nil {
+		return err
+	}
+	sort.Slice(services, func(i, j int) bool { return services[i].Name < services[j].Name })
+	for _, s := range services {
+		name := app.names.name("mackerel_service", s.Name)
+		writeResource(w, newHCLBlock("resource", "mackerel_service", name).
+			set("name", s.Name).
+			setNonZero("memo", s.Memo), s.Name)
+
+		roles, err := app.client.FindRoles(s.Name)
+		if err != nil {
+			return err
+		}
+		sort.Slice(roles, func(i, j int) bool { return roles[i].Name < roles[j].Name })
+		for _, r := range roles {
+			writeResource(w, newHCLBlock("resource", "mackerel_role", app.names.name("mackerel_role", s.Name+"_"+r.Name)).
+				set("service", hclExpr("mackerel_service."+name+".name")).
+				set("name", r.Name).
+				setNonZero("memo", r.Memo), s.Name+":"+r.Name)
+		}
+	}
+	return nil
+}
+
+func (app *exportApp) writeMonitors(w io.Writer) error {
+	monitors, err := app.client.FindMonitors()
+	if err != nil {
+		return err
+	}
+	for _, m := range monitors {
+		resource, err := monitorResource(m, app.names.name("mackerel_monitor", m.MonitorName()))
+		if err != nil {
+			app.logger.Log("warning", fmt.Sprintf("skip the monitor %s: %s", m.MonitorID(), err))
+			continue
+		}
+		writeResource(w, resource, m.MonitorID())
+	}
+	return nil
+}
+
+// formatThreshold formats the threshold of the monitor, which is a string in the provider
+func formatThreshold(v *float64) string {
+	if v == nil {
+		return ""
+	}
+	return strconv.FormatFloat(*v, 'f', -1, 64)
+}
+
+func monitorResource(m mackerel.Monitor, name string) (*hclBlock, error) {
+	resource := newHCLBlock("resource", "mackerel_monitor", name)
+	common := func(name, memo string, isMute bool, notificationInterval uint64) {
+		resource.set("name", name).
+			setNonZero("memo", memo).
+			setNonZero("is_mute", isMute).
+			setNonZero("notification_interval", notificationInterval)
+	}
+	switch m := m.(type) {
+	case *mackerel.MonitorConnectivity:
+		common(m.Name, m.Memo, m.IsMute, m.NotificationInterval)
+		resource.block("connectivity").
+			setNonZero("scopes", m.Scopes).
+			setNonZero("exclude_scopes", m.ExcludeScopes)
+	case *mackerel.MonitorHostMetric:
+		common(m.Name, m.Memo, m.IsMute, m.NotificationInterval)
+		resource.block("host_metric").
+			set("metric", m.Metric).
+			set("operator", m.Operator).
+			setNonZero("warning", formatThreshold(m.Warning)).
+			setNonZero("critical", formatThreshold(m.Critical)).
+			set("duration", m.Duration).
+			setNonZero("max_check_attempts", m.MaxCheckAttempts).
+			setNonZero("scopes", m.Scopes).
+			setNonZero("exclude_scopes", m.ExcludeScopes)
+	case *mackerel.MonitorServiceMetric:
+		common(m.Name, m.Memo, m.IsMute, m.NotificationInterval)
+		resource.block("service_metric").
+			set("service", m.Service).
+			set("metric", m.Metric).
+			set("operator", m.Operator).
+			setNonZero("warning", formatThreshold(m.Warning)).
+			setNonZero("critical", formatThreshold(m.Critical)).
+			set("duration", m.Duration).
+			setNonZero("max_check_attempts", m.MaxCheckAttempts).
+			setNonZero("missing_duration_warning", m.MissingDurationWarning).
+			setNonZero("missing_duration_critical", m.MissingDurationCritical)
+	case *mackerel.MonitorExternalHTTP:
+		common(m.Name, m.Memo, m.IsMute, m.NotificationInterval)
+		b := resource.block("external").
+			setNonZero("method", m.Method).
+			set("url", m.URL).
+			setNonZero("request_body", m.RequestBody).
+			setNonZero("service", m.Service).
+			setNonZero("contains_string", m.ContainsString).
+			setNonZero("max_check_attempts", m.MaxCheckAttempts).
+			setNonZero("skip_certificate_verification", m.SkipCertificateVerification)
+		if m.ResponseTimeCritical != nil {
+			b.set("response_time_critical", *m.ResponseTimeCritical)
+		}
+		if m.ResponseTimeWarning != nil {
+			b.set("response_time_warning", *m.ResponseTimeWarning)
+		}
+		if m.ResponseTimeDuration != nil {
+			b.set("response_time_duration", *m.ResponseTimeDuration)
+		}
+		if m.CertificationExpirationCritical != nil {
+			b.set("certification_expiration_critical", *m.CertificationExpirationCritical)
+		}
+		if m.CertificationExpirationWarning != nil {
+			b.set("certification_expiration_warning", *m.CertificationExpirationWarning)
+		}
+		if len(m.Headers) > 0 {
+			headers := make(map[string]string, len(m.Headers))
+			for _, h := range m.Headers {
+				headers[h.Name] = h.Value
+			}
+			b.set("headers", headers)
+		}
+	case *mackerel.MonitorExpression:
+		common(m.Name, m.Memo, m.IsMute, m.NotificationInterval)
+		resource.block("expression").
+			set("expression", m.Expression).
+			set("operator", m.Operator).
+			setNonZero("warning", formatThreshold(m.Warning)).
+			setNonZero("critical", formatThreshold(m.Critical))
+	case *mackerel.MonitorAnomalyDetection:
+		common(m.Name, m.Memo, m.IsMute, m.NotificationInterval)
+		resource.block("anomaly_detection").
+			setNonZero("warning_sensitivity", m.WarningSensitivity).
+			setNonZero("critical_sensitivity", m.CriticalSensitivity).
+			setNonZero("max_check_attempts", m.MaxCheckAttempts).
+			setNonZero("training_period_from", m.TrainingPeriodFrom).
+			set("scopes", m.Scopes)
+	default:
+		return nil, fmt.Errorf("unsupported monitor type: %s", m.MonitorType())
+	}
+	return resource, nil
+}
+
+func (app *exportApp) writeDashboards(w io.Writer) error {
+	dashboards, err := app.client.FindDashboards()
+	if err != nil {
+		return err
+	}
+	for _, d := range dashboards {
+		if d.IsLegacy {
+			app.logger.Log("warning", fmt.Sprintf("skip the legacy dashboard %s", d.ID))
+			continue
+		}
+		// the list of the dashboards does not have the widgets
+		dashboard, err := app.client.FindDashboard(d.ID)
+		if err != nil {
+			return err
+		}
+		resource, err := dashboardResource(dashboard, app.names.name("mackerel_dashboard", d.Title))
+		if err != nil {
+			app.logger.Log("warning", fmt.Sprintf("skip the dashboard %s: %s", d.ID, err))
+			continue
+		}
+		writeResource(w, resource, d.ID)
+	}
+	return nil
+}
+
+func dashboardResource(d *mackerel.Dashboard, name string) (*hclBlock, error) {
+	resource := newHCLBlock("resource", "mackerel_dashboard", name).
+		set("title", d.Title).
+		set("url_path", d.URLPath).
+		setNonZero("memo", d.Memo)
+	for i, widget := range d.Widgets {
+		var b *hclBlock
+		switch widget.Type {
+		case "graph":
+			b = resource.block("graph").set("title", widget.Title)
+			if err := setDashboardGraph(b, widget.Graph); err != nil {
+				return nil, fmt.Errorf("widgets[%d]: %s", i, err)
+			}
+			switch widget.Range.Type {
+			case "relative":
+				b.block("range").block("relative").
+					set("period", widget.Range.Period).
+					set("offset", widget.Range.Offset)
+			case "absolute":
+				b.block("range").block("absolute").
+					set("start", widget.Range.Start).
+					set("end", widget.Range.End)
+			}
+		case "value":
+			b = resource.block("value").set("title", widget.Title)
+			if err := setDashboardMetric(b.block("metric"), widget.Metric); err != nil {
+				return nil, fmt.Errorf("widgets[%d]: %s", i, err)
+			}
+		case "markdown":
+			b = resource.block("markdown").
+				set("title", widget.Title).
+				set("markdown", widget.Markdown)
+		default:
+			return nil, fmt.Errorf("widgets[%d]: unsupported widget type: %s", i, widget.Type)
+		}
+		b.block("layout").
+			set("x", widget.Layout.X).
+			set("y", widget.Layout.Y).
+			set("width", widget.Layout.Width).
+			set("height", widget.Layout.Height)
+	}
+	return resource, nil
+}
+
+func setDashboardGraph(b *hclBlock, g mackerel.Graph) error {
+	switch g.Type {
+	case "host":
+		b.block("host").set("host_id", g.HostID).set("name", g.Name)
+	case "role":
+		b.block("role").set("role_fullname", g.RoleFullName).set("name", g.Name).setNonZero("is_stacked", g.IsStacked)
+	case "service":
+		b.block("service").set("service_name", g.ServiceName).set("name", g.Name)
+	case "expression":
+		b.block("expression").set("expression", g.Expression)
+	default:
+		return fmt.Errorf("unsupported graph type: %s", g.Type)
+	}
+	return nil
+}
+
+func setDashboardMetric(b *hclBlock, m mackerel.Metric) error {
+	switch m.Type {
+	case "host":
+		b.block("host").set("host_id", m.HostID).set("name", m.Name)
+	case "service":
+		b.block("service").set("service_name", m.ServiceName).set("name", m.Name)
+	case "expression":
+		b.block("expression").set("expression", m.Expression)
+	default:
+		return fmt.Errorf("unsupported metric type: %s", m.Type)
+	}
+	return nil
+}
+
+func containsString(xs []string, x string) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
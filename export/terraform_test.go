@@ -0,0 +1,234 @@
+package export
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type fakeClient struct {
+	services   []*mackerel.Service
+	roles      map[string][]*mackerel.Role
+	monitors   []mackerel.Monitor
+	dashboards []*mackerel.Dashboard
+}
+
+func (c *fakeClient) FindServices() ([]*mackerel.Service, error) {
+	return c.services, nil
+}
+
+func (c *fakeClient) FindRoles(serviceName string) ([]*mackerel.Role, error) {
+	return c.roles[serviceName], nil
+}
+
+func (c *fakeClient) FindMonitors() ([]mackerel.Monitor, error) {
+	return c.monitors, nil
+}
+
+func (c *fakeClient) FindDashboards() ([]*mackerel.Dashboard, error) {
+	var dashboards []*mackerel.Dashboard
+	for _, d := range c.dashboards {
+		dashboards = append(dashboards, &mackerel.Dashboard{ID: d.ID, Title: d.Title, URLPath: d.URLPath, IsLegacy: d.IsLegacy})
+	}
+	return dashboards, nil
+}
+
+func (c *fakeClient) FindDashboard(dashboardID string) (*mackerel.Dashboard, error) {
+	for _, d := range c.dashboards {
+		if d.ID == dashboardID {
+			return d, nil
+		}
+	}
+	return nil, fmt.Errorf("dashboard not found: %s", dashboardID)
+}
+
+type testLogger struct {
+	w io.Writer
+}
+
+func (l *testLogger) Log(prefix, message string) {
+	fmt.Fprintln(l.w, prefix, message)
+}
+
+func pfloat64(v float64) *float64 {
+	return &v
+}
+
+func TestExportApp_exportTerraform(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-export")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	client := &fakeClient{
+		services: []*mackerel.Service{{Name: "My-Service", Memo: "my service"}},
+		roles: map[string][]*mackerel.Role{
+			"My-Service": {{Name: "web"}},
+		},
+		monitors: []mackerel.Monitor{
+			&mackerel.MonitorHostMetric{
+				ID: "mon1", Name: "cpu %", Type: "host", Metric: "cpu%", Operator: ">",
+				Warning: pfloat64(80.5), Duration: 3, Scopes: []string{"My-Service"},
+			},
+			&mackerel.MonitorExternalHTTP{
+				ID: "mon2", Name: "cpu %", Type: "external", URL: "https://example.com/${path}",
+				Headers: []mackerel.HeaderField{{Name: "Cache-Control", Value: "no-cache"}},
+			},
+		},
+		dashboards: []*mackerel.Dashboard{
+			{ID: "dash0", Title: "Legacy", IsLegacy: true},
+			{ID: "dash1", Title: "1st Dashboard", URLPath: "first", Widgets: []mackerel.Widget{
+				{Type: "markdown", Title: "memo", Markdown: "# \"title\"\n", Layout: mackerel.Layout{Width: 24, Height: 3}},
+				{Type: "graph", Title: "loadavg", Graph: mackerel.Graph{Type: "role", RoleFullName: "My-Service:web", Name: "loadavg5"},
+					Range: mackerel.Range{Type: "relative", Period: 3600}, Layout: mackerel.Layout{Y: 3, Width: 12, Height: 8}},
+			}},
+		},
+	}
+	var logs bytes.Buffer
+	app := &exportApp{client: client, logger: &testLogger{&logs}}
+	assert.NoError(t, app.exportTerraform(exportTerraformParam{
+		resources: []string{"dashboards", "monitors", "services"},
+		outDir:    dir,
+	}))
+
+	read := func(name string) string {
+		data, err := ioutil.ReadFile(filepath.Join(dir, name))
+		assert.NoError(t, err)
+		return string(data)
+	}
+	assert.Equal(t, `resource "mackerel_service" "my_service" {
+  name = "My-Service"
+  memo = "my service"
+}
+
+import {
+  to = mackerel_service.my_service
+  id = "My-Service"
+}
+
+resource "mackerel_role" "my_service_web" {
+  service = mackerel_service.my_service.name
+  name    = "web"
+}
+
+import {
+  to = mackerel_role.my_service_web
+  id = "My-Service:web"
+}
+
+`, read("services.tf"))
+
+	assert.Equal(t, `resource "mackerel_monitor" "cpu" {
+  name = "cpu %"
+
+  host_metric {
+    metric   = "cpu%"
+    operator = ">"
+    warning  = "80.5"
+    duration = 3
+    scopes   = ["My-Service"]
+  }
+}
+
+import {
+  to = mackerel_monitor.cpu
+  id = "mon1"
+}
+
+resource "mackerel_monitor" "cpu_2" {
+  name = "cpu %"
+
+  external {
+    url     = "https://example.com/$${path}"
+    headers = { "Cache-Control" = "no-cache" }
+  }
+}
+
+import {
+  to = mackerel_monitor.cpu_2
+  id = "mon2"
+}
+
+`, read("monitors.tf"))
+
+	assert.Equal(t, `resource "mackerel_dashboard" "r_1st_dashboard" {
+  title    = "1st Dashboard"
+  url_path = "first"
+
+  markdown {
+    title    = "memo"
+    markdown = "# \"title\"\n"
+
+    layout {
+      x      = 0
+      y      = 0
+      width  = 24
+      height = 3
+    }
+  }
+
+  graph {
+    title = "loadavg"
+
+    role {
+      role_fullname = "My-Service:web"
+      name          = "loadavg5"
+    }
+
+    range {
+      relative {
+        period = 3600
+        offset = 0
+      }
+    }
+
+    layout {
+      x      = 0
+      y      = 3
+      width  = 12
+      height = 8
+    }
+  }
+}
+
+import {
+  to = mackerel_dashboard.r_1st_dashboard
+  id = "dash1"
+}
+
+`, read("dashboards.tf"))
+
+	assert.Equal(t, terraformProvider, read("provider.tf"))
+	assert.Contains(t, logs.String(), "warning skip the legacy dashboard dash0")
+
+	// provider.tf is kept
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "provider.tf"), []byte("# my provider\n"), 0644))
+	assert.NoError(t, app.exportTerraform(exportTerraformParam{resources: []string{"services"}, outDir: dir}))
+	assert.Equal(t, "# my provider\n", read("provider.tf"))
+
+	assert.EqualError(t, app.exportTerraform(exportTerraformParam{resources: []string{"alerts"}, outDir: dir}),
+		"unknown resource: alerts (should be one of [services monitors dashboards])")
+}
+
+func TestHCLQuote(t *testing.T) {
+	testCases := []struct {
+		in, out string
+	}{
+		{"foo", `"foo"`},
+		{"a\"b\\c\n\t", `"a\"b\\c\n\t"`},
+		{"${var} %{if} $ % {}", `"$${var} %%{if} $ % {}"`},
+		{"\x01あ", `"\u0001あ"`},
+	}
+	for _, tc := range testCases {
+		assert.Equal(t, tc.out, hclQuote(tc.in))
+	}
+}
@@ -0,0 +1,263 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/urfave/cli"
+)
+
+// the range to find the latest service metric values, which the API does not provide
+const serviceMetricLatestLookback = 24 * time.Hour
+
+// metricSeries is the metric values of a metric of a host or a service
+type metricSeries struct {
+	target string
+	name   string
+	values []mackerel.MetricValue
+}
+
+type hostMetricRangeFetcher interface {
+	FetchHostMetricValues(hostID string, metricName string, from int64, to int64) ([]mackerel.MetricValue, error)
+}
+
+func fetchHostMetricSeries(client hostMetricRangeFetcher, hostIDs, names []string, from, to int64) ([]*metricSeries, error) {
+	var series []*metricSeries
+	for _, hostID := range hostIDs {
+		for _, name := range names {
+			values, err := client.FetchHostMetricValues(hostID, name, from, to)
+			if err != nil {
+				return nil, err
+			}
+			series = append(series, &metricSeries{target: hostID, name: name, values: values})
+		}
+	}
+	return series, nil
+}
+
+type serviceMetricRangeFetcher interface {
+	FetchServiceMetricValues(serviceName string, metricName string, from int64, to int64) ([]mackerel.MetricValue, error)
+}
+
+func fetchServiceMetricSeries(client serviceMetricRangeFetcher, service string, names []string, from, to int64) ([]*metricSeries, error) {
+	var series []*metricSeries
+	for _, name := range names {
+		values, err := client.FetchServiceMetricValues(service, name, from, to)
+		if err != nil {
+			return nil, err
+		}
+		series = append(series, &metricSeries{target: service, name: name, values: values})
+	}
+	return series, nil
+}
+
+// lastMetricValues leaves only the last value of each series.
+func lastMetricValues(series []*metricSeries) []*metricSeries {
+	last := make([]*metricSeries, 0, len(series))
+	for _, s := range series {
+		if len(s.values) == 0 {
+			continue
+		}
+		latest := s.values[0]
+		for _, v := range s.values[1:] {
+			if v.Time > latest.Time {
+				latest = v
+			}
+		}
+		last = append(last, &metricSeries{target: s.target, name: s.name, values: []mackerel.MetricValue{latest}})
+	}
+	return last
+}
+
+type latestMetricValuesFetcher interface {
+	FetchLatestMetricValues(hostIDs []string, metricNames []string) (mackerel.LatestMetricValues, error)
+}
+
+// latestMetricBatchSize is the number of the hosts per request to avoid the maximum length of the URL
+const latestMetricBatchSize = 100
+
+// fetchLatestHostMetricValues fetches the latest metric values of the hosts in batches with up to parallel requests at once.
+func fetchLatestHostMetricValues(client latestMetricValuesFetcher, hostIDs, names []string, parallel int) (mackerel.LatestMetricValues, error) {
+	if parallel < 1 {
+		parallel = 1
+	}
+	var (
+		wg       sync.WaitGroup
+		mu       sync.Mutex
+		sem      = make(chan struct{}, parallel)
+		latest   = make(mackerel.LatestMetricValues)
+		firstErr error
+	)
+	for _, ids := range split(hostIDs, latestMetricBatchSize) {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(ids []string) {
+			defer func() { <-sem; wg.Done() }()
+			values, err := client.FetchLatestMetricValues(ids, names)
+			mu.Lock()
+			defer mu.Unlock()
+			if err != nil {
+				if firstErr == nil {
+					firstErr = err
+				}
+				return
+			}
+			for hostID, v := range values {
+				latest[hostID] = v
+			}
+		}(ids)
+	}
+	wg.Wait()
+	if firstErr != nil {
+		return nil, firstErr
+	}
+	return latest, nil
+}
+
+// latestMetricTable builds the matrix of the latest metric values with the rows of the hosts
+// and the columns of the metrics. The names of the hosts are shown if they are known.
+func latestMetricTable(latest mackerel.LatestMetricValues, hostIDs []string, hostNames map[string]string, names []string) *format.Table {
+	t := format.NewTable(append([]string{"HOST_ID", "NAME"}, names...)...)
+	for _, hostID := range hostIDs {
+		row := []string{hostID, hostNames[hostID]}
+		for _, name := range names {
+			if v, ok := latest[hostID][name]; ok && v != nil {
+				row = append(row, fmt.Sprint(v.Value))
+			} else {
+				row = append(row, "-")
+			}
+		}
+		t.Append(row...)
+	}
+	return t
+}
+
+// latestMetricSeries converts the latest metric values into the series in the order of the arguments.
+func latestMetricSeries(latest mackerel.LatestMetricValues, targets, names []string) []*metricSeries {
+	var series []*metricSeries
+	for _, target := range targets {
+		for _, name := range names {
+			v, ok := latest[target][name]
+			if !ok || v == nil {
+				continue
+			}
+			series = append(series, &metricSeries{target: target, name: name, values: []mackerel.MetricValue{{Time: v.Time, Value: v.Value}}})
+		}
+	}
+	return series
+}
+
+// metricRange resolves --from, --to and --duration into epoch seconds.
+func metricRange(c *cli.Context, now time.Time) (int64, int64, error) {
+	to := now
+	if s := c.String("to"); s != "" {
+		var err error
+		if to, err = parseAlertTime(s, now); err != nil {
+			return 0, 0, err
+		}
+	}
+	from := to.Add(-time.Hour)
+	switch {
+	case c.String("from") != "":
+		var err error
+		if from, err = parseAlertTime(c.String("from"), now); err != nil {
+			return 0, 0, err
+		}
+	case c.String("duration") != "":
+		seconds, err := parseWidgetPeriod(c.String("duration"))
+		if err != nil {
+			return 0, 0, err
+		}
+		from = to.Add(-time.Duration(seconds) * time.Second)
+	}
+	if !from.Before(to) {
+		return 0, 0, fmt.Errorf("--from should be before --to")
+	}
+	return from.Unix(), to.Unix(), nil
+}
+
+var metricAggregators = map[string]func([]float64) float64{
+	"avg": func(xs []float64) float64 {
+		var sum float64
+		for _, x := range xs {
+			sum += x
+		}
+		return sum / float64(len(xs))
+	},
+	"max": func(xs []float64) float64 {
+		m := xs[0]
+		for _, x := range xs[1:] {
+			if x > m {
+				m = x
+			}
+		}
+		return m
+	},
+	"min": func(xs []float64) float64 {
+		m := xs[0]
+		for _, x := range xs[1:] {
+			if x < m {
+				m = x
+			}
+		}
+		return m
+	},
+}
+
+// downsampleMetricValues aggregates the values in each step, which is timestamped with the start of the step.
+func downsampleMetricValues(values []mackerel.MetricValue, step int64, agg func([]float64) float64) []mackerel.MetricValue {
+	buckets := map[int64][]float64{}
+	var times []int64
+	for _, v := range values {
+		f, ok := metricValueFloat(v.Value)
+		if !ok {
+			continue
+		}
+		t := v.Time - v.Time%step
+		if _, ok := buckets[t]; !ok {
+			times = append(times, t)
+		}
+		buckets[t] = append(buckets[t], f)
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+	downsampled := make([]mackerel.MetricValue, 0, len(times))
+	for _, t := range times {
+		downsampled = append(downsampled, mackerel.MetricValue{Time: t, Value: agg(buckets[t])})
+	}
+	return downsampled
+}
+
+func printMetricSeries(w io.Writer, series []*metricSeries, output string) error {
+	switch output {
+	case "", "json":
+		m := map[string]map[string][]mackerel.MetricValue{}
+		for _, s := range series {
+			if m[s.target] == nil {
+				m[s.target] = map[string][]mackerel.MetricValue{}
+			}
+			m[s.target][s.name] = s.values
+		}
+		return format.PrettyPrintJSON(w, m)
+	case "csv", "tsv":
+		cw := csv.NewWriter(w)
+		if output == "tsv" {
+			cw.Comma = '\t'
+		}
+		cw.Write([]string{"target", "name", "time", "value"})
+		for _, s := range series {
+			for _, v := range s.values {
+				cw.Write([]string{s.target, s.name, strconv.FormatInt(v.Time, 10), fmt.Sprint(v.Value)})
+			}
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+	return fmt.Errorf("output should be 'json', 'csv' or 'tsv': %s", output)
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/urfave/cli"
+)
+
+func TestDownsampleMetricValues(t *testing.T) {
+	values := []mackerel.MetricValue{
+		{Time: 1000, Value: 1.0},
+		{Time: 1060, Value: 3.0},
+		{Time: 1320, Value: 5.0},
+		{Time: 1200, Value: 2.0},
+	}
+	testCases := []struct {
+		agg      string
+		expected []mackerel.MetricValue
+	}{
+		{"avg", []mackerel.MetricValue{{Time: 900, Value: 2.0}, {Time: 1200, Value: 3.5}}},
+		{"max", []mackerel.MetricValue{{Time: 900, Value: 3.0}, {Time: 1200, Value: 5.0}}},
+		{"min", []mackerel.MetricValue{{Time: 900, Value: 1.0}, {Time: 1200, Value: 2.0}}},
+	}
+	for _, tc := range testCases {
+		got := downsampleMetricValues(values, 300, metricAggregators[tc.agg])
+		if !reflect.DeepEqual(got, tc.expected) {
+			t.Errorf("%s: values should be %+v but: %+v", tc.agg, tc.expected, got)
+		}
+	}
+}
+
+func TestMetricRange(t *testing.T) {
+	now := time.Unix(100000, 0)
+	testCases := []struct {
+		args     []string
+		from, to int64
+		hasError bool
+	}{
+		{[]string{"--from", "1h"}, 96400, 100000, false},
+		{[]string{"--from", "90000", "--to", "95000"}, 90000, 95000, false},
+		{[]string{"--duration", "30m", "--to", "95000"}, 93200, 95000, false},
+		{[]string{}, 96400, 100000, false},
+		{[]string{"--from", "95000", "--to", "90000"}, 0, 0, true},
+	}
+	for _, tc := range testCases {
+		set := flag.NewFlagSet("fetch", flag.ContinueOnError)
+		set.String("from", "", "")
+		set.String("to", "", "")
+		set.String("duration", "", "")
+		set.Parse(tc.args)
+		from, to, err := metricRange(cli.NewContext(nil, set, nil), now)
+		if tc.hasError {
+			if err == nil {
+				t.Errorf("%v: err should occur", tc.args)
+			}
+			continue
+		}
+		if err != nil || from != tc.from || to != tc.to {
+			t.Errorf("%v: range should be %d-%d but: %d-%d (%v)", tc.args, tc.from, tc.to, from, to, err)
+		}
+	}
+}
+
+func TestPrintMetricSeries(t *testing.T) {
+	latest := mackerel.LatestMetricValues{
+		"host1": {"loadavg5": {Name: "loadavg5", Time: 1000, Value: 0.5}},
+		"host2": {"loadavg5": nil},
+	}
+	series := latestMetricSeries(latest, []string{"host1", "host2"}, []string{"loadavg5"})
+	out := new(bytes.Buffer)
+	if err := printMetricSeries(out, series, "csv"); err != nil {
+		t.Fatal(err)
+	}
+	if expected := "target,name,time,value\nhost1,loadavg5,1000,0.5\n"; out.String() != expected {
+		t.Errorf("output should be %q but: %q", expected, out.String())
+	}
+
+	out.Reset()
+	if err := printMetricSeries(out, series, "json"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `{
+    "host1": {
+        "loadavg5": [
+            {
+                "time": 1000,
+                "value": 0.5
+            }
+        ]
+    }
+}
+`
+	if out.String() != expected {
+		t.Errorf("output should be %q but: %q", expected, out.String())
+	}
+
+	if err := printMetricSeries(out, series, "xml"); err == nil {
+		t.Errorf("err should occur for unknown output")
+	}
+}
+
+type fakeServiceMetricFetcher struct {
+	values map[string][]mackerel.MetricValue
+}
+
+func (f *fakeServiceMetricFetcher) FetchServiceMetricValues(serviceName string, metricName string, from int64, to int64) ([]mackerel.MetricValue, error) {
+	return f.values[serviceName+"/"+metricName], nil
+}
+
+func TestFetchServiceMetricSeries(t *testing.T) {
+	fetcher := &fakeServiceMetricFetcher{values: map[string][]mackerel.MetricValue{
+		"Blog/access.count": {{Time: 1060, Value: 3.0}, {Time: 1120, Value: 5.0}, {Time: 1000, Value: 1.0}},
+	}}
+	series, err := fetchServiceMetricSeries(fetcher, "Blog", []string{"access.count", "error.count"}, 900, 1200)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(series) != 2 || series[0].target != "Blog" || len(series[0].values) != 3 {
+		t.Errorf("series should be fetched for each metric: %+v", series)
+	}
+
+	last := lastMetricValues(series)
+	expected := []*metricSeries{{target: "Blog", name: "access.count", values: []mackerel.MetricValue{{Time: 1120, Value: 5.0}}}}
+	if !reflect.DeepEqual(last, expected) {
+		t.Errorf("last values should be %+v but: %+v", expected, last)
+	}
+}
+
+type fakeLatestMetricValuesFetcher struct {
+	mu       sync.Mutex
+	requests int
+}
+
+func (f *fakeLatestMetricValuesFetcher) FetchLatestMetricValues(hostIDs []string, metricNames []string) (mackerel.LatestMetricValues, error) {
+	f.mu.Lock()
+	f.requests++
+	f.mu.Unlock()
+	latest := mackerel.LatestMetricValues{}
+	for _, hostID := range hostIDs {
+		latest[hostID] = map[string]*mackerel.MetricValue{}
+		for i, name := range metricNames {
+			latest[hostID][name] = &mackerel.MetricValue{Name: name, Time: 1000, Value: float64(i)}
+		}
+	}
+	return latest, nil
+}
+
+func TestFetchLatestHostMetricValues(t *testing.T) {
+	var hostIDs []string
+	for i := 0; i < 250; i++ {
+		hostIDs = append(hostIDs, fmt.Sprintf("host%d", i))
+	}
+	fetcher := &fakeLatestMetricValuesFetcher{}
+	latest, err := fetchLatestHostMetricValues(fetcher, hostIDs, []string{"loadavg5"}, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fetcher.requests != 3 {
+		t.Errorf("the hosts should be fetched in 3 requests but: %d", fetcher.requests)
+	}
+	if len(latest) != 250 {
+		t.Errorf("the values of all the hosts should be fetched but: %d", len(latest))
+	}
+}
+
+func TestLatestMetricTable(t *testing.T) {
+	latest := mackerel.LatestMetricValues{
+		"host1": {"loadavg5": {Name: "loadavg5", Time: 1000, Value: 0.5}, "cpu.user.percentage": nil},
+	}
+	o, _ := format.ParseOutput(format.OutputTSV)
+	out := new(bytes.Buffer)
+	err := o.Print(out, nil, func() *format.Table {
+		return latestMetricTable(latest, []string{"host1", "host2"}, map[string]string{"host1": "app001"}, []string{"loadavg5", "cpu.user.percentage"})
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := "HOST_ID\tNAME\tloadavg5\tcpu.user.percentage\nhost1\tapp001\t0.5\t-\nhost2\t\t-\t-\n"
+	if out.String() != expected {
+		t.Errorf("output should be %q but: %q", expected, out.String())
+	}
+}
@@ -24,7 +24,16 @@ type Host struct {
 }
 
 // PrettyPrintJSON output indented json via stdout.
+// The results of the query are printed instead if it is set by SetQuery.
 func PrettyPrintJSON(outStream io.Writer, src interface{}) error {
+	if query != nil {
+		return printQuery(outStream, query, src)
+	}
+	return WriteIndentJSON(outStream, src)
+}
+
+// WriteIndentJSON writes indented json regardless of the query, which is used to save files.
+func WriteIndentJSON(outStream io.Writer, src interface{}) error {
 	_, err := fmt.Fprintln(outStream, JSONMarshalIndent(src, "", "    "))
 	return err
 }
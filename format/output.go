@@ -0,0 +1,152 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+	"text/template"
+
+	"github.com/urfave/cli"
+)
+
+// The output formats supported by Output
+const (
+	OutputJSON       = "json"
+	OutputYAML       = "yaml"
+	OutputTable      = "table"
+	OutputTSV        = "tsv"
+	OutputGoTemplate = "go-template"
+)
+
+// OutputFormats are the output formats supported by Output
+var OutputFormats = []string{OutputJSON, OutputYAML, OutputTable, OutputTSV, OutputGoTemplate}
+
+// OutputUsage is the usage of --output option of the commands using Output
+const OutputUsage = "Output format: 'json', 'yaml', 'table', 'tsv' or 'go-template=<template>'"
+
+// Output renders values in the format specified by --output option
+type Output struct {
+	Format   string
+	template *template.Template
+}
+
+// ParseOutput parses the output format. The template of go-template is
+// executed with the JSON representation of the value, e.g. go-template='{{range .}}{{.id}}{{"\n"}}{{end}}'.
+func ParseOutput(s string) (*Output, error) {
+	if strings.HasPrefix(s, OutputGoTemplate+"=") {
+		t, err := template.New("output").Parse(strings.TrimPrefix(s, OutputGoTemplate+"="))
+		if err != nil {
+			return nil, err
+		}
+		return &Output{Format: OutputGoTemplate, template: t}, nil
+	}
+	switch s {
+	case OutputJSON, OutputYAML, OutputTable, OutputTSV:
+		return &Output{Format: s}, nil
+	}
+	return nil, fmt.Errorf("output should be 'json', 'yaml', 'table', 'tsv' or 'go-template=<template>': %s", s)
+}
+
+// defaultOutput is the default output format of the profile
+var defaultOutput string
+
+// SetDefaultOutput sets the default output format of the profile, which is used by OutputName.
+func SetDefaultOutput(s string) {
+	defaultOutput = s
+}
+
+// OutputName returns the output format of the command in the order of precedence:
+// --output option of the command, global --output option, the default output format
+// of the profile set by SetDefaultOutput if the command supports it, and defaultFormat.
+func OutputName(c *cli.Context, defaultFormat string, supported ...string) string {
+	if c.IsSet("output") {
+		return c.String("output")
+	}
+	if c.GlobalIsSet("output") {
+		return c.GlobalString("output")
+	}
+	if defaultOutput != "" {
+		name := strings.SplitN(defaultOutput, "=", 2)[0]
+		for _, s := range supported {
+			if s == name {
+				return defaultOutput
+			}
+		}
+	}
+	return defaultFormat
+}
+
+// OutputFromContext returns the Output of the command. See OutputName for the precedence.
+func OutputFromContext(c *cli.Context, defaultFormat string) (*Output, error) {
+	return ParseOutput(OutputName(c, defaultFormat, OutputFormats...))
+}
+
+// Print writes v in the output format. The table is built only for table and tsv,
+// and these formats are not supported if table is nil.
+func (o *Output) Print(w io.Writer, v interface{}, table func() *Table) error {
+	switch o.Format {
+	case OutputJSON:
+		return PrettyPrintJSON(w, v)
+	case OutputYAML:
+		data, err := YAMLMarshal(v)
+		if err != nil {
+			return err
+		}
+		_, err = w.Write(data)
+		return err
+	case OutputGoTemplate:
+		data, err := json.Marshal(v)
+		if err != nil {
+			return err
+		}
+		dec := json.NewDecoder(bytes.NewReader(data))
+		dec.UseNumber()
+		var x interface{}
+		if err := dec.Decode(&x); err != nil {
+			return err
+		}
+		return o.template.Execute(w, x)
+	case OutputTable, OutputTSV:
+		if table == nil {
+			return fmt.Errorf("output '%s' is not supported by this command", o.Format)
+		}
+		return table().print(w, o.Format == OutputTSV)
+	}
+	return fmt.Errorf("unknown output format: %s", o.Format)
+}
+
+// Table represents the rows of table and tsv output
+type Table struct {
+	header []string
+	rows   [][]string
+}
+
+// NewTable creates a table with the header
+func NewTable(header ...string) *Table {
+	return &Table{header: header}
+}
+
+// Append appends a row to the table
+func (t *Table) Append(values ...string) {
+	t.rows = append(t.rows, values)
+}
+
+func (t *Table) print(w io.Writer, tsv bool) error {
+	out := w
+	var tw *tabwriter.Writer
+	if !tsv {
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		out = tw
+	}
+	fmt.Fprintln(out, strings.Join(t.header, "\t"))
+	for _, row := range t.rows {
+		fmt.Fprintln(out, strings.Join(row, "\t"))
+	}
+	if tw != nil {
+		return tw.Flush()
+	}
+	return nil
+}
@@ -0,0 +1,172 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/urfave/cli"
+)
+
+type outputTestItem struct {
+	ID    string   `json:"id"`
+	Names []string `json:"names,omitempty"`
+	Time  int64    `json:"time"`
+}
+
+var outputTestItems = []*outputTestItem{
+	{ID: "abc", Names: []string{"foo", "bar"}, Time: 1552992837},
+	{ID: "defgh", Time: 1552992838},
+}
+
+func outputTestTable() *Table {
+	t := NewTable("ID", "NAMES")
+	for _, item := range outputTestItems {
+		names := ""
+		for i, name := range item.Names {
+			if i > 0 {
+				names += ","
+			}
+			names += name
+		}
+		t.Append(item.ID, names)
+	}
+	return t
+}
+
+func TestOutput_Print(t *testing.T) {
+	testCases := []struct {
+		output   string
+		expected string
+	}{
+		{
+			output: "json",
+			expected: `[
+    {
+        "id": "abc",
+        "names": [
+            "foo",
+            "bar"
+        ],
+        "time": 1552992837
+    },
+    {
+        "id": "defgh",
+        "time": 1552992838
+    }
+]
+`,
+		},
+		{
+			output: "yaml",
+			expected: `- id: abc
+  names:
+  - foo
+  - bar
+  time: 1552992837
+- id: defgh
+  time: 1552992838
+`,
+		},
+		{
+			output: "table",
+			expected: `ID     NAMES
+abc    foo,bar
+defgh  
+`,
+		},
+		{
+			output:   "tsv",
+			expected: "ID\tNAMES\nabc\tfoo,bar\ndefgh\t\n",
+		},
+		{
+			output:   `go-template={{range .}}{{.id}}={{.time}}{{"\n"}}{{end}}`,
+			expected: "abc=1552992837\ndefgh=1552992838\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.output, func(t *testing.T) {
+			o, err := ParseOutput(tc.output)
+			if err != nil {
+				t.Fatal(err)
+			}
+			var buf bytes.Buffer
+			if err := o.Print(&buf, outputTestItems, outputTestTable); err != nil {
+				t.Fatal(err)
+			}
+			if buf.String() != tc.expected {
+				t.Errorf("output should be:\n%s\nbut got:\n%s", tc.expected, buf.String())
+			}
+		})
+	}
+}
+
+func TestOutput_Print_noTable(t *testing.T) {
+	o, _ := ParseOutput("table")
+	if err := o.Print(new(bytes.Buffer), outputTestItems, nil); err == nil {
+		t.Error("table output without the table should be an error")
+	}
+}
+
+func TestParseOutput(t *testing.T) {
+	for _, s := range []string{"", "csv", "go-template={{.id"} {
+		if _, err := ParseOutput(s); err == nil {
+			t.Errorf("ParseOutput(%q) should be an error", s)
+		}
+	}
+}
+
+func TestOutputName(t *testing.T) {
+	defer SetDefaultOutput("")
+
+	testCases := []struct {
+		args     []string
+		expected string
+	}{
+		{[]string{"mkr", "list"}, "table"},
+		{[]string{"mkr", "list", "-o", "json"}, "json"},
+		{[]string{"mkr", "--output", "tsv", "list"}, "tsv"},
+		{[]string{"mkr", "--output", "tsv", "list", "-o", "json"}, "json"},
+		{[]string{"mkr", "--profile", "yaml", "list"}, "yaml"},
+		{[]string{"mkr", "--profile", "yaml", "--output", "json", "list"}, "json"},
+		// the default output of the profile is ignored if the command does not support it
+		{[]string{"mkr", "--profile", "yaml", "text"}, "text"},
+	}
+	for _, tc := range testCases {
+		// the default output of the profile is set by mkr on starting
+		SetDefaultOutput("")
+		if tc.args[1] == "--profile" {
+			SetDefaultOutput("yaml")
+		}
+		var got string
+		app := cli.NewApp()
+		app.Flags = []cli.Flag{
+			cli.StringFlag{Name: "conf", Value: "testdata/not-found.conf"},
+			cli.StringFlag{Name: "profile"},
+			cli.StringFlag{Name: "output"},
+		}
+		app.Commands = []cli.Command{
+			{
+				Name:  "list",
+				Flags: []cli.Flag{cli.StringFlag{Name: "output, o", Value: "table"}},
+				Action: func(c *cli.Context) error {
+					got = OutputName(c, "table", OutputFormats...)
+					return nil
+				},
+			},
+			{
+				Name:  "text",
+				Flags: []cli.Flag{cli.StringFlag{Name: "output, o", Value: "text"}},
+				Action: func(c *cli.Context) error {
+					got = OutputName(c, "text", "text", "json")
+					return nil
+				},
+			},
+		}
+		if err := app.Run(tc.args); err != nil {
+			t.Fatal(err)
+		}
+		if got != tc.expected {
+			t.Errorf("output of %v should be %s but got %s", tc.args, tc.expected, got)
+		}
+	}
+}
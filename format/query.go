@@ -0,0 +1,89 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/itchyny/gojq"
+)
+
+// query is the jq expression applied to the JSON output of PrettyPrintJSON
+var query *gojq.Code
+
+// SetQuery sets the jq expression applied to the JSON output, such as '.[].id'.
+// An empty expression is ignored.
+func SetQuery(q string) error {
+	if q == "" {
+		return nil
+	}
+	parsed, err := gojq.Parse(q)
+	if err != nil {
+		return fmt.Errorf("failed to parse the query %q: %s", q, err)
+	}
+	code, err := gojq.Compile(parsed)
+	if err != nil {
+		return fmt.Errorf("failed to compile the query %q: %s", q, err)
+	}
+	query = code
+	return nil
+}
+
+// printQuery prints the results of the query applied to the JSON representation of src.
+// The strings are printed as they are, and the others are printed in indented JSON.
+func printQuery(w io.Writer, code *gojq.Code, src interface{}) error {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return err
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return err
+	}
+	iter := code.Run(normalizeNumbers(v))
+	for {
+		x, ok := iter.Next()
+		if !ok {
+			return nil
+		}
+		switch x := x.(type) {
+		case error:
+			return x
+		case string:
+			_, err = fmt.Fprintln(w, x)
+		default:
+			_, err = fmt.Fprintln(w, JSONMarshalIndent(x, "", "    "))
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// normalizeNumbers converts json.Number into int or float64 which gojq can handle,
+// keeping integers such as epoch seconds from being printed in exponential notation.
+func normalizeNumbers(v interface{}) interface{} {
+	switch v := v.(type) {
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return int(i)
+		}
+		f, _ := v.Float64()
+		return f
+	case map[string]interface{}:
+		for k, x := range v {
+			v[k] = normalizeNumbers(x)
+		}
+		return v
+	case []interface{}:
+		for i, x := range v {
+			v[i] = normalizeNumbers(x)
+		}
+		return v
+	default:
+		return v
+	}
+}
@@ -0,0 +1,79 @@
+package format
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestPrettyPrintJSON_query(t *testing.T) {
+	testCases := []struct {
+		query    string
+		expected string
+	}{
+		{
+			query: ".[].id",
+			expected: `abc
+defgh
+`,
+		},
+		{
+			query: ".[0].time",
+			expected: `1552992837
+`,
+		},
+		{
+			query: "map({id, count: (.names | length)})",
+			expected: `[
+    {
+        "count": 2,
+        "id": "abc"
+    },
+    {
+        "count": 0,
+        "id": "defgh"
+    }
+]
+`,
+		},
+	}
+	defer func() { query = nil }()
+	for _, tc := range testCases {
+		if err := SetQuery(tc.query); err != nil {
+			t.Fatalf("SetQuery(%q) should not raise an error: %s", tc.query, err)
+		}
+		out := new(bytes.Buffer)
+		if err := PrettyPrintJSON(out, outputTestItems); err != nil {
+			t.Fatalf("PrettyPrintJSON with the query %q should not raise an error: %s", tc.query, err)
+		}
+		if out.String() != tc.expected {
+			t.Errorf("the output with the query %q should be %q but got: %q", tc.query, tc.expected, out.String())
+		}
+	}
+}
+
+func TestPrettyPrintJSON_queryError(t *testing.T) {
+	defer func() { query = nil }()
+	if err := SetQuery(".[] |"); err == nil {
+		t.Errorf("SetQuery should raise an error for an invalid query")
+	}
+	if err := SetQuery(".[].id | ascii_downcase | error"); err != nil {
+		t.Fatalf("SetQuery should not raise an error: %s", err)
+	}
+	if err := PrettyPrintJSON(new(bytes.Buffer), outputTestItems); err == nil {
+		t.Errorf("PrettyPrintJSON should raise the error of the query")
+	}
+}
+
+func TestWriteIndentJSON_query(t *testing.T) {
+	defer func() { query = nil }()
+	if err := SetQuery(".[].id"); err != nil {
+		t.Fatalf("SetQuery should not raise an error: %s", err)
+	}
+	out := new(bytes.Buffer)
+	if err := WriteIndentJSON(out, []string{"abc"}); err != nil {
+		t.Fatalf("WriteIndentJSON should not raise an error: %s", err)
+	}
+	if expected := "[\n    \"abc\"\n]\n"; out.String() != expected {
+		t.Errorf("WriteIndentJSON should ignore the query, expected %q but got: %q", expected, out.String())
+	}
+}
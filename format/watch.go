@@ -0,0 +1,48 @@
+package format
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/urfave/cli"
+)
+
+// WatchFlags are the flags of the commands which can re-render the output on an interval
+var WatchFlags = []cli.Flag{
+	cli.BoolFlag{Name: "watch", Usage: "Clear and re-render the output on every --interval"},
+	cli.DurationFlag{Name: "interval", Value: 15 * time.Second, Usage: "The interval of --watch"},
+}
+
+// WatchFromContext prints the output of render to w once, or on every --interval until interrupted with --watch.
+// The errors are printed on the screen in the watch mode, so that the watch survives the transient failures.
+func WatchFromContext(c *cli.Context, w io.Writer, render func(io.Writer) error) error {
+	if !c.Bool("watch") {
+		return render(w)
+	}
+	interval := c.Duration("interval")
+	if interval <= 0 {
+		return fmt.Errorf("--interval should be positive: %s", interval)
+	}
+	title := "mkr " + strings.Join(os.Args[1:], " ")
+	for {
+		if err := printWatchFrame(w, title, interval, time.Now(), render); err != nil {
+			return err
+		}
+		time.Sleep(interval)
+	}
+}
+
+// printWatchFrame clears the screen and prints the header and the output, like watch(1).
+// The output is buffered not to keep the screen blank while requesting the API.
+func printWatchFrame(w io.Writer, title string, interval time.Duration, now time.Time, render func(io.Writer) error) error {
+	buf := new(bytes.Buffer)
+	if err := render(buf); err != nil {
+		fmt.Fprintf(buf, "error: %s\n", err)
+	}
+	_, err := fmt.Fprintf(w, "\x1b[H\x1b[2JEvery %s: %s    %s\n\n%s", interval, title, now.Format(time.RFC3339), buf.String())
+	return err
+}
@@ -0,0 +1,45 @@
+package format
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"testing"
+	"time"
+)
+
+func TestPrintWatchFrame(t *testing.T) {
+	now := time.Date(2020, 6, 1, 12, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		name     string
+		render   func(io.Writer) error
+		expected string
+	}{
+		{
+			name: "output",
+			render: func(w io.Writer) error {
+				_, err := fmt.Fprintln(w, `{"status": "working"}`)
+				return err
+			},
+			expected: "\x1b[H\x1b[2JEvery 15s: mkr status    2020-06-01T12:00:00Z\n\n{\"status\": \"working\"}\n",
+		},
+		{
+			name: "error",
+			render: func(w io.Writer) error {
+				fmt.Fprintln(w, "partial")
+				return errors.New("API request failed")
+			},
+			expected: "\x1b[H\x1b[2JEvery 15s: mkr status    2020-06-01T12:00:00Z\n\npartial\nerror: API request failed\n",
+		},
+	}
+	for _, tc := range testCases {
+		out := new(bytes.Buffer)
+		if err := printWatchFrame(out, "mkr status", 15*time.Second, now, tc.render); err != nil {
+			t.Fatalf("%s: printWatchFrame should not raise an error: %s", tc.name, err)
+		}
+		if out.String() != tc.expected {
+			t.Errorf("%s: the frame should be %q but got %q", tc.name, tc.expected, out.String())
+		}
+	}
+}
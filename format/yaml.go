@@ -0,0 +1,69 @@
+package format
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// YAMLMarshal encodes `src` into YAML using the keys of its JSON representation,
+// so that the structures defined in mackerel-client-go can be written in YAML.
+func YAMLMarshal(src interface{}) ([]byte, error) {
+	data, err := json.Marshal(src)
+	if err != nil {
+		return nil, err
+	}
+	// decode numbers as json.Number to keep large integers (e.g. epoch seconds) as they are
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	var v interface{}
+	if err := dec.Decode(&v); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(v)
+}
+
+// YAMLToJSON converts YAML into JSON.
+func YAMLToJSON(data []byte) ([]byte, error) {
+	var v interface{}
+	if err := yaml.Unmarshal(data, &v); err != nil {
+		return nil, err
+	}
+	v, err := convertYAMLMap(v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+// convertYAMLMap replaces map[interface{}]interface{} decoded by yaml.v2,
+// which cannot be encoded by encoding/json, with map[string]interface{}.
+func convertYAMLMap(v interface{}) (interface{}, error) {
+	switch v := v.(type) {
+	case map[interface{}]interface{}:
+		m := make(map[string]interface{}, len(v))
+		for k, val := range v {
+			key, ok := k.(string)
+			if !ok {
+				key = fmt.Sprint(k)
+			}
+			var err error
+			if m[key], err = convertYAMLMap(val); err != nil {
+				return nil, err
+			}
+		}
+		return m, nil
+	case []interface{}:
+		for i, val := range v {
+			var err error
+			if v[i], err = convertYAMLMap(val); err != nil {
+				return nil, err
+			}
+		}
+		return v, nil
+	default:
+		return v, nil
+	}
+}
@@ -0,0 +1,40 @@
+package format
+
+import (
+	"testing"
+)
+
+func TestYAMLMarshal(t *testing.T) {
+	src := struct {
+		ID    string   `json:"id"`
+		Names []string `json:"names,omitempty"`
+		Empty string   `json:"empty,omitempty"`
+		Time  int64    `json:"time"`
+		Value float64  `json:"value"`
+	}{
+		ID:    "abcde",
+		Names: []string{"foo", "bar"},
+		Time:  1552992837,
+		Value: 0.5,
+	}
+	expect := "id: abcde\nnames:\n- foo\n- bar\ntime: 1552992837\nvalue: 0.5\n"
+	got, err := YAMLMarshal(src)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != expect {
+		t.Errorf("should be %q got %q", expect, string(got))
+	}
+}
+
+func TestYAMLToJSON(t *testing.T) {
+	src := "id: abcde\nlayout:\n  x: 0\n  width: 24\nnames:\n- foo\n- bar\n"
+	expect := `{"id":"abcde","layout":{"width":24,"x":0},"names":["foo","bar"]}`
+	got, err := YAMLToJSON([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(got) != expect {
+		t.Errorf("should be %q got %q", expect, string(got))
+	}
+}
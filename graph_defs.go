@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandGraphDefs = cli.Command{
+	Name:  "graph-defs",
+	Usage: "Manipulate graph definitions",
+	Description: `
+    Manipulate the graph definitions of the custom metrics. With no subcommand specified, this will show all of subcommands.
+    The graph definitions cannot be pulled since the API does not support listing them.
+    See https://mackerel.io/api-docs/entry/host-metrics#post-graphdef .
+`,
+	Subcommands: []cli.Command{
+		{
+			Name:      "push",
+			Usage:     "Push graph definitions",
+			ArgsUsage: "--file-path | -F <file> [--dry-run | -d]",
+			Description: `
+    Create or update the graph definitions in the JSON or YAML file, which is an array of the graph definitions such as
+    [{"name": "custom.queue", "displayName": "Queue", "unit": "integer", "metrics": [{"name": "custom.queue.*", "isStacked": true}]}].
+    Requests "POST /api/v0/graph-defs/create".
+`,
+			Action: doGraphDefsPush,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "The JSON or YAML file of the graph definitions"},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Validate and show the graph definitions, but not push them"},
+			},
+		},
+	},
+}
+
+// hostGraphDefPrefix is the prefix of the graph names of the custom host metrics
+const hostGraphDefPrefix = "custom."
+
+var graphDefUnits = []string{"float", "integer", "percentage", "seconds", "milliseconds", "bytes", "bytes/sec", "bits/sec", "iops"}
+
+func loadGraphDefs(filePath string) ([]*mackerel.GraphDefsParam, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is also accepted since it is a subset of YAML
+	if data, err = format.YAMLToJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	var graphDefs []*mackerel.GraphDefsParam
+	if err := json.Unmarshal(data, &graphDefs); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	return graphDefs, nil
+}
+
+// validateGraphDefs checks the graph definitions whose names should begin with namePrefix,
+// such as "custom." for the custom host metrics. The metric names should begin with the graph name.
+func validateGraphDefs(graphDefs []*mackerel.GraphDefsParam, namePrefix string) error {
+	if len(graphDefs) == 0 {
+		return fmt.Errorf("no graph definitions")
+	}
+	names := map[string]bool{}
+	for _, g := range graphDefs {
+		if g.Name == "" {
+			return fmt.Errorf("the graph name is empty")
+		}
+		if !strings.HasPrefix(g.Name, namePrefix) {
+			return fmt.Errorf("the graph name should begin with '%s': %q", namePrefix, g.Name)
+		}
+		if names[g.Name] {
+			return fmt.Errorf("the graph %s is duplicated", g.Name)
+		}
+		names[g.Name] = true
+		if g.Unit != "" && !containsString(graphDefUnits, g.Unit) {
+			return fmt.Errorf("the unit of the graph %s should be one of %s: %s", g.Name, strings.Join(graphDefUnits, ", "), g.Unit)
+		}
+		if len(g.Metrics) == 0 {
+			return fmt.Errorf("the graph %s has no metrics", g.Name)
+		}
+		for _, m := range g.Metrics {
+			if !strings.HasPrefix(m.Name, g.Name+".") {
+				return fmt.Errorf("the metric name of the graph %s should begin with '%s.': %s", g.Name, g.Name, m.Name)
+			}
+		}
+	}
+	return nil
+}
+
+func doGraphDefsPush(c *cli.Context) error {
+	filePath := c.String("file-path")
+	if filePath == "" {
+		cli.ShowCommandHelp(c, "push")
+		os.Exit(1)
+	}
+	graphDefs, err := loadGraphDefs(filePath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	if err := validateGraphDefs(graphDefs, hostGraphDefPrefix); err != nil {
+		return cli.NewExitError(fmt.Sprintf("%s: %s", filePath, err), 1)
+	}
+	if c.Bool("dry-run") {
+		format.PrettyPrintJSON(os.Stdout, graphDefs)
+		return nil
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	logger.DieIf(client.CreateGraphDefs(graphDefs))
+	logger.Log("info", fmt.Sprintf("Graph definitions are pushed (%d graphs).", len(graphDefs)))
+	return nil
+}
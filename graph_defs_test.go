@@ -0,0 +1,89 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestLoadGraphDefs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-graph-defs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "graphdefs.yaml")
+	if err := ioutil.WriteFile(filePath, []byte(`
+- name: custom.queue
+  displayName: Queue
+  unit: integer
+  metrics:
+    - name: custom.queue.*
+      isStacked: true
+`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	graphDefs, err := loadGraphDefs(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []*mackerel.GraphDefsParam{{
+		Name:        "custom.queue",
+		DisplayName: "Queue",
+		Unit:        "integer",
+		Metrics:     []*mackerel.GraphDefsMetric{{Name: "custom.queue.*", IsStacked: true}},
+	}}
+	if !reflect.DeepEqual(graphDefs, want) {
+		t.Errorf("graph defs should be %+v but got %+v", want, graphDefs)
+	}
+}
+
+func TestValidateGraphDefs(t *testing.T) {
+	metrics := []*mackerel.GraphDefsMetric{{Name: "custom.queue.size"}}
+	testCases := []struct {
+		graphDefs []*mackerel.GraphDefsParam
+		err       string
+	}{
+		{
+			graphDefs: []*mackerel.GraphDefsParam{{Name: "custom.queue", Unit: "bytes/sec", Metrics: metrics}},
+		},
+		{
+			graphDefs: []*mackerel.GraphDefsParam{},
+			err:       "no graph definitions",
+		},
+		{
+			graphDefs: []*mackerel.GraphDefsParam{{Name: "queue", Metrics: metrics}},
+			err:       `the graph name should begin with 'custom.': "queue"`,
+		},
+		{
+			graphDefs: []*mackerel.GraphDefsParam{{Name: "custom.queue", Metrics: metrics}, {Name: "custom.queue", Metrics: metrics}},
+			err:       "the graph custom.queue is duplicated",
+		},
+		{
+			graphDefs: []*mackerel.GraphDefsParam{{Name: "custom.queue", Unit: "count", Metrics: metrics}},
+			err:       "the unit of the graph custom.queue should be one of float, integer, percentage, seconds, milliseconds, bytes, bytes/sec, bits/sec, iops: count",
+		},
+		{
+			graphDefs: []*mackerel.GraphDefsParam{{Name: "custom.queue"}},
+			err:       "the graph custom.queue has no metrics",
+		},
+		{
+			graphDefs: []*mackerel.GraphDefsParam{{Name: "custom.queue", Metrics: []*mackerel.GraphDefsMetric{{Name: "custom.queues.size"}}}},
+			err:       "the metric name of the graph custom.queue should begin with 'custom.queue.': custom.queues.size",
+		},
+	}
+	for _, tc := range testCases {
+		err := validateGraphDefs(tc.graphDefs, hostGraphDefPrefix)
+		if tc.err == "" {
+			if err != nil {
+				t.Errorf("unexpected error: %s", err)
+			}
+		} else if err == nil || err.Error() != tc.err {
+			t.Errorf("error should be %q but got %v", tc.err, err)
+		}
+	}
+}
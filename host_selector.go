@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// the statuses of the hosts which are selected by default. The API returns only working and standby hosts without statuses.
+var allHostStatuses = []string{"working", "standby", "maintenance", "poweroff"}
+
+type hostSelector struct {
+	service  string
+	roles    []string
+	statuses []string
+	// selects the hosts created before it if not zero
+	createdBefore time.Time
+}
+
+func (s *hostSelector) isEmpty() bool {
+	return s.service == "" && len(s.roles) == 0 && len(s.statuses) == 0 && s.createdBefore.IsZero()
+}
+
+type hostFinder interface {
+	FindHosts(param *mackerel.FindHostsParam) ([]*mackerel.Host, error)
+}
+
+// selectHosts finds the hosts matching the selector.
+func selectHosts(client hostFinder, s *hostSelector) ([]*mackerel.Host, error) {
+	if len(s.roles) > 0 && s.service == "" {
+		return nil, fmt.Errorf("--role requires --service")
+	}
+	statuses := s.statuses
+	if len(statuses) == 0 {
+		statuses = allHostStatuses
+	}
+	hosts, err := client.FindHosts(&mackerel.FindHostsParam{
+		Service:  s.service,
+		Roles:    s.roles,
+		Statuses: statuses,
+	})
+	if err != nil || s.createdBefore.IsZero() {
+		return hosts, err
+	}
+	selected := make([]*mackerel.Host, 0, len(hosts))
+	for _, h := range hosts {
+		if int64(h.CreatedAt) < s.createdBefore.Unix() {
+			selected = append(selected, h)
+		}
+	}
+	return selected, nil
+}
+
+type latestMetricFetcher interface {
+	ListHostMetricNames(id string) ([]string, error)
+	FetchLatestMetricValues(hostIDs []string, metricNames []string) (mackerel.LatestMetricValues, error)
+}
+
+// freshnessMetricNames are the metrics posted by mackerel-agent every minute, which are checked by findFreshHosts
+var freshnessMetricNames = []string{"loadavg5", "cpu.user.percentage", "memory.used"}
+
+// findFreshHosts returns the IDs of the hosts which have posted any of freshnessMetricNames since the time.
+// The hosts are checked in batches to make a few requests even for hundreds of hosts.
+func findFreshHosts(client latestMetricValuesFetcher, hostIDs []string, since time.Time) ([]string, error) {
+	latest, err := fetchLatestHostMetricValues(client, hostIDs, freshnessMetricNames, 1)
+	if err != nil {
+		return nil, err
+	}
+	var fresh []string
+	for _, hostID := range hostIDs {
+		for _, v := range latest[hostID] {
+			if v != nil && v.Time >= since.Unix() {
+				fresh = append(fresh, hostID)
+				break
+			}
+		}
+	}
+	return fresh, nil
+}
+
+// mergeHostIDs appends the IDs of the hosts to ids without duplication.
+func mergeHostIDs(ids []string, hosts []*mackerel.Host) []string {
+	seen := map[string]bool{}
+	merged := make([]string, 0, len(ids)+len(hosts))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			merged = append(merged, id)
+		}
+	}
+	for _, h := range hosts {
+		if !seen[h.ID] {
+			seen[h.ID] = true
+			merged = append(merged, h.ID)
+		}
+	}
+	return merged
+}
+
+// describeHosts returns the lines of the hosts for confirmation prompts.
+func describeHosts(hosts []*mackerel.Host) string {
+	lines := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		lines = append(lines, fmt.Sprintf("%s %s (%s) [%s]", h.ID, h.Name, h.Status, strings.Join(h.GetRoleFullnames(), ", ")))
+	}
+	return strings.Join(lines, "\n  ")
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type fakeHostFinder struct {
+	param *mackerel.FindHostsParam
+	hosts []*mackerel.Host
+}
+
+func (f *fakeHostFinder) FindHosts(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+	f.param = param
+	return f.hosts, nil
+}
+
+func TestSelectHosts(t *testing.T) {
+	finder := &fakeHostFinder{hosts: []*mackerel.Host{{ID: "host1"}}}
+	hosts, err := selectHosts(finder, &hostSelector{service: "MyApp", roles: []string{"db"}})
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if len(hosts) != 1 {
+		t.Errorf("hosts should be selected: %v", hosts)
+	}
+	expect := &mackerel.FindHostsParam{Service: "MyApp", Roles: []string{"db"}, Statuses: allHostStatuses}
+	if !reflect.DeepEqual(finder.param, expect) {
+		t.Errorf("param should be %+v but: %+v", expect, finder.param)
+	}
+
+	if _, err := selectHosts(finder, &hostSelector{roles: []string{"db"}}); err == nil {
+		t.Errorf("err should occur when --role is specified without --service")
+	}
+}
+
+func TestMergeHostIDs(t *testing.T) {
+	hosts := []*mackerel.Host{{ID: "host2"}, {ID: "host3"}}
+	ids := mergeHostIDs([]string{"host1", "host2"}, hosts)
+	expect := []string{"host1", "host2", "host3"}
+	if !reflect.DeepEqual(ids, expect) {
+		t.Errorf("ids should be %v but: %v", expect, ids)
+	}
+}
+
+func TestSelectHosts_createdBefore(t *testing.T) {
+	finder := &fakeHostFinder{hosts: []*mackerel.Host{{ID: "old", CreatedAt: 1000}, {ID: "new", CreatedAt: 3000}}}
+	hosts, err := selectHosts(finder, &hostSelector{createdBefore: time.Unix(2000, 0)})
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if len(hosts) != 1 || hosts[0].ID != "old" {
+		t.Errorf("only the old host should be selected: %v", hosts)
+	}
+}
+
+type fakeLatestMetricFetcher struct {
+	latest   mackerel.LatestMetricValues
+	requests int
+}
+
+func (f *fakeLatestMetricFetcher) ListHostMetricNames(id string) ([]string, error) {
+	var names []string
+	for name := range f.latest[id] {
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+func (f *fakeLatestMetricFetcher) FetchLatestMetricValues(hostIDs []string, metricNames []string) (mackerel.LatestMetricValues, error) {
+	f.requests++
+	latest := mackerel.LatestMetricValues{}
+	for _, id := range hostIDs {
+		latest[id] = map[string]*mackerel.MetricValue{}
+		for _, name := range metricNames {
+			if v, ok := f.latest[id][name]; ok {
+				latest[id][name] = v
+			}
+		}
+	}
+	return latest, nil
+}
+
+func TestFindFreshHosts(t *testing.T) {
+	fetcher := &fakeLatestMetricFetcher{latest: mackerel.LatestMetricValues{
+		"fresh": {"loadavg5": {Name: "loadavg5", Time: 1900}, "memory.used": {Name: "memory.used", Time: 1000}},
+		"stale": {"loadavg5": {Name: "loadavg5", Time: 1000}},
+		"empty": {},
+	}}
+	hostIDs := []string{"fresh", "stale", "empty"}
+	for i := 0; i < 150; i++ {
+		hostIDs = append(hostIDs, fmt.Sprintf("host%d", i))
+	}
+	fresh, err := findFreshHosts(fetcher, hostIDs, time.Unix(1800, 0))
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if !reflect.DeepEqual(fresh, []string{"fresh"}) {
+		t.Errorf("only the fresh host should be returned: %v", fresh)
+	}
+	if fetcher.requests != 2 {
+		t.Errorf("the hosts should be checked in batches but requested %d times", fetcher.requests)
+	}
+}
@@ -1,8 +1,10 @@
 package hosts
 
 import (
+	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"text/template"
 
 	"github.com/mackerelio/mackerel-client-go"
@@ -25,50 +27,176 @@ type hostApp struct {
 type findHostsParam struct {
 	verbose bool
 
-	name     string
-	service  string
-	roles    []string
-	statuses []string
+	name             string
+	service          string
+	roles            []string
+	statuses         []string
+	customIdentifier string
+	ips              []string
+	metas            []string
 
-	format string
+	format  string
+	output  string
+	columns string
 }
 
 func (ha *hostApp) findHosts(param findHostsParam) error {
-	hosts, err := ha.client.FindHosts(&mackerel.FindHostsParam{
-		Name:     param.name,
-		Service:  param.service,
-		Roles:    param.roles,
-		Statuses: param.statuses,
-	})
+	findParam := &mackerel.FindHostsParam{
+		Name:             param.name,
+		Service:          param.service,
+		Roles:            param.roles,
+		Statuses:         param.statuses,
+		CustomIdentifier: param.customIdentifier,
+	}
+	filter, err := newHostFilter(param.ips, param.metas)
+	if err != nil {
+		return err
+	}
+	var columns []string
+	var output *format.Output
+	switch param.output {
+	case "", "json":
+	case "jsonl":
+		return ha.streamHosts(findParam, filter, param.verbose)
+	case "table", "tsv":
+		if columns, err = parseHostColumns(param.columns); err != nil {
+			return err
+		}
+	default:
+		// yaml and go-template are rendered by the shared formatter
+		if output, err = format.ParseOutput(param.output); err != nil {
+			return fmt.Errorf("output should be 'json', 'jsonl', 'yaml', 'table', 'tsv' or 'go-template=<template>': %s", param.output)
+		}
+	}
+
+	found, err := ha.client.FindHosts(findParam)
 	if err != nil {
 		return err
 	}
+	hosts := make([]*mackerel.Host, 0, len(found))
+	for _, host := range found {
+		if filter.match(host) {
+			hosts = append(hosts, host)
+		}
+	}
 
 	switch {
+	case columns != nil:
+		return printHostsTable(ha.outStream, hosts, columns, param.output == "tsv")
 	case param.format != "":
 		t, err := template.New("format").Parse(param.format)
 		if err != nil {
 			return err
 		}
 		return t.Execute(ha.outStream, hosts)
+	case output != nil && param.verbose:
+		return output.Print(ha.outStream, hosts, nil)
 	case param.verbose:
 		return format.PrettyPrintJSON(ha.outStream, hosts)
+	}
+	var hostsFormat []*format.Host
+	for _, host := range hosts {
+		hostsFormat = append(hostsFormat, formatHost(host))
+	}
+	if output != nil {
+		return output.Print(ha.outStream, hostsFormat, nil)
+	}
+	return format.PrettyPrintJSON(ha.outStream, hostsFormat)
+}
+
+func formatHost(host *mackerel.Host) *format.Host {
+	return &format.Host{
+		ID:            host.ID,
+		Name:          host.Name,
+		DisplayName:   host.DisplayName,
+		Status:        host.Status,
+		RoleFullnames: host.GetRoleFullnames(),
+		IsRetired:     host.IsRetired,
+		CreatedAt:     format.ISO8601Extended(host.DateFromCreatedAt()),
+		IPAddresses:   host.IPAddresses(),
+	}
+}
+
+// streamHosts prints a host per line as soon as it is decoded if the client supports streaming.
+func (ha *hostApp) streamHosts(param *mackerel.FindHostsParam, filter *hostFilter, verbose bool) error {
+	enc := json.NewEncoder(ha.outStream)
+	enc.SetEscapeHTML(false)
+	fn := func(host *mackerel.Host) error {
+		if !filter.match(host) {
+			return nil
+		}
+		if verbose {
+			return enc.Encode(host)
+		}
+		return enc.Encode(formatHost(host))
+	}
+
+	if s, ok := ha.client.(mackerelclient.HostStreamer); ok {
+		return s.StreamHosts(param, fn)
+	}
+	hosts, err := ha.client.FindHosts(param)
+	if err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		if err := fn(host); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type exportHostsParam struct {
+	name     string
+	service  string
+	roles    []string
+	statuses []string
+	ips      []string
+	metas    []string
+
+	format  string
+	columns string
+}
+
+// exportHosts writes the hosts flattened into rows for reporting.
+func (ha *hostApp) exportHosts(param exportHostsParam) error {
+	var delimiter rune
+	switch param.format {
+	case "", "csv":
+		delimiter = ','
+	case "tsv":
+		delimiter = '\t'
 	default:
-		var hostsFormat []*format.Host
-		for _, host := range hosts {
-			hostsFormat = append(hostsFormat, &format.Host{
-				ID:            host.ID,
-				Name:          host.Name,
-				DisplayName:   host.DisplayName,
-				Status:        host.Status,
-				RoleFullnames: host.GetRoleFullnames(),
-				IsRetired:     host.IsRetired,
-				CreatedAt:     format.ISO8601Extended(host.DateFromCreatedAt()),
-				IPAddresses:   host.IPAddresses(),
-			})
+		return fmt.Errorf("format should be 'csv' or 'tsv': %s", param.format)
+	}
+	columns := exportHostColumns
+	if param.columns != "" {
+		var err error
+		if columns, err = parseHostColumns(param.columns); err != nil {
+			return err
+		}
+	}
+	filter, err := newHostFilter(param.ips, param.metas)
+	if err != nil {
+		return err
+	}
+
+	found, err := ha.client.FindHosts(&mackerel.FindHostsParam{
+		Name:     param.name,
+		Service:  param.service,
+		Roles:    param.roles,
+		Statuses: param.statuses,
+	})
+	if err != nil {
+		return err
+	}
+	hosts := make([]*mackerel.Host, 0, len(found))
+	for _, host := range found {
+		if filter.match(host) {
+			hosts = append(hosts, host)
 		}
-		return format.PrettyPrintJSON(ha.outStream, hostsFormat)
 	}
+	return writeHostsCSV(ha.outStream, hosts, columns, delimiter)
 }
 
 type createHostParam struct {
@@ -76,14 +204,60 @@ type createHostParam struct {
 	roleFullnames    []string
 	status           string
 	customIdentifier string
+	filePath         string
+}
+
+// hostSpec is the definition of a host in the spec file, which has the same keys as the API.
+type hostSpec struct {
+	mackerel.CreateHostParam
+	Status string `json:"status"`
+}
+
+func loadHostSpec(filePath string) (*hostSpec, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is also accepted since it is a subset of YAML
+	if data, err = format.YAMLToJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to load the host spec %s: %s", filePath, err)
+	}
+	var spec hostSpec
+	if err := json.Unmarshal(data, &spec); err != nil {
+		return nil, fmt.Errorf("failed to load the host spec %s: %s", filePath, err)
+	}
+	return &spec, nil
 }
 
 func (ha *hostApp) createHost(param createHostParam) error {
-	hostID, err := ha.client.CreateHost(&mackerel.CreateHostParam{
-		Name:             param.name,
-		RoleFullnames:    param.roleFullnames,
-		CustomIdentifier: param.customIdentifier,
-	})
+	spec := &hostSpec{}
+	if param.filePath != "" {
+		var err error
+		if spec, err = loadHostSpec(param.filePath); err != nil {
+			ha.error(err)
+			return err
+		}
+	}
+	// the flags take precedence over the spec file
+	if param.name != "" {
+		spec.Name = param.name
+	}
+	if len(param.roleFullnames) > 0 {
+		spec.RoleFullnames = param.roleFullnames
+	}
+	if param.customIdentifier != "" {
+		spec.CustomIdentifier = param.customIdentifier
+	}
+	if param.status != "" {
+		spec.Status = param.status
+	}
+	if spec.Name == "" {
+		err := fmt.Errorf("the name of the host is not specified")
+		ha.error(err)
+		return err
+	}
+
+	hostID, err := ha.client.CreateHost(&spec.CreateHostParam)
 	if err != nil {
 		ha.error(err)
 		return err
@@ -91,13 +265,13 @@ func (ha *hostApp) createHost(param createHostParam) error {
 
 	ha.log("created", hostID)
 
-	if param.status != "" {
-		err := ha.client.UpdateHostStatus(hostID, param.status)
+	if spec.Status != "" {
+		err := ha.client.UpdateHostStatus(hostID, spec.Status)
 		if err != nil {
 			ha.error(err)
 			return err
 		}
-		ha.log("updated", fmt.Sprintf("%s %s", hostID, param.status))
+		ha.log("updated", fmt.Sprintf("%s %s", hostID, spec.Status))
 	}
 	return nil
 }
@@ -4,6 +4,8 @@ import (
 	"bytes"
 	"fmt"
 	"io"
+	"io/ioutil"
+	"os"
 	"testing"
 	"time"
 
@@ -305,3 +307,246 @@ func TestHostApp_CreateHost(t *testing.T) {
 		})
 	}
 }
+
+func TestHostApp_FindHostsJSONLines(t *testing.T) {
+	time.Local = time.FixedZone("Asia/Tokyo", 9*60*60)
+	defer func() { time.Local = nil }()
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindHosts(func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+			return []*mackerel.Host{sampleHost1, sampleHost2}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &hostApp{
+		client:    client,
+		outStream: out,
+	}
+	assert.NoError(t, app.findHosts(findHostsParam{output: "jsonl"}))
+	assert.Equal(t, `{"id":"foo","name":"sample.app1","displayName":"Sample Host foo","status":"working","roleFullnames":["SampleService:app"],"isRetired":false,"createdAt":"2019-03-19T21:53:20+09:00","ipAddresses":{"en0":"10.0.0.1"}}
+{"id":"bar","name":"sample.app2","displayName":"Sample Host bar","status":"standby","roleFullnames":["SampleService:db"],"isRetired":false,"createdAt":"2019-03-08T08:06:40+09:00","ipAddresses":{"eth0":"10.0.1.2"}}
+`, out.String())
+
+	assert.Error(t, app.findHosts(findHostsParam{output: "xml"}))
+}
+
+func TestHostApp_FindHostsTable(t *testing.T) {
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindHosts(func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+			return []*mackerel.Host{sampleHost1, sampleHost2}, nil
+		}),
+	)
+	testCases := []struct {
+		id       string
+		output   string
+		columns  string
+		expected string
+	}{
+		{
+			id:     "table",
+			output: "table",
+			expected: `ID   NAME         STATUS   ROLES              IP
+foo  sample.app1  working  SampleService:app  10.0.0.1
+bar  sample.app2  standby  SampleService:db   10.0.1.2
+`,
+		},
+		{
+			id:      "tsv",
+			output:  "tsv",
+			columns: "id,display-name,agent-version",
+			expected: "ID\tDISPLAY_NAME\tAGENT_VERSION\n" +
+				"foo\tSample Host foo\t\n" +
+				"bar\tSample Host bar\t\n",
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			app := &hostApp{
+				client:    client,
+				outStream: out,
+			}
+			assert.NoError(t, app.findHosts(findHostsParam{output: tc.output, columns: tc.columns}))
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+
+	app := &hostApp{client: client, outStream: new(bytes.Buffer)}
+	assert.EqualError(t, app.findHosts(findHostsParam{output: "table", columns: "id,unknown"}), "unknown column: unknown")
+}
+
+func TestHostApp_FindHostsTemplate(t *testing.T) {
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindHosts(func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+			return []*mackerel.Host{sampleHost1, sampleHost2}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &hostApp{
+		client:    client,
+		outStream: out,
+	}
+	assert.NoError(t, app.findHosts(findHostsParam{output: `go-template={{range .}}{{.id}} {{.status}}{{"\n"}}{{end}}`}))
+	assert.Equal(t, "foo working\nbar standby\n", out.String())
+}
+
+func TestHostApp_FindHostsFilter(t *testing.T) {
+	host3 := &mackerel.Host{
+		ID:               "baz",
+		Name:             "sample.cloud",
+		Status:           mackerel.HostStatusWorking,
+		CustomIdentifier: "i-0123456789",
+		Meta: mackerel.HostMeta{
+			AgentVersion: "0.70.0",
+			Cloud:        &mackerel.Cloud{Provider: "ec2", MetaData: map[string]interface{}{"instance-id": "i-0123456789"}},
+		},
+	}
+	testCases := []struct {
+		id               string
+		customIdentifier string
+		ips              []string
+		metas            []string
+		expected         string
+	}{
+		{id: "ip", ips: []string{"10.0.1.2"}, expected: "bar\n"},
+		{id: "meta", metas: []string{"cloud.metadata.instance-id=i-0123456789", "agent-version=0.70.0"}, expected: "baz\n"},
+		{id: "unmatched meta", metas: []string{"cloud.provider=gce"}, expected: ""},
+		{id: "custom identifier", customIdentifier: "i-0123456789", expected: "foo\nbar\nbaz\n"},
+	}
+	for _, tc := range testCases {
+		client := mackerelclient.NewMockClient(
+			mackerelclient.MockFindHosts(func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+				assert.Equal(t, tc.customIdentifier, param.CustomIdentifier)
+				return []*mackerel.Host{sampleHost1, sampleHost2, host3}, nil
+			}),
+		)
+		t.Run(tc.id, func(t *testing.T) {
+			out := new(bytes.Buffer)
+			app := &hostApp{
+				client:    client,
+				outStream: out,
+			}
+			assert.NoError(t, app.findHosts(findHostsParam{
+				customIdentifier: tc.customIdentifier,
+				ips:              tc.ips,
+				metas:            tc.metas,
+				format:           `{{range .}}{{.ID}}{{"\n"}}{{end}}`,
+			}))
+			assert.Equal(t, tc.expected, out.String())
+		})
+	}
+
+	app := &hostApp{client: mackerelclient.NewMockClient(), outStream: new(bytes.Buffer)}
+	assert.Error(t, app.findHosts(findHostsParam{metas: []string{"invalid"}}))
+}
+
+func TestSplitCommas(t *testing.T) {
+	assert.Equal(t, []string{"working", "standby", "poweroff"}, splitCommas([]string{"working, standby", "poweroff"}))
+}
+
+func TestHostApp_ExportHosts(t *testing.T) {
+	time.Local = time.FixedZone("Asia/Tokyo", 9*60*60)
+	defer func() { time.Local = nil }()
+	host := &mackerel.Host{
+		ID:     "baz",
+		Name:   "sample.web1",
+		Status: mackerel.HostStatusWorking,
+		Roles: mackerel.Roles{
+			"SampleService": []string{"web", "app"},
+		},
+		Meta: mackerel.HostMeta{
+			Cloud: &mackerel.Cloud{
+				Provider: "ec2",
+				MetaData: map[string]interface{}{
+					"instance-id":                 "i-0123",
+					"instance-type":               "t3.micro",
+					"placement/availability-zone": "ap-northeast-1a",
+				},
+			},
+		},
+		Interfaces: []mackerel.Interface{
+			{Name: "eth0", IPv4Addresses: []string{"10.0.0.3"}, MacAddress: "00:00:5e:00:53:01"},
+			{Name: "eth1", IPAddress: "10.0.0.4"},
+		},
+	}
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindHosts(func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+			return []*mackerel.Host{sampleHost1, host}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &hostApp{
+		client:    client,
+		outStream: out,
+	}
+	assert.NoError(t, app.exportHosts(exportHostsParam{
+		columns: "id,roles,interfaces,mac-addresses,cloud-provider,instance-id,instance-type,region",
+	}))
+	assert.Equal(t, `id,roles,interfaces,mac-addresses,cloud-provider,instance-id,instance-type,region
+foo,SampleService:app,en0=10.0.0.1,,,,,
+baz,"SampleService:web,SampleService:app","eth0=10.0.0.3,eth1=10.0.0.4",00:00:5e:00:53:01,ec2,i-0123,t3.micro,ap-northeast-1
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, app.exportHosts(exportHostsParam{format: "tsv", columns: "id,name"}))
+	assert.Equal(t, "id\tname\nfoo\tsample.app1\nbaz\tsample.web1\n", out.String())
+
+	assert.Error(t, app.exportHosts(exportHostsParam{format: "xlsx"}))
+}
+
+func TestHostApp_CreateHostFromFile(t *testing.T) {
+	f, err := ioutil.TempFile("", "mkr-host-spec")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	fmt.Fprint(f, `name: external.example.com
+displayName: External
+customIdentifier: external-1
+roleFullnames:
+  - foo:bar
+interfaces:
+  - name: eth0
+    ipv4Addresses: [192.0.2.1]
+meta:
+  cloud:
+    provider: custom
+    metadata:
+      region: somewhere
+checks:
+  - name: ping
+status: standby
+`)
+	f.Close()
+
+	var created *mackerel.CreateHostParam
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockCreateHost(func(param *mackerel.CreateHostParam) (string, error) {
+			created = param
+			return "xxx", nil
+		}),
+		mackerelclient.MockUpdateHostStatus(func(hostID, status string) error {
+			assert.Equal(t, "standby", status)
+			return nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &hostApp{
+		client:    client,
+		logger:    &testLogger{out},
+		outStream: out,
+	}
+	assert.NoError(t, app.createHost(createHostParam{
+		customIdentifier: "external-2",
+		filePath:         f.Name(),
+	}))
+	assert.Equal(t, &mackerel.CreateHostParam{
+		Name:        "external.example.com",
+		DisplayName: "External",
+		Meta: mackerel.HostMeta{
+			Cloud: &mackerel.Cloud{Provider: "custom", MetaData: map[string]interface{}{"region": "somewhere"}},
+		},
+		Interfaces:       []mackerel.Interface{{Name: "eth0", IPv4Addresses: []string{"192.0.2.1"}}},
+		RoleFullnames:    []string{"foo:bar"},
+		Checks:           []mackerel.CheckConfig{{Name: "ping"}},
+		CustomIdentifier: "external-2",
+	}, created)
+	assert.Equal(t, "created xxx\nupdated xxx standby\n", out.String())
+}
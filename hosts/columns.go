@@ -0,0 +1,185 @@
+package hosts
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+)
+
+const defaultHostColumns = "id,name,status,roles,ip"
+
+// hostColumns are the columns of the table and TSV output
+var hostColumns = map[string]func(*mackerel.Host) string{
+	"id":                func(h *mackerel.Host) string { return h.ID },
+	"name":              func(h *mackerel.Host) string { return h.Name },
+	"display-name":      func(h *mackerel.Host) string { return h.DisplayName },
+	"status":            func(h *mackerel.Host) string { return h.Status },
+	"roles":             func(h *mackerel.Host) string { return strings.Join(h.GetRoleFullnames(), ",") },
+	"ip":                hostIPAddresses,
+	"agent-version":     func(h *mackerel.Host) string { return h.Meta.AgentVersion },
+	"custom-identifier": func(h *mackerel.Host) string { return h.CustomIdentifier },
+	"created-at":        func(h *mackerel.Host) string { return format.ISO8601Extended(h.DateFromCreatedAt()) },
+	"memo":              func(h *mackerel.Host) string { return h.Memo },
+	"interfaces":        hostInterfaces,
+	"mac-addresses":     hostMacAddresses,
+	"cloud-provider":    hostCloudProvider,
+	"instance-id":       func(h *mackerel.Host) string { return hostCloudMetadata(h, "instance-id", "instanceId", "vmId") },
+	"instance-type":     func(h *mackerel.Host) string { return hostCloudMetadata(h, "instance-type", "machineType", "vmSize") },
+	"region":            hostRegion,
+}
+
+// the columns of the export in the stable order
+var exportHostColumns = []string{
+	"id", "name", "display-name", "status", "roles", "custom-identifier", "created-at",
+	"ip", "interfaces", "mac-addresses", "agent-version",
+	"cloud-provider", "instance-id", "instance-type", "region", "memo",
+}
+
+func hostIPAddresses(h *mackerel.Host) string {
+	var ips []string
+	for _, iface := range h.Interfaces {
+		if iface.IPAddress != "" {
+			ips = append(ips, iface.IPAddress)
+		}
+	}
+	return strings.Join(ips, ",")
+}
+
+// hostInterfaces returns the interfaces in the form of name=address.
+func hostInterfaces(h *mackerel.Host) string {
+	var ifaces []string
+	for _, iface := range h.Interfaces {
+		ips := iface.IPv4Addresses
+		if len(ips) == 0 && iface.IPAddress != "" {
+			ips = []string{iface.IPAddress}
+		}
+		ips = append(ips, iface.IPv6Addresses...)
+		for _, ip := range ips {
+			ifaces = append(ifaces, iface.Name+"="+ip)
+		}
+	}
+	return strings.Join(ifaces, ",")
+}
+
+func hostMacAddresses(h *mackerel.Host) string {
+	var macs []string
+	for _, iface := range h.Interfaces {
+		if iface.MacAddress != "" {
+			macs = append(macs, iface.MacAddress)
+		}
+	}
+	return strings.Join(macs, ",")
+}
+
+func hostCloudProvider(h *mackerel.Host) string {
+	if h.Meta.Cloud == nil {
+		return ""
+	}
+	return h.Meta.Cloud.Provider
+}
+
+// hostCloudMetadata returns the first value found in the cloud metadata by the keys,
+// which differ between the cloud providers.
+func hostCloudMetadata(h *mackerel.Host, keys ...string) string {
+	if h.Meta.Cloud == nil {
+		return ""
+	}
+	m, ok := h.Meta.Cloud.MetaData.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+	for _, key := range keys {
+		if v, ok := m[key]; ok && v != nil {
+			s := fmt.Sprint(v)
+			// GCE returns the resource paths such as projects/123/machineTypes/n1-standard-1
+			return s[strings.LastIndex(s, "/")+1:]
+		}
+	}
+	return ""
+}
+
+// hostRegion returns the region of the host, which is derived from the availability zone if necessary.
+func hostRegion(h *mackerel.Host) string {
+	if region := hostCloudMetadata(h, "region", "location"); region != "" {
+		return region
+	}
+	switch zone := hostCloudMetadata(h, "placement/availability-zone", "zone"); hostCloudProvider(h) {
+	case "ec2":
+		// ap-northeast-1a
+		if len(zone) > 1 {
+			return zone[:len(zone)-1]
+		}
+	case "gce":
+		// asia-northeast1-a
+		if i := strings.LastIndex(zone, "-"); i > 0 {
+			return zone[:i]
+		}
+	}
+	return ""
+}
+
+func parseHostColumns(s string) ([]string, error) {
+	if s == "" {
+		s = defaultHostColumns
+	}
+	columns := strings.Split(s, ",")
+	for i, column := range columns {
+		columns[i] = strings.TrimSpace(column)
+		if _, ok := hostColumns[columns[i]]; !ok {
+			return nil, fmt.Errorf("unknown column: %s", columns[i])
+		}
+	}
+	return columns, nil
+}
+
+// printHostsTable prints the hosts in aligned columns, or separated by tabs if tsv is true.
+func printHostsTable(w io.Writer, hosts []*mackerel.Host, columns []string, tsv bool) error {
+	out := w
+	var tw *tabwriter.Writer
+	if !tsv {
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		out = tw
+	}
+	headers := make([]string, len(columns))
+	for i, column := range columns {
+		headers[i] = strings.ToUpper(strings.Replace(column, "-", "_", -1))
+	}
+	fmt.Fprintln(out, strings.Join(headers, "\t"))
+	for _, host := range hosts {
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = hostColumns[column](host)
+		}
+		fmt.Fprintln(out, strings.Join(values, "\t"))
+	}
+	if tw != nil {
+		return tw.Flush()
+	}
+	return nil
+}
+
+// writeHostsCSV writes the hosts with a header row, separated by comma or the delimiter.
+func writeHostsCSV(w io.Writer, hosts []*mackerel.Host, columns []string, delimiter rune) error {
+	cw := csv.NewWriter(w)
+	cw.Comma = delimiter
+	if err := cw.Write(columns); err != nil {
+		return err
+	}
+	for _, host := range hosts {
+		values := make([]string, len(columns))
+		for i, column := range columns {
+			values[i] = hostColumns[column](host)
+		}
+		if err := cw.Write(values); err != nil {
+			return err
+		}
+	}
+	cw.Flush()
+	return cw.Error()
+}
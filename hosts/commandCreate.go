@@ -12,9 +12,12 @@ import (
 var CommandCreate = cli.Command{
 	Name:      "create",
 	Usage:     "Create a new host",
-	ArgsUsage: "[--status | -st <status>] [--roleFullname | -R <service:role>] [--customIdentifier <customIdentifier>] <hostName>",
+	ArgsUsage: "[--status | -st <status>] [--roleFullname | -R <service:role>] [--customIdentifier <customIdentifier>] [--file-path | -F <file>] <hostName>",
 	Description: `
     Create a new host with status, roleFullname and/or customIdentifier.
+    With --file-path, the host is created from the YAML or JSON spec file, which can have
+    the same keys as the API such as displayName, meta, interfaces and checks, and status.
+    The flags and <hostName> take precedence over the spec file.
     Requests "POST /api/v0/hosts". See https://mackerel.io/api-docs/entry/hosts#create .
 `,
 	Action: doCreate,
@@ -26,17 +29,19 @@ var CommandCreate = cli.Command{
 			Usage: "Multiple choices are allowed. ex. My-Service:proxy, My-Service:db-master",
 		},
 		cli.StringFlag{Name: "customIdentifier", Value: "", Usage: "CustomIdentifier for the Host"},
+		cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Read the host spec from <file>"},
 	},
 }
 
 func doCreate(c *cli.Context) error {
 	argHostName := c.Args().Get(0)
-	if argHostName == "" {
+	filePath := c.String("file-path")
+	if argHostName == "" && filePath == "" {
 		cli.ShowCommandHelp(c, "create")
 		os.Exit(1)
 	}
 
-	client, err := mackerelclient.New(c.GlobalString("conf"), c.GlobalString("apibase"))
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
 	if err != nil {
 		return err
 	}
@@ -50,5 +55,6 @@ func doCreate(c *cli.Context) error {
 		roleFullnames:    c.StringSlice("roleFullname"),
 		status:           c.String("status"),
 		customIdentifier: c.String("customIdentifier"),
+		filePath:         filePath,
 	})
 }
@@ -0,0 +1,74 @@
+package hosts
+
+import (
+	"os"
+
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+var commandExport = cli.Command{
+	Name:      "export",
+	Usage:     "Export hosts inventory",
+	ArgsUsage: "[--format csv|tsv] [--columns <columns>] [--name | -n <name>] [--service | -s <service>] [[--role | -r <role>]...] [[--status | --st <status>]...] [[--ip <address>]...] [[--meta <key=value>]...]",
+	Description: `
+    Export the hosts flattened into rows with a header row for asset management.
+    The columns can be selected by --columns from
+    id, name, display-name, status, roles, custom-identifier, created-at, ip, interfaces, mac-addresses,
+    agent-version, cloud-provider, instance-id, instance-type, region and memo.
+    All of them are exported in this order when --columns is not specified.
+    Requests "GET /api/v0/hosts.json". See https://mackerel.io/api-docs/entry/hosts#list .
+`,
+	Action: doExport,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "format", Value: "csv", Usage: "Output format: 'csv' or 'tsv'"},
+		cli.StringFlag{Name: "columns", Value: "", Usage: "Comma separated columns to export"},
+		cli.StringFlag{Name: "name, n", Value: "", Usage: "Export hosts only matched with <name>"},
+		cli.StringFlag{Name: "service, s", Value: "", Usage: "Export hosts only belonging to <service>"},
+		cli.StringSliceFlag{
+			Name:  "role, r",
+			Value: &cli.StringSlice{},
+			Usage: "Export hosts only belonging to <role>. Multiple choices are allowed. Required --service",
+		},
+		cli.StringSliceFlag{
+			Name:  "status, st",
+			Value: &cli.StringSlice{},
+			Usage: "Export hosts only matched <status>. Multiple choices are allowed, also separated by commas.",
+		},
+		cli.StringSliceFlag{
+			Name:  "ip",
+			Value: &cli.StringSlice{},
+			Usage: "Export hosts only having <address>. Multiple choices are allowed.",
+		},
+		cli.StringSliceFlag{
+			Name:  "meta",
+			Value: &cli.StringSlice{},
+			Usage: "Export hosts only whose meta matches <key=value>. Multiple conditions are ANDed.",
+		},
+	},
+}
+
+func doExport(c *cli.Context) error {
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
+	if err != nil {
+		return err
+	}
+
+	return (&hostApp{
+		client:    client,
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}).exportHosts(exportHostsParam{
+		name:     c.String("name"),
+		service:  c.String("service"),
+		roles:    c.StringSlice("role"),
+		statuses: splitCommas(c.StringSlice("status")),
+		ips:      c.StringSlice("ip"),
+		metas:    c.StringSlice("meta"),
+
+		format:  c.String("format"),
+		columns: c.String("columns"),
+	})
+}
@@ -1,10 +1,12 @@
 package hosts
 
 import (
+	"io"
 	"os"
 
 	"github.com/urfave/cli"
 
+	"github.com/mackerelio/mkr/format"
 	"github.com/mackerelio/mkr/logger"
 	"github.com/mackerelio/mkr/mackerelclient"
 )
@@ -13,13 +15,20 @@ import (
 var CommandHosts = cli.Command{
 	Name:      "hosts",
 	Usage:     "List hosts",
-	ArgsUsage: "[--verbose | -v] [--name | -n <name>] [--service | -s <service>] [[--role | -r <role>]...] [[--status | --st <status>]...]",
+	ArgsUsage: "[--verbose | -v] [--name | -n <name>] [--service | -s <service>] [[--role | -r <role>]...] [[--status | --st <status>]...] [--custom-identifier <id>] [[--ip <address>]...] [[--meta <key=value>]...] [--output | -o json|jsonl|yaml|table|tsv|go-template=<template>] [--columns <columns>] [--watch [--interval <duration>]]",
 	Description: `
-    List the information of the hosts refined by host name, service name, role name and/or status.
+    List the information of the hosts refined by host name, service name, role name, status, custom identifier, IP address and/or host meta.
+    The key of --meta is a dotted path in the host meta such as 'agent-version' or 'cloud.metadata.instance-id'.
+    With --output jsonl, each host is printed in a line as soon as it is received.
+    With --watch, the output is cleared and re-rendered on every --interval.
+    With --output table or tsv, the columns can be selected by --columns from
+    id, name, display-name, status, roles, ip, agent-version, custom-identifier, created-at, memo,
+    interfaces, mac-addresses, cloud-provider, instance-id, instance-type and region.
     Requests "GET /api/v0/hosts.json". See https://mackerel.io/api-docs/entry/hosts#list .
 `,
-	Action: doHosts,
-	Flags: []cli.Flag{
+	Action:      doHosts,
+	Subcommands: []cli.Command{commandMetadata, commandRoles, commandExport, commandSnapshot, commandDiff},
+	Flags: append([]cli.Flag{
 		cli.StringFlag{Name: "name, n", Value: "", Usage: "List hosts only matched with <name>"},
 		cli.StringFlag{Name: "service, s", Value: "", Usage: "List hosts only belonging to <service>"},
 		cli.StringSliceFlag{
@@ -30,31 +39,52 @@ var CommandHosts = cli.Command{
 		cli.StringSliceFlag{
 			Name:  "status, st",
 			Value: &cli.StringSlice{},
-			Usage: "List hosts only matched <status>. Multiple choices are allowed.",
+			Usage: "List hosts only matched <status>. Multiple choices are allowed, also separated by commas.",
+		},
+		cli.StringFlag{Name: "custom-identifier", Value: "", Usage: "List hosts only matched with <customIdentifier>"},
+		cli.StringSliceFlag{
+			Name:  "ip",
+			Value: &cli.StringSlice{},
+			Usage: "List hosts only having <address>. Multiple choices are allowed.",
+		},
+		cli.StringSliceFlag{
+			Name:  "meta",
+			Value: &cli.StringSlice{},
+			Usage: "List hosts only whose meta matches <key=value>. Multiple conditions are ANDed.",
 		},
 		cli.StringFlag{Name: "format, f", Value: "", Usage: "Output format template"},
+		cli.StringFlag{Name: "output, o", Value: "json", Usage: "Output format: 'json', 'jsonl', 'yaml', 'table', 'tsv' or 'go-template=<template>'"},
+		cli.StringFlag{Name: "columns", Value: defaultHostColumns, Usage: "Comma separated columns of table and tsv output"},
 		cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
-	},
+	}, format.WatchFlags...),
 }
 
 func doHosts(c *cli.Context) error {
-	client, err := mackerelclient.New(c.GlobalString("conf"), c.GlobalString("apibase"))
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
 	if err != nil {
 		return err
 	}
 
-	return (&hostApp{
-		client:    client,
-		logger:    logger.New(),
-		outStream: os.Stdout,
-	}).findHosts(findHostsParam{
+	param := findHostsParam{
 		verbose: c.Bool("verbose"),
 
-		name:     c.String("name"),
-		service:  c.String("service"),
-		roles:    c.StringSlice("role"),
-		statuses: c.StringSlice("status"),
+		name:             c.String("name"),
+		service:          c.String("service"),
+		roles:            c.StringSlice("role"),
+		statuses:         splitCommas(c.StringSlice("status")),
+		customIdentifier: c.String("custom-identifier"),
+		ips:              c.StringSlice("ip"),
+		metas:            c.StringSlice("meta"),
 
-		format: c.String("format"),
+		format:  c.String("format"),
+		output:  format.OutputName(c, "json", "json", "jsonl", "yaml", "table", "tsv", "go-template"),
+		columns: c.String("columns"),
+	}
+	return format.WatchFromContext(c, os.Stdout, func(w io.Writer) error {
+		return (&hostApp{
+			client:    client,
+			logger:    logger.New(),
+			outStream: w,
+		}).findHosts(param)
 	})
 }
@@ -0,0 +1,199 @@
+package hosts
+
+import (
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+var commandMetadata = cli.Command{
+	Name:  "metadata",
+	Usage: "Manipulate host metadata",
+	Description: `
+    Manipulate the metadata of hosts. With no subcommand specified, this will show all of subcommands.
+    Requests APIs under "/api/v0/hosts/<hostId>/metadata". See https://mackerel.io/api-docs/entry/metadata .
+`,
+	Subcommands: []cli.Command{
+		{
+			Name:      "get",
+			Usage:     "Show host metadata",
+			ArgsUsage: "--host-id <hostId> | --host-name <name> [--namespace <namespace>]",
+			Description: `
+    Show the metadata of the host in the namespace.
+    The namespaces of the host are listed when --namespace is not specified.
+`,
+			Action: doMetadataGet,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "host-id, H", Value: "", Usage: "Show the metadata of <hostId>"},
+				mackerelclient.HostNameFlag,
+				cli.StringFlag{Name: "namespace", Value: "", Usage: "The namespace of the metadata"},
+			},
+		},
+		{
+			Name:      "put",
+			Usage:     "Put host metadata",
+			ArgsUsage: "--host-id <hostId> | --host-name <name> --namespace <namespace> [--file | -F <file>]",
+			Description: `
+    Put the metadata of the host in the namespace from the JSON file or stdin.
+`,
+			Action: doMetadataPut,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "host-id, H", Value: "", Usage: "Put the metadata of <hostId>"},
+				mackerelclient.HostNameFlag,
+				cli.StringFlag{Name: "namespace", Value: "", Usage: "The namespace of the metadata"},
+				cli.StringFlag{Name: "file, F", Value: "", Usage: "Read the metadata from <file>. Read from stdin if not specified"},
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete host metadata",
+			ArgsUsage: "--host-id <hostId> | --host-name <name> --namespace <namespace>",
+			Description: `
+    Delete the metadata of the host in the namespace.
+`,
+			Action: doMetadataDelete,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "host-id, H", Value: "", Usage: "Delete the metadata of <hostId>"},
+				mackerelclient.HostNameFlag,
+				cli.StringFlag{Name: "namespace", Value: "", Usage: "The namespace of the metadata"},
+			},
+		},
+		{
+			Name:      "pull",
+			Usage:     "Pull host metadata",
+			ArgsUsage: "--dir <dir> [[--host-id | -H <hostId>]...] [[--host-name <name>]...] [--service | -s <service> [[--role | -r <role>]...]] [--namespace <namespace>]",
+			Description: `
+    Save the metadata of the hosts into <dir>/<hostId>/<namespace>.json.
+    The hosts are specified by --host-id or --host-name, or selected by --service and --role.
+    All the namespaces are saved when --namespace is not specified.
+`,
+			Action: doMetadataPull,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: "", Usage: "The directory to save metadata files"},
+				cli.StringSliceFlag{
+					Name:  "host-id, H",
+					Value: &cli.StringSlice{},
+					Usage: "Pull the metadata of <hostId>. Multiple choices are allowed.",
+				},
+				mackerelclient.HostNamesFlag,
+				cli.StringFlag{Name: "service, s", Value: "", Usage: "Pull the metadata of the hosts belonging to <service>"},
+				cli.StringSliceFlag{
+					Name:  "role, r",
+					Value: &cli.StringSlice{},
+					Usage: "Pull the metadata of the hosts belonging to <role>. Multiple choices are allowed. Required --service",
+				},
+				cli.StringFlag{Name: "namespace", Value: "", Usage: "Pull only the metadata of <namespace>"},
+			},
+		},
+		{
+			Name:      "push",
+			Usage:     "Push host metadata",
+			ArgsUsage: "--dir <dir> [--namespace <namespace>]",
+			Description: `
+    Put the metadata of the files in <dir>/<hostId>/<namespace>.json, which are saved by "mkr hosts metadata pull".
+`,
+			Action: doMetadataPush,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: "", Usage: "The directory of metadata files"},
+				cli.StringFlag{Name: "namespace", Value: "", Usage: "Push only the metadata of <namespace>"},
+			},
+		},
+	},
+}
+
+func newMetadataApp(c *cli.Context) *metadataApp {
+	return &metadataApp{
+		client:    &apiMetadataClient{client: mackerelclient.NewFromContext(c)},
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}
+}
+
+func requireFlags(c *cli.Context, names ...string) {
+	for _, name := range names {
+		if c.String(name) == "" {
+			cli.ShowCommandHelp(c, c.Command.Name)
+			os.Exit(1)
+		}
+	}
+}
+
+// requireHostID returns the host ID specified by --host-id or --host-name.
+func requireHostID(c *cli.Context) (string, error) {
+	if c.String("host-id") == "" && c.String("host-name") == "" {
+		cli.ShowCommandHelp(c, c.Command.Name)
+		os.Exit(1)
+	}
+	return mackerelclient.HostIDFromContext(c, mackerelclient.NewFromContext(c), "host-id")
+}
+
+func doMetadataGet(c *cli.Context) error {
+	hostID, err := requireHostID(c)
+	if err != nil {
+		return err
+	}
+	return newMetadataApp(c).get(hostID, c.String("namespace"))
+}
+
+func doMetadataPut(c *cli.Context) error {
+	requireFlags(c, "namespace")
+	hostID, err := requireHostID(c)
+	if err != nil {
+		return err
+	}
+	var r io.Reader = os.Stdin
+	if path := c.String("file"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		r = f
+	}
+	return newMetadataApp(c).put(hostID, c.String("namespace"), r)
+}
+
+func doMetadataDelete(c *cli.Context) error {
+	requireFlags(c, "namespace")
+	hostID, err := requireHostID(c)
+	if err != nil {
+		return err
+	}
+	return newMetadataApp(c).delete(hostID, c.String("namespace"))
+}
+
+func doMetadataPull(c *cli.Context) error {
+	requireFlags(c, "dir")
+	client := mackerelclient.NewFromContext(c)
+	hostIDs, err := mackerelclient.HostIDsFromContext(c, client, "host-id")
+	if err != nil {
+		return err
+	}
+	if service := c.String("service"); service != "" {
+		hosts, err := client.FindHosts(&mackerel.FindHostsParam{
+			Service: service,
+			Roles:   c.StringSlice("role"),
+		})
+		if err != nil {
+			return err
+		}
+		for _, host := range hosts {
+			hostIDs = append(hostIDs, host.ID)
+		}
+	}
+	if len(hostIDs) == 0 {
+		return fmt.Errorf("specify --host-id or --service to select hosts")
+	}
+	return newMetadataApp(c).pull(c.String("dir"), hostIDs, c.String("namespace"))
+}
+
+func doMetadataPush(c *cli.Context) error {
+	requireFlags(c, "dir")
+	return newMetadataApp(c).push(c.String("dir"), c.String("namespace"))
+}
@@ -0,0 +1,106 @@
+package hosts
+
+import (
+	"os"
+
+	"github.com/Songmu/prompter"
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+var rolesSelectorFlags = []cli.Flag{
+	cli.StringSliceFlag{
+		Name:  "host-id, H",
+		Value: &cli.StringSlice{},
+		Usage: "Update the roles of <hostId>. Multiple choices are allowed.",
+	},
+	mackerelclient.HostNamesFlag,
+	cli.StringFlag{Name: "service, s", Value: "", Usage: "Update the roles of the hosts belonging to <service>"},
+	cli.StringSliceFlag{
+		Name:  "role, r",
+		Value: &cli.StringSlice{},
+		Usage: "Update the roles of the hosts belonging to <role>. Multiple choices are allowed. Required --service",
+	},
+	cli.BoolFlag{Name: "dry-run", Usage: "Show the roles to be updated, but not update them"},
+	cli.BoolFlag{Name: "force", Usage: "Update the roles of the hosts selected by --service without confirmation"},
+}
+
+var commandRoles = cli.Command{
+	Name:  "roles",
+	Usage: "Add or remove roles of hosts",
+	Description: `
+    Add roles to or remove roles from hosts. With no subcommand specified, this will show all of subcommands.
+    Requests "PUT /api/v0/hosts/<hostId>/role-fullnames". See https://mackerel.io/api-docs/entry/hosts#update-roles .
+`,
+	Subcommands: []cli.Command{
+		{
+			Name:      "add",
+			Usage:     "Add roles to hosts",
+			ArgsUsage: "[[--host-id | -H <hostId>]...] [[--host-name <name>]...] [--service | -s <service> [[--role | -r <role>]...]] [--exclusive] [--dry-run] [--force] <service:role>...",
+			Description: `
+    Add the roles to the hosts specified by --host-id or --host-name, or selected by --service and --role.
+    With --exclusive, the roles of the hosts are replaced with the specified roles.
+    The hosts selected by --service are updated after confirmation unless --force is specified.
+`,
+			Action: doRolesAdd,
+			Flags: append(append([]cli.Flag{}, rolesSelectorFlags...),
+				cli.BoolFlag{Name: "exclusive", Usage: "Replace all the roles of the hosts with the specified roles"},
+			),
+		},
+		{
+			Name:      "remove",
+			Usage:     "Remove roles from hosts",
+			ArgsUsage: "[[--host-id | -H <hostId>]...] [[--host-name <name>]...] [--service | -s <service> [[--role | -r <role>]...]] [--dry-run] [--force] <service:role>...",
+			Description: `
+    Remove the roles from the hosts specified by --host-id or --host-name, or selected by --service and --role.
+    The hosts selected by --service are updated after confirmation unless --force is specified.
+`,
+			Action: doRolesRemove,
+			Flags:  rolesSelectorFlags,
+		},
+	},
+}
+
+func doRolesAdd(c *cli.Context) error {
+	return doRolesUpdate(c, false)
+}
+
+func doRolesRemove(c *cli.Context) error {
+	return doRolesUpdate(c, true)
+}
+
+func doRolesUpdate(c *cli.Context, remove bool) error {
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
+	if err != nil {
+		return err
+	}
+
+	hostIDs, err := mackerelclient.HostIDsFromContext(c, client, "host-id")
+	if err != nil {
+		return err
+	}
+
+	var confirm func(string) bool
+	if !c.Bool("force") {
+		confirm = func(prompt string) bool { return prompter.YN(prompt, false) }
+	}
+
+	return (&hostApp{
+		client:    client,
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}).updateRoles(updateRolesParam{
+		hostIDs: hostIDs,
+		service: c.String("service"),
+		roles:   c.StringSlice("role"),
+
+		remove:        remove,
+		exclusive:     c.Bool("exclusive"),
+		roleFullnames: c.Args(),
+
+		dryRun:  c.Bool("dry-run"),
+		confirm: confirm,
+	})
+}
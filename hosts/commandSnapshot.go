@@ -0,0 +1,129 @@
+package hosts
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+var snapshotSelectorFlags = []cli.Flag{
+	cli.StringFlag{Name: "service, s", Value: "", Usage: "Only the hosts belonging to <service>"},
+	cli.StringSliceFlag{
+		Name:  "role, r",
+		Value: &cli.StringSlice{},
+		Usage: "Only the hosts belonging to <role>. Multiple choices are allowed. Required --service",
+	},
+	cli.StringSliceFlag{
+		Name:  "status, st",
+		Value: &cli.StringSlice{},
+		Usage: "Only the hosts matched <status>. Multiple choices are allowed, also separated by commas. Defaults to all the statuses",
+	},
+}
+
+var commandSnapshot = cli.Command{
+	Name:      "snapshot",
+	Usage:     "Save a snapshot of hosts",
+	ArgsUsage: "[--out <file>] [--service | -s <service>] [[--role | -r <role>]...] [[--status | --st <status>]...]",
+	Description: `
+    Save the information of the hosts into <file>, which can be compared later by "mkr hosts diff".
+    Prints to stdout if --out is not specified.
+    Requests "GET /api/v0/hosts.json". See https://mackerel.io/api-docs/entry/hosts#list .
+`,
+	Action: doSnapshot,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "out", Value: "", Usage: "The file to save the snapshot"},
+	}, snapshotSelectorFlags...),
+}
+
+var commandDiff = cli.Command{
+	Name:      "diff",
+	Usage:     "Compare hosts against a snapshot or another organization",
+	ArgsUsage: "(--base <file> | --base-apikey <apikey>) [--key id|name] [--output | -o text|json] [--service | -s <service>] [[--role | -r <role>]...] [[--status | --st <status>]...]",
+	Description: `
+    Report the added, retired and changed hosts compared with the snapshot saved by "mkr hosts snapshot",
+    or with the hosts of another organization specified by --base-apikey.
+    The hosts are identified by --key, which defaults to 'name' with --base-apikey since the IDs differ between organizations.
+    The selector given by --service, --role and --status is applied to both the base hosts and the current hosts.
+    Requests "GET /api/v0/hosts.json". See https://mackerel.io/api-docs/entry/hosts#list .
+`,
+	Action: doDiff,
+	Flags: append([]cli.Flag{
+		cli.StringFlag{Name: "base", Value: "", Usage: "The snapshot file to compare with"},
+		cli.StringFlag{Name: "base-apikey", Value: "", Usage: "The API key of the organization to compare with"},
+		cli.StringFlag{Name: "key", Value: "", Usage: "Identify the hosts by 'id' or 'name'"},
+		cli.StringFlag{Name: "output, o", Value: "text", Usage: "Output format: 'text' or 'json'"},
+	}, snapshotSelectorFlags...),
+}
+
+func doSnapshot(c *cli.Context) error {
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
+	if err != nil {
+		return err
+	}
+
+	return (&hostApp{
+		client:    client,
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}).snapshotHosts(snapshotHostsParam{
+		service:  c.String("service"),
+		roles:    c.StringSlice("role"),
+		statuses: splitCommas(c.StringSlice("status")),
+		out:      c.String("out"),
+	})
+}
+
+func doDiff(c *cli.Context) error {
+	selector := newSnapshotFindParam(c.String("service"), c.StringSlice("role"), splitCommas(c.StringSlice("status")))
+	key := c.String("key")
+	var base []*mackerel.Host
+	var err error
+	switch {
+	case c.String("base") != "":
+		if key == "" {
+			key = "id"
+		}
+		base, err = loadHostsSnapshot(c.String("base"))
+	case c.String("base-apikey") != "":
+		if key == "" {
+			key = "name"
+		}
+		apibase := c.GlobalString("apibase")
+		if apibase == "" {
+			apibase = mackerelclient.LoadApibaseFromConfigWithFallback(c.GlobalString("conf"))
+		}
+		var baseClient *mackerel.Client
+		if baseClient, err = mackerelclient.NewClient(c.String("base-apikey"), apibase); err == nil {
+			base, err = baseClient.FindHosts(selector)
+		}
+	default:
+		return fmt.Errorf("specify --base or --base-apikey to compare with")
+	}
+	if err != nil {
+		return err
+	}
+
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
+	if err != nil {
+		return err
+	}
+
+	return (&hostApp{
+		client:    client,
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}).diffHosts(diffHostsParam{
+		base:     base,
+		service:  selector.Service,
+		roles:    selector.Roles,
+		statuses: selector.Statuses,
+		key:      key,
+		output:   format.OutputName(c, "text", "text", "json"),
+	})
+}
@@ -0,0 +1,91 @@
+package hosts
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// hostFilter selects the hosts by the conditions which the API does not support.
+type hostFilter struct {
+	ips  []string
+	meta map[string]string
+}
+
+func newHostFilter(ips, metas []string) (*hostFilter, error) {
+	f := &hostFilter{ips: ips, meta: map[string]string{}}
+	for _, m := range metas {
+		kv := strings.SplitN(m, "=", 2)
+		if len(kv) != 2 || kv[0] == "" {
+			return nil, fmt.Errorf("meta should be in the form of key=value: %s", m)
+		}
+		f.meta[kv[0]] = kv[1]
+	}
+	return f, nil
+}
+
+func (f *hostFilter) match(h *mackerel.Host) bool {
+	if len(f.ips) > 0 {
+		found := false
+		for _, iface := range h.Interfaces {
+			for _, ip := range f.ips {
+				if iface.IPAddress == ip || containsString(iface.IPv4Addresses, ip) || containsString(iface.IPv6Addresses, ip) {
+					found = true
+				}
+			}
+		}
+		if !found {
+			return false
+		}
+	}
+	if len(f.meta) > 0 {
+		var meta interface{}
+		data, _ := json.Marshal(h.Meta)
+		json.Unmarshal(data, &meta)
+		for key, value := range f.meta {
+			v, ok := lookupMeta(meta, strings.Split(key, "."))
+			if !ok || fmt.Sprint(v) != value {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// lookupMeta returns the value of the dotted key path in the host meta.
+func lookupMeta(meta interface{}, path []string) (interface{}, bool) {
+	for _, key := range path {
+		m, ok := meta.(map[string]interface{})
+		if !ok {
+			return nil, false
+		}
+		if meta, ok = m[key]; !ok {
+			return nil, false
+		}
+	}
+	return meta, true
+}
+
+func containsString(xs []string, x string) bool {
+	for _, y := range xs {
+		if x == y {
+			return true
+		}
+	}
+	return false
+}
+
+// splitCommas splits the values containing commas such as "working,standby".
+func splitCommas(values []string) []string {
+	var result []string
+	for _, v := range values {
+		for _, s := range strings.Split(v, ",") {
+			if s = strings.TrimSpace(s); s != "" {
+				result = append(result, s)
+			}
+		}
+	}
+	return result
+}
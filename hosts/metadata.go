@@ -0,0 +1,178 @@
+package hosts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+)
+
+// metadataClient represents a client of the host metadata API
+type metadataClient interface {
+	ListHostMetadataNamespaces(hostID string) ([]string, error)
+	GetHostMetadata(hostID, namespace string) (json.RawMessage, error)
+	PutHostMetadata(hostID, namespace string, metadata json.RawMessage) error
+	DeleteHostMetadata(hostID, namespace string) error
+}
+
+// apiMetadataClient converts the host metadata into JSON to keep it as it is in files.
+type apiMetadataClient struct {
+	client *mackerel.Client
+}
+
+func (c *apiMetadataClient) ListHostMetadataNamespaces(hostID string) ([]string, error) {
+	return c.client.GetHostMetaDataNameSpaces(hostID)
+}
+
+func (c *apiMetadataClient) GetHostMetadata(hostID, namespace string) (json.RawMessage, error) {
+	resp, err := c.client.GetHostMetaData(hostID, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(resp.HostMetaData)
+}
+
+func (c *apiMetadataClient) PutHostMetadata(hostID, namespace string, metadata json.RawMessage) error {
+	return c.client.PutHostMetaData(hostID, namespace, metadata)
+}
+
+func (c *apiMetadataClient) DeleteHostMetadata(hostID, namespace string) error {
+	return c.client.DeleteHostMetaData(hostID, namespace)
+}
+
+type metadataApp struct {
+	client    metadataClient
+	logger    appLogger
+	outStream io.Writer
+}
+
+func (ma *metadataApp) get(hostID, namespace string) error {
+	if namespace == "" {
+		namespaces, err := ma.client.ListHostMetadataNamespaces(hostID)
+		if err != nil {
+			return err
+		}
+		return format.PrettyPrintJSON(ma.outStream, namespaces)
+	}
+	metadata, err := ma.client.GetHostMetadata(hostID, namespace)
+	if err != nil {
+		return err
+	}
+	return format.PrettyPrintJSON(ma.outStream, metadata)
+}
+
+func (ma *metadataApp) put(hostID, namespace string, r io.Reader) error {
+	metadata, err := readMetadata(r)
+	if err != nil {
+		return err
+	}
+	if err := ma.client.PutHostMetadata(hostID, namespace, metadata); err != nil {
+		return err
+	}
+	ma.logger.Log("updated", hostID+" "+namespace)
+	return nil
+}
+
+func (ma *metadataApp) delete(hostID, namespace string) error {
+	if err := ma.client.DeleteHostMetadata(hostID, namespace); err != nil {
+		return err
+	}
+	ma.logger.Log("deleted", hostID+" "+namespace)
+	return nil
+}
+
+// pull saves the metadata of the hosts into <dir>/<hostId>/<namespace>.json.
+// All the namespaces are saved when namespace is empty.
+func (ma *metadataApp) pull(dir string, hostIDs []string, namespace string) error {
+	for _, hostID := range hostIDs {
+		namespaces := []string{namespace}
+		if namespace == "" {
+			var err error
+			if namespaces, err = ma.client.ListHostMetadataNamespaces(hostID); err != nil {
+				return err
+			}
+		}
+		for _, ns := range namespaces {
+			metadata, err := ma.client.GetHostMetadata(hostID, ns)
+			if err != nil {
+				return err
+			}
+			if err := os.MkdirAll(filepath.Join(dir, hostID), 0755); err != nil {
+				return err
+			}
+			path := filepath.Join(dir, hostID, ns+".json")
+			if err := ioutil.WriteFile(path, []byte(format.JSONMarshalIndent(metadata, "", "    ")+"\n"), 0644); err != nil {
+				return err
+			}
+			ma.logger.Log("info", fmt.Sprintf("Metadata %s of %s is saved to '%s'.", ns, hostID, path))
+		}
+	}
+	return nil
+}
+
+type metadataFile struct {
+	hostID    string
+	namespace string
+	path      string
+}
+
+// listMetadataFiles lists the files of <dir>/<hostId>/<namespace>.json.
+func listMetadataFiles(dir string) ([]*metadataFile, error) {
+	paths, err := filepath.Glob(filepath.Join(dir, "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(paths)
+	files := make([]*metadataFile, 0, len(paths))
+	for _, path := range paths {
+		files = append(files, &metadataFile{
+			hostID:    filepath.Base(filepath.Dir(path)),
+			namespace: strings.TrimSuffix(filepath.Base(path), ".json"),
+			path:      path,
+		})
+	}
+	return files, nil
+}
+
+// push puts the metadata of the files in <dir>/<hostId>/<namespace>.json.
+// Only the namespace is pushed when it is not empty.
+func (ma *metadataApp) push(dir, namespace string) error {
+	files, err := listMetadataFiles(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if namespace != "" && f.namespace != namespace {
+			continue
+		}
+		file, err := os.Open(f.path)
+		if err != nil {
+			return err
+		}
+		err = ma.put(f.hostID, f.namespace, file)
+		file.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", f.path, err)
+		}
+	}
+	return nil
+}
+
+func readMetadata(r io.Reader) (json.RawMessage, error) {
+	data, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if !json.Valid(data) {
+		return nil, fmt.Errorf("metadata should be a valid JSON")
+	}
+	return json.RawMessage(data), nil
+}
@@ -0,0 +1,94 @@
+package hosts
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMetadataClient struct {
+	metadata map[string]map[string]json.RawMessage
+}
+
+func (c *fakeMetadataClient) ListHostMetadataNamespaces(hostID string) ([]string, error) {
+	var namespaces []string
+	for ns := range c.metadata[hostID] {
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces, nil
+}
+
+func (c *fakeMetadataClient) GetHostMetadata(hostID, namespace string) (json.RawMessage, error) {
+	return c.metadata[hostID][namespace], nil
+}
+
+func (c *fakeMetadataClient) PutHostMetadata(hostID, namespace string, metadata json.RawMessage) error {
+	if c.metadata[hostID] == nil {
+		c.metadata[hostID] = map[string]json.RawMessage{}
+	}
+	c.metadata[hostID][namespace] = metadata
+	return nil
+}
+
+func (c *fakeMetadataClient) DeleteHostMetadata(hostID, namespace string) error {
+	delete(c.metadata[hostID], namespace)
+	return nil
+}
+
+func TestMetadataApp_Put(t *testing.T) {
+	client := &fakeMetadataClient{metadata: map[string]map[string]json.RawMessage{}}
+	app := &metadataApp{client: client, logger: &testLogger{ioutil.Discard}, outStream: ioutil.Discard}
+
+	assert.NoError(t, app.put("foo", "app", strings.NewReader(`{"version":"1.0"}`)))
+	assert.Equal(t, `{"version":"1.0"}`, string(client.metadata["foo"]["app"]))
+
+	assert.Error(t, app.put("foo", "app", strings.NewReader(`{"version"`)))
+}
+
+func TestMetadataApp_Get(t *testing.T) {
+	client := &fakeMetadataClient{metadata: map[string]map[string]json.RawMessage{
+		"foo": {"app": json.RawMessage(`{"version":"1.0"}`)},
+	}}
+	out := new(bytes.Buffer)
+	app := &metadataApp{client: client, logger: &testLogger{ioutil.Discard}, outStream: out}
+
+	assert.NoError(t, app.get("foo", "app"))
+	assert.Equal(t, "{\n    \"version\": \"1.0\"\n}\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, app.get("foo", ""))
+	assert.Equal(t, "[\n    \"app\"\n]\n", out.String())
+}
+
+func TestMetadataApp_PullPush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-metadata")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client := &fakeMetadataClient{metadata: map[string]map[string]json.RawMessage{
+		"foo": {"app": json.RawMessage(`{"version":"1.0"}`), "db": json.RawMessage(`{"port":3306}`)},
+		"bar": {"app": json.RawMessage(`{"version":"2.0"}`)},
+	}}
+	app := &metadataApp{client: client, logger: &testLogger{ioutil.Discard}, outStream: ioutil.Discard}
+
+	assert.NoError(t, app.pull(dir, []string{"foo", "bar"}, ""))
+	files, err := listMetadataFiles(dir)
+	assert.NoError(t, err)
+	assert.Equal(t, []*metadataFile{
+		{hostID: "bar", namespace: "app", path: filepath.Join(dir, "bar", "app.json")},
+		{hostID: "foo", namespace: "app", path: filepath.Join(dir, "foo", "app.json")},
+		{hostID: "foo", namespace: "db", path: filepath.Join(dir, "foo", "db.json")},
+	}, files)
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "foo", "app.json"), []byte(`{"version":"1.1"}`), 0644))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "foo", "db.json"), []byte(`{"port":5432}`), 0644))
+	assert.NoError(t, app.push(dir, "app"))
+	assert.Equal(t, `{"version":"1.1"}`, string(client.metadata["foo"]["app"]))
+	assert.Equal(t, `{"port":3306}`, string(client.metadata["foo"]["db"]))
+}
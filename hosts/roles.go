@@ -0,0 +1,139 @@
+package hosts
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type updateRolesParam struct {
+	hostIDs []string
+	service string
+	roles   []string
+
+	remove        bool
+	exclusive     bool
+	roleFullnames []string
+
+	dryRun bool
+	// confirm is called with the prompt before updating the hosts selected by the service.
+	// The hosts are updated without confirmation if it is nil.
+	confirm func(string) bool
+}
+
+// updateRoles adds the roles to or removes them from the hosts specified by IDs or the selector.
+// With exclusive, the roles of the hosts are replaced with the roles.
+func (ha *hostApp) updateRoles(param updateRolesParam) error {
+	if len(param.roleFullnames) == 0 {
+		return fmt.Errorf("specify the roles in the form of <service>:<role>")
+	}
+	for _, fullname := range param.roleFullnames {
+		if parts := strings.SplitN(fullname, ":", 2); len(parts) != 2 || parts[0] == "" || parts[1] == "" {
+			return fmt.Errorf("role should be in the form of <service>:<role>: %s", fullname)
+		}
+	}
+	if param.remove && param.exclusive {
+		return fmt.Errorf("--exclusive cannot be specified with remove")
+	}
+
+	var hosts []*mackerel.Host
+	seen := map[string]bool{}
+	for _, hostID := range param.hostIDs {
+		if seen[hostID] {
+			continue
+		}
+		host, err := ha.client.FindHost(hostID)
+		if err != nil {
+			return err
+		}
+		seen[host.ID] = true
+		hosts = append(hosts, host)
+	}
+	if param.service != "" {
+		found, err := ha.client.FindHosts(&mackerel.FindHostsParam{Service: param.service, Roles: param.roles})
+		if err != nil {
+			return err
+		}
+		var selected []string
+		for _, host := range found {
+			if !seen[host.ID] {
+				seen[host.ID] = true
+				hosts = append(hosts, host)
+				selected = append(selected, fmt.Sprintf("%s (%s)", host.ID, host.Name))
+			}
+		}
+		if len(selected) > 0 && !param.dryRun && param.confirm != nil &&
+			!param.confirm("Update the roles of following hosts.\n  "+strings.Join(selected, "\n  ")+"\nAre you sure?") {
+			ha.log("", "update is canceled.")
+			return nil
+		}
+	}
+	if len(hosts) == 0 {
+		return fmt.Errorf("specify --host-id or --service to select hosts")
+	}
+
+	for _, host := range hosts {
+		current := host.GetRoleFullnames()
+		var fullnames []string
+		switch {
+		case param.exclusive:
+			fullnames = mergeRoleFullnames(nil, param.roleFullnames)
+		case param.remove:
+			fullnames = subtractRoleFullnames(current, param.roleFullnames)
+		default:
+			fullnames = mergeRoleFullnames(current, param.roleFullnames)
+		}
+		if sameRoleFullnames(current, fullnames) {
+			ha.log("skipped", fmt.Sprintf("%s [%s]", host.ID, strings.Join(current, ", ")))
+			continue
+		}
+		if param.dryRun {
+			ha.log("will update", fmt.Sprintf("%s [%s] -> [%s]", host.ID, strings.Join(current, ", "), strings.Join(fullnames, ", ")))
+			continue
+		}
+		if err := ha.client.UpdateHostRoleFullnames(host.ID, fullnames); err != nil {
+			return err
+		}
+		ha.log("updated", fmt.Sprintf("%s [%s]", host.ID, strings.Join(fullnames, ", ")))
+	}
+	return nil
+}
+
+func mergeRoleFullnames(current, added []string) []string {
+	seen := map[string]bool{}
+	fullnames := make([]string, 0, len(current)+len(added))
+	for _, fullname := range append(append([]string{}, current...), added...) {
+		if !seen[fullname] {
+			seen[fullname] = true
+			fullnames = append(fullnames, fullname)
+		}
+	}
+	return fullnames
+}
+
+func subtractRoleFullnames(current, removed []string) []string {
+	fullnames := make([]string, 0, len(current))
+	for _, fullname := range current {
+		if !containsString(removed, fullname) {
+			fullnames = append(fullnames, fullname)
+		}
+	}
+	return fullnames
+}
+
+func sameRoleFullnames(xs, ys []string) bool {
+	if len(xs) != len(ys) {
+		return false
+	}
+	xs, ys = append([]string{}, xs...), append([]string{}, ys...)
+	sort.Strings(xs)
+	sort.Strings(ys)
+	for i := range xs {
+		if xs[i] != ys[i] {
+			return false
+		}
+	}
+	return true
+}
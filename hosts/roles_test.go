@@ -0,0 +1,144 @@
+package hosts
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+func TestHostApp_UpdateRoles(t *testing.T) {
+	testCases := []struct {
+		id            string
+		hostIDs       []string
+		service       string
+		remove        bool
+		exclusive     bool
+		roleFullnames []string
+		dryRun        bool
+		canceled      bool
+		updated       map[string][]string
+		output        string
+		hasError      bool
+	}{
+		{
+			id:            "add",
+			hostIDs:       []string{"foo"},
+			roleFullnames: []string{"SampleService:db", "SampleService:app"},
+			updated:       map[string][]string{"foo": {"SampleService:app", "SampleService:db"}},
+			output:        "updated foo [SampleService:app, SampleService:db]\n",
+		},
+		{
+			id:            "add by selector",
+			service:       "SampleService",
+			roleFullnames: []string{"SampleService:app"},
+			updated:       map[string][]string{"bar": {"SampleService:db", "SampleService:app"}},
+			output:        "skipped foo [SampleService:app]\nupdated bar [SampleService:db, SampleService:app]\n",
+		},
+		{
+			id:            "remove",
+			hostIDs:       []string{"foo"},
+			remove:        true,
+			roleFullnames: []string{"SampleService:app"},
+			updated:       map[string][]string{"foo": {}},
+			output:        "updated foo []\n",
+		},
+		{
+			id:            "exclusive",
+			hostIDs:       []string{"foo"},
+			exclusive:     true,
+			roleFullnames: []string{"Other:web"},
+			updated:       map[string][]string{"foo": {"Other:web"}},
+			output:        "updated foo [Other:web]\n",
+		},
+		{
+			id:            "exclusive without roles",
+			hostIDs:       []string{"foo"},
+			exclusive:     true,
+			roleFullnames: []string{},
+			hasError:      true,
+		},
+		{
+			id:            "both host id and selector",
+			hostIDs:       []string{"bar", "bar"},
+			service:       "SampleService",
+			roleFullnames: []string{"SampleService:web"},
+			updated: map[string][]string{
+				"foo": {"SampleService:app", "SampleService:web"},
+				"bar": {"SampleService:db", "SampleService:web"},
+			},
+			output: "updated bar [SampleService:db, SampleService:web]\nupdated foo [SampleService:app, SampleService:web]\n",
+		},
+		{
+			id:            "canceled",
+			service:       "SampleService",
+			roleFullnames: []string{"SampleService:web"},
+			canceled:      true,
+			updated:       map[string][]string{},
+			output:        " update is canceled.\n",
+		},
+		{
+			id:            "dry run",
+			service:       "SampleService",
+			roleFullnames: []string{"SampleService:web"},
+			dryRun:        true,
+			canceled:      true,
+			updated:       map[string][]string{},
+			output:        "will update foo [SampleService:app] -> [SampleService:app, SampleService:web]\nwill update bar [SampleService:db] -> [SampleService:db, SampleService:web]\n",
+		},
+		{
+			id:            "invalid role",
+			hostIDs:       []string{"foo"},
+			roleFullnames: []string{"app"},
+			hasError:      true,
+		},
+		{
+			id:            "no hosts",
+			roleFullnames: []string{"SampleService:app"},
+			hasError:      true,
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.id, func(t *testing.T) {
+			updated := map[string][]string{}
+			client := mackerelclient.NewMockClient(
+				mackerelclient.MockFindHost(func(id string) (*mackerel.Host, error) {
+					return map[string]*mackerel.Host{"foo": sampleHost1, "bar": sampleHost2}[id], nil
+				}),
+				mackerelclient.MockFindHosts(func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+					assert.Equal(t, tc.service, param.Service)
+					return []*mackerel.Host{sampleHost1, sampleHost2}, nil
+				}),
+				mackerelclient.MockUpdateHostRoleFullnames(func(hostID string, roleFullnames []string) error {
+					updated[hostID] = roleFullnames
+					return nil
+				}),
+			)
+			out := new(bytes.Buffer)
+			app := &hostApp{
+				client:    client,
+				logger:    &testLogger{out},
+				outStream: out,
+			}
+			err := app.updateRoles(updateRolesParam{
+				hostIDs:       tc.hostIDs,
+				service:       tc.service,
+				remove:        tc.remove,
+				exclusive:     tc.exclusive,
+				roleFullnames: tc.roleFullnames,
+				dryRun:        tc.dryRun,
+				confirm:       func(string) bool { return !tc.canceled },
+			})
+			if tc.hasError {
+				assert.Error(t, err)
+				return
+			}
+			assert.NoError(t, err)
+			assert.Equal(t, tc.updated, updated)
+			assert.Equal(t, tc.output, out.String())
+		})
+	}
+}
@@ -0,0 +1,217 @@
+package hosts
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+)
+
+// the columns compared between the snapshots
+var snapshotDiffColumns = []string{
+	"name", "display-name", "status", "roles", "custom-identifier", "ip", "agent-version", "instance-type", "memo",
+}
+
+type hostFieldChange struct {
+	Field   string `json:"field"`
+	Base    string `json:"base"`
+	Current string `json:"current"`
+}
+
+type hostChange struct {
+	ID      string             `json:"id"`
+	Name    string             `json:"name"`
+	Changes []*hostFieldChange `json:"changes"`
+}
+
+type hostsDiff struct {
+	Added   []*format.Host `json:"added"`
+	Retired []*format.Host `json:"retired"`
+	Changed []*hostChange  `json:"changed"`
+}
+
+// hostKey returns the key to identify the host between the snapshots.
+// The names are used to compare the hosts in different organizations since the IDs differ.
+func hostKey(h *mackerel.Host, key string) string {
+	if key == "name" {
+		return h.Name
+	}
+	return h.ID
+}
+
+// diffHosts compares the current hosts against the base hosts.
+// The hosts which exist only in the base are reported as retired.
+func diffHosts(base, current []*mackerel.Host, key string) *hostsDiff {
+	baseHosts := make(map[string]*mackerel.Host, len(base))
+	for _, h := range base {
+		baseHosts[hostKey(h, key)] = h
+	}
+	currentHosts := make(map[string]bool, len(current))
+	d := &hostsDiff{Added: []*format.Host{}, Retired: []*format.Host{}, Changed: []*hostChange{}}
+	for _, h := range current {
+		k := hostKey(h, key)
+		currentHosts[k] = true
+		b, ok := baseHosts[k]
+		if !ok {
+			d.Added = append(d.Added, formatHost(h))
+			continue
+		}
+		var changes []*hostFieldChange
+		for _, column := range snapshotDiffColumns {
+			if bv, cv := hostColumns[column](b), hostColumns[column](h); bv != cv {
+				changes = append(changes, &hostFieldChange{Field: column, Base: bv, Current: cv})
+			}
+		}
+		if len(changes) > 0 {
+			d.Changed = append(d.Changed, &hostChange{ID: h.ID, Name: h.Name, Changes: changes})
+		}
+	}
+	for _, h := range base {
+		if !currentHosts[hostKey(h, key)] {
+			d.Retired = append(d.Retired, formatHost(h))
+		}
+	}
+	sort.SliceStable(d.Added, func(i, j int) bool { return d.Added[i].Name < d.Added[j].Name })
+	sort.SliceStable(d.Retired, func(i, j int) bool { return d.Retired[i].Name < d.Retired[j].Name })
+	sort.SliceStable(d.Changed, func(i, j int) bool { return d.Changed[i].Name < d.Changed[j].Name })
+	return d
+}
+
+func printHostsDiff(w io.Writer, d *hostsDiff, output string) error {
+	switch output {
+	case "json":
+		return format.PrettyPrintJSON(w, d)
+	case "", "text":
+	default:
+		return fmt.Errorf("output should be 'text' or 'json': %s", output)
+	}
+	for _, h := range d.Added {
+		fmt.Fprintf(w, "+ %s %s\n", h.ID, h.Name)
+	}
+	for _, h := range d.Retired {
+		fmt.Fprintf(w, "- %s %s\n", h.ID, h.Name)
+	}
+	for _, c := range d.Changed {
+		fmt.Fprintf(w, "~ %s %s\n", c.ID, c.Name)
+		for _, f := range c.Changes {
+			fmt.Fprintf(w, "    %s: %q -> %q\n", f.Field, f.Base, f.Current)
+		}
+	}
+	fmt.Fprintf(w, "%d added, %d retired, %d changed\n", len(d.Added), len(d.Retired), len(d.Changed))
+	return nil
+}
+
+// the statuses of the hosts in the snapshots unless the statuses are specified,
+// since the hosts in maintenance or powered off are omitted by the API by default
+var allHostStatuses = []string{
+	mackerel.HostStatusWorking, mackerel.HostStatusStandby, mackerel.HostStatusMaintenance, mackerel.HostStatusPoweroff,
+}
+
+func newSnapshotFindParam(service string, roles, statuses []string) *mackerel.FindHostsParam {
+	if len(statuses) == 0 {
+		statuses = allHostStatuses
+	}
+	return &mackerel.FindHostsParam{Service: service, Roles: roles, Statuses: statuses}
+}
+
+// selectSnapshotHosts returns the hosts matched to the selector, the same as the hosts returned by FindHosts.
+func selectSnapshotHosts(hosts []*mackerel.Host, selector *mackerel.FindHostsParam) []*mackerel.Host {
+	var selected []*mackerel.Host
+	for _, h := range hosts {
+		if selector.Service != "" {
+			roles, ok := h.Roles[selector.Service]
+			if !ok {
+				continue
+			}
+			if len(selector.Roles) > 0 && !containsAnyString(roles, selector.Roles) {
+				continue
+			}
+		}
+		if !containsString(selector.Statuses, h.Status) {
+			continue
+		}
+		selected = append(selected, h)
+	}
+	return selected
+}
+
+func containsAnyString(xs, ys []string) bool {
+	for _, y := range ys {
+		if containsString(xs, y) {
+			return true
+		}
+	}
+	return false
+}
+
+type snapshotHostsParam struct {
+	service  string
+	roles    []string
+	statuses []string
+	out      string
+}
+
+// snapshotHosts saves all the information of the hosts into the file, or prints it if the file is not specified.
+func (ha *hostApp) snapshotHosts(param snapshotHostsParam) error {
+	hosts, err := ha.client.FindHosts(newSnapshotFindParam(param.service, param.roles, param.statuses))
+	if err != nil {
+		return err
+	}
+	if param.out == "" {
+		return format.PrettyPrintJSON(ha.outStream, hosts)
+	}
+	f, err := os.Create(param.out)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	if err := format.WriteIndentJSON(f, hosts); err != nil {
+		return err
+	}
+	ha.log("info", fmt.Sprintf("%d hosts are saved to '%s'.", len(hosts), param.out))
+	return nil
+}
+
+func loadHostsSnapshot(path string) ([]*mackerel.Host, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var hosts []*mackerel.Host
+	if err := json.NewDecoder(f).Decode(&hosts); err != nil {
+		return nil, fmt.Errorf("failed to load the snapshot %s: %s", path, err)
+	}
+	return hosts, nil
+}
+
+type diffHostsParam struct {
+	base     []*mackerel.Host
+	service  string
+	roles    []string
+	statuses []string
+	key      string
+	output   string
+}
+
+func (ha *hostApp) diffHosts(param diffHostsParam) error {
+	if param.key != "id" && param.key != "name" {
+		return fmt.Errorf("key should be 'id' or 'name': %s", param.key)
+	}
+	if param.output != "" && param.output != "text" && param.output != "json" {
+		return fmt.Errorf("output should be 'text' or 'json': %s", param.output)
+	}
+	selector := newSnapshotFindParam(param.service, param.roles, param.statuses)
+	current, err := ha.client.FindHosts(selector)
+	if err != nil {
+		return err
+	}
+	// the base hosts may be saved with another selector
+	base := selectSnapshotHosts(param.base, selector)
+	return printHostsDiff(ha.outStream, diffHosts(base, current, param.key), param.output)
+}
@@ -0,0 +1,75 @@
+package hosts
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+func TestDiffHosts(t *testing.T) {
+	changed := *sampleHost2
+	changed.Status = mackerel.HostStatusWorking
+	changed.Roles = mackerel.Roles{"SampleService": []string{"db", "app"}}
+	added := &mackerel.Host{ID: "baz", Name: "sample.app3"}
+
+	d := diffHosts([]*mackerel.Host{sampleHost1, sampleHost2}, []*mackerel.Host{&changed, added}, "id")
+	out := new(bytes.Buffer)
+	assert.NoError(t, printHostsDiff(out, d, "text"))
+	assert.Equal(t, `+ baz sample.app3
+- foo sample.app1
+~ bar sample.app2
+    status: "standby" -> "working"
+    roles: "SampleService:db" -> "SampleService:db,SampleService:app"
+1 added, 1 retired, 1 changed
+`, out.String())
+
+	renamed := *sampleHost1
+	renamed.ID = "qux"
+	d = diffHosts([]*mackerel.Host{sampleHost1}, []*mackerel.Host{&renamed}, "name")
+	assert.Empty(t, d.Added)
+	assert.Empty(t, d.Retired)
+	assert.Empty(t, d.Changed)
+}
+
+func TestHostApp_SnapshotDiff(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-hosts-snapshot")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "hosts.json")
+
+	hosts := []*mackerel.Host{sampleHost1, sampleHost2}
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindHosts(func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+			assert.Equal(t, allHostStatuses, param.Statuses)
+			return hosts, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &hostApp{
+		client:    client,
+		logger:    &testLogger{out},
+		outStream: out,
+	}
+	assert.NoError(t, app.snapshotHosts(snapshotHostsParam{out: path}))
+
+	base, err := loadHostsSnapshot(path)
+	assert.NoError(t, err)
+	hosts = []*mackerel.Host{sampleHost1}
+	out.Reset()
+	assert.NoError(t, app.diffHosts(diffHostsParam{base: base, key: "id", output: "text"}))
+	assert.Equal(t, "- bar sample.app2\n0 added, 1 retired, 0 changed\n", out.String())
+
+	other := &mackerel.Host{ID: "qux", Name: "other.app", Status: mackerel.HostStatusWorking, Roles: mackerel.Roles{"Other": []string{"app"}}}
+	out.Reset()
+	assert.NoError(t, app.diffHosts(diffHostsParam{base: append(base, other), service: "SampleService", roles: []string{"app"}, key: "id", output: "text"}))
+	assert.Equal(t, "0 added, 0 retired, 0 changed\n", out.String())
+
+	assert.Error(t, app.diffHosts(diffHostsParam{base: base, key: "ip"}))
+}
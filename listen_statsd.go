@@ -0,0 +1,233 @@
+package main
+
+import (
+	"fmt"
+	"math"
+	"net"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandListen = cli.Command{
+	Name:  "listen",
+	Usage: "Listen for metrics sent by applications",
+	Description: `
+    Run a server which receives the metrics sent by applications and posts them to Mackerel.
+    With no subcommand specified, this will show all of subcommands.
+`,
+	Subcommands: []cli.Command{
+		commandListenStatsd,
+	},
+}
+
+var commandListenStatsd = cli.Command{
+	Name:      "statsd",
+	Usage:     "Receive statsd metrics and post the aggregated values to Mackerel",
+	ArgsUsage: "[--udp <address>] [--host-id | -H <hostId> | --host-name <name>] [--service | -s <service>] [--prefix <prefix>] [--flush-interval <duration>]",
+	Description: `
+    Listen for the statsd protocol on UDP, aggregate the metrics and post them to the host metrics
+    or the service metrics of Mackerel on every --flush-interval. The metric names are as follows,
+    prefixed with "custom." for the host metrics.
+
+        counters (|c)       <prefix>.counters.<name>.count, <prefix>.counters.<name>.rate (per second)
+        gauges (|g)         <prefix>.gauges.<name>
+        timers (|ms, |h)    <prefix>.timers.<name>.{count,lower,mean,upper,upper_90}
+        sets (|s)           <prefix>.sets.<name>.count
+
+    The sample rates (|@0.1) are taken into account for the counters and the timers. The gauges keep
+    the last value and are posted until mkr stops, and "+" or "-" of the gauge values changes the value.
+    The tags (|#tag) are ignored.
+
+    Requests "POST /api/v0/tsdb" and "POST /api/v0/services/<serviceName>/tsdb".
+`,
+	Action: doListenStatsd,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "udp", Value: ":8125", Usage: "The UDP <address> to listen on"},
+		cli.StringFlag{Name: "host-id, H", Value: "", Usage: "Post host metric values to <hostID>."},
+		mackerelclient.HostNameFlag,
+		cli.StringFlag{Name: "service, s", Value: "", Usage: "Post service metric values to <service>."},
+		cli.StringFlag{Name: "prefix", Value: "statsd", Usage: "The prefix of the metric names"},
+		cli.DurationFlag{Name: "flush-interval", Value: time.Minute, Usage: "The interval to post the aggregated values"},
+	},
+}
+
+// statsdAggregator aggregates the statsd metrics received between the flushes.
+type statsdAggregator struct {
+	mu       sync.Mutex
+	counters map[string]float64
+	gauges   map[string]float64
+	timers   map[string][]float64
+	counts   map[string]float64 // the counts of the timers with the sample rates
+	sets     map[string]map[string]bool
+}
+
+func newStatsdAggregator() *statsdAggregator {
+	a := &statsdAggregator{gauges: map[string]float64{}}
+	a.reset()
+	return a
+}
+
+func (a *statsdAggregator) reset() {
+	a.counters = map[string]float64{}
+	a.timers = map[string][]float64{}
+	a.counts = map[string]float64{}
+	a.sets = map[string]map[string]bool{}
+}
+
+// add parses a line of the statsd protocol, such as "api.requests:1|c|@0.5", and aggregates it.
+func (a *statsdAggregator) add(line string) error {
+	i := strings.Index(line, ":")
+	if i <= 0 {
+		return fmt.Errorf("invalid line: %s", line)
+	}
+	// the names of statsd may contain the characters not allowed in the metric names, e.g. "/" or spaces
+	name := sanitizeMetricName(line[:i])
+	fields := strings.Split(line[i+1:], "|")
+	if len(fields) < 2 {
+		return fmt.Errorf("invalid line: %s", line)
+	}
+	rawValue, typ := fields[0], fields[1]
+	rate := 1.0
+	for _, f := range fields[2:] {
+		if strings.HasPrefix(f, "@") {
+			r, err := strconv.ParseFloat(f[1:], 64)
+			if err != nil || r <= 0 || r > 1 {
+				return fmt.Errorf("invalid sample rate: %s", line)
+			}
+			rate = r
+		}
+	}
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if typ == "s" {
+		if a.sets[name] == nil {
+			a.sets[name] = map[string]bool{}
+		}
+		a.sets[name][rawValue] = true
+		return nil
+	}
+	value, err := strconv.ParseFloat(rawValue, 64)
+	if err != nil || math.IsNaN(value) || math.IsInf(value, 0) {
+		return fmt.Errorf("invalid value: %s", line)
+	}
+	switch typ {
+	case "c":
+		a.counters[name] += value / rate
+	case "g":
+		if strings.HasPrefix(rawValue, "+") || strings.HasPrefix(rawValue, "-") {
+			a.gauges[name] += value
+		} else {
+			a.gauges[name] = value
+		}
+	case "ms", "h":
+		a.timers[name] = append(a.timers[name], value)
+		a.counts[name] += 1 / rate
+	default:
+		return fmt.Errorf("unknown metric type %s: %s", typ, line)
+	}
+	return nil
+}
+
+// flush returns the aggregated values and resets the metrics except for the gauges.
+func (a *statsdAggregator) flush(prefix string, interval time.Duration, now time.Time) []*mackerel.MetricValue {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	var values []*mackerel.MetricValue
+	add := func(name string, value float64) {
+		if prefix != "" {
+			name = prefix + "." + name
+		}
+		values = append(values, &mackerel.MetricValue{Name: name, Value: value, Time: now.Unix()})
+	}
+	for name, count := range a.counters {
+		add("counters."+name+".count", count)
+		add("counters."+name+".rate", count/interval.Seconds())
+	}
+	for name, value := range a.gauges {
+		add("gauges."+name, value)
+	}
+	for name, timings := range a.timers {
+		sort.Float64s(timings)
+		var sum float64
+		for _, t := range timings {
+			sum += t
+		}
+		add("timers."+name+".count", a.counts[name])
+		add("timers."+name+".lower", timings[0])
+		add("timers."+name+".mean", sum/float64(len(timings)))
+		add("timers."+name+".upper", timings[len(timings)-1])
+		add("timers."+name+".upper_90", timings[int(math.Ceil(float64(len(timings))*0.9))-1])
+	}
+	for name, set := range a.sets {
+		add("sets."+name+".count", float64(len(set)))
+	}
+	a.reset()
+	sort.Slice(values, func(i, j int) bool { return values[i].Name < values[j].Name })
+	return values
+}
+
+// serveStatsd reads the packets from conn and aggregates the metrics until conn is closed.
+func serveStatsd(conn net.PacketConn, aggregator *statsdAggregator) error {
+	buf := make([]byte, 65535)
+	for {
+		n, _, err := conn.ReadFrom(buf)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(buf[:n]), "\n") {
+			if line = strings.TrimSpace(line); line == "" {
+				continue
+			}
+			if err := aggregator.add(line); err != nil {
+				logger.Log("warning", err.Error())
+			}
+		}
+	}
+}
+
+func doListenStatsd(c *cli.Context) error {
+	client := mackerelclient.NewFromContext(c)
+	target, err := metricTargetFromContext(c, client)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	interval := c.Duration("flush-interval")
+	if interval <= 0 {
+		return cli.NewExitError("flush-interval should be positive", 1)
+	}
+	prefix := c.String("prefix")
+
+	conn, err := net.ListenPacket("udp", c.String("udp"))
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	aggregator := newStatsdAggregator()
+	errCh := make(chan error, 1)
+	go func() {
+		errCh <- serveStatsd(conn, aggregator)
+	}()
+	logger.Log("info", fmt.Sprintf("listening on %s to post the metrics to the %s", conn.LocalAddr(), target))
+
+	buffer := newMetricBuffer()
+	err = runFlushLoop(func() error {
+		for _, v := range aggregator.flush(prefix, interval, time.Now()) {
+			v.Name = customMetricName(v.Name, target)
+			buffer.add(target, v)
+		}
+		return buffer.flush(client)
+	}, interval, errCh)
+	conn.Close()
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return nil
+}
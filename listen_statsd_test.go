@@ -0,0 +1,59 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestStatsdAggregator(t *testing.T) {
+	a := newStatsdAggregator()
+	lines := []string{
+		"api.requests:1|c",
+		"api.requests:2|c|@0.5",
+		"queue.size:10|g",
+		"queue.size:-3|g",
+		"db.query:30|ms",
+		"db.query:10|ms|@0.5",
+		"db.query:20|ms|#table:users",
+		"users:alice|s",
+		"users:bob|s",
+		"users:alice|s",
+		"http/GET /items:1|c",
+	}
+	for _, line := range lines {
+		if err := a.add(line); err != nil {
+			t.Errorf("%q should be accepted but: %s", line, err)
+		}
+	}
+	for _, line := range []string{"api.requests", "api.requests:1", "api.requests:x|c", "api.requests:1|c|@2", "api.requests:1|x"} {
+		if err := a.add(line); err == nil {
+			t.Errorf("err should occur for %q", line)
+		}
+	}
+
+	now := time.Unix(1600000000, 0)
+	expect := []*mackerel.MetricValue{
+		{Name: "statsd.counters.api.requests.count", Value: 5.0, Time: now.Unix()},
+		{Name: "statsd.counters.api.requests.rate", Value: 0.5, Time: now.Unix()},
+		{Name: "statsd.counters.http_GET__items.count", Value: 1.0, Time: now.Unix()},
+		{Name: "statsd.counters.http_GET__items.rate", Value: 0.1, Time: now.Unix()},
+		{Name: "statsd.gauges.queue.size", Value: 7.0, Time: now.Unix()},
+		{Name: "statsd.sets.users.count", Value: 2.0, Time: now.Unix()},
+		{Name: "statsd.timers.db.query.count", Value: 4.0, Time: now.Unix()},
+		{Name: "statsd.timers.db.query.lower", Value: 10.0, Time: now.Unix()},
+		{Name: "statsd.timers.db.query.mean", Value: 20.0, Time: now.Unix()},
+		{Name: "statsd.timers.db.query.upper", Value: 30.0, Time: now.Unix()},
+		{Name: "statsd.timers.db.query.upper_90", Value: 30.0, Time: now.Unix()},
+	}
+	if got := a.flush("statsd", 10*time.Second, now); !reflect.DeepEqual(got, expect) {
+		t.Errorf("the aggregated values should be %v but: %v", expect, got)
+	}
+
+	expect = []*mackerel.MetricValue{{Name: "gauges.queue.size", Value: 7.0, Time: now.Unix()}}
+	if got := a.flush("", 10*time.Second, now); !reflect.DeepEqual(got, expect) {
+		t.Errorf("only the gauges should be kept after the flush but: %v", got)
+	}
+}
@@ -3,21 +3,129 @@ package logger
 // Originally from github.com/motemen/ghq/utils
 
 import (
+	"encoding/json"
 	"fmt"
+	"io"
 	"os"
+	"strings"
+	"sync"
+	"time"
 
 	colorine "github.com/motemen/go-colorine"
 )
 
+// Level is the severity of log messages
+type Level int
+
+// Log levels in the order of severity
+const (
+	LevelDebug Level = iota
+	LevelInfo
+	LevelWarn
+	LevelError
+)
+
+var levelNames = []string{"debug", "info", "warn", "error"}
+
+func (l Level) String() string {
+	if l < LevelDebug || l > LevelError {
+		return fmt.Sprintf("Level(%d)", int(l))
+	}
+	return levelNames[l]
+}
+
+// ParseLevel parses the name of the log level: debug, info, warn or error
+func ParseLevel(s string) (Level, error) {
+	for i, name := range levelNames {
+		if s == name {
+			return Level(i), nil
+		}
+	}
+	if s == "warning" {
+		return LevelWarn, nil
+	}
+	return LevelInfo, fmt.Errorf("unknown log level %q: should be one of %s", s, strings.Join(levelNames, ", "))
+}
+
+// Log formats
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// the level and the format shared by all the loggers
+var (
+	mu       sync.RWMutex
+	minLevel = LevelInfo
+	format   = FormatText
+)
+
+// SetLevel sets the minimum level of the messages to be logged
+func SetLevel(s string) error {
+	l, err := ParseLevel(s)
+	if err != nil {
+		return err
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	minLevel = l
+	return nil
+}
+
+// SetFormat sets the format of the messages: text or json
+func SetFormat(s string) error {
+	if s != FormatText && s != FormatJSON {
+		return fmt.Errorf("unknown log format %q: should be %s or %s", s, FormatText, FormatJSON)
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	format = s
+	return nil
+}
+
+// DebugEnabled reports whether the debug messages are logged
+func DebugEnabled() bool {
+	return enabled(LevelDebug)
+}
+
+func enabled(l Level) bool {
+	mu.RLock()
+	defer mu.RUnlock()
+	return l >= minLevel
+}
+
+func currentFormat() string {
+	mu.RLock()
+	defer mu.RUnlock()
+	return format
+}
+
+// prefixLevel returns the level of the messages with the prefix such as "warning" or "created"
+func prefixLevel(prefix string) Level {
+	switch prefix {
+	case "debug":
+		return LevelDebug
+	case "warning", "warn":
+		return LevelWarn
+	case "error":
+		return LevelError
+	default:
+		return LevelInfo
+	}
+}
+
 // Logger is wrapped go-colorine logger for mkr
 type Logger struct {
 	logger *colorine.Logger
+	out    io.Writer
 }
 
 // New is constructor for new colorine logger
 func New() *Logger {
 	logger := &colorine.Logger{
 		Prefixes: colorine.Prefixes{
+			"debug": colorine.Verbose,
+
 			"warning": colorine.Warn,
 
 			"error": colorine.Error,
@@ -33,18 +141,55 @@ func New() *Logger {
 
 	// Default output
 	logger.SetOutput(os.Stderr)
-	return &Logger{logger: logger}
+	return &Logger{logger: logger, out: os.Stderr}
 }
 
-// Log outputs `message` with `prefix` by go-colorine
+// SetOutput sets the destination of the logger
+func (l *Logger) SetOutput(w io.Writer) {
+	l.logger.SetOutput(w)
+	l.out = w
+}
+
+type jsonEntry struct {
+	Time    string `json:"time"`
+	Level   string `json:"level"`
+	Prefix  string `json:"prefix,omitempty"`
+	Message string `json:"message"`
+}
+
+// Log outputs `message` with `prefix` by go-colorine, or in a JSON line with --log-format json.
+// The messages below the log level are discarded.
 func (l *Logger) Log(prefix, message string) {
+	level := prefixLevel(prefix)
+	if !enabled(level) {
+		return
+	}
+	if currentFormat() == FormatJSON {
+		data, err := json.Marshal(jsonEntry{
+			Time:    time.Now().Format(time.RFC3339),
+			Level:   level.String(),
+			Prefix:  prefix,
+			Message: strings.TrimSpace(message),
+		})
+		if err == nil {
+			fmt.Fprintln(l.out, string(data))
+		}
+		return
+	}
 	l.logger.Log(prefix, message)
 }
 
 // Logf outputs `message` with `prefix` by go-colorine
 func (l *Logger) Logf(prefix, message string, args ...interface{}) {
 	msg := fmt.Sprintf(message, args...)
-	l.logger.Log(prefix, msg)
+	l.Log(prefix, msg)
+}
+
+// Debugf outputs `message` in the debug level
+func (l *Logger) Debugf(message string, args ...interface{}) {
+	if enabled(LevelDebug) {
+		l.Logf("debug", message, args...)
+	}
 }
 
 // Error outputs log given non-nil `err`
@@ -64,6 +209,11 @@ func Logf(prefix, message string, args ...interface{}) {
 	defaultLogger.Logf(prefix, message, args...)
 }
 
+// Debugf outputs `message` in the debug level
+func Debugf(message string, args ...interface{}) {
+	defaultLogger.Debugf(message, args...)
+}
+
 // ErrorIf outputs log if `err` occurs.
 func ErrorIf(err error) bool {
 	if err == nil {
@@ -76,6 +226,35 @@ func ErrorIf(err error) bool {
 // DieIf outputs log and exit(1) if `err` occurs.
 func DieIf(err error) {
 	if ErrorIf(err) {
-		os.Exit(1)
+		Exit(1)
+	}
+}
+
+// the functions called before exiting by Exit
+var (
+	exitMu    sync.Mutex
+	exitHooks []func()
+)
+
+// AtExit registers the function called before exiting by Exit and DieIf.
+func AtExit(f func()) {
+	exitMu.Lock()
+	defer exitMu.Unlock()
+	exitHooks = append(exitHooks, f)
+}
+
+// Exit calls the functions registered by AtExit and exits with the code.
+func Exit(code int) {
+	runExitHooks()
+	os.Exit(code)
+}
+
+func runExitHooks() {
+	exitMu.Lock()
+	hooks := exitHooks
+	exitHooks = nil
+	exitMu.Unlock()
+	for _, f := range hooks {
+		f()
 	}
 }
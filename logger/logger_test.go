@@ -0,0 +1,90 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestParseLevel(t *testing.T) {
+	testCases := []struct {
+		name     string
+		expected Level
+		err      bool
+	}{
+		{name: "debug", expected: LevelDebug},
+		{name: "info", expected: LevelInfo},
+		{name: "warn", expected: LevelWarn},
+		{name: "warning", expected: LevelWarn},
+		{name: "error", expected: LevelError},
+		{name: "trace", expected: LevelInfo, err: true},
+	}
+	for _, tc := range testCases {
+		l, err := ParseLevel(tc.name)
+		if tc.err != (err != nil) {
+			t.Errorf("ParseLevel(%q) should raise an error: %t but got %v", tc.name, tc.err, err)
+		}
+		if l != tc.expected {
+			t.Errorf("ParseLevel(%q) should be %s but got %s", tc.name, tc.expected, l)
+		}
+	}
+}
+
+func TestLogger_Log_level(t *testing.T) {
+	defer SetLevel("info")
+	if err := SetLevel("warn"); err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	l := New()
+	l.SetOutput(out)
+	l.Log("created", "a host is created")
+	l.Debugf("a debug message")
+	l.Log("warning", "a warning message")
+	l.Error(bytes.ErrTooLarge)
+
+	got := out.String()
+	for _, msg := range []string{"a host is created", "a debug message"} {
+		if strings.Contains(got, msg) {
+			t.Errorf("%q should be discarded but got %q", msg, got)
+		}
+	}
+	for _, msg := range []string{"a warning message", bytes.ErrTooLarge.Error()} {
+		if !strings.Contains(got, msg) {
+			t.Errorf("%q should be logged but got %q", msg, got)
+		}
+	}
+}
+
+func TestLogger_Log_json(t *testing.T) {
+	defer SetFormat("text")
+	if err := SetFormat("json"); err != nil {
+		t.Fatal(err)
+	}
+	if err := SetFormat("xml"); err == nil {
+		t.Errorf("SetFormat should raise an error for an unknown format")
+	}
+	out := new(bytes.Buffer)
+	l := New()
+	l.SetOutput(out)
+	l.Logf("created", "host %s is created", "abcde")
+
+	var entry map[string]string
+	if err := json.Unmarshal(out.Bytes(), &entry); err != nil {
+		t.Fatalf("the log should be a JSON line but got %q: %s", out.String(), err)
+	}
+	if entry["level"] != "info" || entry["prefix"] != "created" || entry["message"] != "host abcde is created" || entry["time"] == "" {
+		t.Errorf("unexpected log entry: %v", entry)
+	}
+}
+
+func TestAtExit(t *testing.T) {
+	var called int
+	AtExit(func() { called++ })
+	runExitHooks()
+	runExitHooks()
+	if called != 1 {
+		t.Errorf("the hook should be called once but: %d", called)
+	}
+}
@@ -0,0 +1,185 @@
+package mackerelclient
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httputil"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// DefaultCacheTTL is the default time to live of the cached responses
+const DefaultCacheTTL = 5 * time.Minute
+
+// CacheOptions is the options of the local cache of the API responses
+type CacheOptions struct {
+	// Enabled caches the responses of the GET requests
+	Enabled bool
+	// TTL is the time to live of the cached responses
+	TTL time.Duration
+	// Offline serves the responses only from the cache regardless of the TTL
+	Offline bool
+	// Dir is the directory of the cache. DefaultCacheDir is used if empty
+	Dir string
+}
+
+var (
+	cacheOptionsMu sync.RWMutex
+	cacheOptions   = CacheOptions{TTL: DefaultCacheTTL}
+)
+
+// SetCacheOptions sets the options of the cache of the clients created by NewClient
+func SetCacheOptions(opts CacheOptions) error {
+	if opts.TTL < 0 {
+		return fmt.Errorf("the cache TTL should not be negative: %s", opts.TTL)
+	}
+	cacheOptionsMu.Lock()
+	defer cacheOptionsMu.Unlock()
+	cacheOptions = opts
+	return nil
+}
+
+func currentCacheOptions() CacheOptions {
+	cacheOptionsMu.RLock()
+	defer cacheOptionsMu.RUnlock()
+	return cacheOptions
+}
+
+// DefaultCacheDir returns $XDG_CACHE_HOME/mkr, or ~/.cache/mkr
+func DefaultCacheDir() string {
+	dir := os.Getenv("XDG_CACHE_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".cache")
+	}
+	return filepath.Join(dir, "mkr")
+}
+
+// resourcePaths are the prefixes of the API paths of the resources whose cache is cleared when they are modified
+var resourcePaths = []string{
+	"/api/v0/hosts",
+	"/api/v0/monitors",
+	"/api/v0/dashboards",
+	"/api/v0/services",
+}
+
+// cachePathPattern matches the API paths of the lists and the details of the resources whose responses are cached.
+// The metrics of the resources such as /api/v0/hosts/<hostId>/metrics are not cached since they change every minute.
+var cachePathPattern = regexp.MustCompile(`^/api/v0/(hosts|monitors|dashboards|services)(\.json|/[^/]+)?$`)
+
+func isCachePath(path string) bool {
+	return cachePathPattern.MatchString(path)
+}
+
+func isResourcePath(path string) bool {
+	for _, p := range resourcePaths {
+		if strings.HasPrefix(path, p) {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheTransport serves the responses of the GET requests from the files in the cache directory.
+// The cache is separated by the apikeys, and the cache of the apikey is cleared when the resources are modified.
+type cacheTransport struct {
+	transport http.RoundTripper
+	dir       string
+	ttl       time.Duration
+	offline   bool
+	now       func() time.Time
+}
+
+func (t *cacheTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	dir := t.keyDir(req)
+	if req.Method != http.MethodGet && isResourcePath(req.URL.Path) {
+		if t.offline {
+			return nil, fmt.Errorf("cannot request %s %s in the offline mode", req.Method, req.URL.Path)
+		}
+		resp, err := t.transport.RoundTrip(req)
+		if err == nil && resp.StatusCode < 300 {
+			os.RemoveAll(dir)
+		}
+		return resp, err
+	}
+	if req.Method != http.MethodGet || !isCachePath(req.URL.Path) {
+		if t.offline {
+			return nil, fmt.Errorf("cannot request %s %s in the offline mode", req.Method, req.URL.Path)
+		}
+		return t.transport.RoundTrip(req)
+	}
+
+	file := filepath.Join(dir, hashString(req.URL.String())+".cache")
+	if resp, err := t.load(file, req); err == nil {
+		return resp, nil
+	} else if t.offline {
+		return nil, fmt.Errorf("%s is not cached for the offline mode: %s", req.URL.Path, err)
+	}
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil || resp.StatusCode != http.StatusOK {
+		return resp, err
+	}
+	data, err := httputil.DumpResponse(resp, true)
+	if err != nil {
+		return nil, err
+	}
+	// the cache is not essential, so the failures of saving it are ignored
+	if err := os.MkdirAll(dir, 0700); err == nil {
+		writeFileAtomic(file, data)
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+}
+
+// keyDir returns the cache directory of the apikey, which is not saved in plaintext
+func (t *cacheTransport) keyDir(req *http.Request) string {
+	return filepath.Join(t.dir, hashString(req.Header.Get("X-Api-Key") + "\n" + req.URL.Host)[:16])
+}
+
+func (t *cacheTransport) load(file string, req *http.Request) (*http.Response, error) {
+	fi, err := os.Stat(file)
+	if err != nil {
+		return nil, err
+	}
+	if !t.offline && t.now().Sub(fi.ModTime()) > t.ttl {
+		return nil, fmt.Errorf("the cache is expired")
+	}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(data)), req)
+}
+
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := ioutil.TempFile(filepath.Dir(file), ".tmp-")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), file)
+}
+
+func hashString(s string) string {
+	sum := sha256.Sum256([]byte(s))
+	return hex.EncodeToString(sum[:])
+}
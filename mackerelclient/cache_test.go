@@ -0,0 +1,129 @@
+package mackerelclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestCacheTransport(t *testing.T) {
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&count, 1)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"services":[{"name":"foo"}]}`))
+	}))
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "mkr-cache-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	newTransport := func(offline bool) *cacheTransport {
+		return &cacheTransport{
+			transport: http.DefaultTransport,
+			dir:       dir,
+			ttl:       5 * time.Minute,
+			offline:   offline,
+			now:       func() time.Time { return now },
+		}
+	}
+	request := func(rt http.RoundTripper, method, path, apikey string) (string, error) {
+		req, err := http.NewRequest(method, ts.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("X-Api-Key", apikey)
+		resp, err := rt.RoundTrip(req)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		data, err := ioutil.ReadAll(resp.Body)
+		return string(data), err
+	}
+	expected := `{"services":[{"name":"foo"}]}`
+
+	// the first request is cached
+	body, err := request(newTransport(false), "GET", "/api/v0/services", "apikey")
+	if err != nil || body != expected {
+		t.Fatalf("unexpected response: %q, %v", body, err)
+	}
+	now = time.Now().Add(time.Minute)
+	body, err = request(newTransport(false), "GET", "/api/v0/services", "apikey")
+	if err != nil || body != expected {
+		t.Fatalf("unexpected response: %q, %v", body, err)
+	}
+	if count != 1 {
+		t.Errorf("the second request should be served from the cache but requested %d times", count)
+	}
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && !info.IsDir() {
+			if data, _ := ioutil.ReadFile(path); strings.Contains(path+string(data), "apikey") {
+				t.Errorf("the apikey should not be saved in the cache: %s", path)
+			}
+		}
+		return nil
+	})
+
+	// the cache is separated by the apikeys
+	request(newTransport(false), "GET", "/api/v0/services", "another-apikey")
+	if count != 2 {
+		t.Errorf("the cache of another apikey should not be used")
+	}
+
+	// the requests of the other paths are not cached
+	request(newTransport(false), "GET", "/api/v0/alerts", "apikey")
+	request(newTransport(false), "GET", "/api/v0/alerts", "apikey")
+	if count != 4 {
+		t.Errorf("the alerts should not be cached")
+	}
+
+	// the expired cache is not used except in the offline mode
+	now = time.Now().Add(10 * time.Minute)
+	if body, err := request(newTransport(true), "GET", "/api/v0/services", "apikey"); err != nil || body != expected {
+		t.Errorf("the expired cache should be used in the offline mode: %q, %v", body, err)
+	}
+	if count != 4 {
+		t.Errorf("the offline mode should not request the API")
+	}
+	request(newTransport(false), "GET", "/api/v0/services", "apikey")
+	if count != 5 {
+		t.Errorf("the expired cache should not be used")
+	}
+
+	// the offline mode fails without the cache
+	if _, err := request(newTransport(true), "GET", "/api/v0/hosts.json", "apikey"); err == nil {
+		t.Errorf("the offline mode should fail without the cache")
+	}
+	if _, err := request(newTransport(true), "POST", "/api/v0/services", "apikey"); err == nil {
+		t.Errorf("the offline mode should fail to modify the resources")
+	}
+
+	// the cache is cleared when the resources are modified
+	now = time.Now()
+	request(newTransport(false), "POST", "/api/v0/services", "apikey")
+	request(newTransport(false), "GET", "/api/v0/services", "apikey")
+	if count != 7 {
+		t.Errorf("the cache should be cleared after the modification but requested %d times", count)
+	}
+
+	// the metrics of the resources are not cached
+	for _, path := range []string{"/api/v0/hosts/2u4PP3TJqbw/metrics", "/api/v0/hosts/2u4PP3TJqbw/metric-names", "/api/v0/services/blog/metrics"} {
+		before := count
+		request(newTransport(false), "GET", path, "apikey")
+		request(newTransport(false), "GET", path, "apikey")
+		if count != before+2 {
+			t.Errorf("%s should not be cached", path)
+		}
+	}
+}
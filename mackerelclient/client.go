@@ -5,9 +5,26 @@ import "github.com/mackerelio/mackerel-client-go"
 // Client represents a client of Mackerel API
 type Client interface {
 	FindHosts(param *mackerel.FindHostsParam) ([]*mackerel.Host, error)
+	FindHost(id string) (*mackerel.Host, error)
 	FindServices() ([]*mackerel.Service, error)
+	CreateService(param *mackerel.CreateServiceParam) (*mackerel.Service, error)
+	DeleteService(serviceName string) (*mackerel.Service, error)
+	FindRoles(serviceName string) ([]*mackerel.Role, error)
+	CreateRole(serviceName string, param *mackerel.CreateRoleParam) (*mackerel.Role, error)
+	DeleteRole(serviceName, roleName string) (*mackerel.Role, error)
 	FindChannels() ([]*mackerel.Channel, error)
+	CreateChannel(param *mackerel.Channel) (*mackerel.Channel, error)
+	DeleteChannel(id string) (*mackerel.Channel, error)
+	FindDowntimes() ([]*mackerel.Downtime, error)
+	CreateDowntime(param *mackerel.Downtime) (*mackerel.Downtime, error)
+	UpdateDowntime(downtimeID string, param *mackerel.Downtime) (*mackerel.Downtime, error)
+	DeleteDowntime(downtimeID string) (*mackerel.Downtime, error)
+	FindNotificationGroups() ([]*mackerel.NotificationGroup, error)
+	CreateNotificationGroup(param *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error)
+	UpdateNotificationGroup(id string, param *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error)
+	DeleteNotificationGroup(id string) (*mackerel.NotificationGroup, error)
 	GetOrg() (*mackerel.Org, error)
 	CreateHost(param *mackerel.CreateHostParam) (string, error)
 	UpdateHostStatus(hostID string, status string) error
+	UpdateHostRoleFullnames(hostID string, roleFullnames []string) error
 }
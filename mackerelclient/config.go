@@ -55,3 +55,22 @@ func LoadHostIDFromConfig(conffile string) string {
 	}
 	return hostID
 }
+
+// Apikey represents an apikey with the description of where it is configured
+type Apikey struct {
+	Value  string
+	Source string
+}
+
+// LoadApikeys returns the configured apikeys in the order of precedence,
+// that is MACKEREL_APIKEY environment value and the apikey in mackerel-agent.conf
+func LoadApikeys(conffile string) []*Apikey {
+	var apikeys []*Apikey
+	if apiKey := os.Getenv("MACKEREL_APIKEY"); apiKey != "" {
+		apikeys = append(apikeys, &Apikey{Value: apiKey, Source: "MACKEREL_APIKEY environment variable"})
+	}
+	if apiKey := LoadApikeyFromConfig(conffile); apiKey != "" {
+		apikeys = append(apikeys, &Apikey{Value: apiKey, Source: "config file (" + conffile + ")"})
+	}
+	return apikeys
+}
@@ -76,3 +76,26 @@ func TestLoadHostIDFromConfig(t *testing.T) {
 		t.Error("should be 9876ABCD")
 	}
 }
+
+func TestLoadApikeys(t *testing.T) {
+	conffile := "testdata/mackerel-agent.conf"
+
+	os.Setenv("MACKEREL_APIKEY", "")
+	apikeys := LoadApikeys(conffile)
+	if len(apikeys) != 1 {
+		t.Fatalf("should have 1 apikey but got %d", len(apikeys))
+	}
+	if apikeys[0].Value != "123456ABCD" || apikeys[0].Source != "config file (testdata/mackerel-agent.conf)" {
+		t.Errorf("unexpected apikey: %+v", apikeys[0])
+	}
+
+	os.Setenv("MACKEREL_APIKEY", "ENV123456ABCD")
+	defer os.Setenv("MACKEREL_APIKEY", "")
+	apikeys = LoadApikeys(conffile)
+	if len(apikeys) != 2 {
+		t.Fatalf("should have 2 apikeys but got %d", len(apikeys))
+	}
+	if apikeys[0].Value != "ENV123456ABCD" || apikeys[0].Source != "MACKEREL_APIKEY environment variable" {
+		t.Errorf("unexpected apikey: %+v", apikeys[0])
+	}
+}
@@ -0,0 +1,107 @@
+package mackerelclient
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/urfave/cli"
+)
+
+// HostNamePrefix is the prefix of the host IDs to specify the hosts by the names, e.g. name:app001.
+const HostNamePrefix = "name:"
+
+// HostNameFlag is the flag to specify the host by the name instead of the host ID flag.
+var HostNameFlag = cli.StringFlag{
+	Name:  "host-name",
+	Value: "",
+	Usage: "Specify the host by <name> instead of the host ID. The host ID also accepts name:<name>",
+}
+
+// HostNamesFlag is HostNameFlag of the commands accepting multiple hosts.
+var HostNamesFlag = cli.StringSliceFlag{
+	Name:  "host-name",
+	Value: &cli.StringSlice{},
+	Usage: "Specify the hosts by <name> instead of the host IDs. Multiple choices are allowed. The host IDs also accept name:<name>",
+}
+
+// HostFinder finds the hosts to resolve the names
+type HostFinder interface {
+	FindHosts(param *mackerel.FindHostsParam) ([]*mackerel.Host, error)
+}
+
+// ResolveHostName returns the ID of the host with the name. It fails if no host or
+// multiple hosts have the name, listing the candidates in the latter case.
+func ResolveHostName(client HostFinder, name string) (string, error) {
+	hosts, err := client.FindHosts(&mackerel.FindHostsParam{
+		Name:     name,
+		Statuses: []string{"working", "standby", "maintenance", "poweroff"},
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to find the host %s: %s", name, err)
+	}
+	switch len(hosts) {
+	case 0:
+		return "", fmt.Errorf("no host is named %s", name)
+	case 1:
+		return hosts[0].ID, nil
+	}
+	candidates := make([]string, 0, len(hosts))
+	for _, h := range hosts {
+		candidates = append(candidates, fmt.Sprintf("%s %s (%s) [%s]", h.ID, h.Name, h.Status, strings.Join(h.GetRoleFullnames(), ", ")))
+	}
+	return "", fmt.Errorf("%d hosts are named %s. Specify one of them by the ID:\n  %s", len(hosts), name, strings.Join(candidates, "\n  "))
+}
+
+// ResolveHostID returns the host ID, resolving the name of the host if it has HostNamePrefix.
+func ResolveHostID(client HostFinder, hostID string) (string, error) {
+	if !strings.HasPrefix(hostID, HostNamePrefix) {
+		return hostID, nil
+	}
+	return ResolveHostName(client, strings.TrimPrefix(hostID, HostNamePrefix))
+}
+
+// ResolveHostIDs resolves the host IDs with ResolveHostID.
+func ResolveHostIDs(client HostFinder, hostIDs []string) ([]string, error) {
+	if hostIDs == nil {
+		return nil, nil
+	}
+	resolved := make([]string, 0, len(hostIDs))
+	for _, hostID := range hostIDs {
+		id, err := ResolveHostID(client, hostID)
+		if err != nil {
+			return nil, err
+		}
+		resolved = append(resolved, id)
+	}
+	return resolved, nil
+}
+
+// HostIDFromContext returns the host ID specified by the flag or HostNameFlag,
+// or an empty string if neither is specified.
+func HostIDFromContext(c *cli.Context, client HostFinder, flag string) (string, error) {
+	hostID, name := c.String(flag), c.String(HostNameFlag.Name)
+	if name == "" {
+		return ResolveHostID(client, hostID)
+	}
+	if hostID != "" {
+		return "", fmt.Errorf("specify either --%s or --%s", flag, HostNameFlag.Name)
+	}
+	return ResolveHostName(client, name)
+}
+
+// HostIDsFromContext returns the host IDs specified by the flag and HostNamesFlag.
+func HostIDsFromContext(c *cli.Context, client HostFinder, flag string) ([]string, error) {
+	hostIDs, err := ResolveHostIDs(client, c.StringSlice(flag))
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range c.StringSlice(HostNamesFlag.Name) {
+		id, err := ResolveHostName(client, name)
+		if err != nil {
+			return nil, err
+		}
+		hostIDs = append(hostIDs, id)
+	}
+	return hostIDs, nil
+}
@@ -0,0 +1,47 @@
+package mackerelclient
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestResolveHostIDs(t *testing.T) {
+	client := NewMockClient(MockFindHosts(func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+		if len(param.Statuses) != 4 {
+			t.Errorf("all the statuses should be searched: %v", param.Statuses)
+		}
+		switch param.Name {
+		case "app001":
+			return []*mackerel.Host{{ID: "host1", Name: "app001"}}, nil
+		case "app002":
+			return []*mackerel.Host{
+				{ID: "host2", Name: "app002", Status: "working", Roles: mackerel.Roles{"blog": {"app"}}},
+				{ID: "host3", Name: "app002", Status: "poweroff"},
+			}, nil
+		}
+		return nil, nil
+	}))
+
+	hostIDs, err := ResolveHostIDs(client, []string{"host0", "name:app001"})
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	if expect := []string{"host0", "host1"}; !reflect.DeepEqual(hostIDs, expect) {
+		t.Errorf("host IDs should be %v but: %v", expect, hostIDs)
+	}
+
+	testCases := []struct {
+		hostID string
+		err    string
+	}{
+		{"name:app002", "2 hosts are named app002. Specify one of them by the ID:\n  host2 app002 (working) [blog:app]\n  host3 app002 (poweroff) []"},
+		{"name:app003", "no host is named app003"},
+	}
+	for _, tc := range testCases {
+		if _, err := ResolveHostID(client, tc.hostID); err == nil || err.Error() != tc.err {
+			t.Errorf("ResolveHostID(%q) should fail with %q but: %v", tc.hostID, tc.err, err)
+		}
+	}
+}
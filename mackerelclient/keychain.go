@@ -0,0 +1,111 @@
+package mackerelclient
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+)
+
+// keychainService is the service name of the apikeys stored in the OS keychain
+const keychainService = "mkr"
+
+// The commands to access the OS keychain, which are replaced in tests
+var (
+	keychainGOOS    = runtime.GOOS
+	keychainCommand = exec.Command
+)
+
+// the PowerShell scripts to access the Credential Manager on Windows.
+// The account is given by the environment variable and the apikey is given by stdin.
+const (
+	windowsCredentialVault = `[void][Windows.Security.Credentials.PasswordVault,Windows.Security.Credentials,ContentType=WindowsRuntime]; $vault = New-Object Windows.Security.Credentials.PasswordVault; `
+	windowsStoreScript     = windowsCredentialVault + `$vault.Add((New-Object Windows.Security.Credentials.PasswordCredential($env:MKR_KEYCHAIN_SERVICE, $env:MKR_KEYCHAIN_ACCOUNT, [Console]::In.ReadLine())))`
+	windowsLoadScript      = windowsCredentialVault + `$c = $vault.Retrieve($env:MKR_KEYCHAIN_SERVICE, $env:MKR_KEYCHAIN_ACCOUNT); $c.RetrievePassword(); $c.Password`
+)
+
+// StoreKeychainApikey stores the apikey of the profile in the OS keychain.
+// It uses security(1) on macOS, secret-tool(1) of Secret Service on Linux and the Credential Manager on Windows.
+// The apikey is given to the commands by stdin, not by the arguments which can be seen by other processes.
+func StoreKeychainApikey(profile, apikey string) error {
+	var cmd *exec.Cmd
+	switch keychainGOOS {
+	case "darwin":
+		// the commands of security -i are read from stdin
+		cmd = keychainCommand("security", "-i")
+		cmd.Stdin = strings.NewReader(fmt.Sprintf("add-generic-password -U -s %s -a %s -w %s\n",
+			quoteSecurityArg(keychainService), quoteSecurityArg(profile), quoteSecurityArg(apikey)))
+	case "linux":
+		cmd = keychainCommand("secret-tool", "store", "--label", fmt.Sprintf("mkr apikey (%s)", profile), "service", keychainService, "account", profile)
+		cmd.Stdin = strings.NewReader(apikey)
+	case "windows":
+		cmd = keychainCommand("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsStoreScript)
+		cmd.Env = windowsKeychainEnv(profile)
+		cmd.Stdin = strings.NewReader(apikey + "\n")
+	default:
+		return fmt.Errorf("the keychain is not supported on %s. Use apikey_command instead", keychainGOOS)
+	}
+	return runKeychainCommand(cmd)
+}
+
+// LoadKeychainApikey loads the apikey of the profile from the OS keychain
+func LoadKeychainApikey(profile string) (string, error) {
+	var cmd *exec.Cmd
+	switch keychainGOOS {
+	case "darwin":
+		cmd = keychainCommand("security", "find-generic-password", "-s", keychainService, "-a", profile, "-w")
+	case "linux":
+		cmd = keychainCommand("secret-tool", "lookup", "service", keychainService, "account", profile)
+	case "windows":
+		cmd = keychainCommand("powershell", "-NoProfile", "-NonInteractive", "-Command", windowsLoadScript)
+		cmd.Env = windowsKeychainEnv(profile)
+	default:
+		return "", fmt.Errorf("the keychain is not supported on %s. Use apikey_command instead", keychainGOOS)
+	}
+	var stdout bytes.Buffer
+	cmd.Stdout = &stdout
+	if err := runKeychainCommand(cmd); err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
+
+// quoteSecurityArg quotes the argument of the commands read by security -i
+func quoteSecurityArg(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+func windowsKeychainEnv(profile string) []string {
+	return append(os.Environ(), "MKR_KEYCHAIN_SERVICE="+keychainService, "MKR_KEYCHAIN_ACCOUNT="+profile)
+}
+
+func runKeychainCommand(cmd *exec.Cmd) error {
+	var stderr bytes.Buffer
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("failed to access the keychain by %s: %s %s", cmd.Args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
+
+// runApikeyCommand runs the command by the shell and returns the output as the apikey
+func runApikeyCommand(command string) (string, error) {
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/c", command)
+	} else {
+		cmd = exec.Command("sh", "-c", command)
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("failed to run apikey_command: %s %s", err, strings.TrimSpace(stderr.String()))
+	}
+	apikey := strings.TrimSpace(stdout.String())
+	if apikey == "" {
+		return "", fmt.Errorf("apikey_command printed no apikey")
+	}
+	return apikey, nil
+}
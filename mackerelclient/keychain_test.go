@@ -0,0 +1,54 @@
+package mackerelclient
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestStoreKeychainApikey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-keychain")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	stdinFile := filepath.Join(dir, "stdin")
+
+	origGOOS, origCommand := keychainGOOS, keychainCommand
+	defer func() { keychainGOOS, keychainCommand = origGOOS, origCommand }()
+	var args []string
+	keychainCommand = func(name string, arg ...string) *exec.Cmd {
+		args = append([]string{name}, arg...)
+		return exec.Command("sh", "-c", "cat > "+stdinFile)
+	}
+	for _, goos := range []string{"darwin", "linux", "windows"} {
+		keychainGOOS = goos
+		if err := StoreKeychainApikey("prod", "SECRET123"); err != nil {
+			t.Fatalf("%s: %s", goos, err)
+		}
+		if strings.Contains(strings.Join(args, " "), "SECRET123") {
+			t.Errorf("%s: the apikey should not be given by the arguments: %v", goos, args)
+		}
+		stdin, err := ioutil.ReadFile(stdinFile)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(string(stdin), "SECRET123") {
+			t.Errorf("%s: the apikey should be given by stdin but: %q", goos, stdin)
+		}
+	}
+
+	keychainGOOS = "plan9"
+	if err := StoreKeychainApikey("prod", "SECRET123"); err == nil {
+		t.Error("the keychain on plan9 should be an error")
+	}
+}
+
+func TestQuoteSecurityArg(t *testing.T) {
+	if got, expected := quoteSecurityArg(`a "b" \c`), `"a \"b\" \\c"`; got != expected {
+		t.Errorf("quoteSecurityArg should return %s but: %s", expected, got)
+	}
+}
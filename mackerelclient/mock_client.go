@@ -4,12 +4,29 @@ import "github.com/mackerelio/mackerel-client-go"
 
 // MockClient represents a mock client of Mackerel API
 type MockClient struct {
-	findHostsCallback        func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error)
-	findServicesCallback     func() ([]*mackerel.Service, error)
-	findChannelsCallback     func() ([]*mackerel.Channel, error)
-	getOrgCallback           func() (*mackerel.Org, error)
-	createHostCallback       func(param *mackerel.CreateHostParam) (string, error)
-	updateHostStatusCallback func(hostID string, status string) error
+	findHostsCallback               func(param *mackerel.FindHostsParam) ([]*mackerel.Host, error)
+	findHostCallback                func(id string) (*mackerel.Host, error)
+	findServicesCallback            func() ([]*mackerel.Service, error)
+	findChannelsCallback            func() ([]*mackerel.Channel, error)
+	createChannelCallback           func(*mackerel.Channel) (*mackerel.Channel, error)
+	deleteChannelCallback           func(string) (*mackerel.Channel, error)
+	findDowntimesCallback           func() ([]*mackerel.Downtime, error)
+	createDowntimeCallback          func(*mackerel.Downtime) (*mackerel.Downtime, error)
+	updateDowntimeCallback          func(string, *mackerel.Downtime) (*mackerel.Downtime, error)
+	deleteDowntimeCallback          func(string) (*mackerel.Downtime, error)
+	createServiceCallback           func(*mackerel.CreateServiceParam) (*mackerel.Service, error)
+	deleteServiceCallback           func(string) (*mackerel.Service, error)
+	findRolesCallback               func(string) ([]*mackerel.Role, error)
+	createRoleCallback              func(string, *mackerel.CreateRoleParam) (*mackerel.Role, error)
+	deleteRoleCallback              func(string, string) (*mackerel.Role, error)
+	findNotificationGroupsCallback  func() ([]*mackerel.NotificationGroup, error)
+	createNotificationGroupCallback func(*mackerel.NotificationGroup) (*mackerel.NotificationGroup, error)
+	updateNotificationGroupCallback func(string, *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error)
+	deleteNotificationGroupCallback func(string) (*mackerel.NotificationGroup, error)
+	getOrgCallback                  func() (*mackerel.Org, error)
+	createHostCallback              func(param *mackerel.CreateHostParam) (string, error)
+	updateHostStatusCallback        func(hostID string, status string) error
+	updateHostRoleFullnamesCallback func(hostID string, roleFullnames []string) error
 }
 
 // MockClientOption represents an option of mock client of Mackerel API
@@ -50,6 +67,21 @@ func MockFindHosts(callback func(param *mackerel.FindHostsParam) ([]*mackerel.Ho
 	}
 }
 
+// FindHost ...
+func (c *MockClient) FindHost(id string) (*mackerel.Host, error) {
+	if c.findHostCallback != nil {
+		return c.findHostCallback(id)
+	}
+	return nil, errCallbackNotFound("FindHost")
+}
+
+// MockFindHost returns an option to set the callback of FindHost
+func MockFindHost(callback func(id string) (*mackerel.Host, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.findHostCallback = callback
+	}
+}
+
 // FindServices ...
 func (c *MockClient) FindServices() ([]*mackerel.Service, error) {
 	if c.findServicesCallback != nil {
@@ -80,6 +112,156 @@ func MockFindChannels(callback func() ([]*mackerel.Channel, error)) MockClientOp
 	}
 }
 
+// CreateChannel ...
+func (c *MockClient) CreateChannel(param *mackerel.Channel) (*mackerel.Channel, error) {
+	if c.createChannelCallback != nil {
+		return c.createChannelCallback(param)
+	}
+	return nil, errCallbackNotFound("CreateChannel")
+}
+
+// MockCreateChannel returns an option to set the callback of CreateChannel
+func MockCreateChannel(callback func(*mackerel.Channel) (*mackerel.Channel, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.createChannelCallback = callback
+	}
+}
+
+// DeleteChannel ...
+func (c *MockClient) DeleteChannel(id string) (*mackerel.Channel, error) {
+	if c.deleteChannelCallback != nil {
+		return c.deleteChannelCallback(id)
+	}
+	return nil, errCallbackNotFound("DeleteChannel")
+}
+
+// MockDeleteChannel returns an option to set the callback of DeleteChannel
+func MockDeleteChannel(callback func(string) (*mackerel.Channel, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.deleteChannelCallback = callback
+	}
+}
+
+// FindDowntimes ...
+func (c *MockClient) FindDowntimes() ([]*mackerel.Downtime, error) {
+	if c.findDowntimesCallback != nil {
+		return c.findDowntimesCallback()
+	}
+	return nil, errCallbackNotFound("FindDowntimes")
+}
+
+// MockFindDowntimes returns an option to set the callback of FindDowntimes
+func MockFindDowntimes(callback func() ([]*mackerel.Downtime, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.findDowntimesCallback = callback
+	}
+}
+
+// CreateDowntime ...
+func (c *MockClient) CreateDowntime(param *mackerel.Downtime) (*mackerel.Downtime, error) {
+	if c.createDowntimeCallback != nil {
+		return c.createDowntimeCallback(param)
+	}
+	return nil, errCallbackNotFound("CreateDowntime")
+}
+
+// MockCreateDowntime returns an option to set the callback of CreateDowntime
+func MockCreateDowntime(callback func(*mackerel.Downtime) (*mackerel.Downtime, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.createDowntimeCallback = callback
+	}
+}
+
+// UpdateDowntime ...
+func (c *MockClient) UpdateDowntime(downtimeID string, param *mackerel.Downtime) (*mackerel.Downtime, error) {
+	if c.updateDowntimeCallback != nil {
+		return c.updateDowntimeCallback(downtimeID, param)
+	}
+	return nil, errCallbackNotFound("UpdateDowntime")
+}
+
+// MockUpdateDowntime returns an option to set the callback of UpdateDowntime
+func MockUpdateDowntime(callback func(string, *mackerel.Downtime) (*mackerel.Downtime, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.updateDowntimeCallback = callback
+	}
+}
+
+// DeleteDowntime ...
+func (c *MockClient) DeleteDowntime(downtimeID string) (*mackerel.Downtime, error) {
+	if c.deleteDowntimeCallback != nil {
+		return c.deleteDowntimeCallback(downtimeID)
+	}
+	return nil, errCallbackNotFound("DeleteDowntime")
+}
+
+// MockDeleteDowntime returns an option to set the callback of DeleteDowntime
+func MockDeleteDowntime(callback func(string) (*mackerel.Downtime, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.deleteDowntimeCallback = callback
+	}
+}
+
+// FindNotificationGroups ...
+func (c *MockClient) FindNotificationGroups() ([]*mackerel.NotificationGroup, error) {
+	if c.findNotificationGroupsCallback != nil {
+		return c.findNotificationGroupsCallback()
+	}
+	return nil, errCallbackNotFound("FindNotificationGroups")
+}
+
+// MockFindNotificationGroups returns an option to set the callback of FindNotificationGroups
+func MockFindNotificationGroups(callback func() ([]*mackerel.NotificationGroup, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.findNotificationGroupsCallback = callback
+	}
+}
+
+// CreateNotificationGroup ...
+func (c *MockClient) CreateNotificationGroup(param *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error) {
+	if c.createNotificationGroupCallback != nil {
+		return c.createNotificationGroupCallback(param)
+	}
+	return nil, errCallbackNotFound("CreateNotificationGroup")
+}
+
+// MockCreateNotificationGroup returns an option to set the callback of CreateNotificationGroup
+func MockCreateNotificationGroup(callback func(*mackerel.NotificationGroup) (*mackerel.NotificationGroup, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.createNotificationGroupCallback = callback
+	}
+}
+
+// UpdateNotificationGroup ...
+func (c *MockClient) UpdateNotificationGroup(id string, param *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error) {
+	if c.updateNotificationGroupCallback != nil {
+		return c.updateNotificationGroupCallback(id, param)
+	}
+	return nil, errCallbackNotFound("UpdateNotificationGroup")
+}
+
+// MockUpdateNotificationGroup returns an option to set the callback of UpdateNotificationGroup
+func MockUpdateNotificationGroup(callback func(string, *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.updateNotificationGroupCallback = callback
+	}
+}
+
+// DeleteNotificationGroup ...
+func (c *MockClient) DeleteNotificationGroup(id string) (*mackerel.NotificationGroup, error) {
+	if c.deleteNotificationGroupCallback != nil {
+		return c.deleteNotificationGroupCallback(id)
+	}
+	return nil, errCallbackNotFound("DeleteNotificationGroup")
+}
+
+// MockDeleteNotificationGroup returns an option to set the callback of DeleteNotificationGroup
+func MockDeleteNotificationGroup(callback func(string) (*mackerel.NotificationGroup, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.deleteNotificationGroupCallback = callback
+	}
+}
+
 // GetOrg ...
 func (c *MockClient) GetOrg() (*mackerel.Org, error) {
 	if c.getOrgCallback != nil {
@@ -124,3 +306,93 @@ func MockUpdateHostStatus(callback func(string, string) error) MockClientOption
 		c.updateHostStatusCallback = callback
 	}
 }
+
+// UpdateHostRoleFullnames ...
+func (c *MockClient) UpdateHostRoleFullnames(hostID string, roleFullnames []string) error {
+	if c.updateHostRoleFullnamesCallback != nil {
+		return c.updateHostRoleFullnamesCallback(hostID, roleFullnames)
+	}
+	return errCallbackNotFound("UpdateHostRoleFullnames")
+}
+
+// MockUpdateHostRoleFullnames returns an option to set the callback of UpdateHostRoleFullnames
+func MockUpdateHostRoleFullnames(callback func(string, []string) error) MockClientOption {
+	return func(c *MockClient) {
+		c.updateHostRoleFullnamesCallback = callback
+	}
+}
+
+// CreateService ...
+func (c *MockClient) CreateService(param *mackerel.CreateServiceParam) (*mackerel.Service, error) {
+	if c.createServiceCallback != nil {
+		return c.createServiceCallback(param)
+	}
+	return nil, errCallbackNotFound("CreateService")
+}
+
+// MockCreateService returns an option to set the callback of CreateService
+func MockCreateService(callback func(*mackerel.CreateServiceParam) (*mackerel.Service, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.createServiceCallback = callback
+	}
+}
+
+// DeleteService ...
+func (c *MockClient) DeleteService(serviceName string) (*mackerel.Service, error) {
+	if c.deleteServiceCallback != nil {
+		return c.deleteServiceCallback(serviceName)
+	}
+	return nil, errCallbackNotFound("DeleteService")
+}
+
+// MockDeleteService returns an option to set the callback of DeleteService
+func MockDeleteService(callback func(string) (*mackerel.Service, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.deleteServiceCallback = callback
+	}
+}
+
+// FindRoles ...
+func (c *MockClient) FindRoles(serviceName string) ([]*mackerel.Role, error) {
+	if c.findRolesCallback != nil {
+		return c.findRolesCallback(serviceName)
+	}
+	return nil, errCallbackNotFound("FindRoles")
+}
+
+// MockFindRoles returns an option to set the callback of FindRoles
+func MockFindRoles(callback func(string) ([]*mackerel.Role, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.findRolesCallback = callback
+	}
+}
+
+// CreateRole ...
+func (c *MockClient) CreateRole(serviceName string, param *mackerel.CreateRoleParam) (*mackerel.Role, error) {
+	if c.createRoleCallback != nil {
+		return c.createRoleCallback(serviceName, param)
+	}
+	return nil, errCallbackNotFound("CreateRole")
+}
+
+// MockCreateRole returns an option to set the callback of CreateRole
+func MockCreateRole(callback func(string, *mackerel.CreateRoleParam) (*mackerel.Role, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.createRoleCallback = callback
+	}
+}
+
+// DeleteRole ...
+func (c *MockClient) DeleteRole(serviceName, roleName string) (*mackerel.Role, error) {
+	if c.deleteRoleCallback != nil {
+		return c.deleteRoleCallback(serviceName, roleName)
+	}
+	return nil, errCallbackNotFound("DeleteRole")
+}
+
+// MockDeleteRole returns an option to set the callback of DeleteRole
+func MockDeleteRole(callback func(string, string) (*mackerel.Role, error)) MockClientOption {
+	return func(c *MockClient) {
+		c.deleteRoleCallback = callback
+	}
+}
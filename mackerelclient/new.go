@@ -1,12 +1,12 @@
 package mackerelclient
 
 import (
-	"fmt"
+	"net/http"
 	"os"
+	"time"
 
 	"github.com/urfave/cli"
 
-	"github.com/mackerelio/mackerel-agent/config"
 	"github.com/mackerelio/mackerel-client-go"
 
 	"github.com/mackerelio/mkr/logger"
@@ -14,48 +14,81 @@ import (
 
 // New returns new mackerel client
 func New(conffile, apibase string) (Client, error) {
-	apikey := os.Getenv("MACKEREL_APIKEY")
-	var conf *config.Config
-	if apikey == "" {
-		var err error
-		conf, err = config.LoadConfig(conffile)
-		if err != nil {
-			return nil, err
-		}
-		apikey = conf.Apikey
+	return NewWithProfile(conffile, apibase, "")
+}
+
+// NewWithProfile returns new mackerel client of the profile.
+// The apikey is loaded from MACKEREL_APIKEY or config if the profile is empty.
+func NewWithProfile(conffile, apibase, profile string) (Client, error) {
+	apikey, err := ResolveApikey(conffile, profile)
+	if err != nil {
+		return nil, err
+	}
+	client, err := NewClient(apikey.Value, ResolveApibase(conffile, apibase, profile))
+	if err != nil {
+		return nil, err
+	}
+	return &streamClient{client}, nil
+}
+
+// NewClient returns new mackerel client which retries the requests by the options of SetHTTPOptions,
+// and caches the responses by the options of SetCacheOptions. The requests are counted as the API usage.
+// The requests and the responses are traced in the debug log level, or when the DEBUG environment variable is set.
+func NewClient(apikey, apibase string) (*mackerel.Client, error) {
+	client, err := mackerel.NewClientWithOptions(apikey, apibase, false)
+	if err != nil {
+		return nil, err
 	}
-	if apikey == "" {
-		return nil, fmt.Errorf("No mackerel apikeys are specified from MACKEREL_APIKEY or config")
+	transport := client.HTTPClient.Transport
+	if transport == nil {
+		transport = http.DefaultTransport
 	}
-	if apibase == "" {
-		if conf == nil {
-			conf, _ = config.LoadConfig(conffile)
-			if conf == nil {
-				conf = config.DefaultConfig
-			}
+	transport = &usageTransport{transport: transport, now: time.Now}
+	if os.Getenv("DEBUG") != "" || logger.DebugEnabled() {
+		transport = &traceTransport{transport: transport, logger: logger.New()}
+	}
+	timeout, retries := httpOptions()
+	client.HTTPClient.Transport = &retryTransport{
+		transport:  transport,
+		timeout:    timeout,
+		maxRetries: retries,
+		logger:     logger.New(),
+	}
+	if opts := currentCacheOptions(); opts.Enabled || opts.Offline {
+		dir := opts.Dir
+		if dir == "" {
+			dir = DefaultCacheDir()
+		}
+		client.HTTPClient.Transport = &cacheTransport{
+			transport: client.HTTPClient.Transport,
+			dir:       dir,
+			ttl:       opts.TTL,
+			offline:   opts.Offline,
+			now:       time.Now,
 		}
-		apibase = conf.Apibase
 	}
-	return mackerel.NewClientWithOptions(apikey, apibase, os.Getenv("DEBUG") != "")
+	// the timeout is applied to each attempt by retryTransport
+	client.HTTPClient.Timeout = 0
+	return client, nil
 }
 
 // NewFromContext returns mackerel client from cli.Context
 func NewFromContext(c *cli.Context) *mackerel.Client {
 	confFile := c.GlobalString("conf")
-	apiBase := c.GlobalString("apibase")
-	apiKey := LoadApikeyFromEnvOrConfig(confFile)
-	if apiKey == "" {
-		logger.Log("error", `
+	profile := c.GlobalString("profile")
+	apiKey, err := ResolveApikey(confFile, profile)
+	if err != nil {
+		if profile != "" {
+			logger.Log("error", err.Error())
+		} else {
+			logger.Log("error", `
     MACKEREL_APIKEY environment variable is not set. (Try "export MACKEREL_APIKEY='<Your apikey>'")
 `)
+		}
 		os.Exit(1)
 	}
 
-	if apiBase == "" {
-		apiBase = LoadApibaseFromConfigWithFallback(confFile)
-	}
-
-	client, err := mackerel.NewClientWithOptions(apiKey, apiBase, os.Getenv("DEBUG") != "")
+	client, err := NewClient(apiKey.Value, ResolveApibase(confFile, c.GlobalString("apibase"), profile))
 	logger.DieIf(err)
 
 	return client
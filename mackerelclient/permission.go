@@ -0,0 +1,41 @@
+package mackerelclient
+
+import (
+	"bytes"
+	"net/http"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// The permissions of apikeys
+const (
+	PermissionRead      = "read"
+	PermissionReadWrite = "read/write"
+)
+
+// DetectPermission detects the permission of the apikey by a probe request.
+// The probe creates a service without a name, which is rejected either for the permission
+// of the apikey (403) or for the invalid parameter (400), so it never changes the organization.
+func DetectPermission(client *mackerel.Client) (string, error) {
+	u := *client.BaseURL
+	u.Path = "/api/v0/services"
+	req, err := http.NewRequest("POST", u.String(), bytes.NewReader([]byte("{}")))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Request(req)
+	if err == nil {
+		resp.Body.Close()
+		return PermissionReadWrite, nil
+	}
+	if apiErr, ok := err.(*mackerel.APIError); ok {
+		switch apiErr.StatusCode {
+		case http.StatusForbidden:
+			return PermissionRead, nil
+		case http.StatusBadRequest:
+			return PermissionReadWrite, nil
+		}
+	}
+	return "", err
+}
@@ -0,0 +1,39 @@
+package mackerelclient
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestDetectPermission(t *testing.T) {
+	testCases := []struct {
+		status     int
+		permission string
+		err        bool
+	}{
+		{http.StatusBadRequest, PermissionReadWrite, false},
+		{http.StatusForbidden, PermissionRead, false},
+		{http.StatusUnauthorized, "", true},
+	}
+	for _, tc := range testCases {
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method != "POST" || r.URL.Path != "/api/v0/services" {
+				t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+			}
+			w.WriteHeader(tc.status)
+			w.Write([]byte(`{"error":{"message":"error"}}`))
+		}))
+		client, _ := mackerel.NewClientWithOptions("dummy-key", ts.URL, false)
+		permission, err := DetectPermission(client)
+		ts.Close()
+		if tc.err != (err != nil) {
+			t.Errorf("unexpected error for status %d: %v", tc.status, err)
+		}
+		if permission != tc.permission {
+			t.Errorf("permission should be %q for status %d but got %q", tc.permission, tc.status, permission)
+		}
+	}
+}
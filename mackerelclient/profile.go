@@ -0,0 +1,184 @@
+package mackerelclient
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/BurntSushi/toml"
+	"github.com/mackerelio/mackerel-agent/config"
+)
+
+// DefaultProfile is the profile used when no profiles are selected
+// and no apikeys are specified from MACKEREL_APIKEY or mackerel-agent.conf
+const DefaultProfile = "default"
+
+// Profile represents a named set of the settings to access an organization
+type Profile struct {
+	Apikey string `toml:"apikey,omitempty"`
+	// ApikeyCommand is the command which prints the apikey (e.g. vault kv get -field=apikey secret/mackerel)
+	ApikeyCommand string `toml:"apikey_command,omitempty"`
+	// ApikeyKeychain is true if the apikey is stored in the OS keychain
+	ApikeyKeychain bool   `toml:"apikey_keychain,omitempty"`
+	Apibase        string `toml:"apibase,omitempty"`
+	// Output is the default output format of the commands
+	Output string `toml:"output,omitempty"`
+}
+
+// Config represents the configuration file of mkr (~/.config/mkr/config.toml)
+type Config struct {
+	Profiles map[string]*Profile `toml:"profiles"`
+}
+
+// DefaultConfigFile returns the path of the configuration file of mkr.
+// It respects XDG_CONFIG_HOME and defaults to ~/.config/mkr/config.toml.
+func DefaultConfigFile() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		dir = filepath.Join(home, ".config")
+	}
+	return filepath.Join(dir, "mkr", "config.toml")
+}
+
+// LoadConfig loads the configuration file of mkr. An empty configuration is returned if the file does not exist.
+func LoadConfig(path string) (*Config, error) {
+	conf := &Config{}
+	if path == "" {
+		return conf, nil
+	}
+	if _, err := toml.DecodeFile(path, conf); err != nil {
+		if os.IsNotExist(err) {
+			return conf, nil
+		}
+		return nil, fmt.Errorf("failed to load %s: %s", path, err)
+	}
+	return conf, nil
+}
+
+// Save writes the configuration to the file. The file is readable only by the owner
+// since it may contain apikeys.
+func (conf *Config) Save(path string) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0700); err != nil {
+		return err
+	}
+	tmp, err := ioutil.TempFile(filepath.Dir(path), ".config.toml")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+	if err := toml.NewEncoder(tmp).Encode(conf); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// ProfileNames returns the names of the profiles in sorted order
+func (conf *Config) ProfileNames() []string {
+	names := make([]string, 0, len(conf.Profiles))
+	for name := range conf.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Profile returns the profile of the name
+func (conf *Config) Profile(name string) (*Profile, error) {
+	p, ok := conf.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q is not defined in %s", name, DefaultConfigFile())
+	}
+	return p, nil
+}
+
+// Apikey returns the apikey of the profile. The apikey is loaded from the configuration file,
+// the output of apikey_command or the OS keychain.
+func (conf *Config) Apikey(name string) (*Apikey, error) {
+	p, err := conf.Profile(name)
+	if err != nil {
+		return nil, err
+	}
+	switch {
+	case p.Apikey != "":
+		return &Apikey{Value: p.Apikey, Source: fmt.Sprintf("profile %s (%s)", name, DefaultConfigFile())}, nil
+	case p.ApikeyCommand != "":
+		apikey, err := runApikeyCommand(p.ApikeyCommand)
+		if err != nil {
+			return nil, err
+		}
+		return &Apikey{Value: apikey, Source: fmt.Sprintf("profile %s (apikey_command)", name)}, nil
+	case p.ApikeyKeychain:
+		apikey, err := LoadKeychainApikey(name)
+		if err != nil {
+			return nil, err
+		}
+		return &Apikey{Value: apikey, Source: fmt.Sprintf("profile %s (keychain)", name)}, nil
+	}
+	return nil, fmt.Errorf("apikey is not specified in profile %q", name)
+}
+
+// LoadProfile loads the profile of the name from the configuration file of mkr
+func LoadProfile(name string) (*Profile, error) {
+	conf, err := LoadConfig(DefaultConfigFile())
+	if err != nil {
+		return nil, err
+	}
+	return conf.Profile(name)
+}
+
+// SelectProfile returns the profile to use. The default profile is used
+// only if no apikeys are specified from MACKEREL_APIKEY or mackerel-agent.conf.
+func SelectProfile(conffile, profile string) string {
+	if profile != "" || len(LoadApikeys(conffile)) > 0 {
+		return profile
+	}
+	if _, err := LoadProfile(DefaultProfile); err == nil {
+		return DefaultProfile
+	}
+	return ""
+}
+
+// ResolveApikey returns the apikey of the profile if it is specified,
+// or the apikey from MACKEREL_APIKEY or mackerel-agent.conf, or the apikey of the default profile.
+func ResolveApikey(conffile, profile string) (*Apikey, error) {
+	if profile = SelectProfile(conffile, profile); profile != "" {
+		conf, err := LoadConfig(DefaultConfigFile())
+		if err != nil {
+			return nil, err
+		}
+		return conf.Apikey(profile)
+	}
+	apikeys := LoadApikeys(conffile)
+	if len(apikeys) == 0 {
+		return nil, fmt.Errorf("No mackerel apikeys are specified from MACKEREL_APIKEY or config")
+	}
+	return apikeys[0], nil
+}
+
+// ResolveApibase returns the apibase option if it is specified, or the apibase of the profile,
+// or the apibase in mackerel-agent.conf. It fallbacks to default (https://api.mackerelio.com/).
+func ResolveApibase(conffile, apibase, profile string) string {
+	if apibase != "" {
+		return apibase
+	}
+	if profile = SelectProfile(conffile, profile); profile != "" {
+		if p, err := LoadProfile(profile); err == nil && p.Apibase != "" {
+			return p.Apibase
+		}
+		return config.DefaultConfig.Apibase
+	}
+	return LoadApibaseFromConfigWithFallback(conffile)
+}
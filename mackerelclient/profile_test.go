@@ -0,0 +1,179 @@
+package mackerelclient
+
+import (
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"reflect"
+	"testing"
+)
+
+func setConfigHome(t *testing.T, dir string) {
+	t.Helper()
+	orig, ok := os.LookupEnv("XDG_CONFIG_HOME")
+	os.Setenv("XDG_CONFIG_HOME", dir)
+	t.Cleanup(func() {
+		if ok {
+			os.Setenv("XDG_CONFIG_HOME", orig)
+		} else {
+			os.Unsetenv("XDG_CONFIG_HOME")
+		}
+	})
+}
+
+func TestLoadConfig(t *testing.T) {
+	conf, err := LoadConfig("testdata/xdg/mkr/config.toml")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if names := conf.ProfileNames(); !reflect.DeepEqual(names, []string{"empty", "prod", "staging"}) {
+		t.Errorf("unexpected profile names: %v", names)
+	}
+	p, err := conf.Profile("prod")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := &Profile{Apikey: "PROD123456", Apibase: "https://prod.example.com/", Output: "json"}
+	if !reflect.DeepEqual(p, expected) {
+		t.Errorf("profile should be %+v but got %+v", expected, p)
+	}
+	if _, err := conf.Profile("unknown"); err == nil {
+		t.Error("should be an error for an undefined profile")
+	}
+
+	conf, err = LoadConfig("testdata/not-found.toml")
+	if err != nil {
+		t.Errorf("should not be an error for a missing file: %s", err)
+	}
+	if len(conf.Profiles) != 0 {
+		t.Errorf("should be empty: %+v", conf)
+	}
+}
+
+func TestResolveApikey(t *testing.T) {
+	setConfigHome(t, "testdata/xdg")
+	os.Setenv("MACKEREL_APIKEY", "ENV123456ABCD")
+	defer os.Setenv("MACKEREL_APIKEY", "")
+	conffile := "testdata/mackerel-agent.conf"
+
+	apikey, err := ResolveApikey(conffile, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apikey.Value != "ENV123456ABCD" {
+		t.Errorf("should be ENV123456ABCD but got %s", apikey.Value)
+	}
+
+	apikey, err = ResolveApikey(conffile, "staging")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if apikey.Value != "STAGING123456" || apikey.Source != "profile staging (testdata/xdg/mkr/config.toml)" {
+		t.Errorf("unexpected apikey: %+v", apikey)
+	}
+
+	if _, err := ResolveApikey(conffile, "empty"); err == nil {
+		t.Error("should be an error for a profile without apikey")
+	}
+	if _, err := ResolveApikey(conffile, "unknown"); err == nil {
+		t.Error("should be an error for an undefined profile")
+	}
+}
+
+func TestResolveApibase(t *testing.T) {
+	setConfigHome(t, "testdata/xdg")
+	conffile := "testdata/mackerel-agent.conf"
+
+	testCases := []struct {
+		apibase, profile, expected string
+	}{
+		{"", "", "https://example.com/"},
+		{"https://option.example.com/", "prod", "https://option.example.com/"},
+		{"", "prod", "https://prod.example.com/"},
+		{"", "staging", "https://api.mackerelio.com"},
+	}
+	for _, tc := range testCases {
+		if apibase := ResolveApibase(conffile, tc.apibase, tc.profile); apibase != tc.expected {
+			t.Errorf("apibase should be %s for (%q, %q) but got %s", tc.expected, tc.apibase, tc.profile, apibase)
+		}
+	}
+}
+
+func TestConfig_Apikey(t *testing.T) {
+	setConfigHome(t, "testdata/xdg")
+	conf := &Config{Profiles: map[string]*Profile{
+		"plain":    {Apikey: "PLAIN123"},
+		"command":  {ApikeyCommand: "echo COMMAND123"},
+		"failure":  {ApikeyCommand: "exit 1"},
+		"keychain": {ApikeyKeychain: true},
+		"empty":    {},
+	}}
+
+	apikey, err := conf.Apikey("plain")
+	if err != nil || apikey.Value != "PLAIN123" {
+		t.Errorf("unexpected apikey: %+v, %v", apikey, err)
+	}
+	apikey, err = conf.Apikey("command")
+	if err != nil || apikey.Value != "COMMAND123" || apikey.Source != "profile command (apikey_command)" {
+		t.Errorf("unexpected apikey: %+v, %v", apikey, err)
+	}
+	if _, err := conf.Apikey("failure"); err == nil {
+		t.Error("the failure of apikey_command should be an error")
+	}
+	if _, err := conf.Apikey("empty"); err == nil {
+		t.Error("should be an error for a profile without apikey")
+	}
+
+	origGOOS, origCommand := keychainGOOS, keychainCommand
+	defer func() { keychainGOOS, keychainCommand = origGOOS, origCommand }()
+	var args []string
+	keychainGOOS = "linux"
+	keychainCommand = func(name string, arg ...string) *exec.Cmd {
+		args = append([]string{name}, arg...)
+		return exec.Command("echo", "KEYCHAIN123")
+	}
+	apikey, err = conf.Apikey("keychain")
+	if err != nil || apikey.Value != "KEYCHAIN123" || apikey.Source != "profile keychain (keychain)" {
+		t.Errorf("unexpected apikey: %+v, %v", apikey, err)
+	}
+	if expected := []string{"secret-tool", "lookup", "service", "mkr", "account", "keychain"}; !reflect.DeepEqual(args, expected) {
+		t.Errorf("keychain command should be %v but got %v", expected, args)
+	}
+
+	keychainGOOS = "plan9"
+	if _, err := conf.Apikey("keychain"); err == nil {
+		t.Error("the keychain on plan9 should be an error")
+	}
+}
+
+func TestResolveApikey_defaultProfile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	setConfigHome(t, dir)
+
+	conf := &Config{Profiles: map[string]*Profile{
+		DefaultProfile: {Apikey: "DEFAULT123", Apibase: "https://default.example.com/"},
+	}}
+	if err := conf.Save(DefaultConfigFile()); err != nil {
+		t.Fatal(err)
+	}
+	if fi, err := os.Stat(DefaultConfigFile()); err != nil || fi.Mode().Perm() != 0600 {
+		t.Errorf("config file should be saved with 0600: %v, %v", fi, err)
+	}
+
+	// the default profile is used only when no apikeys are configured
+	apikey, err := ResolveApikey("testdata/not-found.conf", "")
+	if err != nil || apikey.Value != "DEFAULT123" {
+		t.Errorf("unexpected apikey: %+v, %v", apikey, err)
+	}
+	if apibase := ResolveApibase("testdata/not-found.conf", "", ""); apibase != "https://default.example.com/" {
+		t.Errorf("apibase should be of the default profile but got %s", apibase)
+	}
+	apikey, err = ResolveApikey("testdata/mackerel-agent.conf", "")
+	if err != nil || apikey.Value != "123456ABCD" {
+		t.Errorf("unexpected apikey: %+v, %v", apikey, err)
+	}
+}
@@ -0,0 +1,75 @@
+package mackerelclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// RequestJSON requests the API which mackerel-client-go does not support yet.
+// The body is encoded to JSON unless it is nil, and the response is decoded into out unless it is nil.
+func RequestJSON(client *mackerel.Client, method, path string, query url.Values, body, out interface{}) error {
+	u := *client.BaseURL
+	u.Path = path
+	u.RawQuery = query.Encode()
+
+	var r io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return err
+		}
+		r = bytes.NewReader(data)
+	}
+	req, err := http.NewRequest(method, u.String(), r)
+	if err != nil {
+		return err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	resp, err := client.Request(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// RequestRaw requests the path of the API, which may contain the query, with the body unless it is nil.
+// Unlike RequestJSON, the response is returned as it is even if the status is not 2xx.
+func RequestRaw(client *mackerel.Client, method, path string, body []byte) (*http.Response, error) {
+	ref, err := url.Parse(path)
+	if err != nil {
+		return nil, err
+	}
+	u := *client.BaseURL
+	u.Path, u.RawQuery = ref.Path, ref.RawQuery
+
+	var r io.Reader
+	if body != nil {
+		r = bytes.NewReader(body)
+	}
+	req, err := http.NewRequest(method, u.String(), r)
+	if err != nil {
+		return nil, err
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	for header, values := range client.AdditionalHeaders {
+		for _, v := range values {
+			req.Header.Add(header, v)
+		}
+	}
+	req.Header.Set("X-Api-Key", client.APIKey)
+	req.Header.Set("User-Agent", client.UserAgent)
+	return client.HTTPClient.Do(req)
+}
@@ -0,0 +1,178 @@
+package mackerelclient
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jpillora/backoff"
+
+	"github.com/mackerelio/mkr/logger"
+)
+
+// The default options of the HTTP requests to the API
+const (
+	DefaultHTTPTimeout = 30 * time.Second
+	DefaultMaxRetries  = 3
+)
+
+// the options shared by all the clients created by NewClient
+var (
+	httpOptionsMu sync.RWMutex
+	httpTimeout   = DefaultHTTPTimeout
+	maxRetries    = DefaultMaxRetries
+)
+
+// SetHTTPOptions sets the timeout of each request and the maximum number of the retries.
+// The timeout is disabled if it is zero.
+func SetHTTPOptions(timeout time.Duration, retries int) error {
+	if timeout < 0 {
+		return fmt.Errorf("the HTTP timeout should not be negative: %s", timeout)
+	}
+	if retries < 0 {
+		return fmt.Errorf("the maximum number of the retries should not be negative: %d", retries)
+	}
+	httpOptionsMu.Lock()
+	defer httpOptionsMu.Unlock()
+	httpTimeout, maxRetries = timeout, retries
+	return nil
+}
+
+func httpOptions() (time.Duration, int) {
+	httpOptionsMu.RLock()
+	defer httpOptionsMu.RUnlock()
+	return httpTimeout, maxRetries
+}
+
+// the range of the interval between the retries, which can be changed in tests
+var (
+	retryMinInterval = time.Second
+	retryMaxInterval = time.Minute
+)
+
+// retryTransport retries the requests with jittered exponential backoff when they are rate limited (429),
+// or when the idempotent requests fail with the server errors (5xx). The Retry-After header is respected.
+// The timeout is applied to each attempt.
+type retryTransport struct {
+	transport  http.RoundTripper
+	timeout    time.Duration
+	maxRetries int
+	logger     *logger.Logger
+}
+
+func (t *retryTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	b := &backoff.Backoff{
+		Min:    retryMinInterval,
+		Max:    retryMaxInterval,
+		Factor: 2,
+		Jitter: true,
+	}
+	for {
+		resp, err := t.roundTrip(req)
+		if err != nil || !t.shouldRetry(req, resp) || int(b.Attempt()) >= t.maxRetries {
+			return resp, err
+		}
+		wait := b.Duration()
+		if d, ok := retryAfter(resp, time.Now()); ok {
+			wait = d
+			if wait > retryMaxInterval {
+				wait = retryMaxInterval
+			}
+		}
+		// the body of the next request is obtained beforehand so as not to wait in vain
+		next, err := rewindRequest(req)
+		if err != nil {
+			return resp, nil
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+		t.logger.Logf("warning", "%s %s responded %s. Retry after %s (%d/%d).",
+			req.Method, req.URL.Path, resp.Status, wait.Round(time.Millisecond), int(b.Attempt()), t.maxRetries)
+		select {
+		case <-req.Context().Done():
+			return nil, req.Context().Err()
+		case <-time.After(wait):
+		}
+		req = next
+	}
+}
+
+// roundTrip sends the request with the timeout, which is canceled when the response body is closed.
+func (t *retryTransport) roundTrip(req *http.Request) (*http.Response, error) {
+	if t.timeout <= 0 {
+		return t.transport.RoundTrip(req)
+	}
+	ctx, cancel := context.WithTimeout(req.Context(), t.timeout)
+	resp, err := t.transport.RoundTrip(req.WithContext(ctx))
+	if err != nil {
+		cancel()
+		return nil, err
+	}
+	resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+	return resp, nil
+}
+
+func (t *retryTransport) shouldRetry(req *http.Request, resp *http.Response) bool {
+	if resp.StatusCode == http.StatusTooManyRequests {
+		return true
+	}
+	if resp.StatusCode < 500 {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodPut, http.MethodDelete, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// rewindRequest returns the copy of the request with a new body to resend it.
+func rewindRequest(req *http.Request) (*http.Request, error) {
+	if req.Body == nil || req.Body == http.NoBody {
+		return req, nil
+	}
+	if req.GetBody == nil {
+		return nil, fmt.Errorf("the body of the request cannot be resent")
+	}
+	body, err := req.GetBody()
+	if err != nil {
+		return nil, err
+	}
+	next := req.Clone(req.Context())
+	next.Body = body
+	return next, nil
+}
+
+// retryAfter parses the Retry-After header in seconds or in the HTTP date.
+func retryAfter(resp *http.Response, now time.Time) (time.Duration, bool) {
+	v := resp.Header.Get("Retry-After")
+	if v == "" {
+		return 0, false
+	}
+	if secs, err := strconv.Atoi(v); err == nil && secs >= 0 {
+		return time.Duration(secs) * time.Second, true
+	}
+	if t, err := http.ParseTime(v); err == nil {
+		if d := t.Sub(now); d > 0 {
+			return d, true
+		}
+		return 0, true
+	}
+	return 0, false
+}
+
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
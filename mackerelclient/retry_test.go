@@ -0,0 +1,170 @@
+package mackerelclient
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mkr/logger"
+)
+
+func setRetryInterval(t *testing.T) {
+	min, max := retryMinInterval, retryMaxInterval
+	retryMinInterval, retryMaxInterval = time.Millisecond, 10*time.Millisecond
+	t.Cleanup(func() { retryMinInterval, retryMaxInterval = min, max })
+}
+
+func newTestRetryTransport(maxRetries int, timeout time.Duration) *retryTransport {
+	l := logger.New()
+	l.SetOutput(ioutil.Discard)
+	return &retryTransport{transport: http.DefaultTransport, timeout: timeout, maxRetries: maxRetries, logger: l}
+}
+
+func TestRetryTransport(t *testing.T) {
+	setRetryInterval(t)
+	testCases := []struct {
+		name       string
+		method     string
+		statuses   []int
+		retryAfter string
+		maxRetries int
+		status     int
+		requests   int32
+	}{
+		{name: "success", method: "GET", statuses: []int{200}, maxRetries: 3, status: 200, requests: 1},
+		{name: "server errors", method: "GET", statuses: []int{503, 502, 200}, maxRetries: 3, status: 200, requests: 3},
+		{name: "rate limited", method: "POST", statuses: []int{429, 200}, retryAfter: "0", maxRetries: 3, status: 200, requests: 2},
+		{name: "server error of POST", method: "POST", statuses: []int{500, 200}, maxRetries: 3, status: 500, requests: 1},
+		{name: "client error", method: "GET", statuses: []int{404, 200}, maxRetries: 3, status: 404, requests: 1},
+		{name: "too many retries", method: "PUT", statuses: []int{503, 503, 503, 200}, maxRetries: 2, status: 503, requests: 3},
+		{name: "no retries", method: "GET", statuses: []int{429, 200}, maxRetries: 0, status: 429, requests: 1},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var count int32
+			ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				i := atomic.AddInt32(&count, 1) - 1
+				if body, _ := ioutil.ReadAll(r.Body); r.Method != "GET" && string(body) != `{"name":"foo"}` {
+					t.Errorf("the body should be resent but got %q", string(body))
+				}
+				if tc.retryAfter != "" {
+					w.Header().Set("Retry-After", tc.retryAfter)
+				}
+				w.WriteHeader(tc.statuses[i])
+			}))
+			defer ts.Close()
+
+			req, err := http.NewRequest(tc.method, ts.URL, nil)
+			if tc.method != "GET" {
+				req, err = http.NewRequest(tc.method, ts.URL, strings.NewReader(`{"name":"foo"}`))
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := (&http.Client{Transport: newTestRetryTransport(tc.maxRetries, 0)}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != tc.status {
+				t.Errorf("the status should be %d but got %d", tc.status, resp.StatusCode)
+			}
+			if count != tc.requests {
+				t.Errorf("the number of the requests should be %d but got %d", tc.requests, count)
+			}
+		})
+	}
+}
+
+func TestRetryTransport_timeout(t *testing.T) {
+	setRetryInterval(t)
+	var count int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&count, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		time.Sleep(200 * time.Millisecond)
+	}))
+	defer ts.Close()
+
+	_, err := (&http.Client{Transport: newTestRetryTransport(3, 50*time.Millisecond)}).Get(ts.URL)
+	if err == nil {
+		t.Fatalf("the request should be timed out")
+	}
+	if count != 2 {
+		t.Errorf("the timed out request should not be retried but requested %d times", count)
+	}
+}
+
+func TestRetryTransport_body(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		w.Write(bytes.Repeat([]byte("a"), 1024))
+	}))
+	defer ts.Close()
+
+	resp, err := (&http.Client{Transport: newTestRetryTransport(3, time.Second)}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	data, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("the body should be read within the timeout: %s", err)
+	}
+	if len(data) != 1024 {
+		t.Errorf("the length of the body should be 1024 but got %d", len(data))
+	}
+}
+
+func TestRetryAfter(t *testing.T) {
+	now := time.Date(2020, 6, 1, 0, 0, 0, 0, time.UTC)
+	testCases := []struct {
+		header   string
+		expected time.Duration
+		ok       bool
+	}{
+		{header: "", ok: false},
+		{header: "120", expected: 2 * time.Minute, ok: true},
+		{header: "Mon, 01 Jun 2020 00:00:30 GMT", expected: 30 * time.Second, ok: true},
+		{header: "Sun, 31 May 2020 23:59:00 GMT", expected: 0, ok: true},
+		{header: "soon", ok: false},
+	}
+	for _, tc := range testCases {
+		resp := &http.Response{Header: http.Header{}}
+		if tc.header != "" {
+			resp.Header.Set("Retry-After", tc.header)
+		}
+		d, ok := retryAfter(resp, now)
+		if d != tc.expected || ok != tc.ok {
+			t.Errorf("retryAfter(%q) should be (%s, %t) but got (%s, %t)", tc.header, tc.expected, tc.ok, d, ok)
+		}
+	}
+}
+
+func TestSetHTTPOptions(t *testing.T) {
+	defer SetHTTPOptions(DefaultHTTPTimeout, DefaultMaxRetries)
+	if err := SetHTTPOptions(-time.Second, 3); err == nil {
+		t.Errorf("negative timeout should be an error")
+	}
+	if err := SetHTTPOptions(time.Second, -1); err == nil {
+		t.Errorf("negative retries should be an error")
+	}
+	if err := SetHTTPOptions(5*time.Second, 1); err != nil {
+		t.Fatal(err)
+	}
+	client, err := NewClient("apikey", "https://api.mackerelio.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	rt := client.HTTPClient.Transport.(*retryTransport)
+	if rt.timeout != 5*time.Second || rt.maxRetries != 1 {
+		t.Errorf("the options should be applied to the client but got timeout=%s, maxRetries=%d", rt.timeout, rt.maxRetries)
+	}
+}
@@ -0,0 +1,99 @@
+package mackerelclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// HostStreamer is implemented by the clients which can find hosts one by one.
+type HostStreamer interface {
+	StreamHosts(param *mackerel.FindHostsParam, fn func(*mackerel.Host) error) error
+}
+
+// streamClient is a client of Mackerel API with the streaming methods.
+type streamClient struct {
+	*mackerel.Client
+}
+
+// StreamHosts finds hosts and calls fn for each host while decoding the response,
+// so that the hosts do not have to be kept in memory at once.
+// The API does not support pagination, so the hosts are still requested at once.
+func (c *streamClient) StreamHosts(param *mackerel.FindHostsParam, fn func(*mackerel.Host) error) error {
+	v := url.Values{}
+	if param.Service != "" {
+		v.Set("service", param.Service)
+	}
+	for _, role := range param.Roles {
+		v.Add("role", role)
+	}
+	if param.Name != "" {
+		v.Set("name", param.Name)
+	}
+	for _, status := range param.Statuses {
+		v.Add("status", status)
+	}
+	if param.CustomIdentifier != "" {
+		v.Set("customIdentifier", param.CustomIdentifier)
+	}
+	u := *c.BaseURL
+	u.Path = "/api/v0/hosts"
+	u.RawQuery = v.Encode()
+	req, err := http.NewRequest("GET", u.String(), nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.Request(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	dec := json.NewDecoder(resp.Body)
+	if err := expectDelim(dec, '{'); err != nil {
+		return err
+	}
+	for dec.More() {
+		key, err := dec.Token()
+		if err != nil {
+			return err
+		}
+		if key != "hosts" {
+			var skip json.RawMessage
+			if err := dec.Decode(&skip); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := expectDelim(dec, '['); err != nil {
+			return err
+		}
+		for dec.More() {
+			var host mackerel.Host
+			if err := dec.Decode(&host); err != nil {
+				return err
+			}
+			if err := fn(&host); err != nil {
+				return err
+			}
+		}
+		if err := expectDelim(dec, ']'); err != nil {
+			return err
+		}
+	}
+	return expectDelim(dec, '}')
+}
+
+func expectDelim(dec *json.Decoder, delim json.Delim) error {
+	t, err := dec.Token()
+	if err != nil {
+		return err
+	}
+	if t != delim {
+		return fmt.Errorf("unexpected token in the response: %v", t)
+	}
+	return nil
+}
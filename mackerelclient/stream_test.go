@@ -0,0 +1,34 @@
+package mackerelclient
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestStreamHosts(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/hosts" || r.URL.Query().Get("service") != "foo" {
+			t.Errorf("unexpected request: %s", r.URL)
+		}
+		fmt.Fprint(w, `{"hosts": [{"id": "a", "name": "host-a"}, {"id": "b", "name": "host-b"}], "other": {"x": [1]}}`)
+	}))
+	defer ts.Close()
+
+	c, _ := mackerel.NewClientWithOptions("dummy", ts.URL, false)
+	var ids []string
+	err := (&streamClient{c}).StreamHosts(&mackerel.FindHostsParam{Service: "foo"}, func(h *mackerel.Host) error {
+		ids = append(ids, h.ID)
+		return nil
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]string{"a", "b"}, ids) {
+		t.Errorf("hosts should be streamed but: %v", ids)
+	}
+}
@@ -0,0 +1,40 @@
+package mackerelclient
+
+import (
+	"net/http"
+	"net/http/httputil"
+	"strings"
+
+	"github.com/mackerelio/mkr/logger"
+)
+
+// traceTransport logs the requests and the responses with the apikey redacted
+type traceTransport struct {
+	transport http.RoundTripper
+	logger    *logger.Logger
+}
+
+const redactedApikey = "<redacted>"
+
+func (t *traceTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	apikey := req.Header.Get("X-Api-Key")
+	if dump, err := httputil.DumpRequestOut(req, true); err == nil {
+		t.logger.Debugf("request:\n%s", redact(strings.TrimSpace(string(dump)), apikey))
+	}
+	resp, err := t.transport.RoundTrip(req)
+	if err != nil {
+		t.logger.Debugf("request failed: %s", err)
+		return nil, err
+	}
+	if dump, err := httputil.DumpResponse(resp, true); err == nil {
+		t.logger.Debugf("response:\n%s", redact(strings.TrimSpace(string(dump)), apikey))
+	}
+	return resp, nil
+}
+
+func redact(s, apikey string) string {
+	if apikey == "" {
+		return s
+	}
+	return strings.Replace(s, apikey, redactedApikey, -1)
+}
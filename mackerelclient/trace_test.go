@@ -0,0 +1,46 @@
+package mackerelclient
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/mkr/logger"
+)
+
+func TestTraceTransport(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"services":[]}`))
+	}))
+	defer ts.Close()
+
+	defer logger.SetLevel("info")
+	if err := logger.SetLevel("debug"); err != nil {
+		t.Fatal(err)
+	}
+	out := new(bytes.Buffer)
+	l := logger.New()
+	l.SetOutput(out)
+
+	client, err := NewClient("secret-apikey", ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	client.HTTPClient.Transport.(*retryTransport).transport.(*traceTransport).logger = l
+	if _, err := client.FindServices(); err != nil {
+		t.Fatal(err)
+	}
+
+	got := out.String()
+	if strings.Contains(got, "secret-apikey") {
+		t.Errorf("the apikey should be redacted but got %q", got)
+	}
+	for _, s := range []string{"GET /api/v0/services", "X-Api-Key: " + redactedApikey, `{"services":[]}`} {
+		if !strings.Contains(got, s) {
+			t.Errorf("the trace should contain %q but got %q", s, got)
+		}
+	}
+}
@@ -0,0 +1,188 @@
+package mackerelclient
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// RateLimit is the rate limit of the API reported by the headers of the last response
+type RateLimit struct {
+	Limit      string `json:"limit,omitempty"`
+	Remaining  string `json:"remaining,omitempty"`
+	Reset      string `json:"reset,omitempty"`
+	RetryAfter string `json:"retryAfter,omitempty"`
+	ObservedAt int64  `json:"observedAt"`
+}
+
+// CommandUsage is the number of the API requests made by a command
+type CommandUsage struct {
+	Calls       int64 `json:"calls"`
+	RateLimited int64 `json:"rateLimited"`
+	LastUsedAt  int64 `json:"lastUsedAt,omitempty"`
+}
+
+// APIUsage is the API usage of the commands recorded in the usage file
+type APIUsage struct {
+	Commands  map[string]*CommandUsage `json:"commands"`
+	RateLimit *RateLimit               `json:"rateLimit,omitempty"`
+}
+
+// the usage of the running command, which is recorded by all the clients created by NewClient
+var (
+	usageMu      sync.Mutex
+	usageCommand string
+	usage        CommandUsage
+	rateLimit    *RateLimit
+)
+
+// SetUsageCommand sets the name of the running command to record the API usage.
+func SetUsageCommand(name string) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	usageCommand = name
+}
+
+// CurrentUsage returns the API usage of the running command and the last rate limit reported by the API.
+// The rate limit is nil if no response reports it.
+func CurrentUsage() (CommandUsage, *RateLimit) {
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	return usage, rateLimit
+}
+
+// usageTransport counts the requests and records the rate limit headers of the responses
+type usageTransport struct {
+	transport http.RoundTripper
+	now       func() time.Time
+}
+
+func (t *usageTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := t.transport.RoundTrip(req)
+	usageMu.Lock()
+	defer usageMu.Unlock()
+	usage.Calls++
+	usage.LastUsedAt = t.now().Unix()
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode == http.StatusTooManyRequests {
+		usage.RateLimited++
+	}
+	rl := &RateLimit{
+		Limit:      resp.Header.Get("X-RateLimit-Limit"),
+		Remaining:  resp.Header.Get("X-RateLimit-Remaining"),
+		Reset:      resp.Header.Get("X-RateLimit-Reset"),
+		RetryAfter: resp.Header.Get("Retry-After"),
+		ObservedAt: t.now().Unix(),
+	}
+	if rl.Limit != "" || rl.Remaining != "" || rl.Reset != "" || rl.RetryAfter != "" {
+		rateLimit = rl
+	}
+	return resp, nil
+}
+
+// DefaultUsageFile returns the file to record the API usage of the commands in the cache directory.
+func DefaultUsageFile() string {
+	return filepath.Join(DefaultCacheDir(), "usage.json")
+}
+
+// LoadUsage loads the API usage recorded in the file. It returns the empty usage if the file does not exist.
+func LoadUsage(file string) (*APIUsage, error) {
+	u := &APIUsage{Commands: map[string]*CommandUsage{}}
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return u, nil
+		}
+		return nil, err
+	}
+	if err := json.Unmarshal(data, u); err != nil {
+		return nil, err
+	}
+	if u.Commands == nil {
+		u.Commands = map[string]*CommandUsage{}
+	}
+	return u, nil
+}
+
+// SaveUsage adds the API usage of the running command to the file. Nothing is saved if no request is made.
+func SaveUsage(file string) error {
+	current, rl := CurrentUsage()
+	if current.Calls == 0 {
+		return nil
+	}
+	usageMu.Lock()
+	name := usageCommand
+	usageMu.Unlock()
+	if name == "" {
+		name = "mkr"
+	}
+
+	if err := os.MkdirAll(filepath.Dir(file), 0700); err != nil {
+		return err
+	}
+	// the file is locked while it is updated since multiple mkr processes may run at the same time
+	unlock, err := lockFile(file + ".lock")
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	u, err := LoadUsage(file)
+	if err != nil {
+		return err
+	}
+	cu := u.Commands[name]
+	if cu == nil {
+		cu = &CommandUsage{}
+		u.Commands[name] = cu
+	}
+	cu.Calls += current.Calls
+	cu.RateLimited += current.RateLimited
+	cu.LastUsedAt = current.LastUsedAt
+	if rl != nil {
+		u.RateLimit = rl
+	}
+	data, err := json.Marshal(u)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(file, data)
+}
+
+// the interval to retry locking the file, and the age of the lock file regarded as left by a killed process
+var (
+	lockRetryInterval = 10 * time.Millisecond
+	lockTimeout       = 3 * time.Second
+	staleLockAge      = 10 * time.Second
+)
+
+// lockFile creates the lock file exclusively, waiting for the other processes to remove it.
+// The returned function removes the lock file.
+func lockFile(lock string) (unlock func(), err error) {
+	deadline := time.Now().Add(lockTimeout)
+	for {
+		f, err := os.OpenFile(lock, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+		if err == nil {
+			f.Close()
+			return func() { os.Remove(lock) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, err
+		}
+		if fi, err := os.Stat(lock); err == nil && time.Since(fi.ModTime()) > staleLockAge {
+			os.Remove(lock)
+			continue
+		}
+		if time.Now().After(deadline) {
+			return nil, fmt.Errorf("failed to lock %s: timed out", lock)
+		}
+		time.Sleep(lockRetryInterval)
+	}
+}
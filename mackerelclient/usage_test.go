@@ -0,0 +1,159 @@
+package mackerelclient
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+func resetUsage(t *testing.T) {
+	usageMu.Lock()
+	usageCommand, usage, rateLimit = "", CommandUsage{}, nil
+	usageMu.Unlock()
+	t.Cleanup(func() {
+		usageMu.Lock()
+		usageCommand, usage, rateLimit = "", CommandUsage{}, nil
+		usageMu.Unlock()
+	})
+}
+
+func TestUsageTransport(t *testing.T) {
+	resetUsage(t)
+	statuses := []int{429, 200, 200}
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		status := statuses[0]
+		statuses = statuses[1:]
+		if status == 429 {
+			w.Header().Set("Retry-After", "0")
+		} else if len(statuses) == 1 {
+			w.Header().Set("X-RateLimit-Limit", "150")
+			w.Header().Set("X-RateLimit-Remaining", "148")
+		}
+		w.WriteHeader(status)
+	}))
+	defer ts.Close()
+
+	now := time.Unix(1700000000, 0)
+	transport := &usageTransport{transport: http.DefaultTransport, now: func() time.Time { return now }}
+	for i := 0; i < 3; i++ {
+		req, _ := http.NewRequest("GET", ts.URL, nil)
+		resp, err := transport.RoundTrip(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+	}
+
+	current, rl := CurrentUsage()
+	if expect := (CommandUsage{Calls: 3, RateLimited: 1, LastUsedAt: now.Unix()}); current != expect {
+		t.Errorf("usage should be %+v but: %+v", expect, current)
+	}
+	// the rate limit of the last response reporting it is kept
+	if expect := (&RateLimit{Limit: "150", Remaining: "148", ObservedAt: now.Unix()}); !reflect.DeepEqual(rl, expect) {
+		t.Errorf("rate limit should be %+v but: %+v", expect, rl)
+	}
+}
+
+func TestSaveUsage(t *testing.T) {
+	resetUsage(t)
+	dir, err := ioutil.TempDir("", "mkr-usage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "mkr", "usage.json")
+
+	if err := SaveUsage(file); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("nothing should be saved without requests")
+	}
+
+	SetUsageCommand("hosts")
+	for _, calls := range []int64{3, 2} {
+		usageMu.Lock()
+		usage = CommandUsage{Calls: calls, RateLimited: 1, LastUsedAt: 1700000000 + calls}
+		rateLimit = &RateLimit{Remaining: "10", ObservedAt: 1700000000}
+		usageMu.Unlock()
+		if err := SaveUsage(file); err != nil {
+			t.Fatal(err)
+		}
+	}
+	u, err := LoadUsage(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expect := &APIUsage{
+		Commands:  map[string]*CommandUsage{"hosts": {Calls: 5, RateLimited: 2, LastUsedAt: 1700000002}},
+		RateLimit: &RateLimit{Remaining: "10", ObservedAt: 1700000000},
+	}
+	if !reflect.DeepEqual(u, expect) {
+		t.Errorf("usage should be %+v but: %+v", expect, u)
+	}
+}
+
+func TestSaveUsage_concurrent(t *testing.T) {
+	resetUsage(t)
+	dir, err := ioutil.TempDir("", "mkr-usage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	file := filepath.Join(dir, "usage.json")
+
+	SetUsageCommand("hosts")
+	usageMu.Lock()
+	usage = CommandUsage{Calls: 1}
+	usageMu.Unlock()
+	// the goroutines stand for the processes saving the usage at the same time
+	var wg sync.WaitGroup
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := SaveUsage(file); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+	u, err := LoadUsage(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if calls := u.Commands["hosts"].Calls; calls != 20 {
+		t.Errorf("all the usage should be saved but the calls are %d", calls)
+	}
+	if _, err := os.Stat(file + ".lock"); !os.IsNotExist(err) {
+		t.Errorf("the lock file should be removed")
+	}
+}
+
+func TestLockFile_stale(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-usage-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	lock := filepath.Join(dir, "usage.json.lock")
+
+	if err := ioutil.WriteFile(lock, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	old := time.Now().Add(-time.Minute)
+	if err := os.Chtimes(lock, old, old); err != nil {
+		t.Fatal(err)
+	}
+	unlock, err := lockFile(lock)
+	if err != nil {
+		t.Fatalf("the stale lock file should be removed: %s", err)
+	}
+	unlock()
+}
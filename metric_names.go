@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandMetricNames = cli.Command{
+	Name:      "metric-names",
+	Usage:     "List metric names",
+	ArgsUsage: "(--host-id | -H <hostId> | --host-name <name> | --service | -s <service>) [--filter <regex>]",
+	Description: `
+    List the names of the metrics posted to the host or the service.
+    Requests "GET /api/v0/hosts/<hostId>/metric-names" or "GET /api/v0/services/<serviceName>/metric-names".
+    See https://mackerel.io/api-docs/entry/hosts#metric-names, https://mackerel.io/api-docs/entry/services#metric-names .
+`,
+	Action: doMetricNames,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "host-id, H", Value: "", Usage: "List the metric names of <hostId>."},
+		mackerelclient.HostNameFlag,
+		cli.StringFlag{Name: "service, s", Value: "", Usage: "List the metric names of <service>."},
+		cli.StringFlag{Name: "filter", Value: "", Usage: "List only the metric names matched with <regex>."},
+	},
+}
+
+// filterMetricNames returns the sorted names matched with the pattern.
+func filterMetricNames(names []string, re *regexp.Regexp) []string {
+	filtered := make([]string, 0, len(names))
+	for _, name := range names {
+		if re == nil || re.MatchString(name) {
+			filtered = append(filtered, name)
+		}
+	}
+	sort.Strings(filtered)
+	return filtered
+}
+
+func doMetricNames(c *cli.Context) error {
+	isHost := c.String("host-id") != "" || c.String("host-name") != ""
+	optService := c.String("service")
+	if isHost == (optService != "") {
+		cli.ShowCommandHelp(c, "metric-names")
+		os.Exit(1)
+	}
+
+	var re *regexp.Regexp
+	if filter := c.String("filter"); filter != "" {
+		var err error
+		if re, err = regexp.Compile(filter); err != nil {
+			return fmt.Errorf("invalid filter: %s", err)
+		}
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	var names []string
+	if isHost {
+		hostID, err := mackerelclient.HostIDFromContext(c, client, "host-id")
+		if err != nil {
+			return cli.NewExitError(err.Error(), 1)
+		}
+		names, err = client.ListHostMetricNames(hostID)
+		logger.DieIf(err)
+	} else {
+		var err error
+		names, err = client.ListServiceMetricNames(optService)
+		logger.DieIf(err)
+	}
+
+	format.PrettyPrintJSON(os.Stdout, filterMetricNames(names, re))
+	return nil
+}
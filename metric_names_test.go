@@ -0,0 +1,17 @@
+package main
+
+import (
+	"reflect"
+	"regexp"
+	"testing"
+)
+
+func TestFilterMetricNames(t *testing.T) {
+	names := []string{"loadavg5", "custom.foo.bar", "cpu.user.percentage", "custom.baz"}
+	if got, expected := filterMetricNames(names, nil), []string{"cpu.user.percentage", "custom.baz", "custom.foo.bar", "loadavg5"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("names should be %v but: %v", expected, got)
+	}
+	if got, expected := filterMetricNames(names, regexp.MustCompile(`^custom\.`)), []string{"custom.baz", "custom.foo.bar"}; !reflect.DeepEqual(got, expected) {
+		t.Errorf("names should be %v but: %v", expected, got)
+	}
+}
@@ -3,9 +3,12 @@ package main
 import (
 	"fmt"
 	"os"
+	"sync"
 
 	"github.com/mackerelio/mackerel-agent/config"
+	"github.com/mackerelio/mkr/format"
 	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
 	"github.com/urfave/cli"
 )
 
@@ -15,7 +18,7 @@ func main() {
 	app.Version = fmt.Sprintf("%s (rev:%s)", version, gitcommit)
 	app.Usage = "A CLI tool for mackerel.io"
 	app.Author = "Hatena Co., Ltd."
-	app.Commands = Commands
+	app.Commands = addQueryFlag(recordUsageCommands(Commands, ""))
 	app.Flags = []cli.Flag{
 		cli.StringFlag{
 			Name:  "conf",
@@ -27,9 +30,110 @@ func main() {
 			// this default value is set in config.LoadApibaseFromConfigWithFallback
 			Usage: fmt.Sprintf("API Base (default: \"%s\")", config.DefaultConfig.Apibase),
 		},
+		cli.StringFlag{
+			Name:   "profile",
+			EnvVar: "MKR_PROFILE",
+			Usage:  "Profile name defined in ~/.config/mkr/config.toml",
+		},
+		cli.StringFlag{
+			Name:  "output",
+			Usage: "Output format of the commands: 'json', 'yaml', 'table', 'tsv' or 'go-template=<template>'",
+		},
+		queryFlag,
+		cli.StringFlag{
+			Name:   "log-level",
+			Value:  "info",
+			EnvVar: "MKR_LOG_LEVEL",
+			Usage:  "Log level: 'debug', 'info', 'warn' or 'error'. The API requests and responses are logged in debug",
+		},
+		cli.StringFlag{
+			Name:   "log-format",
+			Value:  "text",
+			EnvVar: "MKR_LOG_FORMAT",
+			Usage:  "Log format: 'text' or 'json'",
+		},
+		cli.DurationFlag{
+			Name:   "http-timeout",
+			Value:  mackerelclient.DefaultHTTPTimeout,
+			EnvVar: "MKR_HTTP_TIMEOUT",
+			Usage:  "Timeout of each API request. No timeout if it is zero",
+		},
+		cli.IntFlag{
+			Name:   "max-retries",
+			Value:  mackerelclient.DefaultMaxRetries,
+			EnvVar: "MKR_MAX_RETRIES",
+			Usage:  "Maximum number of the retries of the API requests which are rate limited or failed with server errors",
+		},
+		cli.BoolFlag{
+			Name:   "cache",
+			EnvVar: "MKR_CACHE",
+			Usage:  "Cache the responses of hosts, monitors, dashboards and services in ~/.cache/mkr",
+		},
+		cli.DurationFlag{
+			Name:   "cache-ttl",
+			Value:  mackerelclient.DefaultCacheTTL,
+			EnvVar: "MKR_CACHE_TTL",
+			Usage:  "Time to live of the cached responses",
+		},
+		cli.BoolFlag{
+			Name:   "offline",
+			EnvVar: "MKR_OFFLINE",
+			Usage:  "Serve the responses only from the cache without requesting the API",
+		},
+		cli.BoolFlag{
+			Name:   "show-rate-limit",
+			EnvVar: "MKR_SHOW_RATE_LIMIT",
+			Usage:  "Show the number of the API requests and the rate limit reported by the API after the command",
+		},
 	}
+	var showRateLimit bool
+	app.Before = func(c *cli.Context) error {
+		level := c.String("log-level")
+		if os.Getenv("DEBUG") != "" && !c.IsSet("log-level") {
+			level = "debug"
+		}
+		if err := logger.SetLevel(level); err != nil {
+			return err
+		}
+		if err := logger.SetFormat(c.String("log-format")); err != nil {
+			return err
+		}
+		if err := mackerelclient.SetHTTPOptions(c.Duration("http-timeout"), c.Int("max-retries")); err != nil {
+			return err
+		}
+		if err := mackerelclient.SetCacheOptions(mackerelclient.CacheOptions{
+			Enabled: c.Bool("cache"),
+			TTL:     c.Duration("cache-ttl"),
+			Offline: c.Bool("offline"),
+		}); err != nil {
+			return err
+		}
+		showRateLimit = c.Bool("show-rate-limit")
+		// the default output format of the profile is resolved once here, not by every rendering
+		if profile := mackerelclient.SelectProfile(c.String("conf"), c.String("profile")); profile != "" {
+			if p, err := mackerelclient.LoadProfile(profile); err == nil {
+				format.SetDefaultOutput(p.Output)
+			}
+		}
+		return format.SetQuery(c.String("query"))
+	}
+
+	// the usage is also flushed when the commands exit by logger.DieIf
+	var flushOnce sync.Once
+	flushUsage := func() {
+		flushOnce.Do(func() {
+			if err := mackerelclient.SaveUsage(mackerelclient.DefaultUsageFile()); err != nil {
+				logger.Debugf("failed to save the API usage: %s", err)
+			}
+			if showRateLimit {
+				logger.Log("api", describeCurrentUsage())
+			}
+		})
+	}
+	logger.AtExit(flushUsage)
 
 	err := app.Run(os.Args)
+	flushUsage()
 	if err != nil {
 		exitCode := 1
 		if excoder, ok := err.(cli.ExitCoder); ok {
@@ -39,3 +143,30 @@ func main() {
 		os.Exit(exitCode)
 	}
 }
+
+var queryFlag = cli.StringFlag{
+	Name:  "query",
+	Usage: "The jq `expression` applied to the JSON output, such as '.[].id'",
+}
+
+// addQueryFlag adds the --query flag to the commands recursively,
+// so that it can be specified after the command name like 'mkr hosts --query .[].id'.
+func addQueryFlag(commands []cli.Command) []cli.Command {
+	cmds := make([]cli.Command, len(commands))
+	for i, cmd := range commands {
+		cmd.Flags = append(cmd.Flags[:len(cmd.Flags):len(cmd.Flags)], queryFlag)
+		cmd.Subcommands = addQueryFlag(cmd.Subcommands)
+		before := cmd.Before
+		cmd.Before = func(c *cli.Context) error {
+			if err := format.SetQuery(c.String("query")); err != nil {
+				return err
+			}
+			if before != nil {
+				return before(c)
+			}
+			return nil
+		}
+		cmds[i] = cmd
+	}
+	return cmds
+}
@@ -4,9 +4,14 @@ import (
 	"encoding/json"
 	"fmt"
 	"io"
+	"io/ioutil"
 	"log"
 	"os"
+	"path/filepath"
 	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
 	"strings"
 
 	"github.com/mackerelio/mackerel-client-go"
@@ -26,51 +31,164 @@ var commandMonitors = cli.Command{
     Requests APIs under "/api/v0/monitors". See https://mackerel.io/api-docs/entry/monitors .
 `,
 	Action: doMonitorsList,
+	Flags:  monitorsListFlags,
 	Subcommands: []cli.Command{
+		{
+			Name:      "list",
+			Usage:     "list monitors",
+			ArgsUsage: "[--type <type>] [--service <service>] [--scope <scope>] [--name-match <regexp>] [--output | -o <format>]",
+			Description: `
+    Show the monitor rules filtered by the type and the regular expression matching their names.
+`,
+			Action: doMonitorsList,
+			Flags:  monitorsListFlags,
+		},
 		{
 			Name:      "pull",
 			Usage:     "pull rules",
-			ArgsUsage: "[--file-path | -F <file>] [--verbose | -v]",
+			ArgsUsage: "[--file-path | -F <file>] [--split-dir <dir> [--filename-format id|name]] [--type <type>] [--service <service>] [--scope <scope>] [--verbose | -v]",
 			Description: `
     Pull monitor rules from Mackerel server and save them to a file. The file can be specified by filepath argument <file>. The default is 'monitors.json'.
+    With --split-dir, each monitor rule is saved to its own file in the directory.
+    With --type, --service or --scope, only the matched rules are saved. Specify the same options to diff and push,
+    so that the other rules on Mackerel are not regarded as removed.
 `,
 			Action: doMonitorsPull,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Filename to store monitor rule definitions. default: monitors.json"},
+				cli.StringFlag{Name: "split-dir", Value: "", Usage: "Directory to store monitor rule definitions in separate files."},
+				cli.StringFlag{Name: "filename-format", Value: "id", Usage: "Name the files in --split-dir by 'id' or 'name'."},
 				cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
-			},
+			}, monitorsFilterFlags...),
 		},
 		{
 			Name:  "diff",
 			Usage: "diff rules",
 			Description: `
     Show difference of monitor rules between Mackerel and a file. The file can be specified by filepath argument <file>. The default is 'monitors.json'.
+    The monitors which have "extends" field are expanded with the templates in "templates" field of the file before comparison.
 `,
-			ArgsUsage: "[--file-path | -F <file>]",
+			ArgsUsage: "[--file-path | -F <file>] [--split-dir <dir>] [--type <type>] [--service <service>] [--scope <scope>] [--output | -o text|json]",
 			Action:    doMonitorsDiff,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.BoolFlag{Name: "exit-code, e", Usage: "Make mkr exit with code 1 if there are differences and 0 if there aren't. This is similar to diff(1)"},
 				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Filename to store monitor rule definitions. default: monitors.json"},
+				cli.StringFlag{Name: "split-dir", Value: "", Usage: "Directory of monitor rule definitions in separate files."},
 				cli.BoolFlag{Name: "reverse", Usage: "The difference on the remote server is represented by plus and the difference on the local file is represented by minus"},
-			},
+				cli.StringFlag{Name: "output, o", Value: "text", Usage: "Output format: 'text' or 'json'"},
+			}, monitorsFilterFlags...),
 		},
 		{
 			Name:      "push",
 			Usage:     "push rules",
-			ArgsUsage: "[--dry-run | -d] [--file-path | -F <file>] [--verbose | -v]",
+			ArgsUsage: "[--dry-run | -d] [--parallel <num>] [--file-path | -F <file>] [--split-dir <dir>] [--type <type>] [--service <service>] [--scope <scope>] [--verbose | -v]",
 			Description: `
     Push monitor rules stored in a file to Mackerel. The file can be specified by filepath argument <file>. The default is 'monitors.json'.
+    With --split-dir, monitor rules are read from the files in the directory, each of which has one monitor rule.
+    The rules to be created or updated are validated before any change is made. With --dry-run, the difference is shown instead of pushing it.
 `,
 			Action: doMonitorsPush,
-			Flags: []cli.Flag{
+			Flags: append([]cli.Flag{
 				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Filename to store monitor rule definitions. default: monitors.json"},
-				cli.BoolFlag{Name: "dry-run, d", Usage: "Show which apis are called, but not execute."},
+				cli.StringFlag{Name: "split-dir", Value: "", Usage: "Directory of monitor rule definitions in separate files."},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Validate the rules and show the difference to be pushed, but not execute."},
+				cli.IntFlag{Name: "parallel", Value: 1, Usage: "Number of the rules pushed concurrently. The rate limited requests are retried with backoff."},
 				cli.BoolFlag{Name: "verbose, v", Usage: "Verbose output mode"},
+			}, monitorsFilterFlags...),
+		},
+		{
+			Name:      "disable",
+			Usage:     "disable monitors",
+			ArgsUsage: "[--id <monitorId>]... [--name-match <regexp>] [<monitorIds...>]",
+			Description: `
+    Disable (mute) the monitors specified by IDs or the regular expression matching their names.
+`,
+			Action: doMonitorsDisable,
+			Flags:  monitorsMuteFlags,
+		},
+		{
+			Name:      "enable",
+			Usage:     "enable monitors",
+			ArgsUsage: "[--id <monitorId>]... [--name-match <regexp>] [<monitorIds...>]",
+			Description: `
+    Enable (unmute) the monitors specified by IDs or the regular expression matching their names.
+`,
+			Action: doMonitorsEnable,
+			Flags:  monitorsMuteFlags,
+		},
+		{
+			Name:      "test",
+			Usage:     "test a monitor against recent metrics",
+			ArgsUsage: "--file-path | -F <file> [--name <name>] (--host <hostId> | --host-name <name> | --service <service>) [--hours <hours>]",
+			Description: `
+    Evaluate a host or service metric monitor in the file against the metric values of the last hours,
+    and show when the monitor would have changed its status. The file has a monitor rule, or monitor rules
+    of 'mkr monitors pull' with --name to specify one of them.
+`,
+			Action: doMonitorsTest,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Filename of the monitor rule definition"},
+				cli.StringFlag{Name: "name", Value: "", Usage: "Name of the monitor in the file"},
+				cli.StringFlag{Name: "host, H", Value: "", Usage: "Host ID to fetch the metric values for host metric monitors"},
+				mackerelclient.HostNameFlag,
+				cli.StringFlag{Name: "service, s", Value: "", Usage: "Service name to fetch the metric values for service metric monitors"},
+				cli.IntFlag{Name: "hours", Value: 24, Usage: "Hours of the metric values to evaluate"},
+			},
+		},
+		{
+			Name:      "import",
+			Usage:     "import alerting rules of other monitoring systems",
+			ArgsUsage: "--format prometheus [--file-path | -F <file>] [--dry-run | -d] <ruleFile>",
+			Description: `
+    Convert the alerting rules of Prometheus into monitor rules and append them to the file. The default is 'monitors.json'.
+    The rules comparing a metric of node_exporter with a number are converted into host metric monitors,
+    or expression monitors if the metric is aggregated by "role" label. The other rules are skipped with warnings.
+`,
+			Action: doMonitorsImport,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "format", Value: "prometheus", Usage: "Format of the rule file. Only 'prometheus' is supported."},
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Filename to store monitor rule definitions. default: monitors.json"},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show the converted monitor rules, but not append them."},
+			},
+		},
+		{
+			Name:      "lint",
+			Usage:     "check monitor rules in the file",
+			ArgsUsage: "[--file-path | -F <file>] [--check-refs] [<file>]",
+			Description: `
+    Check the monitor rules in the file without sending them. The default is 'monitors.json'.
+    In addition to the checks of 'mkr monitors push', the durations and the intervals are checked to be
+    in the ranges accepted by Mackerel, and the names are checked to be unique.
+    With --check-refs, the services and the roles in the scopes are also checked to exist in the organization,
+    and so are the notification channels of the notification groups which the monitors (matched by "id") belong to.
+    Exits with status 1 if any problems are found.
+`,
+			Action: doMonitorsLint,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "Filename of monitor rule definitions. default: monitors.json"},
+				cli.BoolFlag{Name: "check-refs", Usage: "Check the services, the roles and the notification channels referred by the monitors exist"},
 			},
 		},
 	},
 }
 
+var monitorsFilterFlags = []cli.Flag{
+	cli.StringFlag{Name: "type", Value: "", Usage: "Only the monitors of the type: connectivity, host, service, external, expression or anomalyDetection"},
+	cli.StringFlag{Name: "service", Value: "", Usage: "Only the monitors of the service or the roles of it"},
+	cli.StringFlag{Name: "scope", Value: "", Usage: "Only the monitors with the scope such as 'service' or 'service:role'"},
+}
+
+var monitorsListFlags = append([]cli.Flag{
+	cli.StringFlag{Name: "name-match", Value: "", Usage: "Show only the monitors whose names match the regular expression"},
+	cli.StringFlag{Name: "output, o", Value: "json", Usage: format.OutputUsage},
+}, monitorsFilterFlags...)
+
+var monitorsMuteFlags = []cli.Flag{
+	cli.StringSliceFlag{Name: "id", Value: &cli.StringSlice{}, Usage: "Monitor ID. Multiple choices are allowed."},
+	cli.StringFlag{Name: "name-match", Value: "", Usage: "Regular expression matching the names of the monitors"},
+	cli.BoolFlag{Name: "dry-run, d", Usage: "Show the monitors to be changed, but not execute."},
+}
+
 func monitorSaveRules(rules []mackerel.Monitor, filePath string) error {
 	file, err := os.Create(filePath)
 	if err != nil {
@@ -101,19 +219,87 @@ func monitorLoadRules(optFilePath string) ([]mackerel.Monitor, error) {
 	return decodeMonitors(f)
 }
 
+func monitorFileName(m mackerel.Monitor, filenameFormat string) (string, error) {
+	var name string
+	switch filenameFormat {
+	case "", "id":
+		name = m.MonitorID()
+	case "name":
+		name = slugify(m.MonitorName())
+	default:
+		return "", fmt.Errorf("filename-format should be 'id' or 'name': %s", filenameFormat)
+	}
+	if name == "" {
+		name = m.MonitorID()
+	}
+	return name + ".json", nil
+}
+
+// monitorSaveRulesSplit saves each monitor rule to its own file in dir.
+func monitorSaveRulesSplit(rules []mackerel.Monitor, dir, filenameFormat string) error {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	names := map[string]bool{}
+	for _, m := range rules {
+		name, err := monitorFileName(m, filenameFormat)
+		if err != nil {
+			return err
+		}
+		if names[name] {
+			// avoid overwriting the rules which have the same name
+			name = m.MonitorID() + ".json"
+		}
+		names[name] = true
+		data := format.JSONMarshalIndent(m, "", "    ") + "\n"
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(data), 0644); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// monitorLoadRulesSplit loads monitor rules from the JSON files in dir.
+func monitorLoadRulesSplit(dir string) ([]mackerel.Monitor, error) {
+	filePaths, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+	sort.Strings(filePaths)
+	ms := make([]mackerel.Monitor, 0, len(filePaths))
+	for _, filePath := range filePaths {
+		data, err := ioutil.ReadFile(filePath)
+		if err != nil {
+			return nil, err
+		}
+		m, err := decodeMonitor(data)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load '%s': %s", filePath, err)
+		}
+		ms = append(ms, m)
+	}
+	return ms, nil
+}
+
 // decodeMonitors decodes monitors JSON.
+// The monitors which have "extends" field are expanded with the templates in "templates" field.
 //
 // There are almost same code in mackerel-client-go.
 func decodeMonitors(r io.Reader) ([]mackerel.Monitor, error) {
 	var data struct {
-		Monitors []json.RawMessage `json:"monitors"`
+		Templates map[string]json.RawMessage `json:"templates"`
+		Monitors  []json.RawMessage          `json:"monitors"`
 	}
 	if err := json.NewDecoder(r).Decode(&data); err != nil {
 		return nil, err
 	}
 	ms := make([]mackerel.Monitor, 0, len(data.Monitors))
-	for _, rawmes := range data.Monitors {
-		m, err := decodeMonitor(rawmes)
+	for i, rawmes := range data.Monitors {
+		expanded, err := expandMonitorTemplate(rawmes, data.Templates, nil)
+		if err != nil {
+			return nil, fmt.Errorf("monitors[%d]: %s", i, err)
+		}
+		m, err := decodeMonitor(expanded)
 		if err != nil {
 			return nil, err
 		}
@@ -122,6 +308,47 @@ func decodeMonitors(r io.Reader) ([]mackerel.Monitor, error) {
 	return ms, nil
 }
 
+// expandMonitorTemplate merges the fields of a monitor into the template which the monitor extends.
+// The fields of the monitor override the ones of the template, and templates can extend other templates.
+func expandMonitorTemplate(mes json.RawMessage, templates map[string]json.RawMessage, visited []string) (json.RawMessage, error) {
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(mes, &fields); err != nil {
+		return nil, err
+	}
+	ext, ok := fields["extends"]
+	if !ok {
+		return mes, nil
+	}
+	delete(fields, "extends")
+
+	var name string
+	if err := json.Unmarshal(ext, &name); err != nil {
+		return nil, fmt.Errorf("extends should be a template name: %s", ext)
+	}
+	for _, v := range visited {
+		if v == name {
+			return nil, fmt.Errorf("circular extends of template: %s", strings.Join(append(visited, name), " -> "))
+		}
+	}
+	tmpl, ok := templates[name]
+	if !ok {
+		return nil, fmt.Errorf("template not found: %s", name)
+	}
+	tmpl, err := expandMonitorTemplate(tmpl, templates, append(visited, name))
+	if err != nil {
+		return nil, err
+	}
+
+	var merged map[string]json.RawMessage
+	if err := json.Unmarshal(tmpl, &merged); err != nil {
+		return nil, fmt.Errorf("template %s: %s", name, err)
+	}
+	for k, v := range fields {
+		merged[k] = v
+	}
+	return json.Marshal(merged)
+}
+
 // decodeMonitor decodes json.RawMessage and returns monitor.
 //
 // There are almost same code in mackerel-client-go.
@@ -153,20 +380,150 @@ func decodeMonitor(mes json.RawMessage) (mackerel.Monitor, error) {
 	return m, nil
 }
 
+// monitorScopes returns the scopes of the monitor. The excluded scopes are prefixed with '!'.
+func monitorScopes(monitor mackerel.Monitor) []string {
+	var scopes []string
+	switch m := monitor.(type) {
+	case *mackerel.MonitorConnectivity:
+		scopes = append(scopes, m.Scopes...)
+		for _, s := range m.ExcludeScopes {
+			scopes = append(scopes, "!"+s)
+		}
+	case *mackerel.MonitorHostMetric:
+		scopes = append(scopes, m.Scopes...)
+		for _, s := range m.ExcludeScopes {
+			scopes = append(scopes, "!"+s)
+		}
+	case *mackerel.MonitorServiceMetric:
+		scopes = append(scopes, m.Service)
+	case *mackerel.MonitorExternalHTTP:
+		if m.Service != "" {
+			scopes = append(scopes, m.Service)
+		}
+	case *mackerel.MonitorAnomalyDetection:
+		scopes = append(scopes, m.Scopes...)
+	}
+	return scopes
+}
+
+// monitorFilter selects the monitors. The empty conditions match any monitors.
+type monitorFilter struct {
+	typ       string
+	nameMatch *regexp.Regexp
+	service   string
+	scope     string
+}
+
+func newMonitorFilter(c *cli.Context) (*monitorFilter, error) {
+	f := &monitorFilter{
+		typ:     c.String("type"),
+		service: c.String("service"),
+		scope:   c.String("scope"),
+	}
+	if nameMatch := c.String("name-match"); nameMatch != "" {
+		var err error
+		if f.nameMatch, err = regexp.Compile(nameMatch); err != nil {
+			return nil, fmt.Errorf("invalid --name-match: %s", err)
+		}
+	}
+	return f, nil
+}
+
+// match reports whether the monitor satisfies the conditions.
+// A monitor belongs to the service if its scopes or its service is the service or the roles of it.
+// Expression monitors belong to the services whose roles are referred by the expressions.
+func (f *monitorFilter) match(m mackerel.Monitor) bool {
+	if f.typ != "" && m.MonitorType() != f.typ {
+		return false
+	}
+	if f.nameMatch != nil && !f.nameMatch.MatchString(m.MonitorName()) {
+		return false
+	}
+	if f.service == "" && f.scope == "" {
+		return true
+	}
+	var serviceFound, scopeFound bool
+	for _, s := range monitorScopes(m) {
+		s = strings.TrimPrefix(s, "!")
+		if s == f.service || strings.HasPrefix(s, f.service+":") {
+			serviceFound = true
+		}
+		if s == f.scope {
+			scopeFound = true
+		}
+	}
+	if e, ok := m.(*mackerel.MonitorExpression); ok && f.service != "" && strings.Contains(e.Expression, f.service+":") {
+		serviceFound = true
+	}
+	return (f.service == "" || serviceFound) && (f.scope == "" || scopeFound)
+}
+
+func filterMonitors(monitors []mackerel.Monitor, f *monitorFilter) []mackerel.Monitor {
+	filtered := make([]mackerel.Monitor, 0, len(monitors))
+	for _, m := range monitors {
+		if f.match(m) {
+			filtered = append(filtered, m)
+		}
+	}
+	return filtered
+}
+
+func printMonitors(w io.Writer, monitors []mackerel.Monitor, output string) error {
+	if output == "" {
+		output = format.OutputJSON
+	}
+	o, err := format.ParseOutput(output)
+	if err != nil {
+		return err
+	}
+	return o.Print(w, monitors, func() *format.Table {
+		t := format.NewTable("ID", "TYPE", "NAME", "SCOPES", "MUTE")
+		for _, m := range monitors {
+			var isMute bool
+			if f := monitorMuteField(m); f != nil {
+				isMute = *f
+			}
+			t.Append(m.MonitorID(), m.MonitorType(), m.MonitorName(), strings.Join(monitorScopes(m), ","), strconv.FormatBool(isMute))
+		}
+		return t
+	})
+}
+
 func doMonitorsList(c *cli.Context) error {
+	filter, err := newMonitorFilter(c)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
 	monitors, err := mackerelclient.NewFromContext(c).FindMonitors()
 	logger.DieIf(err)
 
-	format.PrettyPrintJSON(os.Stdout, monitors)
+	monitors = filterMonitors(monitors, filter)
+	if err := printMonitors(os.Stdout, monitors, format.OutputName(c, "json", format.OutputFormats...)); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
 	return nil
 }
 
 func doMonitorsPull(c *cli.Context) error {
 	isVerbose := c.Bool("verbose")
 	filePath := c.String("file-path")
+	splitDir := c.String("split-dir")
+	filter, err := newMonitorFilter(c)
+	logger.DieIf(err)
 
 	monitors, err := mackerelclient.NewFromContext(c).FindMonitors()
 	logger.DieIf(err)
+	monitors = filterMonitors(monitors, filter)
+
+	if splitDir != "" {
+		logger.DieIf(monitorSaveRulesSplit(monitors, splitDir, c.String("filename-format")))
+		if isVerbose {
+			format.PrettyPrintJSON(os.Stdout, monitors)
+		}
+		logger.Log("info", fmt.Sprintf("Monitor rules are saved to '%s' (%d rules).", splitDir, len(monitors)))
+		return nil
+	}
 
 	if filePath == "" {
 		filePath = "monitors.json"
@@ -324,18 +681,47 @@ type monitorDiff struct {
 
 func checkMonitorsDiff(c *cli.Context) monitorDiff {
 	filePath := c.String("file-path")
+	splitDir := c.String("split-dir")
 
-	var monitorDiff monitorDiff
-
+	filter, err := newMonitorFilter(c)
+	logger.DieIf(err)
 	monitorsRemote, err := mackerelclient.NewFromContext(c).FindMonitors()
 	logger.DieIf(err)
-	flagNameUniquenessRemote, err := validateRules(monitorsRemote, "remote rules")
+
+	var monitorsLocal []mackerel.Monitor
+	if splitDir != "" {
+		monitorsLocal, err = monitorLoadRulesSplit(splitDir)
+	} else {
+		monitorsLocal, err = monitorLoadRules(filePath)
+	}
 	logger.DieIf(err)
 
-	monitorsLocal, err := monitorLoadRules(filePath)
+	monitorDiff, err := diffFilteredMonitors(monitorsRemote, monitorsLocal, filter)
 	logger.DieIf(err)
+	return monitorDiff
+}
+
+// diffFilteredMonitors compares the remote and the local monitor rules selected by the filter,
+// so that the local rules out of the filter are neither created nor reported.
+func diffFilteredMonitors(monitorsRemote, monitorsLocal []mackerel.Monitor, f *monitorFilter) (monitorDiff, error) {
+	return diffMonitors(filterMonitors(monitorsRemote, f), filterMonitors(monitorsLocal, f))
+}
+
+// diffMonitors compares the remote monitor rules with the local ones.
+// The rules are matched by the IDs, or by the names if the names are unique.
+func diffMonitors(monitorsRemote, monitorsLocal []mackerel.Monitor) (monitorDiff, error) {
+	var monitorDiff monitorDiff
+
+	flagNameUniquenessRemote, err := validateRules(monitorsRemote, "remote rules")
+	if err != nil {
+		return monitorDiff, err
+	}
 	flagNameUniquenessLocal, err := validateRules(monitorsLocal, "local rules")
-	logger.DieIf(err)
+	if err != nil {
+		return monitorDiff, err
+	}
+	// the matched local rules are set to nil below
+	monitorsLocal = append([]mackerel.Monitor(nil), monitorsLocal...)
 
 	flagNameUniqueness := flagNameUniquenessLocal && flagNameUniquenessRemote
 
@@ -362,14 +748,95 @@ func checkMonitorsDiff(c *cli.Context) monitorDiff {
 		}
 	}
 
-	return monitorDiff
+	return monitorDiff, nil
 }
 
-func doMonitorsDiff(c *cli.Context) error {
-	monitorDiff := checkMonitorsDiff(c)
-	isExitCode := c.Bool("exit-code")
-	isReverse := c.Bool("reverse")
+type monitorFieldChange struct {
+	Field string      `json:"field"`
+	From  interface{} `json:"from"`
+	To    interface{} `json:"to"`
+}
+
+type monitorChange struct {
+	ID      string                `json:"id"`
+	Name    string                `json:"name"`
+	Type    string                `json:"type"`
+	Changes []*monitorFieldChange `json:"changes"`
+}
+
+type monitorDiffResult struct {
+	Added   []mackerel.Monitor `json:"added"`
+	Removed []mackerel.Monitor `json:"removed"`
+	Changed []*monitorChange   `json:"changed"`
+}
+
+// diffMonitorFields returns the changes of the fields from a to b.
+// The fields of nested objects are represented by dotted paths, and the top level "id" field is skipped.
+func diffMonitorFields(a mackerel.Monitor, b mackerel.Monitor) []*monitorFieldChange {
+	var am, bm map[string]interface{}
+	json.Unmarshal([]byte(format.JSONMarshalIndent(a, "", "")), &am)
+	json.Unmarshal([]byte(format.JSONMarshalIndent(b, "", "")), &bm)
+	delete(am, "id")
+	delete(bm, "id")
+	changes := []*monitorFieldChange{}
+	diffMonitorFieldsRec("", am, bm, &changes)
+	return changes
+}
+
+func diffMonitorFieldsRec(prefix string, a, b map[string]interface{}, changes *[]*monitorFieldChange) {
+	keys := make([]string, 0, len(a)+len(b))
+	for k := range a {
+		keys = append(keys, k)
+	}
+	for k := range b {
+		if _, ok := a[k]; !ok {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	for _, k := range keys {
+		av, bv := a[k], b[k]
+		if am, ok := av.(map[string]interface{}); ok {
+			if bm, ok := bv.(map[string]interface{}); ok {
+				diffMonitorFieldsRec(prefix+k+".", am, bm, changes)
+				continue
+			}
+		}
+		if !reflect.DeepEqual(av, bv) {
+			*changes = append(*changes, &monitorFieldChange{Field: prefix + k, From: av, To: bv})
+		}
+	}
+}
+
+func (md monitorDiff) result(isReverse bool) *monitorDiffResult {
+	r := &monitorDiffResult{
+		Added:   []mackerel.Monitor{},
+		Removed: []mackerel.Monitor{},
+		Changed: []*monitorChange{},
+	}
+	if isReverse {
+		r.Added = append(r.Added, md.onlyRemote...)
+		r.Removed = append(r.Removed, md.onlyLocal...)
+	} else {
+		r.Added = append(r.Added, md.onlyLocal...)
+		r.Removed = append(r.Removed, md.onlyRemote...)
+	}
+	for _, d := range md.diff {
+		from, to := d.remote, d.local
+		if isReverse {
+			from, to = to, from
+		}
+		r.Changed = append(r.Changed, &monitorChange{
+			ID:      d.remote.MonitorID(),
+			Name:    d.remote.MonitorName(),
+			Type:    d.remote.MonitorType(),
+			Changes: diffMonitorFields(from, to),
+		})
+	}
+	return r
+}
 
+func printMonitorsDiff(w io.Writer, monitorDiff monitorDiff, isReverse bool) {
 	var diffs []string
 	for _, d := range monitorDiff.diff {
 		var diff string
@@ -391,57 +858,190 @@ func doMonitorsDiff(c *cli.Context) error {
 		monitorOnlyTo = monitorDiff.onlyLocal
 	}
 
-	fmt.Printf("Summary: %d modify, %d append, %d remove\n\n", len(monitorDiff.diff), len(monitorOnlyTo), len(monitorOnlyFrom))
-	noDiff := true
+	fmt.Fprintf(w, "Summary: %d modify, %d append, %d remove\n\n", len(monitorDiff.diff), len(monitorOnlyTo), len(monitorOnlyFrom))
 	for _, diff := range diffs {
-		fmt.Println(diff)
-		noDiff = false
+		fmt.Fprintln(w, diff)
 	}
 	for _, m := range monitorOnlyFrom {
-		fmt.Println(stringifyMonitor(m, "-"))
-		noDiff = false
+		fmt.Fprintln(w, stringifyMonitor(m, "-"))
 	}
 	for _, m := range monitorOnlyTo {
-		fmt.Println(stringifyMonitor(m, "+"))
-		noDiff = false
+		fmt.Fprintln(w, stringifyMonitor(m, "+"))
 	}
+}
+
+func doMonitorsDiff(c *cli.Context) error {
+	output := format.OutputName(c, "text", "text", "json")
+	if output != "text" && output != "json" {
+		return cli.NewExitError(fmt.Sprintf("output should be 'text' or 'json': %s", output), 1)
+	}
+
+	monitorDiff := checkMonitorsDiff(c)
+	isExitCode := c.Bool("exit-code")
+	isReverse := c.Bool("reverse")
+	noDiff := len(monitorDiff.diff) == 0 && len(monitorDiff.onlyLocal) == 0 && len(monitorDiff.onlyRemote) == 0
+
+	if output == "json" {
+		format.PrettyPrintJSON(os.Stdout, monitorDiff.result(isReverse))
+		if isExitCode && !noDiff {
+			logger.Exit(1)
+		}
+		return nil
+	}
+
+	printMonitorsDiff(os.Stdout, monitorDiff, isReverse)
 	if isExitCode == true && noDiff == false {
-		os.Exit(1)
+		logger.Exit(1)
 	}
 	return nil
 }
 
+// validateMonitorsPush validates the monitor rules to be created or updated.
+func validateMonitorsPush(monitorDiff monitorDiff) []string {
+	var problems []string
+	for _, m := range monitorDiff.onlyLocal {
+		problems = append(problems, validateMonitorPayload(m)...)
+	}
+	for _, d := range monitorDiff.diff {
+		problems = append(problems, validateMonitorPayload(d.local)...)
+	}
+	return problems
+}
+
 func doMonitorsPush(c *cli.Context) error {
 	monitorDiff := checkMonitorsDiff(c)
-	isDryRun := c.Bool("dry-run")
 	isVerbose := c.Bool("verbose")
 
 	client := mackerelclient.NewFromContext(c)
 	if isVerbose {
 		client.Verbose = true
 	}
+	var progress func(done, total int)
+	if !isVerbose && isTerminal(os.Stderr) {
+		progress = progressBar(os.Stderr)
+	}
+	return pushMonitors(client, monitorDiff, c.Bool("dry-run"), c.Int("parallel"), progress)
+}
 
-	for _, m := range monitorDiff.onlyLocal {
-		logger.Log("info", "Create a new rule.")
-		fmt.Println(stringifyMonitor(m, ""))
-		if !isDryRun {
-			_, err := client.CreateMonitor(m)
-			logger.DieIf(err)
+// pushMonitors validates the difference of the monitor rules and pushes it.
+// With isDryRun, the difference is shown instead of pushing it.
+func pushMonitors(client monitorWriter, monitorDiff monitorDiff, isDryRun bool, parallel int, progress func(done, total int)) error {
+	if problems := validateMonitorsPush(monitorDiff); len(problems) > 0 {
+		for _, p := range problems {
+			logger.Log("error", p)
 		}
+		return cli.NewExitError(fmt.Sprintf("%d problem(s) are found in the local rules.", len(problems)), 1)
 	}
-	for _, m := range monitorDiff.onlyRemote {
-		logger.Log("info", "Delete a rule.")
-		fmt.Println(stringifyMonitor(m, ""))
-		if !isDryRun {
-			_, err := client.DeleteMonitor(m.MonitorID())
-			logger.DieIf(err)
+
+	if isDryRun {
+		printMonitorsDiff(os.Stdout, monitorDiff, false)
+		return nil
+	}
+
+	tasks := buildMonitorPushTasks(client, monitorDiff)
+	for _, t := range tasks {
+		logger.Log("info", t.action+".")
+		fmt.Println(stringifyMonitor(t.monitor, ""))
+	}
+	if errs := runMonitorPushTasks(tasks, parallel, progress); len(errs) > 0 {
+		for _, err := range errs {
+			logger.Log("error", err.Error())
 		}
+		return batchError("push", len(errs), len(tasks), "rule(s)")
 	}
-	for _, d := range monitorDiff.diff {
-		logger.Log("info", "Update a rule.")
-		fmt.Println(stringifyMonitor(d.local, ""))
-		if !isDryRun {
-			_, err := client.UpdateMonitor(d.remote.MonitorID(), d.local)
+	return nil
+}
+
+// selectMonitors returns the monitors whose IDs are in ids or whose names match re.
+func selectMonitors(monitors []mackerel.Monitor, ids []string, re *regexp.Regexp) ([]mackerel.Monitor, error) {
+	idSet := map[string]bool{}
+	for _, id := range ids {
+		idSet[id] = true
+	}
+	var selected []mackerel.Monitor
+	for _, m := range monitors {
+		if idSet[m.MonitorID()] || (re != nil && re.MatchString(m.MonitorName())) {
+			selected = append(selected, m)
+			delete(idSet, m.MonitorID())
+		}
+	}
+	for _, id := range ids {
+		if idSet[id] {
+			return nil, fmt.Errorf("monitor not found: %s", id)
+		}
+	}
+	return selected, nil
+}
+
+// monitorMuteField returns the pointer to isMute of the monitor.
+func monitorMuteField(monitor mackerel.Monitor) *bool {
+	switch m := monitor.(type) {
+	case *mackerel.MonitorConnectivity:
+		return &m.IsMute
+	case *mackerel.MonitorHostMetric:
+		return &m.IsMute
+	case *mackerel.MonitorServiceMetric:
+		return &m.IsMute
+	case *mackerel.MonitorExternalHTTP:
+		return &m.IsMute
+	case *mackerel.MonitorExpression:
+		return &m.IsMute
+	case *mackerel.MonitorAnomalyDetection:
+		return &m.IsMute
+	}
+	return nil
+}
+
+// setMonitorMute sets isMute of the monitor and reports whether it is changed.
+func setMonitorMute(monitor mackerel.Monitor, mute bool) bool {
+	isMute := monitorMuteField(monitor)
+	if isMute == nil || *isMute == mute {
+		return false
+	}
+	*isMute = mute
+	return true
+}
+
+func doMonitorsDisable(c *cli.Context) error {
+	return doMonitorsMute(c, true)
+}
+
+func doMonitorsEnable(c *cli.Context) error {
+	return doMonitorsMute(c, false)
+}
+
+func doMonitorsMute(c *cli.Context, mute bool) error {
+	ids := append(c.StringSlice("id"), c.Args()...)
+	var re *regexp.Regexp
+	if nameMatch := c.String("name-match"); nameMatch != "" {
+		var err error
+		if re, err = regexp.Compile(nameMatch); err != nil {
+			return cli.NewExitError(fmt.Sprintf("invalid --name-match: %s", err), 1)
+		}
+	}
+	if len(ids) == 0 && re == nil {
+		cli.ShowCommandHelp(c, c.Command.Name)
+		return cli.NewExitError("either monitor IDs or --name-match is required", 1)
+	}
+
+	client := mackerelclient.NewFromContext(c)
+	monitors, err := client.FindMonitors()
+	logger.DieIf(err)
+	selected, err := selectMonitors(monitors, ids, re)
+	logger.DieIf(err)
+
+	action := "Enable"
+	if mute {
+		action = "Disable"
+	}
+	for _, m := range selected {
+		if !setMonitorMute(m, mute) {
+			logger.Log("info", fmt.Sprintf("Monitor '%s' (%s) is already %sd.", m.MonitorName(), m.MonitorID(), strings.ToLower(action)))
+			continue
+		}
+		logger.Log("info", fmt.Sprintf("%s monitor '%s' (%s).", action, m.MonitorName(), m.MonitorID()))
+		if !c.Bool("dry-run") {
+			_, err := client.UpdateMonitor(m.MonitorID(), m)
 			logger.DieIf(err)
 		}
 	}
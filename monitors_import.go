@@ -0,0 +1,228 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/urfave/cli"
+	"gopkg.in/yaml.v2"
+)
+
+type prometheusRuleFile struct {
+	Groups []struct {
+		Name  string           `yaml:"name"`
+		Rules []prometheusRule `yaml:"rules"`
+	} `yaml:"groups"`
+}
+
+type prometheusRule struct {
+	Alert       string            `yaml:"alert"`
+	Expr        string            `yaml:"expr"`
+	For         string            `yaml:"for"`
+	Labels      map[string]string `yaml:"labels"`
+	Annotations map[string]string `yaml:"annotations"`
+}
+
+// the metrics of node_exporter which have the corresponding host metrics of mackerel-agent
+var prometheusHostMetrics = map[string]string{
+	"node_load1":                     "loadavg1",
+	"node_load5":                     "loadavg5",
+	"node_load15":                    "loadavg15",
+	"node_memory_MemAvailable_bytes": "memory.available",
+	"node_memory_MemFree_bytes":      "memory.free",
+	"node_memory_MemTotal_bytes":     "memory.total",
+	"node_memory_Cached_bytes":       "memory.cached",
+	"node_memory_Buffers_bytes":      "memory.buffers",
+	"node_memory_SwapFree_bytes":     "memory.swap_free",
+	"node_memory_SwapTotal_bytes":    "memory.swap_total",
+}
+
+var (
+	prometheusComparisonPattern = regexp.MustCompile(`^\s*(.+?)\s*(>=|<=|>|<)\s*(-?[0-9]+(?:\.[0-9]+)?)\s*$`)
+	prometheusSelectorPattern   = regexp.MustCompile(`^(?:(avg|max|min|sum)\s*\(\s*)?([a-zA-Z_:][a-zA-Z0-9_:]*)\s*(?:\{([^}]*)\})?\s*(\))?$`)
+	prometheusLabelPattern      = regexp.MustCompile(`^\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*=\s*"([^"]*)"\s*$`)
+)
+
+// convertPrometheusRule converts an alerting rule into a monitor.
+// The rule should compare a metric of node_exporter with a number. The aggregated metric
+// of a role (by the "role" label such as `avg(node_load5{role="service:role"}) > 3`) is converted
+// into an expression monitor, and the others are converted into host metric monitors.
+func convertPrometheusRule(rule prometheusRule) (mackerel.Monitor, error) {
+	m := prometheusComparisonPattern.FindStringSubmatch(rule.Expr)
+	if m == nil {
+		return nil, fmt.Errorf("expr should compare a metric with a number: %s", rule.Expr)
+	}
+	lhs, op := m[1], m[2]
+	threshold, _ := strconv.ParseFloat(m[3], 64)
+	// mackerel supports only '>' and '<'
+	op = strings.TrimSuffix(op, "=")
+
+	s := prometheusSelectorPattern.FindStringSubmatch(lhs)
+	if s == nil || (s[1] == "") != (s[4] == "") {
+		return nil, fmt.Errorf("unsupported expr: %s", rule.Expr)
+	}
+	aggregation, metric := s[1], s[2]
+	hostMetric, ok := prometheusHostMetrics[metric]
+	if !ok {
+		return nil, fmt.Errorf("no corresponding metric of %s", metric)
+	}
+	var role string
+	if s[3] != "" {
+		for _, l := range strings.Split(s[3], ",") {
+			lm := prometheusLabelPattern.FindStringSubmatch(l)
+			if lm == nil {
+				return nil, fmt.Errorf("unsupported label matcher: %s", l)
+			}
+			if lm[1] != "role" {
+				return nil, fmt.Errorf("unsupported label: %s", lm[1])
+			}
+			role = lm[2]
+		}
+	}
+
+	var warning, critical *float64
+	if rule.Labels["severity"] == "warning" {
+		warning = &threshold
+	} else {
+		critical = &threshold
+	}
+	memo := rule.Annotations["summary"]
+	if memo == "" {
+		memo = rule.Annotations["description"]
+	}
+
+	if aggregation != "" {
+		if role == "" {
+			return nil, fmt.Errorf("aggregation requires role label: %s", rule.Expr)
+		}
+		return &mackerel.MonitorExpression{
+			Type:       "expression",
+			Name:       rule.Alert,
+			Memo:       memo,
+			Expression: fmt.Sprintf("%s(role(%s, %s))", aggregation, role, hostMetric),
+			Operator:   op,
+			Warning:    warning,
+			Critical:   critical,
+		}, nil
+	}
+
+	duration := uint64(1)
+	if rule.For != "" {
+		seconds, err := parseWidgetPeriod(rule.For)
+		if err != nil {
+			return nil, fmt.Errorf("invalid for: %s", rule.For)
+		}
+		if minutes := uint64(seconds / 60); minutes > 1 {
+			duration = minutes
+		}
+	}
+	monitor := &mackerel.MonitorHostMetric{
+		Type:     "host",
+		Name:     rule.Alert,
+		Memo:     memo,
+		Metric:   hostMetric,
+		Operator: op,
+		Warning:  warning,
+		Critical: critical,
+		Duration: duration,
+	}
+	if role != "" {
+		monitor.Scopes = []string{role}
+	}
+	return monitor, nil
+}
+
+// convertPrometheusRules converts the alerting rules in a rule file.
+// The rules which cannot be converted are reported as warnings.
+func convertPrometheusRules(data []byte) ([]mackerel.Monitor, []string, error) {
+	var rf prometheusRuleFile
+	if err := yaml.Unmarshal(data, &rf); err != nil {
+		return nil, nil, err
+	}
+	var monitors []mackerel.Monitor
+	var warnings []string
+	for _, g := range rf.Groups {
+		for _, rule := range g.Rules {
+			if rule.Alert == "" {
+				// recording rule
+				continue
+			}
+			m, err := convertPrometheusRule(rule)
+			if err != nil {
+				warnings = append(warnings, fmt.Sprintf("%s/%s: %s", g.Name, rule.Alert, err))
+				continue
+			}
+			monitors = append(monitors, m)
+		}
+	}
+	return monitors, warnings, nil
+}
+
+// appendMonitorRules appends monitors to the monitors file keeping the other fields such as templates.
+func appendMonitorRules(filePath string, monitors []mackerel.Monitor) error {
+	fields := map[string]json.RawMessage{}
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	if err == nil {
+		if err := json.Unmarshal(data, &fields); err != nil {
+			return err
+		}
+	}
+	var rules []json.RawMessage
+	if raw, ok := fields["monitors"]; ok {
+		if err := json.Unmarshal(raw, &rules); err != nil {
+			return err
+		}
+	}
+	for _, m := range monitors {
+		raw, err := json.Marshal(m)
+		if err != nil {
+			return err
+		}
+		rules = append(rules, raw)
+	}
+	if fields["monitors"], err = json.Marshal(rules); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(filePath, []byte(format.JSONMarshalIndent(fields, "", "    ")+"\n"), 0644)
+}
+
+func doMonitorsImport(c *cli.Context) error {
+	if c.NArg() != 1 {
+		cli.ShowCommandHelp(c, "import")
+		return cli.NewExitError("a rule file is required", 1)
+	}
+	if f := c.String("format"); f != "prometheus" {
+		return cli.NewExitError(fmt.Sprintf("format should be 'prometheus': %s", f), 1)
+	}
+	filePath := c.String("file-path")
+	if filePath == "" {
+		filePath = "monitors.json"
+	}
+
+	data, err := ioutil.ReadFile(c.Args().First())
+	logger.DieIf(err)
+	monitors, warnings, err := convertPrometheusRules(data)
+	logger.DieIf(err)
+	for _, w := range warnings {
+		logger.Log("warning", "skip "+w)
+	}
+
+	if c.Bool("dry-run") {
+		format.PrettyPrintJSON(os.Stdout, monitors)
+		return nil
+	}
+	logger.DieIf(appendMonitorRules(filePath, monitors))
+	logger.Log("info", fmt.Sprintf("%d monitor rules are appended to '%s'.", len(monitors), filePath))
+	return nil
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+)
+
+func TestConvertPrometheusRules(t *testing.T) {
+	src := `groups:
+- name: node
+  rules:
+  - record: job:node_load5:avg
+    expr: avg(node_load5)
+  - alert: HighLoad
+    expr: node_load5{role="foo:app"} >= 4
+    for: 10m
+    labels:
+      severity: warning
+    annotations:
+      summary: load average is high
+  - alert: RoleHighLoad
+    expr: avg(node_load5{role="foo:app"}) > 8
+  - alert: HighCPU
+    expr: 100 - avg(rate(node_cpu_seconds_total{mode="idle"}[5m])) * 100 > 90
+`
+	monitors, warnings, err := convertPrometheusRules([]byte(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []mackerel.Monitor{
+		&mackerel.MonitorHostMetric{Type: "host", Name: "HighLoad", Memo: "load average is high", Metric: "loadavg5", Operator: ">", Warning: pfloat64(4), Duration: 10, Scopes: []string{"foo:app"}},
+		&mackerel.MonitorExpression{Type: "expression", Name: "RoleHighLoad", Expression: "avg(role(foo:app, loadavg5))", Operator: ">", Critical: pfloat64(8)},
+	}
+	if !reflect.DeepEqual(expected, monitors) {
+		t.Errorf("monitors should be %s but: %s", format.JSONMarshalIndent(expected, "", " "), format.JSONMarshalIndent(monitors, "", " "))
+	}
+	if len(warnings) != 1 {
+		t.Errorf("a rule should be skipped but: %v", warnings)
+	}
+}
+
+func TestAppendMonitorRules(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-monitors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "monitors.json")
+	src := `{"templates": {"base": {"type": "host", "operator": ">", "duration": 1}}, "monitors": [{"extends": "base", "name": "cpu", "metric": "cpu%", "warning": 80}]}`
+	if err := ioutil.WriteFile(filePath, []byte(src), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	err = appendMonitorRules(filePath, []mackerel.Monitor{&mackerel.MonitorConnectivity{Type: "connectivity", Name: "connectivity"}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	monitors, err := monitorLoadRules(filePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(monitors) != 2 || monitors[0].MonitorType() != "host" || monitors[1].MonitorName() != "connectivity" {
+		t.Errorf("monitor should be appended keeping templates but: %s", format.JSONMarshalIndent(monitors, "", " "))
+	}
+}
@@ -0,0 +1,173 @@
+package main
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// the ranges of the fields of the monitors accepted by Mackerel
+var monitorLintRanges = []struct {
+	field    string
+	min, max uint64
+}{
+	{"Duration", 1, 10},
+	{"MaxCheckAttempts", 1, 10},
+	{"MissingDurationWarning", 10, 10080},
+	{"MissingDurationCritical", 10, 10080},
+}
+
+// the durations of service metric monitors are the numbers of the points, not minutes
+const maxServiceMonitorDuration = 1440
+
+// the minimum interval (in minutes) to notify the alerts again
+const minNotificationInterval = 10
+
+// lintMonitors checks the monitor rules offline and returns the problems found in them,
+// in addition to the problems found by validateMonitorPayload.
+func lintMonitors(monitors []mackerel.Monitor) []string {
+	var problems []string
+	names := map[string]bool{}
+	for _, monitor := range monitors {
+		problems = append(problems, validateMonitorPayload(monitor)...)
+		addf := func(f string, args ...interface{}) {
+			problems = append(problems, fmt.Sprintf("Monitor '%s': ", monitor.MonitorName())+fmt.Sprintf(f, args...))
+		}
+		name := monitor.MonitorName()
+		if name != "" && names[name] {
+			addf("name is not unique")
+		}
+		names[name] = true
+
+		v := reflect.ValueOf(monitor).Elem()
+		for _, r := range monitorLintRanges {
+			min, max := r.min, r.max
+			if r.field == "Duration" && monitor.MonitorType() == "service" {
+				max = maxServiceMonitorDuration
+			}
+			// zero is the default of the omitted fields
+			if f := v.FieldByName(r.field); f.IsValid() && f.Uint() != 0 && (f.Uint() < min || f.Uint() > max) {
+				addf("%s should be between %d and %d: %d", lowerFirst(r.field), min, max, f.Uint())
+			}
+		}
+		if f := v.FieldByName("NotificationInterval"); f.IsValid() && f.Uint() != 0 && f.Uint() < minNotificationInterval {
+			addf("notificationInterval should be 0 or at least %d: %d", minNotificationInterval, f.Uint())
+		}
+		if m, ok := monitor.(*mackerel.MonitorExternalHTTP); ok {
+			if d := m.ResponseTimeDuration; d != nil && *d > 10 {
+				addf("responseTimeDuration should be between 1 and 10: %d", *d)
+			}
+			for _, h := range m.Headers {
+				if h.Name == "" || strings.ContainsAny(h.Name, " :\t") {
+					addf("header name is invalid: %q", h.Name)
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func lowerFirst(s string) string {
+	if s == "" {
+		return s
+	}
+	return strings.ToLower(s[:1]) + s[1:]
+}
+
+// checkMonitorRefs checks that the services and the roles referred by the monitors exist in the org.
+func checkMonitorRefs(monitors []mackerel.Monitor, services []*mackerel.Service) []string {
+	roles := map[string]bool{}
+	for _, s := range services {
+		roles[s.Name] = true
+		for _, r := range s.Roles {
+			roles[s.Name+":"+r] = true
+		}
+	}
+	var problems []string
+	for _, monitor := range monitors {
+		for _, scope := range monitorScopes(monitor) {
+			// the scopes may be excluded with '!', and written as "service: role"
+			scope = strings.Replace(strings.TrimPrefix(scope, "!"), ": ", ":", 1)
+			if scope == "" || roles[scope] {
+				continue
+			}
+			kind := "service"
+			if strings.Contains(scope, ":") {
+				kind = "role"
+			}
+			problems = append(problems, fmt.Sprintf("Monitor '%s': %s not found: %s", monitor.MonitorName(), kind, scope))
+		}
+	}
+	return problems
+}
+
+// checkMonitorChannels checks that the channels of the notification groups binding the monitors exist in the org.
+// The monitors are matched to the notification groups by the IDs, so the monitors without IDs are not checked.
+func checkMonitorChannels(monitors []mackerel.Monitor, groups []*mackerel.NotificationGroup, channels []*mackerel.Channel) []string {
+	channelIDs := map[string]bool{}
+	for _, ch := range channels {
+		channelIDs[ch.ID] = true
+	}
+	byID := map[string]mackerel.Monitor{}
+	for _, monitor := range monitors {
+		if id := monitor.MonitorID(); id != "" {
+			byID[id] = monitor
+		}
+	}
+	var problems []string
+	for _, g := range groups {
+		for _, gm := range g.Monitors {
+			monitor, ok := byID[gm.ID]
+			if !ok {
+				continue
+			}
+			for _, id := range g.ChildChannelIDs {
+				if !channelIDs[id] {
+					problems = append(problems, fmt.Sprintf("Monitor '%s': channel not found: %s (notification group '%s')", monitor.MonitorName(), id, g.Name))
+				}
+			}
+		}
+	}
+	return problems
+}
+
+func doMonitorsLint(c *cli.Context) error {
+	if c.NArg() > 1 {
+		cli.ShowCommandHelp(c, "lint")
+		return cli.NewExitError("too many arguments", 1)
+	}
+	filePath := c.Args().First()
+	if filePath == "" {
+		filePath = c.String("file-path")
+	}
+	monitors, err := monitorLoadRules(filePath)
+	if err != nil {
+		return cli.NewExitError(fmt.Sprintf("failed to load the monitor rules: %s", err), 1)
+	}
+
+	problems := lintMonitors(monitors)
+	if c.Bool("check-refs") {
+		client := mackerelclient.NewFromContext(c)
+		services, err := client.FindServices()
+		logger.DieIf(err)
+		problems = append(problems, checkMonitorRefs(monitors, services)...)
+		groups, err := client.FindNotificationGroups()
+		logger.DieIf(err)
+		channels, err := client.FindChannels()
+		logger.DieIf(err)
+		problems = append(problems, checkMonitorChannels(monitors, groups, channels)...)
+	}
+	for _, p := range problems {
+		logger.Log("error", p)
+	}
+	if len(problems) > 0 {
+		return cli.NewExitError(fmt.Sprintf("%d problem(s) are found in %d monitor rule(s).", len(problems), len(monitors)), 1)
+	}
+	logger.Log("info", fmt.Sprintf("no problems are found in %d monitor rule(s).", len(monitors)))
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestLintMonitors(t *testing.T) {
+	monitors := []mackerel.Monitor{
+		&mackerel.MonitorHostMetric{Name: "cpu", Type: "host", Metric: "cpu%", Operator: ">", Warning: pfloat64(80), Critical: pfloat64(90), Duration: 15, MaxCheckAttempts: 3},
+		&mackerel.MonitorServiceMetric{Name: "latency", Type: "service", Service: "foo", Metric: "latency", Operator: ">", Warning: pfloat64(10), Duration: 60, MissingDurationWarning: 5},
+		&mackerel.MonitorExternalHTTP{Name: "cpu", Type: "external", URL: "https://example.com", NotificationInterval: 5, ResponseTimeDuration: puint64(30), ResponseTimeWarning: pfloat64(1000),
+			Headers: []mackerel.HeaderField{{Name: "X-Foo: bar", Value: "baz"}}},
+		&mackerel.MonitorConnectivity{Type: "connectivity", NotificationInterval: 60},
+	}
+	expect := []string{
+		"Monitor 'cpu': duration should be between 1 and 10: 15",
+		"Monitor 'latency': missingDurationWarning should be between 10 and 10080: 5",
+		"Monitor 'cpu': name is not unique",
+		"Monitor 'cpu': notificationInterval should be 0 or at least 10: 5",
+		"Monitor 'cpu': responseTimeDuration should be between 1 and 10: 30",
+		`Monitor 'cpu': header name is invalid: "X-Foo: bar"`,
+	}
+	if problems := lintMonitors(monitors); !reflect.DeepEqual(problems, expect) {
+		t.Errorf("problems should be %q but: %q", expect, problems)
+	}
+}
+
+func TestCheckMonitorRefs(t *testing.T) {
+	services := []*mackerel.Service{{Name: "foo", Roles: []string{"web", "db"}}}
+	monitors := []mackerel.Monitor{
+		&mackerel.MonitorHostMetric{Name: "cpu", Scopes: []string{"foo", "foo: web"}, ExcludeScopes: []string{"foo:batch"}},
+		&mackerel.MonitorServiceMetric{Name: "latency", Service: "bar"},
+		&mackerel.MonitorExternalHTTP{Name: "example"},
+		&mackerel.MonitorAnomalyDetection{Name: "anomaly", Scopes: []string{"foo:db"}},
+	}
+	expect := []string{
+		"Monitor 'cpu': role not found: foo:batch",
+		"Monitor 'latency': service not found: bar",
+	}
+	if problems := checkMonitorRefs(monitors, services); !reflect.DeepEqual(problems, expect) {
+		t.Errorf("problems should be %q but: %q", expect, problems)
+	}
+}
+
+func TestCheckMonitorChannels(t *testing.T) {
+	monitors := []mackerel.Monitor{
+		&mackerel.MonitorHostMetric{ID: "mon1", Name: "cpu"},
+		&mackerel.MonitorConnectivity{ID: "mon2", Name: "connectivity"},
+		&mackerel.MonitorExternalHTTP{Name: "example"},
+	}
+	groups := []*mackerel.NotificationGroup{
+		{Name: "ops", ChildChannelIDs: []string{"ch1", "ch9"}, Monitors: []*mackerel.NotificationGroupMonitor{{ID: "mon1"}}},
+		{Name: "dev", ChildChannelIDs: []string{"ch8"}, Monitors: []*mackerel.NotificationGroupMonitor{{ID: "mon3"}}},
+	}
+	channels := []*mackerel.Channel{{ID: "ch1"}}
+	expect := []string{
+		"Monitor 'cpu': channel not found: ch9 (notification group 'ops')",
+	}
+	if problems := checkMonitorChannels(monitors, groups, channels); !reflect.DeepEqual(problems, expect) {
+		t.Errorf("problems should be %q but: %q", expect, problems)
+	}
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// monitorWriter is the subset of the client to push monitors.
+type monitorWriter interface {
+	CreateMonitor(param mackerel.Monitor) (mackerel.Monitor, error)
+	UpdateMonitor(monitorID string, param mackerel.Monitor) (mackerel.Monitor, error)
+	DeleteMonitor(monitorID string) (mackerel.Monitor, error)
+}
+
+type monitorPushTask struct {
+	action  string
+	monitor mackerel.Monitor
+	do      func() error
+}
+
+func (t *monitorPushTask) String() string {
+	return fmt.Sprintf("%s '%s'", t.action, t.monitor.MonitorName())
+}
+
+func buildMonitorPushTasks(client monitorWriter, monitorDiff monitorDiff) []*monitorPushTask {
+	var tasks []*monitorPushTask
+	for _, m := range monitorDiff.onlyLocal {
+		m := m
+		tasks = append(tasks, &monitorPushTask{"Create a new rule", m, func() error {
+			_, err := client.CreateMonitor(m)
+			return err
+		}})
+	}
+	for _, m := range monitorDiff.onlyRemote {
+		m := m
+		tasks = append(tasks, &monitorPushTask{"Delete a rule", m, func() error {
+			_, err := client.DeleteMonitor(m.MonitorID())
+			return err
+		}})
+	}
+	for _, d := range monitorDiff.diff {
+		d := d
+		tasks = append(tasks, &monitorPushTask{"Update a rule", d.local, func() error {
+			_, err := client.UpdateMonitor(d.remote.MonitorID(), d.local)
+			return err
+		}})
+	}
+	return tasks
+}
+
+// runMonitorPushTasks runs the tasks with the workers and returns the errors of the failed tasks.
+// progress is called each time a task is finished.
+func runMonitorPushTasks(tasks []*monitorPushTask, parallel int, progress func(done, total int)) []error {
+	if parallel < 1 {
+		parallel = 1
+	}
+	ch := make(chan *monitorPushTask)
+	var (
+		wg   sync.WaitGroup
+		mu   sync.Mutex
+		errs []error
+		done int
+	)
+	for i := 0; i < parallel; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for t := range ch {
+				err := t.do()
+				mu.Lock()
+				if err != nil {
+					errs = append(errs, fmt.Errorf("%s: %s", t, err))
+				}
+				done++
+				if progress != nil {
+					progress(done, len(tasks))
+				}
+				mu.Unlock()
+			}
+		}()
+	}
+	for _, t := range tasks {
+		ch <- t
+	}
+	close(ch)
+	wg.Wait()
+	return errs
+}
+
+// progressBar returns the function to show the progress of the tasks on w.
+func progressBar(w io.Writer) func(done, total int) {
+	const width = 40
+	return func(done, total int) {
+		n := width * done / total
+		fmt.Fprintf(w, "\r[%s%s] %d/%d", strings.Repeat("=", n), strings.Repeat(" ", width-n), done, total)
+		if done == total {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func isTerminal(f *os.File) bool {
+	fi, err := f.Stat()
+	return err == nil && fi.Mode()&os.ModeCharDevice != 0
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"sync"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type fakeMonitorWriter struct {
+	mu    sync.Mutex
+	calls []string
+}
+
+func (w *fakeMonitorWriter) call(name string) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if name == "delete:broken" {
+		return &mackerel.APIError{StatusCode: 404, Message: "Not Found"}
+	}
+	w.calls = append(w.calls, name)
+	return nil
+}
+
+func (w *fakeMonitorWriter) CreateMonitor(m mackerel.Monitor) (mackerel.Monitor, error) {
+	return m, w.call("create:" + m.MonitorName())
+}
+
+func (w *fakeMonitorWriter) UpdateMonitor(id string, m mackerel.Monitor) (mackerel.Monitor, error) {
+	return m, w.call("update:" + id)
+}
+
+func (w *fakeMonitorWriter) DeleteMonitor(id string) (mackerel.Monitor, error) {
+	return nil, w.call("delete:" + id)
+}
+
+func TestRunMonitorPushTasks(t *testing.T) {
+	md := monitorDiff{
+		onlyRemote: []mackerel.Monitor{
+			&mackerel.MonitorConnectivity{ID: "broken", Name: "broken"},
+		},
+		diff: []*monitorDiffPair{
+			{remote: &mackerel.MonitorHostMetric{ID: "u1", Name: "u1"}, local: &mackerel.MonitorHostMetric{Name: "u1"}},
+		},
+	}
+	for i := 0; i < 10; i++ {
+		md.onlyLocal = append(md.onlyLocal, &mackerel.MonitorHostMetric{Name: fmt.Sprintf("c%d", i)})
+	}
+	w := &fakeMonitorWriter{}
+
+	var buf bytes.Buffer
+	errs := runMonitorPushTasks(buildMonitorPushTasks(w, md), 4, progressBar(&buf))
+
+	if len(errs) != 1 || errs[0].Error() != "Delete a rule 'broken': API request failed: Not Found" {
+		t.Errorf("errors should be only the failed deletion but: %v", errs)
+	}
+	sort.Strings(w.calls)
+	if len(w.calls) != 11 || w.calls[3] != "create:c3" || w.calls[10] != "update:u1" {
+		t.Errorf("all the tasks should be run but: %v", w.calls)
+	}
+	if !bytes.HasSuffix(buf.Bytes(), []byte("] 12/12\n")) {
+		t.Errorf("progress should be finished but: %q", buf.String())
+	}
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// monitorThreshold is the condition of a host or service metric monitor.
+type monitorThreshold struct {
+	operator         string
+	warning          *float64
+	critical         *float64
+	duration         uint64
+	maxCheckAttempts uint64
+}
+
+// monitorTestEvent is a change of the alert status while evaluating a monitor.
+type monitorTestEvent struct {
+	time   int64
+	status string
+	value  float64
+}
+
+func (th monitorThreshold) exceeds(value float64, threshold *float64) bool {
+	if threshold == nil {
+		return false
+	}
+	if th.operator == "<" {
+		return value < *threshold
+	}
+	return value > *threshold
+}
+
+// evaluateMonitorThreshold replays the metric values and returns the changes of the alert status.
+// The values are averaged over the duration (in points) and the status changes after
+// the same status continues maxCheckAttempts times.
+func evaluateMonitorThreshold(th monitorThreshold, values []mackerel.MetricValue) []*monitorTestEvent {
+	duration := int(th.duration)
+	if duration < 1 {
+		duration = 1
+	}
+	attempts := int(th.maxCheckAttempts)
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var events []*monitorTestEvent
+	current, pending, count := "OK", "OK", 0
+	var window []float64
+	for _, v := range values {
+		f, ok := metricValueFloat(v.Value)
+		if !ok {
+			continue
+		}
+		window = append(window, f)
+		if len(window) > duration {
+			window = window[1:]
+		}
+		if len(window) < duration {
+			continue
+		}
+		var sum float64
+		for _, w := range window {
+			sum += w
+		}
+		avg := sum / float64(duration)
+
+		status := "OK"
+		if th.exceeds(avg, th.critical) {
+			status = "CRITICAL"
+		} else if th.exceeds(avg, th.warning) {
+			status = "WARNING"
+		}
+		if status == pending {
+			count++
+		} else {
+			pending, count = status, 1
+		}
+		if pending != current && (count >= attempts || pending == "OK") {
+			current = pending
+			events = append(events, &monitorTestEvent{time: v.Time, status: current, value: avg})
+		}
+	}
+	return events
+}
+
+func metricValueFloat(v interface{}) (float64, bool) {
+	switch x := v.(type) {
+	case float64:
+		return x, true
+	case json.Number:
+		f, err := x.Float64()
+		return f, err == nil
+	}
+	return 0, false
+}
+
+func printMonitorTestEvents(w io.Writer, events []*monitorTestEvent) {
+	alerts := 0
+	for _, e := range events {
+		fmt.Fprintf(w, "%s\t%s\t%.3f\n", time.Unix(e.time, 0).Format(time.RFC3339), e.status, e.value)
+		if e.status != "OK" {
+			alerts++
+		}
+	}
+	fmt.Fprintf(w, "The monitor would have changed to WARNING or CRITICAL %d time(s).\n", alerts)
+}
+
+// loadMonitorForTest loads a monitor from a file which has a monitor or the monitors of `mkr monitors pull`.
+func loadMonitorForTest(filePath, name string) (mackerel.Monitor, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, err
+	}
+	if _, ok := fields["monitors"]; !ok {
+		return decodeMonitor(data)
+	}
+
+	f, err := os.Open(filePath)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	monitors, err := decodeMonitors(f)
+	if err != nil {
+		return nil, err
+	}
+	var found []mackerel.Monitor
+	for _, m := range monitors {
+		if name == "" || m.MonitorName() == name {
+			found = append(found, m)
+		}
+	}
+	if len(found) != 1 {
+		return nil, fmt.Errorf("%d monitors are found in %s. specify one monitor by --name", len(found), filePath)
+	}
+	return found[0], nil
+}
+
+func doMonitorsTest(c *cli.Context) error {
+	filePath := c.String("file-path")
+	service := c.String("service")
+	hours := c.Int("hours")
+	if filePath == "" || hours <= 0 {
+		cli.ShowCommandHelp(c, "test")
+		return cli.NewExitError("--file-path and positive --hours are required", 1)
+	}
+
+	monitor, err := loadMonitorForTest(filePath, c.String("name"))
+	logger.DieIf(err)
+
+	to := time.Now().Unix()
+	from := to - int64(hours)*60*60
+	client := mackerelclient.NewFromContext(c)
+	hostID, err := mackerelclient.HostIDFromContext(c, client, "host")
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+
+	var th monitorThreshold
+	var values []mackerel.MetricValue
+	switch m := monitor.(type) {
+	case *mackerel.MonitorHostMetric:
+		if hostID == "" {
+			return cli.NewExitError("--host is required for host metric monitors", 1)
+		}
+		th = monitorThreshold{m.Operator, m.Warning, m.Critical, m.Duration, m.MaxCheckAttempts}
+		values, err = client.FetchHostMetricValues(hostID, m.Metric, from, to)
+	case *mackerel.MonitorServiceMetric:
+		if service == "" {
+			service = m.Service
+		}
+		if service == "" {
+			return cli.NewExitError("--service is required for service metric monitors", 1)
+		}
+		th = monitorThreshold{m.Operator, m.Warning, m.Critical, m.Duration, m.MaxCheckAttempts}
+		values, err = client.FetchServiceMetricValues(service, m.Metric, from, to)
+	default:
+		return cli.NewExitError(fmt.Sprintf("monitors test supports host and service metric monitors only: %s", monitor.MonitorType()), 1)
+	}
+	logger.DieIf(err)
+
+	logger.Log("info", fmt.Sprintf("Evaluate monitor '%s' with %d metric values of the last %d hours.", monitor.MonitorName(), len(values), hours))
+	printMonitorTestEvents(os.Stdout, evaluateMonitorThreshold(th, values))
+	return nil
+}
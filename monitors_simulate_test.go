@@ -0,0 +1,60 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestEvaluateMonitorThreshold(t *testing.T) {
+	var values []mackerel.MetricValue
+	for i, v := range []float64{10, 85, 90, 95, 99, 98, 50, 40, 30} {
+		values = append(values, mackerel.MetricValue{Time: int64(60 * i), Value: v})
+	}
+
+	testCases := []struct {
+		name     string
+		th       monitorThreshold
+		expected []*monitorTestEvent
+	}{
+		{
+			name: "each point",
+			th:   monitorThreshold{operator: ">", warning: pfloat64(80), critical: pfloat64(95), duration: 1},
+			expected: []*monitorTestEvent{
+				{time: 60, status: "WARNING", value: 85},
+				{time: 240, status: "CRITICAL", value: 99},
+				{time: 360, status: "OK", value: 50},
+			},
+		},
+		{
+			name: "with duration and max check attempts",
+			th:   monitorThreshold{operator: ">", warning: pfloat64(80), critical: pfloat64(95), duration: 2, maxCheckAttempts: 2},
+			expected: []*monitorTestEvent{
+				{time: 180, status: "WARNING", value: 92.5},
+				{time: 300, status: "CRITICAL", value: 98.5},
+				{time: 360, status: "OK", value: 74},
+			},
+		},
+		{
+			name: "less than",
+			th:   monitorThreshold{operator: "<", critical: pfloat64(35), duration: 1},
+			expected: []*monitorTestEvent{
+				{time: 0, status: "CRITICAL", value: 10},
+				{time: 60, status: "OK", value: 85},
+				{time: 480, status: "CRITICAL", value: 30},
+			},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			events := evaluateMonitorThreshold(tc.th, values)
+			if !reflect.DeepEqual(tc.expected, events) {
+				for _, e := range events {
+					t.Logf("%+v", e)
+				}
+				t.Errorf("unexpected events")
+			}
+		})
+	}
+}
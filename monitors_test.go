@@ -1,10 +1,16 @@
 package main
 
 import (
+	"bytes"
 	"io/ioutil"
+	"os"
+	"reflect"
+	"regexp"
+	"strings"
 	"testing"
 
 	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
 )
 
 func TestIsSameMonitor(t *testing.T) {
@@ -202,3 +208,249 @@ func TestDiffMonitorsWithScopes(t *testing.T) {
 		t.Errorf("expected:\n%s\n, output:\n%s\n", expected, diff)
 	}
 }
+
+func TestMonitorSaveAndLoadRulesSplit(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-monitors")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	monitors := []mackerel.Monitor{
+		&mackerel.MonitorConnectivity{ID: "12345", Name: "Connectivity", Type: "connectivity"},
+		&mackerel.MonitorHostMetric{ID: "23456", Name: "CPU %", Type: "host", Metric: "cpu%", Operator: ">", Warning: pfloat64(80), Duration: 1},
+		&mackerel.MonitorHostMetric{ID: "34567", Name: "CPU %", Type: "host", Metric: "cpu%", Operator: ">", Critical: pfloat64(95), Duration: 1},
+	}
+	if err := monitorSaveRulesSplit(monitors, dir, "name"); err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	files, _ := ioutil.ReadDir(dir)
+	for _, f := range files {
+		names = append(names, f.Name())
+	}
+	expected := []string{"34567.json", "connectivity.json", "cpu.json"}
+	if !reflect.DeepEqual(expected, names) {
+		t.Errorf("files should be %v but: %v", expected, names)
+	}
+
+	loaded, err := monitorLoadRulesSplit(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual([]mackerel.Monitor{monitors[2], monitors[0], monitors[1]}, loaded) {
+		t.Errorf("loaded monitors should be same as saved ones but: %v", loaded)
+	}
+}
+
+func TestMonitorDiffResult(t *testing.T) {
+	remote := &mackerel.MonitorHostMetric{ID: "12345", Name: "CPU %", Type: "host", Metric: "cpu%", Operator: ">", Warning: pfloat64(80), Duration: 1, Scopes: []string{"foo"}}
+	local := &mackerel.MonitorHostMetric{Name: "CPU %", Type: "host", Metric: "cpu%", Operator: ">", Warning: pfloat64(90), Duration: 1, Scopes: []string{"foo", "bar"}}
+	onlyLocal := &mackerel.MonitorConnectivity{Name: "connectivity", Type: "connectivity"}
+
+	md := monitorDiff{
+		onlyLocal: []mackerel.Monitor{onlyLocal},
+		diff:      []*monitorDiffPair{{remote: remote, local: local}},
+	}
+
+	r := md.result(false)
+	if !reflect.DeepEqual([]mackerel.Monitor{onlyLocal}, r.Added) || len(r.Removed) != 0 {
+		t.Errorf("unexpected added or removed monitors: %v, %v", r.Added, r.Removed)
+	}
+	expected := []*monitorChange{{
+		ID:   "12345",
+		Name: "CPU %",
+		Type: "host",
+		Changes: []*monitorFieldChange{
+			{Field: "scopes", From: []interface{}{"foo"}, To: []interface{}{"foo", "bar"}},
+			{Field: "warning", From: float64(80), To: float64(90)},
+		},
+	}}
+	if !reflect.DeepEqual(expected, r.Changed) {
+		t.Errorf("changes should be %s but: %s", format.JSONMarshalIndent(expected, "", " "), format.JSONMarshalIndent(r.Changed, "", " "))
+	}
+
+	r = md.result(true)
+	if len(r.Added) != 0 || !reflect.DeepEqual([]mackerel.Monitor{onlyLocal}, r.Removed) {
+		t.Errorf("unexpected added or removed monitors in reverse: %v, %v", r.Added, r.Removed)
+	}
+	if c := r.Changed[0].Changes[1]; c.From != float64(90) || c.To != float64(80) {
+		t.Errorf("the change should be reversed but: %v", c)
+	}
+}
+
+func TestDecodeMonitorsWithTemplates(t *testing.T) {
+	src := `{
+  "templates": {
+    "host-base": {"type": "host", "operator": ">", "duration": 3, "maxCheckAttempts": 3},
+    "cpu": {"extends": "host-base", "metric": "cpu%", "warning": 80, "critical": 90}
+  },
+  "monitors": [
+    {"extends": "cpu", "name": "cpu app", "scopes": ["foo:app"]},
+    {"extends": "cpu", "name": "cpu db", "scopes": ["foo:db"], "critical": null},
+    {"type": "connectivity", "name": "connectivity"}
+  ]
+}`
+	ms, err := decodeMonitors(strings.NewReader(src))
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := []mackerel.Monitor{
+		&mackerel.MonitorHostMetric{Name: "cpu app", Type: "host", Metric: "cpu%", Operator: ">", Warning: pfloat64(80), Critical: pfloat64(90), Duration: 3, MaxCheckAttempts: 3, Scopes: []string{"foo:app"}},
+		&mackerel.MonitorHostMetric{Name: "cpu db", Type: "host", Metric: "cpu%", Operator: ">", Warning: pfloat64(80), Duration: 3, MaxCheckAttempts: 3, Scopes: []string{"foo:db"}},
+		&mackerel.MonitorConnectivity{Name: "connectivity", Type: "connectivity"},
+	}
+	if !reflect.DeepEqual(expected, ms) {
+		t.Errorf("monitors should be %s but: %s", format.JSONMarshalIndent(expected, "", " "), format.JSONMarshalIndent(ms, "", " "))
+	}
+}
+
+func TestDecodeMonitorsWithTemplates_Error(t *testing.T) {
+	testCases := []struct {
+		src string
+		err string
+	}{
+		{
+			src: `{"monitors": [{"extends": "unknown", "name": "cpu"}]}`,
+			err: "monitors[0]: template not found: unknown",
+		},
+		{
+			src: `{"templates": {"a": {"extends": "b"}, "b": {"extends": "a"}}, "monitors": [{"extends": "a"}]}`,
+			err: "monitors[0]: circular extends of template: a -> b -> a",
+		},
+	}
+	for _, tc := range testCases {
+		_, err := decodeMonitors(strings.NewReader(tc.src))
+		if err == nil || err.Error() != tc.err {
+			t.Errorf("error should be %q but: %v", tc.err, err)
+		}
+	}
+}
+
+func TestSelectMonitorsAndSetMute(t *testing.T) {
+	monitors := []mackerel.Monitor{
+		&mackerel.MonitorConnectivity{ID: "1", Name: "connectivity", Type: "connectivity"},
+		&mackerel.MonitorHostMetric{ID: "2", Name: "cpu app", Type: "host"},
+		&mackerel.MonitorHostMetric{ID: "3", Name: "cpu db", Type: "host", IsMute: true},
+		&mackerel.MonitorExternalHTTP{ID: "4", Name: "example", Type: "external"},
+	}
+
+	selected, err := selectMonitors(monitors, []string{"1"}, regexp.MustCompile("^cpu "))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(monitors[:3], selected) {
+		t.Errorf("selected monitors should be %v but: %v", monitors[:3], selected)
+	}
+
+	if _, err := selectMonitors(monitors, []string{"5"}, nil); err == nil || err.Error() != "monitor not found: 5" {
+		t.Errorf("unknown ID should be an error but: %v", err)
+	}
+
+	var changed []bool
+	for _, m := range selected {
+		changed = append(changed, setMonitorMute(m, true))
+	}
+	if !reflect.DeepEqual([]bool{true, true, false}, changed) {
+		t.Errorf("changed should be [true true false] but: %v", changed)
+	}
+	if !monitors[1].(*mackerel.MonitorHostMetric).IsMute {
+		t.Errorf("monitor should be muted")
+	}
+}
+
+func TestFilterAndPrintMonitors(t *testing.T) {
+	monitors := []mackerel.Monitor{
+		&mackerel.MonitorConnectivity{ID: "1", Name: "connectivity", Type: "connectivity", Scopes: []string{"foo"}, ExcludeScopes: []string{"foo:db"}},
+		&mackerel.MonitorHostMetric{ID: "2", Name: "cpu app", Type: "host", Scopes: []string{"foo:app"}, IsMute: true},
+		&mackerel.MonitorHostMetric{ID: "3", Name: "memory app", Type: "host"},
+		&mackerel.MonitorServiceMetric{ID: "4", Name: "cpu service", Type: "service", Service: "foo"},
+	}
+
+	filtered := filterMonitors(monitors, &monitorFilter{typ: "host", nameMatch: regexp.MustCompile("^cpu")})
+	if !reflect.DeepEqual(monitors[1:2], filtered) {
+		t.Errorf("filtered monitors should be %v but: %v", monitors[1:2], filtered)
+	}
+
+	var buf bytes.Buffer
+	if err := printMonitors(&buf, monitors, "table"); err != nil {
+		t.Fatal(err)
+	}
+	expected := `ID  TYPE          NAME          SCOPES       MUTE
+1   connectivity  connectivity  foo,!foo:db  false
+2   host          cpu app       foo:app      true
+3   host          memory app                 false
+4   service       cpu service   foo          false
+`
+	if buf.String() != expected {
+		t.Errorf("output should be:\n%s\nbut:\n%s", expected, buf.String())
+	}
+
+	buf.Reset()
+	if err := printMonitors(&buf, monitors, "go-template={{range .}}{{.id}} {{end}}"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "1 2 3 4 " {
+		t.Errorf("output should be rendered by the template but: %s", buf.String())
+	}
+
+	if err := printMonitors(&buf, monitors, "csv"); err == nil {
+		t.Errorf("unknown output should be an error")
+	}
+}
+
+func TestMonitorFilter(t *testing.T) {
+	monitors := []mackerel.Monitor{
+		&mackerel.MonitorConnectivity{ID: "1", Type: "connectivity", Scopes: []string{"foo"}},
+		&mackerel.MonitorHostMetric{ID: "2", Type: "host", Scopes: []string{"foo:app"}},
+		&mackerel.MonitorHostMetric{ID: "3", Type: "host", Scopes: []string{"bar:app"}},
+		&mackerel.MonitorServiceMetric{ID: "4", Type: "service", Service: "foo"},
+		&mackerel.MonitorExpression{ID: "5", Type: "expression", Expression: "avg(role(foo:app, loadavg5))"},
+		&mackerel.MonitorExternalHTTP{ID: "6", Type: "external"},
+	}
+	testCases := []struct {
+		filter monitorFilter
+		ids    []string
+	}{
+		{monitorFilter{}, []string{"1", "2", "3", "4", "5", "6"}},
+		{monitorFilter{service: "foo"}, []string{"1", "2", "4", "5"}},
+		{monitorFilter{service: "foo", typ: "host"}, []string{"2"}},
+		{monitorFilter{scope: "foo:app"}, []string{"2"}},
+		{monitorFilter{scope: "fo"}, nil},
+	}
+	for _, tc := range testCases {
+		var ids []string
+		for _, m := range filterMonitors(monitors, &tc.filter) {
+			ids = append(ids, m.MonitorID())
+		}
+		if !reflect.DeepEqual(tc.ids, ids) {
+			t.Errorf("filter %+v should select %v but: %v", tc.filter, tc.ids, ids)
+		}
+	}
+}
+
+func TestDiffFilteredMonitors(t *testing.T) {
+	remote := []mackerel.Monitor{
+		&mackerel.MonitorHostMetric{ID: "1", Name: "foo cpu", Type: "host", Metric: "cpu%", Scopes: []string{"foo:app"}},
+		&mackerel.MonitorHostMetric{ID: "2", Name: "bar cpu", Type: "host", Metric: "cpu%", Scopes: []string{"bar:app"}},
+	}
+	local := []mackerel.Monitor{
+		&mackerel.MonitorHostMetric{ID: "1", Name: "foo cpu", Type: "host", Metric: "cpu%", Scopes: []string{"foo:app"}, Warning: pfloat64(80)},
+		&mackerel.MonitorHostMetric{Name: "bar memory", Type: "host", Metric: "memory%", Scopes: []string{"bar:app"}},
+		&mackerel.MonitorHostMetric{Name: "foo memory", Type: "host", Metric: "memory%", Scopes: []string{"foo:app"}},
+	}
+	d, err := diffFilteredMonitors(remote, local, &monitorFilter{service: "foo"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(d.onlyRemote) != 0 {
+		t.Errorf("no remote rules should be deleted but: %v", d.onlyRemote)
+	}
+	if len(d.onlyLocal) != 1 || d.onlyLocal[0].MonitorName() != "foo memory" {
+		t.Errorf("only the local rule of the service should be created but: %v", d.onlyLocal)
+	}
+	if len(d.diff) != 1 || d.diff[0].remote.MonitorID() != "1" {
+		t.Errorf("the rule of the service should be updated but: %v", d.diff)
+	}
+}
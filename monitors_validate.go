@@ -0,0 +1,147 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// validateMonitorPayload checks a monitor rule before it is sent to Mackerel
+// and returns the problems found in it.
+func validateMonitorPayload(monitor mackerel.Monitor) []string {
+	var problems []string
+	addf := func(f string, args ...interface{}) {
+		problems = append(problems, fmt.Sprintf("Monitor '%s': ", monitor.MonitorName())+fmt.Sprintf(f, args...))
+	}
+	if monitor.MonitorName() == "" && monitor.MonitorType() != "connectivity" {
+		addf("name is required")
+	}
+
+	switch m := monitor.(type) {
+	case *mackerel.MonitorConnectivity:
+	case *mackerel.MonitorHostMetric:
+		if m.Metric == "" {
+			addf("metric is required")
+		}
+		if m.Duration == 0 {
+			addf("duration is required")
+		}
+		for _, p := range validateMonitorThresholds(m.Operator, m.Warning, m.Critical) {
+			addf(p)
+		}
+	case *mackerel.MonitorServiceMetric:
+		if m.Service == "" {
+			addf("service is required")
+		}
+		if m.Metric == "" {
+			addf("metric is required")
+		}
+		if m.Duration == 0 {
+			addf("duration is required")
+		}
+		for _, p := range validateMonitorThresholds(m.Operator, m.Warning, m.Critical) {
+			addf(p)
+		}
+	case *mackerel.MonitorExternalHTTP:
+		if u, err := url.Parse(m.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			addf("url should be an absolute http(s) URL: %q", m.URL)
+		}
+		switch m.Method {
+		case "", "GET", "POST", "PUT", "DELETE":
+		default:
+			addf("method should be GET, POST, PUT or DELETE: %q", m.Method)
+		}
+		if m.ResponseTimeWarning != nil || m.ResponseTimeCritical != nil {
+			if m.ResponseTimeDuration == nil || *m.ResponseTimeDuration == 0 {
+				addf("responseTimeDuration is required with response time thresholds")
+			}
+			for _, p := range validateMonitorThresholds(">", m.ResponseTimeWarning, m.ResponseTimeCritical) {
+				addf("response time " + p)
+			}
+		}
+		if m.CertificationExpirationWarning != nil && m.CertificationExpirationCritical != nil &&
+			*m.CertificationExpirationWarning < *m.CertificationExpirationCritical {
+			addf("certificationExpirationWarning should not be less than certificationExpirationCritical")
+		}
+	case *mackerel.MonitorExpression:
+		if m.Expression == "" {
+			addf("expression is required")
+		} else if err := checkExpressionSyntax(m.Expression); err != nil {
+			addf("invalid expression: %s", err)
+		}
+		for _, p := range validateMonitorThresholds(m.Operator, m.Warning, m.Critical) {
+			addf(p)
+		}
+	case *mackerel.MonitorAnomalyDetection:
+		if len(m.Scopes) == 0 {
+			addf("scopes is required")
+		}
+		if m.WarningSensitivity == "" && m.CriticalSensitivity == "" {
+			addf("either warningSensitivity or criticalSensitivity is required")
+		}
+		for _, s := range []string{m.WarningSensitivity, m.CriticalSensitivity} {
+			switch s {
+			case "", "insensitive", "normal", "sensitive":
+			default:
+				addf("sensitivity should be insensitive, normal or sensitive: %q", s)
+			}
+		}
+	default:
+		addf("unknown type: %s", monitor.MonitorType())
+	}
+	return problems
+}
+
+// validateMonitorThresholds checks that the warning threshold is reached before the critical one.
+func validateMonitorThresholds(operator string, warning, critical *float64) []string {
+	var problems []string
+	if operator != ">" && operator != "<" {
+		problems = append(problems, fmt.Sprintf("operator should be '>' or '<': %q", operator))
+	}
+	if warning == nil && critical == nil {
+		problems = append(problems, "either warning or critical threshold is required")
+	}
+	if warning != nil && critical != nil {
+		if operator == ">" && *warning > *critical {
+			problems = append(problems, fmt.Sprintf("warning (%v) should not be greater than critical (%v)", *warning, *critical))
+		}
+		if operator == "<" && *warning < *critical {
+			problems = append(problems, fmt.Sprintf("warning (%v) should not be less than critical (%v)", *warning, *critical))
+		}
+	}
+	return problems
+}
+
+// checkExpressionSyntax checks the parentheses and the quotes of an expression are balanced.
+// It does not parse the functions, which are checked by Mackerel.
+func checkExpressionSyntax(expr string) error {
+	depth := 0
+	var quote rune
+	for _, r := range expr {
+		if quote != 0 {
+			if r == quote {
+				quote = 0
+			}
+			continue
+		}
+		switch r {
+		case '\'', '"':
+			quote = r
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth < 0 {
+				return fmt.Errorf("unexpected ')'")
+			}
+		}
+	}
+	if quote != 0 {
+		return fmt.Errorf("unterminated string")
+	}
+	if depth > 0 {
+		return fmt.Errorf("missing ')'")
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestValidateMonitorPayload(t *testing.T) {
+	testCases := []struct {
+		name     string
+		monitor  mackerel.Monitor
+		problems []string
+	}{
+		{
+			name:    "valid host metric",
+			monitor: &mackerel.MonitorHostMetric{Name: "cpu", Type: "host", Metric: "cpu%", Operator: ">", Warning: pfloat64(80), Critical: pfloat64(90), Duration: 1},
+		},
+		{
+			name:    "host metric without thresholds",
+			monitor: &mackerel.MonitorHostMetric{Name: "cpu", Type: "host", Metric: "cpu%", Operator: ">=", Duration: 1},
+			problems: []string{
+				`Monitor 'cpu': operator should be '>' or '<': ">="`,
+				"Monitor 'cpu': either warning or critical threshold is required",
+			},
+		},
+		{
+			name:     "inverted thresholds",
+			monitor:  &mackerel.MonitorServiceMetric{Name: "latency", Type: "service", Service: "foo", Metric: "latency", Operator: "<", Warning: pfloat64(10), Critical: pfloat64(20), Duration: 1},
+			problems: []string{"Monitor 'latency': warning (10) should not be less than critical (20)"},
+		},
+		{
+			name:     "service metric without service",
+			monitor:  &mackerel.MonitorServiceMetric{Name: "latency", Type: "service", Metric: "latency", Operator: ">", Warning: pfloat64(10), Duration: 1},
+			problems: []string{"Monitor 'latency': service is required"},
+		},
+		{
+			name:     "external without scheme",
+			monitor:  &mackerel.MonitorExternalHTTP{Name: "example", Type: "external", URL: "example.com"},
+			problems: []string{`Monitor 'example': url should be an absolute http(s) URL: "example.com"`},
+		},
+		{
+			name:     "unbalanced expression",
+			monitor:  &mackerel.MonitorExpression{Name: "expr", Type: "expression", Expression: "max(role(foo:bar, loadavg5)", Operator: ">", Warning: pfloat64(1)},
+			problems: []string{"Monitor 'expr': invalid expression: missing ')'"},
+		},
+		{
+			name:     "anomaly detection with unknown sensitivity",
+			monitor:  &mackerel.MonitorAnomalyDetection{Name: "anomaly", Type: "anomalyDetection", Scopes: []string{"foo:bar"}, WarningSensitivity: "high"},
+			problems: []string{`Monitor 'anomaly': sensitivity should be insensitive, normal or sensitive: "high"`},
+		},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			problems := validateMonitorPayload(tc.monitor)
+			if !reflect.DeepEqual(tc.problems, problems) {
+				t.Errorf("problems should be %q but: %q", tc.problems, problems)
+			}
+		})
+	}
+}
+
+func TestCheckExpressionSyntax(t *testing.T) {
+	for _, expr := range []string{"role(foo:bar, loadavg5)", "max(role('foo:bar', 'custom.x.*'))"} {
+		if err := checkExpressionSyntax(expr); err != nil {
+			t.Errorf("%q should be valid but: %s", expr, err)
+		}
+	}
+	for _, expr := range []string{"role(foo:bar))", "role('foo:bar, x)"} {
+		if err := checkExpressionSyntax(expr); err == nil {
+			t.Errorf("%q should be invalid", expr)
+		}
+	}
+}
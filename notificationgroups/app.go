@@ -0,0 +1,149 @@
+package notificationgroups
+
+import (
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+type appLogger interface {
+	Log(string, string)
+}
+
+type notificationGroupsApp struct {
+	client    mackerelclient.Client
+	logger    appLogger
+	outStream io.Writer
+}
+
+func (app *notificationGroupsApp) list(output *format.Output) error {
+	groups, err := app.client.FindNotificationGroups()
+	if err != nil {
+		return err
+	}
+	channels, err := app.client.FindChannels()
+	if err != nil {
+		return err
+	}
+	return output.Print(app.outStream, groups, func() *format.Table {
+		return notificationGroupsTable(groups, channels)
+	})
+}
+
+// notificationGroupsTable returns the table of the groups with the names of their child groups and channels.
+func notificationGroupsTable(groups []*mackerel.NotificationGroup, channels []*mackerel.Channel) *format.Table {
+	names := map[string]string{}
+	for _, g := range groups {
+		names[g.ID] = g.Name
+	}
+	for _, ch := range channels {
+		names[ch.ID] = ch.Name
+	}
+	describe := func(ids []string) string {
+		xs := make([]string, len(ids))
+		for i, id := range ids {
+			if name, ok := names[id]; ok {
+				xs[i] = name
+			} else {
+				xs[i] = id
+			}
+		}
+		return strings.Join(xs, ",")
+	}
+	t := format.NewTable("ID", "NAME", "LEVEL", "CHILD_GROUPS", "CHANNELS", "MONITORS", "SERVICES")
+	for _, g := range groups {
+		monitors := make([]string, len(g.Monitors))
+		for i, m := range g.Monitors {
+			monitors[i] = m.ID
+			if m.SkipDefault {
+				monitors[i] += "(skip default)"
+			}
+		}
+		services := make([]string, len(g.Services))
+		for i, s := range g.Services {
+			services[i] = s.Name
+		}
+		t.Append(g.ID, g.Name, string(g.NotificationLevel),
+			describe(g.ChildNotificationGroupIDs), describe(g.ChildChannelIDs),
+			strings.Join(monitors, ","), strings.Join(services, ","))
+	}
+	return t
+}
+
+// normalize fills the nil children since the API requires the arrays.
+func normalize(g *mackerel.NotificationGroup) {
+	if g.ChildNotificationGroupIDs == nil {
+		g.ChildNotificationGroupIDs = []string{}
+	}
+	if g.ChildChannelIDs == nil {
+		g.ChildChannelIDs = []string{}
+	}
+	if g.NotificationLevel == "" {
+		g.NotificationLevel = mackerel.NotificationLevelAll
+	}
+}
+
+func validateNotificationGroup(g *mackerel.NotificationGroup) error {
+	if g.Name == "" {
+		return fmt.Errorf("the name of the notification group is empty")
+	}
+	if g.NotificationLevel != mackerel.NotificationLevelAll && g.NotificationLevel != mackerel.NotificationLevelCritical {
+		return fmt.Errorf("the notification level of %s should be all or critical: %q", g.Name, g.NotificationLevel)
+	}
+	return nil
+}
+
+func (app *notificationGroupsApp) create(g *mackerel.NotificationGroup) error {
+	normalize(g)
+	if err := validateNotificationGroup(g); err != nil {
+		return err
+	}
+	group, err := app.client.CreateNotificationGroup(g)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("created", fmt.Sprintf("%s (%s)", group.Name, group.ID))
+	return nil
+}
+
+// update modifies the notification group of the id by the function and updates it.
+func (app *notificationGroupsApp) update(id string, modify func(*mackerel.NotificationGroup)) error {
+	groups, err := app.client.FindNotificationGroups()
+	if err != nil {
+		return err
+	}
+	var g *mackerel.NotificationGroup
+	for _, x := range groups {
+		if x.ID == id {
+			g = x
+		}
+	}
+	if g == nil {
+		return fmt.Errorf("notification group not found: %s", id)
+	}
+	modify(g)
+	normalize(g)
+	if err := validateNotificationGroup(g); err != nil {
+		return err
+	}
+	group, err := app.client.UpdateNotificationGroup(id, g)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("updated", fmt.Sprintf("%s (%s)", group.Name, group.ID))
+	return nil
+}
+
+func (app *notificationGroupsApp) delete(id string) error {
+	group, err := app.client.DeleteNotificationGroup(id)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("deleted", fmt.Sprintf("%s (%s)", group.Name, group.ID))
+	return nil
+}
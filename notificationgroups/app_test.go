@@ -0,0 +1,99 @@
+package notificationgroups
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+type testLogger struct {
+	w io.Writer
+}
+
+func (l *testLogger) Log(prefix, message string) {
+	fmt.Fprintln(l.w, prefix, message)
+}
+
+func TestNotificationGroupsApp_List(t *testing.T) {
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindNotificationGroups(func() ([]*mackerel.NotificationGroup, error) {
+			return []*mackerel.NotificationGroup{
+				{
+					ID:                        "3JwREyrZGQ9",
+					Name:                      "ops",
+					NotificationLevel:         mackerel.NotificationLevelAll,
+					ChildNotificationGroupIDs: []string{"3JwREyrZGQA"},
+					ChildChannelIDs:           []string{"abcdefabc", "unknown"},
+					Monitors:                  []*mackerel.NotificationGroupMonitor{{ID: "2cSZzK3XfmG"}, {ID: "2cSZzK3XfmH", SkipDefault: true}},
+					Services:                  []*mackerel.NotificationGroupService{{Name: "Blog"}},
+				},
+				{
+					ID:                        "3JwREyrZGQA",
+					Name:                      "oncall",
+					NotificationLevel:         mackerel.NotificationLevelCritical,
+					ChildNotificationGroupIDs: []string{},
+					ChildChannelIDs:           []string{"bcdefabcd"},
+					Services:                  []*mackerel.NotificationGroupService{{Name: "Shop"}},
+				},
+			}, nil
+		}),
+		mackerelclient.MockFindChannels(func() ([]*mackerel.Channel, error) {
+			return []*mackerel.Channel{{ID: "abcdefabc", Name: "mail"}, {ID: "bcdefabcd", Name: "slack"}}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &notificationGroupsApp{client: client, logger: &testLogger{out}, outStream: out}
+	assert.NoError(t, app.list(&format.Output{Format: format.OutputTable}))
+	assert.Equal(t, `ID           NAME    LEVEL     CHILD_GROUPS  CHANNELS      MONITORS                               SERVICES
+3JwREyrZGQ9  ops     all       oncall        mail,unknown  2cSZzK3XfmG,2cSZzK3XfmH(skip default)  Blog
+3JwREyrZGQA  oncall  critical                slack                                                Shop
+`, out.String())
+}
+
+func TestNotificationGroupsApp_CreateUpdateDelete(t *testing.T) {
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindNotificationGroups(func() ([]*mackerel.NotificationGroup, error) {
+			return []*mackerel.NotificationGroup{{ID: "3JwREyrZGQ9", Name: "ops", NotificationLevel: mackerel.NotificationLevelAll}}, nil
+		}),
+		mackerelclient.MockCreateNotificationGroup(func(g *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error) {
+			assert.Equal(t, &mackerel.NotificationGroup{
+				Name:                      "oncall",
+				NotificationLevel:         mackerel.NotificationLevelAll,
+				ChildNotificationGroupIDs: []string{},
+				ChildChannelIDs:           []string{"abcdefabc"},
+			}, g)
+			g.ID = "3JwREyrZGQA"
+			return g, nil
+		}),
+		mackerelclient.MockUpdateNotificationGroup(func(id string, g *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error) {
+			assert.Equal(t, "3JwREyrZGQ9", id)
+			assert.Equal(t, mackerel.NotificationLevelCritical, g.NotificationLevel)
+			return g, nil
+		}),
+		mackerelclient.MockDeleteNotificationGroup(func(id string) (*mackerel.NotificationGroup, error) {
+			return &mackerel.NotificationGroup{ID: id, Name: "ops"}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &notificationGroupsApp{client: client, logger: &testLogger{out}, outStream: out}
+	assert.NoError(t, app.create(&mackerel.NotificationGroup{Name: "oncall", ChildChannelIDs: []string{"abcdefabc"}}))
+	assert.EqualError(t, app.create(&mackerel.NotificationGroup{Name: "oncall", NotificationLevel: "warning"}),
+		`the notification level of oncall should be all or critical: "warning"`)
+	assert.NoError(t, app.update("3JwREyrZGQ9", func(g *mackerel.NotificationGroup) {
+		g.NotificationLevel = mackerel.NotificationLevelCritical
+	}))
+	assert.EqualError(t, app.update("unknown", func(*mackerel.NotificationGroup) {}), "notification group not found: unknown")
+	assert.NoError(t, app.delete("3JwREyrZGQ9"))
+	assert.Equal(t, `created oncall (3JwREyrZGQA)
+updated ops (3JwREyrZGQ9)
+deleted ops (3JwREyrZGQ9)
+`, out.String())
+}
@@ -0,0 +1,267 @@
+package notificationgroups
+
+import (
+	"os"
+
+	"github.com/Songmu/prompter"
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+var notificationGroupsListFlags = []cli.Flag{
+	cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+}
+
+var notificationGroupFlags = []cli.Flag{
+	cli.StringFlag{Name: "name", Value: "", Usage: "The name of the notification group"},
+	cli.StringFlag{Name: "level", Value: "all", Usage: "The notification level: all or critical"},
+	cli.StringSliceFlag{
+		Name:  "child-group",
+		Value: &cli.StringSlice{},
+		Usage: "The ID of the child notification group. Multiple choices are allowed",
+	},
+	cli.StringSliceFlag{
+		Name:  "channel",
+		Value: &cli.StringSlice{},
+		Usage: "The ID of the child channel. Multiple choices are allowed",
+	},
+	cli.StringSliceFlag{
+		Name:  "monitor",
+		Value: &cli.StringSlice{},
+		Usage: "The ID of the monitor to notify. Multiple choices are allowed",
+	},
+	cli.StringSliceFlag{
+		Name:  "skip-default-monitor",
+		Value: &cli.StringSlice{},
+		Usage: "The ID of the monitor to notify without the default notification group. Multiple choices are allowed",
+	},
+	cli.StringSliceFlag{
+		Name:  "service, s",
+		Value: &cli.StringSlice{},
+		Usage: "The service to notify. Multiple choices are allowed",
+	},
+}
+
+// Command is the definition of notification-groups subcommand
+var Command = cli.Command{
+	Name:      "notification-groups",
+	Usage:     "List notification groups",
+	ArgsUsage: "[--output | -o <format>]",
+	Description: `
+    List the notification groups with the names of their child groups and channels. With a subcommand, manipulate the notification groups.
+    Requests APIs under "/api/v0/notification-groups". See https://mackerel.io/api-docs/entry/notification-groups .
+`,
+	Action: doNotificationGroups,
+	Flags:  notificationGroupsListFlags,
+	Subcommands: []cli.Command{
+		{
+			Name:      "list",
+			Usage:     "List notification groups",
+			ArgsUsage: "[--output | -o <format>]",
+			Description: `
+    List the notification groups with the names of their child groups and channels.
+`,
+			Action: doNotificationGroups,
+			Flags:  notificationGroupsListFlags,
+		},
+		{
+			Name:      "create",
+			Usage:     "Create a notification group",
+			ArgsUsage: "--name <name> [--level all|critical] [--child-group <groupId>] [--channel <channelId>] [--monitor <monitorId>] [--skip-default-monitor <monitorId>] [--service | -s <service>]",
+			Description: `
+    Create a new notification group.
+    Requests "POST /api/v0/notification-groups". See https://mackerel.io/api-docs/entry/notification-groups#create.
+`,
+			Action: doCreateNotificationGroup,
+			Flags:  notificationGroupFlags,
+		},
+		{
+			Name:      "update",
+			Usage:     "Update a notification group",
+			ArgsUsage: "--id <id> [--name <name>] [--level all|critical] [--child-group <groupId>] [--channel <channelId>] [--monitor <monitorId>] [--skip-default-monitor <monitorId>] [--service | -s <service>]",
+			Description: `
+    Update the notification group. Only the specified fields are changed,
+    and the child groups, the channels, the monitors and the services are replaced if specified.
+    Requests "PUT /api/v0/notification-groups/<notificationGroupId>". See https://mackerel.io/api-docs/entry/notification-groups#update.
+`,
+			Action: doUpdateNotificationGroup,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: "id", Value: "", Usage: "The ID of the notification group"},
+			}, notificationGroupFlags...),
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete a notification group",
+			ArgsUsage: "[--force] <notificationGroupId>",
+			Description: `
+    Delete the notification group.
+    Requests "DELETE /api/v0/notification-groups/<notificationGroupId>". See https://mackerel.io/api-docs/entry/notification-groups#delete.
+`,
+			Action: doDeleteNotificationGroup,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "force", Usage: "Delete the notification group without confirmation"},
+			},
+		},
+		{
+			Name:      "pull",
+			Usage:     "Pull notification groups",
+			ArgsUsage: "[--file-path | -F <file>]",
+			Description: `
+    Save the notification groups to the JSON file, which can be pushed by "mkr notification-groups push".
+`,
+			Action: doPullNotificationGroups,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "notification-groups.json", Usage: "The JSON file to save the notification groups"},
+			},
+		},
+		{
+			Name:      "push",
+			Usage:     "Push notification groups",
+			ArgsUsage: "[--file-path | -F <file>] [--dry-run | -d] [--prune]",
+			Description: `
+    Create or update the notification groups in the JSON file. The groups are matched by "id", or by "name" if they have no id.
+    With --prune, the notification groups which do not exist in the file are deleted.
+`,
+			Action: doPushNotificationGroups,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "notification-groups.json", Usage: "The JSON file of the notification groups"},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show the changes, but not apply them"},
+				cli.BoolFlag{Name: "prune", Usage: "Delete the notification groups which do not exist in the file"},
+			},
+		},
+	},
+}
+
+func newNotificationGroupsApp(c *cli.Context) (*notificationGroupsApp, error) {
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
+	if err != nil {
+		return nil, err
+	}
+	return &notificationGroupsApp{
+		client:    client,
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}, nil
+}
+
+func doNotificationGroups(c *cli.Context) error {
+	app, err := newNotificationGroupsApp(c)
+	if err != nil {
+		return err
+	}
+	output, err := format.OutputFromContext(c, "table")
+	if err != nil {
+		return err
+	}
+	return app.list(output)
+}
+
+// applyFlags sets the fields of the group by the flags. Only the specified flags are applied if update is true.
+func applyFlags(c *cli.Context, g *mackerel.NotificationGroup, update bool) {
+	isSet := func(name string) bool {
+		return !update || c.IsSet(name)
+	}
+	if isSet("name") {
+		g.Name = c.String("name")
+	}
+	if isSet("level") {
+		g.NotificationLevel = mackerel.NotificationLevel(c.String("level"))
+	}
+	if isSet("child-group") {
+		g.ChildNotificationGroupIDs = c.StringSlice("child-group")
+	}
+	if isSet("channel") {
+		g.ChildChannelIDs = c.StringSlice("channel")
+	}
+	if isSet("monitor") || isSet("skip-default-monitor") {
+		g.Monitors = nil
+		for _, id := range c.StringSlice("monitor") {
+			g.Monitors = append(g.Monitors, &mackerel.NotificationGroupMonitor{ID: id})
+		}
+		for _, id := range c.StringSlice("skip-default-monitor") {
+			g.Monitors = append(g.Monitors, &mackerel.NotificationGroupMonitor{ID: id, SkipDefault: true})
+		}
+	}
+	if isSet("service") {
+		g.Services = nil
+		for _, name := range c.StringSlice("service") {
+			g.Services = append(g.Services, &mackerel.NotificationGroupService{Name: name})
+		}
+	}
+}
+
+func doCreateNotificationGroup(c *cli.Context) error {
+	if c.String("name") == "" {
+		cli.ShowCommandHelp(c, "create")
+		os.Exit(1)
+	}
+	g := &mackerel.NotificationGroup{}
+	applyFlags(c, g, false)
+	app, err := newNotificationGroupsApp(c)
+	if err != nil {
+		return err
+	}
+	return app.create(g)
+}
+
+func doUpdateNotificationGroup(c *cli.Context) error {
+	id := c.String("id")
+	if id == "" {
+		cli.ShowCommandHelp(c, "update")
+		os.Exit(1)
+	}
+	app, err := newNotificationGroupsApp(c)
+	if err != nil {
+		return err
+	}
+	return app.update(id, func(g *mackerel.NotificationGroup) {
+		applyFlags(c, g, true)
+	})
+}
+
+func doDeleteNotificationGroup(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelp(c, "delete")
+		os.Exit(1)
+	}
+	id := c.Args().Get(0)
+	if !c.Bool("force") && !prompter.YN("Delete the notification group "+id+".\nAre you sure?", false) {
+		logger.Log("", "deletion is canceled.")
+		return nil
+	}
+	app, err := newNotificationGroupsApp(c)
+	if err != nil {
+		return err
+	}
+	return app.delete(id)
+}
+
+func doPullNotificationGroups(c *cli.Context) error {
+	app, err := newNotificationGroupsApp(c)
+	if err != nil {
+		return err
+	}
+	return app.pull(c.String("file-path"))
+}
+
+func doPushNotificationGroups(c *cli.Context) error {
+	return Push(c, c.String("file-path"), c.Bool("dry-run"), c.Bool("prune"))
+}
+
+// Push creates, updates and deletes the notification groups in the JSON file as "mkr notification-groups push" does.
+// It is also used by "mkr apply".
+func Push(c *cli.Context, filePath string, dryRun, prune bool) error {
+	app, err := newNotificationGroupsApp(c)
+	if err != nil {
+		return err
+	}
+	return app.push(pushNotificationGroupsParam{
+		filePath: filePath,
+		dryRun:   dryRun,
+		prune:    prune,
+	})
+}
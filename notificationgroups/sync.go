@@ -0,0 +1,115 @@
+package notificationgroups
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/resourcesync"
+)
+
+type notificationGroupsFile struct {
+	NotificationGroups []*mackerel.NotificationGroup `json:"notificationGroups"`
+}
+
+func (app *notificationGroupsApp) pull(filePath string) error {
+	groups, err := app.client.FindNotificationGroups()
+	if err != nil {
+		return err
+	}
+	data := format.JSONMarshalIndent(notificationGroupsFile{NotificationGroups: groups}, "", "    ") + "\n"
+	if err := ioutil.WriteFile(filePath, []byte(data), 0644); err != nil {
+		return err
+	}
+	app.logger.Log("info", fmt.Sprintf("Notification groups are saved to '%s' (%d groups).", filePath, len(groups)))
+	return nil
+}
+
+func loadNotificationGroups(filePath string) ([]*mackerel.NotificationGroup, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var file notificationGroupsFile
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	for _, g := range file.NotificationGroups {
+		normalize(g)
+		if err := validateNotificationGroup(g); err != nil {
+			return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+		}
+	}
+	if err := resourcesync.CheckResources("notification group", notificationGroupResources(file.NotificationGroups)); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	return file.NotificationGroups, nil
+}
+
+func notificationGroupResources(groups []*mackerel.NotificationGroup) []resourcesync.Resource {
+	resources := make([]resourcesync.Resource, len(groups))
+	for i, g := range groups {
+		resources[i] = resourcesync.Resource{ID: g.ID, Name: g.Name, Value: g}
+	}
+	return resources
+}
+
+// planNotificationGroups returns the changes to make the current groups the desired ones.
+// The desired groups are matched by the id, or by the name if they have no id.
+// The groups which do not exist in the desired ones are deleted only if prune is true.
+func planNotificationGroups(current, desired []*mackerel.NotificationGroup, prune bool) ([]*resourcesync.ResourceChange, error) {
+	return resourcesync.Plan("notification group", notificationGroupResources(current), notificationGroupResources(desired), prune, func(x, y interface{}) bool {
+		return sameNotificationGroup(x.(*mackerel.NotificationGroup), y.(*mackerel.NotificationGroup))
+	})
+}
+
+// sameNotificationGroup compares the groups except for their ids.
+func sameNotificationGroup(x, y *mackerel.NotificationGroup) bool {
+	xx, yy := *x, *y
+	xx.ID, yy.ID = "", ""
+	normalize(&xx)
+	normalize(&yy)
+	a, _ := json.Marshal(xx)
+	b, _ := json.Marshal(yy)
+	return bytes.Equal(a, b)
+}
+
+type pushNotificationGroupsParam struct {
+	filePath string
+	dryRun   bool
+	prune    bool
+}
+
+func (app *notificationGroupsApp) push(param pushNotificationGroupsParam) error {
+	desired, err := loadNotificationGroups(param.filePath)
+	if err != nil {
+		return err
+	}
+	current, err := app.client.FindNotificationGroups()
+	if err != nil {
+		return err
+	}
+	changes, err := planNotificationGroups(current, desired, param.prune)
+	if err != nil {
+		return err
+	}
+	return resourcesync.Apply(app.outStream, app.logger, "notification groups", resourcesync.Changes(changes), param.dryRun, func(c resourcesync.Change) error {
+		rc := c.(*resourcesync.ResourceChange)
+		g := *rc.Value.(*mackerel.NotificationGroup)
+		g.ID = ""
+		var err error
+		switch rc.Action() {
+		case "create":
+			_, err = app.client.CreateNotificationGroup(&g)
+		case "update":
+			_, err = app.client.UpdateNotificationGroup(rc.ID, &g)
+		case "delete":
+			_, err = app.client.DeleteNotificationGroup(rc.ID)
+		}
+		return err
+	})
+}
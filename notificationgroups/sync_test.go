@@ -0,0 +1,108 @@
+package notificationgroups
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/mackerelio/mkr/resourcesync"
+)
+
+func TestPlanNotificationGroups(t *testing.T) {
+	current := []*mackerel.NotificationGroup{
+		{ID: "a", Name: "ops", NotificationLevel: "all", ChildNotificationGroupIDs: []string{}, ChildChannelIDs: []string{"x"}},
+		{ID: "b", Name: "oncall", NotificationLevel: "critical", ChildNotificationGroupIDs: []string{}, ChildChannelIDs: []string{}},
+		{ID: "c", Name: "obsolete", NotificationLevel: "all", ChildNotificationGroupIDs: []string{}, ChildChannelIDs: []string{}},
+	}
+	desired := []*mackerel.NotificationGroup{
+		{ID: "a", Name: "ops", NotificationLevel: "all", ChildChannelIDs: []string{"x"}},
+		{Name: "oncall", NotificationLevel: "all"},
+		{Name: "new", NotificationLevel: "all", Services: []*mackerel.NotificationGroupService{{Name: "Blog"}}},
+	}
+	describe := func(changes []*resourcesync.ResourceChange) []string {
+		var xs []string
+		for _, c := range changes {
+			xs = append(xs, c.Action()+" "+c.Target())
+		}
+		return xs
+	}
+
+	changes, err := planNotificationGroups(current, desired, false)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update 'oncall' (b)", "create 'new'"}, describe(changes))
+
+	changes, err = planNotificationGroups(current, desired, true)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update 'oncall' (b)", "create 'new'", "delete 'obsolete' (c)"}, describe(changes))
+
+	_, err = planNotificationGroups(current, []*mackerel.NotificationGroup{{ID: "z", Name: "unknown"}}, false)
+	assert.EqualError(t, err, "notification group not found: z")
+}
+
+func TestNotificationGroupsApp_PullPush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-notification-groups")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+	filePath := filepath.Join(dir, "notification-groups.json")
+
+	var created []*mackerel.NotificationGroup
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindNotificationGroups(func() ([]*mackerel.NotificationGroup, error) {
+			return []*mackerel.NotificationGroup{
+				{ID: "a", Name: "ops", NotificationLevel: "all", ChildNotificationGroupIDs: []string{}, ChildChannelIDs: []string{"x"}},
+			}, nil
+		}),
+		mackerelclient.MockCreateNotificationGroup(func(g *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error) {
+			created = append(created, g)
+			return g, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &notificationGroupsApp{client: client, logger: &testLogger{out}, outStream: out}
+
+	assert.NoError(t, app.pull(filePath))
+	data, err := ioutil.ReadFile(filePath)
+	assert.NoError(t, err)
+	assert.Equal(t, `{
+    "notificationGroups": [
+        {
+            "id": "a",
+            "name": "ops",
+            "notificationLevel": "all",
+            "childNotificationGroupIds": [],
+            "childChannelIds": [
+                "x"
+            ]
+        }
+    ]
+}
+`, string(data))
+
+	out.Reset()
+	assert.NoError(t, app.push(pushNotificationGroupsParam{filePath: filePath}))
+	assert.Equal(t, "info notification groups are up to date.\n", out.String())
+
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(`{"notificationGroups": [
+		{"id": "a", "name": "ops", "notificationLevel": "all", "childChannelIds": ["x"]},
+		{"name": "oncall", "notificationLevel": "critical", "childNotificationGroupIds": ["a"]}
+	]}`), 0644))
+	out.Reset()
+	assert.NoError(t, app.push(pushNotificationGroupsParam{filePath: filePath}))
+	assert.Equal(t, "created 'oncall'\n", out.String())
+	assert.Equal(t, []*mackerel.NotificationGroup{{
+		Name:                      "oncall",
+		NotificationLevel:         mackerel.NotificationLevelCritical,
+		ChildNotificationGroupIDs: []string{"a"},
+		ChildChannelIDs:           []string{},
+	}}, created)
+
+	assert.NoError(t, ioutil.WriteFile(filePath, []byte(`{"notificationGroups": [{"name": "ops", "notificationLevel": "warning"}]}`), 0644))
+	assert.Error(t, app.push(pushNotificationGroupsParam{filePath: filePath}))
+}
@@ -8,16 +8,70 @@ import (
 )
 
 type orgApp struct {
-	client    mackerelclient.Client
-	outStream io.Writer
+	client       mackerelclient.Client
+	apikeySource string
+	targets      []*orgTarget
+	outStream    io.Writer
 }
 
-func (app *orgApp) run() error {
+type orgTarget struct {
+	apikeySource     string
+	client           mackerelclient.Client
+	detectPermission func() (string, error)
+	err              error
+}
+
+type orgInfo struct {
+	Name         string `json:"name,omitempty"`
+	APIKeySource string `json:"apikeySource,omitempty"`
+	Permission   string `json:"permission,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+func (app *orgApp) run(output *format.Output) error {
 	org, err := app.client.GetOrg()
 	if err != nil {
 		return err
 	}
 
-	format.PrettyPrintJSON(app.outStream, org)
-	return nil
+	info := &orgInfo{
+		Name:         org.Name,
+		APIKeySource: app.apikeySource,
+	}
+	return output.Print(app.outStream, info, func() *format.Table {
+		t := format.NewTable("NAME", "APIKEY_SOURCE")
+		t.Append(info.Name, info.APIKeySource)
+		return t
+	})
+}
+
+// runAll fetches the organizations of all the targets. The failure of a target
+// is reported in the output and does not stop fetching the others.
+func (app *orgApp) runAll(output *format.Output) error {
+	orgs := make([]*orgInfo, 0, len(app.targets))
+	for _, t := range app.targets {
+		info := &orgInfo{APIKeySource: t.apikeySource}
+		orgs = append(orgs, info)
+		if t.err != nil {
+			info.Error = t.err.Error()
+			continue
+		}
+		org, err := t.client.GetOrg()
+		if err != nil {
+			info.Error = err.Error()
+			continue
+		}
+		info.Name = org.Name
+		if info.Permission, err = t.detectPermission(); err != nil {
+			info.Error = err.Error()
+		}
+	}
+
+	return output.Print(app.outStream, orgs, func() *format.Table {
+		t := format.NewTable("NAME", "APIKEY_SOURCE", "PERMISSION", "ERROR")
+		for _, info := range orgs {
+			t.Append(info.Name, info.APIKeySource, info.Permission, info.Error)
+		}
+		return t
+	})
 }
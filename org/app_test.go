@@ -2,20 +2,23 @@ package org
 
 import (
 	"bytes"
+	"errors"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/mackerelio/mackerel-client-go"
 
+	"github.com/mackerelio/mkr/format"
 	"github.com/mackerelio/mkr/mackerelclient"
 )
 
 func TestOrgApp_Run(t *testing.T) {
 	testCases := []struct {
-		id       string
-		org      *mackerel.Org
-		expected string
+		id           string
+		org          *mackerel.Org
+		apikeySource string
+		expected     string
 	}{
 		{
 			id:  "default",
@@ -23,6 +26,16 @@ func TestOrgApp_Run(t *testing.T) {
 			expected: `{
     "name": "sample-org"
 }
+`,
+		},
+		{
+			id:           "with apikey source",
+			org:          &mackerel.Org{Name: "sample-org"},
+			apikeySource: "MACKEREL_APIKEY environment variable",
+			expected: `{
+    "name": "sample-org",
+    "apikeySource": "MACKEREL_APIKEY environment variable"
+}
 `,
 		},
 	}
@@ -35,11 +48,70 @@ func TestOrgApp_Run(t *testing.T) {
 		t.Run(tc.id, func(t *testing.T) {
 			out := new(bytes.Buffer)
 			app := &orgApp{
-				client:    client,
-				outStream: out,
+				client:       client,
+				apikeySource: tc.apikeySource,
+				outStream:    out,
 			}
-			assert.NoError(t, app.run())
+			assert.NoError(t, app.run(&format.Output{Format: format.OutputJSON}))
 			assert.Equal(t, tc.expected, out.String())
 		})
 	}
 }
+
+func TestOrgApp_RunAll(t *testing.T) {
+	targets := []*orgTarget{
+		{
+			apikeySource: "MACKEREL_APIKEY environment variable",
+			client: mackerelclient.NewMockClient(
+				mackerelclient.MockGetOrg(func() (*mackerel.Org, error) {
+					return &mackerel.Org{Name: "sample-org"}, nil
+				}),
+			),
+			detectPermission: func() (string, error) {
+				return mackerelclient.PermissionRead, nil
+			},
+		},
+		{
+			apikeySource: "config file (mackerel-agent.conf)",
+			client: mackerelclient.NewMockClient(
+				mackerelclient.MockGetOrg(func() (*mackerel.Org, error) {
+					return &mackerel.Org{Name: "another-org"}, nil
+				}),
+			),
+			detectPermission: func() (string, error) {
+				return mackerelclient.PermissionReadWrite, nil
+			},
+		},
+		{
+			apikeySource: "config file (invalid.conf)",
+			client: mackerelclient.NewMockClient(
+				mackerelclient.MockGetOrg(func() (*mackerel.Org, error) {
+					return nil, errors.New("API request failed: Authentication failed")
+				}),
+			),
+		},
+	}
+	out := new(bytes.Buffer)
+	app := &orgApp{
+		targets:   targets,
+		outStream: out,
+	}
+	assert.NoError(t, app.runAll(&format.Output{Format: format.OutputJSON}))
+	assert.Equal(t, `[
+    {
+        "name": "sample-org",
+        "apikeySource": "MACKEREL_APIKEY environment variable",
+        "permission": "read"
+    },
+    {
+        "name": "another-org",
+        "apikeySource": "config file (mackerel-agent.conf)",
+        "permission": "read/write"
+    },
+    {
+        "apikeySource": "config file (invalid.conf)",
+        "error": "API request failed: Authentication failed"
+    }
+]
+`, out.String())
+}
@@ -1,31 +1,108 @@
 package org
 
 import (
+	"fmt"
 	"os"
 
-	"github.com/mackerelio/mkr/mackerelclient"
 	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
 )
 
 // Command is the definition of org subcommand
 var Command = cli.Command{
-	Name:  "org",
-	Usage: "Fetch organization",
+	Name:      "org",
+	Usage:     "Fetch organization",
+	ArgsUsage: "[--all] [--output | -o <format>]",
 	Description: `
-    Fetch organization.
+    Fetch organization and show where the apikey is configured.
+    With --all, fetch the organization of every configured apikey, that is MACKEREL_APIKEY, the apikey
+    in mackerel-agent.conf and the profiles in ~/.config/mkr/config.toml, with its permission (read or read/write).
+    The permission is detected by a probe request that is always rejected and never changes the organization.
     Requests APIs under "/api/v0/org". See https://mackerel.io/api-docs/entry/organizations .
 `,
 	Action: doOrg,
+	Flags: []cli.Flag{
+		cli.BoolFlag{Name: "all", Usage: "Fetch the organizations of all the configured apikeys"},
+		cli.StringFlag{Name: "output, o", Value: "json", Usage: format.OutputUsage},
+	},
 }
 
 func doOrg(c *cli.Context) error {
-	client, err := mackerelclient.New(c.GlobalString("conf"), c.GlobalString("apibase"))
+	output, err := format.OutputFromContext(c, "json")
 	if err != nil {
 		return err
 	}
+	if c.Bool("all") {
+		targets, err := newOrgTargets(c.GlobalString("conf"), c.GlobalString("apibase"))
+		if err != nil {
+			return err
+		}
+		return (&orgApp{
+			targets:   targets,
+			outStream: os.Stdout,
+		}).runAll(output)
+	}
 
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
+	if err != nil {
+		return err
+	}
+
+	apikey, err := mackerelclient.ResolveApikey(c.GlobalString("conf"), c.GlobalString("profile"))
+	if err != nil {
+		return err
+	}
 	return (&orgApp{
-		client:    client,
-		outStream: os.Stdout,
-	}).run()
+		client:       client,
+		apikeySource: apikey.Source,
+		outStream:    os.Stdout,
+	}).run(output)
+}
+
+func newOrgTargets(conffile, apibase string) ([]*orgTarget, error) {
+	var targets []*orgTarget
+	for _, apikey := range mackerelclient.LoadApikeys(conffile) {
+		t, err := newOrgTarget(apikey, mackerelclient.ResolveApibase(conffile, apibase, ""))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	conf, err := mackerelclient.LoadConfig(mackerelclient.DefaultConfigFile())
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range conf.ProfileNames() {
+		apikey, err := conf.Apikey(name)
+		if err != nil {
+			// report the failure of loading the apikey (e.g. apikey_command) in the output
+			targets = append(targets, &orgTarget{apikeySource: "profile " + name, err: err})
+			continue
+		}
+		t, err := newOrgTarget(apikey, mackerelclient.ResolveApibase(conffile, apibase, name))
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	if len(targets) == 0 {
+		return nil, fmt.Errorf("No mackerel apikeys are specified from MACKEREL_APIKEY, config or profiles")
+	}
+	return targets, nil
+}
+
+func newOrgTarget(apikey *mackerelclient.Apikey, apibase string) (*orgTarget, error) {
+	client, err := mackerelclient.NewClient(apikey.Value, apibase)
+	if err != nil {
+		return nil, err
+	}
+	return &orgTarget{
+		apikeySource: apikey.Source,
+		client:       client,
+		detectPermission: func() (string, error) {
+			return mackerelclient.DetectPermission(client)
+		},
+	}, nil
 }
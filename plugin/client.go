@@ -2,6 +2,7 @@ package plugin
 
 import (
 	"fmt"
+	"io"
 	"net/http"
 	"strings"
 )
@@ -35,3 +36,15 @@ func (c *client) get(url string) (*http.Response, error) {
 	}
 	return resp, nil
 }
+
+// opener opens the content of `url`
+type opener func(url string) (io.ReadCloser, error)
+
+// Open the content of `url` with client
+func openURL(url string) (io.ReadCloser, error) {
+	resp, err := (&client{}).get(url)
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
@@ -10,9 +10,14 @@ var CommandPlugin = cli.Command{
 	Usage: "Manage mackerel plugin",
 	Description: `
     Manage mackerel plugin.  For example, you can install a mackerel plugin and
-    check plugin by "mkr plugin install".
+    check plugin by "mkr plugin install", and list, upgrade or uninstall the installed plugins.
 `,
 	Subcommands: []cli.Command{
 		commandPluginInstall,
+		commandPluginList,
+		commandPluginUpgrade,
+		commandPluginUninstall,
+		commandPluginLock,
+		commandPluginInit,
 	},
 }
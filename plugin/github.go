@@ -2,8 +2,11 @@ package plugin
 
 import (
 	"context"
+	"io/ioutil"
 	"net/http"
 	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/google/go-github/github"
 	gitconfig "github.com/tcnksm/go-gitconfig"
@@ -12,10 +15,15 @@ import (
 
 // Get github client having github token.
 func getGithubClient(ctx context.Context) *github.Client {
+	return newGithubClient(ctx, getGithubToken())
+}
+
+// Create github client with the token. The client is not authenticated if the token is empty.
+func newGithubClient(ctx context.Context, token string) *github.Client {
 	var oauthClient *http.Client
-	if token := getGithubToken(); token != "" {
+	if token != "" {
 		ts := oauth2.StaticTokenSource(
-			&oauth2.Token{AccessToken: getGithubToken()},
+			&oauth2.Token{AccessToken: token},
 		)
 		oauthClient = oauth2.NewClient(ctx, ts)
 	}
@@ -31,3 +39,56 @@ func getGithubToken() string {
 	token, _ = gitconfig.GithubToken()
 	return token
 }
+
+// Get the password for the host from netrc file, which is $NETRC or ~/.netrc
+func getNetrcToken(host string) string {
+	fpath := os.Getenv("NETRC")
+	if fpath == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return ""
+		}
+		fpath = filepath.Join(home, ".netrc")
+	}
+	b, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return ""
+	}
+	return parseNetrc(string(b), host)
+}
+
+// Parse netrc content and returns the password of the machine, or the default.
+// The password of api.<host> is also looked up by <host>.
+func parseNetrc(content, host string) string {
+	hosts := []string{host}
+	if strings.HasPrefix(host, "api.") {
+		hosts = append(hosts, strings.TrimPrefix(host, "api."))
+	}
+	passwords := make(map[string]string)
+	var machine string
+	fields := strings.Fields(content)
+	for i := 0; i < len(fields); i++ {
+		switch fields[i] {
+		case "machine":
+			if i+1 < len(fields) {
+				i++
+				machine = fields[i]
+			}
+		case "default":
+			machine = ""
+		case "password":
+			if i+1 < len(fields) {
+				i++
+				if _, ok := passwords[machine]; !ok {
+					passwords[machine] = fields[i]
+				}
+			}
+		}
+	}
+	for _, h := range hosts {
+		if p, ok := passwords[h]; ok {
+			return p
+		}
+	}
+	return passwords[""]
+}
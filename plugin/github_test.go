@@ -88,3 +88,16 @@ func TestGetGithubToken(t *testing.T) {
 		os.Unsetenv("GIT_CONFIG")
 	}
 }
+
+func TestParseNetrc(t *testing.T) {
+	content := `machine github.example.com
+  login user1
+  password tokenForGHE
+machine github.com login user2 password tokenForGithub
+default login anonymous password tokenForDefault
+`
+	assert.Equal(t, "tokenForGHE", parseNetrc(content, "github.example.com"))
+	assert.Equal(t, "tokenForGithub", parseNetrc(content, "api.github.com"))
+	assert.Equal(t, "tokenForDefault", parseNetrc(content, "unknown.example.com"))
+	assert.Equal(t, "", parseNetrc("machine github.com login user", "github.com"))
+}
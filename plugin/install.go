@@ -9,6 +9,7 @@ import (
 	"path/filepath"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/mackerelio/mkr/logger"
 	"github.com/mholt/archiver"
@@ -31,7 +32,7 @@ var defaultPluginInstallLocation = func() string {
 var commandPluginInstall = cli.Command{
 	Name:      "install",
 	Usage:     "Install a plugin from github or plugin registry",
-	ArgsUsage: "[--prefix <prefix>] [--overwrite] [--upgrade] <install_target>",
+	ArgsUsage: "[--prefix <prefix>] [--overwrite] [--upgrade] [--github-token <token>] [--github-api-url <url>] [--gpg-key <key_file>] [--insecure-skip-verify] [--mirror <base_url>] (<install_target> | --from-file <lockfile>)",
 	Action:    doPluginInstall,
 	Flags: []cli.Flag{
 		cli.StringFlag{
@@ -46,6 +47,31 @@ var commandPluginInstall = cli.Command{
 			Name:  "upgrade",
 			Usage: "Upgrade a plugin command in a plugin directory only when a release_tag is modified",
 		},
+		cli.StringFlag{
+			Name:  "github-token",
+			Usage: "Github token to use Github API, which is required for private repositories",
+		},
+		cli.StringFlag{
+			Name:  "github-api-url",
+			Usage: "Github API URL for Github Enterprise, such as https://github.example.com/api/v3",
+		},
+		cli.StringFlag{
+			Name:  "gpg-key",
+			Usage: "Verify the signature of checksums.txt with the armored GPG public key file",
+		},
+		cli.BoolFlag{
+			Name:  "insecure-skip-verify",
+			Usage: "Install a plugin without verifying the checksum of the artifact",
+		},
+		cli.StringFlag{
+			Name:   "mirror",
+			EnvVar: "MKR_PLUGIN_MIRROR",
+			Usage:  "Base URL of the mirror which github.com release URLs are rewritten to",
+		},
+		cli.StringFlag{
+			Name:  "from-file",
+			Usage: "Install the plugins pinned in the lockfile generated by \"mkr plugin lock\"",
+		},
 	},
 	Description: `
     Install a mackerel plugin and a check plugin from github or plugin registry.
@@ -68,6 +94,29 @@ var commandPluginInstall = cli.Command{
     The installer uses Github API to find the latest release.  Please set a github token to
     GITHUB_TOKEN environment variable, or to github.token in .gitconfig.
     Otherwise, installation sometimes fails because of Github API Rate Limit.
+    The github token can be also specified by --github-token, or the password for
+    api.github.com (or github.com) in .netrc.
+
+    To install from a private repository, the installer downloads the artifact through
+    Github API with the github token when the artifact is not publicly available.
+    To install from Github Enterprise, specify --github-api-url. The installer always
+    downloads the artifact through Github API of Github Enterprise.
+
+    The installer verifies the SHA256 checksum of the artifact with checksums.txt released
+    with it, which is in the format of sha256sum output, and refuses the unverified artifact.
+    With --gpg-key, the installer also verifies checksums.txt with the detached GPG signature
+    checksums.txt.sig. Use --insecure-skip-verify to install a plugin without checksums.txt.
+
+    With --from-file, the installer installs exactly the versions of the plugins pinned in the
+    lockfile, and verifies the artifacts with the checksums in the lockfile instead of checksums.txt.
+    The plugins already installed with the same versions are skipped.
+
+    The installer honors HTTPS_PROXY, HTTP_PROXY and NO_PROXY environment variables.
+    With --mirror (or MKR_PLUGIN_MIRROR environment variable), the release URLs of github.com
+    such as https://github.com/<owner>/<repo>/releases/download/<release_tag>/<file> are rewritten
+    to <base_url>/<owner>/<repo>/releases/download/<release_tag>/<file>, and checksums.txt is also
+    downloaded from the mirror. Specify <owner>/<repo>@<release_tag> in air-gapped environments
+    since finding the latest release and the plugin registry require github.com.
 
     If you want to use the plugin installer by a server provisioning tool,
     we recommend you to specify <release_tag> explicitly.
@@ -87,20 +136,58 @@ var errSkipInstall = errors.New("skip installing for now")
 
 // main function for mkr plugin install
 func doPluginInstall(c *cli.Context) error {
+	if lockfilePath := c.String("from-file"); lockfilePath != "" {
+		return doPluginInstallFromFile(c, lockfilePath)
+	}
 	argInstallTarget := c.Args().First()
 	if argInstallTarget == "" {
 		return fmt.Errorf("Specify install target")
 	}
 
-	it, err := newInstallTargetFromString(argInstallTarget)
+	pluginDir, err := setupPluginDir(c.String("prefix"))
 	if err != nil {
-		return errors.Wrap(err, "Failed to install plugin while parsing install target")
+		return errors.Wrap(err, "Failed to install plugin while setup plugin directory")
 	}
+	return installPlugin(argInstallTarget, pluginDir, newInstallOption(c))
+}
 
-	pluginDir, err := setupPluginDir(c.String("prefix"))
+// installOption is the options to install a plugin
+type installOption struct {
+	overwrite          bool
+	upgrade            bool
+	githubToken        string
+	githubAPIURL       string
+	gpgKey             string
+	insecureSkipVerify bool
+	// sha256 is the checksum of the artifact pinned by the lockfile
+	sha256 string
+	mirror string
+}
+
+func newInstallOption(c *cli.Context) *installOption {
+	return &installOption{
+		overwrite:          c.Bool("overwrite"),
+		upgrade:            c.Bool("upgrade"),
+		githubToken:        c.String("github-token"),
+		githubAPIURL:       c.String("github-api-url"),
+		gpgKey:             c.String("gpg-key"),
+		insecureSkipVerify: c.Bool("insecure-skip-verify"),
+		mirror:             c.String("mirror"),
+	}
+}
+
+// Install a plugin to the plugin directory, and record it in the manifest
+func installPlugin(argInstallTarget, pluginDir string, opt *installOption) error {
+	it, err := newInstallTargetFromString(argInstallTarget)
 	if err != nil {
-		return errors.Wrap(err, "Failed to install plugin while setup plugin directory")
+		return errors.Wrap(err, "Failed to install plugin while parsing install target")
+	}
+	it.githubToken = opt.githubToken
+	if opt.githubAPIURL != "" {
+		it.apiGithubURL = strings.TrimSuffix(opt.githubAPIURL, "/")
+		it.useReleaseAPI = true
 	}
+	it.mirrorURL = opt.mirror
 
 	// Create a work directory for downloading and extracting an artifact
 	workdir, err := ioutil.TempDir(filepath.Join(pluginDir, "work"), "mkr-plugin-installer-")
@@ -125,8 +212,8 @@ func doPluginInstall(c *cli.Context) error {
 		}
 	}
 
-	overwrite := c.Bool("overwrite")
-	if isMetaDataStoreEnabled && c.Bool("upgrade") {
+	overwrite := opt.overwrite
+	if isMetaDataStoreEnabled && opt.upgrade {
 		releaseTag, err := meta.load("release_tag")
 		if err != nil {
 			return errors.Wrap(err, "Failed to load release_tag")
@@ -138,17 +225,34 @@ func doPluginInstall(c *cli.Context) error {
 		overwrite = true // force overwrite in upgrade
 	}
 
-	artifactFile, err := downloadPluginArtifact(downloadURL, workdir)
+	artifactFile, err := downloadPluginArtifact(it.open, downloadURL, workdir)
 	if err != nil {
 		return errors.Wrap(err, "Failed to install plugin while downloading an artifact")
 	}
-	err = installByArtifact(artifactFile, filepath.Join(pluginDir, "bin"), workdir, overwrite)
+	checksum, err := sha256File(artifactFile)
+	if err != nil {
+		return errors.Wrap(err, "Failed to install plugin while calculating the checksum")
+	}
+	if opt.sha256 != "" {
+		// the pinned checksum is trusted instead of checksums.txt
+		if checksum != opt.sha256 {
+			return fmt.Errorf("Failed to install plugin because of checksum mismatch for %s. expected: %s, actual: %s", downloadURL, opt.sha256, checksum)
+		}
+	} else if opt.insecureSkipVerify {
+		logger.Log("warning", "Skip verifying the artifact")
+	} else if err := verifyPluginArtifact(it.open, artifactFile, downloadURL, opt.gpgKey); err != nil {
+		return errors.Wrap(err, "Failed to install plugin while verifying an artifact (use --insecure-skip-verify to skip)")
+	}
+	files, err := installByArtifact(artifactFile, filepath.Join(pluginDir, "bin"), workdir, overwrite)
 	if err == nil {
 		if meta != nil {
 			if err := meta.store("release_tag", it.releaseTag); err != nil {
 				return errors.Wrap(err, "Failed to store release_tag")
 			}
 		}
+		if err := recordInstalledPlugin(pluginDir, it, opt, files, checksum); err != nil {
+			return errors.Wrap(err, "Failed to record the plugin in the manifest")
+		}
 	} else if err == errSkipInstall {
 		// do not update metadata
 	} else {
@@ -159,6 +263,30 @@ func doPluginInstall(c *cli.Context) error {
 	return nil
 }
 
+// Record the installed plugin in the manifest
+func recordInstalledPlugin(pluginDir string, it *installTarget, opt *installOption, files []string, checksum string) error {
+	m, err := loadManifest(pluginDir)
+	if err != nil {
+		return err
+	}
+	name, target := it.nameAndTarget()
+	var source string
+	if it.owner != "" && it.repo != "" {
+		source = it.owner + "/" + it.repo
+	}
+	m.put(&manifestPlugin{
+		Name:         name,
+		Target:       target,
+		Source:       source,
+		ReleaseTag:   it.releaseTag,
+		GithubAPIURL: opt.githubAPIURL,
+		SHA256:       checksum,
+		Files:        files,
+		InstalledAt:  time.Now().Format(time.RFC3339),
+	})
+	return m.save(pluginDir)
+}
+
 // Create a directory for plugin install
 func setupPluginDir(pluginDir string) (string, error) {
 	if pluginDir == "" {
@@ -181,15 +309,15 @@ func setupPluginDir(pluginDir string) (string, error) {
 
 // Download plugin artifact from `u`(URL) to `workdir`,
 // and returns downloaded filepath
-func downloadPluginArtifact(u, workdir string) (fpath string, err error) {
+func downloadPluginArtifact(open opener, u, workdir string) (fpath string, err error) {
 	logger.Log("", fmt.Sprintf("Downloading %s", u))
 
 	// Create request to download
-	resp, err := (&client{}).get(u)
+	body, err := open(u)
 	if err != nil {
 		return "", err
 	}
-	defer resp.Body.Close()
+	defer body.Close()
 
 	// fpath is filepath where artifact will be saved
 	fpath = filepath.Join(workdir, path.Base(u))
@@ -201,7 +329,7 @@ func downloadPluginArtifact(u, workdir string) (fpath string, err error) {
 	}
 	defer file.Close()
 
-	_, err = io.Copy(file, resp.Body)
+	_, err = io.Copy(file, body)
 	if err != nil {
 		return "", err
 	}
@@ -209,8 +337,8 @@ func downloadPluginArtifact(u, workdir string) (fpath string, err error) {
 	return fpath, nil
 }
 
-// Extract artifact and install plugin
-func installByArtifact(artifactFile, bindir, workdir string, overwrite bool) error {
+// Extract artifact and install plugin, and returns the names of installed files
+func installByArtifact(artifactFile, bindir, workdir string, overwrite bool) ([]string, error) {
 	var unarchiver archiver.Unarchiver
 	// unzip artifact to work directory
 	unarchiver = archiver.DefaultZip
@@ -219,11 +347,12 @@ func installByArtifact(artifactFile, bindir, workdir string, overwrite bool) err
 	}
 
 	if err := unarchiver.Unarchive(artifactFile, workdir); err != nil {
-		return err
+		return nil, err
 	}
 
 	// Look for plugin files recursively, and place those to binPath
-	return filepath.Walk(workdir, func(path string, info os.FileInfo, err error) error {
+	var installed []string
+	err := filepath.Walk(workdir, func(path string, info os.FileInfo, err error) error {
 		if err != nil {
 			return err
 		}
@@ -235,11 +364,15 @@ func installByArtifact(artifactFile, bindir, workdir string, overwrite bool) err
 		name := info.Name()
 		isExecutable := isWin || (info.Mode()&0111) != 0
 		if isExecutable && looksLikePlugin(name) {
-			return placePlugin(path, filepath.Join(bindir, name), overwrite)
+			if err := placePlugin(path, filepath.Join(bindir, name), overwrite); err != nil {
+				return err
+			}
+			installed = append(installed, name)
 		}
 		// `path` is a file but not plugin.
 		return nil
 	})
+	return installed, err
 }
 
 func looksLikePlugin(name string) bool {
@@ -258,3 +391,8 @@ func placePlugin(src, dest string, overwrite bool) error {
 	logger.Log("", fmt.Sprintf("Installing %s", dest))
 	return os.Rename(src, dest)
 }
+
+// DefaultInstallLocation returns the default location to install the plugins
+func DefaultInstallLocation() string {
+	return defaultPluginInstallLocation
+}
@@ -4,10 +4,14 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"io"
 	"net/url"
+	"path"
 	"regexp"
 	"runtime"
 	"strings"
+
+	"github.com/google/go-github/github"
 )
 
 type installTarget struct {
@@ -17,6 +21,14 @@ type installTarget struct {
 	releaseTag string
 	directURL  string
 
+	// githubToken is the token specified explicitly
+	githubToken string
+	// useReleaseAPI makes assets downloaded through Github API, which is required for Github Enterprise
+	useReleaseAPI bool
+	release       *github.RepositoryRelease
+	// mirrorURL is the base URL of the mirror which github.com release URLs are rewritten to
+	mirrorURL string
+
 	// fields for testing
 	rawGithubURL string
 	apiGithubURL string
@@ -25,6 +37,7 @@ type installTarget struct {
 const (
 	defaultRawGithubURL = "https://raw.githubusercontent.com"
 	defaultAPIGithubURL = "https://api.github.com"
+	defaultWebGithubURL = "https://github.com"
 )
 
 // the pattern of installTarget string
@@ -65,7 +78,7 @@ func newInstallTargetFromString(target string) (*installTarget, error) {
 // Make artifact's download URL
 func (it *installTarget) makeDownloadURL() (string, error) {
 	if it.directURL != "" {
-		return it.directURL, nil
+		return it.rewriteURL(it.directURL), nil
 	}
 
 	owner, repo, err := it.getOwnerAndRepo()
@@ -80,14 +93,23 @@ func (it *installTarget) makeDownloadURL() (string, error) {
 
 	filename := fmt.Sprintf("%s_%s_%s.zip", url.PathEscape(repo), runtime.GOOS, runtime.GOARCH)
 	downloadURL := fmt.Sprintf(
-		"https://github.com/%s/%s/releases/download/%s/%s",
+		"%s/%s/%s/releases/download/%s/%s",
+		it.getWebGithubURL(),
 		url.PathEscape(owner),
 		url.PathEscape(repo),
 		url.PathEscape(releaseTag),
 		filename,
 	)
 
-	return downloadURL, nil
+	return it.rewriteURL(downloadURL), nil
+}
+
+// Rewrite github.com release URL to the mirror
+func (it *installTarget) rewriteURL(u string) string {
+	if it.mirrorURL == "" || !strings.HasPrefix(u, defaultWebGithubURL+"/") {
+		return u
+	}
+	return strings.TrimSuffix(it.mirrorURL, "/") + strings.TrimPrefix(u, defaultWebGithubURL)
 }
 
 func (it *installTarget) getOwnerAndRepo() (string, string, error) {
@@ -137,8 +159,7 @@ func (it *installTarget) getReleaseTag(owner, repo string) (string, error) {
 
 	// Get latest release tag from Github API
 	ctx := context.Background()
-	client := getGithubClient(ctx)
-	client.BaseURL = it.getAPIGithubURL()
+	client := it.getGithubClient(ctx)
 
 	release, _, err := client.Repositories.GetLatestRelease(ctx, owner, repo)
 	if err != nil {
@@ -168,6 +189,98 @@ func (it *installTarget) getAPIGithubURL() *url.URL {
 	return apiURL
 }
 
+// Returns the name of the plugin and the install target without the release tag
+func (it *installTarget) nameAndTarget() (string, string) {
+	switch {
+	case it.directURL != "":
+		name := path.Base(it.directURL)
+		for _, ext := range []string{".zip", ".tar.gz", ".tgz"} {
+			name = strings.TrimSuffix(name, ext)
+		}
+		return strings.TrimSuffix(name, fmt.Sprintf("_%s_%s", runtime.GOOS, runtime.GOARCH)), it.directURL
+	case it.pluginName != "":
+		return it.pluginName, it.pluginName
+	default:
+		return it.repo, it.owner + "/" + it.repo
+	}
+}
+
+// Returns the base URL of Github web, which is derived from the API URL of Github Enterprise
+func (it *installTarget) getWebGithubURL() string {
+	if it.useReleaseAPI && it.apiGithubURL != "" {
+		return strings.TrimSuffix(strings.TrimSuffix(it.apiGithubURL, "/"), "/api/v3")
+	}
+	return defaultWebGithubURL
+}
+
+// Get github token which is specified explicitly, from environment variables, gitconfig file or netrc file
+func (it *installTarget) getGithubToken() string {
+	if it.githubToken != "" {
+		return it.githubToken
+	}
+	if token := getGithubToken(); token != "" {
+		return token
+	}
+	return getNetrcToken(it.getAPIGithubURL().Hostname())
+}
+
+func (it *installTarget) getGithubClient(ctx context.Context) *github.Client {
+	client := newGithubClient(ctx, it.getGithubToken())
+	client.BaseURL = it.getAPIGithubURL()
+	return client
+}
+
+// Open the content of `u`(URL). The release assets are downloaded through Github API
+// for Github Enterprise, or when the public URL is not available with a github token,
+// since the assets of private repositories are not public. The mirror is not fallen back to Github API.
+func (it *installTarget) open(u string) (io.ReadCloser, error) {
+	if it.directURL != "" || it.mirrorURL != "" || !it.useReleaseAPI {
+		rc, err := openURL(u)
+		if err == nil {
+			return rc, nil
+		}
+		if it.directURL != "" || it.mirrorURL != "" || it.getGithubToken() == "" {
+			return nil, err
+		}
+	}
+	return it.openReleaseAsset(path.Base(u))
+}
+
+// Open the release asset through Github API
+func (it *installTarget) openReleaseAsset(name string) (io.ReadCloser, error) {
+	ctx := context.Background()
+	ghClient := it.getGithubClient(ctx)
+	owner, repo, err := it.getOwnerAndRepo()
+	if err != nil {
+		return nil, err
+	}
+	if it.release == nil {
+		releaseTag, err := it.getReleaseTag(owner, repo)
+		if err != nil {
+			return nil, err
+		}
+		it.release, _, err = ghClient.Repositories.GetReleaseByTag(ctx, owner, repo, releaseTag)
+		if err != nil {
+			return nil, err
+		}
+	}
+	for _, asset := range it.release.Assets {
+		if asset.GetName() != name {
+			continue
+		}
+		rc, redirectURL, err := ghClient.Repositories.DownloadReleaseAsset(ctx, owner, repo, asset.GetID())
+		if err != nil {
+			return nil, err
+		}
+		if redirectURL != "" {
+			// the redirect URL is signed for the storage, so the token is not required
+			return openURL(redirectURL)
+		}
+		return rc, nil
+	}
+	return nil, fmt.Errorf("asset %s is not found in the release %s of %s/%s", name, it.release.GetTagName(), owner, repo)
+}
+
 // registryDef represents one plugin definition in plugin-registry
 // See Also: https://github.com/mackerelio/plugin-registry
 type registryDef struct {
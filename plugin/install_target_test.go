@@ -3,9 +3,12 @@ package plugin
 import (
 	"encoding/json"
 	"fmt"
+	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
 	"runtime"
+	"strings"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
@@ -132,6 +135,24 @@ func TestInstallTargetMakeDownloadURL(t *testing.T) {
 		)
 	}
 
+	{
+		// Make download URL for `<owner>/<repo>@<releaseTag>` with the mirror
+		it := &installTarget{
+			owner:      "mackerelio",
+			repo:       "mackerel-plugin-sample",
+			releaseTag: "v0.1.0",
+			mirrorURL:  "https://mirror.example.com/github/",
+		}
+		url, err := it.makeDownloadURL()
+		assert.NoError(t, err, "makeDownloadURL is successful")
+		assert.Equal(
+			t,
+			fmt.Sprintf("https://mirror.example.com/github/mackerelio/mackerel-plugin-sample/releases/download/v0.1.0/mackerel-plugin-sample_%s_%s.zip", runtime.GOOS, runtime.GOARCH),
+			url,
+			"Download URL is rewritten to the mirror",
+		)
+	}
+
 	{
 		// Make download URL for `<pluginName>@<releaseTag>`
 		mux := http.NewServeMux()
@@ -375,3 +396,131 @@ func TestInstallTargetGetAPIGithubURL(t *testing.T) {
 	it = &installTarget{apiGithubURL: "https://api.example.com"}
 	assert.Equal(t, "https://api.example.com/", it.getAPIGithubURL().String(), "Returns customized URL")
 }
+
+func TestInstallTargetOpen(t *testing.T) {
+	teardown := githubTestSetup()
+	defer teardown()
+	os.Setenv("GIT_CONFIG", "testdata/not_exists")
+	defer os.Unsetenv("GIT_CONFIG")
+	os.Setenv("NETRC", "testdata/not_exists")
+	defer os.Unsetenv("NETRC")
+
+	var authHeader string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/v3/repos/owner1/private-repo1/releases/tags/v0.1.0", func(w http.ResponseWriter, r *http.Request) {
+		authHeader = r.Header.Get("Authorization")
+		fmt.Fprint(w, `{"tag_name": "v0.1.0", "assets": [{"id": 1, "name": "private-repo1_linux_amd64.zip"}]}`)
+	})
+	mux.HandleFunc("/api/v3/repos/owner1/private-repo1/releases/assets/1", func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "application/octet-stream", r.Header.Get("Accept"))
+		fmt.Fprint(w, "artifact")
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	{
+		// Download through Github API of Github Enterprise
+		it := &installTarget{
+			owner:         "owner1",
+			repo:          "private-repo1",
+			releaseTag:    "v0.1.0",
+			githubToken:   "tokenFromFlag",
+			apiGithubURL:  ts.URL + "/api/v3",
+			useReleaseAPI: true,
+		}
+		u, err := it.makeDownloadURL()
+		assert.NoError(t, err)
+		assert.True(t, strings.HasPrefix(u, ts.URL+"/owner1/private-repo1/releases/download/v0.1.0/"), "Download URL is made for Github Enterprise")
+
+		rc, err := it.open(ts.URL + "/owner1/private-repo1/releases/download/v0.1.0/private-repo1_linux_amd64.zip")
+		assert.NoError(t, err, "asset is opened through Github API")
+		b, _ := ioutil.ReadAll(rc)
+		rc.Close()
+		assert.Equal(t, "artifact", string(b))
+		assert.Equal(t, "Bearer tokenFromFlag", authHeader, "token is included in request")
+
+		_, err = it.open(ts.URL + "/owner1/private-repo1/releases/download/v0.1.0/checksums.txt")
+		assert.EqualError(t, err, "asset checksums.txt is not found in the release v0.1.0 of owner1/private-repo1")
+	}
+
+	{
+		// Fall back to Github API when the public URL is not available
+		it := &installTarget{
+			owner:        "owner1",
+			repo:         "private-repo1",
+			releaseTag:   "v0.1.0",
+			githubToken:  "tokenFromFlag",
+			apiGithubURL: ts.URL + "/api/v3",
+		}
+		rc, err := it.open(ts.URL + "/not_found/private-repo1_linux_amd64.zip")
+		assert.NoError(t, err, "asset is opened through Github API")
+		rc.Close()
+
+		// Without token
+		it = &installTarget{
+			owner:        "owner1",
+			repo:         "private-repo1",
+			releaseTag:   "v0.1.0",
+			apiGithubURL: ts.URL + "/api/v3",
+		}
+		_, err = it.open(ts.URL + "/not_found/private-repo1_linux_amd64.zip")
+		assert.Contains(t, err.Error(), "http response not OK. code: 404,")
+	}
+}
+
+func TestInstallTargetRewriteURL(t *testing.T) {
+	testCases := []struct {
+		mirror, url, expect string
+	}{
+		{
+			"",
+			"https://github.com/owner1/repo1/releases/download/v0.1.0/repo1_linux_amd64.zip",
+			"https://github.com/owner1/repo1/releases/download/v0.1.0/repo1_linux_amd64.zip",
+		},
+		{
+			"https://mirror.example.com",
+			"https://github.com/owner1/repo1/releases/download/v0.1.0/repo1_linux_amd64.zip",
+			"https://mirror.example.com/owner1/repo1/releases/download/v0.1.0/repo1_linux_amd64.zip",
+		},
+		{
+			"https://mirror.example.com/github/",
+			"https://github.com/owner1/repo1/releases/download/v0.1.0/checksums.txt",
+			"https://mirror.example.com/github/owner1/repo1/releases/download/v0.1.0/checksums.txt",
+		},
+		{
+			"https://mirror.example.com",
+			"https://example.com/repo1_linux_amd64.zip",
+			"https://example.com/repo1_linux_amd64.zip",
+		},
+		{
+			"https://mirror.example.com",
+			"https://github.company.example.com/owner1/repo1/releases/download/v0.1.0/repo1_linux_amd64.zip",
+			"https://github.company.example.com/owner1/repo1/releases/download/v0.1.0/repo1_linux_amd64.zip",
+		},
+	}
+	for _, tc := range testCases {
+		it := &installTarget{mirrorURL: tc.mirror}
+		assert.Equal(t, tc.expect, it.rewriteURL(tc.url))
+	}
+}
+
+func TestInstallTargetNameAndTarget(t *testing.T) {
+	testCases := []struct {
+		target, name, installTarget string
+	}{
+		{"mackerel-plugin-sample@v0.0.1", "mackerel-plugin-sample", "mackerel-plugin-sample"},
+		{"mackerelio/mackerel-plugin-sample@v0.0.1", "mackerel-plugin-sample", "mackerelio/mackerel-plugin-sample"},
+		{
+			fmt.Sprintf("https://example.com/mackerel-plugin-sample_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH),
+			"mackerel-plugin-sample",
+			fmt.Sprintf("https://example.com/mackerel-plugin-sample_%s_%s.tar.gz", runtime.GOOS, runtime.GOARCH),
+		},
+	}
+	for _, tc := range testCases {
+		it, err := newInstallTargetFromString(tc.target)
+		assert.NoError(t, err)
+		name, installTarget := it.nameAndTarget()
+		assert.Equal(t, tc.name, name)
+		assert.Equal(t, tc.installTarget, installTarget)
+	}
+}
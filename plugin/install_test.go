@@ -83,7 +83,7 @@ func TestDownloadPluginArtifact(t *testing.T) {
 		tmpd := tempd(t)
 		defer os.RemoveAll(tmpd)
 
-		fpath, err := downloadPluginArtifact(ts.URL+"/not_found.zip", tmpd)
+		fpath, err := downloadPluginArtifact(openURL, ts.URL+"/not_found.zip", tmpd)
 		assert.Equal(t, "", fpath, "fpath is empty")
 		assert.Contains(t, err.Error(), "http response not OK. code: 404,", "Returns correct err")
 	})
@@ -92,7 +92,7 @@ func TestDownloadPluginArtifact(t *testing.T) {
 		tmpd := tempd(t)
 		defer os.RemoveAll(tmpd)
 
-		fpath, err := downloadPluginArtifact(ts.URL+"/mackerel-plugin-sample_linux_amd64.zip", tmpd)
+		fpath, err := downloadPluginArtifact(openURL, ts.URL+"/mackerel-plugin-sample_linux_amd64.zip", tmpd)
 		assert.Equal(t, filepath.Join(tmpd, "/mackerel-plugin-sample_linux_amd64.zip"), fpath, "Returns fpath correctly")
 
 		_, err = os.Stat(fpath)
@@ -112,7 +112,7 @@ func TestInstallByArtifact(t *testing.T) {
 			workdir := tempd(t)
 			defer os.RemoveAll(workdir)
 
-			err := installByArtifact("testdata/mackerel-plugin-sample_linux_amd64.zip", bindir, workdir, false)
+			_, err := installByArtifact("testdata/mackerel-plugin-sample_linux_amd64.zip", bindir, workdir, false)
 			assert.Nil(t, err, "installByArtifact finished successfully")
 
 			fi, err := os.Stat(installedPath)
@@ -131,7 +131,7 @@ func TestInstallByArtifact(t *testing.T) {
 		t.Run("Install same name plugin, but it is skipped", func(t *testing.T) {
 			workdir := tempd(t)
 			defer os.RemoveAll(workdir)
-			err := installByArtifact("testdata/mackerel-plugin-sample-duplicate_linux_amd64.zip", bindir, workdir, false)
+			_, err := installByArtifact("testdata/mackerel-plugin-sample-duplicate_linux_amd64.zip", bindir, workdir, false)
 			assert.Equal(t, err, errSkipInstall, "installByArtifact finished successfully even if same name plugin exists")
 
 			_, err = os.Stat(installedPath)
@@ -147,7 +147,7 @@ func TestInstallByArtifact(t *testing.T) {
 		t.Run("Install same name plugin with overwrite option", func(t *testing.T) {
 			workdir := tempd(t)
 			defer os.RemoveAll(workdir)
-			err := installByArtifact("testdata/mackerel-plugin-sample-duplicate_linux_amd64.zip", bindir, workdir, true)
+			_, err := installByArtifact("testdata/mackerel-plugin-sample-duplicate_linux_amd64.zip", bindir, workdir, true)
 			assert.Nil(t, err, "installByArtifact finished successfully")
 			assertEqualFileContent(
 				t,
@@ -164,7 +164,7 @@ func TestInstallByArtifact(t *testing.T) {
 		workdir := tempd(t)
 		defer os.RemoveAll(workdir)
 
-		err := installByArtifact("testdata/mackerel-plugin-sample_linux_amd64.tar.gz", bindir, workdir, false)
+		_, err := installByArtifact("testdata/mackerel-plugin-sample_linux_amd64.tar.gz", bindir, workdir, false)
 		assert.Nil(t, err, "installByArtifact finished successfully")
 
 		installedPath := filepath.Join(bindir, "mackerel-plugin-sample")
@@ -188,7 +188,7 @@ func TestInstallByArtifact(t *testing.T) {
 		workdir := tempd(t)
 		defer os.RemoveAll(workdir)
 
-		installByArtifact("testdata/mackerel-plugin-sample-multi_darwin_386.zip", bindir, workdir, false)
+		_, _ = installByArtifact("testdata/mackerel-plugin-sample-multi_darwin_386.zip", bindir, workdir, false)
 
 		// check-sample, mackerel-plugin-sample-multi-1 and plugins/mackerel-plugin-sample-multi-2
 		// are installed.  But followings are not installed
@@ -0,0 +1,50 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var commandPluginList = cli.Command{
+	Name:      "list",
+	Usage:     "List plugins installed by the installer",
+	ArgsUsage: "[--prefix <prefix>]",
+	Action:    doPluginList,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "prefix",
+			Usage: fmt.Sprintf("Plugin install location. The default is %s", defaultPluginInstallLocation),
+		},
+	},
+	Description: `
+    List the plugins recorded in the manifest of the plugin directory, which is updated
+    by "mkr plugin install". The plugins installed before the manifest is introduced are not listed.
+`,
+}
+
+func doPluginList(c *cli.Context) error {
+	pluginDir := c.String("prefix")
+	if pluginDir == "" {
+		pluginDir = defaultPluginInstallLocation
+	}
+	m, err := loadManifest(pluginDir)
+	if err != nil {
+		return errors.Wrap(err, "Failed to load the manifest")
+	}
+	return printPlugins(os.Stdout, m)
+}
+
+func printPlugins(w io.Writer, m *manifest) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "NAME\tRELEASE_TAG\tTARGET\tFILES\tINSTALLED_AT")
+	for _, p := range m.Plugins {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", p.Name, p.ReleaseTag, p.Target, strings.Join(p.Files, ","), p.InstalledAt)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,167 @@
+package plugin
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+const defaultLockfilePath = "plugins.lock"
+
+var commandPluginLock = cli.Command{
+	Name:      "lock",
+	Usage:     "Generate a lockfile of the installed plugins",
+	ArgsUsage: "[--prefix <prefix>] [--file-path | -F <lockfile>]",
+	Action:    doPluginLock,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "prefix",
+			Usage: fmt.Sprintf("Plugin install location. The default is %s", defaultPluginInstallLocation),
+		},
+		cli.StringFlag{
+			Name:  "file-path, F",
+			Value: defaultLockfilePath,
+			Usage: "Filename to write the lockfile",
+		},
+	},
+	Description: `
+    Generate a lockfile which pins the names, the versions and the checksums of the plugins
+    in the manifest of the plugin directory. The plugins in the lockfile are installed by
+    "mkr plugin install --from-file <lockfile>" to provision many hosts reproducibly.
+`,
+}
+
+// lockfile pins the plugins to install
+type lockfile struct {
+	Plugins []*lockedPlugin `json:"plugins"`
+}
+
+type lockedPlugin struct {
+	Name         string `json:"name"`
+	Target       string `json:"target"`
+	ReleaseTag   string `json:"releaseTag,omitempty"`
+	GithubAPIURL string `json:"githubApiUrl,omitempty"`
+	SHA256       string `json:"sha256"`
+}
+
+// Returns the install target with the release tag
+func (p *lockedPlugin) installTarget() string {
+	if p.ReleaseTag == "" {
+		return p.Target
+	}
+	return p.Target + "@" + p.ReleaseTag
+}
+
+// Make the lockfile of the plugins in the manifest
+func makeLockfile(m *manifest) (*lockfile, error) {
+	lock := &lockfile{Plugins: []*lockedPlugin{}}
+	for _, p := range m.Plugins {
+		if p.SHA256 == "" {
+			return nil, fmt.Errorf("the checksum of %s is not recorded. Please install it again", p.Name)
+		}
+		lock.Plugins = append(lock.Plugins, &lockedPlugin{
+			Name:         p.Name,
+			Target:       p.Target,
+			ReleaseTag:   p.ReleaseTag,
+			GithubAPIURL: p.GithubAPIURL,
+			SHA256:       p.SHA256,
+		})
+	}
+	return lock, nil
+}
+
+func loadLockfile(fpath string) (*lockfile, error) {
+	b, err := ioutil.ReadFile(fpath)
+	if err != nil {
+		return nil, err
+	}
+	var lock lockfile
+	if err := json.Unmarshal(b, &lock); err != nil {
+		return nil, err
+	}
+	for _, p := range lock.Plugins {
+		if p.Target == "" {
+			return nil, fmt.Errorf("the target of %s is not specified", p.Name)
+		}
+		if !sha256Reg.MatchString(p.SHA256) {
+			return nil, fmt.Errorf("the sha256 of %s is invalid: %q", p.Name, p.SHA256)
+		}
+	}
+	return &lock, nil
+}
+
+func doPluginLock(c *cli.Context) error {
+	pluginDir := c.String("prefix")
+	if pluginDir == "" {
+		pluginDir = defaultPluginInstallLocation
+	}
+	m, err := loadManifest(pluginDir)
+	if err != nil {
+		return errors.Wrap(err, "Failed to load the manifest")
+	}
+	lock, err := makeLockfile(m)
+	if err != nil {
+		return errors.Wrap(err, "Failed to generate the lockfile")
+	}
+	b, err := json.MarshalIndent(lock, "", "  ")
+	if err != nil {
+		return err
+	}
+	fpath := c.String("file-path")
+	if err := ioutil.WriteFile(fpath, append(b, '\n'), 0644); err != nil {
+		return errors.Wrap(err, "Failed to write the lockfile")
+	}
+	logger.Log("", fmt.Sprintf("Successfully locked %d plugins to %s", len(lock.Plugins), fpath))
+	return nil
+}
+
+// Install the plugins pinned in the lockfile
+func doPluginInstallFromFile(c *cli.Context, lockfilePath string) error {
+	if c.Args().Present() {
+		return fmt.Errorf("Specify either install target or --from-file")
+	}
+	lock, err := loadLockfile(lockfilePath)
+	if err != nil {
+		return errors.Wrap(err, "Failed to load the lockfile")
+	}
+	pluginDir, err := setupPluginDir(c.String("prefix"))
+	if err != nil {
+		return errors.Wrap(err, "Failed to install plugin while setup plugin directory")
+	}
+	m, err := loadManifest(pluginDir)
+	if err != nil {
+		return errors.Wrap(err, "Failed to load the manifest")
+	}
+	for _, p := range lock.Plugins {
+		if installed := m.find(p.Name); installed != nil && installed.SHA256 == p.SHA256 && pluginFilesExist(pluginDir, installed) {
+			logger.Log("", fmt.Sprintf("%s is already installed. Skip installing for now", p.installTarget()))
+			continue
+		}
+		opt := newInstallOption(c)
+		opt.overwrite = true // install exactly the pinned version
+		opt.upgrade = false
+		if p.GithubAPIURL != "" {
+			opt.githubAPIURL = p.GithubAPIURL
+		}
+		opt.sha256 = p.SHA256
+		if err := installPlugin(p.installTarget(), pluginDir, opt); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func pluginFilesExist(pluginDir string, p *manifestPlugin) bool {
+	for _, f := range p.Files {
+		if _, err := os.Stat(filepath.Join(pluginDir, "bin", f)); err != nil {
+			return false
+		}
+	}
+	return len(p.Files) > 0
+}
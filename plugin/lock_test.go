@@ -0,0 +1,94 @@
+package plugin
+
+import (
+	"encoding/json"
+	"flag"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/urfave/cli"
+)
+
+func newPluginInstallFromFileContext(lockfilePath, prefix string) *cli.Context {
+	fs := flag.NewFlagSet("name", flag.ContinueOnError)
+	for _, f := range commandPluginInstall.Flags {
+		f.Apply(fs)
+	}
+	fs.Parse([]string{"-prefix=" + prefix, "-from-file=" + lockfilePath})
+	return cli.NewContext(nil, fs, nil)
+}
+
+func TestMakeLockfile(t *testing.T) {
+	_, err := makeLockfile(&manifest{Plugins: []*manifestPlugin{{Name: "mackerel-plugin-sample"}}})
+	assert.EqualError(t, err, "the checksum of mackerel-plugin-sample is not recorded. Please install it again")
+
+	lock, err := makeLockfile(&manifest{Plugins: []*manifestPlugin{{
+		Name:       "mackerel-plugin-sample",
+		Target:     "mackerelio/mackerel-plugin-sample",
+		Source:     "mackerelio/mackerel-plugin-sample",
+		ReleaseTag: "v0.0.1",
+		SHA256:     "c9a7977f3f547714a718bdaddf2a42ef9b2767b7331efec3ec6e368b88f062c4",
+		Files:      []string{"mackerel-plugin-sample"},
+	}}})
+	assert.NoError(t, err)
+	assert.Equal(t, []*lockedPlugin{{
+		Name:       "mackerel-plugin-sample",
+		Target:     "mackerelio/mackerel-plugin-sample",
+		ReleaseTag: "v0.0.1",
+		SHA256:     "c9a7977f3f547714a718bdaddf2a42ef9b2767b7331efec3ec6e368b88f062c4",
+	}}, lock.Plugins)
+	assert.Equal(t, "mackerelio/mackerel-plugin-sample@v0.0.1", lock.Plugins[0].installTarget())
+}
+
+func TestDoPluginInstallFromFile(t *testing.T) {
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer ts.Close()
+	tmpd := tempd(t)
+	defer os.RemoveAll(tmpd)
+
+	// Generate the lockfile from the installed plugin
+	src := filepath.Join(tmpd, "src")
+	err := doPluginInstall(newPluginInstallContext(ts.URL+"/mackerel-plugin-sample_linux_amd64.zip", src, false))
+	assert.NoError(t, err)
+	m, err := loadManifest(src)
+	assert.NoError(t, err)
+	lock, err := makeLockfile(m)
+	assert.NoError(t, err)
+	b, _ := json.Marshal(lock)
+	lockfilePath := filepath.Join(tmpd, "plugins.lock")
+	assert.NoError(t, ioutil.WriteFile(lockfilePath, b, 0644))
+
+	// Install from the lockfile into another directory
+	dest := filepath.Join(tmpd, "dest")
+	err = doPluginInstall(newPluginInstallFromFileContext(lockfilePath, dest))
+	assert.NoError(t, err, "plugins are installed from the lockfile")
+	assertEqualFileContent(t,
+		filepath.Join(dest, "bin", "mackerel-plugin-sample"),
+		"testdata/mackerel-plugin-sample_linux_amd64/mackerel-plugin-sample",
+		"plugin is installed from the lockfile",
+	)
+	installed, _ := loadManifest(dest)
+	assert.Equal(t, m.Plugins[0].SHA256, installed.Plugins[0].SHA256)
+
+	// Install again, which is skipped
+	err = doPluginInstall(newPluginInstallFromFileContext(lockfilePath, dest))
+	assert.NoError(t, err, "installing the same versions is skipped")
+
+	// Checksum mismatch
+	lock.Plugins[0].SHA256 = strings.Repeat("0", 64)
+	b, _ = json.Marshal(lock)
+	assert.NoError(t, ioutil.WriteFile(lockfilePath, b, 0644))
+	err = doPluginInstall(newPluginInstallFromFileContext(lockfilePath, filepath.Join(tmpd, "mismatch")))
+	assert.Contains(t, err.Error(), "checksum mismatch")
+
+	// Invalid lockfile
+	assert.NoError(t, ioutil.WriteFile(lockfilePath, []byte(`{"plugins": [{"name": "sample", "target": "sample", "sha256": "xxx"}]}`), 0644))
+	err = doPluginInstall(newPluginInstallFromFileContext(lockfilePath, dest))
+	assert.Contains(t, err.Error(), `the sha256 of sample is invalid: "xxx"`)
+}
@@ -0,0 +1,100 @@
+package plugin
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+const manifestFileName = "manifest.json"
+
+// manifest records the plugins installed by the installer under the plugin directory
+type manifest struct {
+	Plugins []*manifestPlugin `json:"plugins"`
+}
+
+type manifestPlugin struct {
+	Name string `json:"name"`
+	// Target is the install target without the release tag
+	Target string `json:"target"`
+	// Source is <owner>/<repo> of the plugin, which is empty if the plugin is installed by URL
+	Source       string `json:"source,omitempty"`
+	ReleaseTag   string `json:"releaseTag,omitempty"`
+	GithubAPIURL string `json:"githubApiUrl,omitempty"`
+	// SHA256 is the checksum of the artifact
+	SHA256      string   `json:"sha256,omitempty"`
+	Files       []string `json:"files"`
+	InstalledAt string   `json:"installedAt"`
+}
+
+// Load the manifest in the plugin directory. The manifest is empty if not exists.
+func loadManifest(pluginDir string) (*manifest, error) {
+	b, err := ioutil.ReadFile(filepath.Join(pluginDir, manifestFileName))
+	if os.IsNotExist(err) {
+		return &manifest{Plugins: []*manifestPlugin{}}, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var m manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, err
+	}
+	return &m, nil
+}
+
+// Save the manifest in the plugin directory atomically
+func (m *manifest) save(pluginDir string) error {
+	sort.Slice(m.Plugins, func(i, j int) bool { return m.Plugins[i].Name < m.Plugins[j].Name })
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	tmpf, err := ioutil.TempFile(pluginDir, "tmp-manifest-")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpf.Name())
+	if _, err := tmpf.Write(append(b, '\n')); err != nil {
+		tmpf.Close()
+		return err
+	}
+	if err := tmpf.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmpf.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmpf.Name(), filepath.Join(pluginDir, manifestFileName))
+}
+
+func (m *manifest) find(name string) *manifestPlugin {
+	for _, p := range m.Plugins {
+		if p.Name == name {
+			return p
+		}
+	}
+	return nil
+}
+
+// Add the plugin, or replace the plugin of the same name
+func (m *manifest) put(plugin *manifestPlugin) {
+	for i, p := range m.Plugins {
+		if p.Name == plugin.Name {
+			m.Plugins[i] = plugin
+			return
+		}
+	}
+	m.Plugins = append(m.Plugins, plugin)
+}
+
+func (m *manifest) remove(name string) {
+	plugins := []*manifestPlugin{}
+	for _, p := range m.Plugins {
+		if p.Name != name {
+			plugins = append(plugins, p)
+		}
+	}
+	m.Plugins = plugins
+}
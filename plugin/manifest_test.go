@@ -0,0 +1,77 @@
+package plugin
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestManifest(t *testing.T) {
+	tmpd := tempd(t)
+	defer os.RemoveAll(tmpd)
+
+	m, err := loadManifest(tmpd)
+	assert.Nil(t, err, "the manifest is empty if not exists")
+	assert.Equal(t, []*manifestPlugin{}, m.Plugins)
+
+	m.put(&manifestPlugin{Name: "mackerel-plugin-sample", ReleaseTag: "v0.0.1"})
+	m.put(&manifestPlugin{Name: "check-sample", ReleaseTag: "v0.1.0"})
+	m.put(&manifestPlugin{Name: "mackerel-plugin-sample", ReleaseTag: "v0.0.2"})
+	assert.Nil(t, m.save(tmpd))
+
+	m, err = loadManifest(tmpd)
+	assert.Nil(t, err)
+	assert.Equal(t, []*manifestPlugin{
+		{Name: "check-sample", ReleaseTag: "v0.1.0"},
+		{Name: "mackerel-plugin-sample", ReleaseTag: "v0.0.2"},
+	}, m.Plugins, "the plugins are sorted by name and replaced by the same name")
+	assert.Equal(t, "v0.1.0", m.find("check-sample").ReleaseTag)
+	assert.Nil(t, m.find("not-found"))
+
+	m.remove("check-sample")
+	assert.Equal(t, []*manifestPlugin{{Name: "mackerel-plugin-sample", ReleaseTag: "v0.0.2"}}, m.Plugins)
+}
+
+func TestPluginLifecycle(t *testing.T) {
+	ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+	defer ts.Close()
+	tmpd := tempd(t)
+	defer os.RemoveAll(tmpd)
+
+	err := doPluginInstall(newPluginInstallContext(ts.URL+"/mackerel-plugin-sample_linux_amd64.zip", tmpd, false))
+	assert.Nil(t, err, "sample plugin is succesfully installed")
+
+	// the suffix of the platform is trimmed from the name
+	name := strings.TrimSuffix("mackerel-plugin-sample_linux_amd64", fmt.Sprintf("_%s_%s", runtime.GOOS, runtime.GOARCH))
+	m, err := loadManifest(tmpd)
+	assert.Nil(t, err)
+	if assert.Len(t, m.Plugins, 1) {
+		p := m.Plugins[0]
+		assert.Equal(t, name, p.Name)
+		assert.Equal(t, ts.URL+"/mackerel-plugin-sample_linux_amd64.zip", p.Target)
+		assert.Equal(t, "", p.Source)
+		assert.Equal(t, []string{"mackerel-plugin-sample"}, p.Files)
+	}
+
+	buf := &bytes.Buffer{}
+	assert.Nil(t, printPlugins(buf, m))
+	assert.Contains(t, buf.String(), name)
+
+	err = uninstallPlugin("not-found", tmpd)
+	assert.EqualError(t, err, "plugin not-found is not found in the manifest")
+
+	err = uninstallPlugin(name, tmpd)
+	assert.Nil(t, err, "sample plugin is successfully uninstalled")
+	_, err = os.Stat(filepath.Join(tmpd, "bin", "mackerel-plugin-sample"))
+	assert.True(t, os.IsNotExist(err), "plugin file is removed")
+	m, _ = loadManifest(tmpd)
+	assert.Len(t, m.Plugins, 0, "plugin is removed from the manifest")
+}
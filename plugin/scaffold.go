@@ -0,0 +1,179 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"text/template"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var commandPluginInit = cli.Command{
+	Name:      "init",
+	Usage:     "Create a new plugin project",
+	ArgsUsage: "[--lang go|sh] [--module <module>] [--dir <dir>] <name>",
+	Action:    doPluginInit,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "lang",
+			Value: "go",
+			Usage: "Language of the plugin: 'go' or 'sh'",
+		},
+		cli.StringFlag{
+			Name:  "module",
+			Usage: "Go module path of the plugin, such as github.com/<owner>/<name>. The default is <name>",
+		},
+		cli.StringFlag{
+			Name:  "dir",
+			Usage: "Directory to create the project. The default is ./<name>",
+		},
+	},
+	Description: `
+    Create a new plugin project which can be installed by "mkr plugin install".
+    <name> should start with "mackerel-plugin-" for a metric plugin, or "check-" for a check plugin.
+    The project has the plugin (main.go using go-mackerel-plugin or checkers for go, or a shell script for sh),
+    Makefile to build the artifacts with checksums.txt, and the release workflow of GitHub Actions.
+    Example: mkr plugin init mackerel-plugin-sample --module github.com/mackerelio/mackerel-plugin-sample
+`,
+}
+
+var (
+	pluginNameReg = regexp.MustCompile(`^(?:mackerel-plugin|check)-[-a-zA-Z0-9_]+$`)
+	wordSeparator = regexp.MustCompile(`[-_]+`)
+)
+
+// scaffold is the data of the plugin project
+type scaffold struct {
+	Name   string
+	Lang   string
+	Module string
+	// Key is the name without the prefix, used for the metric key prefix or the check name
+	Key   string
+	Type  string
+	Check bool
+}
+
+func newScaffold(name, lang, module string) (*scaffold, error) {
+	if !pluginNameReg.MatchString(name) {
+		return nil, fmt.Errorf("plugin name should start with \"mackerel-plugin-\" or \"check-\": %s", name)
+	}
+	if lang != "go" && lang != "sh" {
+		return nil, fmt.Errorf("lang should be 'go' or 'sh': %s", lang)
+	}
+	if module == "" {
+		module = name
+	}
+	check := strings.HasPrefix(name, "check-")
+	key := strings.TrimPrefix(strings.TrimPrefix(name, "mackerel-plugin-"), "check-")
+	return &scaffold{
+		Name:   name,
+		Lang:   lang,
+		Module: module,
+		Key:    key,
+		Type:   typeName(key) + "Plugin",
+		Check:  check,
+	}, nil
+}
+
+// Make a Go type name from the key such as "sample-key" to "SampleKey"
+func typeName(key string) string {
+	var b strings.Builder
+	for _, w := range wordSeparator.Split(key, -1) {
+		if w != "" {
+			b.WriteString(strings.ToUpper(w[:1]) + w[1:])
+		}
+	}
+	return b.String()
+}
+
+type scaffoldFile struct {
+	path     string
+	template string
+	mode     os.FileMode
+}
+
+func (s *scaffold) files() []scaffoldFile {
+	workflow := scaffoldFile{filepath.Join(".github", "workflows", "release.yml"), releaseWorkflowTemplate, 0644}
+	readme := scaffoldFile{"README.md", readmeTemplate, 0644}
+	if s.Lang == "sh" {
+		script := shMetricPluginTemplate
+		if s.Check {
+			script = shCheckPluginTemplate
+		}
+		return []scaffoldFile{
+			{s.Name, script, 0755},
+			{"Makefile", shMakefileTemplate, 0644},
+			{".gitignore", shGitignoreTemplate, 0644},
+			workflow,
+			readme,
+		}
+	}
+	mainGo := goMetricPluginTemplate
+	if s.Check {
+		mainGo = goCheckPluginTemplate
+	}
+	return []scaffoldFile{
+		{"main.go", mainGo, 0644},
+		{"go.mod", goModTemplate, 0644},
+		{"Makefile", goMakefileTemplate, 0644},
+		{".gitignore", goGitignoreTemplate, 0644},
+		workflow,
+		readme,
+	}
+}
+
+// Render the files of the project into the directory, which should not have the files
+func (s *scaffold) render(dir string) error {
+	files := s.files()
+	for _, f := range files {
+		if _, err := os.Stat(filepath.Join(dir, f.path)); err == nil {
+			return fmt.Errorf("%s already exists", filepath.Join(dir, f.path))
+		}
+	}
+	for _, f := range files {
+		fpath := filepath.Join(dir, f.path)
+		if err := os.MkdirAll(filepath.Dir(fpath), 0755); err != nil {
+			return err
+		}
+		tmpl, err := template.New(f.path).Delims("{%", "%}").Parse(f.template)
+		if err != nil {
+			return err
+		}
+		file, err := os.OpenFile(fpath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, f.mode)
+		if err != nil {
+			return err
+		}
+		err = tmpl.Execute(file, s)
+		file.Close()
+		if err != nil {
+			return err
+		}
+		logger.Log("create", fpath)
+	}
+	return nil
+}
+
+func doPluginInit(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return fmt.Errorf("Specify a plugin name")
+	}
+	s, err := newScaffold(name, c.String("lang"), c.String("module"))
+	if err != nil {
+		return errors.Wrap(err, "Failed to create plugin project")
+	}
+	dir := c.String("dir")
+	if dir == "" {
+		dir = name
+	}
+	if err := s.render(dir); err != nil {
+		return errors.Wrap(err, "Failed to create plugin project")
+	}
+	logger.Log("", fmt.Sprintf("Successfully created %s in %s", name, dir))
+	return nil
+}
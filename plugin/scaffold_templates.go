@@ -0,0 +1,257 @@
+package plugin
+
+// The templates of the plugin project, whose delimiters are {% and %}
+// not to conflict with the expressions of GitHub Actions.
+
+const goMetricPluginTemplate = `package main
+
+import (
+	"flag"
+	"strings"
+
+	mp "github.com/mackerelio/go-mackerel-plugin"
+)
+
+// {%.Type%} is a metric plugin of {%.Name%}
+type {%.Type%} struct {
+	Prefix string
+}
+
+// MetricKeyPrefix returns the prefix of the metric keys
+func (p *{%.Type%}) MetricKeyPrefix() string {
+	if p.Prefix == "" {
+		p.Prefix = "{%.Key%}"
+	}
+	return p.Prefix
+}
+
+// GraphDefinition returns the definitions of the graphs
+func (p *{%.Type%}) GraphDefinition() map[string]mp.Graphs {
+	labelPrefix := strings.Title(p.MetricKeyPrefix())
+	return map[string]mp.Graphs{
+		"": {
+			Label: labelPrefix,
+			Unit:  "integer",
+			Metrics: []mp.Metrics{
+				{Name: "value", Label: "Value"},
+			},
+		},
+	}
+}
+
+// FetchMetrics fetches the metrics
+func (p *{%.Type%}) FetchMetrics() (map[string]float64, error) {
+	// TODO: fetch the metrics
+	return map[string]float64{"value": 1}, nil
+}
+
+func main() {
+	optPrefix := flag.String("metric-key-prefix", "", "Metric key prefix")
+	optTempfile := flag.String("tempfile", "", "Temp file name")
+	flag.Parse()
+
+	plugin := mp.NewMackerelPlugin(&{%.Type%}{Prefix: *optPrefix})
+	plugin.Tempfile = *optTempfile
+	plugin.Run()
+}
+`
+
+const goCheckPluginTemplate = `package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/mackerelio/checkers"
+)
+
+func main() {
+	ckr := run(os.Args[1:])
+	ckr.Name = "{%.Key%}"
+	ckr.Exit()
+}
+
+func run(args []string) *checkers.Checker {
+	fs := flag.NewFlagSet("{%.Name%}", flag.ExitOnError)
+	warning := fs.Float64("warning", 80, "Warning threshold")
+	critical := fs.Float64("critical", 90, "Critical threshold")
+	fs.Parse(args)
+
+	// TODO: measure the value to check
+	value := 0.0
+
+	msg := fmt.Sprintf("value is %f", value)
+	switch {
+	case value >= *critical:
+		return checkers.Critical(msg)
+	case value >= *warning:
+		return checkers.Warning(msg)
+	default:
+		return checkers.Ok(msg)
+	}
+}
+`
+
+const goModTemplate = `module {%.Module%}
+
+go 1.14
+`
+
+const shMetricPluginTemplate = `#!/bin/sh
+# {%.Name%}: a metric plugin of mackerel-agent
+set -eu
+
+prefix="${METRIC_KEY_PREFIX:-{%.Key%}}"
+
+if [ "${MACKEREL_AGENT_PLUGIN_META:-}" = "1" ]; then
+  echo "# mackerel-agent-plugin"
+  cat <<JSON
+{"graphs": {"${prefix}": {"label": "${prefix}", "unit": "integer", "metrics": [{"name": "value", "label": "Value"}]}}}
+JSON
+  exit 0
+fi
+
+# TODO: fetch the metrics
+value=1
+
+printf '%s\t%s\t%s\n' "${prefix}.value" "${value}" "$(date +%s)"
+`
+
+const shCheckPluginTemplate = `#!/bin/sh
+# {%.Name%}: a check plugin of mackerel-agent
+# The exit status is 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN).
+set -eu
+
+warning="${WARNING:-80}"
+critical="${CRITICAL:-90}"
+
+# TODO: measure the value to check
+value=0
+
+if [ "${value}" -ge "${critical}" ]; then
+  echo "{%.Key%} CRITICAL: value is ${value}"
+  exit 2
+elif [ "${value}" -ge "${warning}" ]; then
+  echo "{%.Key%} WARNING: value is ${value}"
+  exit 1
+fi
+echo "{%.Key%} OK: value is ${value}"
+`
+
+const goMakefileTemplate = `NAME := {%.Name%}
+TARGETS := linux/amd64 linux/386 linux/arm64 darwin/amd64 windows/amd64
+
+.PHONY: build test dist clean
+
+build:
+	go build -o $(NAME) .
+
+test:
+	go test ./...
+
+# dist builds the artifacts in the format of "mkr plugin install" with checksums.txt
+dist: clean
+	@for target in $(TARGETS); do \
+		os=$${target%/*}; arch=$${target#*/}; dir=$(NAME)_$${os}_$${arch}; ext=; \
+		if [ $$os = windows ]; then ext=.exe; fi; \
+		mkdir -p dist/$$dir && \
+		GOOS=$$os GOARCH=$$arch CGO_ENABLED=0 go build -o dist/$$dir/$(NAME)$$ext . && \
+		(cd dist && zip -r $$dir.zip $$dir) || exit 1; \
+	done
+	cd dist && sha256sum *.zip > checksums.txt
+
+clean:
+	rm -rf dist $(NAME)
+`
+
+const shMakefileTemplate = `NAME := {%.Name%}
+TARGETS := linux/amd64 linux/386 linux/arm64 darwin/amd64
+
+.PHONY: test dist clean
+
+test:
+	sh -n $(NAME)
+
+# dist builds the artifacts in the format of "mkr plugin install" with checksums.txt
+dist: clean
+	@for target in $(TARGETS); do \
+		os=$${target%/*}; arch=$${target#*/}; dir=$(NAME)_$${os}_$${arch}; \
+		mkdir -p dist/$$dir && \
+		cp $(NAME) dist/$$dir/ && chmod +x dist/$$dir/$(NAME) && \
+		(cd dist && zip -r $$dir.zip $$dir) || exit 1; \
+	done
+	cd dist && sha256sum *.zip > checksums.txt
+
+clean:
+	rm -rf dist
+`
+
+const releaseWorkflowTemplate = `name: release
+
+on:
+  push:
+    tags:
+      - "v*"
+
+jobs:
+  release:
+    runs-on: ubuntu-latest
+    steps:
+      - uses: actions/checkout@v2
+{%- if eq .Lang "go" %}
+      - uses: actions/setup-go@v2
+        with:
+          go-version: 1.14.x
+      - run: make test
+{%- end %}
+      - run: make dist
+      - uses: softprops/action-gh-release@v1
+        with:
+          files: |
+            dist/*.zip
+            dist/checksums.txt
+        env:
+          GITHUB_TOKEN: ${{ secrets.GITHUB_TOKEN }}
+`
+
+const readmeTemplate = `# {%.Name%}
+
+{%if .Check%}A check plugin{%else%}A metric plugin{%end%} of mackerel-agent.
+
+## Install
+
+Push a tag such as ` + "`" + `v0.0.1` + "`" + ` to release the artifacts with GitHub Actions, and install the plugin by
+
+` + "`" + `` + "`" + `` + "`" + `
+mkr plugin install <owner>/{%.Name%}@v0.0.1
+` + "`" + `` + "`" + `` + "`" + `
+{%- if eq .Lang "go" %}
+
+## Development
+
+` + "`" + `` + "`" + `` + "`" + `
+go mod tidy
+make build
+` + "`" + `` + "`" + `` + "`" + `
+{%- end %}
+
+## Setting
+
+` + "`" + `` + "`" + `` + "`" + `
+{%- if .Check %}
+[plugin.checks.{%.Key%}]
+command = ["/opt/mackerel-agent/plugins/bin/{%.Name%}"]
+{%- else %}
+[plugin.metrics.{%.Key%}]
+command = ["/opt/mackerel-agent/plugins/bin/{%.Name%}"]
+{%- end %}
+` + "`" + `` + "`" + `` + "`" + `
+`
+
+const goGitignoreTemplate = `/{%.Name%}
+/dist/
+`
+
+const shGitignoreTemplate = `/dist/
+`
@@ -0,0 +1,86 @@
+package plugin
+
+import (
+	"go/parser"
+	"go/token"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewScaffold(t *testing.T) {
+	s, err := newScaffold("mackerel-plugin-sample-key", "go", "")
+	assert.NoError(t, err)
+	assert.Equal(t, &scaffold{
+		Name:   "mackerel-plugin-sample-key",
+		Lang:   "go",
+		Module: "mackerel-plugin-sample-key",
+		Key:    "sample-key",
+		Type:   "SampleKeyPlugin",
+	}, s)
+
+	s, err = newScaffold("check-sample", "sh", "github.com/mackerelio/check-sample")
+	assert.NoError(t, err)
+	assert.True(t, s.Check)
+	assert.Equal(t, "sample", s.Key)
+
+	_, err = newScaffold("sample", "go", "")
+	assert.EqualError(t, err, `plugin name should start with "mackerel-plugin-" or "check-": sample`)
+	_, err = newScaffold("check-sample", "ruby", "")
+	assert.EqualError(t, err, "lang should be 'go' or 'sh': ruby")
+}
+
+func TestScaffoldRender(t *testing.T) {
+	testCases := []struct {
+		name, lang string
+		files      []string
+	}{
+		{"mackerel-plugin-sample", "go", []string{"main.go", "go.mod", "Makefile", ".gitignore", ".github/workflows/release.yml", "README.md"}},
+		{"check-sample", "go", []string{"main.go", "go.mod", "Makefile", ".gitignore", ".github/workflows/release.yml", "README.md"}},
+		{"mackerel-plugin-sample", "sh", []string{"mackerel-plugin-sample", "Makefile", ".gitignore", ".github/workflows/release.yml", "README.md"}},
+		{"check-sample", "sh", []string{"check-sample", "Makefile", ".gitignore", ".github/workflows/release.yml", "README.md"}},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.name+" in "+tc.lang, func(t *testing.T) {
+			tmpd := tempd(t)
+			defer os.RemoveAll(tmpd)
+			s, err := newScaffold(tc.name, tc.lang, "github.com/mackerelio/"+tc.name)
+			assert.NoError(t, err)
+			assert.NoError(t, s.render(tmpd))
+
+			for _, f := range tc.files {
+				b, err := ioutil.ReadFile(filepath.Join(tmpd, f))
+				assert.NoError(t, err, "%s is created", f)
+				assert.NotContains(t, string(b), "{%", "%s is rendered", f)
+			}
+			workflow, _ := ioutil.ReadFile(filepath.Join(tmpd, ".github", "workflows", "release.yml"))
+			assert.Contains(t, string(workflow), "${{ secrets.GITHUB_TOKEN }}")
+			makefile, _ := ioutil.ReadFile(filepath.Join(tmpd, "Makefile"))
+			assert.Contains(t, string(makefile), "\n\tcd dist && sha256sum *.zip > checksums.txt\n", "recipes are indented with tabs")
+
+			if tc.lang == "go" {
+				_, err := parser.ParseFile(token.NewFileSet(), filepath.Join(tmpd, "main.go"), nil, 0)
+				assert.NoError(t, err, "main.go is valid")
+			} else if !isWin {
+				script := filepath.Join(tmpd, tc.name)
+				fi, _ := os.Stat(script)
+				assert.True(t, fi.Mode()&0111 != 0, "script is executable")
+				out, err := exec.Command(script).Output()
+				assert.NoError(t, err, "script runs successfully")
+				if strings.HasPrefix(tc.name, "check-") {
+					assert.Equal(t, "sample OK: value is 0\n", string(out))
+				} else {
+					assert.True(t, strings.HasPrefix(string(out), "sample.value\t1\t"), "metric is printed: %q", out)
+				}
+			}
+
+			err = s.render(tmpd)
+			assert.Contains(t, err.Error(), "already exists")
+		})
+	}
+}
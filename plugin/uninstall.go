@@ -0,0 +1,73 @@
+package plugin
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var commandPluginUninstall = cli.Command{
+	Name:      "uninstall",
+	Usage:     "Uninstall a plugin installed by the installer",
+	ArgsUsage: "[--prefix <prefix>] <name>",
+	Action:    doPluginUninstall,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "prefix",
+			Usage: fmt.Sprintf("Plugin install location. The default is %s", defaultPluginInstallLocation),
+		},
+	},
+	Description: `
+    Remove the plugin files of <name> recorded in the manifest, and the plugin from the manifest.
+`,
+}
+
+func doPluginUninstall(c *cli.Context) error {
+	name := c.Args().First()
+	if name == "" {
+		return fmt.Errorf("Specify a plugin name")
+	}
+	pluginDir := c.String("prefix")
+	if pluginDir == "" {
+		pluginDir = defaultPluginInstallLocation
+	}
+	if err := uninstallPlugin(name, pluginDir); err != nil {
+		return errors.Wrap(err, "Failed to uninstall plugin")
+	}
+	logger.Log("", fmt.Sprintf("Successfully uninstalled %s", name))
+	return nil
+}
+
+func uninstallPlugin(name, pluginDir string) error {
+	m, err := loadManifest(pluginDir)
+	if err != nil {
+		return err
+	}
+	p := m.find(name)
+	if p == nil {
+		return fmt.Errorf("plugin %s is not found in the manifest", name)
+	}
+	for _, f := range p.Files {
+		if f != filepath.Base(f) || !looksLikePlugin(f) {
+			continue
+		}
+		fpath := filepath.Join(pluginDir, "bin", f)
+		logger.Log("", fmt.Sprintf("Removing %s", fpath))
+		if err := os.Remove(fpath); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if ownerAndRepo := strings.Split(p.Source, "/"); len(ownerAndRepo) == 2 && !strings.HasPrefix(p.Source, ".") && !strings.Contains(p.Source, "/.") {
+		// remove release_tag not to skip installing again with --upgrade
+		if err := os.RemoveAll(filepath.Join(pluginDir, "meta", ownerAndRepo[0], ownerAndRepo[1])); err != nil {
+			return err
+		}
+	}
+	m.remove(name)
+	return m.save(pluginDir)
+}
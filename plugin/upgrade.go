@@ -0,0 +1,92 @@
+package plugin
+
+import (
+	"fmt"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/pkg/errors"
+	"github.com/urfave/cli"
+)
+
+var commandPluginUpgrade = cli.Command{
+	Name:      "upgrade",
+	Usage:     "Upgrade plugins installed by the installer",
+	ArgsUsage: "[--prefix <prefix>] [--github-token <token>] [--gpg-key <key_file>] [--insecure-skip-verify] [--mirror <base_url>] (--all | <name>)",
+	Action:    doPluginUpgrade,
+	Flags: []cli.Flag{
+		cli.StringFlag{
+			Name:  "prefix",
+			Usage: fmt.Sprintf("Plugin install location. The default is %s", defaultPluginInstallLocation),
+		},
+		cli.BoolFlag{
+			Name:  "all",
+			Usage: "Upgrade all the plugins in the manifest",
+		},
+		cli.StringFlag{
+			Name:  "github-token",
+			Usage: "Github token to use Github API, which is required for private repositories",
+		},
+		cli.StringFlag{
+			Name:  "gpg-key",
+			Usage: "Verify the signature of checksums.txt with the armored GPG public key file",
+		},
+		cli.BoolFlag{
+			Name:  "insecure-skip-verify",
+			Usage: "Install a plugin without verifying the checksum of the artifact",
+		},
+		cli.StringFlag{
+			Name:   "mirror",
+			EnvVar: "MKR_PLUGIN_MIRROR",
+			Usage:  "Base URL of the mirror which github.com release URLs are rewritten to",
+		},
+	},
+	Description: `
+    Upgrade the plugin of <name> in the manifest, or all the plugins with --all, to the latest
+    release. The plugins installed with <release_tag> are also upgraded to the latest release.
+    The plugins installed by <direct_url> are not upgraded.
+`,
+}
+
+func doPluginUpgrade(c *cli.Context) error {
+	name := c.Args().First()
+	if (name == "") == !c.Bool("all") {
+		return fmt.Errorf("Specify a plugin name or --all")
+	}
+	pluginDir, err := setupPluginDir(c.String("prefix"))
+	if err != nil {
+		return errors.Wrap(err, "Failed to upgrade plugin while setup plugin directory")
+	}
+	m, err := loadManifest(pluginDir)
+	if err != nil {
+		return errors.Wrap(err, "Failed to load the manifest")
+	}
+	plugins := m.Plugins
+	if name != "" {
+		p := m.find(name)
+		if p == nil {
+			return fmt.Errorf("plugin %s is not found in the manifest", name)
+		}
+		plugins = []*manifestPlugin{p}
+	}
+
+	var failed []string
+	for _, p := range plugins {
+		if p.Source == "" {
+			logger.Log("", fmt.Sprintf("%s is installed by URL. Skip upgrading", p.Name))
+			continue
+		}
+		opt := newInstallOption(c)
+		opt.upgrade = true
+		if p.GithubAPIURL != "" {
+			opt.githubAPIURL = p.GithubAPIURL
+		}
+		if err := installPlugin(p.Target, pluginDir, opt); err != nil {
+			logger.Log("error", fmt.Sprintf("Failed to upgrade %s: %s", p.Name, err))
+			failed = append(failed, p.Name)
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("Failed to upgrade plugins: %v", failed)
+	}
+	return nil
+}
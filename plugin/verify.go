@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/pkg/errors"
+	"golang.org/x/crypto/openpgp"
+)
+
+const checksumsFileName = "checksums.txt"
+
+var sha256Reg = regexp.MustCompile(`^[0-9a-fA-F]{64}$`)
+
+// Make the URL of checksums.txt, which is released with the artifact
+func makeChecksumsURL(downloadURL string) string {
+	return downloadURL[:strings.LastIndex(downloadURL, "/")+1] + checksumsFileName
+}
+
+// Fetch content from `u`(URL)
+func fetch(open opener, u string) ([]byte, error) {
+	rc, err := open(u)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	return ioutil.ReadAll(rc)
+}
+
+// Parse checksums.txt in the format of sha256sum, such as
+// "<sha256>  <filename>" (or "<sha256> *<filename>" in binary mode)
+func parseChecksums(data []byte) map[string]string {
+	checksums := make(map[string]string)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) != 2 || !sha256Reg.MatchString(fields[0]) {
+			continue
+		}
+		checksums[strings.TrimPrefix(fields[1], "*")] = strings.ToLower(fields[0])
+	}
+	return checksums
+}
+
+// Calculate SHA256 checksum of the file
+func sha256File(fpath string) (string, error) {
+	f, err := os.Open(fpath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	h := sha256.New()
+	if _, err := io.Copy(h, f); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verify the artifact with checksums.txt released with it,
+// and the signature of checksums.txt if `gpgKeyFile` is specified
+func verifyPluginArtifact(open opener, artifactFile, downloadURL, gpgKeyFile string) error {
+	checksumsURL := makeChecksumsURL(downloadURL)
+	logger.Log("", fmt.Sprintf("Verifying %s with %s", path.Base(downloadURL), checksumsURL))
+	checksums, err := fetch(open, checksumsURL)
+	if err != nil {
+		return err
+	}
+	if gpgKeyFile != "" {
+		if err := verifyChecksumsSignature(open, checksums, checksumsURL+".sig", gpgKeyFile); err != nil {
+			return errors.Wrap(err, "Failed to verify the signature of "+checksumsFileName)
+		}
+	}
+
+	expected, ok := parseChecksums(checksums)[path.Base(downloadURL)]
+	if !ok {
+		return fmt.Errorf("checksum of %s is not found in %s", path.Base(downloadURL), checksumsFileName)
+	}
+	actual, err := sha256File(artifactFile)
+	if err != nil {
+		return err
+	}
+	if actual != expected {
+		return fmt.Errorf("checksum mismatch for %s. expected: %s, actual: %s", path.Base(downloadURL), expected, actual)
+	}
+	return nil
+}
+
+// Verify the detached signature of checksums.txt with the armored public key
+func verifyChecksumsSignature(open opener, checksums []byte, sigURL, gpgKeyFile string) error {
+	keyFile, err := os.Open(gpgKeyFile)
+	if err != nil {
+		return err
+	}
+	defer keyFile.Close()
+	keyring, err := openpgp.ReadArmoredKeyRing(keyFile)
+	if err != nil {
+		return err
+	}
+
+	sig, err := fetch(open, sigURL)
+	if err != nil {
+		return err
+	}
+	// Both of armored and binary signatures are accepted
+	if bytes.HasPrefix(bytes.TrimSpace(sig), []byte("-----BEGIN PGP SIGNATURE-----")) {
+		_, err = openpgp.CheckArmoredDetachedSignature(keyring, bytes.NewReader(checksums), bytes.NewReader(sig))
+	} else {
+		_, err = openpgp.CheckDetachedSignature(keyring, bytes.NewReader(checksums), bytes.NewReader(sig))
+	}
+	return err
+}
@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/crypto/openpgp"
+	"golang.org/x/crypto/openpgp/armor"
+)
+
+func TestMakeChecksumsURL(t *testing.T) {
+	assert.Equal(t,
+		"https://github.com/mackerelio/mackerel-plugin-sample/releases/download/v0.0.1/checksums.txt",
+		makeChecksumsURL("https://github.com/mackerelio/mackerel-plugin-sample/releases/download/v0.0.1/mackerel-plugin-sample_linux_amd64.zip"),
+	)
+	assert.Equal(t, "file:///tmp/checksums.txt", makeChecksumsURL("file:///tmp/mackerel-plugin-sample_linux_amd64.zip"))
+}
+
+func TestParseChecksums(t *testing.T) {
+	checksums := parseChecksums([]byte(`93B341941A1C916182F6D9912A89A81E006D72A22DF4E54B03C84C25CA3D1D33  a.zip
+c9a7977f3f547714a718bdaddf2a42ef9b2767b7331efec3ec6e368b88f062c4 *b.tar.gz
+
+invalid line
+`))
+	assert.Equal(t, map[string]string{
+		"a.zip":    "93b341941a1c916182f6d9912a89a81e006d72a22df4e54b03c84c25ca3d1d33",
+		"b.tar.gz": "c9a7977f3f547714a718bdaddf2a42ef9b2767b7331efec3ec6e368b88f062c4",
+	}, checksums)
+}
+
+func TestVerifyPluginArtifact(t *testing.T) {
+	artifact := "testdata/mackerel-plugin-sample_linux_amd64.zip"
+
+	t.Run("checksum matches", func(t *testing.T) {
+		ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+		defer ts.Close()
+		err := verifyPluginArtifact(openURL, artifact, ts.URL+"/mackerel-plugin-sample_linux_amd64.zip", "")
+		assert.Nil(t, err, "artifact is verified")
+	})
+
+	t.Run("checksum mismatches", func(t *testing.T) {
+		ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+		defer ts.Close()
+		err := verifyPluginArtifact(openURL, artifact, ts.URL+"/mackerel-plugin-sample_linux_amd64.tar.gz", "")
+		assert.Contains(t, err.Error(), "checksum mismatch")
+	})
+
+	t.Run("checksum not found", func(t *testing.T) {
+		ts := httptest.NewServer(http.FileServer(http.Dir("testdata")))
+		defer ts.Close()
+		err := verifyPluginArtifact(openURL, artifact, ts.URL+"/unknown.zip", "")
+		assert.Contains(t, err.Error(), "checksum of unknown.zip is not found")
+	})
+
+	t.Run("checksums.txt not found", func(t *testing.T) {
+		ts := httptest.NewServer(http.NotFoundHandler())
+		defer ts.Close()
+		err := verifyPluginArtifact(openURL, artifact, ts.URL+"/mackerel-plugin-sample_linux_amd64.zip", "")
+		assert.NotNil(t, err, "artifact is not verified without checksums.txt")
+	})
+}
+
+func TestVerifyPluginArtifact_signature(t *testing.T) {
+	tmpd := tempd(t)
+	defer os.RemoveAll(tmpd)
+
+	checksums, err := ioutil.ReadFile("testdata/checksums.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+	signer, err := openpgp.NewEntity("mkr", "test", "mkr@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sig := &bytes.Buffer{}
+	if err := openpgp.ArmoredDetachSign(sig, signer, bytes.NewReader(checksums), nil); err != nil {
+		t.Fatal(err)
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/", http.FileServer(http.Dir("testdata")))
+	mux.HandleFunc("/checksums.txt.sig", func(w http.ResponseWriter, r *http.Request) {
+		w.Write(sig.Bytes())
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	writeKey := func(e *openpgp.Entity) string {
+		fpath := filepath.Join(tmpd, e.PrimaryKey.KeyIdShortString()+".asc")
+		f, err := os.Create(fpath)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		w, err := armor.Encode(f, openpgp.PublicKeyType, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer w.Close()
+		if err := e.Serialize(w); err != nil {
+			t.Fatal(err)
+		}
+		return fpath
+	}
+	downloadURL := ts.URL + "/mackerel-plugin-sample_linux_amd64.zip"
+
+	err = verifyPluginArtifact(openURL, "testdata/mackerel-plugin-sample_linux_amd64.zip", downloadURL, writeKey(signer))
+	assert.Nil(t, err, "signature is verified with the key of the signer")
+
+	other, err := openpgp.NewEntity("other", "test", "other@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = verifyPluginArtifact(openURL, "testdata/mackerel-plugin-sample_linux_amd64.zip", downloadURL, writeKey(other))
+	assert.NotNil(t, err, "signature is not verified with the key of the other")
+}
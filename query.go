@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandQuery = cli.Command{
+	Name:      "query",
+	Usage:     "Evaluate a graph expression",
+	ArgsUsage: "[--from <time>] [--to <time>] [--output | -o table|csv|tsv|json|sparkline] <expression>",
+	Description: `
+    Evaluate the graph expression such as avg(role(My-Service:db, loadavg5)) and print the time series.
+    The times are specified in RFC3339, epoch seconds, "now" or durations before now such as -1h or 7d.
+    Requests "GET /api/v0/expressions/graph", which the expression graphs are drawn with.
+`,
+	Action: doQuery,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "from", Value: "-1h", Usage: "Evaluate the expression since <time>."},
+		cli.StringFlag{Name: "to", Value: "now", Usage: "Evaluate the expression until <time>."},
+		cli.StringFlag{Name: "output, o", Value: "table", Usage: "Output format: 'table', 'csv', 'tsv', 'json' or 'sparkline'"},
+	},
+}
+
+type expressionSeries struct {
+	Name   string                 `json:"name"`
+	HostID string                 `json:"hostId,omitempty"`
+	Data   []mackerel.MetricValue `json:"data"`
+}
+
+func fetchExpressionSeries(client *mackerel.Client, expr string, from, to int64) ([]*expressionSeries, error) {
+	query := url.Values{}
+	query.Set("query", expr)
+	query.Set("from", strconv.FormatInt(from, 10))
+	query.Set("to", strconv.FormatInt(to, 10))
+	var data struct {
+		Series []*expressionSeries `json:"series"`
+	}
+	if err := mackerelclient.RequestJSON(client, http.MethodGet, "/api/v0/expressions/graph", query, nil, &data); err != nil {
+		return nil, err
+	}
+	return data.Series, nil
+}
+
+// printSeriesTable prints the series in columns with a row for each time.
+func printSeriesTable(w io.Writer, series []*metricSeries) error {
+	values := map[int64][]string{}
+	var times []int64
+	for i, s := range series {
+		for _, v := range s.values {
+			if _, ok := values[v.Time]; !ok {
+				values[v.Time] = make([]string, len(series))
+				times = append(times, v.Time)
+			}
+			values[v.Time][i] = fmt.Sprint(v.Value)
+		}
+	}
+	sort.Slice(times, func(i, j int) bool { return times[i] < times[j] })
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	headers := []string{"TIME"}
+	for _, s := range series {
+		headers = append(headers, s.name)
+	}
+	fmt.Fprintln(tw, strings.Join(headers, "\t"))
+	for _, t := range times {
+		fmt.Fprintln(tw, time.Unix(t, 0).Format(time.RFC3339)+"\t"+strings.Join(values[t], "\t"))
+	}
+	return tw.Flush()
+}
+
+var sparklineTicks = []rune("▁▂▃▄▅▆▇█")
+
+// the maximum number of characters of a sparkline
+const sparklineWidth = 60
+
+// sparkline draws the values with block characters. The values are averaged when they are more than the width.
+func sparkline(values []float64, width int) string {
+	if len(values) > width {
+		averaged := make([]float64, width)
+		for i := range averaged {
+			bucket := values[i*len(values)/width : (i+1)*len(values)/width]
+			for _, v := range bucket {
+				averaged[i] += v
+			}
+			averaged[i] /= float64(len(bucket))
+		}
+		values = averaged
+	}
+	if len(values) == 0 {
+		return ""
+	}
+	min, max := values[0], values[0]
+	for _, v := range values {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+	var b strings.Builder
+	for _, v := range values {
+		i := 0
+		if max > min {
+			i = int((v - min) / (max - min) * float64(len(sparklineTicks)-1))
+		}
+		b.WriteRune(sparklineTicks[i])
+	}
+	return b.String()
+}
+
+func printSparklines(w io.Writer, series []*metricSeries) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, s := range series {
+		var values []float64
+		for _, v := range s.values {
+			if f, ok := metricValueFloat(v.Value); ok {
+				values = append(values, f)
+			}
+		}
+		if len(values) == 0 {
+			fmt.Fprintf(tw, "%s\t\tno data\n", s.name)
+			continue
+		}
+		min, max := values[0], values[0]
+		for _, v := range values {
+			if v < min {
+				min = v
+			}
+			if v > max {
+				max = v
+			}
+		}
+		fmt.Fprintf(tw, "%s\t%s\tmin=%g max=%g last=%g\n", s.name, sparkline(values, sparklineWidth), min, max, values[len(values)-1])
+	}
+	return tw.Flush()
+}
+
+func doQuery(c *cli.Context) error {
+	expr := c.Args().First()
+	if expr == "" {
+		cli.ShowCommandHelp(c, "query")
+		os.Exit(1)
+	}
+	output := format.OutputName(c, "table", "table", "csv", "tsv", "json", "sparkline")
+	switch output {
+	case "table", "csv", "tsv", "json", "sparkline":
+	default:
+		return fmt.Errorf("output should be 'table', 'csv', 'tsv', 'json' or 'sparkline': %s", output)
+	}
+	now := time.Now()
+	from, err := parseAlertTime(c.String("from"), now)
+	if err != nil {
+		return err
+	}
+	to, err := parseAlertTime(c.String("to"), now)
+	if err != nil {
+		return err
+	}
+
+	es, err := fetchExpressionSeries(mackerelclient.NewFromContext(c), expr, from.Unix(), to.Unix())
+	logger.DieIf(err)
+
+	series := make([]*metricSeries, 0, len(es))
+	for _, s := range es {
+		series = append(series, &metricSeries{target: expr, name: s.Name, values: s.Data})
+	}
+	switch output {
+	case "table":
+		return printSeriesTable(os.Stdout, series)
+	case "sparkline":
+		return printSparklines(os.Stdout, series)
+	}
+	return printMetricSeries(os.Stdout, series, output)
+}
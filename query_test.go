@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestFetchAndPrintExpressionSeries(t *testing.T) {
+	time.Local = time.UTC
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/v0/expressions/graph" {
+			t.Errorf("unexpected path: %s", r.URL.Path)
+		}
+		if q := r.URL.Query(); q.Get("query") != "avg(role(foo:bar, loadavg5))" || q.Get("from") != "60" || q.Get("to") != "180" {
+			t.Errorf("unexpected query: %s", r.URL.RawQuery)
+		}
+		fmt.Fprint(w, `{"series": [
+			{"name": "avg", "data": [{"time": 60, "value": 1.5}, {"time": 120, "value": 2}]},
+			{"name": "max", "data": [{"time": 120, "value": 4}]}
+		]}`)
+	}))
+	defer ts.Close()
+
+	client, _ := mackerel.NewClientWithOptions("dummy", ts.URL, false)
+	es, err := fetchExpressionSeries(client, "avg(role(foo:bar, loadavg5))", 60, 180)
+	if err != nil {
+		t.Fatal(err)
+	}
+	series := make([]*metricSeries, 0, len(es))
+	for _, s := range es {
+		series = append(series, &metricSeries{name: s.Name, values: s.Data})
+	}
+	var buf bytes.Buffer
+	if err := printSeriesTable(&buf, series); err != nil {
+		t.Fatal(err)
+	}
+	want := `TIME                  avg  max
+1970-01-01T00:01:00Z  1.5  
+1970-01-01T00:02:00Z  2    4
+`
+	if buf.String() != want {
+		t.Errorf("output should be:\n%s\nbut:\n%s", want, buf.String())
+	}
+}
+
+func TestSparkline(t *testing.T) {
+	if got, want := sparkline([]float64{0, 1, 2, 3, 4, 5, 6, 7}, 60), "▁▂▃▄▅▆▇█"; got != want {
+		t.Errorf("sparkline should be %s but: %s", want, got)
+	}
+	if got, want := sparkline([]float64{0, 0, 7, 7}, 2), "▁█"; got != want {
+		t.Errorf("sparkline should be %s but: %s", want, got)
+	}
+	if got, want := sparkline([]float64{3, 3}, 60), "▁▁"; got != want {
+		t.Errorf("sparkline should be %s but: %s", want, got)
+	}
+}
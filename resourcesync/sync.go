@@ -0,0 +1,147 @@
+package resourcesync
+
+import (
+	"fmt"
+	"io"
+)
+
+// Resource is a resource pushed by a file, which is matched by the id or by the name.
+type Resource struct {
+	ID    string
+	Name  string
+	Value interface{}
+}
+
+// CheckResources checks that the resources loaded from a file have the names and are not duplicated.
+// The kind is the name of the resource used in the messages, e.g. "channel".
+func CheckResources(kind string, resources []Resource) error {
+	ids, names := map[string]bool{}, map[string]bool{}
+	for _, r := range resources {
+		if r.Name == "" {
+			return fmt.Errorf("the name of a %s is empty", kind)
+		}
+		if r.ID != "" {
+			if ids[r.ID] {
+				return fmt.Errorf("the %s %s is duplicated", kind, r.ID)
+			}
+			ids[r.ID] = true
+		} else {
+			if names[r.Name] {
+				return fmt.Errorf("the %s '%s' without id is duplicated", kind, r.Name)
+			}
+			names[r.Name] = true
+		}
+	}
+	return nil
+}
+
+// Change is a change applied by Apply.
+type Change interface {
+	Action() string
+	Target() string
+}
+
+// ResourceChange is a change of a resource planned by Plan.
+// The ID is the id of the current resource, which is empty on creating.
+// The Value is the desired resource, or the current one on deleting.
+type ResourceChange struct {
+	action string
+	ID     string
+	Name   string
+	Value  interface{}
+}
+
+// Action returns create, update or delete.
+func (c *ResourceChange) Action() string {
+	return c.action
+}
+
+// Target returns the name and the id of the resource.
+func (c *ResourceChange) Target() string {
+	if c.ID == "" {
+		return fmt.Sprintf("'%s'", c.Name)
+	}
+	return fmt.Sprintf("'%s' (%s)", c.Name, c.ID)
+}
+
+// Plan returns the changes to make the current resources the desired ones.
+// The desired resources are matched by the id, or by the name if they have no id,
+// and updated if they are not the same as the current ones.
+// The resources which do not exist in the desired ones are deleted only if prune is true.
+func Plan(kind string, current, desired []Resource, prune bool, same func(current, desired interface{}) bool) ([]*ResourceChange, error) {
+	byID, byName := map[string]Resource{}, map[string]Resource{}
+	for _, r := range current {
+		byID[r.ID] = r
+		if _, ok := byName[r.Name]; !ok {
+			byName[r.Name] = r
+		}
+	}
+	// the resources with ids are not matched by the names of the others
+	matched := map[string]bool{}
+	for _, r := range desired {
+		matched[r.ID] = r.ID != ""
+	}
+	var changes []*ResourceChange
+	for _, r := range desired {
+		var cur Resource
+		var ok bool
+		if r.ID != "" {
+			if cur, ok = byID[r.ID]; !ok {
+				return nil, fmt.Errorf("%s not found: %s", kind, r.ID)
+			}
+		} else if cur, ok = byName[r.Name]; ok && matched[cur.ID] {
+			ok = false
+		}
+		if !ok {
+			changes = append(changes, &ResourceChange{action: "create", Name: r.Name, Value: r.Value})
+			continue
+		}
+		matched[cur.ID] = true
+		if !same(cur.Value, r.Value) {
+			changes = append(changes, &ResourceChange{action: "update", ID: cur.ID, Name: r.Name, Value: r.Value})
+		}
+	}
+	if prune {
+		for _, r := range current {
+			if !matched[r.ID] {
+				changes = append(changes, &ResourceChange{action: "delete", ID: r.ID, Name: r.Name, Value: r.Value})
+			}
+		}
+	}
+	return changes, nil
+}
+
+// Logger logs the applied changes.
+type Logger interface {
+	Log(string, string)
+}
+
+// Apply applies the changes in order by apply, or only prints them to w if dryRun is true.
+// The kinds is the plural name of the resources used in the message when there are no changes.
+func Apply(w io.Writer, logger Logger, kinds string, changes []Change, dryRun bool, apply func(Change) error) error {
+	if len(changes) == 0 {
+		logger.Log("info", kinds+" are up to date.")
+		return nil
+	}
+	for _, c := range changes {
+		if dryRun {
+			fmt.Fprintln(w, c.Action(), c.Target())
+			continue
+		}
+		if err := apply(c); err != nil {
+			return fmt.Errorf("failed to %s %s: %s", c.Action(), c.Target(), err)
+		}
+		// created, updated or deleted
+		logger.Log(c.Action()+"d", c.Target())
+	}
+	return nil
+}
+
+// Changes converts the changes of the resources to apply them.
+func Changes(changes []*ResourceChange) []Change {
+	cs := make([]Change, len(changes))
+	for i, c := range changes {
+		cs[i] = c
+	}
+	return cs
+}
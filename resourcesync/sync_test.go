@@ -0,0 +1,92 @@
+package resourcesync
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type testLogger struct {
+	out *bytes.Buffer
+}
+
+func (l *testLogger) Log(prefix, message string) {
+	fmt.Fprintln(l.out, prefix, message)
+}
+
+func TestCheckResources(t *testing.T) {
+	assert.NoError(t, CheckResources("channel", []Resource{{ID: "a", Name: "ops"}, {Name: "ops"}, {Name: "slack"}}))
+	assert.EqualError(t, CheckResources("channel", []Resource{{ID: "a"}}), "the name of a channel is empty")
+	assert.EqualError(t, CheckResources("channel", []Resource{{ID: "a", Name: "ops"}, {ID: "a", Name: "slack"}}), "the channel a is duplicated")
+	assert.EqualError(t, CheckResources("channel", []Resource{{Name: "ops"}, {Name: "ops"}}), "the channel 'ops' without id is duplicated")
+}
+
+func TestPlan(t *testing.T) {
+	current := []Resource{
+		{ID: "a", Name: "ops", Value: 1},
+		{ID: "b", Name: "oncall", Value: 1},
+		{ID: "c", Name: "ops", Value: 1},
+		{ID: "d", Name: "obsolete", Value: 1},
+	}
+	desired := []Resource{
+		{ID: "a", Name: "ops", Value: 1},
+		{Name: "oncall", Value: 2},
+		// not matched with a since it is matched by the id
+		{Name: "ops", Value: 1},
+		{Name: "new", Value: 1},
+	}
+	same := func(x, y interface{}) bool { return x == y }
+	describe := func(changes []*ResourceChange) []string {
+		var xs []string
+		for _, c := range changes {
+			xs = append(xs, c.Action()+" "+c.Target())
+		}
+		return xs
+	}
+
+	changes, err := Plan("channel", current, desired, false, same)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update 'oncall' (b)", "create 'ops'", "create 'new'"}, describe(changes))
+
+	changes, err = Plan("channel", current, desired, true, same)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"update 'oncall' (b)", "create 'ops'", "create 'new'", "delete 'ops' (c)", "delete 'obsolete' (d)"}, describe(changes))
+
+	_, err = Plan("channel", current, []Resource{{ID: "x", Name: "unknown"}}, false, same)
+	assert.EqualError(t, err, "channel not found: x")
+}
+
+func TestApply(t *testing.T) {
+	changes := []*ResourceChange{
+		{action: "create", Name: "new"},
+		{action: "delete", ID: "a", Name: "ops"},
+		{action: "update", ID: "b", Name: "oncall"},
+	}
+	out := new(bytes.Buffer)
+	logger := &testLogger{out}
+
+	assert.NoError(t, Apply(out, logger, "channels", Changes(changes), true, func(Change) error {
+		t.Error("changes should not be applied on dry run")
+		return nil
+	}))
+	assert.Equal(t, "create 'new'\ndelete 'ops' (a)\nupdate 'oncall' (b)\n", out.String())
+
+	out.Reset()
+	var applied []string
+	err := Apply(out, logger, "channels", Changes(changes), false, func(c Change) error {
+		applied = append(applied, c.Target())
+		if c.Action() == "delete" {
+			return fmt.Errorf("forbidden")
+		}
+		return nil
+	})
+	assert.EqualError(t, err, "failed to delete 'ops' (a): forbidden")
+	assert.Equal(t, []string{"'new'", "'ops' (a)"}, applied)
+	assert.Equal(t, "created 'new'\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, Apply(out, logger, "channels", nil, false, nil))
+	assert.Equal(t, "info channels are up to date.\n", out.String())
+}
@@ -0,0 +1,583 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+var commandRestore = cli.Command{
+	Name:      "restore",
+	Usage:     "Restore the configuration of the organization from a backup",
+	ArgsUsage: "--file-path | -F <file> [--resources <resources>] [--conflict skip|overwrite] [--dry-run | -d]",
+	Description: `
+    Create the resources in the archive of "mkr backup". The resources which exist in the organization
+    (by the names, or the URL paths of the dashboards) are skipped, or updated with --conflict overwrite.
+    The services, the roles and the channels are never updated. The IDs of the channels, the monitors and
+    the notification groups referred by the notification groups and the downtimes are replaced with the restored ones.
+    With --dry-run, the changes are shown but not applied.
+`,
+	Action: doRestore,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "file-path, F", Value: "", Usage: "The archive file of mkr backup"},
+		cli.StringFlag{Name: "resources", Value: "", Usage: "Comma separated resource types to restore. All the resources in the archive if not specified"},
+		cli.StringFlag{Name: "conflict", Value: "skip", Usage: "How to resolve the conflicts with the existing resources: 'skip' or 'overwrite'"},
+		cli.BoolFlag{Name: "dry-run, d", Usage: "Show the changes, but not apply them"},
+	},
+}
+
+type restoreClient interface {
+	backupClient
+	CreateService(param *mackerel.CreateServiceParam) (*mackerel.Service, error)
+	CreateRole(serviceName string, param *mackerel.CreateRoleParam) (*mackerel.Role, error)
+	CreateChannel(param *mackerel.Channel) (*mackerel.Channel, error)
+	CreateMonitor(param mackerel.Monitor) (mackerel.Monitor, error)
+	UpdateMonitor(monitorID string, param mackerel.Monitor) (mackerel.Monitor, error)
+	CreateNotificationGroup(param *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error)
+	UpdateNotificationGroup(id string, param *mackerel.NotificationGroup) (*mackerel.NotificationGroup, error)
+	CreateDowntime(param *mackerel.Downtime) (*mackerel.Downtime, error)
+	UpdateDowntime(downtimeID string, param *mackerel.Downtime) (*mackerel.Downtime, error)
+	CreateDashboard(param *mackerel.Dashboard) (*mackerel.Dashboard, error)
+	UpdateDashboard(dashboardID string, param *mackerel.Dashboard) (*mackerel.Dashboard, error)
+	CreateAWSIntegration(param json.RawMessage) (string, error)
+	UpdateAWSIntegration(id string, param json.RawMessage) error
+}
+
+// orgRestorer restores the resources in the backup
+type orgRestorer struct {
+	changeCounter
+	client    restoreClient
+	backup    *orgBackup
+	overwrite bool
+
+	// the IDs in the backup to the IDs of the restored or the existing resources
+	channelIDs           map[string]string
+	monitorIDs           map[string]string
+	notificationGroupIDs map[string]string
+}
+
+func newOrgRestorer(client restoreClient, backup *orgBackup, overwrite, isDryRun bool) *orgRestorer {
+	return &orgRestorer{
+		changeCounter:        changeCounter{isDryRun: isDryRun},
+		client:               client,
+		backup:               backup,
+		overwrite:            overwrite,
+		channelIDs:           map[string]string{},
+		monitorIDs:           map[string]string{},
+		notificationGroupIDs: map[string]string{},
+	}
+}
+
+func (or *orgRestorer) restore(resources []string) error {
+	for _, r := range resources {
+		if !containsString(backupResources, r) {
+			return fmt.Errorf("unknown resource: %s (should be one of %v)", r, backupResources)
+		}
+		if _, _, ok := or.backup.resource(r); !ok {
+			return fmt.Errorf("%s are not in the backup", r)
+		}
+	}
+	// resolve the references to the existing resources even if they are not restored
+	if err := or.resolveExistingIDs(); err != nil {
+		return err
+	}
+	for _, r := range backupResources {
+		if !containsString(resources, r) {
+			continue
+		}
+		var err error
+		switch r {
+		case "services":
+			err = or.restoreServices()
+		case "channels":
+			err = or.restoreChannels()
+		case "monitors":
+			err = or.restoreMonitors()
+		case "notification-groups":
+			err = or.restoreNotificationGroups()
+		case "downtimes":
+			err = or.restoreDowntimes()
+		case "dashboards":
+			err = or.restoreDashboards()
+		case "aws-integrations":
+			err = or.restoreAWSIntegrations()
+		}
+		if err != nil {
+			return fmt.Errorf("failed to restore %s: %s", r, err)
+		}
+	}
+	return nil
+}
+
+func monitorKey(m mackerel.Monitor) string {
+	return m.MonitorType() + ":" + m.MonitorName()
+}
+
+// resolveExistingIDs maps the IDs in the backup to the IDs of the existing resources with the same names
+func (or *orgRestorer) resolveExistingIDs() error {
+	if or.backup.Channels != nil {
+		channels, err := or.client.FindChannels()
+		if err != nil {
+			return err
+		}
+		for _, ch := range or.backup.Channels {
+			for _, existing := range channels {
+				if ch.Name == existing.Name {
+					or.channelIDs[ch.ID] = existing.ID
+				}
+			}
+		}
+	}
+	if or.backup.Monitors != nil {
+		monitors, err := or.client.FindMonitors()
+		if err != nil {
+			return err
+		}
+		for _, m := range or.backup.Monitors {
+			for _, existing := range monitors {
+				if monitorKey(m) == monitorKey(existing) {
+					or.monitorIDs[m.MonitorID()] = existing.MonitorID()
+				}
+			}
+		}
+	}
+	if or.backup.NotificationGroups != nil {
+		groups, err := or.client.FindNotificationGroups()
+		if err != nil {
+			return err
+		}
+		for _, g := range or.backup.NotificationGroups {
+			for _, existing := range groups {
+				if g.Name == existing.Name {
+					or.notificationGroupIDs[g.ID] = existing.ID
+				}
+			}
+		}
+	}
+	return nil
+}
+
+func (or *orgRestorer) restoreServices() error {
+	services, err := or.client.FindServices()
+	if err != nil {
+		return err
+	}
+	roles := map[string]map[string]bool{}
+	for _, s := range services {
+		roles[s.Name] = map[string]bool{}
+		for _, r := range s.Roles {
+			roles[s.Name][r] = true
+		}
+	}
+	for _, s := range or.backup.Services {
+		if roles[s.Name] != nil {
+			or.skip(fmt.Sprintf("the service %s which exists", s.Name))
+		} else if !or.dryRun("Create", "the service "+s.Name) {
+			if _, err := or.client.CreateService(&mackerel.CreateServiceParam{Name: s.Name, Memo: s.Memo}); err != nil {
+				or.fail(fmt.Sprintf("failed to create the service %s: %s", s.Name, err))
+				continue
+			}
+			or.succeed("Create", "the service "+s.Name)
+		}
+		for _, r := range s.Roles {
+			fullname := s.Name + ":" + r.Name
+			if roles[s.Name][r.Name] {
+				or.skip(fmt.Sprintf("the role %s which exists", fullname))
+				continue
+			}
+			if or.dryRun("Create", "the role "+fullname) {
+				continue
+			}
+			if _, err := or.client.CreateRole(s.Name, &mackerel.CreateRoleParam{Name: r.Name, Memo: r.Memo}); err != nil {
+				or.fail(fmt.Sprintf("failed to create the role %s: %s", fullname, err))
+				continue
+			}
+			or.succeed("Create", "the role "+fullname)
+		}
+	}
+	return nil
+}
+
+func (or *orgRestorer) restoreChannels() error {
+	for _, ch := range or.backup.Channels {
+		label := fmt.Sprintf("the channel %s (%s)", ch.Name, ch.ID)
+		if _, ok := or.channelIDs[ch.ID]; ok {
+			or.skip(label + " which exists")
+			continue
+		}
+		if !copyableChannelTypes[ch.Type] {
+			or.fail(fmt.Sprintf("%s cannot be restored: the type %s is not supported by the API", label, ch.Type))
+			continue
+		}
+		if or.dryRun("Create", label) {
+			or.channelIDs[ch.ID] = ch.ID
+			continue
+		}
+		param := *ch
+		param.ID = ""
+		created, err := or.client.CreateChannel(&param)
+		if err != nil {
+			or.fail(fmt.Sprintf("failed to create %s: %s", label, err))
+			continue
+		}
+		or.channelIDs[ch.ID] = created.ID
+		or.succeed("Create", fmt.Sprintf("the channel %s (%s -> %s)", ch.Name, ch.ID, created.ID))
+	}
+	return nil
+}
+
+// sameResource reports whether the resources are the same except for the IDs
+func sameResource(a, b interface{}) bool {
+	normalize := func(v interface{}) map[string]interface{} {
+		var m map[string]interface{}
+		data, _ := json.Marshal(v)
+		json.Unmarshal(data, &m)
+		delete(m, "id")
+		return m
+	}
+	return reflect.DeepEqual(normalize(a), normalize(b))
+}
+
+func (or *orgRestorer) restoreMonitors() error {
+	monitors, err := or.client.FindMonitors()
+	if err != nil {
+		return err
+	}
+	existing := map[string]mackerel.Monitor{}
+	for _, m := range monitors {
+		existing[m.MonitorID()] = m
+	}
+	for _, m := range or.backup.Monitors {
+		label := fmt.Sprintf("the monitor %s (%s)", m.MonitorName(), m.MonitorID())
+		param, err := monitorWithoutID(m)
+		if err != nil {
+			return err
+		}
+		if id, ok := or.monitorIDs[m.MonitorID()]; ok {
+			if !or.overwrite || sameResource(existing[id], param) {
+				or.skip(label + " which exists")
+				continue
+			}
+			if or.dryRun("Update", label) {
+				continue
+			}
+			if _, err := or.client.UpdateMonitor(id, param); err != nil {
+				or.fail(fmt.Sprintf("failed to update %s: %s", label, err))
+				continue
+			}
+			or.succeed("Update", fmt.Sprintf("the monitor %s (%s)", m.MonitorName(), id))
+			continue
+		}
+		if or.dryRun("Create", label) {
+			or.monitorIDs[m.MonitorID()] = m.MonitorID()
+			continue
+		}
+		created, err := or.client.CreateMonitor(param)
+		if err != nil {
+			or.fail(fmt.Sprintf("failed to create %s: %s", label, err))
+			continue
+		}
+		or.monitorIDs[m.MonitorID()] = created.MonitorID()
+		or.succeed("Create", fmt.Sprintf("the monitor %s (%s -> %s)", m.MonitorName(), m.MonitorID(), created.MonitorID()))
+	}
+	return nil
+}
+
+// rewriteIDs replaces the IDs in the backup with the restored ones, dropping the unresolved IDs with the warnings
+func rewriteIDs(ids []string, restored map[string]string, label, kind string) []string {
+	if ids == nil {
+		return nil
+	}
+	rewritten := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if r, ok := restored[id]; ok {
+			rewritten = append(rewritten, r)
+		} else {
+			logger.Log("warning", fmt.Sprintf("%s refers to the %s %s which is not restored", label, kind, id))
+		}
+	}
+	return rewritten
+}
+
+// sortNotificationGroups sorts the notification groups so that the child groups come first
+func sortNotificationGroups(groups []*mackerel.NotificationGroup) []*mackerel.NotificationGroup {
+	byID := map[string]*mackerel.NotificationGroup{}
+	for _, g := range groups {
+		byID[g.ID] = g
+	}
+	sorted := make([]*mackerel.NotificationGroup, 0, len(groups))
+	visited := map[string]bool{}
+	var visit func(g *mackerel.NotificationGroup)
+	visit = func(g *mackerel.NotificationGroup) {
+		if visited[g.ID] {
+			return
+		}
+		visited[g.ID] = true
+		for _, id := range g.ChildNotificationGroupIDs {
+			if child, ok := byID[id]; ok {
+				visit(child)
+			}
+		}
+		sorted = append(sorted, g)
+	}
+	for _, g := range groups {
+		visit(g)
+	}
+	return sorted
+}
+
+func (or *orgRestorer) restoreNotificationGroups() error {
+	groups, err := or.client.FindNotificationGroups()
+	if err != nil {
+		return err
+	}
+	existing := map[string]*mackerel.NotificationGroup{}
+	for _, g := range groups {
+		existing[g.ID] = g
+	}
+	for _, g := range sortNotificationGroups(or.backup.NotificationGroups) {
+		label := fmt.Sprintf("the notification group %s (%s)", g.Name, g.ID)
+		param := *g
+		param.ID = ""
+		param.ChildChannelIDs = rewriteIDs(g.ChildChannelIDs, or.channelIDs, label, "channel")
+		param.ChildNotificationGroupIDs = rewriteIDs(g.ChildNotificationGroupIDs, or.notificationGroupIDs, label, "notification group")
+		param.Monitors = nil
+		for _, m := range g.Monitors {
+			for _, id := range rewriteIDs([]string{m.ID}, or.monitorIDs, label, "monitor") {
+				param.Monitors = append(param.Monitors, &mackerel.NotificationGroupMonitor{ID: id, SkipDefault: m.SkipDefault})
+			}
+		}
+
+		if id, ok := or.notificationGroupIDs[g.ID]; ok {
+			if !or.overwrite || sameResource(existing[id], &param) {
+				or.skip(label + " which exists")
+				continue
+			}
+			if or.dryRun("Update", label) {
+				continue
+			}
+			if _, err := or.client.UpdateNotificationGroup(id, &param); err != nil {
+				or.fail(fmt.Sprintf("failed to update %s: %s", label, err))
+				continue
+			}
+			or.succeed("Update", fmt.Sprintf("the notification group %s (%s)", g.Name, id))
+			continue
+		}
+		if or.dryRun("Create", label) {
+			or.notificationGroupIDs[g.ID] = g.ID
+			continue
+		}
+		created, err := or.client.CreateNotificationGroup(&param)
+		if err != nil {
+			or.fail(fmt.Sprintf("failed to create %s: %s", label, err))
+			continue
+		}
+		or.notificationGroupIDs[g.ID] = created.ID
+		or.succeed("Create", fmt.Sprintf("the notification group %s (%s -> %s)", g.Name, g.ID, created.ID))
+	}
+	return nil
+}
+
+func (or *orgRestorer) restoreDowntimes() error {
+	downtimes, err := or.client.FindDowntimes()
+	if err != nil {
+		return err
+	}
+	existing := map[string]*mackerel.Downtime{}
+	for _, d := range downtimes {
+		existing[d.Name] = d
+	}
+	for _, d := range or.backup.Downtimes {
+		label := fmt.Sprintf("the downtime %s (%s)", d.Name, d.ID)
+		param := *d
+		param.ID = ""
+		param.MonitorScopes = rewriteIDs(d.MonitorScopes, or.monitorIDs, label, "monitor")
+		param.MonitorExcludeScopes = rewriteIDs(d.MonitorExcludeScopes, or.monitorIDs, label, "monitor")
+
+		if e, ok := existing[d.Name]; ok {
+			if !or.overwrite || sameResource(e, &param) {
+				or.skip(label + " which exists")
+				continue
+			}
+			if or.dryRun("Update", label) {
+				continue
+			}
+			if _, err := or.client.UpdateDowntime(e.ID, &param); err != nil {
+				or.fail(fmt.Sprintf("failed to update %s: %s", label, err))
+				continue
+			}
+			or.succeed("Update", fmt.Sprintf("the downtime %s (%s)", d.Name, e.ID))
+			continue
+		}
+		if or.dryRun("Create", label) {
+			continue
+		}
+		created, err := or.client.CreateDowntime(&param)
+		if err != nil {
+			or.fail(fmt.Sprintf("failed to create %s: %s", label, err))
+			continue
+		}
+		or.succeed("Create", fmt.Sprintf("the downtime %s (%s -> %s)", d.Name, d.ID, created.ID))
+	}
+	return nil
+}
+
+func (or *orgRestorer) restoreDashboards() error {
+	dashboards, err := or.client.FindDashboards()
+	if err != nil {
+		return err
+	}
+	existing := map[string]*mackerel.Dashboard{}
+	for _, d := range dashboards {
+		existing[d.URLPath] = d
+	}
+	for _, d := range or.backup.Dashboards {
+		label := fmt.Sprintf("the dashboard %s (%s)", d.Title, d.ID)
+		param := *d
+		param.ID, param.CreatedAt, param.UpdatedAt = "", 0, 0
+
+		if e, ok := existing[d.URLPath]; ok {
+			if !or.overwrite {
+				or.skip(label + " which exists")
+				continue
+			}
+			// the list of the dashboards does not have the widgets
+			remote, err := or.client.FindDashboard(e.ID)
+			if err != nil {
+				return err
+			}
+			if diffDashboard(remote, &param, "", "") == "" {
+				or.skip(label + " which exists")
+				continue
+			}
+			if or.dryRun("Update", label) {
+				continue
+			}
+			if _, err := or.client.UpdateDashboard(e.ID, &param); err != nil {
+				or.fail(fmt.Sprintf("failed to update %s: %s", label, err))
+				continue
+			}
+			or.succeed("Update", fmt.Sprintf("the dashboard %s (%s)", d.Title, e.ID))
+			continue
+		}
+		if or.dryRun("Create", label) {
+			continue
+		}
+		created, err := or.client.CreateDashboard(&param)
+		if err != nil {
+			or.fail(fmt.Sprintf("failed to create %s: %s", label, err))
+			continue
+		}
+		or.succeed("Create", fmt.Sprintf("the dashboard %s (%s -> %s)", d.Title, d.ID, created.ID))
+	}
+	return nil
+}
+
+func (or *orgRestorer) restoreAWSIntegrations() error {
+	integrations, err := or.client.FindAWSIntegrations()
+	if err != nil {
+		return err
+	}
+	type awsIntegration struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	decode := func(raw json.RawMessage) (awsIntegration, map[string]json.RawMessage, error) {
+		var a awsIntegration
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(raw, &a); err != nil {
+			return a, nil, err
+		}
+		if err := json.Unmarshal(raw, &fields); err != nil {
+			return a, nil, err
+		}
+		delete(fields, "id")
+		return a, fields, nil
+	}
+	existing := map[string]map[string]json.RawMessage{}
+	existingIDs := map[string]string{}
+	for _, raw := range integrations {
+		a, fields, err := decode(raw)
+		if err != nil {
+			return err
+		}
+		existing[a.Name], existingIDs[a.Name] = fields, a.ID
+	}
+	for _, raw := range or.backup.AWSIntegrations {
+		a, fields, err := decode(raw)
+		if err != nil {
+			return err
+		}
+		label := fmt.Sprintf("the AWS integration %s (%s)", a.Name, a.ID)
+		param, err := json.Marshal(fields)
+		if err != nil {
+			return err
+		}
+
+		if e, ok := existing[a.Name]; ok {
+			if !or.overwrite || sameResource(e, fields) {
+				or.skip(label + " which exists")
+				continue
+			}
+			if or.dryRun("Update", label) {
+				continue
+			}
+			if err := or.client.UpdateAWSIntegration(existingIDs[a.Name], param); err != nil {
+				or.fail(fmt.Sprintf("failed to update %s: %s", label, err))
+				continue
+			}
+			or.succeed("Update", fmt.Sprintf("the AWS integration %s (%s)", a.Name, existingIDs[a.Name]))
+			continue
+		}
+		if or.dryRun("Create", label) {
+			continue
+		}
+		id, err := or.client.CreateAWSIntegration(param)
+		if err != nil {
+			// the secret access key is not in the backup
+			or.fail(fmt.Sprintf("failed to create %s: %s", label, err))
+			continue
+		}
+		or.succeed("Create", fmt.Sprintf("the AWS integration %s (%s -> %s)", a.Name, a.ID, id))
+	}
+	return nil
+}
+
+func doRestore(c *cli.Context) error {
+	filePath := c.String("file-path")
+	if filePath == "" {
+		cli.ShowCommandHelp(c, "restore")
+		return cli.NewExitError("specify the archive file with --file-path.", 1)
+	}
+	conflict := c.String("conflict")
+	if conflict != "skip" && conflict != "overwrite" {
+		return cli.NewExitError(fmt.Sprintf("--conflict should be 'skip' or 'overwrite': %s", conflict), 1)
+	}
+
+	manifest, b, err := openBackupArchive(filePath)
+	if err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	logger.Log("info", fmt.Sprintf("Restore the backup created at %s", manifest.CreatedAt.Local().Format("2006-01-02 15:04:05")))
+
+	resources := splitResources(c.String("resources"))
+	if len(resources) == 0 {
+		for _, r := range backupResources {
+			if _, _, ok := b.resource(r); ok {
+				resources = append(resources, r)
+			}
+		}
+	}
+
+	client := backupAPIClient{mackerelclient.NewFromContext(c)}
+	or := newOrgRestorer(client, b, conflict == "overwrite", c.Bool("dry-run"))
+	if err := or.restore(resources); err != nil {
+		return cli.NewExitError(err.Error(), 1)
+	}
+	return or.result("restore", "resource(s)")
+}
@@ -1,23 +1,73 @@
 package services
 
 import (
+	"fmt"
 	"io"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
 
 	"github.com/mackerelio/mkr/format"
 	"github.com/mackerelio/mkr/mackerelclient"
 )
 
+type appLogger interface {
+	Log(string, string)
+}
+
 type servicesApp struct {
 	client    mackerelclient.Client
+	logger    appLogger
 	outStream io.Writer
 }
 
-func (app *servicesApp) run() error {
+func (app *servicesApp) run(output *format.Output) error {
 	services, err := app.client.FindServices()
 	if err != nil {
 		return err
 	}
 
-	format.PrettyPrintJSON(app.outStream, services)
+	return output.Print(app.outStream, services, func() *format.Table {
+		t := format.NewTable("NAME", "MEMO", "ROLES")
+		for _, s := range services {
+			t.Append(s.Name, s.Memo, strings.Join(s.Roles, ","))
+		}
+		return t
+	})
+}
+
+func (app *servicesApp) createService(name, memo string) error {
+	service, err := app.client.CreateService(&mackerel.CreateServiceParam{Name: name, Memo: memo})
+	if err != nil {
+		return err
+	}
+	app.logger.Log("created", service.Name)
+	return nil
+}
+
+func (app *servicesApp) deleteService(name string) error {
+	service, err := app.client.DeleteService(name)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("deleted", service.Name)
+	return nil
+}
+
+func (app *servicesApp) createRole(serviceName, roleName, memo string) error {
+	role, err := app.client.CreateRole(serviceName, &mackerel.CreateRoleParam{Name: roleName, Memo: memo})
+	if err != nil {
+		return err
+	}
+	app.logger.Log("created", fmt.Sprintf("%s:%s", serviceName, role.Name))
+	return nil
+}
+
+func (app *servicesApp) deleteRole(serviceName, roleName string) error {
+	role, err := app.client.DeleteRole(serviceName, roleName)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("deleted", fmt.Sprintf("%s:%s", serviceName, role.Name))
 	return nil
 }
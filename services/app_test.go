@@ -2,12 +2,15 @@ package services
 
 import (
 	"bytes"
+	"fmt"
+	"io"
 	"testing"
 
 	"github.com/stretchr/testify/assert"
 
 	"github.com/mackerelio/mackerel-client-go"
 
+	"github.com/mackerelio/mkr/format"
 	"github.com/mackerelio/mkr/mackerelclient"
 )
 
@@ -80,8 +83,68 @@ func TestServicesApp_Run(t *testing.T) {
 				client:    client,
 				outStream: out,
 			}
-			assert.NoError(t, app.run())
+			assert.NoError(t, app.run(&format.Output{Format: format.OutputJSON}))
 			assert.Equal(t, tc.expected, out.String())
 		})
 	}
 }
+
+func TestServicesApp_Run_table(t *testing.T) {
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindServices(func() ([]*mackerel.Service, error) {
+			return []*mackerel.Service{
+				{Name: "sample-service", Memo: "sample memo", Roles: []string{"app", "db"}},
+				{Name: "empty-service", Roles: []string{}},
+			}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &servicesApp{
+		client:    client,
+		outStream: out,
+	}
+	assert.NoError(t, app.run(&format.Output{Format: format.OutputTable}))
+	assert.Equal(t, `NAME            MEMO         ROLES
+sample-service  sample memo  app,db
+empty-service                
+`, out.String())
+}
+
+type testLogger struct {
+	w io.Writer
+}
+
+func (l *testLogger) Log(prefix, message string) {
+	fmt.Fprintln(l.w, prefix, message)
+}
+
+func TestServicesApp_CreateDelete(t *testing.T) {
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockCreateService(func(param *mackerel.CreateServiceParam) (*mackerel.Service, error) {
+			assert.Equal(t, &mackerel.CreateServiceParam{Name: "Blog", Memo: "my blog"}, param)
+			return &mackerel.Service{Name: param.Name, Memo: param.Memo}, nil
+		}),
+		mackerelclient.MockDeleteService(func(name string) (*mackerel.Service, error) {
+			return &mackerel.Service{Name: name}, nil
+		}),
+		mackerelclient.MockCreateRole(func(serviceName string, param *mackerel.CreateRoleParam) (*mackerel.Role, error) {
+			assert.Equal(t, "Blog", serviceName)
+			assert.Equal(t, &mackerel.CreateRoleParam{Name: "db", Memo: "database"}, param)
+			return &mackerel.Role{Name: param.Name, Memo: param.Memo}, nil
+		}),
+		mackerelclient.MockDeleteRole(func(serviceName, roleName string) (*mackerel.Role, error) {
+			return nil, fmt.Errorf("role not found")
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &servicesApp{
+		client:    client,
+		logger:    &testLogger{out},
+		outStream: out,
+	}
+	assert.NoError(t, app.createService("Blog", "my blog"))
+	assert.NoError(t, app.createRole("Blog", "db", "database"))
+	assert.Error(t, app.deleteRole("Blog", "web"))
+	assert.NoError(t, app.deleteService("Blog"))
+	assert.Equal(t, "created Blog\ncreated Blog:db\ndeleted Blog\n", out.String())
+}
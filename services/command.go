@@ -1,8 +1,13 @@
 package services
 
 import (
+	"fmt"
+	"io"
 	"os"
 
+	"github.com/Songmu/prompter"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
 	"github.com/mackerelio/mkr/mackerelclient"
 	"github.com/urfave/cli"
 )
@@ -11,22 +16,336 @@ import (
 var Command = cli.Command{
 	Name:      "services",
 	Usage:     "List services",
-	ArgsUsage: "",
+	ArgsUsage: "[--output | -o <format>]",
 	Description: `
     List the information of the services.
     Requests "GET /api/v0/services". See https://mackerel.io/api-docs/entry/services#list.
 `,
 	Action: doServices,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "output, o", Value: "json", Usage: format.OutputUsage},
+	},
+	Subcommands: []cli.Command{
+		{
+			Name:      "pull",
+			Usage:     "Pull services",
+			ArgsUsage: "",
+			Description: `
+    Print the services and their roles with the memos in YAML, which can be pushed by "mkr services push".
+`,
+			Action: doPullServices,
+		},
+		{
+			Name:      "push",
+			Usage:     "Push services",
+			ArgsUsage: "--file-path | -F <file> [--dry-run | -d] [--prune]",
+			Description: `
+    Create the services and roles in the YAML file which do not exist.
+    With --prune, the services and roles which do not exist in the file are deleted.
+    The memos cannot be updated since the API does not support it, and their differences are warned.
+`,
+			Action: doPushServices,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "file-path, F", Value: "", Usage: "The YAML file of the services"},
+				cli.BoolFlag{Name: "dry-run, d", Usage: "Show the changes, but not apply them"},
+				cli.BoolFlag{Name: "prune", Usage: "Delete the services and roles which do not exist in the file"},
+			},
+		},
+		{
+			Name:      "create",
+			Usage:     "Create a service",
+			ArgsUsage: "[--memo <memo>] <serviceName>",
+			Description: `
+    Create a new service.
+    Requests "POST /api/v0/services". See https://mackerel.io/api-docs/entry/services#create.
+`,
+			Action: doCreateService,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "memo", Value: "", Usage: "The memo of the service"},
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete a service",
+			ArgsUsage: "[--force] <serviceName>",
+			Description: `
+    Delete the service. Be careful because the roles and the metrics of the service are also deleted.
+    Requests "DELETE /api/v0/services/<serviceName>". See https://mackerel.io/api-docs/entry/services#delete.
+`,
+			Action: doDeleteService,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "force", Usage: "Delete the service without confirmation"},
+			},
+		},
+		commandMetadata,
+		{
+			Name:  "roles",
+			Usage: "Create or delete roles",
+			Description: `
+    Create or delete roles of the service. With no subcommand specified, this will show all of subcommands.
+`,
+			Subcommands: []cli.Command{
+				{
+					Name:      "create",
+					Usage:     "Create a role",
+					ArgsUsage: "[--memo <memo>] <serviceName> <roleName>",
+					Description: `
+    Create a new role in the service.
+    Requests "POST /api/v0/services/<serviceName>/roles". See https://mackerel.io/api-docs/entry/services#create-role.
+`,
+					Action: doCreateRole,
+					Flags: []cli.Flag{
+						cli.StringFlag{Name: "memo", Value: "", Usage: "The memo of the role"},
+					},
+				},
+				{
+					Name:      "delete",
+					Usage:     "Delete a role",
+					ArgsUsage: "[--force] <serviceName> <roleName>",
+					Description: `
+    Delete the role of the service.
+    Requests "DELETE /api/v0/services/<serviceName>/roles/<roleName>". See https://mackerel.io/api-docs/entry/services#delete-role.
+`,
+					Action: doDeleteRole,
+					Flags: []cli.Flag{
+						cli.BoolFlag{Name: "force", Usage: "Delete the role without confirmation"},
+					},
+				},
+			},
+		},
+	},
+}
+
+func newServicesApp(c *cli.Context) (*servicesApp, error) {
+	client, err := mackerelclient.NewWithProfile(c.GlobalString("conf"), c.GlobalString("apibase"), c.GlobalString("profile"))
+	if err != nil {
+		return nil, err
+	}
+	return &servicesApp{
+		client:    client,
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}, nil
 }
 
 func doServices(c *cli.Context) error {
-	client, err := mackerelclient.New(c.GlobalString("conf"), c.GlobalString("apibase"))
+	app, err := newServicesApp(c)
+	if err != nil {
+		return err
+	}
+	output, err := format.OutputFromContext(c, "json")
 	if err != nil {
 		return err
 	}
+	return app.run(output)
+}
 
-	return (&servicesApp{
-		client:    client,
+func requireArgs(c *cli.Context, n int) {
+	if len(c.Args()) != n {
+		cli.ShowCommandHelp(c, c.Command.Name)
+		os.Exit(1)
+	}
+}
+
+func doCreateService(c *cli.Context) error {
+	requireArgs(c, 1)
+	app, err := newServicesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.createService(c.Args().Get(0), c.String("memo"))
+}
+
+func doDeleteService(c *cli.Context) error {
+	requireArgs(c, 1)
+	name := c.Args().Get(0)
+	if !c.Bool("force") && !prompter.YN("Delete the service "+name+" with its roles and metrics.\nAre you sure?", false) {
+		logger.Log("", "deletion is canceled.")
+		return nil
+	}
+	app, err := newServicesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.deleteService(name)
+}
+
+func doCreateRole(c *cli.Context) error {
+	requireArgs(c, 2)
+	app, err := newServicesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.createRole(c.Args().Get(0), c.Args().Get(1), c.String("memo"))
+}
+
+func doDeleteRole(c *cli.Context) error {
+	requireArgs(c, 2)
+	service, role := c.Args().Get(0), c.Args().Get(1)
+	if !c.Bool("force") && !prompter.YN("Delete the role "+service+":"+role+".\nAre you sure?", false) {
+		logger.Log("", "deletion is canceled.")
+		return nil
+	}
+	app, err := newServicesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.deleteRole(service, role)
+}
+
+func doPullServices(c *cli.Context) error {
+	app, err := newServicesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.pull()
+}
+
+func doPushServices(c *cli.Context) error {
+	filePath := c.String("file-path")
+	if filePath == "" {
+		cli.ShowCommandHelp(c, "push")
+		os.Exit(1)
+	}
+	return Push(c, filePath, c.Bool("dry-run"), c.Bool("prune"))
+}
+
+// Push creates and deletes the services and roles in the YAML file as "mkr services push" does.
+// It is also used by "mkr apply".
+func Push(c *cli.Context, filePath string, dryRun, prune bool) error {
+	app, err := newServicesApp(c)
+	if err != nil {
+		return err
+	}
+	return app.push(pushServicesParam{
+		filePath: filePath,
+		dryRun:   dryRun,
+		prune:    prune,
+	})
+}
+
+var metadataTargetFlags = []cli.Flag{
+	cli.StringFlag{Name: "service, s", Value: "", Usage: "The service of the metadata. Required"},
+	cli.StringFlag{Name: "role, r", Value: "", Usage: "The role of the metadata. The service metadata is used if not specified"},
+}
+
+var commandMetadata = cli.Command{
+	Name:  "metadata",
+	Usage: "Manipulate service and role metadata",
+	Description: `
+    Manipulate the metadata of services and roles. With no subcommand specified, this will show all of subcommands.
+    Requests APIs under "/api/v0/services/<serviceName>/metadata" or "/api/v0/services/<serviceName>/roles/<roleName>/metadata".
+    See https://mackerel.io/api-docs/entry/metadata .
+`,
+	Subcommands: []cli.Command{
+		{
+			Name:      "get",
+			Usage:     "Show metadata",
+			ArgsUsage: "--service | -s <service> [--role | -r <role>] [--namespace <namespace>]",
+			Description: `
+    Show the metadata of the service or the role in the namespace.
+    The namespaces are listed when --namespace is not specified.
+`,
+			Action: doMetadataGet,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: "namespace", Value: "", Usage: "The namespace of the metadata"},
+			}, metadataTargetFlags...),
+		},
+		{
+			Name:      "put",
+			Usage:     "Put metadata",
+			ArgsUsage: "--service | -s <service> [--role | -r <role>] --namespace <namespace> [--file | -F <file>]",
+			Description: `
+    Put the metadata of the service or the role in the namespace from the JSON file or stdin.
+`,
+			Action: doMetadataPut,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: "namespace", Value: "", Usage: "The namespace of the metadata"},
+				cli.StringFlag{Name: "file, F", Value: "", Usage: "Read the metadata from <file>. Read from stdin if not specified"},
+			}, metadataTargetFlags...),
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete metadata",
+			ArgsUsage: "--service | -s <service> [--role | -r <role>] --namespace <namespace>",
+			Description: `
+    Delete the metadata of the service or the role in the namespace.
+`,
+			Action: doMetadataDelete,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: "namespace", Value: "", Usage: "The namespace of the metadata"},
+			}, metadataTargetFlags...),
+		},
+		{
+			Name:      "pull",
+			Usage:     "Pull metadata",
+			ArgsUsage: "--dir <dir> --service | -s <service> [--role | -r <role>]",
+			Description: `
+    Save the metadata of the service and all its roles, or only the role with --role,
+    into <dir>/<service>/<namespace>.json and <dir>/<service>/<role>/<namespace>.json.
+`,
+			Action: doMetadataPull,
+			Flags: append([]cli.Flag{
+				cli.StringFlag{Name: "dir", Value: "", Usage: "The directory to save metadata files"},
+			}, metadataTargetFlags...),
+		},
+		{
+			Name:      "push",
+			Usage:     "Push metadata",
+			ArgsUsage: "--dir <dir> [--service | -s <service>]",
+			Description: `
+    Put the metadata of the files saved by "mkr services metadata pull".
+    Only the metadata of the service and its roles are pushed with --service.
+`,
+			Action: doMetadataPush,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "dir", Value: "", Usage: "The directory of metadata files"},
+				cli.StringFlag{Name: "service, s", Value: "", Usage: "Push only the metadata of <service>"},
+			},
+		},
+	},
+}
+
+func newMetadataApp(c *cli.Context, requiredFlags ...string) *metadataApp {
+	for _, name := range requiredFlags {
+		if c.String(name) == "" {
+			cli.ShowCommandHelp(c, c.Command.Name)
+			os.Exit(1)
+		}
+	}
+	return &metadataApp{
+		client:    &apiMetadataClient{mackerelclient.NewFromContext(c)},
+		logger:    logger.New(),
 		outStream: os.Stdout,
-	}).run()
+	}
+}
+
+func doMetadataGet(c *cli.Context) error {
+	return newMetadataApp(c, "service").get(c.String("service"), c.String("role"), c.String("namespace"))
+}
+
+func doMetadataPut(c *cli.Context) error {
+	app := newMetadataApp(c, "service", "namespace")
+	var r io.Reader = os.Stdin
+	if path := c.String("file"); path != "" {
+		f, err := os.Open(path)
+		if err != nil {
+			return fmt.Errorf("failed to open %s: %s", path, err)
+		}
+		defer f.Close()
+		r = f
+	}
+	return app.put(c.String("service"), c.String("role"), c.String("namespace"), r)
+}
+
+func doMetadataDelete(c *cli.Context) error {
+	return newMetadataApp(c, "service", "namespace").delete(c.String("service"), c.String("role"), c.String("namespace"))
+}
+
+func doMetadataPull(c *cli.Context) error {
+	return newMetadataApp(c, "dir", "service").pull(c.String("dir"), c.String("service"), c.String("role"))
+}
+
+func doMetadataPush(c *cli.Context) error {
+	return newMetadataApp(c, "dir").push(c.String("dir"), c.String("service"))
 }
@@ -0,0 +1,193 @@
+package services
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+)
+
+// metadataClient represents a client of the service metadata API, or the role metadata API if the role is not empty
+type metadataClient interface {
+	FindRoles(serviceName string) ([]*mackerel.Role, error)
+	ListMetadataNamespaces(service, role string) ([]string, error)
+	GetMetadata(service, role, namespace string) (interface{}, error)
+	PutMetadata(service, role, namespace string, metadata interface{}) error
+	DeleteMetadata(service, role, namespace string) error
+}
+
+type apiMetadataClient struct {
+	*mackerel.Client
+}
+
+func (c *apiMetadataClient) ListMetadataNamespaces(service, role string) ([]string, error) {
+	if role == "" {
+		return c.GetServiceMetaDataNameSpaces(service)
+	}
+	return c.GetRoleMetaDataNameSpaces(service, role)
+}
+
+func (c *apiMetadataClient) GetMetadata(service, role, namespace string) (interface{}, error) {
+	if role == "" {
+		resp, err := c.GetServiceMetaData(service, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return resp.ServiceMetaData, nil
+	}
+	resp, err := c.GetRoleMetaData(service, role, namespace)
+	if err != nil {
+		return nil, err
+	}
+	return resp.RoleMetaData, nil
+}
+
+func (c *apiMetadataClient) PutMetadata(service, role, namespace string, metadata interface{}) error {
+	if role == "" {
+		return c.PutServiceMetaData(service, namespace, metadata)
+	}
+	return c.PutRoleMetaData(service, role, namespace, metadata)
+}
+
+func (c *apiMetadataClient) DeleteMetadata(service, role, namespace string) error {
+	if role == "" {
+		return c.DeleteServiceMetaData(service, namespace)
+	}
+	return c.DeleteRoleMetaData(service, role, namespace)
+}
+
+func metadataTarget(service, role string) string {
+	if role == "" {
+		return service
+	}
+	return service + ":" + role
+}
+
+type metadataApp struct {
+	client    metadataClient
+	logger    appLogger
+	outStream io.Writer
+}
+
+func (app *metadataApp) get(service, role, namespace string) error {
+	if namespace == "" {
+		namespaces, err := app.client.ListMetadataNamespaces(service, role)
+		if err != nil {
+			return err
+		}
+		return format.PrettyPrintJSON(app.outStream, namespaces)
+	}
+	metadata, err := app.client.GetMetadata(service, role, namespace)
+	if err != nil {
+		return err
+	}
+	return format.PrettyPrintJSON(app.outStream, metadata)
+}
+
+func (app *metadataApp) put(service, role, namespace string, r io.Reader) error {
+	var metadata interface{}
+	if err := json.NewDecoder(r).Decode(&metadata); err != nil {
+		return fmt.Errorf("metadata should be a valid JSON: %s", err)
+	}
+	if err := app.client.PutMetadata(service, role, namespace, metadata); err != nil {
+		return err
+	}
+	app.logger.Log("updated", metadataTarget(service, role)+" "+namespace)
+	return nil
+}
+
+func (app *metadataApp) delete(service, role, namespace string) error {
+	if err := app.client.DeleteMetadata(service, role, namespace); err != nil {
+		return err
+	}
+	app.logger.Log("deleted", metadataTarget(service, role)+" "+namespace)
+	return nil
+}
+
+// metadataFilePath returns <dir>/<service>/<namespace>.json, or <dir>/<service>/<role>/<namespace>.json for roles.
+func metadataFilePath(dir, service, role, namespace string) string {
+	if role == "" {
+		return filepath.Join(dir, service, namespace+".json")
+	}
+	return filepath.Join(dir, service, role, namespace+".json")
+}
+
+// pull saves the metadata of the role, or the service and all its roles if the role is empty.
+func (app *metadataApp) pull(dir, service, role string) error {
+	roles := []string{role}
+	if role == "" {
+		rs, err := app.client.FindRoles(service)
+		if err != nil {
+			return err
+		}
+		for _, r := range rs {
+			roles = append(roles, r.Name)
+		}
+	}
+	for _, role := range roles {
+		namespaces, err := app.client.ListMetadataNamespaces(service, role)
+		if err != nil {
+			return err
+		}
+		for _, ns := range namespaces {
+			metadata, err := app.client.GetMetadata(service, role, ns)
+			if err != nil {
+				return err
+			}
+			path := metadataFilePath(dir, service, role, ns)
+			if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+				return err
+			}
+			if err := ioutil.WriteFile(path, []byte(format.JSONMarshalIndent(metadata, "", "    ")+"\n"), 0644); err != nil {
+				return err
+			}
+			app.logger.Log("info", fmt.Sprintf("Metadata %s of %s is saved to '%s'.", ns, metadataTarget(service, role), path))
+		}
+	}
+	return nil
+}
+
+// push puts the metadata of the files saved by pull. Only the service is pushed if it is not empty.
+func (app *metadataApp) push(dir, service string) error {
+	var paths []string
+	for _, pattern := range []string{filepath.Join(dir, "*", "*.json"), filepath.Join(dir, "*", "*", "*.json")} {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return err
+		}
+		paths = append(paths, matches...)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		rel, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		parts := strings.Split(filepath.ToSlash(rel), "/")
+		s, role, ns := parts[0], "", strings.TrimSuffix(parts[len(parts)-1], ".json")
+		if len(parts) == 3 {
+			role = parts[1]
+		}
+		if service != "" && s != service {
+			continue
+		}
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = app.put(s, role, ns, f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %s", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package services
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeMetadataClient struct {
+	roles    []*mackerel.Role
+	metadata map[string]map[string]interface{}
+}
+
+func (c *fakeMetadataClient) FindRoles(serviceName string) ([]*mackerel.Role, error) {
+	return c.roles, nil
+}
+
+func (c *fakeMetadataClient) ListMetadataNamespaces(service, role string) ([]string, error) {
+	var namespaces []string
+	for ns := range c.metadata[metadataTarget(service, role)] {
+		namespaces = append(namespaces, ns)
+	}
+	return namespaces, nil
+}
+
+func (c *fakeMetadataClient) GetMetadata(service, role, namespace string) (interface{}, error) {
+	return c.metadata[metadataTarget(service, role)][namespace], nil
+}
+
+func (c *fakeMetadataClient) PutMetadata(service, role, namespace string, metadata interface{}) error {
+	target := metadataTarget(service, role)
+	if c.metadata[target] == nil {
+		c.metadata[target] = map[string]interface{}{}
+	}
+	c.metadata[target][namespace] = metadata
+	return nil
+}
+
+func (c *fakeMetadataClient) DeleteMetadata(service, role, namespace string) error {
+	delete(c.metadata[metadataTarget(service, role)], namespace)
+	return nil
+}
+
+func TestMetadataApp_GetPutDelete(t *testing.T) {
+	client := &fakeMetadataClient{metadata: map[string]map[string]interface{}{}}
+	out := new(bytes.Buffer)
+	app := &metadataApp{client: client, logger: &testLogger{out}, outStream: out}
+
+	assert.NoError(t, app.put("Blog", "db", "deploy", strings.NewReader(`{"version": "1.0"}`)))
+	assert.Error(t, app.put("Blog", "", "deploy", strings.NewReader(`{"version"`)))
+	assert.NoError(t, app.get("Blog", "db", "deploy"))
+	assert.NoError(t, app.delete("Blog", "db", "deploy"))
+	assert.Equal(t, "updated Blog:db deploy\n{\n    \"version\": \"1.0\"\n}\ndeleted Blog:db deploy\n", out.String())
+}
+
+func TestMetadataApp_PullPush(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-services-metadata")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	client := &fakeMetadataClient{
+		roles: []*mackerel.Role{{Name: "db"}},
+		metadata: map[string]map[string]interface{}{
+			"Blog":    {"owner": map[string]interface{}{"team": "web"}},
+			"Blog:db": {"deploy": map[string]interface{}{"version": "1.0"}},
+		},
+	}
+	app := &metadataApp{client: client, logger: &testLogger{ioutil.Discard}, outStream: ioutil.Discard}
+	assert.NoError(t, app.pull(dir, "Blog", ""))
+	data, err := ioutil.ReadFile(filepath.Join(dir, "Blog", "db", "deploy.json"))
+	assert.NoError(t, err)
+	assert.Equal(t, "{\n    \"version\": \"1.0\"\n}\n", string(data))
+
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "Blog", "owner.json"), []byte(`{"team": "infra"}`), 0644))
+	client.metadata = map[string]map[string]interface{}{}
+	assert.NoError(t, app.push(dir, "Blog"))
+	assert.Equal(t, map[string]map[string]interface{}{
+		"Blog":    {"owner": map[string]interface{}{"team": "infra"}},
+		"Blog:db": {"deploy": map[string]interface{}{"version": "1.0"}},
+	}, client.metadata)
+}
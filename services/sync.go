@@ -0,0 +1,194 @@
+package services
+
+import (
+	"fmt"
+	"io/ioutil"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/resourcesync"
+	yaml "gopkg.in/yaml.v2"
+)
+
+type roleConfig struct {
+	Name string `yaml:"name"`
+	Memo string `yaml:"memo,omitempty"`
+}
+
+type serviceConfig struct {
+	Name  string        `yaml:"name"`
+	Memo  string        `yaml:"memo,omitempty"`
+	Roles []*roleConfig `yaml:"roles"`
+}
+
+type servicesConfig struct {
+	Services []*serviceConfig `yaml:"services"`
+}
+
+// fetchServicesConfig fetches the services with the memos of their roles.
+func (app *servicesApp) fetchServicesConfig() (*servicesConfig, error) {
+	services, err := app.client.FindServices()
+	if err != nil {
+		return nil, err
+	}
+	config := &servicesConfig{Services: []*serviceConfig{}}
+	for _, s := range services {
+		roles, err := app.client.FindRoles(s.Name)
+		if err != nil {
+			return nil, err
+		}
+		sc := &serviceConfig{Name: s.Name, Memo: s.Memo, Roles: []*roleConfig{}}
+		for _, r := range roles {
+			sc.Roles = append(sc.Roles, &roleConfig{Name: r.Name, Memo: r.Memo})
+		}
+		config.Services = append(config.Services, sc)
+	}
+	return config, nil
+}
+
+func (app *servicesApp) pull() error {
+	config, err := app.fetchServicesConfig()
+	if err != nil {
+		return err
+	}
+	data, err := yaml.Marshal(config)
+	if err != nil {
+		return err
+	}
+	_, err = app.outStream.Write(data)
+	return err
+}
+
+func loadServicesConfig(filePath string) (*servicesConfig, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	var config servicesConfig
+	if err := yaml.UnmarshalStrict(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to load %s: %s", filePath, err)
+	}
+	names := map[string]bool{}
+	for _, s := range config.Services {
+		if s.Name == "" {
+			return nil, fmt.Errorf("failed to load %s: the name of a service is empty", filePath)
+		}
+		if names[s.Name] {
+			return nil, fmt.Errorf("failed to load %s: the service %s is duplicated", filePath, s.Name)
+		}
+		names[s.Name] = true
+	}
+	return &config, nil
+}
+
+type serviceChange struct {
+	action  string
+	service string
+	role    string
+	memo    string
+}
+
+func (c *serviceChange) Action() string {
+	return c.action
+}
+
+func (c *serviceChange) Target() string {
+	if c.role != "" {
+		return c.service + ":" + c.role
+	}
+	return c.service
+}
+
+// planServices returns the changes to make the current services the desired ones, and the warnings of
+// the differences which cannot be applied since the API does not support updating memos.
+// The services and roles which do not exist in the desired ones are deleted only if prune is true.
+func planServices(current, desired *servicesConfig, prune bool) ([]*serviceChange, []string) {
+	var creates, deletes []*serviceChange
+	var warnings []string
+	currentServices := map[string]*serviceConfig{}
+	for _, s := range current.Services {
+		currentServices[s.Name] = s
+	}
+	desiredServices := map[string]bool{}
+	for _, ds := range desired.Services {
+		desiredServices[ds.Name] = true
+		cs, ok := currentServices[ds.Name]
+		if !ok {
+			creates = append(creates, &serviceChange{action: "create", service: ds.Name, memo: ds.Memo})
+			cs = &serviceConfig{}
+		} else if cs.Memo != ds.Memo {
+			warnings = append(warnings, fmt.Sprintf("the memo of %s differs but cannot be updated", ds.Name))
+		}
+		currentRoles := map[string]*roleConfig{}
+		for _, r := range cs.Roles {
+			currentRoles[r.Name] = r
+		}
+		desiredRoles := map[string]bool{}
+		for _, dr := range ds.Roles {
+			desiredRoles[dr.Name] = true
+			if cr, ok := currentRoles[dr.Name]; !ok {
+				creates = append(creates, &serviceChange{action: "create", service: ds.Name, role: dr.Name, memo: dr.Memo})
+			} else if cr.Memo != dr.Memo {
+				warnings = append(warnings, fmt.Sprintf("the memo of %s:%s differs but cannot be updated", ds.Name, dr.Name))
+			}
+		}
+		if prune {
+			for _, cr := range cs.Roles {
+				if !desiredRoles[cr.Name] {
+					deletes = append(deletes, &serviceChange{action: "delete", service: ds.Name, role: cr.Name})
+				}
+			}
+		}
+	}
+	if prune {
+		for _, cs := range current.Services {
+			if !desiredServices[cs.Name] {
+				// the roles are deleted with the service
+				deletes = append(deletes, &serviceChange{action: "delete", service: cs.Name})
+			}
+		}
+	}
+	return append(creates, deletes...), warnings
+}
+
+func (app *servicesApp) applyServiceChange(c *serviceChange) error {
+	var err error
+	switch {
+	case c.action == "create" && c.role == "":
+		_, err = app.client.CreateService(&mackerel.CreateServiceParam{Name: c.service, Memo: c.memo})
+	case c.action == "create":
+		_, err = app.client.CreateRole(c.service, &mackerel.CreateRoleParam{Name: c.role, Memo: c.memo})
+	case c.action == "delete" && c.role == "":
+		_, err = app.client.DeleteService(c.service)
+	case c.action == "delete":
+		_, err = app.client.DeleteRole(c.service, c.role)
+	}
+	return err
+}
+
+type pushServicesParam struct {
+	filePath string
+	dryRun   bool
+	prune    bool
+}
+
+func (app *servicesApp) push(param pushServicesParam) error {
+	desired, err := loadServicesConfig(param.filePath)
+	if err != nil {
+		return err
+	}
+	current, err := app.fetchServicesConfig()
+	if err != nil {
+		return err
+	}
+	changes, warnings := planServices(current, desired, param.prune)
+	for _, w := range warnings {
+		app.logger.Log("warning", w)
+	}
+	steps := make([]resourcesync.Change, len(changes))
+	for i, c := range changes {
+		steps[i] = c
+	}
+	return resourcesync.Apply(app.outStream, app.logger, "services", steps, param.dryRun, func(c resourcesync.Change) error {
+		return app.applyServiceChange(c.(*serviceChange))
+	})
+}
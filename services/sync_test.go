@@ -0,0 +1,84 @@
+package services
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+func TestPlanServices(t *testing.T) {
+	current := &servicesConfig{Services: []*serviceConfig{
+		{Name: "Blog", Memo: "my blog", Roles: []*roleConfig{{Name: "db"}, {Name: "web", Memo: "frontend"}}},
+		{Name: "Old", Roles: []*roleConfig{{Name: "app"}}},
+	}}
+	desired := &servicesConfig{Services: []*serviceConfig{
+		{Name: "Blog", Memo: "blog", Roles: []*roleConfig{{Name: "web", Memo: "frontend"}, {Name: "cache"}}},
+		{Name: "Shop", Memo: "my shop", Roles: []*roleConfig{{Name: "app", Memo: "application"}}},
+	}}
+
+	changes, warnings := planServices(current, desired, false)
+	assert.Equal(t, []*serviceChange{
+		{action: "create", service: "Blog", role: "cache"},
+		{action: "create", service: "Shop", memo: "my shop"},
+		{action: "create", service: "Shop", role: "app", memo: "application"},
+	}, changes)
+	assert.Equal(t, []string{"the memo of Blog differs but cannot be updated"}, warnings)
+
+	changes, _ = planServices(current, desired, true)
+	assert.Equal(t, []*serviceChange{
+		{action: "create", service: "Blog", role: "cache"},
+		{action: "create", service: "Shop", memo: "my shop"},
+		{action: "create", service: "Shop", role: "app", memo: "application"},
+		{action: "delete", service: "Blog", role: "db"},
+		{action: "delete", service: "Old"},
+	}, changes)
+}
+
+func TestServicesApp_PullPush(t *testing.T) {
+	client := mackerelclient.NewMockClient(
+		mackerelclient.MockFindServices(func() ([]*mackerel.Service, error) {
+			return []*mackerel.Service{{Name: "Blog", Memo: "my blog", Roles: []string{"db"}}}, nil
+		}),
+		mackerelclient.MockFindRoles(func(serviceName string) ([]*mackerel.Role, error) {
+			return []*mackerel.Role{{Name: "db", Memo: "database"}}, nil
+		}),
+		mackerelclient.MockCreateRole(func(serviceName string, param *mackerel.CreateRoleParam) (*mackerel.Role, error) {
+			return &mackerel.Role{Name: param.Name}, nil
+		}),
+	)
+	out := new(bytes.Buffer)
+	app := &servicesApp{
+		client:    client,
+		logger:    &testLogger{out},
+		outStream: out,
+	}
+	assert.NoError(t, app.pull())
+	pulled := `services:
+- name: Blog
+  memo: my blog
+  roles:
+  - name: db
+    memo: database
+`
+	assert.Equal(t, pulled, out.String())
+
+	f, err := ioutil.TempFile("", "mkr-services")
+	assert.NoError(t, err)
+	defer os.Remove(f.Name())
+	f.WriteString(pulled + "  - name: web\n")
+	f.Close()
+
+	out.Reset()
+	assert.NoError(t, app.push(pushServicesParam{filePath: f.Name(), dryRun: true}))
+	assert.Equal(t, "create Blog:web\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, app.push(pushServicesParam{filePath: f.Name()}))
+	assert.Equal(t, "created Blog:web\n", out.String())
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+)
+
+type statusClient interface {
+	hostFinder
+	FindHost(id string) (*mackerel.Host, error)
+}
+
+// fetchHosts fetches the hosts with up to parallel requests at once. The hosts are
+// returned in the order of ids, and the hosts failed to fetch are reported in errs.
+func fetchHosts(client statusClient, ids []string, parallel int) ([]*mackerel.Host, []error) {
+	if parallel < 1 {
+		parallel = 1
+	}
+	var (
+		wg      sync.WaitGroup
+		sem     = make(chan struct{}, parallel)
+		fetched = make([]*mackerel.Host, len(ids))
+		errors  = make([]error, len(ids))
+	)
+	for i, id := range ids {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(i int, id string) {
+			defer func() { <-sem; wg.Done() }()
+			var err error
+			fetched[i], err = client.FindHost(id)
+			if err != nil {
+				errors[i] = fmt.Errorf("failed to fetch the host %s: %s", id, err)
+			}
+		}(i, id)
+	}
+	wg.Wait()
+
+	var (
+		hosts []*mackerel.Host
+		errs  []error
+	)
+	for i := range ids {
+		if errors[i] != nil {
+			errs = append(errs, errors[i])
+		} else {
+			hosts = append(hosts, fetched[i])
+		}
+	}
+	return hosts, errs
+}
+
+// findStatusHosts finds the hosts specified by the IDs and the hosts selected by the selector.
+func findStatusHosts(client statusClient, ids []string, selector *hostSelector, parallel int) ([]*mackerel.Host, []error, error) {
+	var selected []*mackerel.Host
+	if !selector.isEmpty() {
+		var err error
+		if selected, err = selectHosts(client, selector); err != nil {
+			return nil, nil, err
+		}
+	}
+	hosts, errs := fetchHosts(client, mergeHostIDs(ids, nil), parallel)
+	seen := map[string]bool{}
+	for _, h := range hosts {
+		seen[h.ID] = true
+	}
+	for _, h := range selected {
+		if !seen[h.ID] {
+			seen[h.ID] = true
+			hosts = append(hosts, h)
+		}
+	}
+	return hosts, errs, nil
+}
+
+func hostStatus(host *mackerel.Host) *format.Host {
+	return &format.Host{
+		ID:            host.ID,
+		Name:          host.Name,
+		DisplayName:   host.DisplayName,
+		Status:        host.Status,
+		RoleFullnames: host.GetRoleFullnames(),
+		IsRetired:     host.IsRetired,
+		CreatedAt:     format.ISO8601Extended(host.DateFromCreatedAt()),
+		IPAddresses:   host.IPAddresses(),
+	}
+}
+
+// hostStatusesTable builds the table of the hosts for mkr status.
+func hostStatusesTable(hosts []*mackerel.Host) *format.Table {
+	t := format.NewTable("ID", "NAME", "STATUS", "ROLES", "IP", "CREATED_AT")
+	for _, h := range hosts {
+		var ips []string
+		for _, iface := range h.Interfaces {
+			if iface.IPAddress != "" {
+				ips = append(ips, iface.IPAddress)
+			}
+		}
+		t.Append(h.ID, h.Name, h.Status, strings.Join(h.GetRoleFullnames(), ","), strings.Join(ips, ","),
+			format.ISO8601Extended(h.DateFromCreatedAt()))
+	}
+	return t
+}
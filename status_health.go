@@ -0,0 +1,184 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+)
+
+// hostHealth is the summary of the latest metrics and the open alerts of a host for mkr status --with-metrics.
+// The metrics not posted by the host are omitted.
+type hostHealth struct {
+	State        string   `json:"state"`
+	LoadAvg5     *float64 `json:"loadavg5,omitempty"`
+	CPU          *float64 `json:"cpuPercentage,omitempty"`
+	Memory       *float64 `json:"memoryPercentage,omitempty"`
+	Disk         *float64 `json:"diskPercentage,omitempty"`
+	DiskName     string   `json:"diskName,omitempty"`
+	Connectivity string   `json:"connectivity"`
+	OpenAlerts   int      `json:"openAlerts"`
+}
+
+// hostStatusWithHealth and hostWithHealth add the health to the outputs of mkr status
+type hostStatusWithHealth struct {
+	*format.Host
+	Health *hostHealth `json:"health"`
+}
+
+type hostWithHealth struct {
+	*mackerel.Host
+	Health *hostHealth `json:"health"`
+}
+
+// isHealthMetricName reports whether the metric is used for the health of the host.
+func isHealthMetricName(name string) bool {
+	switch {
+	case name == "loadavg5", name == "memory.total", name == "memory.used", name == "memory.available":
+		return true
+	case strings.HasPrefix(name, "cpu.") && strings.HasSuffix(name, ".percentage"):
+		return true
+	case strings.HasPrefix(name, "filesystem.") && (strings.HasSuffix(name, ".size") || strings.HasSuffix(name, ".used")):
+		return true
+	}
+	return false
+}
+
+// hostHealthFromMetrics summarizes the latest metric values of a host.
+// The cpu usage is the ratio of the non-idle time, and the disk usage is of the fullest filesystem.
+func hostHealthFromMetrics(values map[string]*mackerel.MetricValue) *hostHealth {
+	metrics := make(map[string]float64, len(values))
+	for name, v := range values {
+		if v == nil {
+			continue
+		}
+		if f, ok := metricValueFloat(v.Value); ok {
+			metrics[name] = f
+		}
+	}
+	percentage := func(x, total float64) *float64 {
+		p := x / total * 100
+		return &p
+	}
+
+	health := &hostHealth{}
+	if v, ok := metrics["loadavg5"]; ok {
+		health.LoadAvg5 = &v
+	}
+
+	var cpuTotal float64
+	for name, v := range metrics {
+		if strings.HasPrefix(name, "cpu.") && strings.HasSuffix(name, ".percentage") {
+			cpuTotal += v
+		}
+	}
+	if idle, ok := metrics["cpu.idle.percentage"]; ok && cpuTotal > 0 {
+		health.CPU = percentage(cpuTotal-idle, cpuTotal)
+	}
+
+	if total := metrics["memory.total"]; total > 0 {
+		if available, ok := metrics["memory.available"]; ok {
+			health.Memory = percentage(total-available, total)
+		} else if used, ok := metrics["memory.used"]; ok {
+			health.Memory = percentage(used, total)
+		}
+	}
+
+	var filesystems []string
+	for name := range metrics {
+		if strings.HasPrefix(name, "filesystem.") && strings.HasSuffix(name, ".size") {
+			filesystems = append(filesystems, strings.TrimSuffix(strings.TrimPrefix(name, "filesystem."), ".size"))
+		}
+	}
+	sort.Strings(filesystems)
+	for _, fs := range filesystems {
+		size, used := metrics["filesystem."+fs+".size"], metrics["filesystem."+fs+".used"]
+		if size <= 0 {
+			continue
+		}
+		if p := percentage(used, size); health.Disk == nil || *p > *health.Disk {
+			health.Disk, health.DiskName = p, fs
+		}
+	}
+	return health
+}
+
+// addHostAlerts adds the open alerts of the host to the health, and decides the state of the host.
+// The state is CRITICAL with a connectivity alert, WARNING with the other alerts, or OK.
+func (h *hostHealth) addHostAlerts(hostID string, alerts []*mackerel.Alert) {
+	h.Connectivity, h.State = "OK", "OK"
+	for _, a := range alerts {
+		if a.HostID != hostID || a.Status == "OK" {
+			continue
+		}
+		h.OpenAlerts++
+		if a.Type == "connectivity" {
+			h.Connectivity = a.Status
+		}
+	}
+	if h.Connectivity != "OK" {
+		h.State = "CRITICAL"
+	} else if h.OpenAlerts > 0 {
+		h.State = "WARNING"
+	}
+}
+
+// fetchHostHealths fetches the latest metrics of the hosts and summarizes them with the open alerts.
+func fetchHostHealths(client latestMetricFetcher, hosts []*mackerel.Host, alerts []*mackerel.Alert) (map[string]*hostHealth, error) {
+	healths := make(map[string]*hostHealth, len(hosts))
+	for _, host := range hosts {
+		names, err := client.ListHostMetricNames(host.ID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch the metric names of the host %s: %s", host.ID, err)
+		}
+		var healthNames []string
+		for _, name := range names {
+			if isHealthMetricName(name) {
+				healthNames = append(healthNames, name)
+			}
+		}
+		var latest mackerel.LatestMetricValues
+		if len(healthNames) > 0 {
+			if latest, err = client.FetchLatestMetricValues([]string{host.ID}, healthNames); err != nil {
+				return nil, fmt.Errorf("failed to fetch the metrics of the host %s: %s", host.ID, err)
+			}
+		}
+		health := hostHealthFromMetrics(latest[host.ID])
+		health.addHostAlerts(host.ID, alerts)
+		healths[host.ID] = health
+	}
+	return healths, nil
+}
+
+func formatHealthValue(v *float64, unit string) string {
+	if v == nil {
+		return "-"
+	}
+	return fmt.Sprintf("%.1f%s", *v, unit)
+}
+
+// summary describes the health in a line such as
+// "OK loadavg5 0.5, cpu 12.3%, memory 45.0%, disk 80.1% (sda1), connectivity OK, 0 open alerts".
+func (h *hostHealth) summary() string {
+	disk := formatHealthValue(h.Disk, "%")
+	if h.DiskName != "" {
+		disk += " (" + h.DiskName + ")"
+	}
+	return fmt.Sprintf("%s loadavg5 %s, cpu %s, memory %s, disk %s, connectivity %s, %d open alerts",
+		h.State, formatHealthValue(h.LoadAvg5, ""), formatHealthValue(h.CPU, "%"), formatHealthValue(h.Memory, "%"),
+		disk, h.Connectivity, h.OpenAlerts)
+}
+
+// hostHealthsTable builds the table of the hosts with the health for mkr status --with-metrics.
+func hostHealthsTable(hosts []*mackerel.Host, healths map[string]*hostHealth) *format.Table {
+	t := format.NewTable("ID", "NAME", "STATUS", "STATE", "LOADAVG5", "CPU", "MEMORY", "DISK", "CONNECTIVITY", "OPEN_ALERTS")
+	for _, h := range hosts {
+		health := healths[h.ID]
+		t.Append(h.ID, h.Name, h.Status, health.State, formatHealthValue(health.LoadAvg5, ""),
+			formatHealthValue(health.CPU, "%"), formatHealthValue(health.Memory, "%"), formatHealthValue(health.Disk, "%"),
+			health.Connectivity, fmt.Sprint(health.OpenAlerts))
+	}
+	return t
+}
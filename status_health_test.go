@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+)
+
+func TestFetchHostHealths(t *testing.T) {
+	metric := func(v float64) *mackerel.MetricValue { return &mackerel.MetricValue{Value: v} }
+	fetcher := &fakeLatestMetricFetcher{latest: mackerel.LatestMetricValues{
+		"host1": {
+			"loadavg5":                      metric(0.5),
+			"cpu.user.percentage":           metric(30.0),
+			"cpu.system.percentage":         metric(10.0),
+			"cpu.idle.percentage":           metric(360.0),
+			"memory.total":                  metric(4000.0),
+			"memory.available":              metric(1000.0),
+			"memory.used":                   metric(2000.0),
+			"filesystem.sda1.size":          metric(100.0),
+			"filesystem.sda1.used":          metric(40.0),
+			"filesystem.sdb1.size":          metric(200.0),
+			"filesystem.sdb1.used":          metric(180.0),
+			"interface.eth0.rxBytes.delta":  metric(1.0),
+			"custom.filesystem.size.latest": metric(1.0),
+		},
+		"host2": {},
+	}}
+	alerts := []*mackerel.Alert{
+		{HostID: "host1", Type: "host", Status: "WARNING"},
+		{HostID: "host2", Type: "connectivity", Status: "CRITICAL"},
+		{HostID: "host3", Type: "host", Status: "CRITICAL"},
+	}
+	hosts := []*mackerel.Host{{ID: "host1", Name: "app001", Status: "working"}, {ID: "host2", Name: "app002", Status: "working"}}
+	healths, err := fetchHostHealths(fetcher, hosts, alerts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expect := "WARNING loadavg5 0.5, cpu 10.0%, memory 75.0%, disk 90.0% (sdb1), connectivity OK, 1 open alerts"
+	if got := healths["host1"].summary(); got != expect {
+		t.Errorf("summary should be %q but: %q", expect, got)
+	}
+	expect = "CRITICAL loadavg5 -, cpu -, memory -, disk -, connectivity CRITICAL, 1 open alerts"
+	if got := healths["host2"].summary(); got != expect {
+		t.Errorf("summary should be %q but: %q", expect, got)
+	}
+
+	output, _ := format.ParseOutput("tsv")
+	var buf bytes.Buffer
+	if err := output.Print(&buf, nil, func() *format.Table { return hostHealthsTable(hosts, healths) }); err != nil {
+		t.Fatal(err)
+	}
+	expect = "ID\tNAME\tSTATUS\tSTATE\tLOADAVG5\tCPU\tMEMORY\tDISK\tCONNECTIVITY\tOPEN_ALERTS\n" +
+		"host1\tapp001\tworking\tWARNING\t0.5\t10.0%\t75.0%\t90.0%\tOK\t1\n" +
+		"host2\tapp002\tworking\tCRITICAL\t-\t-\t-\t-\tCRITICAL\t1\n"
+	if buf.String() != expect {
+		t.Errorf("output should be %q but: %q", expect, buf.String())
+	}
+}
@@ -0,0 +1,69 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"reflect"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+)
+
+type fakeStatusClient struct {
+	fakeHostFinder
+	hostsByID map[string]*mackerel.Host
+}
+
+func (f *fakeStatusClient) FindHost(id string) (*mackerel.Host, error) {
+	if h, ok := f.hostsByID[id]; ok {
+		return h, nil
+	}
+	return nil, fmt.Errorf("host not found")
+}
+
+func TestFindStatusHosts(t *testing.T) {
+	client := &fakeStatusClient{
+		fakeHostFinder: fakeHostFinder{hosts: []*mackerel.Host{{ID: "host2"}, {ID: "host3"}}},
+		hostsByID: map[string]*mackerel.Host{
+			"host1": {ID: "host1"},
+			"host2": {ID: "host2"},
+		},
+	}
+	hosts, errs, err := findStatusHosts(client, []string{"host2", "host1", "host2", "host9"}, &hostSelector{service: "MyApp", roles: []string{"db"}}, 2)
+	if err != nil {
+		t.Fatalf("err should be nil but: %s", err)
+	}
+	var ids []string
+	for _, h := range hosts {
+		ids = append(ids, h.ID)
+	}
+	if expect := []string{"host2", "host1", "host3"}; !reflect.DeepEqual(ids, expect) {
+		t.Errorf("hosts should be %v but: %v", expect, ids)
+	}
+	if len(errs) != 1 || errs[0].Error() != "failed to fetch the host host9: host not found" {
+		t.Errorf("the host failed to fetch should be reported but: %v", errs)
+	}
+
+	if _, _, err := findStatusHosts(client, nil, &hostSelector{roles: []string{"db"}}, 1); err == nil {
+		t.Errorf("err should occur when --role is specified without --service")
+	}
+}
+
+func TestHostStatusesTable(t *testing.T) {
+	hosts := []*mackerel.Host{
+		{ID: "host1", Name: "db1", Status: "working", CreatedAt: 1700000000,
+			Roles:      mackerel.Roles{"MyApp": {"db"}},
+			Interfaces: []mackerel.Interface{{Name: "lo"}, {Name: "eth0", IPAddress: "10.0.0.1"}}},
+	}
+	output, _ := format.ParseOutput("tsv")
+	var buf bytes.Buffer
+	if err := output.Print(&buf, nil, func() *format.Table { return hostStatusesTable(hosts) }); err != nil {
+		t.Fatal(err)
+	}
+	expect := "ID\tNAME\tSTATUS\tROLES\tIP\tCREATED_AT\n" +
+		"host1\tdb1\tworking\tMyApp:db\t10.0.0.1\t" + format.ISO8601Extended(hosts[0].DateFromCreatedAt()) + "\n"
+	if buf.String() != expect {
+		t.Errorf("output should be %q but: %q", expect, buf.String())
+	}
+}
@@ -4,7 +4,6 @@ import (
 	"bufio"
 	"fmt"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
@@ -18,65 +17,93 @@ import (
 var commandThrow = cli.Command{
 	Name:      "throw",
 	Usage:     "Post metric values",
-	ArgsUsage: "[--host | -H <hostId>] [--service | -s <service>] [--retry | -r N ] stdin",
+	ArgsUsage: "[--host | -H <hostId> | --host-name <name>] [--service | -s <service>] [--retry | -r N ] [--input-format sensu|graphite|jsonl|prometheus] [--register-graph-def [--graph-unit <unit>] [--graph-display-name <name>] [--graph-def-file <file>]] stdin",
 	Description: `
     Post metric values to 'host metric' or 'service metric'.
-    Output format of metric values are compatible with that of a Sensu plugin.
+    Output format of metric values are compatible with that of a Sensu plugin by default.
+    The Graphite plaintext protocol, JSON lines of {"name", "value", "time"} and the Prometheus exposition format
+    are also accepted with --input-format. The label values of Prometheus are appended to the metric name.
     Requests "POST /api/v0/tsdb". See https://mackerel.io/api-docs/entry/host-metrics#post .
     Automatically retries the API request when --retry is specified.
+    With --register-graph-def, the graph definitions of the metrics are registered before posting them, so that the graphs
+    are shown with the unit. The graph of a metric is named by removing the last part of the metric name, such as
+    custom.queue of custom.queue.size, and the graph definitions in --graph-def-file of "mkr graph-defs push" are
+    used for the graphs defined in it. Requests "POST /api/v0/graph-defs/create".
 `,
 	Action: doThrow,
 	Flags: []cli.Flag{
 		cli.StringFlag{Name: "host, H", Value: "", Usage: "Post host metric values to <hostID>."},
+		mackerelclient.HostNameFlag,
 		cli.StringFlag{Name: "service, s", Value: "", Usage: "Post service metric values to <service>."},
 		cli.IntFlag{Name: "retry, r", Usage: "Retries up to N times when API request fails."},
+		cli.StringFlag{Name: "input-format", Value: "sensu", Usage: "Input format: 'sensu', 'graphite', 'jsonl' or 'prometheus'"},
+		cli.BoolFlag{Name: "register-graph-def", Usage: "Register the graph definitions of the metrics before posting them"},
+		cli.StringFlag{Name: "graph-unit", Value: "float", Usage: "The unit of the registered graphs: " + strings.Join(graphDefUnits, ", ")},
+		cli.StringFlag{Name: "graph-display-name", Value: "", Usage: "The display name of the registered graphs"},
+		cli.StringFlag{Name: "graph-def-file", Value: "", Usage: "The JSON or YAML file of the graph definitions to register"},
 	},
 }
 
 func doThrow(c *cli.Context) error {
-	optHostID := c.String("host")
 	optService := c.String("service")
 	optMaxRetry := c.Int("retry")
 
-	var metricValues []*(mackerel.MetricValue)
+	parse, ok := metricLineParsers[c.String("input-format")]
+	if !ok {
+		return fmt.Errorf("input-format should be 'sensu', 'graphite', 'jsonl' or 'prometheus': %s", c.String("input-format"))
+	}
 
-	scanner := bufio.NewScanner(os.Stdin)
-	for scanner.Scan() {
-		line := scanner.Text()
+	client := mackerelclient.NewFromContext(c)
+	optHostID, err := mackerelclient.HostIDFromContext(c, client, "host")
+	logger.DieIf(err)
 
-		// name, value, timestamp
-		// ex.) tcp.CLOSING 0 1397031808
-		items := strings.Fields(line)
-		if len(items) != 3 {
-			continue
+	var definedGraphs []*mackerel.GraphDefsParam
+	if c.Bool("register-graph-def") {
+		if unit := c.String("graph-unit"); !containsString(graphDefUnits, unit) {
+			return cli.NewExitError(fmt.Sprintf("graph-unit should be one of %s: %s", strings.Join(graphDefUnits, ", "), unit), 1)
 		}
-		value, err := strconv.ParseFloat(items[1], 64)
-		if err != nil {
-			logger.Log("warning", fmt.Sprintf("Failed to parse values: %s", err))
-			continue
+		if filePath := c.String("graph-def-file"); filePath != "" {
+			if definedGraphs, err = loadGraphDefs(filePath); err != nil {
+				return cli.NewExitError(err.Error(), 1)
+			}
 		}
-		time, err := strconv.ParseInt(items[2], 10, 64)
+	}
+
+	var metricValues []*(mackerel.MetricValue)
+
+	scanner := bufio.NewScanner(os.Stdin)
+	for scanner.Scan() {
+		values, err := parse(scanner.Text(), time.Now())
 		if err != nil {
 			logger.Log("warning", fmt.Sprintf("Failed to parse values: %s", err))
 			continue
 		}
 
-		name := items[0]
-		if optHostID != "" && !strings.HasPrefix(name, "custom.") {
-			name = "custom." + name
-		}
-
-		metricValue := &mackerel.MetricValue{
-			Name:  name,
-			Value: value,
-			Time:  time,
+		for _, metricValue := range values {
+			if optHostID != "" && !strings.HasPrefix(metricValue.Name, "custom.") {
+				metricValue.Name = "custom." + metricValue.Name
+			}
+			metricValues = append(metricValues, metricValue)
 		}
-
-		metricValues = append(metricValues, metricValue)
 	}
 	logger.ErrorIf(scanner.Err())
 
-	client := mackerelclient.NewFromContext(c)
+	if c.Bool("register-graph-def") && len(metricValues) > 0 && (optHostID != "" || optService != "") {
+		namePrefix := ""
+		if optHostID != "" {
+			namePrefix = hostGraphDefPrefix
+		}
+		graphDefs := buildThrowGraphDefs(metricValues, definedGraphs, c.String("graph-unit"), c.String("graph-display-name"))
+		if err := validateGraphDefs(graphDefs, namePrefix); err != nil {
+			return cli.NewExitError(fmt.Sprintf("failed to register the graph definitions: %s", err), 1)
+		}
+		logger.DieIf(requestWithRetry(func() error {
+			return client.CreateGraphDefs(graphDefs)
+		}, optMaxRetry))
+		for _, g := range graphDefs {
+			logger.Log("registered", "the graph definition of "+g.Name)
+		}
+	}
 
 	if optHostID != "" {
 		logger.DieIf(requestWithRetry(func() error {
@@ -101,6 +128,44 @@ func doThrow(c *cli.Context) error {
 	return nil
 }
 
+// buildThrowGraphDefs returns the graph definitions of the graphs of the metrics. The graph of a metric is
+// named by removing the last part of the metric name. The graphs in defined are used as they are, and the
+// other graphs are defined with the unit and the display name to show all the metrics of the graphs.
+func buildThrowGraphDefs(metricValues []*mackerel.MetricValue, defined []*mackerel.GraphDefsParam, unit, displayName string) []*mackerel.GraphDefsParam {
+	definedGraphs := make(map[string]*mackerel.GraphDefsParam, len(defined))
+	for _, g := range defined {
+		definedGraphs[g.Name] = g
+	}
+	var graphDefs []*mackerel.GraphDefsParam
+	seen := map[string]bool{}
+	for _, v := range metricValues {
+		i := strings.LastIndex(v.Name, ".")
+		if i <= 0 {
+			if !seen[v.Name] {
+				seen[v.Name] = true
+				logger.Log("warning", fmt.Sprintf("the graph definition of %s is not registered since the name has no dots", v.Name))
+			}
+			continue
+		}
+		name := v.Name[:i]
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		if g, ok := definedGraphs[name]; ok {
+			graphDefs = append(graphDefs, g)
+			continue
+		}
+		graphDefs = append(graphDefs, &mackerel.GraphDefsParam{
+			Name:        name,
+			DisplayName: displayName,
+			Unit:        unit,
+			Metrics:     []*mackerel.GraphDefsMetric{{Name: name + ".*"}},
+		})
+	}
+	return graphDefs
+}
+
 var minInterval = 15 * time.Second
 
 func requestWithRetry(f func() error, maxRetry int) error {
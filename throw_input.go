@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// metricLineParser parses a line into metric values. It returns no values for the lines to be ignored.
+type metricLineParser func(line string, now time.Time) ([]*mackerel.MetricValue, error)
+
+var metricLineParsers = map[string]metricLineParser{
+	"sensu":      parseSensuLine,
+	"graphite":   parseGraphiteLine,
+	"jsonl":      parseJSONLine,
+	"prometheus": parsePrometheusLine,
+}
+
+// ex.) tcp.CLOSING 0 1397031808
+func parseSensuLine(line string, now time.Time) ([]*mackerel.MetricValue, error) {
+	items := strings.Fields(line)
+	if len(items) != 3 {
+		return nil, nil
+	}
+	return parseMetricItems(items[0], items[1], items[2])
+}
+
+// The timestamp of the graphite plaintext protocol can be omitted or -1 to be the current time.
+// ex.) servers.web1.loadavg 0.5 1397031808
+func parseGraphiteLine(line string, now time.Time) ([]*mackerel.MetricValue, error) {
+	items := strings.Fields(line)
+	switch {
+	case len(items) == 2, len(items) == 3 && items[2] == "-1":
+		return parseMetricItems(sanitizeMetricName(items[0]), items[1], strconv.FormatInt(now.Unix(), 10))
+	case len(items) == 3:
+		return parseMetricItems(sanitizeMetricName(items[0]), items[1], items[2])
+	}
+	return nil, nil
+}
+
+func parseMetricItems(name, value, timestamp string) ([]*mackerel.MetricValue, error) {
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return nil, err
+	}
+	t, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return nil, err
+	}
+	return []*mackerel.MetricValue{{Name: name, Value: v, Time: t}}, nil
+}
+
+// ex.) {"name": "tcp.CLOSING", "value": 0, "time": 1397031808}
+func parseJSONLine(line string, now time.Time) ([]*mackerel.MetricValue, error) {
+	if strings.TrimSpace(line) == "" {
+		return nil, nil
+	}
+	var m struct {
+		Name  string   `json:"name"`
+		Value *float64 `json:"value"`
+		Time  int64    `json:"time"`
+	}
+	if err := json.Unmarshal([]byte(line), &m); err != nil {
+		return nil, err
+	}
+	if m.Name == "" || m.Value == nil {
+		return nil, fmt.Errorf("name and value are required: %s", line)
+	}
+	if m.Time == 0 {
+		m.Time = now.Unix()
+	}
+	return []*mackerel.MetricValue{{Name: m.Name, Value: *m.Value, Time: m.Time}}, nil
+}
+
+var (
+	prometheusSampleLinePattern  = regexp.MustCompile(`^([a-zA-Z_:][a-zA-Z0-9_:]*)(?:\{(.*)\})?\s+(\S+)(?:\s+(-?\d+))?\s*$`)
+	prometheusSampleLabelPattern = regexp.MustCompile(`\s*([a-zA-Z_][a-zA-Z0-9_]*)\s*=\s*"((?:[^"\\]|\\.)*)"\s*,?`)
+)
+
+// The label values are appended to the metric name in the order of the label names.
+// ex.) http_requests_total{code="200",method="get"} 1027 1395066363000 -> http_requests_total.200.get
+func parsePrometheusLine(line string, now time.Time) ([]*mackerel.MetricValue, error) {
+	line = strings.TrimSpace(line)
+	if line == "" || strings.HasPrefix(line, "#") {
+		return nil, nil
+	}
+	m := prometheusSampleLinePattern.FindStringSubmatch(line)
+	if m == nil {
+		return nil, fmt.Errorf("invalid line: %s", line)
+	}
+	value, err := strconv.ParseFloat(m[3], 64)
+	if err != nil {
+		return nil, err
+	}
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return nil, fmt.Errorf("non-finite value: %s", line)
+	}
+	t := now.Unix()
+	if m[4] != "" {
+		ms, err := strconv.ParseInt(m[4], 10, 64)
+		if err != nil {
+			return nil, err
+		}
+		t = ms / 1000
+	}
+
+	labels := map[string]string{}
+	var keys []string
+	for _, l := range prometheusSampleLabelPattern.FindAllStringSubmatch(m[2], -1) {
+		labels[l[1]] = strings.NewReplacer(`\"`, `"`, `\\`, `\`, `\n`, "\n").Replace(l[2])
+		keys = append(keys, l[1])
+	}
+	sort.Strings(keys)
+	parts := []string{m[1]}
+	for _, k := range keys {
+		parts = append(parts, labels[k])
+	}
+	return []*mackerel.MetricValue{{Name: sanitizeMetricName(strings.Join(parts, ".")), Value: value, Time: t}}, nil
+}
+
+var invalidMetricNameChars = regexp.MustCompile(`[^a-zA-Z0-9._-]`)
+
+// sanitizeMetricName replaces the characters which are not allowed in metric names.
+func sanitizeMetricName(name string) string {
+	return invalidMetricNameChars.ReplaceAllString(name, "_")
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestMetricLineParsers(t *testing.T) {
+	now := time.Unix(1500000000, 0)
+	testCases := []struct {
+		format   string
+		line     string
+		expected []*mackerel.MetricValue
+		hasError bool
+	}{
+		{"sensu", "tcp.CLOSING 0 1397031808", []*mackerel.MetricValue{{Name: "tcp.CLOSING", Value: 0.0, Time: 1397031808}}, false},
+		{"sensu", "tcp.CLOSING 0", nil, false},
+		{"sensu", "tcp.CLOSING zero 1397031808", nil, true},
+		{"graphite", "servers.web1.load 0.5 1397031808", []*mackerel.MetricValue{{Name: "servers.web1.load", Value: 0.5, Time: 1397031808}}, false},
+		{"graphite", "servers.web1.load 0.5 -1", []*mackerel.MetricValue{{Name: "servers.web1.load", Value: 0.5, Time: 1500000000}}, false},
+		{"graphite", "servers.web1.load;dc=tokyo 0.5", []*mackerel.MetricValue{{Name: "servers.web1.load_dc_tokyo", Value: 0.5, Time: 1500000000}}, false},
+		{"jsonl", `{"name": "foo.bar", "value": 1.5, "time": 1397031808}`, []*mackerel.MetricValue{{Name: "foo.bar", Value: 1.5, Time: 1397031808}}, false},
+		{"jsonl", `{"name": "foo.bar", "value": 1.5}`, []*mackerel.MetricValue{{Name: "foo.bar", Value: 1.5, Time: 1500000000}}, false},
+		{"jsonl", `{"name": "foo.bar"}`, nil, true},
+		{"jsonl", ``, nil, false},
+		{"prometheus", `# HELP http_requests_total The total number of HTTP requests.`, nil, false},
+		{"prometheus", `http_requests_total{method="post",code="200"} 1027 1395066363000`, []*mackerel.MetricValue{{Name: "http_requests_total.200.post", Value: 1027.0, Time: 1395066363}}, false},
+		{"prometheus", `process_open_fds 12`, []*mackerel.MetricValue{{Name: "process_open_fds", Value: 12.0, Time: 1500000000}}, false},
+		{"prometheus", `rpc_duration_seconds{quantile="0.5"} 4773`, []*mackerel.MetricValue{{Name: "rpc_duration_seconds.0.5", Value: 4773.0, Time: 1500000000}}, false},
+		{"prometheus", `go_memstats_frees_total{path="/a b"} NaN`, nil, true},
+	}
+	for _, tc := range testCases {
+		values, err := metricLineParsers[tc.format](tc.line, now)
+		if tc.hasError {
+			if err == nil {
+				t.Errorf("%s %q: err should occur", tc.format, tc.line)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s %q: err should be nil but: %s", tc.format, tc.line, err)
+			continue
+		}
+		if !reflect.DeepEqual(values, tc.expected) {
+			t.Errorf("%s %q: values should be %+v but: %+v", tc.format, tc.line, tc.expected, values)
+		}
+	}
+}
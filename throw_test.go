@@ -2,6 +2,7 @@ package main
 
 import (
 	"fmt"
+	"reflect"
 	"testing"
 	"time"
 
@@ -144,3 +145,31 @@ func TestRequestWithRetry_Status(t *testing.T) {
 		t.Errorf("function should be called only once, but called %d times", counter)
 	}
 }
+
+func TestBuildThrowGraphDefs(t *testing.T) {
+	metricValues := []*mackerel.MetricValue{
+		{Name: "queue.size.high"},
+		{Name: "queue.size.low"},
+		{Name: "orders.count"},
+		{Name: "orders"},
+		{Name: "queue.size.high"},
+	}
+	defined := []*mackerel.GraphDefsParam{
+		{Name: "orders", Unit: "integer", Metrics: []*mackerel.GraphDefsMetric{{Name: "orders.count", DisplayName: "Orders"}}},
+		{Name: "users", Unit: "integer", Metrics: []*mackerel.GraphDefsMetric{{Name: "users.count"}}},
+	}
+	graphDefs := buildThrowGraphDefs(metricValues, defined, "bytes", "Queue")
+	want := []*mackerel.GraphDefsParam{
+		{Name: "queue.size", DisplayName: "Queue", Unit: "bytes", Metrics: []*mackerel.GraphDefsMetric{{Name: "queue.size.*"}}},
+		defined[0],
+	}
+	if !reflect.DeepEqual(graphDefs, want) {
+		t.Errorf("graph defs should be %+v but got %+v", want, graphDefs)
+	}
+	if err := validateGraphDefs(graphDefs, ""); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+	if err := validateGraphDefs(graphDefs, hostGraphDefPrefix); err == nil {
+		t.Errorf("the graph names of the host metrics should begin with custom.")
+	}
+}
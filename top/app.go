@@ -0,0 +1,366 @@
+package top
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+// topClient is the client of the APIs shown in mkr top.
+type topClient interface {
+	FindAlerts() (*mackerel.AlertsResp, error)
+	CloseAlert(alertID string, reason string) (*mackerel.Alert, error)
+	FindHosts(param *mackerel.FindHostsParam) ([]*mackerel.Host, error)
+	UpdateHostStatus(hostID string, status string) error
+	FindServices() ([]*mackerel.Service, error)
+	FindGraphAnnotations(service string, from int64, to int64) ([]mackerel.GraphAnnotation, error)
+}
+
+type pane int
+
+const (
+	paneAlerts pane = iota
+	paneHosts
+	paneAnnotations
+	paneCount
+)
+
+var paneNames = [paneCount]string{"Alerts", "Hosts", "Annotations"}
+
+type inputMode int
+
+const (
+	modeNormal inputMode = iota
+	modeCloseReason
+	modeHostStatus
+)
+
+// hostStatusKeys are the keys to select the status of the host
+var hostStatusKeys = map[rune]string{
+	'w': "working",
+	's': "standby",
+	'm': "maintenance",
+	'p': "poweroff",
+}
+
+// annotationsRange is the range of the recent annotations
+const annotationsRange = 24 * time.Hour
+
+type topApp struct {
+	client   topClient
+	services []string
+	now      func() time.Time
+
+	alerts      []*mackerel.Alert
+	hosts       []*mackerel.Host
+	annotations []mackerel.GraphAnnotation
+	hostNames   map[string]string
+	updatedAt   time.Time
+
+	pane    pane
+	cursors [paneCount]int
+	mode    inputMode
+	input   string
+	message string
+}
+
+// refresh fetches the open alerts, the hosts and the recent annotations.
+// The errors are shown in the message line, keeping the other panes updated.
+func (app *topApp) refresh() {
+	var errs []string
+	if resp, err := app.client.FindAlerts(); err != nil {
+		errs = append(errs, fmt.Sprintf("failed to fetch alerts: %s", err))
+	} else {
+		app.alerts = resp.Alerts
+	}
+	if hosts, err := app.client.FindHosts(&mackerel.FindHostsParam{
+		Statuses: []string{"working", "standby", "maintenance", "poweroff"},
+	}); err != nil {
+		errs = append(errs, fmt.Sprintf("failed to fetch hosts: %s", err))
+	} else {
+		sort.Slice(hosts, func(i, j int) bool { return hosts[i].Name < hosts[j].Name })
+		app.hosts = hosts
+		app.hostNames = make(map[string]string, len(hosts))
+		for _, h := range hosts {
+			app.hostNames[h.ID] = h.Name
+		}
+	}
+	if annotations, err := app.fetchAnnotations(); err != nil {
+		errs = append(errs, fmt.Sprintf("failed to fetch annotations: %s", err))
+	} else {
+		app.annotations = annotations
+	}
+	app.updatedAt = app.now()
+	app.message = strings.Join(errs, "; ")
+	for p := pane(0); p < paneCount; p++ {
+		app.moveCursor(p, 0)
+	}
+}
+
+func (app *topApp) fetchAnnotations() ([]mackerel.GraphAnnotation, error) {
+	services := app.services
+	if len(services) == 0 {
+		ss, err := app.client.FindServices()
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range ss {
+			services = append(services, s.Name)
+		}
+	}
+	to := app.now()
+	from := to.Add(-annotationsRange)
+	var annotations []mackerel.GraphAnnotation
+	for _, service := range services {
+		as, err := app.client.FindGraphAnnotations(service, from.Unix(), to.Unix())
+		if err != nil {
+			return nil, err
+		}
+		annotations = append(annotations, as...)
+	}
+	sort.SliceStable(annotations, func(i, j int) bool { return annotations[i].From > annotations[j].From })
+	return annotations, nil
+}
+
+func (app *topApp) itemCount(p pane) int {
+	switch p {
+	case paneAlerts:
+		return len(app.alerts)
+	case paneHosts:
+		return len(app.hosts)
+	default:
+		return len(app.annotations)
+	}
+}
+
+// moveCursor moves the cursor of the pane by delta within the items
+func (app *topApp) moveCursor(p pane, delta int) {
+	c := app.cursors[p] + delta
+	if n := app.itemCount(p); c >= n {
+		c = n - 1
+	}
+	if c < 0 {
+		c = 0
+	}
+	app.cursors[p] = c
+}
+
+func (app *topApp) selectedAlert() *mackerel.Alert {
+	if len(app.alerts) == 0 {
+		return nil
+	}
+	return app.alerts[app.cursors[paneAlerts]]
+}
+
+func (app *topApp) selectedHost() *mackerel.Host {
+	if len(app.hosts) == 0 {
+		return nil
+	}
+	return app.hosts[app.cursors[paneHosts]]
+}
+
+// handleKey handles the key and reports whether mkr top should quit.
+func (app *topApp) handleKey(k key) bool {
+	switch app.mode {
+	case modeCloseReason:
+		app.handleCloseReasonKey(k)
+		return false
+	case modeHostStatus:
+		app.handleHostStatusKey(k)
+		return false
+	}
+	switch {
+	case k.code == keyCtrlC || k.isRune('q'):
+		return true
+	case k.code == keyTab || k.code == keyRight || k.isRune('l'):
+		app.pane = (app.pane + 1) % paneCount
+	case k.code == keyShiftTab || k.code == keyLeft || k.isRune('h'):
+		app.pane = (app.pane + paneCount - 1) % paneCount
+	case k.isRune('1'), k.isRune('2'), k.isRune('3'):
+		app.pane = pane(k.r - '1')
+	case k.code == keyDown || k.isRune('j'):
+		app.moveCursor(app.pane, 1)
+	case k.code == keyUp || k.isRune('k'):
+		app.moveCursor(app.pane, -1)
+	case k.isRune('r'):
+		app.refresh()
+	case k.isRune('c') && app.pane == paneAlerts && app.selectedAlert() != nil:
+		app.mode, app.input, app.message = modeCloseReason, "", ""
+	case k.isRune('s') && app.pane == paneHosts && app.selectedHost() != nil:
+		app.mode, app.message = modeHostStatus, ""
+	}
+	return false
+}
+
+func (app *topApp) handleCloseReasonKey(k key) {
+	switch {
+	case k.code == keyEsc || k.code == keyCtrlC:
+		app.mode, app.message = modeNormal, "canceled"
+	case k.code == keyEnter:
+		app.mode = modeNormal
+		alert := app.selectedAlert()
+		if _, err := app.client.CloseAlert(alert.ID, app.input); err != nil {
+			app.message = fmt.Sprintf("failed to close %s: %s", alert.ID, err)
+			return
+		}
+		app.refresh()
+		if app.message == "" {
+			app.message = fmt.Sprintf("closed the alert %s", alert.ID)
+		}
+	case k.code == keyBackspace:
+		if _, size := utf8.DecodeLastRuneInString(app.input); size > 0 {
+			app.input = app.input[:len(app.input)-size]
+		}
+	case k.code == keyRune:
+		app.input += string(k.r)
+	}
+}
+
+func (app *topApp) handleHostStatusKey(k key) {
+	if k.code != keyRune {
+		app.mode, app.message = modeNormal, "canceled"
+		return
+	}
+	status, ok := hostStatusKeys[k.r]
+	if !ok {
+		return
+	}
+	app.mode = modeNormal
+	host := app.selectedHost()
+	if err := app.client.UpdateHostStatus(host.ID, status); err != nil {
+		app.message = fmt.Sprintf("failed to update %s: %s", host.Name, err)
+		return
+	}
+	app.refresh()
+	if app.message == "" {
+		app.message = fmt.Sprintf("updated the status of %s to %s", host.Name, status)
+	}
+}
+
+const (
+	styleReverse = "\x1b[7m"
+	styleBold    = "\x1b[1m"
+	styleReset   = "\x1b[0m"
+)
+
+// render returns the lines of the screen in the size
+func (app *topApp) render(width, height int) []string {
+	if width < 1 || height < 1 {
+		return nil
+	}
+	lines := make([]string, 0, height)
+	// the panes share the lines except for the footer
+	rest := height - 1
+	for p := pane(0); p < paneCount; p++ {
+		h := rest / int(paneCount-p)
+		rest -= h
+		lines = append(lines, app.renderPane(p, width, h)...)
+	}
+	return append(lines, truncate(app.footer(), width))
+}
+
+func (app *topApp) renderPane(p pane, width, height int) []string {
+	if height < 1 {
+		return nil
+	}
+	title := fmt.Sprintf("[%d] %s (%d)", p+1, paneNames[p], app.itemCount(p))
+	if p == paneAlerts && !app.updatedAt.IsZero() {
+		title += "  updated at " + app.updatedAt.Format("15:04:05")
+	}
+	style := styleBold
+	if p == app.pane {
+		style = styleReverse
+	}
+	lines := []string{style + pad(title, width) + styleReset}
+
+	rows := height - 1
+	n := app.itemCount(p)
+	// scroll the rows to show the cursor
+	start := 0
+	if c := app.cursors[p]; c >= rows {
+		start = c - rows + 1
+	}
+	for i := start; i < start+rows; i++ {
+		if i >= n {
+			lines = append(lines, "")
+			continue
+		}
+		line := truncate(app.row(p, i), width)
+		if p == app.pane && i == app.cursors[p] {
+			line = styleReverse + pad(line, width) + styleReset
+		}
+		lines = append(lines, line)
+	}
+	return lines
+}
+
+func (app *topApp) row(p pane, i int) string {
+	switch p {
+	case paneAlerts:
+		a := app.alerts[i]
+		target := app.hostNames[a.HostID]
+		if target == "" {
+			target = a.MonitorID
+		}
+		return fmt.Sprintf("%-8s %s  %-10s %-24s %s",
+			a.Status, formatTime(a.OpenedAt), a.Type, target, a.Message)
+	case paneHosts:
+		h := app.hosts[i]
+		var roles []string
+		for service, rs := range h.Roles {
+			for _, r := range rs {
+				roles = append(roles, service+":"+r)
+			}
+		}
+		sort.Strings(roles)
+		return fmt.Sprintf("%-11s %-12s %-32s %s", h.ID, h.Status, h.Name, strings.Join(roles, ","))
+	default:
+		a := app.annotations[i]
+		return fmt.Sprintf("%s  %-16s %s", formatTime(a.From), a.Service, a.Title)
+	}
+}
+
+func (app *topApp) footer() string {
+	switch app.mode {
+	case modeCloseReason:
+		return fmt.Sprintf("Close %s with reason (Enter: close, Esc: cancel): %s", app.selectedAlert().ID, app.input)
+	case modeHostStatus:
+		return fmt.Sprintf("Status of %s: [w]orking [s]tandby [m]aintenance [p]oweroff (Esc: cancel)", app.selectedHost().Name)
+	}
+	if app.message != "" {
+		return app.message
+	}
+	help := "q: quit  Tab/1-3: pane  j/k: move  r: refresh"
+	switch app.pane {
+	case paneAlerts:
+		help += "  c: close alert"
+	case paneHosts:
+		help += "  s: change status"
+	}
+	return help
+}
+
+func formatTime(epoch int64) string {
+	if epoch == 0 {
+		return "           "
+	}
+	return time.Unix(epoch, 0).Format("01-02 15:04")
+}
+
+// truncate truncates s within width runes
+func truncate(s string, width int) string {
+	if utf8.RuneCountInString(s) <= width {
+		return s
+	}
+	return string([]rune(s)[:width])
+}
+
+// pad pads s with spaces to fill width runes
+func pad(s string, width int) string {
+	s = truncate(s, width)
+	return s + strings.Repeat(" ", width-utf8.RuneCountInString(s))
+}
@@ -0,0 +1,245 @@
+package top
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+type fakeClient struct {
+	alerts      []*mackerel.Alert
+	hosts       []*mackerel.Host
+	services    []*mackerel.Service
+	annotations map[string][]mackerel.GraphAnnotation
+	requests    []string
+}
+
+func (c *fakeClient) FindAlerts() (*mackerel.AlertsResp, error) {
+	var alerts []*mackerel.Alert
+	for _, a := range c.alerts {
+		if a.Status != "OK" {
+			alerts = append(alerts, a)
+		}
+	}
+	return &mackerel.AlertsResp{Alerts: alerts}, nil
+}
+
+func (c *fakeClient) CloseAlert(alertID string, reason string) (*mackerel.Alert, error) {
+	c.requests = append(c.requests, "close "+alertID+" "+reason)
+	for _, a := range c.alerts {
+		if a.ID == alertID {
+			a.Status = "OK"
+			return a, nil
+		}
+	}
+	return nil, fmt.Errorf("alert not found: %s", alertID)
+}
+
+func (c *fakeClient) FindHosts(param *mackerel.FindHostsParam) ([]*mackerel.Host, error) {
+	return c.hosts, nil
+}
+
+func (c *fakeClient) UpdateHostStatus(hostID string, status string) error {
+	c.requests = append(c.requests, "status "+hostID+" "+status)
+	for _, h := range c.hosts {
+		if h.ID == hostID {
+			h.Status = status
+		}
+	}
+	return nil
+}
+
+func (c *fakeClient) FindServices() ([]*mackerel.Service, error) {
+	return c.services, nil
+}
+
+func (c *fakeClient) FindGraphAnnotations(service string, from int64, to int64) ([]mackerel.GraphAnnotation, error) {
+	c.requests = append(c.requests, fmt.Sprintf("annotations %s %d %d", service, from, to))
+	return c.annotations[service], nil
+}
+
+func newFakeClient() *fakeClient {
+	return &fakeClient{
+		alerts: []*mackerel.Alert{
+			{ID: "alert1", Status: "CRITICAL", Type: "connectivity", HostID: "host1", MonitorID: "mon1", OpenedAt: 1590969600},
+			{ID: "alert2", Status: "WARNING", Type: "expression", MonitorID: "mon2", Message: "too many requests", OpenedAt: 1590973200},
+		},
+		hosts: []*mackerel.Host{
+			{ID: "host2", Name: "web02", Status: "standby", Roles: mackerel.Roles{"My-Service": {"web"}}},
+			{ID: "host1", Name: "db01", Status: "working", Roles: mackerel.Roles{"My-Service": {"db", "backup"}}},
+		},
+		services: []*mackerel.Service{{Name: "My-Service"}, {Name: "Another"}},
+		annotations: map[string][]mackerel.GraphAnnotation{
+			"My-Service": {{Title: "deploy", Service: "My-Service", From: 1590966000}},
+			"Another":    {{Title: "release", Service: "Another", From: 1590969600}},
+		},
+	}
+}
+
+func newTestApp(client topClient) *topApp {
+	return &topApp{
+		client: client,
+		now:    func() time.Time { return time.Unix(1590976800, 0) },
+	}
+}
+
+func TestTopApp_refresh(t *testing.T) {
+	client := newFakeClient()
+	app := newTestApp(client)
+	app.refresh()
+
+	assert.Len(t, app.alerts, 2)
+	assert.Equal(t, []string{"db01", "web02"}, []string{app.hosts[0].Name, app.hosts[1].Name}, "hosts should be sorted by the names")
+	assert.Equal(t, []string{"release", "deploy"}, []string{app.annotations[0].Title, app.annotations[1].Title}, "annotations should be in the recent order")
+	assert.Equal(t, []string{
+		"annotations My-Service 1590890400 1590976800",
+		"annotations Another 1590890400 1590976800",
+	}, client.requests)
+
+	client.requests = nil
+	app.services = []string{"Another"}
+	app.refresh()
+	assert.Equal(t, []string{"annotations Another 1590890400 1590976800"}, client.requests)
+}
+
+func TestTopApp_render(t *testing.T) {
+	app := newTestApp(newFakeClient())
+	app.refresh()
+	lines := app.render(120, 13)
+
+	assert.Len(t, lines, 13)
+	assert.Contains(t, lines[0], "[1] Alerts (2)")
+	assert.Contains(t, lines[0], styleReverse, "the focused pane should be highlighted")
+	assert.Contains(t, lines[1], "CRITICAL")
+	assert.Contains(t, lines[1], "db01", "the host name should be shown for the host alerts")
+	assert.Contains(t, lines[2], "too many requests")
+	assert.Contains(t, lines[4], "[2] Hosts (2)")
+	assert.Contains(t, lines[5], "host1")
+	assert.Contains(t, lines[5], "My-Service:backup,My-Service:db")
+	assert.Contains(t, lines[8], "[3] Annotations (2)")
+	assert.Contains(t, lines[9], "release")
+	assert.Contains(t, lines[12], "c: close alert")
+
+	for _, line := range app.render(20, 13) {
+		line = strings.Replace(strings.Replace(strings.Replace(line, styleReverse, "", -1), styleBold, "", -1), styleReset, "", -1)
+		assert.True(t, len([]rune(line)) <= 20, "the line should be truncated: %q", line)
+	}
+}
+
+func TestTopApp_render_scroll(t *testing.T) {
+	client := newFakeClient()
+	for i := 0; i < 10; i++ {
+		client.hosts = append(client.hosts, &mackerel.Host{ID: fmt.Sprintf("host%d", i+10), Name: fmt.Sprintf("app%02d", i)})
+	}
+	app := newTestApp(client)
+	app.refresh()
+	app.handleKey(key{code: keyRune, r: '2'})
+	for i := 0; i < 8; i++ {
+		app.handleKey(key{code: keyDown})
+	}
+	lines := app.render(80, 13)
+	assert.Contains(t, lines[7], "app08", "the pane should be scrolled to show the cursor")
+	assert.Contains(t, lines[7], styleReverse)
+	assert.NotContains(t, lines[5], "app00")
+}
+
+func TestTopApp_handleKey_navigation(t *testing.T) {
+	app := newTestApp(newFakeClient())
+	app.refresh()
+
+	app.handleKey(key{code: keyTab})
+	assert.Equal(t, paneHosts, app.pane)
+	app.handleKey(key{code: keyShiftTab})
+	app.handleKey(key{code: keyShiftTab})
+	assert.Equal(t, paneAnnotations, app.pane)
+	app.handleKey(key{code: keyRune, r: '1'})
+	assert.Equal(t, paneAlerts, app.pane)
+
+	app.handleKey(key{code: keyRune, r: 'j'})
+	app.handleKey(key{code: keyDown})
+	assert.Equal(t, 1, app.cursors[paneAlerts], "the cursor should stop at the last item")
+	app.handleKey(key{code: keyUp})
+	app.handleKey(key{code: keyRune, r: 'k'})
+	assert.Equal(t, 0, app.cursors[paneAlerts])
+
+	assert.False(t, app.handleKey(key{code: keyRune, r: 'x'}))
+	assert.True(t, app.handleKey(key{code: keyRune, r: 'q'}))
+	assert.True(t, app.handleKey(key{code: keyCtrlC}))
+}
+
+func TestTopApp_closeAlert(t *testing.T) {
+	client := newFakeClient()
+	app := newTestApp(client)
+	app.refresh()
+	client.requests = nil
+
+	app.handleKey(key{code: keyDown})
+	app.handleKey(key{code: keyRune, r: 'c'})
+	assert.Equal(t, modeCloseReason, app.mode)
+	for _, r := range "fixedd" {
+		assert.False(t, app.handleKey(key{code: keyRune, r: r}), "q should not quit while typing the reason")
+	}
+	app.handleKey(key{code: keyBackspace})
+	assert.Contains(t, app.render(80, 13)[12], "Close alert2 with reason")
+	app.handleKey(key{code: keyEnter})
+
+	assert.Equal(t, modeNormal, app.mode)
+	assert.Equal(t, "close alert2 fixed", client.requests[0])
+	assert.Len(t, app.alerts, 1, "the alerts should be refreshed")
+	assert.Equal(t, "closed the alert alert2", app.message)
+	assert.Equal(t, 0, app.cursors[paneAlerts], "the cursor should be kept within the alerts")
+
+	client.requests = nil
+	app.handleKey(key{code: keyRune, r: 'c'})
+	app.handleKey(key{code: keyEsc})
+	assert.Equal(t, modeNormal, app.mode)
+	assert.Empty(t, client.requests)
+}
+
+func TestTopApp_updateHostStatus(t *testing.T) {
+	client := newFakeClient()
+	app := newTestApp(client)
+	app.refresh()
+	client.requests = nil
+
+	app.handleKey(key{code: keyRune, r: 's'})
+	assert.Equal(t, modeNormal, app.mode, "the status can be changed only in the hosts pane")
+
+	app.handleKey(key{code: keyRune, r: '2'})
+	app.handleKey(key{code: keyRune, r: 's'})
+	assert.Equal(t, modeHostStatus, app.mode)
+	app.handleKey(key{code: keyRune, r: 'x'})
+	assert.Equal(t, modeHostStatus, app.mode, "unknown keys should be ignored")
+	app.handleKey(key{code: keyRune, r: 'm'})
+
+	assert.Equal(t, modeNormal, app.mode)
+	assert.Equal(t, "status host1 maintenance", client.requests[0])
+	assert.Equal(t, "maintenance", app.hosts[0].Status)
+	assert.Equal(t, "updated the status of db01 to maintenance", app.message)
+}
+
+func TestParseKeys(t *testing.T) {
+	keys := parseKeys([]byte("q\x1b[A\x1b[B\x1bOC\x1b[D\x1b[Z\t\r\x7f\x03\x1b[1;5A\x1b\x01あ"))
+	assert.Equal(t, []key{
+		{code: keyRune, r: 'q'},
+		{code: keyUp},
+		{code: keyDown},
+		{code: keyRight},
+		{code: keyLeft},
+		{code: keyShiftTab},
+		{code: keyTab},
+		{code: keyEnter},
+		{code: keyBackspace},
+		{code: keyCtrlC},
+		{code: keyUnknown},
+		{code: keyUnknown},
+		{code: keyUnknown},
+		{code: keyRune, r: 'あ'},
+	}, keys)
+	assert.Equal(t, []key{{code: keyEsc}}, parseKeys([]byte("\x1b")))
+}
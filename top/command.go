@@ -0,0 +1,51 @@
+package top
+
+import (
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+// Command is the definition of top subcommand
+var Command = cli.Command{
+	Name:      "top",
+	Usage:     "Show alerts, hosts and annotations in a terminal UI",
+	ArgsUsage: "[--interval <duration>] [[--service | -s <service>]...]",
+	Description: `
+    Show the open alerts, the statuses of the hosts and the annotations in the last 24 hours in the panes,
+    which are refreshed in every --interval. Move between the panes with Tab or 1-3, and the rows with j/k or the arrow keys.
+    Press 'c' in the alerts pane to close the selected alert with the reason,
+    and 's' in the hosts pane to change the status of the selected host.
+`,
+	Action: doTop,
+	Flags: []cli.Flag{
+		cli.DurationFlag{Name: "interval", Value: 30 * time.Second, Usage: "The interval to refresh the panes"},
+		cli.StringSliceFlag{
+			Name:  "service, s",
+			Value: &cli.StringSlice{},
+			Usage: "Show the annotations of <service>. Multiple choices are allowed. All the services if not specified",
+		},
+	},
+}
+
+func doTop(c *cli.Context) error {
+	interval := c.Duration("interval")
+	if interval <= 0 {
+		return cli.NewExitError(fmt.Sprintf("--interval should be positive: %s", interval), 1)
+	}
+	app := &topApp{
+		client:   mackerelclient.NewFromContext(c),
+		services: c.StringSlice("service"),
+		now:      time.Now,
+	}
+	// the logs such as the warnings of the retries break the screen, and the errors are shown in the footer
+	if !logger.DebugEnabled() {
+		logger.SetLevel("error")
+	}
+	return app.run(os.Stdin, os.Stdout, interval)
+}
@@ -0,0 +1,183 @@
+package top
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"golang.org/x/crypto/ssh/terminal"
+)
+
+type keyCode int
+
+const (
+	keyRune keyCode = iota
+	keyUp
+	keyDown
+	keyRight
+	keyLeft
+	keyTab
+	keyShiftTab
+	keyEnter
+	keyEsc
+	keyBackspace
+	keyCtrlC
+	keyUnknown
+)
+
+type key struct {
+	code keyCode
+	r    rune
+}
+
+func (k key) isRune(r rune) bool {
+	return k.code == keyRune && k.r == r
+}
+
+var escapeSequences = map[string]keyCode{
+	"\x1b[A": keyUp,
+	"\x1b[B": keyDown,
+	"\x1b[C": keyRight,
+	"\x1b[D": keyLeft,
+	"\x1b[Z": keyShiftTab,
+	"\x1bOA": keyUp,
+	"\x1bOB": keyDown,
+	"\x1bOC": keyRight,
+	"\x1bOD": keyLeft,
+}
+
+// parseKeys parses the input of the terminal in the raw mode
+func parseKeys(b []byte) []key {
+	var keys []key
+	for len(b) > 0 {
+		switch c := b[0]; c {
+		case 0x1b:
+			if len(b) == 1 {
+				keys = append(keys, key{code: keyEsc})
+				b = b[1:]
+				continue
+			}
+			if len(b) >= 3 {
+				if code, ok := escapeSequences[string(b[:3])]; ok {
+					keys = append(keys, key{code: code})
+					b = b[3:]
+					continue
+				}
+			}
+			// skip the unknown escape sequence
+			i := 1
+			if b[1] == '[' || b[1] == 'O' {
+				for i = 2; i < len(b) && (b[i] < 0x40 || b[i] > 0x7e); i++ {
+				}
+				i++
+			}
+			if i > len(b) {
+				i = len(b)
+			}
+			keys = append(keys, key{code: keyUnknown})
+			b = b[i:]
+		case '\t':
+			keys = append(keys, key{code: keyTab})
+			b = b[1:]
+		case '\r', '\n':
+			keys = append(keys, key{code: keyEnter})
+			b = b[1:]
+		case 0x7f, 0x08:
+			keys = append(keys, key{code: keyBackspace})
+			b = b[1:]
+		case 0x03:
+			keys = append(keys, key{code: keyCtrlC})
+			b = b[1:]
+		default:
+			if c < 0x20 {
+				keys = append(keys, key{code: keyUnknown})
+				b = b[1:]
+				continue
+			}
+			r, size := utf8.DecodeRune(b)
+			keys = append(keys, key{code: keyRune, r: r})
+			b = b[size:]
+		}
+	}
+	return keys
+}
+
+// run runs the terminal UI until the user quits
+func (app *topApp) run(in *os.File, out io.Writer, interval time.Duration) error {
+	fd := int(in.Fd())
+	if !terminal.IsTerminal(fd) {
+		return fmt.Errorf("mkr top requires a terminal")
+	}
+	state, err := terminal.MakeRaw(fd)
+	if err != nil {
+		return err
+	}
+	defer terminal.Restore(fd, state)
+
+	w := bufio.NewWriter(out)
+	// use the alternate screen and hide the cursor
+	fmt.Fprint(w, "\x1b[?1049h\x1b[?25l")
+	defer func() {
+		fmt.Fprint(w, "\x1b[?25h\x1b[?1049l")
+		w.Flush()
+	}()
+
+	keys := make(chan []key)
+	go func() {
+		buf := make([]byte, 256)
+		for {
+			n, err := in.Read(buf)
+			if err != nil {
+				close(keys)
+				return
+			}
+			keys <- parseKeys(buf[:n])
+		}
+	}()
+
+	draw := func() {
+		width, height, err := terminal.GetSize(fd)
+		if err != nil || width <= 0 || height <= 0 {
+			width, height = 80, 24
+		}
+		fmt.Fprint(w, "\x1b[H")
+		lines := app.render(width, height)
+		fmt.Fprint(w, strings.Join(lines, "\x1b[K\r\n"))
+		fmt.Fprint(w, "\x1b[K\x1b[J")
+		w.Flush()
+	}
+
+	app.message = "loading..."
+	draw()
+	app.refresh()
+	draw()
+
+	refreshTicker := time.NewTicker(interval)
+	defer refreshTicker.Stop()
+	// redraw periodically to follow the size of the terminal
+	redrawTicker := time.NewTicker(time.Second)
+	defer redrawTicker.Stop()
+	for {
+		select {
+		case ks, ok := <-keys:
+			if !ok {
+				return nil
+			}
+			for _, k := range ks {
+				if app.handleKey(k) {
+					return nil
+				}
+			}
+		case <-refreshTicker.C:
+			if app.mode == modeNormal {
+				app.refresh()
+			}
+		case <-redrawTicker.C:
+		}
+		draw()
+	}
+}
@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+
+	"github.com/Songmu/prompter"
+	"github.com/mackerelio/mackerel-client-go"
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+	"github.com/urfave/cli"
+)
+
+// hostUpdateSpec is the desired state of a host in the file of mkr update --file-path.
+// The host is matched by id or customIdentifier, and the omitted fields are not changed.
+type hostUpdateSpec struct {
+	ID               string   `json:"id"`
+	CustomIdentifier string   `json:"customIdentifier"`
+	Name             string   `json:"name"`
+	DisplayName      string   `json:"displayName"`
+	Status           string   `json:"status"`
+	RoleFullnames    []string `json:"roleFullnames"`
+}
+
+func (s *hostUpdateSpec) String() string {
+	if s.ID != "" {
+		return s.ID
+	}
+	return "customIdentifier " + s.CustomIdentifier
+}
+
+// loadHostUpdateSpecs loads the hosts to update in YAML or JSON. The unknown fields are rejected.
+func loadHostUpdateSpecs(filePath string) ([]*hostUpdateSpec, error) {
+	data, err := ioutil.ReadFile(filePath)
+	if err != nil {
+		return nil, err
+	}
+	// JSON is also converted as YAML
+	if data, err = format.YAMLToJSON(data); err != nil {
+		return nil, fmt.Errorf("failed to load '%s': %s", filePath, err)
+	}
+	var file struct {
+		Hosts []*hostUpdateSpec `json:"hosts"`
+	}
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&file); err != nil {
+		return nil, fmt.Errorf("failed to load '%s': %s", filePath, err)
+	}
+	for i, s := range file.Hosts {
+		if s == nil || (s.ID == "") == (s.CustomIdentifier == "") {
+			return nil, fmt.Errorf("failed to load '%s': hosts[%d] should have either id or customIdentifier", filePath, i)
+		}
+	}
+	return file.Hosts, nil
+}
+
+// hostUpdate is the change of a host described in the file
+type hostUpdate struct {
+	host *mackerel.Host
+
+	name, displayName, status string
+	roleFullnames             []string
+}
+
+func (u *hostUpdate) nameChanged() bool {
+	return u.name != u.host.Name || u.displayName != u.host.DisplayName
+}
+
+func (u *hostUpdate) statusChanged() bool {
+	return u.status != u.host.Status
+}
+
+func (u *hostUpdate) rolesChanged() bool {
+	return strings.Join(u.roleFullnames, ",") != strings.Join(sortedRoleFullnames(u.host), ",")
+}
+
+// diff returns the lines of the changes such as `name: "app001" -> "app002"`.
+func (u *hostUpdate) diff() []string {
+	var lines []string
+	if u.name != u.host.Name {
+		lines = append(lines, fmt.Sprintf("name: %q -> %q", u.host.Name, u.name))
+	}
+	if u.displayName != u.host.DisplayName {
+		lines = append(lines, fmt.Sprintf("displayName: %q -> %q", u.host.DisplayName, u.displayName))
+	}
+	if u.statusChanged() {
+		lines = append(lines, fmt.Sprintf("status: %s -> %s", u.host.Status, u.status))
+	}
+	if u.rolesChanged() {
+		lines = append(lines, fmt.Sprintf("roleFullnames: [%s] -> [%s]",
+			strings.Join(sortedRoleFullnames(u.host), ", "), strings.Join(u.roleFullnames, ", ")))
+	}
+	return lines
+}
+
+func sortedRoleFullnames(host *mackerel.Host) []string {
+	roles := host.GetRoleFullnames()
+	sort.Strings(roles)
+	return roles
+}
+
+// planHostUpdates matches the specs with the hosts by id or customIdentifier, and returns the changed hosts.
+// The specs which match no hosts are returned as errors.
+func planHostUpdates(hosts []*mackerel.Host, specs []*hostUpdateSpec) ([]*hostUpdate, []error) {
+	byID := make(map[string]*mackerel.Host, len(hosts))
+	byCustomIdentifier := make(map[string]*mackerel.Host, len(hosts))
+	for _, h := range hosts {
+		byID[h.ID] = h
+		if h.CustomIdentifier != "" {
+			byCustomIdentifier[h.CustomIdentifier] = h
+		}
+	}
+	var updates []*hostUpdate
+	var errs []error
+	for _, s := range specs {
+		host := byID[s.ID]
+		if s.ID == "" {
+			host = byCustomIdentifier[s.CustomIdentifier]
+		}
+		if host == nil {
+			errs = append(errs, fmt.Errorf("host not found: %s", s))
+			continue
+		}
+		u := &hostUpdate{
+			host:          host,
+			name:          host.Name,
+			displayName:   host.DisplayName,
+			status:        host.Status,
+			roleFullnames: sortedRoleFullnames(host),
+		}
+		if s.Name != "" {
+			u.name = s.Name
+		}
+		if s.DisplayName != "" {
+			u.displayName = s.DisplayName
+		}
+		if s.Status != "" {
+			u.status = s.Status
+		}
+		if s.RoleFullnames != nil {
+			u.roleFullnames = append([]string{}, s.RoleFullnames...)
+			sort.Strings(u.roleFullnames)
+		}
+		if len(u.diff()) > 0 {
+			updates = append(updates, u)
+		}
+	}
+	return updates, errs
+}
+
+type hostUpdater interface {
+	UpdateHostStatus(hostID string, status string) error
+	UpdateHostRoleFullnames(hostID string, roleFullnames []string) error
+	UpdateHost(hostID string, param *mackerel.UpdateHostParam) (string, error)
+}
+
+func applyHostUpdate(client hostUpdater, u *hostUpdate) error {
+	if u.statusChanged() {
+		if err := client.UpdateHostStatus(u.host.ID, u.status); err != nil {
+			return err
+		}
+	}
+	if u.nameChanged() {
+		// the whole host is replaced, so the roles and the other fields are also sent
+		_, err := client.UpdateHost(u.host.ID, &mackerel.UpdateHostParam{
+			Name:             u.name,
+			DisplayName:      u.displayName,
+			Meta:             u.host.Meta,
+			Interfaces:       u.host.Interfaces,
+			RoleFullnames:    u.roleFullnames,
+			CustomIdentifier: u.host.CustomIdentifier,
+		})
+		return err
+	}
+	if u.rolesChanged() {
+		return client.UpdateHostRoleFullnames(u.host.ID, u.roleFullnames)
+	}
+	return nil
+}
+
+func doUpdateFromFile(c *cli.Context) error {
+	specs, err := loadHostUpdateSpecs(c.String("file-path"))
+	logger.DieIf(err)
+
+	client := mackerelclient.NewFromContext(c)
+	hosts, err := client.FindHosts(&mackerel.FindHostsParam{Statuses: allHostStatuses})
+	logger.DieIf(err)
+
+	updates, errs := planHostUpdates(hosts, specs)
+	cc := &changeCounter{isDryRun: c.Bool("dry-run")}
+	for _, err := range errs {
+		cc.fail(err.Error())
+	}
+	cc.skipped = len(specs) - len(updates) - len(errs)
+	if len(updates) == 0 {
+		logger.Log("info", "no hosts are changed.")
+		return cc.result("update", "host(s)")
+	}
+
+	for _, u := range updates {
+		fmt.Printf("%s %s\n    %s\n", u.host.ID, u.host.Name, strings.Join(u.diff(), "\n    "))
+	}
+	if !cc.isDryRun && !c.Bool("force") && !prompter.YN(fmt.Sprintf("Update the %d hosts above. Are you sure?", len(updates)), false) {
+		logger.Log("", "update is canceled.")
+		return nil
+	}
+
+	for _, u := range updates {
+		message := u.host.ID + " " + u.host.Name
+		if cc.dryRun("Update", message) {
+			continue
+		}
+		if err := applyHostUpdate(client, u); err != nil {
+			cc.fail(fmt.Sprintf("failed to update %s: %s", message, err))
+			continue
+		}
+		cc.succeed("Update", message)
+	}
+	return cc.result("update", "host(s)")
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/mackerelio/mackerel-client-go"
+)
+
+func TestLoadHostUpdateSpecs(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mkr-update-")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	testCases := []struct {
+		content string
+		expect  []*hostUpdateSpec
+		isErr   bool
+	}{
+		{
+			content: `
+hosts:
+  - id: 3Xyz
+    status: maintenance
+  - customIdentifier: i-0123
+    roleFullnames: [MyApp:web]
+`,
+			expect: []*hostUpdateSpec{
+				{ID: "3Xyz", Status: "maintenance"},
+				{CustomIdentifier: "i-0123", RoleFullnames: []string{"MyApp:web"}},
+			},
+		},
+		{content: `{"hosts":[{"id":"3Xyz","displayName":"web"}]}`, expect: []*hostUpdateSpec{{ID: "3Xyz", DisplayName: "web"}}},
+		{content: "hosts:\n  - name: app001\n", isErr: true},
+		{content: "hosts:\n  - id: 3Xyz\n    customIdentifier: i-0123\n", isErr: true},
+		{content: "hosts:\n  - id: 3Xyz\n    roles: [web]\n", isErr: true},
+	}
+	for i, tc := range testCases {
+		file := filepath.Join(dir, "hosts.yaml")
+		if err := ioutil.WriteFile(file, []byte(tc.content), 0644); err != nil {
+			t.Fatal(err)
+		}
+		specs, err := loadHostUpdateSpecs(file)
+		if tc.isErr {
+			if err == nil {
+				t.Errorf("[%d] err should occur", i)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("[%d] err should be nil but: %s", i, err)
+			continue
+		}
+		if !reflect.DeepEqual(specs, tc.expect) {
+			t.Errorf("[%d] specs should be %+v but: %+v", i, tc.expect, specs)
+		}
+	}
+}
+
+func TestPlanHostUpdates(t *testing.T) {
+	hosts := []*mackerel.Host{
+		{ID: "host1", Name: "app001", Status: "working", Roles: mackerel.Roles{"MyApp": {"web"}}},
+		{ID: "host2", Name: "app002", Status: "working", CustomIdentifier: "i-0123", Roles: mackerel.Roles{"MyApp": {"db"}}},
+		{ID: "host3", Name: "app003", Status: "standby"},
+	}
+	specs := []*hostUpdateSpec{
+		{ID: "host1", DisplayName: "web", Status: "maintenance"},
+		{CustomIdentifier: "i-0123", RoleFullnames: []string{"MyApp:web", "MyApp:db"}},
+		{ID: "host3", Status: "standby"},
+		{ID: "host4", Name: "app004"},
+	}
+	updates, errs := planHostUpdates(hosts, specs)
+	if len(errs) != 1 || errs[0].Error() != "host not found: host4" {
+		t.Errorf("the host not found should be reported: %v", errs)
+	}
+	if len(updates) != 2 {
+		t.Fatalf("the changed hosts should be updated: %v", updates)
+	}
+
+	if expect := []string{`displayName: "" -> "web"`, "status: working -> maintenance"}; !reflect.DeepEqual(updates[0].diff(), expect) {
+		t.Errorf("diff should be %v but: %v", expect, updates[0].diff())
+	}
+	if expect := []string{"roleFullnames: [MyApp:db] -> [MyApp:db, MyApp:web]"}; !reflect.DeepEqual(updates[1].diff(), expect) {
+		t.Errorf("diff should be %v but: %v", expect, updates[1].diff())
+	}
+}
+
+type fakeHostUpdater struct {
+	calls []string
+}
+
+func (f *fakeHostUpdater) UpdateHostStatus(hostID string, status string) error {
+	f.calls = append(f.calls, "status "+hostID+" "+status)
+	return nil
+}
+
+func (f *fakeHostUpdater) UpdateHostRoleFullnames(hostID string, roleFullnames []string) error {
+	f.calls = append(f.calls, "roles "+hostID+" "+strings.Join(roleFullnames, ","))
+	return nil
+}
+
+func (f *fakeHostUpdater) UpdateHost(hostID string, param *mackerel.UpdateHostParam) (string, error) {
+	f.calls = append(f.calls, "host "+hostID+" "+param.Name+" "+strings.Join(param.RoleFullnames, ","))
+	return hostID, nil
+}
+
+func TestApplyHostUpdate(t *testing.T) {
+	host := &mackerel.Host{ID: "host1", Name: "app001", Status: "working", Roles: mackerel.Roles{"MyApp": {"web"}}}
+	testCases := []struct {
+		update *hostUpdate
+		expect []string
+	}{
+		{
+			update: &hostUpdate{host: host, name: "app002", status: "maintenance", roleFullnames: []string{"MyApp:db"}},
+			expect: []string{"status host1 maintenance", "host host1 app002 MyApp:db"},
+		},
+		{
+			update: &hostUpdate{host: host, name: "app001", status: "working", roleFullnames: []string{}},
+			expect: []string{"roles host1 "},
+		},
+	}
+	for i, tc := range testCases {
+		client := &fakeHostUpdater{}
+		if err := applyHostUpdate(client, tc.update); err != nil {
+			t.Errorf("[%d] err should be nil but: %s", i, err)
+		}
+		if !reflect.DeepEqual(client.calls, tc.expect) {
+			t.Errorf("[%d] calls should be %v but: %v", i, tc.expect, client.calls)
+		}
+	}
+}
@@ -0,0 +1,156 @@
+package users
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+// usersClient is the client of the users and invitations API.
+type usersClient interface {
+	FindUsers() ([]*mackerel.User, error)
+	DeleteUser(userID string) (*mackerel.User, error)
+	FindInvitations() ([]*mackerel.Invitation, error)
+	CreateInvitation(param *mackerel.Invitation) (*mackerel.Invitation, error)
+	RevokeInvitation(email string) error
+}
+
+// apiClient implements the invitation APIs which mackerel-client-go does not support yet.
+type apiClient struct {
+	*mackerel.Client
+}
+
+func (c *apiClient) CreateInvitation(param *mackerel.Invitation) (*mackerel.Invitation, error) {
+	var invitation mackerel.Invitation
+	if err := mackerelclient.RequestJSON(c.Client, "POST", "/api/v0/invitations", url.Values{}, param, &invitation); err != nil {
+		return nil, err
+	}
+	return &invitation, nil
+}
+
+func (c *apiClient) RevokeInvitation(email string) error {
+	return mackerelclient.RequestJSON(c.Client, "POST", "/api/v0/invitations/revoke", url.Values{},
+		map[string]string{"email": email}, nil)
+}
+
+type appLogger interface {
+	Log(string, string)
+}
+
+type usersApp struct {
+	client    usersClient
+	logger    appLogger
+	outStream io.Writer
+}
+
+func formatTime(epoch int64) string {
+	if epoch == 0 {
+		return ""
+	}
+	return format.ISO8601Extended(time.Unix(epoch, 0))
+}
+
+func (app *usersApp) listUsers(output *format.Output) error {
+	users, err := app.client.FindUsers()
+	if err != nil {
+		return err
+	}
+	return output.Print(app.outStream, users, func() *format.Table {
+		t := format.NewTable("ID", "SCREEN_NAME", "EMAIL", "AUTHORITY", "MFA", "JOINED_AT")
+		for _, u := range users {
+			t.Append(u.ID, u.ScreenName, u.Email, u.Authority, strconv.FormatBool(u.IsMFAEnabled), formatTime(u.JoinedAt))
+		}
+		return t
+	})
+}
+
+var userColumns = []string{"id", "screen-name", "email", "authority", "mfa-enabled", "authentication-methods", "in-registration", "joined-at"}
+
+// exportUsers writes the members with a header row for compliance reporting.
+func (app *usersApp) exportUsers(formatName string) error {
+	var delimiter rune
+	switch formatName {
+	case "", "csv":
+		delimiter = ','
+	case "tsv":
+		delimiter = '\t'
+	default:
+		return fmt.Errorf("format should be 'csv' or 'tsv': %s", formatName)
+	}
+	users, err := app.client.FindUsers()
+	if err != nil {
+		return err
+	}
+	w := csv.NewWriter(app.outStream)
+	w.Comma = delimiter
+	w.Write(userColumns)
+	for _, u := range users {
+		w.Write([]string{
+			u.ID, u.ScreenName, u.Email, u.Authority,
+			strconv.FormatBool(u.IsMFAEnabled),
+			strings.Join(u.AuthenticationMethods, " "),
+			strconv.FormatBool(u.IsInRegistrationProcess),
+			formatTime(u.JoinedAt),
+		})
+	}
+	w.Flush()
+	return w.Error()
+}
+
+func (app *usersApp) deleteUser(id string) error {
+	user, err := app.client.DeleteUser(id)
+	if err != nil {
+		return err
+	}
+	app.logger.Log("deleted", fmt.Sprintf("%s <%s> (%s)", user.ScreenName, user.Email, user.ID))
+	return nil
+}
+
+func (app *usersApp) listInvitations(output *format.Output) error {
+	invitations, err := app.client.FindInvitations()
+	if err != nil {
+		return err
+	}
+	return output.Print(app.outStream, invitations, func() *format.Table {
+		t := format.NewTable("EMAIL", "AUTHORITY", "EXPIRES_AT")
+		for _, i := range invitations {
+			t.Append(i.Email, i.Authority, formatTime(i.ExpiresAt))
+		}
+		return t
+	})
+}
+
+var authorities = []string{"manager", "collaborator", "viewer"}
+
+func (app *usersApp) createInvitation(email, authority string) error {
+	valid := false
+	for _, a := range authorities {
+		valid = valid || a == authority
+	}
+	if !valid {
+		return fmt.Errorf("authority should be one of %s: %s", strings.Join(authorities, ", "), authority)
+	}
+	invitation, err := app.client.CreateInvitation(&mackerel.Invitation{Email: email, Authority: authority})
+	if err != nil {
+		return err
+	}
+	app.logger.Log("invited", fmt.Sprintf("%s as %s", invitation.Email, invitation.Authority))
+	return nil
+}
+
+func (app *usersApp) revokeInvitation(email string) error {
+	if err := app.client.RevokeInvitation(email); err != nil {
+		return err
+	}
+	app.logger.Log("revoked", email)
+	return nil
+}
@@ -0,0 +1,144 @@
+package users
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/mackerelio/mackerel-client-go"
+
+	"github.com/mackerelio/mkr/format"
+)
+
+type testLogger struct {
+	w io.Writer
+}
+
+func (l *testLogger) Log(prefix, message string) {
+	fmt.Fprintln(l.w, prefix, message)
+}
+
+type fakeClient struct {
+	users       []*mackerel.User
+	invitations []*mackerel.Invitation
+	requests    []string
+}
+
+func (c *fakeClient) FindUsers() ([]*mackerel.User, error) {
+	return c.users, nil
+}
+
+func (c *fakeClient) DeleteUser(userID string) (*mackerel.User, error) {
+	c.requests = append(c.requests, "delete "+userID)
+	for _, u := range c.users {
+		if u.ID == userID {
+			return u, nil
+		}
+	}
+	return nil, fmt.Errorf("user not found: %s", userID)
+}
+
+func (c *fakeClient) FindInvitations() ([]*mackerel.Invitation, error) {
+	return c.invitations, nil
+}
+
+func (c *fakeClient) CreateInvitation(param *mackerel.Invitation) (*mackerel.Invitation, error) {
+	c.requests = append(c.requests, "invite "+param.Email+" "+param.Authority)
+	return param, nil
+}
+
+func (c *fakeClient) RevokeInvitation(email string) error {
+	c.requests = append(c.requests, "revoke "+email)
+	return nil
+}
+
+var testUsers = []*mackerel.User{
+	{
+		ID: "4TTLxv3dPbP", ScreenName: "alice", Email: "alice@example.com", Authority: "owner",
+		IsMFAEnabled: true, AuthenticationMethods: []string{"password", "github"}, JoinedAt: 1609462800,
+	},
+	{
+		ID: "4TTLxv3dPbQ", ScreenName: "bob", Email: "bob@example.com", Authority: "viewer",
+		AuthenticationMethods: []string{"google"}, JoinedAt: 1609549200,
+	},
+}
+
+func TestUsersApp_Users(t *testing.T) {
+	time.Local = time.UTC
+	client := &fakeClient{users: testUsers}
+	out := new(bytes.Buffer)
+	app := &usersApp{client: client, logger: &testLogger{out}, outStream: out}
+
+	assert.NoError(t, app.listUsers(&format.Output{Format: format.OutputTable}))
+	assert.Equal(t, `ID           SCREEN_NAME  EMAIL              AUTHORITY  MFA    JOINED_AT
+4TTLxv3dPbP  alice        alice@example.com  owner      true   2021-01-01T01:00:00+00:00
+4TTLxv3dPbQ  bob          bob@example.com    viewer     false  2021-01-02T01:00:00+00:00
+`, out.String())
+
+	out.Reset()
+	output, err := format.ParseOutput(`go-template={{range .}}{{.screenName}} {{.joinedAt}}{{"\n"}}{{end}}`)
+	assert.NoError(t, err)
+	assert.NoError(t, app.listUsers(output))
+	assert.Equal(t, "alice 1609462800\nbob 1609549200\n", out.String())
+
+	out.Reset()
+	assert.NoError(t, app.exportUsers("csv"))
+	assert.Equal(t, `id,screen-name,email,authority,mfa-enabled,authentication-methods,in-registration,joined-at
+4TTLxv3dPbP,alice,alice@example.com,owner,true,password github,false,2021-01-01T01:00:00+00:00
+4TTLxv3dPbQ,bob,bob@example.com,viewer,false,google,false,2021-01-02T01:00:00+00:00
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, app.deleteUser("4TTLxv3dPbQ"))
+	assert.Equal(t, "deleted bob <bob@example.com> (4TTLxv3dPbQ)\n", out.String())
+}
+
+func TestUsersApp_Invitations(t *testing.T) {
+	time.Local = time.UTC
+	client := &fakeClient{invitations: []*mackerel.Invitation{{Email: "carol@example.com", Authority: "collaborator", ExpiresAt: 1610067600}}}
+	out := new(bytes.Buffer)
+	app := &usersApp{client: client, logger: &testLogger{out}, outStream: out}
+
+	assert.NoError(t, app.listInvitations(&format.Output{Format: format.OutputTable}))
+	assert.Equal(t, `EMAIL              AUTHORITY     EXPIRES_AT
+carol@example.com  collaborator  2021-01-08T01:00:00+00:00
+`, out.String())
+
+	out.Reset()
+	assert.NoError(t, app.createInvitation("dave@example.com", "viewer"))
+	assert.EqualError(t, app.createInvitation("dave@example.com", "owner"), "authority should be one of manager, collaborator, viewer: owner")
+	assert.NoError(t, app.revokeInvitation("carol@example.com"))
+	assert.Equal(t, "invited dave@example.com as viewer\nrevoked carol@example.com\n", out.String())
+	assert.Equal(t, []string{"invite dave@example.com viewer", "revoke carol@example.com"}, client.requests)
+}
+
+func TestAPIClient_Invitations(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		switch r.Method + " " + r.URL.Path {
+		case "POST /api/v0/invitations":
+			assert.JSONEq(t, `{"email": "dave@example.com", "authority": "viewer"}`, string(body))
+			fmt.Fprint(w, `{"email": "dave@example.com", "authority": "viewer", "expiresAt": 1610067600}`)
+		case "POST /api/v0/invitations/revoke":
+			assert.JSONEq(t, `{"email": "dave@example.com"}`, string(body))
+			fmt.Fprint(w, `{"success": true}`)
+		default:
+			t.Errorf("unexpected request: %s %s", r.Method, r.URL.Path)
+		}
+	}))
+	defer ts.Close()
+
+	client, _ := mackerel.NewClientWithOptions("dummy", ts.URL, false)
+	c := &apiClient{client}
+	invitation, err := c.CreateInvitation(&mackerel.Invitation{Email: "dave@example.com", Authority: "viewer"})
+	assert.NoError(t, err)
+	assert.Equal(t, int64(1610067600), invitation.ExpiresAt)
+	assert.NoError(t, c.RevokeInvitation("dave@example.com"))
+}
@@ -0,0 +1,180 @@
+package users
+
+import (
+	"os"
+
+	"github.com/Songmu/prompter"
+	"github.com/urfave/cli"
+
+	"github.com/mackerelio/mkr/format"
+	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
+)
+
+// Command is the definition of users subcommand
+var Command = cli.Command{
+	Name:      "users",
+	Usage:     "List users",
+	ArgsUsage: "[--output | -o <format>]",
+	Description: `
+    List the users of the organization. With a subcommand, export or delete the users.
+    Requests APIs under "/api/v0/users". See https://mackerel.io/api-docs/entry/users .
+`,
+	Action: doUsers,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+	},
+	Subcommands: []cli.Command{
+		{
+			Name:      "list",
+			Usage:     "List users",
+			ArgsUsage: "[--output | -o <format>]",
+			Description: `
+    List the users of the organization.
+`,
+			Action: doUsers,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+			},
+		},
+		{
+			Name:      "export",
+			Usage:     "Export users",
+			ArgsUsage: "[--format csv|tsv]",
+			Description: `
+    Export the users with a header row for compliance reporting. The columns are
+    id, screen-name, email, authority, mfa-enabled, authentication-methods, in-registration and joined-at.
+`,
+			Action: doExportUsers,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "format", Value: "csv", Usage: "Output format: 'csv' or 'tsv'"},
+			},
+		},
+		{
+			Name:      "delete",
+			Usage:     "Delete a user",
+			ArgsUsage: "[--force] <userId>",
+			Description: `
+    Delete the user from the organization.
+    Requests "DELETE /api/v0/users/<userId>". See https://mackerel.io/api-docs/entry/users#delete.
+`,
+			Action: doDeleteUser,
+			Flags: []cli.Flag{
+				cli.BoolFlag{Name: "force", Usage: "Delete the user without confirmation"},
+			},
+		},
+	},
+}
+
+// CommandInvitations is the definition of invitations subcommand
+var CommandInvitations = cli.Command{
+	Name:      "invitations",
+	Usage:     "List invitations",
+	ArgsUsage: "[--output | -o <format>]",
+	Description: `
+    List the pending invitations to the organization. With a subcommand, create or revoke the invitations.
+    Requests APIs under "/api/v0/invitations". See https://mackerel.io/api-docs/entry/invitations .
+`,
+	Action: doInvitations,
+	Flags: []cli.Flag{
+		cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+	},
+	Subcommands: []cli.Command{
+		{
+			Name:      "list",
+			Usage:     "List invitations",
+			ArgsUsage: "[--output | -o <format>]",
+			Description: `
+    List the pending invitations to the organization.
+`,
+			Action: doInvitations,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "output, o", Value: "table", Usage: format.OutputUsage},
+			},
+		},
+		{
+			Name:      "create",
+			Usage:     "Invite a user",
+			ArgsUsage: "--email <email> --authority manager|collaborator|viewer",
+			Description: `
+    Invite a user to the organization.
+    Requests "POST /api/v0/invitations". See https://mackerel.io/api-docs/entry/invitations#create.
+`,
+			Action: doCreateInvitation,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "email", Value: "", Usage: "The email address to invite"},
+				cli.StringFlag{Name: "authority", Value: "", Usage: "The authority of the user: 'manager', 'collaborator' or 'viewer'"},
+			},
+		},
+		{
+			Name:      "revoke",
+			Usage:     "Revoke an invitation",
+			ArgsUsage: "--email <email>",
+			Description: `
+    Revoke the invitation to the email address.
+    Requests "POST /api/v0/invitations/revoke". See https://mackerel.io/api-docs/entry/invitations#revoke.
+`,
+			Action: doRevokeInvitation,
+			Flags: []cli.Flag{
+				cli.StringFlag{Name: "email", Value: "", Usage: "The email address of the invitation"},
+			},
+		},
+	},
+}
+
+func newUsersApp(c *cli.Context) *usersApp {
+	return &usersApp{
+		client:    &apiClient{mackerelclient.NewFromContext(c)},
+		logger:    logger.New(),
+		outStream: os.Stdout,
+	}
+}
+
+func doUsers(c *cli.Context) error {
+	output, err := format.OutputFromContext(c, "table")
+	if err != nil {
+		return err
+	}
+	return newUsersApp(c).listUsers(output)
+}
+
+func doExportUsers(c *cli.Context) error {
+	return newUsersApp(c).exportUsers(c.String("format"))
+}
+
+func doDeleteUser(c *cli.Context) error {
+	if len(c.Args()) != 1 {
+		cli.ShowCommandHelp(c, "delete")
+		os.Exit(1)
+	}
+	id := c.Args().Get(0)
+	if !c.Bool("force") && !prompter.YN("Delete the user "+id+" from the organization.\nAre you sure?", false) {
+		logger.Log("", "deletion is canceled.")
+		return nil
+	}
+	return newUsersApp(c).deleteUser(id)
+}
+
+func doInvitations(c *cli.Context) error {
+	output, err := format.OutputFromContext(c, "table")
+	if err != nil {
+		return err
+	}
+	return newUsersApp(c).listInvitations(output)
+}
+
+func doCreateInvitation(c *cli.Context) error {
+	if c.String("email") == "" || c.String("authority") == "" {
+		cli.ShowCommandHelp(c, "create")
+		os.Exit(1)
+	}
+	return newUsersApp(c).createInvitation(c.String("email"), c.String("authority"))
+}
+
+func doRevokeInvitation(c *cli.Context) error {
+	if c.String("email") == "" {
+		cli.ShowCommandHelp(c, "revoke")
+		os.Exit(1)
+	}
+	return newUsersApp(c).revokeInvitation(c.String("email"))
+}
@@ -3,9 +3,12 @@ package wrap
 import (
 	"fmt"
 	"os"
+	"strings"
+	"time"
 
 	"github.com/mackerelio/mackerel-agent/config"
 	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
 	"github.com/urfave/cli"
 )
 
@@ -18,16 +21,38 @@ var Command = cli.Command{
     Wrap a batch command with specifying it as arguments. If the command failed
     with non-zero exit code, it sends a report to Mackerel and raises an alert.
     It is useful for cron jobs etc.
+    The message of the report is truncated within --max-message-bytes, keeping the middle, the head or the
+    tail of the output by --truncate. With --save-output, the whole output of the failed command is saved
+    into the file or the directory, or uploaded to the URL with PUT such as a pre-signed URL of S3, and the
+    link is included in the message.
+    With --only-on-change, the failures are reported only when the last execution succeeded, and the success
+    is reported when the last execution failed. The success after failures is reported as OK with the number
+    of the consecutive failures, which closes the alert as --auto-close does. Use --notification-interval
+    to re-send the notification of the alert instead.
+    With --timeout, the command is terminated by SIGTERM when it runs longer than the duration,
+    and killed by SIGKILL after the grace period. The timeout is always reported as CRITICAL.
+    The signals which mkr receives are forwarded to the process group of the command.
+    With --metric-prefix, the execution duration in seconds, the exit code and the output size in bytes
+    are also posted as the custom host metrics custom.<prefix>.duration.seconds,
+    custom.<prefix>.exit_code.code and custom.<prefix>.output_size.bytes.
 `,
 	Action: doWrap,
 	Flags: []cli.Flag{
 		cli.StringFlag{Name: "name, n", Value: "", Usage: "The `check-name` which must be unique on a host. If it is empty it will be automatically derived."},
 		cli.BoolFlag{Name: "detail, d", Usage: "send a detailed report contains command output"},
+		cli.IntFlag{Name: "max-message-bytes", Value: 1024, Usage: "The maximum `bytes` of the message (maximum 1024)"},
+		cli.StringFlag{Name: "truncate", Value: "middle", Usage: "The part of the output to keep in the truncated message: 'middle', 'head' or 'tail'"},
+		cli.StringFlag{Name: "save-output", Value: "", Usage: "The file `path`, directory or URL to save the whole output of the failed command"},
 		cli.StringFlag{Name: "note, N", Value: "", Usage: "`note` of the job"},
-		cli.StringFlag{Name: "host, H", Value: "", Usage: "`hostID`"},
+		cli.StringFlag{Name: "host, H", Value: "", Usage: "`hostID`, or name:<name> to specify the host by the name"},
+		cli.StringFlag{Name: "host-name", Value: "", Usage: "The `name` of the host instead of --host"},
 		cli.BoolFlag{Name: "warning, w", Usage: "alerts as warning"},
 		cli.BoolFlag{Name: "auto-close, a", Usage: "automatically close an existing alert when the command success"},
+		cli.BoolFlag{Name: "only-on-change", Usage: "report only when the result changes, so that the consecutive failures do not alert every time"},
 		cli.DurationFlag{Name: "notification-interval, I", Usage: "The notification re-sending `interval`. If it is zero, never re-send. (minimum 10 minutes)"},
+		cli.DurationFlag{Name: "timeout, t", Usage: "The `duration` to terminate the command. If it is zero, never terminate."},
+		cli.DurationFlag{Name: "kill-grace-period", Value: 10 * time.Second, Usage: "The `duration` to wait before killing the command with SIGKILL after the timeout"},
+		cli.StringFlag{Name: "metric-prefix", Value: "", Usage: "The `prefix` of the custom metrics of the execution to post"},
 		// XXX Implementation of maxCheckAttempts is difficult because the
 		// execution interval of cron or batches are not always one-minute.
 		// This is due to the server-side logic of the Mackerel.
@@ -59,12 +84,24 @@ func doWrap(c *cli.Context) error {
 	if apikey == "" {
 		apikey = conf.Apikey
 	}
+	if profile := c.GlobalString("profile"); profile != "" || apikey == "" {
+		// the apikey of the profile, or the default profile when no apikeys are configured
+		if k, err := mackerelclient.ResolveApikey(confFile, profile); err == nil {
+			apikey = k.Value
+			apibase = mackerelclient.ResolveApibase(confFile, c.GlobalString("apibase"), profile)
+		} else if profile != "" {
+			logger.Logf("error", "[mkr wrap] %s", err)
+		}
+	}
 	if apikey == "" {
 		logger.Log("error", "[mkr wrap] failed to detect Mackerel APIKey. Try to specify in mackerel-agent.conf or export MACKEREL_APIKEY='<Your apikey>'")
 	}
 	var hostID string
-	if id := c.String("host"); id != "" {
-		hostID = id
+	if c.String("host") != "" || c.String("host-name") != "" {
+		var err error
+		if hostID, err = resolveHostID(c, apikey, apibase); err != nil {
+			logger.Logf("error", "[mkr wrap] %s", err)
+		}
 	} else {
 		hostID, _ = conf.LoadHostID()
 	}
@@ -83,6 +120,11 @@ func doWrap(c *cli.Context) error {
 		return fmt.Errorf("no commands specified")
 	}
 
+	truncation := c.String("truncate")
+	if !containsString(truncations, truncation) {
+		return fmt.Errorf("--truncate should be one of %s: %s", strings.Join(truncations, ", "), truncation)
+	}
+
 	return (&wrap{
 		apibase:              apibase,
 		name:                 c.String("name"),
@@ -90,7 +132,14 @@ func doWrap(c *cli.Context) error {
 		note:                 c.String("note"),
 		warning:              c.Bool("warning"),
 		autoClose:            c.Bool("auto-close"),
+		onlyOnChange:         c.Bool("only-on-change"),
 		notificationInterval: c.Duration("notification-interval"),
+		timeout:              c.Duration("timeout"),
+		killGracePeriod:      c.Duration("kill-grace-period"),
+		metricPrefix:         c.String("metric-prefix"),
+		maxMessageBytes:      c.Int("max-message-bytes"),
+		truncation:           truncation,
+		saveOutputTo:         c.String("save-output"),
 		hostID:               hostID,
 		apikey:               apikey,
 		cmd:                  cmd,
@@ -98,3 +147,25 @@ func doWrap(c *cli.Context) error {
 		errStream:            os.Stderr,
 	}).run()
 }
+
+// resolveHostID returns the host ID specified by --host or --host-name, resolving the name with the API.
+func resolveHostID(c *cli.Context, apikey, apibase string) (string, error) {
+	hostID := c.String("host")
+	if c.String("host-name") == "" && !strings.HasPrefix(hostID, mackerelclient.HostNamePrefix) {
+		return hostID, nil
+	}
+	client, err := mackerelclient.NewClient(apikey, apibase)
+	if err != nil {
+		return "", err
+	}
+	return mackerelclient.HostIDFromContext(c, client, "host")
+}
+
+func containsString(xs []string, s string) bool {
+	for _, x := range xs {
+		if x == s {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,66 @@
+package wrap
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/Songmu/retry"
+)
+
+// saveOutput saves the whole output of the command to the file or the URL, and returns the link to it.
+// The output is saved into a new file named after the check if the path is a directory.
+func (wr *wrap) saveOutput(re *result, now time.Time) (string, error) {
+	dest := wr.saveOutputTo
+	if strings.HasPrefix(dest, "http://") || strings.HasPrefix(dest, "https://") {
+		return uploadOutput(dest, re.Output)
+	}
+	if fi, err := os.Stat(dest); err == nil && fi.IsDir() {
+		dest = filepath.Join(dest, fmt.Sprintf("%s-%s.log", re.checkName(), now.Format("20060102150405")))
+	}
+	if err := ioutil.WriteFile(dest, []byte(re.Output), 0644); err != nil {
+		return "", err
+	}
+	return filepath.Abs(dest)
+}
+
+// uploadOutput uploads the output with PUT, which is supported by the pre-signed URLs
+// of S3-compatible storages. The link does not contain the query of the pre-signed URL.
+func uploadOutput(rawurl, output string) (string, error) {
+	u, err := url.Parse(rawurl)
+	if err != nil {
+		return "", err
+	}
+	u.RawQuery = ""
+	link := u.String()
+	cli := &http.Client{Timeout: 30 * time.Second}
+	err = retry.Retry(3, time.Second*3, func() error {
+		req, err := http.NewRequest("PUT", rawurl, strings.NewReader(output))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		resp, err := cli.Do(req)
+		if err != nil {
+			if uerr, ok := err.(*url.Error); ok {
+				// avoid logging the credentials in the query
+				err = uerr.Err
+			}
+			return err
+		}
+		defer resp.Body.Close()
+		if resp.StatusCode >= 300 {
+			return fmt.Errorf("status: %s", resp.Status)
+		}
+		return nil
+	})
+	if err != nil {
+		return "", fmt.Errorf("failed to upload the output to %s: %s", link, err)
+	}
+	return link, nil
+}
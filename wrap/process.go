@@ -0,0 +1,65 @@
+package wrap
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+	"time"
+)
+
+// exitCodeTimedOut is the exit code when the command timed out, which is the same as timeout(1).
+const exitCodeTimedOut = 124
+
+// forwardSignals relays the signals which mkr receives to the process group of the command.
+// It is used with the timeout, where the command does not receive the signals from the terminal
+// since it runs in its own group.
+func forwardSignals(cmd *exec.Cmd) (stop func()) {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP, syscall.SIGINT, syscall.SIGTERM, syscall.SIGQUIT)
+	done := make(chan struct{})
+	go func() {
+		for {
+			select {
+			case sig := <-sigCh:
+				signalGroup(cmd, sig.(syscall.Signal))
+			case <-done:
+				return
+			}
+		}
+	}()
+	return func() {
+		signal.Stop(sigCh)
+		close(done)
+	}
+}
+
+// enforceTimeout sends SIGTERM to the process group of the command after the timeout,
+// and SIGKILL if it is still running after the grace period.
+// The returned function must be called after the command exits, and reports whether it timed out.
+func enforceTimeout(cmd *exec.Cmd, timeout, grace time.Duration) (stop func() (timedOut bool)) {
+	if timeout <= 0 {
+		return func() bool { return false }
+	}
+	done := make(chan struct{})
+	timedOut := make(chan bool, 1)
+	go func() {
+		select {
+		case <-done:
+			timedOut <- false
+			return
+		case <-time.After(timeout):
+		}
+		signalGroup(cmd, syscall.SIGTERM)
+		select {
+		case <-done:
+		case <-time.After(grace):
+			signalGroup(cmd, syscall.SIGKILL)
+		}
+		timedOut <- true
+	}()
+	return func() bool {
+		close(done)
+		return <-timedOut
+	}
+}
@@ -0,0 +1,14 @@
+package wrap
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// setProcessGroup does nothing on Windows, where the process groups cannot be signaled.
+func setProcessGroup(cmd *exec.Cmd) {}
+
+// signalGroup terminates the command since the signals cannot be sent to the processes on Windows.
+func signalGroup(cmd *exec.Cmd, sig syscall.Signal) error {
+	return cmd.Process.Kill()
+}
@@ -11,17 +11,24 @@ import (
 	"regexp"
 	"strings"
 	"text/template"
+	"time"
 
 	"github.com/Songmu/wrapcommander"
+	"github.com/mackerelio/mackerel-client-go"
 )
 
 type result struct {
 	Cmd        []string
 	Name, Note string
 
-	Output   string `json:"-"`
-	ExitCode int
-	Signaled bool
+	Output     string `json:"-"`
+	OutputLink string `json:"-"`
+	ExitCode   int
+	Signaled   bool
+	TimedOut   bool
+	Duration   time.Duration
+	// FailureCount is the number of the consecutive failures including this result
+	FailureCount int
 
 	Msg     string
 	Success bool
@@ -84,6 +91,23 @@ func (re *result) saveResult() error {
 	return os.Rename(tmpf.Name(), fname)
 }
 
+var metricPrefixReg = regexp.MustCompile(`^[-a-zA-Z0-9_]+(?:\.[-a-zA-Z0-9_]+)*$`)
+
+// metricValues builds the custom metrics of the execution, each of which is
+// in its own graph such as custom.<prefix>.duration.seconds.
+func (re *result) metricValues(prefix string, now time.Time) ([]*mackerel.MetricValue, error) {
+	name := strings.TrimPrefix(prefix, "custom.")
+	if !metricPrefixReg.MatchString(name) {
+		return nil, fmt.Errorf("invalid metric prefix: %q", prefix)
+	}
+	name = "custom." + name
+	return []*mackerel.MetricValue{
+		{Name: name + ".duration.seconds", Time: now.Unix(), Value: re.Duration.Seconds()},
+		{Name: name + ".exit_code.code", Time: now.Unix(), Value: re.ExitCode},
+		{Name: name + ".output_size.bytes", Time: now.Unix(), Value: len(re.Output)},
+	}, nil
+}
+
 func (re *result) errorEnd(format string, err error) *result {
 	re.Msg = fmt.Sprintf(format, err)
 	re.ExitCode = wrapcommander.ResolveExitCode(err)
@@ -93,6 +117,8 @@ func (re *result) errorEnd(format string, err error) *result {
 const msgTplText = `{{.Msg}}
 {{- if ne .Note "" }}
 Note: {{.Note}}{{end}}
+{{- if ne .OutputLink "" }}
+Output: {{.OutputLink}}{{end}}
 % {{.Command}}
 {{- if .Detail }}
 {{.Output}}{{end}}`
@@ -103,18 +129,84 @@ func init() {
 	msgTpl = template.Must(template.New("msg").Parse(msgTplText))
 }
 
-func (re *result) buildMsg(detail bool) string {
+const messageLengthLimit = 1024 // https://mackerel.io/api-docs/entry/check-monitoring#post
+
+var truncations = []string{"middle", "head", "tail"}
+
+func (re *result) renderMsg(detail bool) string {
 	s := struct {
-		Msg, Note, Command, Output string
-		Detail                     bool
+		Msg, Note, OutputLink, Command, Output string
+		Detail                                 bool
 	}{
-		re.Msg, re.Note, strings.Join(re.Cmd, " "), re.Output,
+		re.Msg, re.Note, re.OutputLink, strings.Join(re.Cmd, " "), re.Output,
 		detail,
 	}
 	buf := &bytes.Buffer{}
 	template.Must(msgTpl.Clone()).Execute(buf, s)
-	const messageLengthLimit = 1024 // https://mackerel.io/api-docs/entry/check-monitoring#post
-	return truncate(buf.String(), messageLengthLimit, "\n...\n")
+	return buf.String()
+}
+
+// buildMsg builds the message within the limit bytes. The truncation is one of truncations,
+// which keeps both ends, the head, or the tail of the output. The lines before the output are
+// kept even in the tail truncation.
+func (re *result) buildMsg(detail bool, limit int, truncation string) string {
+	if limit <= 0 || messageLengthLimit < limit {
+		limit = messageLengthLimit
+	}
+	const sep = "\n...\n"
+	switch truncation {
+	case "head":
+		return fitBytes(keepHead, re.renderMsg(detail), limit, sep)
+	case "tail":
+		header := re.renderMsg(false)
+		if detail && len(header)+len(sep) < limit {
+			return header + fitBytes(keepTail, "\n"+re.Output, limit-len(header), sep)
+		}
+		return fitBytes(keepHead, re.renderMsg(detail), limit, sep)
+	default:
+		return fitBytes(truncate, re.renderMsg(detail), limit, sep)
+	}
+}
+
+// fitBytes applies the truncation by runes with the largest limit whose result fits in the limit bytes.
+func fitBytes(truncate func(string, int, string) string, src string, limit int, sep string) string {
+	if len(src) <= limit {
+		return src
+	}
+	lo, hi := 0, limit
+	for lo < hi {
+		mid := (lo + hi + 1) / 2
+		if len(truncate(src, mid, sep)) <= limit {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return truncate(src, lo, sep)
+}
+
+func keepHead(src string, limit int, sep string) string {
+	rs := []rune(src)
+	if len(rs) <= limit {
+		return src
+	}
+	seprs := []rune(sep)
+	if limit <= len(seprs) {
+		return string(seprs[:limit])
+	}
+	return string(rs[:limit-len(seprs)]) + sep
+}
+
+func keepTail(src string, limit int, sep string) string {
+	rs := []rune(src)
+	if len(rs) <= limit {
+		return src
+	}
+	seprs := []rune(sep)
+	if limit <= len(seprs) {
+		return string(seprs[:limit])
+	}
+	return sep + string(rs[len(rs)-(limit-len(seprs)):])
 }
 
 func truncate(src string, limit int, sep string) string {
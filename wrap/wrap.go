@@ -12,6 +12,7 @@ import (
 	"github.com/Songmu/wrapcommander"
 	"github.com/mackerelio/mackerel-client-go"
 	"github.com/mackerelio/mkr/logger"
+	"github.com/mackerelio/mkr/mackerelclient"
 	"github.com/urfave/cli"
 	"golang.org/x/sync/errgroup"
 )
@@ -22,7 +23,14 @@ type wrap struct {
 	note                 string
 	warning              bool
 	autoClose            bool
+	onlyOnChange         bool
 	notificationInterval time.Duration
+	timeout              time.Duration
+	killGracePeriod      time.Duration
+	metricPrefix         string
+	maxMessageBytes      int
+	truncation           string
+	saveOutputTo         string
 	hostID               string
 	apibase              string
 	apikey               string
@@ -33,11 +41,26 @@ type wrap struct {
 
 func (wr *wrap) run() error {
 	re := wr.runCmd()
+	if !re.Success && wr.saveOutputTo != "" {
+		link, err := wr.saveOutput(re, time.Now())
+		if err != nil {
+			logger.Logf("error", "failed to save the output of the command: %s", err)
+		}
+		re.OutputLink = link
+	}
 	if err := wr.report(re); err != nil {
 		logger.Logf("error", "failed to post following report to Mackerel: %s\n%s",
-			err, re.buildMsg(wr.detail))
+			err, wr.buildMsg(re))
+	}
+	if wr.metricPrefix != "" {
+		if err := wr.postMetrics(re); err != nil {
+			logger.Logf("error", "failed to post metrics of the command to Mackerel: %s", err)
+		}
 	}
 	if !re.Success {
+		if re.TimedOut {
+			return cli.NewExitError(re.Msg, exitCodeTimedOut)
+		}
 		return cli.NewExitError(re.Msg, re.ExitCode)
 	}
 	return nil
@@ -45,6 +68,11 @@ func (wr *wrap) run() error {
 
 func (wr *wrap) runCmd() *result {
 	cmd := exec.Command(wr.cmd[0], wr.cmd[1:]...)
+	// the command runs in its own process group only with the timeout to terminate its descendants together,
+	// otherwise it stays in the foreground group of the terminal
+	if wr.timeout > 0 {
+		setProcessGroup(cmd)
+	}
 	re := &result{
 		Cmd:  wr.cmd,
 		Name: wr.name,
@@ -67,10 +95,16 @@ func (wr *wrap) runCmd() *result {
 	stdoutPipe2 := io.TeeReader(stdoutPipe, bufMerged)
 	stderrPipe2 := io.TeeReader(stderrPipe, bufMerged)
 
+	startedAt := time.Now()
 	err = cmd.Start()
 	if err != nil {
 		return re.errorEnd("command invocation failed with follwing error: %s", err)
 	}
+	if wr.timeout > 0 {
+		stopForwarding := forwardSignals(cmd)
+		defer stopForwarding()
+	}
+	stopTimeout := enforceTimeout(cmd, wr.timeout, wr.killGracePeriod)
 	eg := &errgroup.Group{}
 
 	eg.Go(func() error {
@@ -86,6 +120,8 @@ func (wr *wrap) runCmd() *result {
 	eg.Wait()
 
 	cmdErr := cmd.Wait()
+	re.Duration = time.Since(startedAt)
+	re.TimedOut = stopTimeout()
 	re.ExitCode = wrapcommander.ResolveExitCode(cmdErr)
 	if re.ExitCode > 128 {
 		w, ok := wrapcommander.ErrorToWaitStatus(cmdErr)
@@ -98,14 +134,19 @@ func (wr *wrap) runCmd() *result {
 	} else {
 		re.Msg = fmt.Sprintf("command died with signal: %d", re.ExitCode&127)
 	}
+	if re.TimedOut {
+		re.Msg = fmt.Sprintf("command timed out after %s (%s)", wr.timeout, re.Msg)
+	}
 	re.Output = bufMerged.String()
 
-	re.Success = re.ExitCode == 0
+	re.Success = re.ExitCode == 0 && !re.TimedOut
 	return re
 }
 
 func (wr *wrap) report(re *result) error {
-	if wr.autoClose {
+	// the last result is needed to detect the changes of the status
+	tracked := wr.autoClose || wr.onlyOnChange
+	if tracked {
 		defer func() {
 			err := re.saveResult()
 			if err != nil {
@@ -114,24 +155,42 @@ func (wr *wrap) report(re *result) error {
 		}()
 	}
 
-	if wr.apikey == "" || wr.hostID == "" {
-		return fmt.Errorf("Both of apikey and hostID are needed to report result to Mackerel")
-	}
 	var lastRe *result
-	if wr.autoClose {
+	if tracked {
 		var err error
 		lastRe, err = re.loadLastResult()
 		if err != nil {
 			// resultFile something went wrong.
 			// It may be no permission, broken json, not a normal file, and so on.
 			// Though it is rough, try to delete as workaround.
+			lastRe = nil
 			err := os.RemoveAll(re.resultFile())
 			if err != nil {
 				return err
 			}
 		}
 	}
-	if !re.Success || wr.autoClose && (lastRe == nil || !lastRe.Success) {
+	lastFailed := lastRe != nil && !lastRe.Success
+	if !re.Success {
+		re.FailureCount = 1
+		if lastFailed {
+			re.FailureCount = lastRe.FailureCount + 1
+		}
+	} else if lastFailed && lastRe.FailureCount > 0 {
+		re.Msg = fmt.Sprintf("%s (recovered after %d consecutive failures)", re.Msg, lastRe.FailureCount)
+	}
+
+	if wr.apikey == "" || wr.hostID == "" {
+		return fmt.Errorf("Both of apikey and hostID are needed to report result to Mackerel")
+	}
+	if !re.Success {
+		if wr.onlyOnChange && lastFailed {
+			// the alert has already been raised by the previous failure
+			return nil
+		}
+		return wr.doReport(re)
+	}
+	if tracked && (lastRe == nil || lastFailed) {
 		return wr.doReport(re)
 	}
 	return nil
@@ -140,7 +199,8 @@ func (wr *wrap) report(re *result) error {
 func (wr *wrap) doReport(re *result) error {
 	checkSt := mackerel.CheckStatusOK
 	if !re.Success {
-		if wr.warning {
+		// the timeout is always critical since the job may be hung
+		if wr.warning && !re.TimedOut {
 			checkSt = mackerel.CheckStatusWarning
 		} else {
 			checkSt = mackerel.CheckStatusCritical
@@ -158,12 +218,12 @@ func (wr *wrap) doReport(re *result) error {
 				Name:                 re.checkName(),
 				Status:               checkSt,
 				OccurredAt:           time.Now().Unix(),
-				Message:              re.buildMsg(wr.detail),
+				Message:              wr.buildMsg(re),
 				NotificationInterval: niInMinutes,
 			},
 		},
 	}
-	mcli, err := mackerel.NewClientWithOptions(wr.apikey, wr.apibase, false)
+	mcli, err := mackerelclient.NewClient(wr.apikey, wr.apibase)
 	if err != nil {
 		return err
 	}
@@ -171,3 +231,24 @@ func (wr *wrap) doReport(re *result) error {
 		return mcli.PostCheckReports(payload)
 	})
 }
+
+func (wr *wrap) buildMsg(re *result) string {
+	return re.buildMsg(wr.detail, wr.maxMessageBytes, wr.truncation)
+}
+
+func (wr *wrap) postMetrics(re *result) error {
+	if wr.apikey == "" || wr.hostID == "" {
+		return fmt.Errorf("Both of apikey and hostID are needed to post metrics to Mackerel")
+	}
+	values, err := re.metricValues(wr.metricPrefix, time.Now())
+	if err != nil {
+		return err
+	}
+	mcli, err := mackerelclient.NewClient(wr.apikey, wr.apibase)
+	if err != nil {
+		return err
+	}
+	return retry.Retry(3, time.Second*3, func() error {
+		return mcli.PostHostMetricValuesByHostID(wr.hostID, values)
+	})
+}
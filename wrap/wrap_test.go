@@ -3,12 +3,16 @@ package wrap
 import (
 	"encoding/json"
 	"flag"
+	"fmt"
 	"io/ioutil"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"reflect"
 	"strings"
 	"testing"
+	"time"
 
 	"github.com/mackerelio/mackerel-client-go"
 	"github.com/urfave/cli"
@@ -141,6 +145,44 @@ Note: This is note
 			},
 			ExitCode: 0,
 		},
+		{
+			Name: "timeout",
+			Args: []string{
+				"-name=test-check4",
+				"-host=xxx",
+				"-warning",
+				"-timeout", "1s",
+				"--",
+				"sh", "-c", "sleep 10",
+			},
+			Result: testResult{
+				Name:   "test-check4",
+				Status: mackerel.CheckStatusCritical,
+				Message: `command timed out after 1s (command died with signal: 15)
+% sh -c sleep 10`,
+				NotificationInterval: 0,
+			},
+			ExitCode: 124,
+		},
+		{
+			Name: "kill after grace period",
+			Args: []string{
+				"-name=test-check5",
+				"-host=xxx",
+				"-timeout", "1s",
+				"-kill-grace-period", "1s",
+				"--",
+				"sh", "-c", `trap "" TERM; sleep 10`,
+			},
+			Result: testResult{
+				Name:   "test-check5",
+				Status: mackerel.CheckStatusCritical,
+				Message: `command timed out after 1s (command died with signal: 9)
+% sh -c trap "" TERM; sleep 10`,
+				NotificationInterval: 0,
+			},
+			ExitCode: 124,
+		},
 	}
 
 	for _, tc := range testCases {
@@ -289,3 +331,226 @@ func Test_truncate(t *testing.T) {
 		}
 	}
 }
+
+func TestCommand_Action_metrics(t *testing.T) {
+	var metrics []*mackerel.HostMetricValue
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.URL.Path == "/api/v0/tsdb" {
+			if err := json.NewDecoder(req.Body).Decode(&metrics); err != nil {
+				t.Fatal("request body should be decoded as json", err)
+			}
+		}
+		res.Header()["Content-Type"] = []string{"application/json"}
+		json.NewEncoder(res).Encode(map[string]bool{
+			"success": true,
+		})
+	}))
+	defer ts.Close()
+
+	c := newWrapContext([]string{
+		"-conf=testdata/dummy.conf", "-apibase", ts.URL, "wrap",
+		"-name=test-metrics", "-host=xxx", "-metric-prefix=batch.backup", "--",
+		"go", "run", "testdata/stub.go",
+	})
+	Command.Action.(func(*cli.Context) error)(c)
+
+	if len(metrics) != 3 {
+		t.Fatalf("3 metrics should be posted but: %d", len(metrics))
+	}
+	expect := map[string]interface{}{
+		"custom.batch.backup.exit_code.code":    float64(1),
+		"custom.batch.backup.output_size.bytes": float64(len("Hello.\nexit status 1\n")),
+	}
+	for _, m := range metrics {
+		if m.HostID != "xxx" {
+			t.Errorf("hostId should be xxx but: %s", m.HostID)
+		}
+		if m.Name == "custom.batch.backup.duration.seconds" {
+			if v, ok := m.Value.(float64); !ok || v <= 0 {
+				t.Errorf("duration should be positive but: %v", m.Value)
+			}
+			continue
+		}
+		if !reflect.DeepEqual(m.Value, expect[m.Name]) {
+			t.Errorf("value of %s should be %v but: %v", m.Name, expect[m.Name], m.Value)
+		}
+	}
+}
+
+func Test_metricValues(t *testing.T) {
+	re := &result{ExitCode: 2, Output: "Hello.\n", Duration: 1500 * time.Millisecond}
+	now := time.Unix(1600000000, 0)
+	for _, prefix := range []string{"backup", "custom.backup"} {
+		got, err := re.metricValues(prefix, now)
+		if err != nil {
+			t.Fatalf("error should not be occurred but: %s", err)
+		}
+		expect := []*mackerel.MetricValue{
+			{Name: "custom.backup.duration.seconds", Time: 1600000000, Value: 1.5},
+			{Name: "custom.backup.exit_code.code", Time: 1600000000, Value: 2},
+			{Name: "custom.backup.output_size.bytes", Time: 1600000000, Value: 7},
+		}
+		if !reflect.DeepEqual(got, expect) {
+			t.Errorf("metricValues(%q) should be %+v but: %+v", prefix, expect, got)
+		}
+	}
+	for _, prefix := range []string{"", "custom.", "back up", "backup."} {
+		if _, err := re.metricValues(prefix, now); err == nil {
+			t.Errorf("metricValues(%q) should be an error", prefix)
+		}
+	}
+}
+
+func TestWrap_report_onlyOnChange(t *testing.T) {
+	var reports []testReport
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		var treq struct {
+			Reports []testReport `json:"reports"`
+		}
+		if err := json.NewDecoder(req.Body).Decode(&treq); err != nil {
+			t.Fatal("request body should be decoded as json", err)
+		}
+		reports = append(reports, treq.Reports...)
+		res.Header()["Content-Type"] = []string{"application/json"}
+		json.NewEncoder(res).Encode(map[string]bool{
+			"success": true,
+		})
+	}))
+	defer ts.Close()
+
+	wr := &wrap{
+		onlyOnChange: true,
+		apibase:      ts.URL,
+		apikey:       "dummy",
+		hostID:       "xxx",
+	}
+	newResult := func(exitCode int) *result {
+		return &result{
+			Cmd:      []string{"backup"},
+			Name:     "test-only-on-change",
+			ExitCode: exitCode,
+			Msg:      fmt.Sprintf("command exited with code: %d", exitCode),
+			Success:  exitCode == 0,
+		}
+	}
+	defer os.Remove(newResult(0).resultFile())
+	os.Remove(newResult(0).resultFile())
+
+	for _, exitCode := range []int{1, 1, 1, 0, 0, 2} {
+		if err := wr.report(newResult(exitCode)); err != nil {
+			t.Fatalf("error should not be occurred but: %s", err)
+		}
+	}
+	expect := []testReport{
+		{Status: mackerel.CheckStatusCritical, Message: "command exited with code: 1\n% backup"},
+		{Status: mackerel.CheckStatusOK, Message: "command exited with code: 0 (recovered after 3 consecutive failures)\n% backup"},
+		{Status: mackerel.CheckStatusCritical, Message: "command exited with code: 2\n% backup"},
+	}
+	if !reflect.DeepEqual(reports, expect) {
+		t.Errorf("something went wrong.\n   got: %+v,\nexpect: %+v", reports, expect)
+	}
+}
+
+type testReport struct {
+	Status  mackerel.CheckStatus `json:"status"`
+	Message string               `json:"message"`
+}
+
+func TestResult_buildMsg(t *testing.T) {
+	re := &result{
+		Cmd:        []string{"backup"},
+		Msg:        "command exited with code: 1",
+		OutputLink: "/var/log/backup.log",
+		Output:     "0123456789abcdefghij",
+	}
+	testCases := []struct {
+		truncation string
+		detail     bool
+		limit      int
+		expected   string
+	}{
+		{"middle", true, 0, "command exited with code: 1\nOutput: /var/log/backup.log\n% backup\n0123456789abcdefghij"},
+		{"middle", false, 40, "command exited wi\n...\nackup.log\n% backup"},
+		{"middle", true, 70, "command exited with code: 1\nOutp\n...\nlog\n% backup\n0123456789abcdefghij"},
+		{"head", true, 75, "command exited with code: 1\nOutput: /var/log/backup.log\n% backup\n01234\n...\n"},
+		{"tail", true, 75, "command exited with code: 1\nOutput: /var/log/backup.log\n% backup\n...\nefghij"},
+		{"tail", true, 64, "command exited with code: 1\nOutput: /var/log/backup.log\n% b\n...\n"},
+	}
+	for _, tc := range testCases {
+		got := re.buildMsg(tc.detail, tc.limit, tc.truncation)
+		if got != tc.expected {
+			t.Errorf("buildMsg(%t, %d, %q) should be %q but got: %q", tc.detail, tc.limit, tc.truncation, tc.expected, got)
+		}
+	}
+}
+
+func Test_fitBytes(t *testing.T) {
+	testCases := []struct {
+		truncate func(string, int, string) string
+		src      string
+		limit    int
+		expected string
+	}{
+		{truncate, "Hello, world!", 10, "He ... ld!"},
+		{keepHead, "Hello, world!", 10, "Hello ... "},
+		{keepTail, "Hello, world!", 10, " ... orld!"},
+		{truncate, "こんにちは、世界", 15, "こん..世界"},
+		{keepHead, "こんにちは、世界", 16, "こんにち.."},
+		{keepTail, "こんにちは、世界", 16, "..は、世界"},
+	}
+	for _, tc := range testCases {
+		sep := " ... "
+		if strings.ContainsRune(tc.src, 'こ') {
+			sep = ".."
+		}
+		got := fitBytes(tc.truncate, tc.src, tc.limit, sep)
+		if got != tc.expected {
+			t.Errorf("fitBytes(%q, %d) should be %q but got: %q", tc.src, tc.limit, tc.expected, got)
+		}
+		if len(got) > tc.limit {
+			t.Errorf("length of fitBytes(%q, %d) should not exceed %d but got: %d", tc.src, tc.limit, tc.limit, len(got))
+		}
+	}
+}
+
+func TestWrap_saveOutput(t *testing.T) {
+	re := &result{Cmd: []string{"backup"}, Name: "test-save-output", Output: "Hello.\n"}
+	now := time.Date(2020, 9, 1, 12, 34, 56, 0, time.UTC)
+
+	dir, err := ioutil.TempDir("", "mkrwrap-test")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	link, err := (&wrap{saveOutputTo: dir}).saveOutput(re, now)
+	if err != nil {
+		t.Fatalf("error should not be occurred but: %s", err)
+	}
+	expect := filepath.Join(dir, "test-save-output-20200901123456.log")
+	if link != expect {
+		t.Errorf("link should be %s but: %s", expect, link)
+	}
+	if b, _ := ioutil.ReadFile(link); string(b) != re.Output {
+		t.Errorf("output should be saved but: %q", string(b))
+	}
+
+	var uploaded string
+	ts := httptest.NewServer(http.HandlerFunc(func(res http.ResponseWriter, req *http.Request) {
+		if req.Method != "PUT" {
+			t.Errorf("request method should be PUT but: %s", req.Method)
+		}
+		body, _ := ioutil.ReadAll(req.Body)
+		uploaded = string(body)
+	}))
+	defer ts.Close()
+	link, err = (&wrap{saveOutputTo: ts.URL + "/logs/backup.log?X-Amz-Signature=secret"}).saveOutput(re, now)
+	if err != nil {
+		t.Fatalf("error should not be occurred but: %s", err)
+	}
+	if expect := ts.URL + "/logs/backup.log"; link != expect {
+		t.Errorf("link should be %s but: %s", expect, link)
+	}
+	if uploaded != re.Output {
+		t.Errorf("output should be uploaded but: %q", uploaded)
+	}
+}